@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/alexandre-normand/glukit/app/auth"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/tasks"
+	"google.golang.org/appengine"
+	"net/http"
+)
+
+const (
+	// The user must confirm the deletion of its account by giving its email in this field
+	ACCOUNT_DELETION_CONFIRMATION_FIELD = "confirm"
+
+	PURGE_ACCOUNT_FUNCTION_NAME = "purgeAccountBatch"
+)
+
+// purgeAccountChunk schedules itself until the purge is done so it's declared in init to avoid an initialization loop
+var purgeAccountChunk *tasks.Function
+
+func init() {
+	purgeAccountChunk = tasks.Func(PURGE_ACCOUNT_FUNCTION_NAME, purgeAccount)
+}
+
+// requestAccountDeletion deletes the account of the logged in user along with all of its data. Since this can't be
+// undone, the user must confirm it by giving its email in the confirm field. Credentials are revoked right away and
+// the data is purged in the background. The status of the deletion is returned.
+func requestAccountDeletion(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := auth.CurrentUser(request)
+
+	if request.FormValue(ACCOUNT_DELETION_CONFIRMATION_FIELD) != user.Email {
+		http.Error(writer, "Account deletion must be confirmed with the account's email in the ["+ACCOUNT_DELETION_CONFIRMATION_FIELD+"] field", 400)
+		return
+	}
+
+	deletion, err := store.RequestAccountDeletion(context, user.Email)
+	if err != nil {
+		log.Warningf(context, "Error requesting deletion of account of user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error requesting account deletion", 500)
+		return
+	}
+
+	if err = purgeAccountChunk.Add(context, DATASTORE_WRITES_QUEUE_NAME, user.Email); err != nil {
+		log.Warningf(context, "Error queuing purge of account of user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error queuing account deletion", 500)
+		return
+	}
+
+	writeAccountDeletion(writer, http.StatusAccepted, *deletion)
+}
+
+// accountDeletionStatus returns the status of the deletion of the logged in user's account. Once the purge is
+// completed, this is the confirmation that nothing is left.
+func accountDeletionStatus(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := auth.CurrentUser(request)
+
+	deletion, err := store.GetAccountDeletion(context, user.Email)
+	if err == store.ErrNoSuchEntity {
+		http.Error(writer, "No account deletion requested", 404)
+		return
+	} else if err != nil {
+		log.Warningf(context, "Error getting account deletion of user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error getting account deletion", 500)
+		return
+	}
+
+	writeAccountDeletion(writer, http.StatusOK, *deletion)
+}
+
+// purgeAccount deletes a batch of the data of an account pending deletion and schedules the next batch until
+// everything is gone. Errors are returned so that the task is retried.
+func purgeAccount(context context.Context, email string) (err error) {
+	done, err := store.PurgeAccountBatch(context, email)
+	if err != nil {
+		log.Warningf(context, "Error purging account of user [%s]: %v", email, err)
+		return err
+	}
+
+	if done {
+		log.Infof(context, "Done purging account of user [%s]", email)
+		return nil
+	}
+
+	return purgeAccountChunk.Add(context, DATASTORE_WRITES_QUEUE_NAME, email)
+}
+
+// resumeAccountPurges schedules the purge of accounts whose deletion was interrupted (i.e. by a restart of the
+// standalone server whose queues don't outlive the process)
+func resumeAccountPurges(context context.Context) (err error) {
+	deletions, err := store.FindPendingAccountDeletions(context)
+	if err != nil {
+		return err
+	}
+
+	for _, deletion := range deletions {
+		log.Infof(context, "Resuming purge of account of user [%s]", deletion.Email)
+		if err = purgeAccountChunk.Add(context, DATASTORE_WRITES_QUEUE_NAME, deletion.Email); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeAccountDeletion(writer http.ResponseWriter, statusCode int, deletion store.AccountDeletion) {
+	writer.Header().Add("Content-type", "application/json")
+	writer.WriteHeader(statusCode)
+
+	enc := json.NewEncoder(writer)
+	enc.Encode(deletion)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/log"
+	"google.golang.org/appengine"
+	"net/http"
+)
+
+// migrateGlukitScores kicks off the recalculation, in the background, of all glukit scores calculated with an older
+// scoring version than the current one
+func migrateGlukitScores(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+
+	if err := engine.StartGlukitScoreMigration(context); err != nil {
+		log.Warningf(context, "Error starting glukit score migration: %v", err)
+		http.Error(writer, "Error starting glukit score migration", 500)
+		return
+	}
+
+	writer.WriteHeader(http.StatusAccepted)
+}
+
+// detectPatterns kicks off the detection, in the background, of the recurring patterns in the reads of all users. It's
+// meant to be called weekly by cron.
+func detectPatterns(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+
+	if err := engine.StartPatternDetection(context); err != nil {
+		log.Warningf(context, "Error starting pattern detection: %v", err)
+		http.Error(writer, "Error starting pattern detection", 500)
+		return
+	}
+
+	writer.WriteHeader(http.StatusAccepted)
+}
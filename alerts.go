@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/alexandre-normand/glukit/app/auth"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/store"
+	"google.golang.org/appengine"
+	"net/http"
+	"time"
+)
+
+const (
+	// How far back alerts are listed
+	ALERTS_HISTORY_DAYS = 30
+)
+
+// alerts returns the alerts raised for the logged in user over the last ALERTS_HISTORY_DAYS, most recent first
+func alerts(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := auth.CurrentUser(request)
+
+	upperBound := time.Now()
+	events, err := store.GetAlertEvents(context, user.Email, upperBound.AddDate(0, 0, -ALERTS_HISTORY_DAYS), upperBound)
+	if err != nil {
+		log.Warningf(context, "Error getting alerts of user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error getting alerts", 500)
+		return
+	}
+
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+
+	writer.Header().Add("Content-type", "application/json")
+	json.NewEncoder(writer).Encode(events)
+}
+
+// checkMissingData kicks off the check, in the background, of all users monitoring missing data. It's meant to be
+// called periodically by cron.
+func checkMissingData(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+
+	if err := engine.StartMissingDataCheck(context); err != nil {
+		log.Warningf(context, "Error starting missing data check: %v", err)
+		http.Error(writer, "Error starting missing data check", 500)
+		return
+	}
+
+	writer.WriteHeader(http.StatusAccepted)
+}
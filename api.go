@@ -2,25 +2,50 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/alexandre-normand/glukit/app/apimodel"
 	"github.com/alexandre-normand/glukit/app/bufio"
 	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/model"
 	"github.com/alexandre-normand/glukit/app/store"
 	"github.com/alexandre-normand/glukit/app/streaming"
 	"google.golang.org/appengine"
-	"google.golang.org/appengine/log"
+	"github.com/alexandre-normand/glukit/app/log"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 )
 
 const (
-	GLUCOSEREADS_V1_ROUTE = "v1_glucosereads"
-	CALIBRATIONS_V1_ROUTE = "v1_calibrations"
-	EXERCISES_V1_ROUTE    = "v1_exercises"
-	MEALS_V1_ROUTE        = "v1_meals"
-	INJECTIONS_V1_ROUTE   = "v1_injections"
+	GLUCOSEREADS_V1_ROUTE    = "v1_glucosereads"
+	CALIBRATIONS_V1_ROUTE    = "v1_calibrations"
+	EXERCISES_V1_ROUTE       = "v1_exercises"
+	MEALS_V1_ROUTE           = "v1_meals"
+	INJECTIONS_V1_ROUTE      = "v1_injections"
+	SENSORSESSIONS_V1_ROUTE  = "v1_sensorsessions"
+	BASALRATES_V1_ROUTE      = "v1_basalrates"
+	TEMPBASALS_V1_ROUTE      = "v1_tempbasals"
+	PUMPSUSPENDS_V1_ROUTE    = "v1_pumpsuspends"
+	EXTENDEDBOLUSES_V1_ROUTE = "v1_extendedboluses"
+	KETONES_V1_ROUTE         = "v1_ketones"
+	BLOODPRESSURES_V1_ROUTE  = "v1_bloodpressures"
+	WEIGHTS_V1_ROUTE         = "v1_weights"
+
+	GLUCOSEREADS_READ_V1_ROUTE   = "v1_glucosereads_read"
+	CALIBRATIONS_READ_V1_ROUTE   = "v1_calibrations_read"
+	EXERCISES_READ_V1_ROUTE      = "v1_exercises_read"
+	MEALS_READ_V1_ROUTE          = "v1_meals_read"
+	INJECTIONS_READ_V1_ROUTE     = "v1_injections_read"
+	SENSORSESSIONS_READ_V1_ROUTE = "v1_sensorsessions_read"
+	KETONES_READ_V1_ROUTE        = "v1_ketones_read"
+	BLOODPRESSURES_READ_V1_ROUTE = "v1_bloodpressures_read"
+	WEIGHTS_READ_V1_ROUTE        = "v1_weights_read"
+
+	// The maximum window of data that can be requested in a single read call. Clients are expected to page through
+	// larger periods using from/to/limit.
+	MAX_API_READ_WINDOW = time.Duration(31*24) * time.Hour
 )
 
 // Represents the logging of a file import
@@ -50,66 +75,96 @@ func CurrentApiUser(request *http.Request) (user *ApiUser) {
 }
 
 func initApiEndpoints(writer http.ResponseWriter, request *http.Request) {
-	muxRouter.Get(CALIBRATIONS_V1_ROUTE).Handler(newOauthAuthenticationHandler(http.HandlerFunc(processNewCalibrationData)))
-	muxRouter.Get(INJECTIONS_V1_ROUTE).Handler(newOauthAuthenticationHandler(http.HandlerFunc(processNewInjectionData)))
-	muxRouter.Get(MEALS_V1_ROUTE).Handler(newOauthAuthenticationHandler(http.HandlerFunc(processNewMealData)))
+	muxRouter.Get(CALIBRATIONS_V1_ROUTE).Handler(newOauthAuthenticationHandler(processNewData(apimodel.CALIBRATION_READ_KIND)))
+	muxRouter.Get(INJECTIONS_V1_ROUTE).Handler(newOauthAuthenticationHandler(processNewData(apimodel.INJECTION_KIND)))
+	muxRouter.Get(MEALS_V1_ROUTE).Handler(newOauthAuthenticationHandler(processNewData(apimodel.MEAL_KIND)))
 	muxRouter.Get(GLUCOSEREADS_V1_ROUTE).Handler(newOauthAuthenticationHandler(http.HandlerFunc(processNewGlucoseReadData)))
-	muxRouter.Get(EXERCISES_V1_ROUTE).Handler(newOauthAuthenticationHandler(http.HandlerFunc(processNewExerciseData)))
+	muxRouter.Get(EXERCISES_V1_ROUTE).Handler(newOauthAuthenticationHandler(processNewData(apimodel.EXERCISE_KIND)))
+	muxRouter.Get(SENSORSESSIONS_V1_ROUTE).Handler(newOauthAuthenticationHandler(http.HandlerFunc(processNewSensorSessionData)))
+	muxRouter.Get(BASALRATES_V1_ROUTE).Handler(newOauthAuthenticationHandler(processNewData(apimodel.BASAL_RATE_KIND)))
+	muxRouter.Get(TEMPBASALS_V1_ROUTE).Handler(newOauthAuthenticationHandler(processNewData(apimodel.TEMP_BASAL_KIND)))
+	muxRouter.Get(PUMPSUSPENDS_V1_ROUTE).Handler(newOauthAuthenticationHandler(processNewData(apimodel.PUMP_SUSPEND_KIND)))
+	muxRouter.Get(EXTENDEDBOLUSES_V1_ROUTE).Handler(newOauthAuthenticationHandler(processNewData(apimodel.EXTENDED_BOLUS_KIND)))
+	muxRouter.Get(KETONES_V1_ROUTE).Handler(newOauthAuthenticationHandler(processNewData(apimodel.KETONE_KIND)))
+	muxRouter.Get(BLOODPRESSURES_V1_ROUTE).Handler(newOauthAuthenticationHandler(processNewData(apimodel.BLOOD_PRESSURE_KIND)))
+	muxRouter.Get(WEIGHTS_V1_ROUTE).Handler(newOauthAuthenticationHandler(processNewData(apimodel.WEIGHT_KIND)))
+
+	muxRouter.Get(CALIBRATIONS_READ_V1_ROUTE).Handler(newOauthAuthenticationHandler(getData(apimodel.CALIBRATION_READ_KIND)))
+	muxRouter.Get(INJECTIONS_READ_V1_ROUTE).Handler(newOauthAuthenticationHandler(getData(apimodel.INJECTION_KIND)))
+	muxRouter.Get(MEALS_READ_V1_ROUTE).Handler(newOauthAuthenticationHandler(getData(apimodel.MEAL_KIND)))
+	muxRouter.Get(GLUCOSEREADS_READ_V1_ROUTE).Handler(newOauthAuthenticationHandler(getData(apimodel.GLUCOSE_READ_KIND)))
+	muxRouter.Get(EXERCISES_READ_V1_ROUTE).Handler(newOauthAuthenticationHandler(getData(apimodel.EXERCISE_KIND)))
+	muxRouter.Get(SENSORSESSIONS_READ_V1_ROUTE).Handler(newOauthAuthenticationHandler(http.HandlerFunc(getSensorSessionData)))
+	muxRouter.Get(KETONES_READ_V1_ROUTE).Handler(newOauthAuthenticationHandler(getData(apimodel.KETONE_KIND)))
+	muxRouter.Get(BLOODPRESSURES_READ_V1_ROUTE).Handler(newOauthAuthenticationHandler(getData(apimodel.BLOOD_PRESSURE_KIND)))
+	muxRouter.Get(WEIGHTS_READ_V1_ROUTE).Handler(newOauthAuthenticationHandler(getData(apimodel.WEIGHT_KIND)))
 }
 
-// processNewCalibrationData Handles a Post to the calibration endpoint and
-// handles all data to be stored for a given user
-func processNewCalibrationData(writer http.ResponseWriter, request *http.Request) {
-	context := appengine.NewContext(request)
-	user := CurrentApiUser(request)
-
-	userProfileKey, _, err := store.GetGlukitUser(context, user.Email)
-	if err != nil {
-		log.Warningf(context, "Error getting user to process calibration data, user email is [%s]: %v", user.Email, err)
-		http.Error(writer, "Error getting user to process calibration data", 500)
-		return
-	}
+// processNewData returns the handler of a Post to the endpoint of a kind of data, which stores all elements for the
+// current user
+func processNewData[T apimodel.Timestamped, D apimodel.DayOf[T]](kind apimodel.Kind[T, D]) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		context := appengine.NewContext(request)
+		user := CurrentApiUser(request)
 
-	dataStoreWriter := store.NewDataStoreCalibrationBatchWriter(context, userProfileKey)
-	batchingWriter := bufio.NewCalibrationWriterSize(dataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
-	calibrationStreamer := streaming.NewCalibrationReadStreamerDuration(batchingWriter, apimodel.DAY_OF_DATA_DURATION)
-
-	decoder := json.NewDecoder(request.Body)
+		_, err := store.GetGlukitUser(context, user.Email)
+		if err != nil {
+			log.Warningf(context, "Error getting user to process [%s] data, user email is [%s]: %v", kind.Name, user.Email, err)
+			http.Error(writer, "Error getting user to process data", 500)
+			return
+		}
 
-	for {
-		var c []apimodel.CalibrationRead
+		dataStoreWriter := store.NewDataStoreBatchWriter(context, user.Email, kind)
+		batchingWriter := bufio.NewWriterSize(kind, dataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
+		streamer := streaming.NewStreamerDuration(batchingWriter, apimodel.DAY_OF_DATA_DURATION)
+
+		decoder := json.NewDecoder(request.Body)
+
+		for {
+			var elements []T
+
+			if err = decoder.Decode(&elements); err == io.EOF {
+				break
+			} else if err != nil {
+				log.Warningf(context, "Error processing [%s] data for user [%s]: %v", kind.Name, user.Email, err)
+				break
+			}
+
+			for _, element := range elements {
+				if validated, ok := any(element).(apimodel.Validated); ok {
+					if err = validated.Validate(); err != nil {
+						log.Warningf(context, "Invalid [%s] data for user [%s]: %v", kind.Name, user.Email, err)
+						http.Error(writer, fmt.Sprintf("Invalid data: %v", err), 400)
+						return
+					}
+				}
+			}
+
+			log.Debugf(context, "Writing [%d] new elements of [%s]", len(elements), kind.Name)
+			streamer, err = streamer.WriteAll(elements)
+			if err != nil {
+				log.Warningf(context, "Error storing [%s] data [%v]: %v", kind.Name, elements, err)
+				http.Error(writer, fmt.Sprintf("Error storing data: %v", err), 502)
+				return
+			}
+		}
 
-		if err = decoder.Decode(&c); err == io.EOF {
-			break
-		} else if err != nil {
-			log.Warningf(context, "Error processing calibration data for user [%s]: %v", user.Email, err)
-			break
+		if err != io.EOF {
+			log.Warningf(context, "Error processing [%s] data for user [%s]: %v", kind.Name, user.Email, err)
+			http.Error(writer, fmt.Sprintf("Error decoding data: %v", err), 400)
+			return
 		}
 
-		log.Debugf(context, "Writing new calibration reads [%v]", c)
-		calibrationStreamer, err = calibrationStreamer.WriteCalibrations(c)
+		streamer, err = streamer.Close()
 		if err != nil {
-			log.Warningf(context, "Error storing calibration data [%v]: %v", c, err)
+			log.Warningf(context, "Error closing [%s] streamer: %v", kind.Name, err)
 			http.Error(writer, fmt.Sprintf("Error storing data: %v", err), 502)
 			return
 		}
-	}
 
-	if err != io.EOF {
-		log.Warningf(context, "Error processing calibration read data for user [%s]: %v", user.Email, err)
-		http.Error(writer, fmt.Sprintf("Error decoding data: %v", err), 400)
-		return
+		log.Infof(context, "Wrote [%s] to the datastore for user [%s]", kind.Name, user.Email)
+		writer.WriteHeader(200)
 	}
-
-	calibrationStreamer, err = calibrationStreamer.Close()
-	if err != nil {
-		log.Warningf(context, "Error closing calibration read streamer: %v", err)
-		http.Error(writer, fmt.Sprintf("Error storing data: %v", err), 502)
-		return
-	}
-
-	log.Infof(context, "Wrote calibrations to the datastore for user [%s]", user.Email)
-	writer.WriteHeader(200)
 }
 
 // processNewGlucoseReadData Handles a Post to the glucosereads endpoint and
@@ -118,18 +173,19 @@ func processNewGlucoseReadData(writer http.ResponseWriter, request *http.Request
 	context := appengine.NewContext(request)
 	user := CurrentApiUser(request)
 
-	userProfileKey, _, err := store.GetGlukitUser(context, user.Email)
+	_, err := store.GetGlukitUser(context, user.Email)
 	if err != nil {
 		log.Warningf(context, "Error getting user to process glucose read data, user email is [%s]: %v", user.Email, err)
 		http.Error(writer, "Error getting user to process glucose read data", 500)
 		return
 	}
 
-	dataStoreWriter := store.NewDataStoreGlucoseReadBatchWriter(context, userProfileKey)
-	batchingWriter := bufio.NewGlucoseReadWriterSize(dataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
-	glucoseReadStreamer := streaming.NewGlucoseStreamerDuration(batchingWriter, apimodel.DAY_OF_DATA_DURATION)
+	dataStoreWriter := store.NewDataStoreGlucoseReadBatchWriter(context, user.Email)
+	batchingWriter := bufio.NewWriterSize(apimodel.GLUCOSE_READ_KIND, dataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
+	glucoseReadStreamer := streaming.NewStreamerDuration(batchingWriter, apimodel.DAY_OF_DATA_DURATION)
 
 	decoder := json.NewDecoder(request.Body)
+	var firstReadTime, lastReadTime time.Time
 
 	for {
 		var c []apimodel.GlucoseRead
@@ -141,8 +197,18 @@ func processNewGlucoseReadData(writer http.ResponseWriter, request *http.Request
 			break
 		}
 
+		for _, read := range c {
+			readTime := read.GetTime()
+			if firstReadTime.IsZero() || readTime.Before(firstReadTime) {
+				firstReadTime = readTime
+			}
+			if readTime.After(lastReadTime) {
+				lastReadTime = readTime
+			}
+		}
+
 		log.Debugf(context, "Writing [%d] new glucose reads: %v", len(c), c)
-		glucoseReadStreamer, err = glucoseReadStreamer.WriteGlucoseReads(c)
+		glucoseReadStreamer, err = glucoseReadStreamer.WriteAll(c)
 		if err != nil {
 			log.Warningf(context, "Error storing user data [%v]: %v", c, err)
 			http.Error(writer, fmt.Sprintf("Error storing data: %v", err), 502)
@@ -163,186 +229,184 @@ func processNewGlucoseReadData(writer http.ResponseWriter, request *http.Request
 		return
 	}
 
-	_, glukitUser, err := store.GetGlukitUser(context, user.Email)
-	if err != nil {
+	if glukitUser, err := store.GetGlukitUser(context, user.Email); err != nil {
 		log.Warningf(context, "Couldn't get glukit user profile [%s] to recalculate score: %v", user.Email, err)
+	} else {
+		engine.StartUserCalculations(context, glukitUser)
 	}
 
-	err = engine.StartGlukitScoreBatch(context, glukitUser)
-	if err != nil {
-		log.Warningf(context, "Error starting glukit score calculation batch for user [%s]: %v", user.Email, err)
-	}
-
-	err = engine.StartA1CCalculationBatch(context, glukitUser)
-	if err != nil {
-		log.Warningf(context, "Error starting a1c calculation batch for user [%s]: %v", user.Email, err)
+	if !lastReadTime.IsZero() {
+		// The lower bound is exclusive so we start right before the first new read
+		err = engine.StartAlertEvaluation(context, user.Email, firstReadTime.Add(-time.Second), lastReadTime)
+		if err != nil {
+			log.Warningf(context, "Error starting alert evaluation for user [%s]: %v", user.Email, err)
+		}
 	}
 
 	log.Infof(context, "Wrote glucose reads to the datastore for user [%s]", user.Email)
 	writer.WriteHeader(200)
 }
 
-// processNewInjectionData Handles a Post to the injections endpoint and
-// handles all data to be stored for a given user
-func processNewInjectionData(writer http.ResponseWriter, request *http.Request) {
+// processNewSensorSessionData Handles a Post to the sensorsessions endpoint and stores the sessions recorded by a user
+// or an uploader. Recorded sessions take precedence over the inferred ones they overlap.
+func processNewSensorSessionData(writer http.ResponseWriter, request *http.Request) {
 	context := appengine.NewContext(request)
 	user := CurrentApiUser(request)
 
-	userProfileKey, _, err := store.GetGlukitUser(context, user.Email)
+	_, err := store.GetGlukitUser(context, user.Email)
 	if err != nil {
-		log.Warningf(context, "Error getting user to process injection data, user email is [%s]: %v", user.Email, err)
-		http.Error(writer, "Error getting user to process injection data", 500)
+		log.Warningf(context, "Error getting user to process sensor session data, user email is [%s]: %v", user.Email, err)
+		http.Error(writer, "Error getting user to process sensor session data", 500)
 		return
 	}
 
-	dataStoreWriter := store.NewDataStoreInjectionBatchWriter(context, userProfileKey)
-	batchingWriter := bufio.NewInjectionWriterSize(dataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
-	injectionStreamer := streaming.NewInjectionStreamerDuration(batchingWriter, apimodel.DAY_OF_DATA_DURATION)
-
 	decoder := json.NewDecoder(request.Body)
 
 	for {
-		var p []apimodel.Injection
+		var s []model.SensorSession
 
-		if err = decoder.Decode(&p); err == io.EOF {
+		if err = decoder.Decode(&s); err == io.EOF {
 			break
 		} else if err != nil {
-			log.Warningf(context, "Error processing injection data for user [%s]: %v", user.Email, err)
-			break
+			log.Warningf(context, "Error processing sensor session data for user [%s]: %v", user.Email, err)
+			http.Error(writer, fmt.Sprintf("Error decoding data: %v", err), 400)
+			return
 		}
 
-		log.Debugf(context, "Writing [%d] new injections", len(p))
-		injectionStreamer, err = injectionStreamer.WriteInjections(p)
-		if err != nil {
-			log.Warningf(context, "Error storing injection data [%v]: %v", p, err)
+		sessions := make([]model.SensorSession, len(s))
+		for i, session := range s {
+			sessions[i] = engine.CompleteSensorSession(session)
+			if err = sessions[i].Validate(); err != nil {
+				http.Error(writer, err.Error(), 400)
+				return
+			}
+		}
+
+		log.Debugf(context, "Writing new sensor sessions [%v]", sessions)
+		if err = store.StoreSensorSessions(context, user.Email, sessions); err != nil {
+			log.Warningf(context, "Error storing sensor session data [%v]: %v", sessions, err)
 			http.Error(writer, fmt.Sprintf("Error storing data: %v", err), 502)
 			return
 		}
 	}
 
-	if err != io.EOF {
-		log.Warningf(context, "Error processing injection data for user [%s]: %v", user.Email, err)
-		http.Error(writer, fmt.Sprintf("Error decoding data: %v", err), 400)
-		return
-	}
-
-	injectionStreamer, err = injectionStreamer.Close()
-	if err != nil {
-		log.Warningf(context, "Error closing injection streamer: %v", err)
-		http.Error(writer, fmt.Sprintf("Error storing data: %v", err), 502)
-		return
-	}
-
-	log.Infof(context, "Wrote injections to the datastore for user [%s]", user.Email)
+	log.Infof(context, "Wrote sensor sessions to the datastore for user [%s]", user.Email)
 	writer.WriteHeader(200)
 }
 
-// processNewMealData Handles a Post to the Meals endpoint and
-// handles all data to be stored for a given user
-func processNewMealData(writer http.ResponseWriter, request *http.Request) {
-	context := appengine.NewContext(request)
-	user := CurrentApiUser(request)
-
-	userProfileKey, _, err := store.GetGlukitUser(context, user.Email)
+// newApiReadWindow resolves the from/to/limit parameters of a read request into the time boundaries to scan.
+// When only one of from or to is specified, the other one is derived using the default lookback period. When
+// neither is, the window ends now.
+func newApiReadWindow(request *http.Request) (scanQuery *store.ScoreScanQuery, lowerBound, upperBound time.Time, err error) {
+	scanQuery, err = newScanQuery(request)
 	if err != nil {
-		log.Warningf(context, "Error getting user to process meal data, user email is [%s]: %v", user.Email, err)
-		http.Error(writer, "Error getting user to process meal data", 500)
-		return
+		return nil, lowerBound, upperBound, err
 	}
 
-	dataStoreWriter := store.NewDataStoreMealBatchWriter(context, userProfileKey)
-	batchingWriter := bufio.NewMealWriterSize(dataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
-	mealStreamer := streaming.NewMealStreamerDuration(batchingWriter, apimodel.DAY_OF_DATA_DURATION)
-
-	decoder := json.NewDecoder(request.Body)
+	if scanQuery.Limit != nil && *scanQuery.Limit < 1 {
+		return nil, lowerBound, upperBound, errors.New(fmt.Sprintf("Invalid query, %s [%d] must be at least 1.",
+			QUERY_PARAM_LIMIT, *scanQuery.Limit))
+	}
 
-	for {
-		var meals []apimodel.Meal
+	switch {
+	case scanQuery.From != nil && scanQuery.To != nil:
+		lowerBound, upperBound = *scanQuery.From, *scanQuery.To
+	case scanQuery.From != nil:
+		lowerBound = *scanQuery.From
+		upperBound = lowerBound.Add(-1 * model.DEFAULT_LOOKBACK_PERIOD)
+	case scanQuery.To != nil:
+		upperBound = *scanQuery.To
+		lowerBound = upperBound.Add(model.DEFAULT_LOOKBACK_PERIOD)
+	default:
+		upperBound = time.Now()
+		lowerBound = upperBound.Add(model.DEFAULT_LOOKBACK_PERIOD)
+	}
 
-		if err = decoder.Decode(&meals); err == io.EOF {
-			break
-		} else if err != nil {
-			log.Warningf(context, "Error processing meal data for user [%s]: %v", user.Email, err)
-			break
-		}
+	if upperBound.Before(lowerBound) {
+		return nil, lowerBound, upperBound, errors.New(fmt.Sprintf("Invalid query, %s [%d] is after %s [%d].",
+			QUERY_PARAM_FROM, lowerBound.Unix(), QUERY_PARAM_TO, upperBound.Unix()))
+	}
 
-		log.Debugf(context, "Writing [%d] new meals", len(meals))
-		mealStreamer, err = mealStreamer.WriteMeals(meals)
-		if err != nil {
-			log.Warningf(context, "Error storing meal data [%v]: %v", meals, err)
-			http.Error(writer, fmt.Sprintf("Error storing meal data: %v", err), 502)
-			return
-		}
+	if upperBound.Sub(lowerBound) > MAX_API_READ_WINDOW {
+		return nil, lowerBound, upperBound, errors.New(fmt.Sprintf("Invalid query, the window between %s and %s can't exceed [%v].",
+			QUERY_PARAM_FROM, QUERY_PARAM_TO, MAX_API_READ_WINDOW))
 	}
 
-	if err != io.EOF {
-		log.Warningf(context, "Error processing meal data for user [%s]: %v", user.Email, err)
-		http.Error(writer, fmt.Sprintf("Error decoding data: %v", err), 400)
-		return
+	return scanQuery, lowerBound, upperBound, nil
+}
+
+// limitReadWindow returns the boundaries of the elements to return for a read request once the limit is applied.
+// When paging forward from a given time, the earliest elements are kept. Otherwise, the most recent ones are.
+func limitReadWindow(scanQuery *store.ScoreScanQuery, length int) (startIndex, endIndex int) {
+	if scanQuery.Limit == nil || *scanQuery.Limit >= length {
+		return 0, length
 	}
 
-	mealStreamer, err = mealStreamer.Close()
-	if err != nil {
-		log.Warningf(context, "Error closing meal streamer: %v", err)
-		http.Error(writer, fmt.Sprintf("Error storing data: %v", err), 502)
-		return
+	if scanQuery.From != nil {
+		return 0, *scanQuery.Limit
 	}
 
-	log.Infof(context, "Wrote meals to the datastore for user [%s]", user.Email)
-	writer.WriteHeader(200)
+	return length - *scanQuery.Limit, length
 }
 
-// processNewExerciseData Handles a Post to the exercises endpoint and
-// handles all data to be stored for a given user
-func processNewExerciseData(writer http.ResponseWriter, request *http.Request) {
-	context := appengine.NewContext(request)
-	user := CurrentApiUser(request)
-
-	userProfileKey, _, err := store.GetGlukitUser(context, user.Email)
-	if err != nil {
-		log.Warningf(context, "Error getting user to process exercise data, user email is [%s]: %v", user.Email, err)
-		http.Error(writer, "Error getting user to process exercise data", 500)
+// writeApiReadResponse writes the elements of a read request as json or a 204 if there's nothing to return
+func writeApiReadResponse(writer http.ResponseWriter, elements interface{}, count int) {
+	if count < 1 {
+		http.Error(writer, "No data found for the requested period.", 204)
 		return
 	}
 
-	dataStoreWriter := store.NewDataStoreExerciseBatchWriter(context, userProfileKey)
-	batchingWriter := bufio.NewExerciseWriterSize(dataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
-	exerciseStreamer := streaming.NewExerciseStreamerDuration(batchingWriter, apimodel.DAY_OF_DATA_DURATION)
+	value := writer.Header()
+	value.Add("Content-type", "application/json")
 
-	decoder := json.NewDecoder(request.Body)
+	enc := json.NewEncoder(writer)
+	enc.Encode(elements)
+}
 
-	for {
-		var exercises []apimodel.Exercise
+// getData returns the handler of a Get to the endpoint of a kind of data, which returns the elements of the requested
+// period
+func getData[T apimodel.Timestamped, D apimodel.DayOf[T]](kind apimodel.Kind[T, D]) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		context := appengine.NewContext(request)
+		user := CurrentApiUser(request)
 
-		if err = decoder.Decode(&exercises); err == io.EOF {
-			break
-		} else if err != nil {
-			log.Warningf(context, "Error processing exercise data for user [%s]: %v", user.Email, err)
-			break
+		scanQuery, lowerBound, upperBound, err := newApiReadWindow(request)
+		if err != nil {
+			http.Error(writer, err.Error(), 400)
+			return
 		}
 
-		log.Debugf(context, "Writing [%d] new Exercises", len(exercises))
-		exerciseStreamer, err = exerciseStreamer.WriteExercises(exercises)
+		elements, err := store.GetElements(context, kind, user.Email, lowerBound, upperBound)
 		if err != nil {
-			log.Warningf(context, "Error storing exercise data [%v]: %v", exercises, err)
-			http.Error(writer, fmt.Sprintf("Error storing exercise data: %v", err), 502)
+			log.Warningf(context, "Error getting [%s] for user [%s]: %v", kind.Name, user.Email, err)
+			http.Error(writer, fmt.Sprintf("Error getting data: %v", err), 500)
 			return
 		}
+
+		startIndex, endIndex := limitReadWindow(scanQuery, len(elements))
+		writeApiReadResponse(writer, elements[startIndex:endIndex], endIndex-startIndex)
 	}
+}
 
-	if err != io.EOF {
-		log.Warningf(context, "Error processing exercise data for user [%s]: %v", user.Email, err)
-		http.Error(writer, fmt.Sprintf("Error decoding data: %v", err), 400)
+// getSensorSessionData handles a Get to the sensorsessions endpoint and returns the sessions worn during the requested
+// period, recorded sessions replacing the inferred ones they overlap
+func getSensorSessionData(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := CurrentApiUser(request)
+
+	scanQuery, lowerBound, upperBound, err := newApiReadWindow(request)
+	if err != nil {
+		http.Error(writer, err.Error(), 400)
 		return
 	}
 
-	exerciseStreamer, err = exerciseStreamer.Close()
+	sessions, err := engine.GetSensorSessions(context, user.Email, lowerBound, upperBound)
 	if err != nil {
-		log.Warningf(context, "Error closing exercise streamer: %v", err)
-		http.Error(writer, fmt.Sprintf("Error storing data: %v", err), 502)
+		log.Warningf(context, "Error getting sensor sessions for user [%s]: %v", user.Email, err)
+		http.Error(writer, fmt.Sprintf("Error getting data: %v", err), 500)
 		return
 	}
 
-	log.Infof(context, "Wrote exercises to the datastore for user [%s]", user.Email)
-	writer.WriteHeader(200)
+	startIndex, endIndex := limitReadWindow(scanQuery, len(sessions))
+	writeApiReadResponse(writer, sessions[startIndex:endIndex], endIndex-startIndex)
 }
@@ -0,0 +1,67 @@
+package apimodel
+
+import (
+	"time"
+)
+
+// BasalRate represents a segment of an insulin pump's basal schedule, delivering UnitsPerHour from its time until
+// the next basal rate
+type BasalRate struct {
+	Time         Time    `json:"time" datastore:"time,noindex"`
+	UnitsPerHour float32 `json:"unitsPerHour" datastore:"unitsPerHour,noindex"`
+	ScheduleName string  `json:"scheduleName" datastore:"scheduleName,noindex"`
+}
+
+// This holds an array of basal rates for a whole day
+type DayOfBasalRates struct {
+	BasalRates []BasalRate `datastore:"basalRates,noindex"`
+	StartTime  time.Time   `datastore:"startTime"`
+	EndTime    time.Time   `datastore:"endTime"`
+}
+
+// BASAL_RATE_KIND describes basal rates, stored by DayOfBasalRates
+var BASAL_RATE_KIND = Kind[BasalRate, DayOfBasalRates]{
+	Name: "DayOfBasalRates",
+	NewDayOf: func(basalRates []BasalRate, startTime time.Time, endTime time.Time) DayOfBasalRates {
+		return DayOfBasalRates{BasalRates: basalRates, StartTime: startTime, EndTime: endTime}
+	},
+}
+
+func NewDayOfBasalRates(basalRates []BasalRate) DayOfBasalRates {
+	return BASAL_RATE_KIND.NewDay(basalRates)
+}
+
+func (day DayOfBasalRates) GetElements() []BasalRate {
+	return day.BasalRates
+}
+
+func (day DayOfBasalRates) GetStartTime() time.Time {
+	return day.StartTime
+}
+
+func (day DayOfBasalRates) GetEndTime() time.Time {
+	return day.EndTime
+}
+
+// GetTime gets the time of a Timestamp value
+func (element BasalRate) GetTime() time.Time {
+	return element.Time.GetTime()
+}
+
+type BasalRateSlice []BasalRate
+
+func (slice BasalRateSlice) Len() int {
+	return len(slice)
+}
+
+func (slice BasalRateSlice) Less(i, j int) bool {
+	return slice[i].Time.Timestamp < slice[j].Time.Timestamp
+}
+
+func (slice BasalRateSlice) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice BasalRateSlice) GetEpochTime(i int) (epochTime int64) {
+	return slice[i].Time.Timestamp / 1000
+}
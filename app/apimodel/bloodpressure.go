@@ -0,0 +1,95 @@
+package apimodel
+
+import (
+	"github.com/alexandre-normand/glukit/app/util"
+	"time"
+)
+
+const (
+	SYSTOLIC_TAG  = "Systolic"
+	DIASTOLIC_TAG = "Diastolic"
+
+	// Unit of blood pressures
+	MM_HG = "mmHg"
+)
+
+// BloodPressure represents a blood pressure measurement, in mmHg, with the pulse in beats per minute when the monitor
+// reports it
+type BloodPressure struct {
+	Time      Time    `json:"time" datastore:"time,noindex"`
+	Systolic  float32 `json:"systolic" datastore:"systolic,noindex"`
+	Diastolic float32 `json:"diastolic" datastore:"diastolic,noindex"`
+	Pulse     int     `json:"pulse,omitempty" datastore:"pulse,noindex"`
+}
+
+// This holds an array of blood pressures for a whole day
+type DayOfBloodPressures struct {
+	BloodPressures []BloodPressure `datastore:"bloodPressures,noindex"`
+	StartTime      time.Time       `datastore:"startTime"`
+	EndTime        time.Time       `datastore:"endTime"`
+}
+
+// BLOOD_PRESSURE_KIND describes blood pressures, stored by DayOfBloodPressures
+var BLOOD_PRESSURE_KIND = Kind[BloodPressure, DayOfBloodPressures]{
+	Name: "DayOfBloodPressures",
+	NewDayOf: func(bloodPressures []BloodPressure, startTime time.Time, endTime time.Time) DayOfBloodPressures {
+		return DayOfBloodPressures{BloodPressures: bloodPressures, StartTime: startTime, EndTime: endTime}
+	},
+}
+
+func NewDayOfBloodPressures(bloodPressures []BloodPressure) DayOfBloodPressures {
+	return BLOOD_PRESSURE_KIND.NewDay(bloodPressures)
+}
+
+func (day DayOfBloodPressures) GetElements() []BloodPressure {
+	return day.BloodPressures
+}
+
+func (day DayOfBloodPressures) GetStartTime() time.Time {
+	return day.StartTime
+}
+
+func (day DayOfBloodPressures) GetEndTime() time.Time {
+	return day.EndTime
+}
+
+// GetTime gets the time of a Timestamp value
+func (element BloodPressure) GetTime() time.Time {
+	return element.Time.GetTime()
+}
+
+type BloodPressureSlice []BloodPressure
+
+func (slice BloodPressureSlice) Len() int {
+	return len(slice)
+}
+
+func (slice BloodPressureSlice) Less(i, j int) bool {
+	return slice[i].Time.Timestamp < slice[j].Time.Timestamp
+}
+
+func (slice BloodPressureSlice) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice BloodPressureSlice) GetEpochTime(i int) (epochTime int64) {
+	return slice[i].Time.Timestamp / 1000
+}
+
+// ToDataPointSlices converts a BloodPressureSlice into two generic DataPoint arrays, one of the systolic pressures and
+// one of the diastolic ones
+func (slice BloodPressureSlice) ToDataPointSlices() (systolic []DataPoint, diastolic []DataPoint) {
+	systolic = make([]DataPoint, len(slice))
+	diastolic = make([]DataPoint, len(slice))
+	for i := range slice {
+		localTime, err := slice[i].Time.Format()
+		if err != nil {
+			util.Propagate(err)
+		}
+
+		systolic[i] = DataPoint{localTime, slice.GetEpochTime(i), slice[i].Systolic, slice[i].Systolic, SYSTOLIC_TAG, MM_HG}
+		diastolic[i] = DataPoint{localTime, slice.GetEpochTime(i), slice[i].Diastolic, slice[i].Diastolic, DIASTOLIC_TAG, MM_HG}
+	}
+
+	return systolic, diastolic
+}
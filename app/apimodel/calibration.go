@@ -31,8 +31,28 @@ func (element CalibrationRead) GetTime() time.Time {
 	return element.Time.GetTime()
 }
 
+// CALIBRATION_READ_KIND describes calibration reads, stored by DayOfCalibrationReads
+var CALIBRATION_READ_KIND = Kind[CalibrationRead, DayOfCalibrationReads]{
+	Name: "DayOfCalibrationReads",
+	NewDayOf: func(reads []CalibrationRead, startTime time.Time, endTime time.Time) DayOfCalibrationReads {
+		return DayOfCalibrationReads{Reads: reads, StartTime: startTime, EndTime: endTime}
+	},
+}
+
 func NewDayOfCalibrationReads(reads []CalibrationRead) DayOfCalibrationReads {
-	return DayOfCalibrationReads{reads, reads[0].GetTime().Truncate(DAY_OF_DATA_DURATION), reads[len(reads)-1].GetTime()}
+	return CALIBRATION_READ_KIND.NewDay(reads)
+}
+
+func (day DayOfCalibrationReads) GetElements() []CalibrationRead {
+	return day.Reads
+}
+
+func (day DayOfCalibrationReads) GetStartTime() time.Time {
+	return day.StartTime
+}
+
+func (day DayOfCalibrationReads) GetEndTime() time.Time {
+	return day.EndTime
 }
 
 type CalibrationReadSlice []CalibrationRead
@@ -23,8 +23,28 @@ type DayOfExercises struct {
 	EndTime   time.Time  `datastore:"endTime"`
 }
 
+// EXERCISE_KIND describes exercises, stored by DayOfExercises
+var EXERCISE_KIND = Kind[Exercise, DayOfExercises]{
+	Name: "DayOfExercises",
+	NewDayOf: func(exercises []Exercise, startTime time.Time, endTime time.Time) DayOfExercises {
+		return DayOfExercises{Exercises: exercises, StartTime: startTime, EndTime: endTime}
+	},
+}
+
 func NewDayOfExercises(exercises []Exercise) DayOfExercises {
-	return DayOfExercises{exercises, exercises[0].GetTime().Truncate(DAY_OF_DATA_DURATION), exercises[len(exercises)-1].GetTime()}
+	return EXERCISE_KIND.NewDay(exercises)
+}
+
+func (day DayOfExercises) GetElements() []Exercise {
+	return day.Exercises
+}
+
+func (day DayOfExercises) GetStartTime() time.Time {
+	return day.StartTime
+}
+
+func (day DayOfExercises) GetEndTime() time.Time {
+	return day.EndTime
 }
 
 // GetTime gets the time of a Timestamp value
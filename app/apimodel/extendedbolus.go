@@ -0,0 +1,73 @@
+package apimodel
+
+import (
+	"time"
+)
+
+// ExtendedBolus represents a bolus delivered over time by an insulin pump. ImmediateUnits are delivered at its time,
+// as for a dual-wave bolus, and ExtendedUnits evenly over DurationMinutes.
+type ExtendedBolus struct {
+	Time            Time    `json:"time" datastore:"time,noindex"`
+	DurationMinutes int     `json:"durationInMinutes" datastore:"durationInMinutes,noindex"`
+	ImmediateUnits  float32 `json:"immediateUnits" datastore:"immediateUnits,noindex"`
+	ExtendedUnits   float32 `json:"extendedUnits" datastore:"extendedUnits,noindex"`
+}
+
+// This holds an array of extended boluses for a whole day
+type DayOfExtendedBoluses struct {
+	ExtendedBoluses []ExtendedBolus `datastore:"extendedBoluses,noindex"`
+	StartTime       time.Time       `datastore:"startTime"`
+	EndTime         time.Time       `datastore:"endTime"`
+}
+
+// EXTENDED_BOLUS_KIND describes extended boluses, stored by DayOfExtendedBoluses
+var EXTENDED_BOLUS_KIND = Kind[ExtendedBolus, DayOfExtendedBoluses]{
+	Name: "DayOfExtendedBoluses",
+	NewDayOf: func(extendedBoluses []ExtendedBolus, startTime time.Time, endTime time.Time) DayOfExtendedBoluses {
+		return DayOfExtendedBoluses{ExtendedBoluses: extendedBoluses, StartTime: startTime, EndTime: endTime}
+	},
+}
+
+func NewDayOfExtendedBoluses(extendedBoluses []ExtendedBolus) DayOfExtendedBoluses {
+	return EXTENDED_BOLUS_KIND.NewDay(extendedBoluses)
+}
+
+func (day DayOfExtendedBoluses) GetElements() []ExtendedBolus {
+	return day.ExtendedBoluses
+}
+
+func (day DayOfExtendedBoluses) GetStartTime() time.Time {
+	return day.StartTime
+}
+
+func (day DayOfExtendedBoluses) GetEndTime() time.Time {
+	return day.EndTime
+}
+
+// GetTime gets the time of a Timestamp value
+func (element ExtendedBolus) GetTime() time.Time {
+	return element.Time.GetTime()
+}
+
+// GetEndTime gets the time the delivery of the extended units ends
+func (element ExtendedBolus) GetEndTime() time.Time {
+	return element.GetTime().Add(time.Duration(element.DurationMinutes) * time.Minute)
+}
+
+type ExtendedBolusSlice []ExtendedBolus
+
+func (slice ExtendedBolusSlice) Len() int {
+	return len(slice)
+}
+
+func (slice ExtendedBolusSlice) Less(i, j int) bool {
+	return slice[i].Time.Timestamp < slice[j].Time.Timestamp
+}
+
+func (slice ExtendedBolusSlice) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice ExtendedBolusSlice) GetEpochTime(i int) (epochTime int64) {
+	return slice[i].Time.Timestamp / 1000
+}
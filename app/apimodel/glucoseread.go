@@ -33,8 +33,28 @@ type DayOfGlucoseReads struct {
 	EndTime   time.Time     `datastore:"endTime"`
 }
 
+// GLUCOSE_READ_KIND describes glucose reads, stored by DayOfGlucoseReads
+var GLUCOSE_READ_KIND = Kind[GlucoseRead, DayOfGlucoseReads]{
+	Name: "DayOfReads",
+	NewDayOf: func(reads []GlucoseRead, startTime time.Time, endTime time.Time) DayOfGlucoseReads {
+		return DayOfGlucoseReads{Reads: reads, StartTime: startTime, EndTime: endTime}
+	},
+}
+
 func NewDayOfGlucoseReads(reads []GlucoseRead) DayOfGlucoseReads {
-	return DayOfGlucoseReads{reads, reads[0].GetTime().Truncate(DAY_OF_DATA_DURATION), reads[len(reads)-1].GetTime()}
+	return GLUCOSE_READ_KIND.NewDay(reads)
+}
+
+func (day DayOfGlucoseReads) GetElements() []GlucoseRead {
+	return day.Reads
+}
+
+func (day DayOfGlucoseReads) GetStartTime() time.Time {
+	return day.StartTime
+}
+
+func (day DayOfGlucoseReads) GetEndTime() time.Time {
+	return day.EndTime
 }
 
 // GetTime gets the time of a Timestamp value
@@ -7,6 +7,10 @@ import (
 
 const (
 	INSULIN_TAG = "Insulin"
+
+	// Insulin types, when known
+	FAST_ACTING_INSULIN_TYPE = "FastActing"
+	LONG_ACTING_INSULIN_TYPE = "LongActing"
 )
 
 // Injection represents an insulin injection
@@ -24,8 +28,28 @@ type DayOfInjections struct {
 	EndTime    time.Time   `datastore:"endTime"`
 }
 
+// INJECTION_KIND describes injections, stored by DayOfInjections
+var INJECTION_KIND = Kind[Injection, DayOfInjections]{
+	Name: "DayOfInjections",
+	NewDayOf: func(injections []Injection, startTime time.Time, endTime time.Time) DayOfInjections {
+		return DayOfInjections{Injections: injections, StartTime: startTime, EndTime: endTime}
+	},
+}
+
 func NewDayOfInjections(injections []Injection) DayOfInjections {
-	return DayOfInjections{injections, injections[0].GetTime().Truncate(DAY_OF_DATA_DURATION), injections[len(injections)-1].GetTime()}
+	return INJECTION_KIND.NewDay(injections)
+}
+
+func (day DayOfInjections) GetElements() []Injection {
+	return day.Injections
+}
+
+func (day DayOfInjections) GetStartTime() time.Time {
+	return day.StartTime
+}
+
+func (day DayOfInjections) GetEndTime() time.Time {
+	return day.EndTime
 }
 
 // GetTime gets the time of a Timestamp value
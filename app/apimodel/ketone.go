@@ -0,0 +1,147 @@
+package apimodel
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/util"
+	"time"
+)
+
+const (
+	KETONE_TAG      = "Ketone"
+	HIGH_KETONE_TAG = "HighKetone"
+
+	// Blood ketones at or over this level, in mmol/L, put a user at risk of diabetic ketoacidosis
+	HIGH_KETONE_THRESHOLD = 1.5
+
+	// Blood ketones (beta-hydroxybutyrate) in mg/dL per mmol/L
+	KETONE_MG_PER_DL_PER_MMOL_PER_L = 10.41
+
+	// Units
+	KETONE_MMOL_PER_L KetoneUnit = MMOL_PER_L
+	KETONE_MG_PER_DL  KetoneUnit = MG_PER_DL
+)
+
+type KetoneUnit string
+
+// Ketone represents a blood ketone measurement. Meters report them in mmolPerL but mgPerDL is also accepted.
+type Ketone struct {
+	Time  Time       `json:"time" datastore:"time,noindex"`
+	Unit  KetoneUnit `json:"unit" datastore:"unit,noindex"`
+	Value float32    `json:"value" datastore:"value,noindex"`
+}
+
+// This holds an array of ketones for a whole day
+type DayOfKetones struct {
+	Ketones   []Ketone  `datastore:"ketones,noindex"`
+	StartTime time.Time `datastore:"startTime"`
+	EndTime   time.Time `datastore:"endTime"`
+}
+
+// KETONE_KIND describes ketones, stored by DayOfKetones
+var KETONE_KIND = Kind[Ketone, DayOfKetones]{
+	Name: "DayOfKetones",
+	NewDayOf: func(ketones []Ketone, startTime time.Time, endTime time.Time) DayOfKetones {
+		return DayOfKetones{Ketones: ketones, StartTime: startTime, EndTime: endTime}
+	},
+}
+
+func NewDayOfKetones(ketones []Ketone) DayOfKetones {
+	return KETONE_KIND.NewDay(ketones)
+}
+
+func (day DayOfKetones) GetElements() []Ketone {
+	return day.Ketones
+}
+
+func (day DayOfKetones) GetStartTime() time.Time {
+	return day.StartTime
+}
+
+func (day DayOfKetones) GetEndTime() time.Time {
+	return day.EndTime
+}
+
+// GetTime gets the time of a Timestamp value
+func (element Ketone) GetTime() time.Time {
+	return element.Time.GetTime()
+}
+
+// Validate returns an error if the ketone isn't in one of the supported units
+func (element Ketone) Validate() error {
+	if element.Unit != KETONE_MMOL_PER_L && element.Unit != KETONE_MG_PER_DL {
+		return errors.New(fmt.Sprintf("Bad ketone unit [%s], must be one of [%s, %s]", element.Unit, KETONE_MG_PER_DL, KETONE_MMOL_PER_L))
+	}
+
+	return nil
+}
+
+// GetNormalizedValue gets the normalized value to the requested unit
+func (element Ketone) GetNormalizedValue(unit KetoneUnit) (float32, error) {
+	if err := element.Validate(); err != nil {
+		return -1., err
+	}
+
+	if unit == element.Unit {
+		return element.Value, nil
+	}
+
+	switch unit {
+	case KETONE_MMOL_PER_L:
+		return element.Value / KETONE_MG_PER_DL_PER_MMOL_PER_L, nil
+	case KETONE_MG_PER_DL:
+		return element.Value * KETONE_MG_PER_DL_PER_MMOL_PER_L, nil
+	default:
+		return -1., errors.New(fmt.Sprintf("Bad unit requested, [%s] is not one of [%s, %s]", unit, KETONE_MG_PER_DL, KETONE_MMOL_PER_L))
+	}
+}
+
+// IsHigh returns true if the ketone is at or over HIGH_KETONE_THRESHOLD
+func (element Ketone) IsHigh() bool {
+	value, err := element.GetNormalizedValue(KETONE_MMOL_PER_L)
+	return err == nil && value >= HIGH_KETONE_THRESHOLD
+}
+
+type KetoneSlice []Ketone
+
+func (slice KetoneSlice) Len() int {
+	return len(slice)
+}
+
+func (slice KetoneSlice) Less(i, j int) bool {
+	return slice[i].Time.Timestamp < slice[j].Time.Timestamp
+}
+
+func (slice KetoneSlice) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice KetoneSlice) GetEpochTime(i int) (epochTime int64) {
+	return slice[i].Time.Timestamp / 1000
+}
+
+// ToDataPointSlice converts a KetoneSlice into a generic DataPoint array in mmolPerL. Ketones at or over
+// HIGH_KETONE_THRESHOLD are tagged with HIGH_KETONE_TAG.
+func (slice KetoneSlice) ToDataPointSlice() (dataPoints []DataPoint) {
+	dataPoints = make([]DataPoint, len(slice))
+	for i := range slice {
+		localTime, err := slice[i].Time.Format()
+		if err != nil {
+			util.Propagate(err)
+		}
+
+		convertedValue, err := slice[i].GetNormalizedValue(KETONE_MMOL_PER_L)
+		if err != nil {
+			util.Propagate(err)
+		}
+
+		tag := KETONE_TAG
+		if slice[i].IsHigh() {
+			tag = HIGH_KETONE_TAG
+		}
+
+		dataPoints[i] = DataPoint{localTime, slice.GetEpochTime(i), convertedValue, convertedValue, tag, GlucoseUnit(KETONE_MMOL_PER_L)}
+	}
+
+	return dataPoints
+}
@@ -0,0 +1,28 @@
+package apimodel_test
+
+import (
+	. "github.com/alexandre-normand/glukit/app/apimodel"
+	"testing"
+)
+
+func TestKetoneUnits(t *testing.T) {
+	ketone := Ketone{Time{0, "UTC"}, KETONE_MG_PER_DL, 1.5 * KETONE_MG_PER_DL_PER_MMOL_PER_L}
+	if err := ketone.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, err := ketone.GetNormalizedValue(KETONE_MMOL_PER_L); err != nil || value != 1.5 || !ketone.IsHigh() {
+		t.Errorf("Expected a high ketone of [1.5] mmolPerL but got [%f]: %v", value, err)
+	}
+}
+
+func TestKetoneWithUnknownUnitIsRejected(t *testing.T) {
+	ketone := Ketone{Time{0, "UTC"}, KetoneUnit("mmol"), 2}
+	if err := ketone.Validate(); err == nil {
+		t.Errorf("Expected an error for unit [%s]", ketone.Unit)
+	}
+
+	if _, err := ketone.GetNormalizedValue(KETONE_MMOL_PER_L); err == nil || ketone.IsHigh() {
+		t.Errorf("Expected ketone in unit [%s] not to be normalized", ketone.Unit)
+	}
+}
@@ -0,0 +1,57 @@
+package apimodel
+
+import (
+	"time"
+)
+
+// Timestamped is implemented by the elements of every kind of data
+type Timestamped interface {
+	GetTime() time.Time
+}
+
+// Validated is implemented by the elements of kinds of data that reject invalid values when they're received
+type Validated interface {
+	Validate() error
+}
+
+// DayOf is implemented by the containers that hold a day of elements of a kind, which is how they're stored
+type DayOf[T Timestamped] interface {
+	// GetElements returns the elements of the day, sorted by time
+	GetElements() []T
+	GetStartTime() time.Time
+	GetEndTime() time.Time
+}
+
+// Kind describes a kind of data whose elements, of type T, are stored by days held in a D. Adding a kind of data
+// only requires its types and a Kind, everything from streaming to storage is generic.
+type Kind[T Timestamped, D DayOf[T]] struct {
+	// Name of the kind of the stored days of data
+	Name string
+	// NewDayOf returns the day holding elements from startTime to endTime
+	NewDayOf func(elements []T, startTime time.Time, endTime time.Time) D
+}
+
+// NewDay returns the day holding elements, starting on the day of the first element and ending at the last one.
+// elements must be sorted by time.
+func (kind Kind[T, D]) NewDay(elements []T) D {
+	return kind.NewDayOf(elements, elements[0].GetTime().Truncate(DAY_OF_DATA_DURATION), elements[len(elements)-1].GetTime())
+}
+
+// TimestampedSlice sorts elements of any kind by time
+type TimestampedSlice[T Timestamped] []T
+
+func (slice TimestampedSlice[T]) Len() int {
+	return len(slice)
+}
+
+func (slice TimestampedSlice[T]) Less(i, j int) bool {
+	return slice[i].GetTime().Before(slice[j].GetTime())
+}
+
+func (slice TimestampedSlice[T]) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice TimestampedSlice[T]) GetEpochTime(i int) (epochTime int64) {
+	return slice[i].GetTime().Unix()
+}
@@ -26,8 +26,28 @@ type DayOfMeals struct {
 	EndTime   time.Time `datastore:"endTime"`
 }
 
+// MEAL_KIND describes meals, stored by DayOfMeals
+var MEAL_KIND = Kind[Meal, DayOfMeals]{
+	Name: "DayOfMeals",
+	NewDayOf: func(meals []Meal, startTime time.Time, endTime time.Time) DayOfMeals {
+		return DayOfMeals{Meals: meals, StartTime: startTime, EndTime: endTime}
+	},
+}
+
 func NewDayOfMeals(meals []Meal) DayOfMeals {
-	return DayOfMeals{meals, meals[0].GetTime().Truncate(DAY_OF_DATA_DURATION), meals[len(meals)-1].GetTime()}
+	return MEAL_KIND.NewDay(meals)
+}
+
+func (day DayOfMeals) GetElements() []Meal {
+	return day.Meals
+}
+
+func (day DayOfMeals) GetStartTime() time.Time {
+	return day.StartTime
+}
+
+func (day DayOfMeals) GetEndTime() time.Time {
+	return day.EndTime
 }
 
 // GetTime gets the time of a Timestamp value
@@ -0,0 +1,77 @@
+package apimodel
+
+import (
+	"time"
+)
+
+const (
+	// Suspend reasons, when known
+	MANUAL_SUSPEND_REASON      = "Manual"
+	LOW_GLUCOSE_SUSPEND_REASON = "LowGlucose"
+)
+
+// PumpSuspend represents the insulin pump stopping all basal delivery for DurationMinutes
+type PumpSuspend struct {
+	Time            Time   `json:"time" datastore:"time,noindex"`
+	DurationMinutes int    `json:"durationInMinutes" datastore:"durationInMinutes,noindex"`
+	Reason          string `json:"reason" datastore:"reason,noindex"`
+}
+
+// This holds an array of pump suspends for a whole day
+type DayOfPumpSuspends struct {
+	PumpSuspends []PumpSuspend `datastore:"pumpSuspends,noindex"`
+	StartTime    time.Time     `datastore:"startTime"`
+	EndTime      time.Time     `datastore:"endTime"`
+}
+
+// PUMP_SUSPEND_KIND describes pump suspends, stored by DayOfPumpSuspends
+var PUMP_SUSPEND_KIND = Kind[PumpSuspend, DayOfPumpSuspends]{
+	Name: "DayOfPumpSuspends",
+	NewDayOf: func(pumpSuspends []PumpSuspend, startTime time.Time, endTime time.Time) DayOfPumpSuspends {
+		return DayOfPumpSuspends{PumpSuspends: pumpSuspends, StartTime: startTime, EndTime: endTime}
+	},
+}
+
+func NewDayOfPumpSuspends(pumpSuspends []PumpSuspend) DayOfPumpSuspends {
+	return PUMP_SUSPEND_KIND.NewDay(pumpSuspends)
+}
+
+func (day DayOfPumpSuspends) GetElements() []PumpSuspend {
+	return day.PumpSuspends
+}
+
+func (day DayOfPumpSuspends) GetStartTime() time.Time {
+	return day.StartTime
+}
+
+func (day DayOfPumpSuspends) GetEndTime() time.Time {
+	return day.EndTime
+}
+
+// GetTime gets the time of a Timestamp value
+func (element PumpSuspend) GetTime() time.Time {
+	return element.Time.GetTime()
+}
+
+// GetEndTime gets the time delivery resumes
+func (element PumpSuspend) GetEndTime() time.Time {
+	return element.GetTime().Add(time.Duration(element.DurationMinutes) * time.Minute)
+}
+
+type PumpSuspendSlice []PumpSuspend
+
+func (slice PumpSuspendSlice) Len() int {
+	return len(slice)
+}
+
+func (slice PumpSuspendSlice) Less(i, j int) bool {
+	return slice[i].Time.Timestamp < slice[j].Time.Timestamp
+}
+
+func (slice PumpSuspendSlice) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice PumpSuspendSlice) GetEpochTime(i int) (epochTime int64) {
+	return slice[i].Time.Timestamp / 1000
+}
@@ -0,0 +1,73 @@
+package apimodel
+
+import (
+	"time"
+)
+
+// TempBasal represents a temporary basal rate replacing the scheduled one for DurationMinutes. When Percent is set, the
+// temporary rate is that percentage of the scheduled rate. Otherwise, it's UnitsPerHour, a zero rate included.
+type TempBasal struct {
+	Time            Time    `json:"time" datastore:"time,noindex"`
+	DurationMinutes int     `json:"durationInMinutes" datastore:"durationInMinutes,noindex"`
+	UnitsPerHour    float32 `json:"unitsPerHour" datastore:"unitsPerHour,noindex"`
+	Percent         float32 `json:"percent,omitempty" datastore:"percent,noindex"`
+}
+
+// This holds an array of temp basals for a whole day
+type DayOfTempBasals struct {
+	TempBasals []TempBasal `datastore:"tempBasals,noindex"`
+	StartTime  time.Time   `datastore:"startTime"`
+	EndTime    time.Time   `datastore:"endTime"`
+}
+
+// TEMP_BASAL_KIND describes temp basals, stored by DayOfTempBasals
+var TEMP_BASAL_KIND = Kind[TempBasal, DayOfTempBasals]{
+	Name: "DayOfTempBasals",
+	NewDayOf: func(tempBasals []TempBasal, startTime time.Time, endTime time.Time) DayOfTempBasals {
+		return DayOfTempBasals{TempBasals: tempBasals, StartTime: startTime, EndTime: endTime}
+	},
+}
+
+func NewDayOfTempBasals(tempBasals []TempBasal) DayOfTempBasals {
+	return TEMP_BASAL_KIND.NewDay(tempBasals)
+}
+
+func (day DayOfTempBasals) GetElements() []TempBasal {
+	return day.TempBasals
+}
+
+func (day DayOfTempBasals) GetStartTime() time.Time {
+	return day.StartTime
+}
+
+func (day DayOfTempBasals) GetEndTime() time.Time {
+	return day.EndTime
+}
+
+// GetTime gets the time of a Timestamp value
+func (element TempBasal) GetTime() time.Time {
+	return element.Time.GetTime()
+}
+
+// GetEndTime gets the time the temp basal ends
+func (element TempBasal) GetEndTime() time.Time {
+	return element.GetTime().Add(time.Duration(element.DurationMinutes) * time.Minute)
+}
+
+type TempBasalSlice []TempBasal
+
+func (slice TempBasalSlice) Len() int {
+	return len(slice)
+}
+
+func (slice TempBasalSlice) Less(i, j int) bool {
+	return slice[i].Time.Timestamp < slice[j].Time.Timestamp
+}
+
+func (slice TempBasalSlice) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice TempBasalSlice) GetEpochTime(i int) (epochTime int64) {
+	return slice[i].Time.Timestamp / 1000
+}
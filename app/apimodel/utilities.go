@@ -45,6 +45,12 @@ func linearInterpolateY(reads []GlucoseRead, timeValue Time, unit GlucoseUnit) (
 	return yValue
 }
 
+// InterpolateGlucose returns the glucose value, in the given unit, at a given time by linear interpolation between the
+// reads around it. Reads must be sorted by time.
+func InterpolateGlucose(reads []GlucoseRead, timeValue Time, unit GlucoseUnit) (value float32) {
+	return linearInterpolateY(reads, timeValue, unit)
+}
+
 func MergeDataPointArrays(first, second []DataPoint) []DataPoint {
 	newslice := make([]DataPoint, len(first)+len(second))
 	copy(newslice, first)
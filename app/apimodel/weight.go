@@ -0,0 +1,127 @@
+package apimodel
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/util"
+	"time"
+)
+
+const (
+	WEIGHT_TAG = "Weight"
+
+	// Units
+	KILOGRAMS = "kg"
+	POUNDS    = "lb"
+
+	POUNDS_PER_KILOGRAM = 2.20462
+)
+
+type WeightUnit string
+
+// Weight represents a body weight measurement
+type Weight struct {
+	Time  Time       `json:"time" datastore:"time,noindex"`
+	Unit  WeightUnit `json:"unit" datastore:"unit,noindex"`
+	Value float32    `json:"value" datastore:"value,noindex"`
+}
+
+// This holds an array of weights for a whole day
+type DayOfWeights struct {
+	Weights   []Weight  `datastore:"weights,noindex"`
+	StartTime time.Time `datastore:"startTime"`
+	EndTime   time.Time `datastore:"endTime"`
+}
+
+// WEIGHT_KIND describes weights, stored by DayOfWeights
+var WEIGHT_KIND = Kind[Weight, DayOfWeights]{
+	Name: "DayOfWeights",
+	NewDayOf: func(weights []Weight, startTime time.Time, endTime time.Time) DayOfWeights {
+		return DayOfWeights{Weights: weights, StartTime: startTime, EndTime: endTime}
+	},
+}
+
+func NewDayOfWeights(weights []Weight) DayOfWeights {
+	return WEIGHT_KIND.NewDay(weights)
+}
+
+func (day DayOfWeights) GetElements() []Weight {
+	return day.Weights
+}
+
+func (day DayOfWeights) GetStartTime() time.Time {
+	return day.StartTime
+}
+
+func (day DayOfWeights) GetEndTime() time.Time {
+	return day.EndTime
+}
+
+// GetTime gets the time of a Timestamp value
+func (element Weight) GetTime() time.Time {
+	return element.Time.GetTime()
+}
+
+// GetNormalizedValue gets the normalized value to the requested unit
+func (element Weight) GetNormalizedValue(unit WeightUnit) (float32, error) {
+	if unit == element.Unit {
+		return element.Value, nil
+	}
+
+	switch unit {
+	case KILOGRAMS:
+		return element.Value / POUNDS_PER_KILOGRAM, nil
+	case POUNDS:
+		return element.Value * POUNDS_PER_KILOGRAM, nil
+	default:
+		return -1., errors.New(fmt.Sprintf("Bad unit requested, [%s] is not one of [%s, %s]", unit, KILOGRAMS, POUNDS))
+	}
+}
+
+type WeightSlice []Weight
+
+func (slice WeightSlice) Len() int {
+	return len(slice)
+}
+
+func (slice WeightSlice) Less(i, j int) bool {
+	return slice[i].Time.Timestamp < slice[j].Time.Timestamp
+}
+
+func (slice WeightSlice) Swap(i, j int) {
+	slice[i], slice[j] = slice[j], slice[i]
+}
+
+func (slice WeightSlice) GetEpochTime(i int) (epochTime int64) {
+	return slice[i].Time.Timestamp / 1000
+}
+
+// ToDataPointSlice converts a WeightSlice into a generic DataPoint array in the unit of its most recent weight, or
+// kilograms if that's not one we know, so that users see weights in the unit they log them in
+func (slice WeightSlice) ToDataPointSlice() (dataPoints []DataPoint) {
+	dataPoints = make([]DataPoint, len(slice))
+	if len(slice) == 0 {
+		return dataPoints
+	}
+
+	var weightUnit WeightUnit = KILOGRAMS
+	if slice[len(slice)-1].Unit == POUNDS {
+		weightUnit = POUNDS
+	}
+
+	for i := range slice {
+		localTime, err := slice[i].Time.Format()
+		if err != nil {
+			util.Propagate(err)
+		}
+
+		convertedValue, err := slice[i].GetNormalizedValue(weightUnit)
+		if err != nil {
+			util.Propagate(err)
+		}
+
+		dataPoints[i] = DataPoint{localTime, slice.GetEpochTime(i), convertedValue, convertedValue, WEIGHT_TAG, GlucoseUnit(weightUnit)}
+	}
+
+	return dataPoints
+}
@@ -0,0 +1,131 @@
+/*
+Package auth identifies the user making a request to the web application (the client API uses OAuth separately).
+On App Engine, the user is the one logged in with the Users API. Outside of App Engine, the standalone server can
+trust a header set by an authenticating reverse proxy or run as a single static user.
+*/
+package auth
+
+import (
+	"errors"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/user"
+	"net/http"
+	"strings"
+)
+
+// ErrNoLoginURL is returned by Authenticators that don't have a login page to send users to
+var ErrNoLoginURL = errors.New("auth: no login url available")
+
+// Authenticator identifies the user of a request
+type Authenticator interface {
+	// CurrentUser returns the user logged in for the request or nil if there isn't one
+	CurrentUser(request *http.Request) *user.User
+	// LoginURL returns the url of the page where a user can log in before being redirected to dest
+	LoginURL(request *http.Request, dest string) (url string, err error)
+}
+
+// AppEngineAuthenticator is the Authenticator that relies on the App Engine Users API
+type AppEngineAuthenticator struct {
+}
+
+func (a AppEngineAuthenticator) CurrentUser(request *http.Request) *user.User {
+	return user.Current(appengine.NewContext(request))
+}
+
+func (a AppEngineAuthenticator) LoginURL(request *http.Request, dest string) (url string, err error) {
+	return user.LoginURL(appengine.NewContext(request), dest)
+}
+
+// HeaderAuthenticator trusts the email address given by a request header. This is meant for deployments behind a
+// reverse proxy that authenticates users and sets the header, never for a server exposed directly.
+type HeaderAuthenticator struct {
+	Header      string
+	AdminEmails []string
+	LoginPage   string
+}
+
+func (a HeaderAuthenticator) CurrentUser(request *http.Request) *user.User {
+	email := strings.TrimSpace(request.Header.Get(a.Header))
+	if email == "" {
+		return nil
+	}
+
+	return &user.User{Email: email, Admin: isAdmin(email, a.AdminEmails)}
+}
+
+func (a HeaderAuthenticator) LoginURL(request *http.Request, dest string) (url string, err error) {
+	if a.LoginPage == "" {
+		return "", ErrNoLoginURL
+	}
+
+	return a.LoginPage, nil
+}
+
+// StaticAuthenticator considers every request to be made by the same user. This is meant for a single-user server
+// on a trusted network and for local integration tests.
+type StaticAuthenticator struct {
+	Email string
+}
+
+func (a StaticAuthenticator) CurrentUser(request *http.Request) *user.User {
+	return &user.User{Email: a.Email, Admin: true}
+}
+
+func (a StaticAuthenticator) LoginURL(request *http.Request, dest string) (url string, err error) {
+	return dest, nil
+}
+
+func isAdmin(email string, adminEmails []string) bool {
+	for _, adminEmail := range adminEmails {
+		if strings.EqualFold(email, adminEmail) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The active authenticator, App Engine's Users API unless configured otherwise
+var authenticator Authenticator = AppEngineAuthenticator{}
+
+// SetAuthenticator sets the Authenticator used to identify users. This is meant to be called once, during
+// initialization, before any request is served.
+func SetAuthenticator(a Authenticator) {
+	authenticator = a
+}
+
+// CurrentUser returns the user logged in for the request or nil if there isn't one
+func CurrentUser(request *http.Request) *user.User {
+	return authenticator.CurrentUser(request)
+}
+
+// LoginURL returns the url of the page where a user can log in before being redirected to dest
+func LoginURL(request *http.Request, dest string) (url string, err error) {
+	return authenticator.LoginURL(request, dest)
+}
+
+// RequireLogin wraps a handler so that requests without a logged in user are redirected to the login page. When
+// admin is true, the user must also be an administrator. This provides what app.yaml's login option does on
+// App Engine.
+func RequireLogin(handler http.Handler, admin bool) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		currentUser := CurrentUser(request)
+		if currentUser == nil {
+			loginURL, err := LoginURL(request, request.URL.String())
+			if err != nil {
+				http.Error(writer, "Login required", http.StatusUnauthorized)
+				return
+			}
+
+			http.Redirect(writer, request, loginURL, http.StatusFound)
+			return
+		}
+
+		if admin && !currentUser.Admin {
+			http.Error(writer, "Admin login required", http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(writer, request)
+	})
+}
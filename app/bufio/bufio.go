@@ -2,3 +2,98 @@
 Package io provider buffered io to provide an efficient mecanism to accumulate data prior to physically persisting it.
 */
 package bufio
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/container"
+	"github.com/alexandre-normand/glukit/app/glukitio"
+)
+
+// BufferedBatchWriter accumulates days of data of a kind and writes them to the underlying writer in batches
+type BufferedBatchWriter[T apimodel.Timestamped, D apimodel.DayOf[T]] struct {
+	kind      apimodel.Kind[T, D]
+	head      *container.ImmutableList
+	size      int
+	flushSize int
+	wr        glukitio.BatchWriter[T, D]
+}
+
+// NewWriterSize returns a new Writer of days of a kind whose buffer has the specified size.
+func NewWriterSize[T apimodel.Timestamped, D apimodel.DayOf[T]](kind apimodel.Kind[T, D], wr glukitio.BatchWriter[T, D], flushSize int) *BufferedBatchWriter[T, D] {
+	return newWriterSize(kind, wr, nil, 0, flushSize)
+}
+
+func newWriterSize[T apimodel.Timestamped, D apimodel.DayOf[T]](kind apimodel.Kind[T, D], wr glukitio.BatchWriter[T, D], head *container.ImmutableList, size int, flushSize int) *BufferedBatchWriter[T, D] {
+	// Is it already a Writer?
+	b, ok := wr.(*BufferedBatchWriter[T, D])
+	if ok && b.flushSize >= flushSize {
+		return b
+	}
+
+	w := new(BufferedBatchWriter[T, D])
+	w.kind = kind
+	w.size = size
+	w.flushSize = flushSize
+	w.wr = wr
+	w.head = head
+
+	return w
+}
+
+// WriteBatch writes p as a single day
+func (b *BufferedBatchWriter[T, D]) WriteBatch(p []T) (glukitio.BatchWriter[T, D], error) {
+	return b.WriteBatches([]D{b.kind.NewDay(p)})
+}
+
+// WriteBatches writes the contents of p into the buffer.
+// It returns the writer to use for subsequent writes.
+// If the write is short, it also returns an error explaining
+// why.
+func (b *BufferedBatchWriter[T, D]) WriteBatches(p []D) (glukitio.BatchWriter[T, D], error) {
+	w := b
+	for _, batch := range p {
+		if w.size >= w.flushSize {
+			fw, err := w.Flush()
+			if err != nil {
+				return fw, err
+			}
+			w = fw.(*BufferedBatchWriter[T, D])
+		}
+
+		w = newWriterSize(w.kind, w.wr, container.NewImmutableList(w.head, batch), w.size+1, w.flushSize)
+	}
+
+	return w, nil
+}
+
+// Flush writes any buffered data to the underlying glukitio.Writer.
+func (b *BufferedBatchWriter[T, D]) Flush() (glukitio.BatchWriter[T, D], error) {
+	if b.size == 0 {
+		return newWriterSize(b.kind, b.wr, nil, 0, b.flushSize), nil
+	}
+	r, size := b.head.ReverseList()
+	batch := ListToArrayOfBatches[D](r, size)
+
+	if len(batch) > 0 {
+		innerWriter, err := b.wr.WriteBatches(batch)
+		if err != nil {
+			return nil, err
+		}
+
+		return newWriterSize(b.kind, innerWriter, nil, 0, b.flushSize), nil
+	}
+
+	return newWriterSize(b.kind, b.wr, nil, 0, b.flushSize), nil
+}
+
+// ListToArrayOfBatches returns the size days of data held in a list
+func ListToArrayOfBatches[D any](head *container.ImmutableList, size int) []D {
+	r := make([]D, size)
+	cursor := head
+	for i := 0; i < size; i++ {
+		r[i] = cursor.Value().(D)
+		cursor = cursor.Next()
+	}
+
+	return r
+}
@@ -0,0 +1,270 @@
+package bufio_test
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	. "github.com/alexandre-normand/glukit/app/bufio"
+	"github.com/alexandre-normand/glukit/app/glukitio"
+	"log"
+	"testing"
+	"time"
+)
+
+type writerState struct {
+	total      int
+	batchCount int
+	writeCount int
+	batches    map[int64]int
+}
+
+type statsWriter[T apimodel.Timestamped, D apimodel.DayOf[T]] struct {
+	kind  apimodel.Kind[T, D]
+	state *writerState
+}
+
+func NewWriterState() *writerState {
+	s := new(writerState)
+	s.batches = make(map[int64]int)
+
+	return s
+}
+
+func NewStatsWriter[T apimodel.Timestamped, D apimodel.DayOf[T]](kind apimodel.Kind[T, D], s *writerState) *statsWriter[T, D] {
+	w := new(statsWriter[T, D])
+	w.kind = kind
+	w.state = s
+
+	return w
+}
+
+func (w *statsWriter[T, D]) WriteBatch(p []T) (glukitio.BatchWriter[T, D], error) {
+	log.Printf("WriteBatch of [%s] with [%d] elements: %v", w.kind.Name, len(p), p)
+
+	return w.WriteBatches([]D{w.kind.NewDay(p)})
+}
+
+func (w *statsWriter[T, D]) WriteBatches(p []D) (glukitio.BatchWriter[T, D], error) {
+	log.Printf("WriteBatches of [%s] with [%d] batches: %v", w.kind.Name, len(p), p)
+	for _, dayOfData := range p {
+		elements := dayOfData.GetElements()
+		w.state.total += len(elements)
+		log.Printf("Adding batch with time [%v]", elements[0].GetTime())
+		w.state.batches[elements[0].GetTime().Unix()] = len(elements)
+	}
+
+	log.Printf("WriteBatches with total of %d", w.state.total)
+	w.state.batchCount += len(p)
+	w.state.writeCount++
+
+	return w, nil
+}
+
+func (w *statsWriter[T, D]) Flush() (glukitio.BatchWriter[T, D], error) {
+	return w, nil
+}
+
+// kindTest runs the buffered writer tests for a kind of data
+type kindTest interface {
+	name() string
+	simpleWriteOfSingleBatch(t *testing.T)
+	individualWrite(t *testing.T)
+	simpleWriteLargerThanOneBatch(t *testing.T)
+	writeOverTwoFullBatches(t *testing.T)
+}
+
+type kindTests[T apimodel.Timestamped, D apimodel.DayOf[T]] struct {
+	kind       apimodel.Kind[T, D]
+	newElement func(elementTime apimodel.Time, i int) T
+}
+
+func newKindTests[T apimodel.Timestamped, D apimodel.DayOf[T]](kind apimodel.Kind[T, D], newElement func(elementTime apimodel.Time, i int) T) kindTest {
+	return kindTests[T, D]{kind: kind, newElement: newElement}
+}
+
+var KIND_TESTS = []kindTest{
+	newKindTests(apimodel.GLUCOSE_READ_KIND, func(readTime apimodel.Time, i int) apimodel.GlucoseRead {
+		return apimodel.GlucoseRead{Time: readTime, Unit: apimodel.MG_PER_DL, Value: float32(i)}
+	}),
+	newKindTests(apimodel.CALIBRATION_READ_KIND, func(calibrationTime apimodel.Time, i int) apimodel.CalibrationRead {
+		return apimodel.CalibrationRead{Time: calibrationTime, Unit: apimodel.MG_PER_DL, Value: 75}
+	}),
+	newKindTests(apimodel.INJECTION_KIND, func(injectionTime apimodel.Time, i int) apimodel.Injection {
+		return apimodel.Injection{Time: injectionTime, Units: float32(i), InsulinName: "Humalog", InsulinType: "Bolus"}
+	}),
+	newKindTests(apimodel.MEAL_KIND, func(mealTime apimodel.Time, i int) apimodel.Meal {
+		return apimodel.Meal{Time: mealTime, Carbohydrates: float32(i), Proteins: float32(i + 1), Fat: float32(i + 2), SaturatedFat: float32(i + 3)}
+	}),
+	newKindTests(apimodel.EXERCISE_KIND, func(exerciseTime apimodel.Time, i int) apimodel.Exercise {
+		return apimodel.Exercise{Time: exerciseTime, DurationMinutes: i, Intensity: "Light", Description: "details"}
+	}),
+	newKindTests(apimodel.BASAL_RATE_KIND, func(basalRateTime apimodel.Time, i int) apimodel.BasalRate {
+		return apimodel.BasalRate{Time: basalRateTime, UnitsPerHour: float32(i), ScheduleName: "Standard"}
+	}),
+	newKindTests(apimodel.TEMP_BASAL_KIND, func(tempBasalTime apimodel.Time, i int) apimodel.TempBasal {
+		return apimodel.TempBasal{Time: tempBasalTime, DurationMinutes: 30, UnitsPerHour: float32(i)}
+	}),
+	newKindTests(apimodel.PUMP_SUSPEND_KIND, func(suspendTime apimodel.Time, i int) apimodel.PumpSuspend {
+		return apimodel.PumpSuspend{Time: suspendTime, DurationMinutes: 30, Reason: apimodel.MANUAL_SUSPEND_REASON}
+	}),
+	newKindTests(apimodel.EXTENDED_BOLUS_KIND, func(bolusTime apimodel.Time, i int) apimodel.ExtendedBolus {
+		return apimodel.ExtendedBolus{Time: bolusTime, DurationMinutes: 120, ExtendedUnits: float32(i)}
+	}),
+	newKindTests(apimodel.KETONE_KIND, func(ketoneTime apimodel.Time, i int) apimodel.Ketone {
+		return apimodel.Ketone{Time: ketoneTime, Unit: apimodel.KETONE_MMOL_PER_L, Value: float32(i) / 10}
+	}),
+	newKindTests(apimodel.BLOOD_PRESSURE_KIND, func(pressureTime apimodel.Time, i int) apimodel.BloodPressure {
+		return apimodel.BloodPressure{Time: pressureTime, Systolic: float32(110 + i), Diastolic: float32(70 + i), Pulse: 60}
+	}),
+	newKindTests(apimodel.WEIGHT_KIND, func(weightTime apimodel.Time, i int) apimodel.Weight {
+		return apimodel.Weight{Time: weightTime, Unit: apimodel.KILOGRAMS, Value: float32(70 + i)}
+	}),
+}
+
+func (k kindTests[T, D]) name() string {
+	return k.kind.Name
+}
+
+// days returns count days of elements every interval starting at startTime
+func (k kindTests[T, D]) days(startTime time.Time, count int, elementsPerDay int, interval time.Duration) []D {
+	batches := make([]D, count)
+	for i := 0; i < count; i++ {
+		batches[i] = k.kind.NewDay(k.elements(startTime.Add(time.Duration(i*elementsPerDay)*interval), elementsPerDay, interval))
+	}
+
+	return batches
+}
+
+// elements returns count elements every interval starting at startTime
+func (k kindTests[T, D]) elements(startTime time.Time, count int, interval time.Duration) []T {
+	elements := make([]T, count)
+	for j := 0; j < count; j++ {
+		elementTime := startTime.Add(time.Duration(j) * interval)
+		elements[j] = k.newElement(apimodel.Time{apimodel.GetTimeMillis(elementTime), "America/Montreal"}, j)
+	}
+
+	return elements
+}
+
+func (k kindTests[T, D]) simpleWriteOfSingleBatch(t *testing.T) {
+	state := NewWriterState()
+	w := NewWriterSize(k.kind, NewStatsWriter(k.kind, state), 10)
+	ct, _ := time.Parse("02/01/2006 15:04", "18/04/2014 00:00")
+	newWriter, _ := w.WriteBatches(k.days(ct, 10, 24, time.Hour))
+	w = newWriter.(*BufferedBatchWriter[T, D])
+	newWriter, _ = w.Flush()
+	w = newWriter.(*BufferedBatchWriter[T, D])
+
+	if state.total != 240 {
+		t.Errorf("TestSimpleWriteOfSingleBatch failed: got a total of %d but expected %d", state.total, 240)
+	}
+
+	if state.batchCount != 10 {
+		t.Errorf("TestSimpleWriteOfSingleBatch failed: got a batchCount of %d but expected %d", state.batchCount, 10)
+	}
+
+	if state.writeCount != 1 {
+		t.Errorf("TestSimpleWriteOfSingleBatch failed: got a writeCount of %d but expected %d", state.writeCount, 1)
+	}
+}
+
+func (k kindTests[T, D]) individualWrite(t *testing.T) {
+	state := NewWriterState()
+	w := NewWriterSize(k.kind, NewStatsWriter(k.kind, state), 10)
+	ct, _ := time.Parse("02/01/2006 15:04", "18/04/2014 00:00")
+	newWriter, _ := w.WriteBatch(k.elements(ct, 24, time.Hour))
+	w = newWriter.(*BufferedBatchWriter[T, D])
+	newWriter, _ = w.Flush()
+	w = newWriter.(*BufferedBatchWriter[T, D])
+
+	if state.total != 24 {
+		t.Errorf("TestIndividualWrite failed: got a total of %d but expected %d", state.total, 24)
+	}
+
+	if state.batchCount != 1 {
+		t.Errorf("TestIndividualWrite failed: got a batchCount of %d but expected %d", state.batchCount, 1)
+	}
+
+	if state.writeCount != 1 {
+		t.Errorf("TestIndividualWrite failed: got a writeCount of %d but expected %d", state.writeCount, 1)
+	}
+}
+
+func (k kindTests[T, D]) simpleWriteLargerThanOneBatch(t *testing.T) {
+	state := NewWriterState()
+	w := NewWriterSize(k.kind, NewStatsWriter(k.kind, state), 10)
+	ct, _ := time.Parse("02/01/2006 15:04", "18/04/2014 00:00")
+	newWriter, _ := w.WriteBatches(k.days(ct, 19, 24, time.Hour))
+	w = newWriter.(*BufferedBatchWriter[T, D])
+
+	if state.total != 240 {
+		t.Errorf("TestSimpleWriteLargerThanOneBatch test failed: got a total of %d but expected %d", state.total, 240)
+	}
+
+	if state.batchCount != 10 {
+		t.Errorf("TestSimpleWriteLargerThanOneBatch test: got a batchCount of %d but expected %d", state.batchCount, 10)
+	}
+
+	if state.writeCount != 1 {
+		t.Errorf("TestSimpleWriteLargerThanOneBatch test failed: got a writeCount of %d but expected %d", state.writeCount, 1)
+	}
+
+	// Flushing should cause the extra batches to be written
+	newWriter, _ = w.Flush()
+	w = newWriter.(*BufferedBatchWriter[T, D])
+
+	if state.total != 456 {
+		t.Errorf("TestSimpleWriteLargerThanOneBatch test failed: got a total of %d but expected %d", state.total, 456)
+	}
+
+	if state.batchCount != 19 {
+		t.Errorf("TestSimpleWriteLargerThanOneBatch test: got a batchCount of %d but expected %d", state.batchCount, 19)
+	}
+
+	if state.writeCount != 2 {
+		t.Errorf("TestSimpleWriteLargerThanOneBatch test failed: got a writeCount of %d but expected %d", state.writeCount, 2)
+	}
+}
+
+func (k kindTests[T, D]) writeOverTwoFullBatches(t *testing.T) {
+	state := NewWriterState()
+	w := NewWriterSize(k.kind, NewStatsWriter(k.kind, state), 2)
+	ct, _ := time.Parse("02/01/2006 15:04", "18/04/2014 00:00")
+
+	for b := 0; b < 3; b++ {
+		newWriter, _ := w.WriteBatch(k.elements(ct.Add(time.Duration(b*24)*time.Hour), 48, 30*time.Minute))
+		w = newWriter.(*BufferedBatchWriter[T, D])
+	}
+
+	newWriter, _ := w.Flush()
+	w = newWriter.(*BufferedBatchWriter[T, D])
+
+	for day := 0; day < 3; day++ {
+		batchTime := ct.Add(time.Duration(day*24) * time.Hour)
+		if _, ok := state.batches[batchTime.Unix()]; !ok {
+			t.Errorf("TestWriteOverTwoFullBatches test failed: could not find a batch starting with a time of [%v] in batches: [%v]", batchTime.Unix(), state.batches)
+		}
+	}
+}
+
+func TestSimpleWriteOfSingleBatch(t *testing.T) {
+	for _, k := range KIND_TESTS {
+		t.Run(k.name(), k.simpleWriteOfSingleBatch)
+	}
+}
+
+func TestIndividualWrite(t *testing.T) {
+	for _, k := range KIND_TESTS {
+		t.Run(k.name(), k.individualWrite)
+	}
+}
+
+func TestSimpleWriteLargerThanOneBatch(t *testing.T) {
+	for _, k := range KIND_TESTS {
+		t.Run(k.name(), k.simpleWriteLargerThanOneBatch)
+	}
+}
+
+func TestWriteOverTwoFullBatches(t *testing.T) {
+	for _, k := range KIND_TESTS {
+		t.Run(k.name(), k.writeOverTwoFullBatches)
+	}
+}
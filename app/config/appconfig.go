@@ -2,10 +2,40 @@
 package config
 
 import (
+	"fmt"
 	"github.com/alexandre-normand/glukit/app/secrets"
 	"google.golang.org/appengine"
+	"os"
+	"time"
 )
 
+// Environment variable holding the longest gap in reads filled by interpolation, as a duration (i.e. 1h). It's set in
+// app.yaml on App Engine.
+const FILL_GAPS_ENV_VARIABLE = "GLUKIT_FILL_GAPS"
+
+// Environment variables holding the shape of the action curve of rapid-acting insulins (exponential or bilinear) and the
+// durations of the insulin and carbs action profiles (i.e. 6h). They're set in app.yaml on App Engine and the engine's
+// defaults are used for those that aren't set.
+const (
+	INSULIN_SHAPE_ENV_VARIABLE                = "GLUKIT_INSULIN_SHAPE"
+	INSULIN_PEAK_ENV_VARIABLE                 = "GLUKIT_INSULIN_PEAK"
+	INSULIN_DURATION_ENV_VARIABLE             = "GLUKIT_INSULIN_DURATION"
+	LONG_ACTING_INSULIN_DURATION_ENV_VARIABLE = "GLUKIT_LONG_ACTING_INSULIN_DURATION"
+	CARBS_ABSORPTION_ENV_VARIABLE             = "GLUKIT_CARBS_ABSORPTION"
+)
+
+// OnBoardConfig holds the action profiles used for insulin and carbs on board. Zero values keep the engine's defaults.
+type OnBoardConfig struct {
+	// Shape (exponential or bilinear), peak and duration of the action of rapid-acting insulins
+	InsulinShape    string
+	InsulinPeak     time.Duration
+	InsulinDuration time.Duration
+	// Duration of the action of long-acting insulins
+	LongActingInsulinDuration time.Duration
+	// Duration of the absorption of carbs
+	CarbsAbsorption time.Duration
+}
+
 // AppConfig is all global application configuration values
 // It has a test mode and a production as per the datastore's appengine
 // environment.
@@ -16,6 +46,10 @@ type AppConfig struct {
 	SSLHost              string
 	StripeKey            string
 	StripePublishableKey string
+	// Longest gap in reads filled by interpolation for glukit scores and a1c estimates, 0 to not fill gaps
+	MaxFilledGap time.Duration
+	// Action profiles of insulin and carbs on board, also used for therapy estimates and forecasts
+	OnBoard OnBoardConfig
 }
 
 // newTestAppConfig returns the AppConfig for a test environment
@@ -48,9 +82,47 @@ func newProdAppConfig(appSecrets *secrets.AppSecrets) *AppConfig {
 // as returned by appengine.IsDevAppServer()
 func NewAppConfig() *AppConfig {
 	appSecrets := secrets.NewAppSecrets()
+	var appConfig *AppConfig
 	if appengine.IsDevAppServer() {
-		return newTestAppConfig(appSecrets)
+		appConfig = newTestAppConfig(appSecrets)
 	} else {
-		return newProdAppConfig(appSecrets)
+		appConfig = newProdAppConfig(appSecrets)
+	}
+	appConfig.MaxFilledGap = getDuration(FILL_GAPS_ENV_VARIABLE)
+	appConfig.OnBoard = OnBoardConfig{
+		InsulinShape:              os.Getenv(INSULIN_SHAPE_ENV_VARIABLE),
+		InsulinPeak:               getDuration(INSULIN_PEAK_ENV_VARIABLE),
+		InsulinDuration:           getDuration(INSULIN_DURATION_ENV_VARIABLE),
+		LongActingInsulinDuration: getDuration(LONG_ACTING_INSULIN_DURATION_ENV_VARIABLE),
+		CarbsAbsorption:           getDuration(CARBS_ABSORPTION_ENV_VARIABLE),
+	}
+
+	return appConfig
+}
+
+// getDuration returns the duration set by an environment variable, 0 if it isn't set
+func getDuration(variable string) time.Duration {
+	value := os.Getenv(variable)
+	if value == "" {
+		return 0
 	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid value for %s [%s]: %v", variable, value, err))
+	}
+
+	return duration
+}
+
+// NewStandaloneAppConfig returns the AppConfig for the standalone server. It uses the local secrets along with the
+// host the server is reachable at. sslHost is the full base url (including the scheme) of the server.
+func NewStandaloneAppConfig(host string, sslHost string, maxFilledGap time.Duration, onBoard OnBoardConfig) *AppConfig {
+	appConfig := newTestAppConfig(secrets.NewAppSecrets())
+	appConfig.Host = host
+	appConfig.SSLHost = sslHost
+	appConfig.MaxFilledGap = maxFilledGap
+	appConfig.OnBoard = onBoard
+
+	return appConfig
 }
@@ -0,0 +1,82 @@
+package dexcomimporter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/util"
+	"strconv"
+	"time"
+)
+
+const (
+	// Dexcom only knows of those intensities, we use the middle one for exercises without intensity
+	DEFAULT_EXERCISE_INTENSITY = "Medium"
+)
+
+// NewXmlGlucoseRead converts a GlucoseRead to its Dexcom xml representation, the reverse of ConvertXmlGlucoseRead
+func NewXmlGlucoseRead(read apimodel.GlucoseRead) (glucose *Glucose, err error) {
+	value, err := formatXmlValue(read.Value, read.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	internalTime, displayTime := formatXmlTimes(read.GetTime())
+	return &Glucose{InternalTime: internalTime, DisplayTime: displayTime, Value: value}, nil
+}
+
+// NewXmlCalibration converts a CalibrationRead to its Dexcom xml representation, the reverse of
+// ConvertXmlCalibrationRead
+func NewXmlCalibration(calibration apimodel.CalibrationRead) (xmlCalibration *Calibration, err error) {
+	value, err := formatXmlValue(calibration.Value, calibration.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	internalTime, displayTime := formatXmlTimes(calibration.GetTime())
+	return &Calibration{InternalTime: internalTime, DisplayTime: displayTime, Value: value}, nil
+}
+
+// NewXmlInjectionEvent converts an Injection to a Dexcom Insulin event. Only the units are kept.
+func NewXmlInjectionEvent(injection apimodel.Injection) (event Event) {
+	description := fmt.Sprintf("Insulin %s units", strconv.FormatFloat(float64(injection.Units), 'f', -1, 32))
+	return newXmlEvent(injection.GetTime(), "Insulin", description)
+}
+
+// NewXmlMealEvent converts a Meal to a Dexcom Carbs event. Dexcom only records carbohydrates, in whole grams.
+func NewXmlMealEvent(meal apimodel.Meal) (event Event) {
+	return newXmlEvent(meal.GetTime(), "Carbs", fmt.Sprintf("Carbs %d grams", int(meal.Carbohydrates+0.5)))
+}
+
+// NewXmlExerciseEvent converts an Exercise to a Dexcom Exercise event whose type carries the intensity
+func NewXmlExerciseEvent(exercise apimodel.Exercise) (event Event) {
+	intensity := exercise.Intensity
+	if intensity == "" {
+		intensity = DEFAULT_EXERCISE_INTENSITY
+	}
+
+	return newXmlEvent(exercise.GetTime(), "Exercise"+intensity, fmt.Sprintf("Exercise %s (%d minutes)", intensity, exercise.DurationMinutes))
+}
+
+func newXmlEvent(eventTime time.Time, eventType string, description string) (event Event) {
+	internalTime, displayTime := formatXmlTimes(eventTime)
+	return Event{InternalTime: internalTime, DisplayTime: displayTime, EventTime: displayTime, EventType: eventType, Description: description}
+}
+
+// formatXmlTimes returns the internal (UTC) and display (local) times the way Dexcom formats them
+func formatXmlTimes(timeValue time.Time) (internalTime, displayTime string) {
+	return util.TimeInUTCNoTz(timeValue), timeValue.Format(util.TIMEFORMAT_NO_TZ)
+}
+
+// formatXmlValue formats a glucose value so that getUnitFromValue finds its unit back: mmol/L values always have two
+// decimals and mg/dL values none.
+func formatXmlValue(value float32, unit apimodel.GlucoseUnit) (formatted string, err error) {
+	switch unit {
+	case apimodel.MMOL_PER_L:
+		return fmt.Sprintf("%.2f", value), nil
+	case apimodel.MG_PER_DL:
+		return strconv.Itoa(int(value + 0.5)), nil
+	default:
+		return "", errors.New(fmt.Sprintf("Can't format value [%f] of unknown unit [%s]", value, unit))
+	}
+}
@@ -9,7 +9,8 @@ import (
 	"github.com/alexandre-normand/glukit/app/util"
 	"github.com/grd/stat"
 	"context"
-	"google.golang.org/appengine/log"
+	"github.com/alexandre-normand/glukit/app/log"
+	"math"
 	"sort"
 	"time"
 )
@@ -20,12 +21,31 @@ const (
 	// A1C estimation scoring period requirement
 	A1C_ESTIMATION_SCORE_PERIOD = 95
 
-	A1C_SCORING_VERSION = 3
+	// Version of the a1c estimation, 4 being estimates with a formula and glycation offset
+	A1C_SCORING_VERSION = 4
+
+	// Margin, in percent, of the confidence range of an a1c estimated from reads covering all of its period. The
+	// margin grows in inverse proportion to coverage up to A1C_MAX_CONFIDENCE_MARGIN.
+	A1C_MIN_CONFIDENCE_MARGIN = 0.3
+	A1C_MAX_CONFIDENCE_MARGIN = 1.5
+
+	// Number of most recent lab results the glycation offset is averaged over
+	LAB_A1C_CALIBRATION_RESULTS = 3
 )
 
-// CalculateA1CEstimate calculates an estimate of a a1c given the last 3 months of data. The current algo is naively assuming that the average of the last
-// 3 months will be an approximation of the a1c.
+// CalculateA1CEstimate calculates an estimate of a a1c given the last 3 months of data with the ADAG formula
 func CalculateA1CEstimate(context context.Context, reads []apimodel.GlucoseRead) (a1c *model.A1CEstimate, err error) {
+	return CalculateA1CEstimateWithFormula(context, reads, model.A1C_FORMULA_ADAG)
+}
+
+// CalculateA1CEstimateWithFormula calculates an estimate of a a1c given the last 3 months of data. The ADAG formula
+// naively assumes that the median of the last 3 months will be an approximation of the a1c while GMI uses the
+// average.
+func CalculateA1CEstimateWithFormula(context context.Context, reads []apimodel.GlucoseRead, formula string) (a1c *model.A1CEstimate, err error) {
+	if !model.IsValidA1CFormula(formula) {
+		return nil, errors.New(fmt.Sprintf("Unsupported a1c formula [%s]", formula))
+	}
+
 	if len(reads) == 0 {
 		return nil, errors.New(fmt.Sprintf("Insufficient read coverage to estimate a1c, got no reads"))
 	}
@@ -42,29 +62,117 @@ func CalculateA1CEstimate(context context.Context, reads []apimodel.GlucoseRead)
 		return nil, errors.New(fmt.Sprintf("Insufficient read coverage to estimate a1c, got [%d] days but requires [%d]", days, A1C_READ_COVERAGE_REQUIREMENT_IN_DAYS))
 	} else {
 		sortedReads := model.ReadStatsSlice(reads)
-		sort.Sort(sortedReads)
-		median := stat.MedianFromSortedData(sortedReads)
-		//a1c := (average + 77.3) / 35.6
-		a1c := (median + 77.3) / 35.6
-		log.Debugf(context, "Estimated a1c is [%f]", a1c)
+		var a1c float64
+		if formula == model.A1C_FORMULA_GMI {
+			a1c = CalculateGMI(stat.Mean(sortedReads))
+		} else {
+			sort.Sort(sortedReads)
+			median := stat.MedianFromSortedData(sortedReads)
+			a1c = (median + 77.3) / 35.6
+		}
+		log.Debugf(context, "Estimated a1c with formula [%s] is [%f]", formula, a1c)
 		return &model.A1CEstimate{
 			Value:          a1c,
 			LowerBound:     lowerBound,
 			UpperBound:     upperBound,
 			CalculatedOn:   time.Now(),
-			ScoringVersion: A1C_SCORING_VERSION}, nil
+			ScoringVersion: A1C_SCORING_VERSION,
+			Formula:        formula}, nil
 	}
 }
 
+// EstimateA1C estimates the a1c of the period ending at endOfPeriod with the user's formula and corrects it with the
+// user's glycation offset
 func EstimateA1C(context context.Context, glukitUser *model.GlukitUser, endOfPeriod time.Time) (a1c *model.A1CEstimate, err error) {
+	if a1c, err = estimateUncorrectedA1C(context, glukitUser.Email, glukitUser.GetA1CFormula(), endOfPeriod); err != nil {
+		return a1c, err
+	}
+
+	a1c.Value = a1c.Value + glukitUser.GlycationOffset
+	a1c.GlycationOffset = glukitUser.GlycationOffset
+	margin := a1cConfidenceMargin(a1c.Coverage)
+	a1c.ConfidenceLow = a1c.Value - margin
+	a1c.ConfidenceHigh = a1c.Value + margin
+
+	return a1c, nil
+}
+
+// estimateUncorrectedA1C estimates the a1c of the period ending at endOfPeriod with the given formula, filling gaps
+// in reads if gap filling is enabled
+func estimateUncorrectedA1C(context context.Context, email string, formula string, endOfPeriod time.Time) (a1c *model.A1CEstimate, err error) {
 	// Get the last period's worth of reads
 	upperBound := util.GetMidnightUTCBefore(endOfPeriod)
 	lowerBound := upperBound.AddDate(0, 0, -1*A1C_ESTIMATION_SCORE_PERIOD)
 
 	log.Debugf(context, "Getting reads for a1c estimate calculation from [%s] to [%s]", lowerBound, upperBound)
-	if reads, err := store.GetGlucoseReads(context, glukitUser.Email, lowerBound, upperBound); err != nil {
+	if reads, err := store.GetGlucoseReads(context, email, lowerBound, upperBound); err != nil {
 		return &model.UNDEFINED_A1C_ESTIMATE, err
 	} else {
-		return CalculateA1CEstimate(context, reads)
+		coverage := AnalyzeCoverage(reads, lowerBound, upperBound)
+		reads, interpolatedReads := FillGaps(reads, gapFilling.MaxGap)
+
+		if a1c, err = CalculateA1CEstimateWithFormula(context, reads, formula); err != nil {
+			return a1c, err
+		}
+
+		a1c.Coverage = coverage.Percentage
+		a1c.InterpolatedReads = interpolatedReads
+		return a1c, nil
+	}
+}
+
+// a1cConfidenceMargin returns the margin, in percent, on each side of an a1c estimated from reads covering the given
+// percentage of its period
+func a1cConfidenceMargin(coverage float64) float64 {
+	if coverage <= 0 {
+		return A1C_MAX_CONFIDENCE_MARGIN
+	}
+
+	return math.Min(A1C_MIN_CONFIDENCE_MARGIN*100/coverage, A1C_MAX_CONFIDENCE_MARGIN)
+}
+
+// RecordLabA1C stores a lab a1c result of the user and recalibrates the user's glycation offset
+func RecordLabA1C(context context.Context, glukitUser *model.GlukitUser, lab model.LabA1C) (err error) {
+	lab.RecordedOn = time.Now()
+	if err = store.StoreLabA1C(context, glukitUser.Email, lab); err != nil {
+		return err
 	}
+
+	return CalibrateA1C(context, glukitUser)
+}
+
+// CalibrateA1C estimates the a1c, with the user's current formula, at the time of each of the user's most recent lab
+// results and sets the user's glycation offset to the average difference between lab results and estimates. Lab
+// results without enough reads to estimate an a1c are left out. The offset applies to a1cs estimated from then on.
+func CalibrateA1C(context context.Context, glukitUser *model.GlukitUser) (err error) {
+	limit := LAB_A1C_CALIBRATION_RESULTS
+	labs, err := store.GetLabA1Cs(context, glukitUser.Email, store.ScoreScanQuery{Limit: &limit})
+	if err != nil {
+		return err
+	}
+
+	totalOffset := 0.
+	calibratingLabs := 0
+	for _, lab := range labs {
+		lab.EstimatedValue = 0
+		if estimate, err := estimateUncorrectedA1C(context, glukitUser.Email, glukitUser.GetA1CFormula(), lab.TakenOn); err != nil {
+			log.Infof(context, "No a1c estimate to calibrate with lab a1c of user [%s] taken on [%s]: %v", glukitUser.Email, lab.TakenOn, err)
+		} else {
+			lab.EstimatedValue = estimate.Value
+			totalOffset += lab.Value - estimate.Value
+			calibratingLabs++
+		}
+
+		if err = store.StoreLabA1C(context, glukitUser.Email, lab); err != nil {
+			return err
+		}
+	}
+
+	glukitUser.GlycationOffset = 0
+	if calibratingLabs > 0 {
+		glukitUser.GlycationOffset = totalOffset / float64(calibratingLabs)
+	}
+
+	log.Infof(context, "Calibrated glycation offset of user [%s] to [%f] from [%d] lab a1cs", glukitUser.Email, glukitUser.GlycationOffset, calibratingLabs)
+	return store.StoreUserProfile(context, time.Now(), *glukitUser)
 }
@@ -1,6 +1,7 @@
 package engine_test
 
 import (
+	"context"
 	"github.com/alexandre-normand/glukit/app/apimodel"
 	"github.com/alexandre-normand/glukit/app/bufio"
 	"github.com/alexandre-normand/glukit/app/engine"
@@ -8,10 +9,8 @@ import (
 	"github.com/alexandre-normand/glukit/app/store"
 	"github.com/alexandre-normand/glukit/app/streaming"
 	"github.com/alexandre-normand/glukit/app/util"
-	"golang.org/x/oauth2"
-	"google.golang.org/appengine/aetest"
-	"google.golang.org/appengine/datastore"
 	"log"
+	"math"
 	"sort"
 	"testing"
 	"time"
@@ -32,11 +31,7 @@ func TestA1cEstimationsFromFixedMeans(t *testing.T) {
 }
 
 func TestCalculationWithInsufficientCoverage(t *testing.T) {
-	c, err := aetest.NewContext(nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer c.Close()
+	c := context.Background()
 
 	r := make([]apimodel.GlucoseRead, 288*89)
 	ct, _ := time.Parse("02/01/2006 15:04", "18/04/2014 00:00")
@@ -52,11 +47,7 @@ func TestCalculationWithInsufficientCoverage(t *testing.T) {
 }
 
 func testA1CEstimateFromFixedAverage(t *testing.T, average float32, expectedA1C float64) {
-	c, err := aetest.NewContext(nil)
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer c.Close()
+	c := context.Background()
 
 	a1cEstimate, err := engine.CalculateA1CEstimate(c, generateReadsWithFixedAverage(average, time.Now()))
 	if err != nil {
@@ -80,28 +71,27 @@ func generateReadsWithFixedAverage(average float32, upperDate time.Time) []apimo
 	return r
 }
 
-func setupTestData(t *testing.T, average float32, upperDate time.Time) (c aetest.Context, glukitUser *model.GlukitUser, key *datastore.Key) {
-	c, err := aetest.NewContext(nil)
-	if err != nil {
-		t.Fatal(err)
-	}
+// setupTestData stores, in a new memory repository, a user with three months of reads at the given average
+func setupTestData(t *testing.T, average float32, upperDate time.Time) (c context.Context, glukitUser *model.GlukitUser) {
+	store.SetRepository(store.NewMemoryRepository())
+	c = context.Background()
 
 	user := model.GlukitUser{TEST_USER, "", "", upperDate,
 		"", "", util.GLUKIT_EPOCH_TIME, apimodel.UNDEFINED_GLUCOSE_READ,
-		model.UNDEFINED_SCORE, model.UNDEFINED_SCORE, false, "", upperDate, model.UNDEFINED_A1C_ESTIMATE}
+		model.UNDEFINED_SCORE, model.UNDEFINED_SCORE, false, "", upperDate, model.UNDEFINED_A1C_ESTIMATE, model.DEFAULT_GLUCOSE_TARGETS, model.A1C_FORMULA_ADAG, 0}
 
-	key, err = store.StoreUserProfile(c, upperDate, user)
+	err := store.StoreUserProfile(c, upperDate, user)
 	if err != nil {
 		t.Fatal(err)
 	}
-	log.Printf("Initialized [%s] with key [%v]", TEST_USER, key)
+	log.Printf("Initialized [%s]", TEST_USER)
 
-	dataStoreWriter := store.NewDataStoreGlucoseReadBatchWriter(c, key)
-	batchingWriter := bufio.NewGlucoseReadWriterSize(dataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
-	glucoseReadStreamer := streaming.NewGlucoseStreamerDuration(batchingWriter, apimodel.DAY_OF_DATA_DURATION)
+	dataStoreWriter := store.NewDataStoreGlucoseReadBatchWriter(c, TEST_USER)
+	batchingWriter := bufio.NewWriterSize(apimodel.GLUCOSE_READ_KIND, dataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
+	glucoseReadStreamer := streaming.NewStreamerDuration(batchingWriter, apimodel.DAY_OF_DATA_DURATION)
 
 	r := generateReadsWithFixedAverage(average, upperDate)
-	glucoseReadStreamer, err = glucoseReadStreamer.WriteGlucoseReads(r)
+	glucoseReadStreamer, err = glucoseReadStreamer.WriteAll(r)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -111,14 +101,14 @@ func setupTestData(t *testing.T, average float32, upperDate time.Time) (c aetest
 		t.Fatal(err)
 	}
 
-	return c, &user, key
+	return c, &user
 }
 
 func TestFetchAndEstimateFlow(t *testing.T) {
 	upperDate, _ := time.Parse(util.TIMEFORMAT_NO_TZ, "2014-04-18 00:00:00")
 
-	c, glukitUser, _ := setupTestData(t, 79, upperDate)
-	defer c.Close()
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c, glukitUser := setupTestData(t, 79, upperDate)
 
 	a1cEstimate, err := engine.EstimateA1C(c, glukitUser, upperDate)
 	if err != nil {
@@ -135,3 +125,114 @@ func TestFetchAndEstimateFlow(t *testing.T) {
 func roundToOneDecimal(value float64) float64 {
 	return float64(int((value+0.05)*10)) / 10
 }
+
+func TestGMIA1CEstimate(t *testing.T) {
+	a1cEstimate, err := engine.CalculateA1CEstimateWithFormula(context.Background(), generateReadsWithFixedAverage(154, time.Now()), model.A1C_FORMULA_GMI)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if math.Abs(a1cEstimate.Value-6.99368) > 0.0001 || a1cEstimate.Formula != model.A1C_FORMULA_GMI {
+		t.Errorf("Expected a GMI of [6.99368] but got [%v]", a1cEstimate)
+	}
+}
+
+func TestUnsupportedA1CFormula(t *testing.T) {
+	if a1cEstimate, err := engine.CalculateA1CEstimateWithFormula(context.Background(), generateReadsWithFixedAverage(154, time.Now()), "HbA1c"); err == nil {
+		t.Errorf("Expected an error estimating an a1c with an unsupported formula but got [%v]", a1cEstimate)
+	}
+}
+
+// storeDaysOfFixedReads stores, in a new memory repository, a user with reads at value every 5 minutes for the given
+// number of days up to upperDate
+func storeDaysOfFixedReads(t *testing.T, c context.Context, value float32, days int, upperDate time.Time) (glukitUser *model.GlukitUser) {
+	store.SetRepository(store.NewMemoryRepository())
+
+	glukitUser = &model.GlukitUser{Email: TEST_USER}
+	if err := store.StoreUserProfile(c, upperDate, *glukitUser); err != nil {
+		t.Fatal(err)
+	}
+
+	daysOfReads := make([]apimodel.DayOfGlucoseReads, 0, days)
+	for dayStart := upperDate.AddDate(0, 0, -days); dayStart.Before(upperDate); dayStart = dayStart.AddDate(0, 0, 1) {
+		reads := readsEvery(dayStart, READ_STEP, 288, func(i int, readTime time.Time) float64 {
+			return float64(value)
+		})
+		daysOfReads = append(daysOfReads, apimodel.DayOfGlucoseReads{reads, reads[0].GetTime(), reads[len(reads)-1].GetTime()})
+	}
+
+	if err := store.StoreDaysOfReads(c, TEST_USER, daysOfReads); err != nil {
+		t.Fatal(err)
+	}
+
+	return glukitUser
+}
+
+func TestA1CConfidenceRangeWidensWithLowerCoverage(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c := context.Background()
+	upperDate := time.Date(2014, 4, 18, 0, 0, 0, 0, time.UTC)
+
+	glukitUser := storeDaysOfFixedReads(t, c, 154, engine.A1C_ESTIMATION_SCORE_PERIOD, upperDate)
+	fullCoverage, err := engine.EstimateA1C(c, glukitUser, upperDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if math.Abs(fullCoverage.ConfidenceHigh-fullCoverage.ConfidenceLow-2*engine.A1C_MIN_CONFIDENCE_MARGIN) > 0.01 {
+		t.Errorf("Expected a confidence range of [%.1f] with full coverage but got [%v]", 2*engine.A1C_MIN_CONFIDENCE_MARGIN, fullCoverage)
+	}
+
+	glukitUser = storeDaysOfFixedReads(t, c, 154, engine.A1C_READ_COVERAGE_REQUIREMENT_IN_DAYS+1, upperDate)
+	partialCoverage, err := engine.EstimateA1C(c, glukitUser, upperDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if partialCoverage.Coverage >= fullCoverage.Coverage || partialCoverage.ConfidenceHigh-partialCoverage.ConfidenceLow <= fullCoverage.ConfidenceHigh-fullCoverage.ConfidenceLow {
+		t.Errorf("Expected a wider confidence range than [%v] with lower coverage but got [%v]", fullCoverage, partialCoverage)
+	}
+}
+
+func TestLabA1CCalibratesEstimates(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c := context.Background()
+	upperDate := time.Date(2014, 4, 18, 0, 0, 0, 0, time.UTC)
+
+	glukitUser := storeDaysOfFixedReads(t, c, 154, engine.A1C_ESTIMATION_SCORE_PERIOD, upperDate)
+	if err := engine.RecordLabA1C(c, glukitUser, model.LabA1C{Value: 7.5, TakenOn: upperDate}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The ADAG estimate of a median of 154 mg/dL is 6.5
+	if roundToOneDecimal(glukitUser.GlycationOffset) != 1.0 {
+		t.Errorf("Expected a glycation offset of [1.0] but got [%f]", glukitUser.GlycationOffset)
+	}
+
+	labs, err := store.GetLabA1Cs(c, TEST_USER, store.ScoreScanQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(labs) != 1 || roundToOneDecimal(labs[0].EstimatedValue) != 6.5 {
+		t.Errorf("Expected the lab a1c to be recorded with its estimate of [6.5] but got [%v]", labs)
+	}
+
+	a1cEstimate, err := engine.EstimateA1C(c, glukitUser, upperDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if roundToOneDecimal(a1cEstimate.Value) != 7.5 || a1cEstimate.GlycationOffset != glukitUser.GlycationOffset {
+		t.Errorf("Expected a corrected a1c estimate of [7.5] but got [%v]", a1cEstimate)
+	}
+
+	// A lab result without reads to estimate from doesn't change the offset
+	if err = engine.RecordLabA1C(c, glukitUser, model.LabA1C{Value: 9, TakenOn: upperDate.AddDate(1, 0, 0)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if roundToOneDecimal(glukitUser.GlycationOffset) != 1.0 {
+		t.Errorf("Expected the glycation offset to stay at [1.0] but got [%f]", glukitUser.GlycationOffset)
+	}
+}
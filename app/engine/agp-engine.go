@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/grd/stat"
+	"sort"
+	"time"
+)
+
+const (
+	// Number of days of reads an AGP is built from
+	AGP_PERIOD_DAYS = 14
+
+	// Supported bucket sizes for the AGP
+	AGP_BUCKET_5_MINUTES  = 5
+	AGP_BUCKET_15_MINUTES = 15
+
+	MINUTES_PER_DAY = 24 * 60
+)
+
+// BuildAGP builds the ambulatory glucose profile of a user from the AGP_PERIOD_DAYS days of reads ending at endOfPeriod
+func BuildAGP(context context.Context, email string, endOfPeriod time.Time, bucketMinutes int, unit apimodel.GlucoseUnit) (agp *model.AmbulatoryGlucoseProfile, err error) {
+	upperBound := endOfPeriod
+	lowerBound := upperBound.AddDate(0, 0, -1*AGP_PERIOD_DAYS)
+
+	log.Debugf(context, "Getting reads for agp calculation from [%s] to [%s]", lowerBound, upperBound)
+	reads, err := store.GetGlucoseReads(context, email, lowerBound, upperBound)
+	if err != nil {
+		return nil, err
+	}
+
+	if agp, err = CalculateAGP(reads, bucketMinutes, unit); err != nil {
+		return nil, err
+	}
+	agp.LowerBound = lowerBound
+	agp.UpperBound = upperBound
+
+	return agp, nil
+}
+
+// CalculateAGP calculates the glucose percentiles of reads by time of day. Reads are assigned to buckets using their
+// own timezone so that the profile reflects the user's local day even across timezone changes.
+func CalculateAGP(reads []apimodel.GlucoseRead, bucketMinutes int, unit apimodel.GlucoseUnit) (agp *model.AmbulatoryGlucoseProfile, err error) {
+	if bucketMinutes != AGP_BUCKET_5_MINUTES && bucketMinutes != AGP_BUCKET_15_MINUTES {
+		return nil, errors.New(fmt.Sprintf("Bad agp bucket size [%d], must be one of [%d, %d]", bucketMinutes, AGP_BUCKET_5_MINUTES, AGP_BUCKET_15_MINUTES))
+	}
+
+	valuesByBucket := make([][]float64, MINUTES_PER_DAY/bucketMinutes)
+	for _, read := range reads {
+		value, err := read.GetNormalizedValue(unit)
+		if err != nil {
+			return nil, err
+		}
+
+		localTime := read.GetTime()
+		bucket := (localTime.Hour()*60 + localTime.Minute()) / bucketMinutes
+		valuesByBucket[bucket] = append(valuesByBucket[bucket], float64(value))
+	}
+
+	agp = &model.AmbulatoryGlucoseProfile{BucketMinutes: bucketMinutes, Unit: unit, Buckets: make([]model.AGPBucket, 0)}
+	if len(reads) > 0 {
+		agp.LowerBound = reads[0].GetTime()
+		agp.UpperBound = reads[len(reads)-1].GetTime()
+	}
+
+	for i, values := range valuesByBucket {
+		if len(values) == 0 {
+			continue
+		}
+
+		sort.Float64s(values)
+		sortedValues := stat.Float64Slice(values)
+		agp.Buckets = append(agp.Buckets, model.AGPBucket{
+			MinuteOfDay:  i * bucketMinutes,
+			ReadCount:    len(values),
+			Percentile5:  stat.QuantileFromSortedData(sortedValues, 0.05),
+			Percentile25: stat.QuantileFromSortedData(sortedValues, 0.25),
+			Percentile50: stat.QuantileFromSortedData(sortedValues, 0.50),
+			Percentile75: stat.QuantileFromSortedData(sortedValues, 0.75),
+			Percentile95: stat.QuantileFromSortedData(sortedValues, 0.95)})
+	}
+
+	return agp, nil
+}
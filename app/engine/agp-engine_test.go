@@ -0,0 +1,60 @@
+package engine_test
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"testing"
+	"time"
+)
+
+func TestAGPBucketsReadsByLocalTimeOfDay(t *testing.T) {
+	location, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reads := make([]apimodel.GlucoseRead, 0)
+	// 20 days of reads at 8h05 and 8h10 local time with values from 100 to 119 and 200 to 219 and a single read at 23h55
+	for day := 0; day < 20; day++ {
+		morning := time.Date(2014, 4, 1+day, 8, 5, 0, 0, location)
+		reads = append(reads, newLocalRead(morning, float32(100+day)))
+		reads = append(reads, newLocalRead(morning.Add(time.Duration(5)*time.Minute), float32(200+day)))
+	}
+	reads = append(reads, newLocalRead(time.Date(2014, 4, 21, 23, 55, 0, 0, location), 150))
+
+	agp, err := engine.CalculateAGP(reads, engine.AGP_BUCKET_5_MINUTES, apimodel.MG_PER_DL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(agp.Buckets) != 3 {
+		t.Fatalf("Expected [3] buckets but got [%d]: [%v]", len(agp.Buckets), agp.Buckets)
+	}
+
+	first := agp.Buckets[0]
+	if first.MinuteOfDay != 8*60+5 || first.ReadCount != 20 {
+		t.Errorf("Expected first bucket at minute [%d] with [20] reads but got [%v]", 8*60+5, first)
+	}
+	assertMetric(t, "p50", first.Percentile50, 109.5)
+	assertMetric(t, "p5", first.Percentile5, 100.95)
+	assertMetric(t, "p95", first.Percentile95, 118.05)
+
+	if agp.Buckets[2].MinuteOfDay != 23*60+55 {
+		t.Errorf("Expected last bucket at minute [%d] but got [%d]", 23*60+55, agp.Buckets[2].MinuteOfDay)
+	}
+
+	agp, err = engine.CalculateAGP(reads, engine.AGP_BUCKET_15_MINUTES, apimodel.MG_PER_DL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(agp.Buckets) != 2 || agp.Buckets[0].MinuteOfDay != 8*60 || agp.Buckets[0].ReadCount != 40 {
+		t.Errorf("Expected reads at 8h05 and 8h10 to share the 8h00 bucket but got [%v]", agp.Buckets)
+	}
+}
+
+func TestAGPWithUnsupportedBucketSize(t *testing.T) {
+	if _, err := engine.CalculateAGP(readsOfValues(metricsStart, 100), 10, apimodel.MG_PER_DL); err == nil {
+		t.Errorf("Expected an error for a bucket size of [10] minutes")
+	}
+}
@@ -0,0 +1,288 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/notifier"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/tasks"
+	"math"
+	"time"
+)
+
+const (
+	ALERTS_QUEUE_NAME                = "alerts"
+	ALERT_EVALUATION_FUNCTION_NAME   = "runAlertEvaluation"
+	MISSING_DATA_CHECK_FUNCTION_NAME = "runMissingDataCheck"
+	// Reads further apart than this break an episode of sustained lows or highs and don't give a rate of change
+	MAX_ALERT_READ_GAP = 15 * time.Minute
+)
+
+var RunAlertEvaluationTask = tasks.Func(ALERT_EVALUATION_FUNCTION_NAME, RunAlertEvaluation)
+var RunMissingDataCheckTask = tasks.Func(MISSING_DATA_CHECK_FUNCTION_NAME, RunMissingDataCheck)
+
+// StartAlertEvaluation queues the evaluation of the alert rules of a user against the reads written between
+// lowerBound (exclusive) and upperBound (inclusive)
+func StartAlertEvaluation(context context.Context, userEmail string, lowerBound time.Time, upperBound time.Time) (err error) {
+	if err = RunAlertEvaluationTask.Add(context, ALERTS_QUEUE_NAME, userEmail, lowerBound, upperBound); err != nil {
+		return err
+	}
+
+	log.Debugf(context, "Queued up alert evaluation for user [%s] between [%s] and [%s]", userEmail, lowerBound, upperBound)
+	return nil
+}
+
+// StartMissingDataCheck queues the check of all users monitoring missing data
+func StartMissingDataCheck(context context.Context) (err error) {
+	return RunMissingDataCheckTask.Add(context, ALERTS_QUEUE_NAME)
+}
+
+// RunAlertEvaluation evaluates the alert rules of a user against the reads between lowerBound (exclusive) and
+// upperBound (inclusive), looking back far enough for sustained lows and highs that started before lowerBound. Alerts
+// that weren't already raised are dispatched and recorded.
+func RunAlertEvaluation(context context.Context, userEmail string, lowerBound time.Time, upperBound time.Time) {
+	settings, err := store.GetAlertSettings(context, userEmail)
+	if err == store.ErrNoSuchEntity {
+		log.Debugf(context, "No alert rules for user [%s], skipping evaluation", userEmail)
+		return
+	} else if err != nil {
+		log.Errorf(context, "Error getting alert settings of user [%s]: %v", userEmail, err)
+		return
+	}
+
+	if skipAccountPendingDeletion(context, userEmail, "alert evaluation") {
+		return
+	}
+
+	reads, err := store.GetGlucoseReads(context, userEmail, lowerBound.Add(-alertLookback(settings.Rules)), upperBound)
+	if err != nil {
+		log.Errorf(context, "Error getting reads of user [%s] to evaluate alerts: %v", userEmail, err)
+		return
+	}
+
+	events, err := EvaluateAlertRules(settings.Rules, reads, lowerBound, upperBound)
+	if err != nil {
+		log.Errorf(context, "Error evaluating alert rules of user [%s]: %v", userEmail, err)
+		return
+	}
+
+	raiseAlerts(context, userEmail, *settings, events)
+}
+
+// RunMissingDataCheck raises a missing data alert for every user whose most recent read is older than the duration
+// of one of its missing data rules. The alert's time is the one at which data went missing so that it's only raised
+// once per gap no matter how many times the check runs.
+func RunMissingDataCheck(context context.Context) {
+	emails, err := store.FindEmailsMonitoringMissingData(context)
+	if err != nil {
+		log.Errorf(context, "Error finding users monitoring missing data: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, email := range emails {
+		settings, err := store.GetAlertSettings(context, email)
+		if err != nil {
+			log.Warningf(context, "Error getting alert settings of user [%s]: %v", email, err)
+			continue
+		}
+
+		glukitUser, err := store.GetUserProfile(context, email)
+		if err != nil {
+			log.Warningf(context, "Error getting profile of user [%s] to check for missing data: %v", email, err)
+			continue
+		}
+
+		if glukitUser.MostRecentRead.Time.Timestamp == 0 {
+			continue
+		}
+
+		events := EvaluateMissingDataRules(settings.Rules, glukitUser.MostRecentRead.GetTime(), now)
+		if len(events) > 0 {
+			raiseAlerts(context, email, *settings, events)
+		}
+	}
+}
+
+// EvaluateAlertRules returns the alerts raised by reads, sorted in ascending order of time, between lowerBound
+// (exclusive) and upperBound (inclusive). Earlier reads are only used to know when an episode started. A sustained
+// low or high is raised once per episode, when it has lasted for the rule's duration, and a rate of change is raised
+// once per run of consecutive reads changing faster than the rule's threshold. Missing data rules aren't evaluated
+// against reads, see EvaluateMissingDataRules.
+func EvaluateAlertRules(rules []model.AlertRule, reads []apimodel.GlucoseRead, lowerBound time.Time, upperBound time.Time) (events []model.AlertEvent, err error) {
+	values := make([]float64, len(reads))
+	for i, read := range reads {
+		value, err := read.GetNormalizedValue(apimodel.MG_PER_DL)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = float64(value)
+	}
+
+	events = make([]model.AlertEvent, 0)
+	for _, rule := range rules {
+		var ruleEvents []model.AlertEvent
+		switch rule.Type {
+		case model.SUSTAINED_LOW_ALERT:
+			ruleEvents = evaluateSustainedRule(rule, reads, values, func(value float64) bool { return value < rule.Threshold })
+		case model.SUSTAINED_HIGH_ALERT:
+			ruleEvents = evaluateSustainedRule(rule, reads, values, func(value float64) bool { return value > rule.Threshold })
+		case model.RATE_OF_CHANGE_ALERT:
+			ruleEvents = evaluateRateOfChangeRule(rule, reads, values)
+		}
+
+		for _, event := range ruleEvents {
+			if event.Time.After(lowerBound) && !event.Time.After(upperBound) {
+				events = insertAlertEvent(events, event)
+			}
+		}
+	}
+
+	return events, nil
+}
+
+// EvaluateMissingDataRules returns the alerts raised by missing data rules given the time of the most recent read
+func EvaluateMissingDataRules(rules []model.AlertRule, mostRecentRead time.Time, now time.Time) (events []model.AlertEvent) {
+	events = make([]model.AlertEvent, 0)
+	for _, rule := range rules {
+		if rule.Type != model.MISSING_DATA_ALERT {
+			continue
+		}
+
+		duration := time.Duration(rule.DurationMinutes) * time.Minute
+		if now.Sub(mostRecentRead) >= duration {
+			events = insertAlertEvent(events, model.AlertEvent{Type: rule.Type, Time: mostRecentRead.Add(duration),
+				Value: float64(rule.DurationMinutes), Rule: rule,
+				Message: fmt.Sprintf("No glucose data for %d minutes since %s", rule.DurationMinutes, mostRecentRead.Format(time.RFC3339))})
+		}
+	}
+
+	return events
+}
+
+// evaluateSustainedRule raises an alert when reads meet the condition for at least the rule's duration
+func evaluateSustainedRule(rule model.AlertRule, reads []apimodel.GlucoseRead, values []float64, meetsCondition func(value float64) bool) (events []model.AlertEvent) {
+	duration := time.Duration(rule.DurationMinutes) * time.Minute
+	inEpisode, raised := false, false
+	var episodeStart, previousTime time.Time
+
+	for i, read := range reads {
+		readTime := read.GetTime()
+		if inEpisode && readTime.Sub(previousTime) > MAX_ALERT_READ_GAP {
+			inEpisode = false
+		}
+		previousTime = readTime
+
+		if !meetsCondition(values[i]) {
+			inEpisode = false
+			continue
+		}
+
+		if !inEpisode {
+			inEpisode, raised, episodeStart = true, false, readTime
+		}
+
+		if !raised && readTime.Sub(episodeStart) >= duration {
+			raised = true
+			direction := "below"
+			if rule.Type == model.SUSTAINED_HIGH_ALERT {
+				direction = "above"
+			}
+
+			events = append(events, model.AlertEvent{Type: rule.Type, Time: readTime, Value: values[i], Rule: rule,
+				Message: fmt.Sprintf("Glucose %s %.0f mg/dL for %d minutes, now at %.0f mg/dL", direction, rule.Threshold,
+					int(readTime.Sub(episodeStart).Minutes()), values[i])})
+		}
+	}
+
+	return events
+}
+
+// evaluateRateOfChangeRule raises an alert when glucose changes faster than the rule's threshold between two
+// consecutive reads
+func evaluateRateOfChangeRule(rule model.AlertRule, reads []apimodel.GlucoseRead, values []float64) (events []model.AlertEvent) {
+	inEpisode := false
+	for i := 1; i < len(reads); i++ {
+		elapsed := reads[i].GetTime().Sub(reads[i-1].GetTime())
+		if elapsed <= 0 || elapsed > MAX_ALERT_READ_GAP {
+			inEpisode = false
+			continue
+		}
+
+		rate := (values[i] - values[i-1]) / elapsed.Minutes()
+		if math.Abs(rate) <= rule.Threshold {
+			inEpisode = false
+			continue
+		}
+
+		if !inEpisode {
+			inEpisode = true
+			direction := "rising"
+			if rate < 0 {
+				direction = "falling"
+			}
+
+			events = append(events, model.AlertEvent{Type: rule.Type, Time: reads[i].GetTime(), Value: rate, Rule: rule,
+				Message: fmt.Sprintf("Glucose %s at %.1f mg/dL/min, now at %.0f mg/dL", direction, math.Abs(rate), values[i])})
+		}
+	}
+
+	return events
+}
+
+// raiseAlerts dispatches and records the alerts that weren't already raised for the user. An alert is recorded even
+// if its delivery failed so that it isn't raised again.
+func raiseAlerts(context context.Context, userEmail string, settings model.AlertSettings, events []model.AlertEvent) {
+	notifiers := notifier.NotifiersFor(settings)
+
+	for _, event := range events {
+		exists, err := store.HasAlertEvent(context, userEmail, event.Rule, event.Time)
+		if err != nil {
+			log.Errorf(context, "Error checking for alert [%s] at [%s] of user [%s]: %v", event.Type, event.Time, userEmail, err)
+			continue
+		} else if exists {
+			continue
+		}
+
+		event.RaisedOn = time.Now()
+		event.Delivered = len(notifiers) > 0
+		for _, alertNotifier := range notifiers {
+			if err := alertNotifier.Notify(context, userEmail, event); err != nil {
+				log.Warningf(context, "Error delivering alert [%s] at [%s] to user [%s]: %v", event.Type, event.Time, userEmail, err)
+				event.Delivered = false
+			}
+		}
+
+		if err := store.StoreAlertEvent(context, userEmail, event); err != nil {
+			log.Errorf(context, "Error recording alert [%s] at [%s] of user [%s]: %v", event.Type, event.Time, userEmail, err)
+		}
+	}
+}
+
+// alertLookback returns how far before the evaluated reads to look for the start of an episode
+func alertLookback(rules []model.AlertRule) (lookback time.Duration) {
+	lookback = MAX_ALERT_READ_GAP
+	for _, rule := range rules {
+		if duration := time.Duration(rule.DurationMinutes)*time.Minute + MAX_ALERT_READ_GAP; rule.Type != model.MISSING_DATA_ALERT && duration > lookback {
+			lookback = duration
+		}
+	}
+
+	return lookback
+}
+
+// insertAlertEvent inserts an event keeping events sorted in ascending order of time
+func insertAlertEvent(events []model.AlertEvent, event model.AlertEvent) []model.AlertEvent {
+	i := len(events)
+	for i > 0 && events[i-1].Time.After(event.Time) {
+		i--
+	}
+
+	events = append(events, model.AlertEvent{})
+	copy(events[i+1:], events[i:])
+	events[i] = event
+	return events
+}
@@ -0,0 +1,191 @@
+package engine_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/notifier"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/util"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const ALERTS_USER = "alerts@glukit.com"
+
+var alertsStart = time.Date(2014, 4, 18, 0, 0, 0, 0, time.UTC)
+
+func TestSustainedLowIsRaisedOncePerEpisode(t *testing.T) {
+	rules := []model.AlertRule{{model.SUSTAINED_LOW_ALERT, 70, 15}}
+	reads := readsOfValues(alertsStart, 80, 65, 62, 60, 58, 55, 75, 65, 60, 60, 60)
+
+	events, err := engine.EvaluateAlertRules(rules, reads, alertsStart.Add(-time.Minute), alertsStart.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first episode starts at 5 minutes and lasts 15 minutes by the read at 20 minutes, the second starts at 35
+	// minutes and lasts 15 minutes by the read at 50 minutes
+	expectedTimes := []time.Time{alertsStart.Add(20 * time.Minute), alertsStart.Add(50 * time.Minute)}
+	if len(events) != len(expectedTimes) {
+		t.Fatalf("Expected [%d] alerts but got [%v]", len(expectedTimes), events)
+	}
+
+	for i, expectedTime := range expectedTimes {
+		if !events[i].Time.Equal(expectedTime) || events[i].Type != model.SUSTAINED_LOW_ALERT {
+			t.Errorf("Expected sustained low at [%s] but got [%v]", expectedTime, events[i])
+		}
+	}
+}
+
+func TestSustainedHighIsBrokenByGapInReads(t *testing.T) {
+	rules := []model.AlertRule{{model.SUSTAINED_HIGH_ALERT, 180, 15}}
+	reads := readsOfValues(alertsStart, 200, 210, 220)
+	// Next read comes after a gap longer than the max read gap, restarting the episode
+	reads = append(reads, newLocalRead(alertsStart.Add(40*time.Minute), 230))
+
+	events, err := engine.EvaluateAlertRules(rules, reads, alertsStart.Add(-time.Minute), alertsStart.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 0 {
+		t.Errorf("Expected no alert but got [%v]", events)
+	}
+}
+
+func TestAlertsOutsideOfBoundsAreNotRaised(t *testing.T) {
+	rules := []model.AlertRule{{model.SUSTAINED_LOW_ALERT, 70, 10}}
+	reads := readsOfValues(alertsStart, 60, 60, 60, 60, 60)
+
+	// The alert is at 10 minutes, before the reads being evaluated
+	events, err := engine.EvaluateAlertRules(rules, reads, alertsStart.Add(10*time.Minute), alertsStart.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 0 {
+		t.Errorf("Expected no alert but got [%v]", events)
+	}
+}
+
+func TestRateOfChange(t *testing.T) {
+	rules := []model.AlertRule{{model.RATE_OF_CHANGE_ALERT, 2, 0}}
+	reads := readsOfValues(alertsStart, 100, 105, 120, 140, 145, 120)
+
+	events, err := engine.EvaluateAlertRules(rules, reads, alertsStart.Add(-time.Minute), alertsStart.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rising at 3 mg/dL/min from 10 minutes and at 4 mg/dL/min at 15 minutes is a single episode, falling at
+	// 5 mg/dL/min at 25 minutes is another one
+	if len(events) != 2 {
+		t.Fatalf("Expected [2] alerts but got [%v]", events)
+	}
+
+	if !events[0].Time.Equal(alertsStart.Add(10*time.Minute)) || events[0].Value != 3 {
+		t.Errorf("Expected rise of [3] mg/dL/min at [%s] but got [%v]", alertsStart.Add(10*time.Minute), events[0])
+	}
+
+	if !events[1].Time.Equal(alertsStart.Add(25*time.Minute)) || events[1].Value != -5 {
+		t.Errorf("Expected fall of [5] mg/dL/min at [%s] but got [%v]", alertsStart.Add(25*time.Minute), events[1])
+	}
+}
+
+func TestMissingData(t *testing.T) {
+	rules := []model.AlertRule{{model.MISSING_DATA_ALERT, 0, 120}}
+
+	if events := engine.EvaluateMissingDataRules(rules, alertsStart, alertsStart.Add(time.Hour)); len(events) != 0 {
+		t.Errorf("Expected no alert after an hour but got [%v]", events)
+	}
+
+	events := engine.EvaluateMissingDataRules(rules, alertsStart, alertsStart.Add(3*time.Hour))
+	if len(events) != 1 || !events[0].Time.Equal(alertsStart.Add(2*time.Hour)) {
+		t.Errorf("Expected missing data alert at [%s] but got [%v]", alertsStart.Add(2*time.Hour), events)
+	}
+}
+
+func TestAlertEvaluationDeliversAndRecordsAlertsOnce(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	defer notifier.SetHttpClientProvider(func(context context.Context) *http.Client { return http.DefaultClient })
+
+	received := make([]notifier.WebhookPayload, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		var payload notifier.WebhookPayload
+		if err := json.NewDecoder(request.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		received = append(received, payload)
+	}))
+	defer server.Close()
+	notifier.SetHttpClientProvider(func(context context.Context) *http.Client { return server.Client() })
+
+	store.SetRepository(store.NewMemoryRepository())
+	c := context.Background()
+	user := model.GlukitUser{ALERTS_USER, "", "", time.Now(),
+		model.DIABETES_TYPE_1, "", util.GLUKIT_EPOCH_TIME, apimodel.UNDEFINED_GLUCOSE_READ,
+		model.UNDEFINED_SCORE, model.UNDEFINED_SCORE, false, "", time.Now(), model.UNDEFINED_A1C_ESTIMATE, model.DEFAULT_GLUCOSE_TARGETS, model.A1C_FORMULA_ADAG, 0}
+	if err := store.StoreUserProfile(c, time.Now(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := model.AlertSettings{Rules: []model.AlertRule{{model.SUSTAINED_LOW_ALERT, 70, 10}}, WebhookUrl: server.URL}
+	if err := store.StoreAlertSettings(c, ALERTS_USER, settings); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.StoreDaysOfReads(c, ALERTS_USER, []apimodel.DayOfGlucoseReads{apimodel.NewDayOfGlucoseReads(readsOfValues(alertsStart, 80, 60, 60, 60, 60))}); err != nil {
+		t.Fatal(err)
+	}
+
+	engine.RunAlertEvaluation(c, ALERTS_USER, alertsStart.Add(-time.Second), alertsStart.Add(time.Hour))
+	engine.RunAlertEvaluation(c, ALERTS_USER, alertsStart.Add(-time.Second), alertsStart.Add(time.Hour))
+
+	if len(received) != 1 || received[0].Email != ALERTS_USER || received[0].Alert.Type != model.SUSTAINED_LOW_ALERT {
+		t.Fatalf("Expected a single sustained low alert delivered to the webhook but got [%v]", received)
+	}
+
+	events, err := store.GetAlertEvents(c, ALERTS_USER, alertsStart, alertsStart.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || !events[0].Delivered || !events[0].Time.Equal(alertsStart.Add(15*time.Minute)) {
+		t.Errorf("Expected a single delivered alert at [%s] but got [%v]", alertsStart.Add(15*time.Minute), events)
+	}
+}
+
+func TestRulesOfTheSameTypeAreRaisedIndependently(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	store.SetRepository(store.NewMemoryRepository())
+	c := context.Background()
+	if err := store.StoreUserProfile(c, time.Now(), model.GlukitUser{Email: ALERTS_USER}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both rules are met by the same read
+	settings := model.AlertSettings{Rules: []model.AlertRule{{model.SUSTAINED_LOW_ALERT, 70, 10}, {model.SUSTAINED_LOW_ALERT, 65, 10}}}
+	if err := store.StoreAlertSettings(c, ALERTS_USER, settings); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.StoreDaysOfReads(c, ALERTS_USER, []apimodel.DayOfGlucoseReads{apimodel.NewDayOfGlucoseReads(readsOfValues(alertsStart, 80, 60, 60, 60, 60))}); err != nil {
+		t.Fatal(err)
+	}
+
+	engine.RunAlertEvaluation(c, ALERTS_USER, alertsStart.Add(-time.Second), alertsStart.Add(time.Hour))
+
+	events, err := store.GetAlertEvents(c, ALERTS_USER, alertsStart, alertsStart.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 || events[0].Rule == events[1].Rule {
+		t.Errorf("Expected an alert for each of the [2] rules but got [%v]", events)
+	}
+}
@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/model"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// Default number of days of calibrations the accuracy of a sensor is calculated from
+	CALIBRATION_ACCURACY_PERIOD = 30
+)
+
+// parkesBoundary is a line of the Parkes error grid, as points of meter and sensor values in mg/dL ordered by meter
+// value, bounding a zone from above or below
+type parkesBoundary [][2]float64
+
+// Upper and lower boundaries of zones A to D of the Parkes (consensus) error grid for type 1 diabetes, everything
+// outside of zone D being in zone E. Zone D doesn't have a lower boundary.
+var parkesZones = []struct {
+	zone  string
+	upper parkesBoundary
+	lower parkesBoundary
+}{
+	{model.ERROR_GRID_ZONE_A, parkesBoundary{{0, 50}, {30, 50}, {140, 170}, {280, 380}, {430, 550}}, parkesBoundary{{50, 0}, {50, 30}, {170, 145}, {385, 300}, {550, 450}}},
+	{model.ERROR_GRID_ZONE_B, parkesBoundary{{0, 60}, {30, 60}, {50, 80}, {70, 110}, {260, 550}}, parkesBoundary{{120, 0}, {120, 30}, {260, 130}, {550, 250}}},
+	{model.ERROR_GRID_ZONE_C, parkesBoundary{{0, 100}, {25, 100}, {50, 125}, {80, 215}, {125, 550}}, parkesBoundary{{250, 0}, {250, 40}, {550, 150}}},
+	{model.ERROR_GRID_ZONE_D, parkesBoundary{{0, 150}, {35, 155}, {50, 550}}, nil},
+}
+
+// AnalyzeCalibrationAccuracy pairs calibrations between lowerBound and upperBound with the reads around them and
+// returns the accuracy of the sensor over the whole period, by sensor session and by day
+func AnalyzeCalibrationAccuracy(reads []apimodel.GlucoseRead, calibrations []apimodel.CalibrationRead, sessions []model.SensorSession, lowerBound time.Time, upperBound time.Time) (accuracy model.CalibrationAccuracy, err error) {
+	sortedReads := make([]apimodel.GlucoseRead, len(reads))
+	copy(sortedReads, reads)
+	sort.Sort(apimodel.GlucoseReadSlice(sortedReads))
+
+	windowCalibrations := make([]apimodel.CalibrationRead, 0, len(calibrations))
+	for _, calibration := range calibrations {
+		if calibrationTime := calibration.GetTime(); !calibrationTime.Before(lowerBound) && !calibrationTime.After(upperBound) {
+			windowCalibrations = append(windowCalibrations, calibration)
+		}
+	}
+
+	pairs, err := PairCalibrations(sortedReads, windowCalibrations)
+	if err != nil {
+		return accuracy, err
+	}
+
+	accuracy = model.CalibrationAccuracy{LowerBound: lowerBound, UpperBound: upperBound, Overall: summarizeAccuracy(pairs),
+		Sessions: make([]model.SessionAccuracy, 0), Days: make([]model.DailyAccuracy, 0)}
+
+	for _, session := range sessions {
+		sessionPairs := make([]model.CalibrationPair, 0)
+		for _, pair := range pairs {
+			if session.Contains(pair.Time) {
+				sessionPairs = append(sessionPairs, pair)
+			}
+		}
+
+		if len(sessionPairs) > 0 {
+			sessionEnd := session.EndedOn
+			if session.IsOngoing() {
+				sessionEnd = upperBound
+			}
+
+			accuracy.Sessions = append(accuracy.Sessions, model.SessionAccuracy{Start: session.InsertedOn, End: sessionEnd,
+				Statistics: summarizeAccuracy(sessionPairs), Pairs: sessionPairs})
+		}
+	}
+
+	for i := 0; i < len(pairs); {
+		date := pairs[i].Time.Format("2006-01-02")
+		end := i + 1
+		for end < len(pairs) && pairs[end].Time.Format("2006-01-02") == date {
+			end++
+		}
+
+		accuracy.Days = append(accuracy.Days, model.DailyAccuracy{Date: date, Statistics: summarizeAccuracy(pairs[i:end])})
+		i = end
+	}
+
+	return accuracy, nil
+}
+
+// PairCalibrations returns the calibrations, sorted by time, paired with the sensor value at their time. Sensor values
+// are interpolated between the reads around a calibration so calibrations without reads on both sides, no more than
+// GAP_THRESHOLD apart, are skipped. Reads must be sorted by time.
+func PairCalibrations(reads []apimodel.GlucoseRead, calibrations []apimodel.CalibrationRead) (pairs []model.CalibrationPair, err error) {
+	sortedCalibrations := make([]apimodel.CalibrationRead, len(calibrations))
+	copy(sortedCalibrations, calibrations)
+	sort.Sort(apimodel.CalibrationReadSlice(sortedCalibrations))
+
+	pairs = make([]model.CalibrationPair, 0)
+	for _, calibration := range sortedCalibrations {
+		meterValue, err := calibration.GetNormalizedValue(apimodel.MG_PER_DL)
+		if err != nil {
+			return nil, err
+		}
+
+		after := sort.Search(len(reads), func(i int) bool { return reads[i].Time.Timestamp >= calibration.Time.Timestamp })
+		if meterValue <= 0 || after == len(reads) {
+			continue
+		}
+
+		var sensorValue float32
+		if reads[after].Time.Timestamp == calibration.Time.Timestamp {
+			if sensorValue, err = reads[after].GetNormalizedValue(apimodel.MG_PER_DL); err != nil {
+				return nil, err
+			}
+		} else if after > 0 && reads[after].GetTime().Sub(reads[after-1].GetTime()) <= GAP_THRESHOLD {
+			sensorValue = apimodel.InterpolateGlucose(reads[after-1:after+1], calibration.Time, apimodel.MG_PER_DL)
+		} else {
+			continue
+		}
+
+		pairs = append(pairs, NewCalibrationPair(calibration.GetTime(), float64(meterValue), float64(sensorValue)))
+	}
+
+	return pairs, nil
+}
+
+// NewCalibrationPair returns the pair of a meter value and a sensor value, in mg/dL, with their differences and
+// error grid zones
+func NewCalibrationPair(pairTime time.Time, meterValue float64, sensorValue float64) (pair model.CalibrationPair) {
+	return model.CalibrationPair{
+		Time:               pairTime,
+		MeterValue:         meterValue,
+		SensorValue:        sensorValue,
+		Difference:         sensorValue - meterValue,
+		RelativeDifference: 100 * math.Abs(sensorValue-meterValue) / meterValue,
+		ClarkeZone:         ClarkeZone(meterValue, sensorValue),
+		ParkesZone:         ParkesZone(meterValue, sensorValue)}
+}
+
+// ClarkeZone returns the zone of the Clarke error grid of a sensor value given the meter value, both in mg/dL
+func ClarkeZone(meterValue float64, sensorValue float64) (zone string) {
+	switch {
+	case (meterValue <= 70 && sensorValue <= 70) || (sensorValue >= 0.8*meterValue && sensorValue <= 1.2*meterValue):
+		return model.ERROR_GRID_ZONE_A
+	case (meterValue >= 180 && sensorValue <= 70) || (meterValue <= 70 && sensorValue >= 180):
+		return model.ERROR_GRID_ZONE_E
+	case (meterValue >= 70 && meterValue <= 290 && sensorValue >= meterValue+110) ||
+		(meterValue >= 130 && meterValue <= 180 && sensorValue <= 7./5.*meterValue-182):
+		return model.ERROR_GRID_ZONE_C
+	case (meterValue >= 240 && sensorValue >= 70 && sensorValue <= 180) ||
+		(meterValue <= 175./3. && sensorValue >= 70 && sensorValue <= 180) ||
+		(meterValue >= 175./3. && meterValue <= 70 && sensorValue >= 6./5.*meterValue):
+		return model.ERROR_GRID_ZONE_D
+	default:
+		return model.ERROR_GRID_ZONE_B
+	}
+}
+
+// ParkesZone returns the zone of the Parkes error grid for type 1 diabetes of a sensor value given the meter value,
+// both in mg/dL
+func ParkesZone(meterValue float64, sensorValue float64) (zone string) {
+	for _, parkesZone := range parkesZones {
+		if sensorValue <= parkesZone.upper.upperAt(meterValue) && sensorValue >= parkesZone.lower.lowerAt(meterValue) {
+			return parkesZone.zone
+		}
+	}
+
+	return model.ERROR_GRID_ZONE_E
+}
+
+// upperAt returns the sensor value of an upper boundary at a meter value, any sensor value being under it past its
+// last point
+func (boundary parkesBoundary) upperAt(meterValue float64) (sensorValue float64) {
+	if len(boundary) == 0 || meterValue > boundary[len(boundary)-1][0] {
+		return math.Inf(1)
+	}
+
+	return boundary.interpolate(meterValue)
+}
+
+// lowerAt returns the sensor value of a lower boundary at a meter value, any sensor value being over it before its
+// first point. Past its last point, it stays at its last sensor value.
+func (boundary parkesBoundary) lowerAt(meterValue float64) (sensorValue float64) {
+	if len(boundary) == 0 || meterValue < boundary[0][0] {
+		return math.Inf(-1)
+	}
+
+	if last := boundary[len(boundary)-1]; meterValue > last[0] {
+		return last[1]
+	}
+
+	return boundary.interpolate(meterValue)
+}
+
+// interpolate returns the sensor value of the boundary at a meter value within its points, the highest one on a
+// vertical segment
+func (boundary parkesBoundary) interpolate(meterValue float64) (sensorValue float64) {
+	for i := 1; i < len(boundary); i++ {
+		start, end := boundary[i-1], boundary[i]
+		if meterValue <= end[0] {
+			if end[0] == start[0] {
+				return math.Max(start[1], end[1])
+			}
+			return start[1] + (end[1]-start[1])*(meterValue-start[0])/(end[0]-start[0])
+		}
+	}
+
+	return boundary[len(boundary)-1][1]
+}
+
+// summarizeAccuracy returns the statistics of calibration pairs
+func summarizeAccuracy(pairs []model.CalibrationPair) (statistics model.AccuracyStatistics) {
+	statistics = model.AccuracyStatistics{PairCount: len(pairs), ClarkeZones: make(map[string]int), ParkesZones: make(map[string]int)}
+	if len(pairs) == 0 {
+		return statistics
+	}
+
+	for _, pair := range pairs {
+		statistics.MARD += pair.RelativeDifference
+		statistics.Bias += pair.Difference
+		statistics.ClarkeZones[pair.ClarkeZone]++
+		statistics.ParkesZones[pair.ParkesZone]++
+	}
+
+	statistics.MARD = statistics.MARD / float64(len(pairs))
+	statistics.Bias = statistics.Bias / float64(len(pairs))
+	return statistics
+}
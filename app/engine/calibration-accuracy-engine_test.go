@@ -0,0 +1,136 @@
+package engine_test
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/model"
+	"math"
+	"testing"
+	"time"
+)
+
+var accuracyStart = time.Date(2014, 4, 1, 0, 0, 0, 0, time.UTC)
+
+// twoSessionReads returns reads of 100 mg/dL plus the minutes elapsed since accuracyStart, modulo 100, every 5 minutes
+// over the first day and, after a warm-up gap of 2 hours, over the next 22 hours
+func twoSessionReads() (reads []apimodel.GlucoseRead) {
+	for _, span := range [][]int{{0, 1440}, {1560, 2880}} {
+		reads = append(reads, readsBetween(accuracyStart.Add(time.Duration(span[0])*time.Minute), accuracyStart.Add(time.Duration(span[1])*time.Minute),
+			func(i int, readTime time.Time) float64 {
+				return float64(100 + int(readTime.Sub(accuracyStart).Minutes())%100)
+			})...)
+	}
+
+	return reads
+}
+
+func calibrationAt(minutes int, value float32) apimodel.CalibrationRead {
+	calibrationTime := accuracyStart.Add(time.Duration(minutes) * time.Minute)
+	return apimodel.CalibrationRead{apimodel.Time{apimodel.GetTimeMillis(calibrationTime), "UTC"}, apimodel.MG_PER_DL, value}
+}
+
+func TestPairCalibrations(t *testing.T) {
+	calibrations := []apimodel.CalibrationRead{
+		// Sensor at 112, interpolated between 110 and 115
+		calibrationAt(12, 125),
+		// Exactly on a read of 120
+		calibrationAt(20, 100),
+		// During the warm-up gap
+		calibrationAt(1500, 100),
+		// After the last read
+		calibrationAt(3000, 100)}
+
+	pairs, err := engine.PairCalibrations(twoSessionReads(), calibrations)
+	if err != nil {
+		t.Fatalf("Unexpected error pairing calibrations: %v", err)
+	}
+
+	if len(pairs) != 2 {
+		t.Fatalf("Expected [2] pairs but got [%v]", pairs)
+	}
+
+	if math.Abs(pairs[0].SensorValue-112) > 0.001 || math.Abs(pairs[0].RelativeDifference-10.4) > 0.001 || pairs[0].Difference >= 0 {
+		t.Errorf("Expected a sensor value of [112] reading [10.4%%] under the meter but got [%v]", pairs[0])
+	}
+
+	if pairs[1].SensorValue != 120 || pairs[1].Difference != 20 || pairs[1].ClarkeZone != model.ERROR_GRID_ZONE_A {
+		t.Errorf("Expected a sensor value of [120] reading [20] over the meter in zone A but got [%v]", pairs[1])
+	}
+}
+
+func TestAnalyzeCalibrationAccuracy(t *testing.T) {
+	calibrations := []apimodel.CalibrationRead{calibrationAt(20, 100), calibrationAt(650, 200), calibrationAt(1720, 100), calibrationAt(2440, 100)}
+
+	reads := twoSessionReads()
+	sessions := engine.InferSensorSessions(reads, calibrations)
+
+	accuracy, err := engine.AnalyzeCalibrationAccuracy(reads, calibrations, sessions, accuracyStart, accuracyStart.Add(48*time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error analyzing accuracy: %v", err)
+	}
+
+	// Sensor values of 120, 150, 120 and 140
+	if accuracy.Overall.PairCount != 4 || math.Abs(accuracy.Overall.MARD-26.25) > 0.001 || math.Abs(accuracy.Overall.Bias-7.5) > 0.001 {
+		t.Errorf("Expected [4] pairs with a MARD of [26.25%%] and a bias of [7.5] but got [%v]", accuracy.Overall)
+	}
+
+	if accuracy.Overall.ClarkeZones[model.ERROR_GRID_ZONE_A] != 2 || accuracy.Overall.ClarkeZones[model.ERROR_GRID_ZONE_B] != 2 {
+		t.Errorf("Expected [2] pairs in zone A and [2] in zone B but got [%v]", accuracy.Overall.ClarkeZones)
+	}
+
+	if len(accuracy.Sessions) != 2 || accuracy.Sessions[0].Statistics.PairCount != 2 || accuracy.Sessions[1].Statistics.PairCount != 2 {
+		t.Fatalf("Expected [2] sessions of [2] pairs but got [%v]", accuracy.Sessions)
+	}
+
+	if !accuracy.Sessions[1].Start.Equal(accuracyStart.Add(1440*time.Minute)) || math.Abs(accuracy.Sessions[1].Statistics.Bias-30) > 0.001 {
+		t.Errorf("Expected the second session to start on insertion, before the warm-up, with a bias of [30] but got [%v]", accuracy.Sessions[1])
+	}
+
+	if len(accuracy.Days) != 2 || accuracy.Days[0].Date != "2014-04-01" || accuracy.Days[1].Statistics.PairCount != 2 {
+		t.Errorf("Expected [2] days of [2] pairs but got [%v]", accuracy.Days)
+	}
+}
+
+func TestClarkeZone(t *testing.T) {
+	cases := []struct {
+		meter, sensor float64
+		zone          string
+	}{
+		{100, 110, model.ERROR_GRID_ZONE_A},
+		{60, 50, model.ERROR_GRID_ZONE_A},
+		{100, 140, model.ERROR_GRID_ZONE_B},
+		{100, 220, model.ERROR_GRID_ZONE_C},
+		{50, 100, model.ERROR_GRID_ZONE_D},
+		{300, 150, model.ERROR_GRID_ZONE_D},
+		{250, 60, model.ERROR_GRID_ZONE_E},
+		{60, 200, model.ERROR_GRID_ZONE_E},
+	}
+
+	for _, c := range cases {
+		if zone := engine.ClarkeZone(c.meter, c.sensor); zone != c.zone {
+			t.Errorf("Expected zone [%s] for a meter value of [%.0f] and a sensor value of [%.0f] but got [%s]", c.zone, c.meter, c.sensor, zone)
+		}
+	}
+}
+
+func TestParkesZone(t *testing.T) {
+	cases := []struct {
+		meter, sensor float64
+		zone          string
+	}{
+		{100, 110, model.ERROR_GRID_ZONE_A},
+		{40, 45, model.ERROR_GRID_ZONE_A},
+		{100, 150, model.ERROR_GRID_ZONE_B},
+		{200, 100, model.ERROR_GRID_ZONE_B},
+		{100, 250, model.ERROR_GRID_ZONE_C},
+		{300, 100, model.ERROR_GRID_ZONE_C},
+		{40, 160, model.ERROR_GRID_ZONE_D},
+		{30, 400, model.ERROR_GRID_ZONE_E},
+	}
+
+	for _, c := range cases {
+		if zone := engine.ParkesZone(c.meter, c.sensor); zone != c.zone {
+			t.Errorf("Expected zone [%s] for a meter value of [%.0f] and a sensor value of [%.0f] but got [%s]", c.zone, c.meter, c.sensor, zone)
+		}
+	}
+}
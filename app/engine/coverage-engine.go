@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/model"
+	"sort"
+	"time"
+)
+
+const (
+	// Interval between two reads of a CGM
+	READ_INTERVAL = 5 * time.Minute
+	// Reads further apart than this leave a gap between them
+	GAP_THRESHOLD = 15 * time.Minute
+	// Gaps from MIN_SENSOR_WARM_UP_GAP to MAX_SENSOR_WARM_UP_GAP are about as long as the warm-up of a new sensor,
+	// shorter ones are likely signal losses
+	MIN_SENSOR_WARM_UP_GAP = 105 * time.Minute
+	MAX_SENSOR_WARM_UP_GAP = 150 * time.Minute
+)
+
+// GapFilling is how gaps in reads are filled before calculating glukit scores and a1c estimates. Gaps of up to
+// MaxGap are filled with reads linearly interpolated every READ_INTERVAL. A MaxGap of 0 leaves gaps as they are.
+type GapFilling struct {
+	MaxGap time.Duration
+}
+
+var gapFilling GapFilling
+
+// SetGapFilling sets how gaps are filled for glukit scores and a1c estimates calculated from then on. Gaps aren't
+// filled by default.
+func SetGapFilling(filling GapFilling) {
+	gapFilling = filling
+}
+
+// AnalyzeCoverage returns the gaps in reads, sorted by time, between lowerBound and upperBound and the percentage of
+// that window they leave covered. Reads outside of the window are ignored.
+func AnalyzeCoverage(reads []apimodel.GlucoseRead, lowerBound time.Time, upperBound time.Time) (coverage model.DataCoverage) {
+	coverage = model.DataCoverage{LowerBound: lowerBound, UpperBound: upperBound, Gaps: make([]model.DataGap, 0)}
+	window := upperBound.Sub(lowerBound)
+	if window <= 0 {
+		return coverage
+	}
+
+	readTimes := make([]time.Time, 0, len(reads))
+	for _, read := range reads {
+		if readTime := read.GetTime(); !readTime.Before(lowerBound) && !readTime.After(upperBound) {
+			readTimes = append(readTimes, readTime)
+		}
+	}
+	sort.Slice(readTimes, func(i, j int) bool { return readTimes[i].Before(readTimes[j]) })
+
+	uncovered := time.Duration(0)
+	previous := lowerBound
+	for _, readTime := range append(readTimes, upperBound) {
+		if gap := readTime.Sub(previous); gap > GAP_THRESHOLD {
+			coverage.Gaps = append(coverage.Gaps, model.DataGap{Start: previous, End: readTime, Cause: gapCause(gap)})
+			uncovered += gap
+		}
+		previous = readTime
+	}
+
+	coverage.Percentage = 100 * float64(window-uncovered) / float64(window)
+	return coverage
+}
+
+// FillGaps returns the reads, sorted by time, with the gaps of up to maxGap filled with reads linearly interpolated
+// every READ_INTERVAL, along with the number of interpolated reads. Reads are returned as they are if maxGap is 0.
+func FillGaps(reads []apimodel.GlucoseRead, maxGap time.Duration) (filled []apimodel.GlucoseRead, interpolated int) {
+	if maxGap <= 0 || len(reads) < 2 {
+		return reads, 0
+	}
+
+	sortedReads := make([]apimodel.GlucoseRead, len(reads))
+	copy(sortedReads, reads)
+	sort.Sort(apimodel.GlucoseReadSlice(sortedReads))
+
+	filled = make([]apimodel.GlucoseRead, 0, len(sortedReads))
+	for i, read := range sortedReads {
+		if i > 0 {
+			previous := sortedReads[i-1]
+			if gap := read.GetTime().Sub(previous.GetTime()); gap > GAP_THRESHOLD && gap <= maxGap {
+				bounds := []apimodel.GlucoseRead{previous, read}
+				for readTime := previous.GetTime().Add(READ_INTERVAL); read.GetTime().Sub(readTime) >= READ_INTERVAL/2; readTime = readTime.Add(READ_INTERVAL) {
+					timeValue := apimodel.Time{Timestamp: apimodel.GetTimeMillis(readTime), TimeZoneId: previous.Time.TimeZoneId}
+					filled = append(filled, apimodel.GlucoseRead{Time: timeValue, Unit: previous.Unit, Value: apimodel.InterpolateGlucose(bounds, timeValue, previous.Unit)})
+					interpolated++
+				}
+			}
+		}
+		filled = append(filled, read)
+	}
+
+	return filled, interpolated
+}
+
+// gapCause returns the likely cause of a gap given its duration
+func gapCause(gap time.Duration) string {
+	switch {
+	case gap < MIN_SENSOR_WARM_UP_GAP:
+		return model.SIGNAL_LOSS_GAP
+	case gap <= MAX_SENSOR_WARM_UP_GAP:
+		return model.SENSOR_WARM_UP_GAP
+	default:
+		return model.NO_DATA_GAP
+	}
+}
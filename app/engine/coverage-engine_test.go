@@ -0,0 +1,88 @@
+package engine_test
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/model"
+	"math"
+	"testing"
+	"time"
+)
+
+var coverageStart = time.Date(2014, 4, 1, 0, 0, 0, 0, time.UTC)
+
+// gappyReads returns reads every 5 minutes, at 100 mg/dL plus the minutes elapsed since coverageStart, over the first
+// hour, from 1h30 to 4h and from 6h to 6h30. That leaves a 30 minutes gap, a 2 hours one and, in a 10 hours window, 3h30
+// without any data at the end.
+func gappyReads() (reads []apimodel.GlucoseRead) {
+	for _, span := range [][]int{{0, 60}, {90, 240}, {360, 390}} {
+		reads = append(reads, readsBetween(coverageStart.Add(time.Duration(span[0])*time.Minute), coverageStart.Add(time.Duration(span[1])*time.Minute),
+			func(i int, readTime time.Time) float64 {
+				return 100 + readTime.Sub(coverageStart).Minutes()
+			})...)
+	}
+
+	return reads
+}
+
+func TestAnalyzeCoverage(t *testing.T) {
+	coverage := engine.AnalyzeCoverage(gappyReads(), coverageStart, coverageStart.Add(10*time.Hour))
+
+	expected := []model.DataGap{
+		{coverageStart.Add(60 * time.Minute), coverageStart.Add(90 * time.Minute), model.SIGNAL_LOSS_GAP},
+		{coverageStart.Add(4 * time.Hour), coverageStart.Add(6 * time.Hour), model.SENSOR_WARM_UP_GAP},
+		{coverageStart.Add(390 * time.Minute), coverageStart.Add(10 * time.Hour), model.NO_DATA_GAP}}
+	if len(coverage.Gaps) != len(expected) {
+		t.Fatalf("Expected [%d] gaps but got [%v]", len(expected), coverage.Gaps)
+	}
+
+	for i, gap := range coverage.Gaps {
+		if !gap.Start.Equal(expected[i].Start) || !gap.End.Equal(expected[i].End) || gap.Cause != expected[i].Cause {
+			t.Errorf("Expected gap [%v] but got [%v]", expected[i], gap)
+		}
+	}
+
+	// 6 of the 10 hours are gaps
+	if math.Abs(coverage.Percentage-40) > 0.001 {
+		t.Errorf("Expected a coverage of [40%%] but got [%f%%]", coverage.Percentage)
+	}
+}
+
+func TestAnalyzeCoverageWithoutReads(t *testing.T) {
+	coverage := engine.AnalyzeCoverage([]apimodel.GlucoseRead{}, coverageStart, coverageStart.Add(24*time.Hour))
+
+	if coverage.Percentage != 0 || len(coverage.Gaps) != 1 || coverage.Gaps[0].Cause != model.NO_DATA_GAP {
+		t.Errorf("Expected a single gap without data over the whole window but got [%v]", coverage)
+	}
+}
+
+func TestFillGaps(t *testing.T) {
+	reads := gappyReads()
+
+	filled, interpolated := engine.FillGaps(reads, time.Hour)
+
+	// Only the 30 minutes gap is short enough to be filled
+	if interpolated != 5 || len(filled) != len(reads)+5 {
+		t.Fatalf("Expected [5] interpolated reads but got [%d] and [%d] reads", interpolated, len(filled))
+	}
+
+	for i, read := range filled[:len(filled)-1] {
+		if gap := filled[i+1].GetTime().Sub(read.GetTime()); gap <= 15*time.Minute && gap != 5*time.Minute {
+			t.Errorf("Expected reads every [5m] but got [%v] between [%v] and [%v]", gap, read, filled[i+1])
+		}
+	}
+
+	if read := filled[15]; !read.GetTime().Equal(coverageStart.Add(75*time.Minute)) || read.Value != 175 {
+		t.Errorf("Expected an interpolated read of [175] at [1h15] but got [%v]", read)
+	}
+}
+
+func TestFillGapsDisabled(t *testing.T) {
+	reads := gappyReads()
+
+	filled, interpolated := engine.FillGaps(reads, 0)
+
+	if interpolated != 0 || len(filled) != len(reads) {
+		t.Errorf("Expected reads to be left as they are but got [%d] interpolated reads", interpolated)
+	}
+}
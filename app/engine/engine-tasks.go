@@ -3,22 +3,54 @@ package engine
 import (
 	"github.com/alexandre-normand/glukit/app/model"
 	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/tasks"
 	"github.com/alexandre-normand/glukit/app/util"
 	"context"
-	"google.golang.org/appengine/delay"
-	"google.golang.org/appengine/log"
-	"google.golang.org/appengine/taskqueue"
+	"github.com/alexandre-normand/glukit/app/log"
 	"time"
 )
 
-var RunGlukitScoreCalculationChunk = delay.Func(GLUKIT_SCORE_BATCH_CALCULATION_FUNCTION_NAME, func(context context.Context, userEmail string,
+var RunGlukitScoreCalculationChunk = tasks.Func(GLUKIT_SCORE_BATCH_CALCULATION_FUNCTION_NAME, func(context context.Context, userEmail string,
 	lowerBound time.Time) {
 	log.Criticalf(context, "This function purely exists as a workaround to the \"initialization loop\" error that "+
 		"shows up because the function calls itself. This implementation defines the same signature as the "+
 		"real one which we define in init() to override this implementation!")
 })
 
-var RunA1CCalculationChunk = delay.Func(A1C_BATCH_CALCULATION_FUNCTION_NAME, func(context context.Context, userEmail string,
+var RunA1CCalculationChunk = tasks.Func(A1C_BATCH_CALCULATION_FUNCTION_NAME, func(context context.Context, userEmail string,
+	lowerBound time.Time) {
+	log.Criticalf(context, "This function purely exists as a workaround to the \"initialization loop\" error that "+
+		"shows up because the function calls itself. This implementation defines the same signature as the "+
+		"real one which we define in init() to override this implementation!")
+})
+
+var RunGlukitScoreMigrationChunk = tasks.Func(GLUKIT_SCORE_MIGRATION_FUNCTION_NAME, func(context context.Context, userEmail string,
+	after time.Time) {
+	log.Criticalf(context, "This function purely exists as a workaround to the \"initialization loop\" error that "+
+		"shows up because the function calls itself. This implementation defines the same signature as the "+
+		"real one which we define in init() to override this implementation!")
+})
+
+var RunUsersGlukitScoreMigrationChunk = tasks.Func(USERS_GLUKIT_SCORE_MIGRATION_FUNCTION_NAME, func(context context.Context, afterEmail string) {
+	log.Criticalf(context, "This function purely exists as a workaround to the \"initialization loop\" error that "+
+		"shows up because the function calls itself. This implementation defines the same signature as the "+
+		"real one which we define in init() to override this implementation!")
+})
+
+var RunMealImpactCalculationChunk = tasks.Func(MEAL_IMPACT_CALCULATION_FUNCTION_NAME, func(context context.Context, userEmail string,
+	lowerBound time.Time) {
+	log.Criticalf(context, "This function purely exists as a workaround to the \"initialization loop\" error that "+
+		"shows up because the function calls itself. This implementation defines the same signature as the "+
+		"real one which we define in init() to override this implementation!")
+})
+
+var RunUsersPatternDetectionChunk = tasks.Func(USERS_PATTERN_DETECTION_FUNCTION_NAME, func(context context.Context, afterEmail string) {
+	log.Criticalf(context, "This function purely exists as a workaround to the \"initialization loop\" error that "+
+		"shows up because the function calls itself. This implementation defines the same signature as the "+
+		"real one which we define in init() to override this implementation!")
+})
+
+var RunSensorSessionInferenceChunk = tasks.Func(SENSOR_SESSION_INFERENCE_FUNCTION_NAME, func(context context.Context, userEmail string,
 	lowerBound time.Time) {
 	log.Criticalf(context, "This function purely exists as a workaround to the \"initialization loop\" error that "+
 		"shows up because the function calls itself. This implementation defines the same signature as the "+
@@ -30,16 +62,66 @@ const (
 	BATCH_CALCULATION_QUEUE_NAME                 = "batch-calculation"
 	GLUKIT_SCORE_BATCH_CALCULATION_FUNCTION_NAME = "runGlukitScoreCalculationChunk"
 	A1C_BATCH_CALCULATION_FUNCTION_NAME          = "runA1CCalculationChunk"
+	GLUKIT_SCORE_MIGRATION_FUNCTION_NAME         = "runGlukitScoreMigrationChunk"
+	USERS_GLUKIT_SCORE_MIGRATION_FUNCTION_NAME   = "runUsersGlukitScoreMigrationChunk"
+	MEAL_IMPACT_CALCULATION_FUNCTION_NAME        = "runMealImpactCalculationChunk"
+	SENSOR_SESSION_INFERENCE_FUNCTION_NAME       = "runSensorSessionInferenceChunk"
+	// Each recalculated score needs a period of reads so we keep batches of them small
+	SCORES_PER_MIGRATION_BATCH = 14
+	USERS_PER_MIGRATION_BATCH  = 100
 )
 
+// StartUserCalculations starts the calculations that follow new data of a user: glukit scores, a1c estimates, therapy
+// estimates, meal impacts and sensor sessions. Errors are only logged since the new data is stored regardless.
+func StartUserCalculations(context context.Context, glukitUser *model.GlukitUser) {
+	if err := StartGlukitScoreBatch(context, glukitUser); err != nil {
+		log.Warningf(context, "Error starting glukit score calculation batch for user [%s]: %v", glukitUser.Email, err)
+	}
+
+	if err := StartA1CCalculationBatch(context, glukitUser); err != nil {
+		log.Warningf(context, "Error starting a1c calculation batch for user [%s]: %v", glukitUser.Email, err)
+	}
+
+	if err := StartTherapyEstimation(context, glukitUser); err != nil {
+		log.Warningf(context, "Error starting therapy estimation for user [%s]: %v", glukitUser.Email, err)
+	}
+
+	if err := StartMealImpactCalculation(context, glukitUser); err != nil {
+		log.Warningf(context, "Error starting meal impact calculation for user [%s]: %v", glukitUser.Email, err)
+	}
+
+	if err := StartSensorSessionInference(context, glukitUser); err != nil {
+		log.Warningf(context, "Error starting sensor session inference for user [%s]: %v", glukitUser.Email, err)
+	}
+}
+
+// skipAccountPendingDeletion returns true, after logging why, if a task for a user shouldn't run because the user's
+// account is being deleted or because that can't be checked
+func skipAccountPendingDeletion(context context.Context, userEmail string, task string) (skip bool) {
+	pending, err := store.IsAccountPendingDeletion(context, userEmail)
+	if err != nil {
+		log.Errorf(context, "Error checking if account of user [%s] is being deleted: %v", userEmail, err)
+		return true
+	} else if pending {
+		log.Infof(context, "Skipping %s for user [%s] whose account is being deleted", task, userEmail)
+		return true
+	}
+
+	return false
+}
+
 func RunGlukitScoreBatchCalculation(context context.Context, userEmail string, lowerBound time.Time) {
-	glukitUser, _, _, err := store.GetUserData(context, userEmail)
+	glukitUser, _, err := store.GetUserData(context, userEmail)
 	if _, ok := err.(store.StoreError); err != nil && !ok {
 		log.Errorf(context, "We're trying to run a batch glukit score calculation for user [%s] that doesn't exist. "+
 			"Got error: %v", userEmail, err)
 		return
 	}
 
+	if skipAccountPendingDeletion(context, userEmail, "batch glukit score calculation") {
+		return
+	}
+
 	bestScore := glukitUser.BestScore
 	mostRecentScore := glukitUser.MostRecentScore
 	glukitScoreBatch := make([]model.GlukitScore, 0)
@@ -78,7 +160,7 @@ func RunGlukitScoreBatchCalculation(context context.Context, userEmail string, l
 	if bestScore != glukitUser.BestScore || mostRecentScore != glukitUser.MostRecentScore {
 		glukitUser.BestScore = bestScore
 		glukitUser.MostRecentScore = mostRecentScore
-		if _, err := store.StoreUserProfile(context, time.Now(), *glukitUser); err != nil {
+		if err := store.StoreUserProfile(context, time.Now(), *glukitUser); err != nil {
 			util.Propagate(err)
 		} else {
 			log.Debugf(context, "Updated glukit user [%s] with an improved GlukitScore of [%v] and most recent score of [%v]",
@@ -88,12 +170,10 @@ func RunGlukitScoreBatchCalculation(context context.Context, userEmail string, l
 
 	// Kick off the next chunk of glukit score calculation
 	if !periodUpperBound.Before(upperBound) {
-		task, err := RunGlukitScoreCalculationChunk.Task(userEmail, periodUpperBound)
-		if err != nil {
+		if err := RunGlukitScoreCalculationChunk.Add(context, BATCH_CALCULATION_QUEUE_NAME, userEmail, periodUpperBound); err != nil {
 			log.Criticalf(context, "Couldn't schedule the next execution of [%s] for user [%s]. "+
 				"This breaks batch calculation of glukit scores for that user!: %v", GLUKIT_SCORE_BATCH_CALCULATION_FUNCTION_NAME, userEmail, err)
 		}
-		taskqueue.Add(context, task, BATCH_CALCULATION_QUEUE_NAME)
 
 		log.Infof(context, "Queued up next chunk of glukit score calculation for user [%s] and lowerBound [%s]", userEmail, periodUpperBound.Format(util.TIMEFORMAT))
 	} else {
@@ -102,13 +182,17 @@ func RunGlukitScoreBatchCalculation(context context.Context, userEmail string, l
 }
 
 func RunA1CBatchCalculation(context context.Context, userEmail string, lowerBound time.Time) {
-	glukitUser, _, _, err := store.GetUserData(context, userEmail)
+	glukitUser, _, err := store.GetUserData(context, userEmail)
 	if _, ok := err.(store.StoreError); err != nil && !ok {
 		log.Errorf(context, "We're trying to run a batch of a1c estimates for user [%s] that doesn't exist. "+
 			"Got error: %v", userEmail, err)
 		return
 	}
 
+	if skipAccountPendingDeletion(context, userEmail, "batch of a1c estimates") {
+		return
+	}
+
 	mostRecentA1C := glukitUser.MostRecentA1C
 	a1cBatch := make([]model.A1CEstimate, 0)
 	var periodUpperBound time.Time
@@ -137,7 +221,7 @@ func RunA1CBatchCalculation(context context.Context, userEmail string, lowerBoun
 	if mostRecentA1C != glukitUser.MostRecentA1C {
 		glukitUser.MostRecentA1C = mostRecentA1C
 
-		if _, err := store.StoreUserProfile(context, time.Now(), *glukitUser); err != nil {
+		if err := store.StoreUserProfile(context, time.Now(), *glukitUser); err != nil {
 			util.Propagate(err)
 		} else {
 			log.Debugf(context, "Updated glukit user [%s] with a most recent a1c [%v]",
@@ -147,15 +231,102 @@ func RunA1CBatchCalculation(context context.Context, userEmail string, lowerBoun
 
 	// Kick off the next chunk of glukit score calculation
 	if !periodUpperBound.Before(upperBound) {
-		task, err := RunA1CCalculationChunk.Task(userEmail, periodUpperBound)
-		if err != nil {
+		if err := RunA1CCalculationChunk.Add(context, BATCH_CALCULATION_QUEUE_NAME, userEmail, periodUpperBound); err != nil {
 			log.Criticalf(context, "Couldn't schedule the next execution of [%s] for user [%s]. "+
 				"This breaks batch calculation of glukit scores for that user!: %v", A1C_BATCH_CALCULATION_FUNCTION_NAME, userEmail, err)
 		}
-		taskqueue.Add(context, task, BATCH_CALCULATION_QUEUE_NAME)
 
 		log.Infof(context, "Queued up next chunk of a1c calculation for user [%s] and lowerBound [%s]", userEmail, periodUpperBound.Format(util.TIMEFORMAT))
 	} else {
 		log.Infof(context, "Done with a1c estimation for user [%s]", userEmail)
 	}
 }
+
+// RunUsersGlukitScoreMigration queues the migration of the glukit scores of a batch of users, those following
+// afterEmail, and schedules the next batch until all users have been covered
+func RunUsersGlukitScoreMigration(context context.Context, afterEmail string) {
+	emails, err := store.ScanUserEmails(context, afterEmail, USERS_PER_MIGRATION_BATCH)
+	if err != nil {
+		log.Errorf(context, "Error scanning users after [%s] for glukit score migration: %v", afterEmail, err)
+		return
+	}
+
+	for _, email := range emails {
+		if err := RunGlukitScoreMigrationChunk.Add(context, BATCH_CALCULATION_QUEUE_NAME, email, util.GLUKIT_EPOCH_TIME); err != nil {
+			log.Criticalf(context, "Couldn't schedule the migration of glukit scores for user [%s]: %v", email, err)
+		}
+	}
+
+	if len(emails) == USERS_PER_MIGRATION_BATCH {
+		lastEmail := emails[len(emails)-1]
+		if err := RunUsersGlukitScoreMigrationChunk.Add(context, BATCH_CALCULATION_QUEUE_NAME, lastEmail); err != nil {
+			log.Criticalf(context, "Couldn't schedule the next execution of [%s] after user [%s]. "+
+				"This breaks the migration of glukit scores for the remaining users!: %v", USERS_GLUKIT_SCORE_MIGRATION_FUNCTION_NAME, lastEmail, err)
+		}
+
+		log.Infof(context, "Queued up next chunk of users for glukit score migration after user [%s]", lastEmail)
+	} else {
+		log.Infof(context, "Done queuing glukit score migration of all users")
+	}
+}
+
+// RunGlukitScoreMigration recalculates a batch of the scores of a user, with an upper bound after the given time, that
+// were calculated with an older scoring version than the current one and schedules the next batch after the last of
+// them. Scores that can't be recalculated are left as they are. Once all scores are migrated, the user's best and most
+// recent scores are refreshed since the migrated values might have changed them.
+func RunGlukitScoreMigration(context context.Context, userEmail string, after time.Time) {
+	glukitUser, _, err := store.GetUserData(context, userEmail)
+	if _, ok := err.(store.StoreError); err != nil && !ok {
+		log.Errorf(context, "We're trying to migrate glukit scores for user [%s] that doesn't exist. "+
+			"Got error: %v", userEmail, err)
+		return
+	}
+
+	if skipAccountPendingDeletion(context, userEmail, "glukit score migration") {
+		return
+	}
+
+	strategy := CurrentScoringStrategy()
+	outdatedScores, err := store.FindOutdatedGlukitScores(context, userEmail, strategy.Version(), after, SCORES_PER_MIGRATION_BATCH)
+	if err != nil {
+		log.Errorf(context, "Error getting outdated glukit scores for user [%s]: %v", userEmail, err)
+		return
+	}
+
+	migratedScores := make([]model.GlukitScore, 0)
+	for _, outdatedScore := range outdatedScores {
+		glukitScore, err := CalculateGlukitScoreWithStrategy(context, glukitUser, outdatedScore.UpperBound, strategy)
+		if err != nil {
+			log.Warningf(context, "Error recalculating glukit score for user [%s] with upper bound [%s]: %v", userEmail, outdatedScore.UpperBound, err)
+		} else if glukitScore.Value == model.UNDEFINED_SCORE_VALUE {
+			log.Warningf(context, "Not enough reads anymore to recalculate glukit score for user [%s] with upper bound [%s]", userEmail, outdatedScore.UpperBound)
+		} else {
+			migratedScores = append(migratedScores, *glukitScore)
+		}
+	}
+
+	if err := store.StoreGlukitScoreBatch(context, userEmail, migratedScores); err != nil {
+		log.Errorf(context, "Error storing migrated glukit scores for user [%s]: %v", userEmail, err)
+		return
+	}
+
+	// Scores that can't be recalculated stay outdated so the next batch starts after this one rather than at the
+	// oldest outdated score
+	if len(outdatedScores) == SCORES_PER_MIGRATION_BATCH {
+		lastUpperBound := outdatedScores[len(outdatedScores)-1].UpperBound
+		if err := RunGlukitScoreMigrationChunk.Add(context, BATCH_CALCULATION_QUEUE_NAME, userEmail, lastUpperBound); err != nil {
+			log.Criticalf(context, "Couldn't schedule the next execution of [%s] for user [%s]. "+
+				"This breaks the migration of glukit scores for that user!: %v", GLUKIT_SCORE_MIGRATION_FUNCTION_NAME, userEmail, err)
+		}
+
+		log.Infof(context, "Queued up next chunk of glukit score migration for user [%s] after [%s]", userEmail, lastUpperBound)
+		return
+	}
+
+	if err := RefreshUserGlukitScores(context, glukitUser); err != nil {
+		log.Errorf(context, "Error refreshing best and most recent glukit scores of user [%s]: %v", userEmail, err)
+		return
+	}
+
+	log.Infof(context, "Done with glukit score migration for user [%s]", userEmail)
+}
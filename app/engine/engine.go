@@ -8,23 +8,22 @@ import (
 	"github.com/alexandre-normand/glukit/app/store"
 	"github.com/alexandre-normand/glukit/app/util"
 	"context"
-	"google.golang.org/appengine/log"
-	"google.golang.org/appengine/taskqueue"
+	"github.com/alexandre-normand/glukit/app/log"
 	"math"
 	"time"
 )
 
 const (
-	// The multiplier applied to any deviation from the target, on the low spectrum (i.e. anything less than 83)
+	// The multiplier applied to any deviation from the target, on the low spectrum (i.e. anything less than the target)
 	LOW_MULTIPLIER = 1
-	// The multiplier applied to any deviation from the target, on the high spectrum (i.e. anything above 83)
+	// The multiplier applied to any deviation from the target, on the high spectrum (i.e. anything above the target)
 	HIGH_MULTIPLIER = 2
 	// Glukit score calculation period
 	GLUKIT_SCORE_PERIOD = 7
 	// One period of reads minus on day for potential data gaps
 	READS_REQUIREMENT = 288 * (GLUKIT_SCORE_PERIOD - 1)
-	// The current Glukit scoring version
-	SCORING_VERSION = 1
+	// The current Glukit scoring version, the version of the ScoringStrategy used to calculate new scores
+	SCORING_VERSION = 2
 	// The max number of days to look back when starting a new batch of calculation
 	MAX_CALCULATION_DAYS_TO_LOOK_BACK = 30
 )
@@ -32,31 +31,53 @@ const (
 // January 1st, 2014
 var A1C_CALCULATION_START = time.Unix(1388534400, 0)
 
-// CalculateGlukitScore computes the GlukitScore for a given user. This is done in a few steps:
-//   1. Get the latest GLUKIT_SCORE_PERIOD days of reads
+// CalculateGlukitScore computes the GlukitScore for a given user with the current scoring strategy
+func CalculateGlukitScore(context context.Context, glukitUser *model.GlukitUser, endOfPeriod time.Time) (glukitScore *model.GlukitScore, err error) {
+	return CalculateGlukitScoreWithStrategy(context, glukitUser, endOfPeriod, CurrentScoringStrategy())
+}
+
+// CalculateGlukitScoreWithStrategy computes the GlukitScore for a given user. This is done in a few steps:
+//   1. Get the latest GLUKIT_SCORE_PERIOD days of reads, analyze their coverage, fill short gaps if gap filling
+//      is enabled and leave out the reads of sensors warming up
 //   2. For the most recent reads up to READS_REQUIREMENT, calculate the individual score
-//      contribution and add it to the GlukitScore.
+//      contribution, as weighted by the scoring strategy against the user's targets, and add it to the GlukitScore.
 //   3. If we had enough reads to satisfy the requirements, we return the sum of
 //      all individual score contributions.
-func CalculateGlukitScore(context context.Context, glukitUser *model.GlukitUser, endOfPeriod time.Time) (glukitScore *model.GlukitScore, err error) {
+func CalculateGlukitScoreWithStrategy(context context.Context, glukitUser *model.GlukitUser, endOfPeriod time.Time, strategy ScoringStrategy) (glukitScore *model.GlukitScore, err error) {
 	// Get the last period's worth of reads
 	upperBound := util.GetMidnightUTCBefore(endOfPeriod)
 	lowerBound := upperBound.AddDate(0, 0, -1*GLUKIT_SCORE_PERIOD)
 	score := model.UNDEFINED_SCORE_VALUE
+	var coverage model.DataCoverage
+	interpolatedReads := 0
 
 	log.Debugf(context, "Getting reads for glukit score calculation from [%s] to [%s]", lowerBound, upperBound)
 	if reads, err := store.GetGlucoseReads(context, glukitUser.Email, lowerBound, upperBound); err != nil {
 		return &model.UNDEFINED_SCORE, err
 	} else {
-		// We might want to do some interpolation of missing reads at some point but for now, we'll only use
-		// actual values. Since we know we'll have gaps in a 2 weeks window because of sensor warm-ups, let's
-		// just normalize by stopping after the equivalent of full 14 days of reads (assuming most people won't have
-		// more than 2 days worth of missing data)
+		// Short gaps are only interpolated if gap filling is enabled. Since we know we'll have gaps in a 2 weeks
+		// window because of sensor warm-ups, let's just normalize by stopping after the equivalent of full 14 days
+		// of reads (assuming most people won't have more than 2 days worth of missing data)
+		coverage = AnalyzeCoverage(reads, lowerBound, upperBound)
+		reads, interpolatedReads = FillGaps(reads, gapFilling.MaxGap)
+
+		sessions, err := GetSensorSessions(context, glukitUser.Email, lowerBound, upperBound)
+		if err != nil {
+			return &model.UNDEFINED_SCORE, err
+		}
+		reads = ExcludeWarmUps(reads, sessions)
+
 		readCount := 0
 		score = 0
+		targets := glukitUser.GetGlucoseTargets()
 
 		for i := 0; i < len(reads) && i < READS_REQUIREMENT; i++ {
-			score = score + int64(CalculateIndividualReadScoreWeight(context, reads[i]))
+			weight, err := strategy.ReadWeight(reads[i], targets.RangeAt(reads[i].GetTime()))
+			if err != nil {
+				return &model.UNDEFINED_SCORE, err
+			}
+
+			score = score + int64(weight)
 			readCount = readCount + 1
 		}
 
@@ -71,31 +92,25 @@ func CalculateGlukitScore(context context.Context, glukitUser *model.GlukitUser,
 		glukitScore = &model.UNDEFINED_SCORE
 	} else {
 		glukitScore = &model.GlukitScore{
-			Value:          score,
-			LowerBound:     lowerBound,
-			UpperBound:     upperBound,
-			CalculatedOn:   time.Now(),
-			ScoringVersion: SCORING_VERSION}
+			Value:             score,
+			LowerBound:        lowerBound,
+			UpperBound:        upperBound,
+			CalculatedOn:      time.Now(),
+			ScoringVersion:    strategy.Version(),
+			Coverage:          coverage.Percentage,
+			InterpolatedReads: interpolatedReads}
 	}
 
 	return glukitScore, nil
 }
 
-// An individual score is either 0 if it's straight on perfection (83) or it's the deviation from 83 weighted
-// by whether it's high (multiplier of 2) or lower (multiplier of 1)
+// CalculateIndividualReadScoreWeight returns the weight of a read as calculated by the current scoring strategy
+// against the default targets
 func CalculateIndividualReadScoreWeight(context context.Context, read apimodel.GlucoseRead) (weightedScoreContribution float64) {
-	weightedScoreContribution = 0.
-	convertedValue, err := read.GetNormalizedValue(apimodel.MG_PER_DL)
+	weightedScoreContribution, err := CurrentScoringStrategy().ReadWeight(read, model.DEFAULT_GLUCOSE_TARGETS.RangeAt(read.GetTime()))
 	if err != nil {
 		util.Propagate(err)
 	}
-	value := float64(convertedValue)
-
-	if value > model.TARGET_GLUCOSE_VALUE {
-		weightedScoreContribution = (value - model.TARGET_GLUCOSE_VALUE) * HIGH_MULTIPLIER
-	} else if value < model.TARGET_GLUCOSE_VALUE {
-		weightedScoreContribution = -(value - model.TARGET_GLUCOSE_VALUE) * LOW_MULTIPLIER
-	}
 
 	return weightedScoreContribution
 }
@@ -128,17 +143,57 @@ func StartGlukitScoreBatch(context context.Context, glukitUser *model.GlukitUser
 	}
 
 	// Kick off the first chunk of glukit score calculation
-	task, err := RunGlukitScoreCalculationChunk.Task(glukitUser.Email, lowerBound)
-	if err != nil {
+	if err := RunGlukitScoreCalculationChunk.Add(context, BATCH_CALCULATION_QUEUE_NAME, glukitUser.Email, lowerBound); err != nil {
 		log.Criticalf(context, "Couldn't schedule the next execution of [%s] for user [%s]. "+
 			"This breaks batch calculation of glukit scores for that user!: %v", GLUKIT_SCORE_BATCH_CALCULATION_FUNCTION_NAME, glukitUser.Email, err)
 	}
-	taskqueue.Add(context, task, BATCH_CALCULATION_QUEUE_NAME)
 	log.Infof(context, "Queued up first chunk of glukit score calculation for user [%s] and lowerBound [%s]", glukitUser.Email, lowerBound.Format(util.TIMEFORMAT))
 
 	return nil
 }
 
+// StartGlukitScoreMigration kicks off the recalculation of all glukit scores calculated with a scoring version older
+// than SCORING_VERSION, for all users
+func StartGlukitScoreMigration(context context.Context) (err error) {
+	if err = RunUsersGlukitScoreMigrationChunk.Add(context, BATCH_CALCULATION_QUEUE_NAME, ""); err != nil {
+		return err
+	}
+
+	log.Infof(context, "Queued up glukit score migration to scoring version [%d]", SCORING_VERSION)
+	return nil
+}
+
+// RefreshUserGlukitScores sets the best and most recent scores of the user from all of its stored scores
+func RefreshUserGlukitScores(context context.Context, glukitUser *model.GlukitUser) (err error) {
+	scores, err := store.GetGlukitScores(context, glukitUser.Email, store.ScoreScanQuery{})
+	if err != nil {
+		return err
+	}
+
+	bestScore := model.UNDEFINED_SCORE
+	mostRecentScore := model.UNDEFINED_SCORE
+	for _, score := range scores {
+		if score.IsBetterThan(bestScore) {
+			bestScore = score
+		}
+
+		if score.UpperBound.After(mostRecentScore.UpperBound) {
+			mostRecentScore = score
+		}
+	}
+
+	if bestScore == glukitUser.BestScore && mostRecentScore == glukitUser.MostRecentScore {
+		return nil
+	}
+
+	glukitUser.BestScore = bestScore
+	glukitUser.MostRecentScore = mostRecentScore
+	log.Debugf(context, "Refreshing glukit user [%s] with best GlukitScore of [%v] and most recent score of [%v]",
+		glukitUser.Email, bestScore, mostRecentScore)
+
+	return store.StoreUserProfile(context, time.Now(), *glukitUser)
+}
+
 // StartA1CCalculationBatch tries to calculate a1c estimates for any week following the most recent calculated glukit score (a hack, we should have the most recent
 // a1c calculation date)
 func StartA1CCalculationBatch(context context.Context, glukitUser *model.GlukitUser) (err error) {
@@ -161,12 +216,10 @@ func StartA1CCalculationBatch(context context.Context, glukitUser *model.GlukitU
 	}
 
 	// Kick off the first chunk of glukit score calculation
-	task, err := RunA1CCalculationChunk.Task(glukitUser.Email, lowerBound)
-	if err != nil {
+	if err := RunA1CCalculationChunk.Add(context, BATCH_CALCULATION_QUEUE_NAME, glukitUser.Email, lowerBound); err != nil {
 		log.Criticalf(context, "Couldn't schedule the next execution of [%s] for user [%s]. "+
 			"This breaks batch calculation of a1c estimates scores for that user!: %v", A1C_BATCH_CALCULATION_FUNCTION_NAME, glukitUser.Email, err)
 	}
-	taskqueue.Add(context, task, BATCH_CALCULATION_QUEUE_NAME)
 	log.Infof(context, "Queued up first chunk of a1c calculation for user [%s] and lowerBound [%s]", glukitUser.Email, lowerBound.Format(util.TIMEFORMAT))
 
 	return nil
@@ -0,0 +1,250 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/util"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	FORECAST_TAG      = "Forecast"
+	FORECAST_LOW_TAG  = "ForecastLow"
+	FORECAST_HIGH_TAG = "ForecastHigh"
+
+	// Interval between two forecasted points
+	FORECAST_STEP = 5 * time.Minute
+	// Default and max horizon of a forecast
+	DEFAULT_FORECAST_HORIZON = 30 * time.Minute
+	MAX_FORECAST_HORIZON     = 60 * time.Minute
+	// How far back reads are used to forecast and how many of them are required
+	FORECAST_HISTORY   = time.Hour
+	MIN_FORECAST_READS = 3
+	// Bounds of forecasted values, in mg/dL, those of the range of a CGM
+	MIN_FORECAST_GLUCOSE = 40.
+	MAX_FORECAST_GLUCOSE = 400.
+	// Number of standard deviations on each side of a forecasted value bounding its 95% prediction interval
+	FORECAST_INTERVAL_Z = 1.96
+
+	KALMAN_FORECASTER = "kalman"
+	// Forecaster used when none is requested
+	DEFAULT_FORECASTER = KALMAN_FORECASTER
+)
+
+// ForecastInput is what forecasts are made from: reads, in ascending order of time, over FORECAST_HISTORY and the
+// injections and meals that can still be on board according to OnBoardModel. Forecasters are free to ignore any of it.
+type ForecastInput struct {
+	Reads        []apimodel.GlucoseRead
+	Injections   []apimodel.Injection
+	Meals        []apimodel.Meal
+	OnBoardModel OnBoardModel
+}
+
+// Forecaster is a model forecasting glucose values following the most recent read. Statistical models only look at
+// reads while physiological ones can account for the insulin and carbs on board.
+type Forecaster interface {
+	// Name returns the name the forecaster is registered and requested by, unique amongst registered forecasters
+	Name() string
+	// Forecast returns a point every FORECAST_STEP after the most recent read up to the horizon
+	Forecast(input ForecastInput, horizon time.Duration) (points []model.ForecastPoint, err error)
+}
+
+// KalmanForecaster filters reads with a local linear trend model, glucose drifting at a rate that changes randomly,
+// and extrapolates the filtered glucose and rate. MeasurementNoise is the standard deviation, in mg/dL, of the noise
+// of a CGM read and RateNoise the variance, in (mg/dL/min)² per minute, of the changes of the rate.
+type KalmanForecaster struct {
+	MeasurementNoise float64
+	RateNoise        float64
+}
+
+var ErrUnknownForecaster = errors.New("Unknown forecaster")
+var ErrInsufficientReadsForForecast = errors.New("Insufficient reads to forecast glucose")
+
+var forecasters = make(map[string]Forecaster)
+var forecastersLock sync.RWMutex
+
+func init() {
+	RegisterForecaster(KalmanForecaster{MeasurementNoise: 6, RateNoise: 0.02})
+}
+
+// RegisterForecaster makes a forecaster available under its name. Registering two forecasters with the same name is
+// a programming error so it panics.
+func RegisterForecaster(forecaster Forecaster) {
+	forecastersLock.Lock()
+	defer forecastersLock.Unlock()
+
+	if _, exists := forecasters[forecaster.Name()]; exists {
+		panic(fmt.Sprintf("A forecaster is already registered with name [%s]", forecaster.Name()))
+	}
+
+	forecasters[forecaster.Name()] = forecaster
+}
+
+// GetForecaster returns the forecaster registered with the given name or ErrUnknownForecaster
+func GetForecaster(name string) (forecaster Forecaster, err error) {
+	forecastersLock.RLock()
+	defer forecastersLock.RUnlock()
+
+	forecaster, exists := forecasters[name]
+	if !exists {
+		return nil, ErrUnknownForecaster
+	}
+
+	return forecaster, nil
+}
+
+// ForecastGlucose forecasts the glucose of a user up to horizon after upperBound, the time of the user's most recent
+// read, from the user's reads, injections and meals in the store
+func ForecastGlucose(context context.Context, email string, upperBound time.Time, horizon time.Duration, forecaster Forecaster) (points []model.ForecastPoint, err error) {
+	input := ForecastInput{OnBoardModel: onBoardModel}
+	if input.Reads, err = store.GetGlucoseReads(context, email, upperBound.Add(-FORECAST_HISTORY), upperBound); err != nil {
+		return nil, err
+	}
+
+	onBoardLowerBound := upperBound.Add(-input.OnBoardModel.Lookback())
+	if input.Injections, err = store.GetInjections(context, email, onBoardLowerBound, upperBound); err != nil {
+		return nil, err
+	}
+	if input.Meals, err = store.GetMeals(context, email, onBoardLowerBound, upperBound); err != nil {
+		return nil, err
+	}
+
+	log.Debugf(context, "Forecasting glucose of user [%s] for [%s] after [%s] with [%s] from [%d] reads", email, horizon,
+		upperBound, forecaster.Name(), len(input.Reads))
+	return forecaster.Forecast(input, horizon)
+}
+
+func (forecaster KalmanForecaster) Name() string {
+	return KALMAN_FORECASTER
+}
+
+// Forecast runs the filter over all reads and then predicts the state, and its growing uncertainty, at every step
+func (forecaster KalmanForecaster) Forecast(input ForecastInput, horizon time.Duration) (points []model.ForecastPoint, err error) {
+	if len(input.Reads) < MIN_FORECAST_READS {
+		return nil, ErrInsufficientReadsForForecast
+	}
+
+	reads := make([]apimodel.GlucoseRead, len(input.Reads))
+	copy(reads, input.Reads)
+	sort.Sort(apimodel.GlucoseReadSlice(reads))
+
+	measurementVariance := forecaster.MeasurementNoise * forecaster.MeasurementNoise
+	filter := kalmanState{covariance: [2][2]float64{{measurementVariance, 0}, {0, 1}}}
+	for i, read := range reads {
+		value, err := read.GetNormalizedValue(apimodel.MG_PER_DL)
+		if err != nil {
+			return nil, err
+		}
+
+		if i == 0 {
+			filter.glucose = float64(value)
+			continue
+		}
+
+		elapsed := read.GetTime().Sub(reads[i-1].GetTime()).Minutes()
+		if elapsed <= 0 {
+			continue
+		}
+
+		filter = filter.predict(elapsed, forecaster.RateNoise)
+		filter = filter.update(float64(value), measurementVariance)
+	}
+
+	lastReadTime := reads[len(reads)-1].GetTime()
+	points = make([]model.ForecastPoint, 0)
+	for offset := FORECAST_STEP; offset <= horizon; offset = offset + FORECAST_STEP {
+		predicted := filter.predict(offset.Minutes(), forecaster.RateNoise)
+		margin := FORECAST_INTERVAL_Z * math.Sqrt(predicted.covariance[0][0]+measurementVariance)
+		points = append(points, model.ForecastPoint{
+			Time:  lastReadTime.Add(offset),
+			Value: clampForecastGlucose(predicted.glucose),
+			Low:   clampForecastGlucose(predicted.glucose - margin),
+			High:  clampForecastGlucose(predicted.glucose + margin)})
+	}
+
+	return points, nil
+}
+
+// kalmanState is the state of a local linear trend Kalman filter: glucose, in mg/dL, its rate of change, in mg/dL/min,
+// and the covariance of both
+type kalmanState struct {
+	glucose    float64
+	rate       float64
+	covariance [2][2]float64
+}
+
+// predict returns the state elapsed minutes later, the rate being subject to changes with a variance of rateNoise per
+// minute
+func (state kalmanState) predict(elapsed float64, rateNoise float64) (predicted kalmanState) {
+	p := state.covariance
+	predicted.glucose = state.glucose + state.rate*elapsed
+	predicted.rate = state.rate
+	predicted.covariance = [2][2]float64{
+		{p[0][0] + elapsed*(p[0][1]+p[1][0]) + elapsed*elapsed*p[1][1] + rateNoise*elapsed*elapsed*elapsed/3,
+			p[0][1] + elapsed*p[1][1] + rateNoise*elapsed*elapsed/2},
+		{p[1][0] + elapsed*p[1][1] + rateNoise*elapsed*elapsed/2,
+			p[1][1] + rateNoise*elapsed}}
+
+	return predicted
+}
+
+// update returns the state corrected by a read of the given value
+func (state kalmanState) update(value float64, measurementVariance float64) (updated kalmanState) {
+	p := state.covariance
+	innovationVariance := p[0][0] + measurementVariance
+	glucoseGain := p[0][0] / innovationVariance
+	rateGain := p[1][0] / innovationVariance
+	innovation := value - state.glucose
+
+	updated.glucose = state.glucose + glucoseGain*innovation
+	updated.rate = state.rate + rateGain*innovation
+	updated.covariance = [2][2]float64{
+		{(1 - glucoseGain) * p[0][0], (1 - glucoseGain) * p[0][1]},
+		{p[1][0] - rateGain*p[0][0], p[1][1] - rateGain*p[0][1]}}
+
+	return updated
+}
+
+// clampForecastGlucose bounds a forecasted value to what a CGM can read
+func clampForecastGlucose(value float64) float64 {
+	return math.Max(MIN_FORECAST_GLUCOSE, math.Min(MAX_FORECAST_GLUCOSE, value))
+}
+
+// ForecastToDataPoints returns the forecasted values and the bounds of their prediction intervals as data points in
+// the given unit, labeled in the location of the points' times
+func ForecastToDataPoints(points []model.ForecastPoint, unit apimodel.GlucoseUnit) (values []apimodel.DataPoint, lows []apimodel.DataPoint, highs []apimodel.DataPoint, err error) {
+	values = make([]apimodel.DataPoint, len(points))
+	lows = make([]apimodel.DataPoint, len(points))
+	highs = make([]apimodel.DataPoint, len(points))
+	for i, point := range points {
+		if values[i], err = newForecastDataPoint(point.Time, point.Value, FORECAST_TAG, unit); err != nil {
+			return nil, nil, nil, err
+		}
+		if lows[i], err = newForecastDataPoint(point.Time, point.Low, FORECAST_LOW_TAG, unit); err != nil {
+			return nil, nil, nil, err
+		}
+		if highs[i], err = newForecastDataPoint(point.Time, point.High, FORECAST_HIGH_TAG, unit); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	return values, lows, highs, nil
+}
+
+func newForecastDataPoint(pointTime time.Time, valueInMgPerDL float64, tag string, unit apimodel.GlucoseUnit) (point apimodel.DataPoint, err error) {
+	read := apimodel.GlucoseRead{Unit: apimodel.MG_PER_DL, Value: float32(valueInMgPerDL)}
+	value, err := read.GetNormalizedValue(unit)
+	if err != nil {
+		return point, err
+	}
+
+	return apimodel.DataPoint{LocalTime: pointTime.Format(util.TIMEFORMAT), EpochTime: pointTime.Unix(), Y: value, Value: value, Tag: tag, Unit: unit}, nil
+}
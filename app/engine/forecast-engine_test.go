@@ -0,0 +1,101 @@
+package engine_test
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/model"
+	"testing"
+	"time"
+)
+
+var forecastStart = time.Date(2014, 4, 1, 8, 0, 0, 0, time.UTC)
+
+// risingReads returns reads every 5 minutes over an hour, rising by 2 mg/dL per minute from 100 mg/dL
+func risingReads() (reads []apimodel.GlucoseRead) {
+	return readsBetween(forecastStart, forecastStart.Add(time.Hour), func(i int, readTime time.Time) float64 {
+		return 100 + 2*readTime.Sub(forecastStart).Minutes()
+	})
+}
+
+func TestKalmanForecastFollowsTrend(t *testing.T) {
+	forecaster, err := engine.GetForecaster(engine.KALMAN_FORECASTER)
+	if err != nil {
+		t.Fatalf("Expected the kalman forecaster to be registered but got [%v]", err)
+	}
+
+	points, err := forecaster.Forecast(engine.ForecastInput{Reads: risingReads()}, 30*time.Minute)
+	if err != nil {
+		t.Fatalf("Unexpected error forecasting: %v", err)
+	}
+
+	if len(points) != 6 {
+		t.Fatalf("Expected [6] forecasted points but got [%d]", len(points))
+	}
+
+	lastReadTime := forecastStart.Add(time.Hour)
+	for i, point := range points {
+		if expectedTime := lastReadTime.Add(time.Duration(i+1) * engine.FORECAST_STEP); !point.Time.Equal(expectedTime) {
+			t.Errorf("Expected point [%d] at [%v] but got [%v]", i, expectedTime, point.Time)
+		}
+
+		if point.Low > point.Value || point.Value > point.High {
+			t.Errorf("Expected point [%d] to be within its interval but got [%v]", i, point)
+		}
+
+		if i > 0 && (point.Value <= points[i-1].Value || point.High-point.Low <= points[i-1].High-points[i-1].Low) {
+			t.Errorf("Expected point [%d] to keep rising with a wider interval but got [%v] after [%v]", i, point, points[i-1])
+		}
+	}
+
+	// 30 minutes after a read of 220 mg/dL, rising by 2 mg/dL per minute
+	if value := points[len(points)-1].Value; value < 270 || value > 290 {
+		t.Errorf("Expected a forecast of about [280] mg/dL but got [%f]", value)
+	}
+}
+
+func TestKalmanForecastIsClamped(t *testing.T) {
+	forecaster, _ := engine.GetForecaster(engine.KALMAN_FORECASTER)
+
+	points, err := forecaster.Forecast(engine.ForecastInput{Reads: risingReads()}, engine.MAX_FORECAST_HORIZON)
+	if err != nil {
+		t.Fatalf("Unexpected error forecasting: %v", err)
+	}
+
+	for _, point := range points {
+		if point.High > engine.MAX_FORECAST_GLUCOSE || point.Low < engine.MIN_FORECAST_GLUCOSE {
+			t.Errorf("Expected point to be within the range of a CGM but got [%v]", point)
+		}
+	}
+}
+
+func TestForecastWithInsufficientReads(t *testing.T) {
+	forecaster, _ := engine.GetForecaster(engine.KALMAN_FORECASTER)
+
+	_, err := forecaster.Forecast(engine.ForecastInput{Reads: risingReads()[:2]}, engine.DEFAULT_FORECAST_HORIZON)
+	if err != engine.ErrInsufficientReadsForForecast {
+		t.Errorf("Expected [%v] but got [%v]", engine.ErrInsufficientReadsForForecast, err)
+	}
+}
+
+func TestGetUnknownForecaster(t *testing.T) {
+	if _, err := engine.GetForecaster("crystalBall"); err != engine.ErrUnknownForecaster {
+		t.Errorf("Expected [%v] but got [%v]", engine.ErrUnknownForecaster, err)
+	}
+}
+
+func TestForecastToDataPoints(t *testing.T) {
+	points := []model.ForecastPoint{{forecastStart, 180, 162, 198}}
+
+	values, lows, highs, err := engine.ForecastToDataPoints(points, apimodel.MMOL_PER_L)
+	if err != nil {
+		t.Fatalf("Unexpected error converting forecast: %v", err)
+	}
+
+	if len(values) != 1 || values[0].Y < 9.9 || values[0].Y > 10.1 || values[0].Tag != engine.FORECAST_TAG {
+		t.Errorf("Expected a forecasted value of [10] mmol/L but got [%v]", values)
+	}
+
+	if lows[0].Y >= values[0].Y || highs[0].Y <= values[0].Y || lows[0].EpochTime != forecastStart.Unix() {
+		t.Errorf("Expected the interval to bound the value but got [%v] and [%v]", lows, highs)
+	}
+}
@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/model"
+	"sort"
+	"time"
+)
+
+const (
+	// Default number of days of total daily insulin
+	DAILY_INSULIN_DAYS = 14
+	// How far back to look for the basal rate, temp basal, suspend or extended bolus still going at the start of a
+	// period. Pumps record every segment of their basal schedule so there's at least one basal rate a day.
+	PUMP_EVENT_LOOKBACK = 24 * time.Hour
+)
+
+// PumpDelivery holds the insulin delivered by a pump, each kind sorted by time
+type PumpDelivery struct {
+	BasalRates      []apimodel.BasalRate
+	TempBasals      []apimodel.TempBasal
+	PumpSuspends    []apimodel.PumpSuspend
+	ExtendedBoluses []apimodel.ExtendedBolus
+}
+
+// NewPumpDelivery returns the delivery of a pump with every kind of event sorted by time
+func NewPumpDelivery(basalRates []apimodel.BasalRate, tempBasals []apimodel.TempBasal, pumpSuspends []apimodel.PumpSuspend, extendedBoluses []apimodel.ExtendedBolus) (delivery PumpDelivery) {
+	delivery = PumpDelivery{BasalRates: basalRates, TempBasals: tempBasals, PumpSuspends: pumpSuspends, ExtendedBoluses: extendedBoluses}
+	sort.Sort(apimodel.BasalRateSlice(delivery.BasalRates))
+	sort.Sort(apimodel.TempBasalSlice(delivery.TempBasals))
+	sort.Sort(apimodel.PumpSuspendSlice(delivery.PumpSuspends))
+	sort.Sort(apimodel.ExtendedBolusSlice(delivery.ExtendedBoluses))
+
+	return delivery
+}
+
+// CalculateTotalDailyInsulin returns the insulin delivered on every day from lowerBound to upperBound, split into basal
+// and bolus. Days start at midnight in the location of upperBound, the first one on the day of lowerBound, and the last
+// one ends at upperBound.
+func CalculateTotalDailyInsulin(injections []apimodel.Injection, delivery PumpDelivery, lowerBound time.Time, upperBound time.Time) (totals model.TotalDailyInsulin) {
+	totals = model.TotalDailyInsulin{LowerBound: lowerBound, UpperBound: upperBound, Days: make([]model.DailyInsulin, 0)}
+
+	location := upperBound.Location()
+	localLowerBound := lowerBound.In(location)
+	dayStart := time.Date(localLowerBound.Year(), localLowerBound.Month(), localLowerBound.Day(), 0, 0, 0, 0, location)
+	deliveryDays := 0
+	for dayStart.Before(upperBound) {
+		dayEnd := dayStart.AddDate(0, 0, 1)
+		if dayEnd.After(upperBound) {
+			dayEnd = upperBound
+		}
+		day := model.DailyInsulin{Date: dayStart.Format("2006-01-02"), Basal: delivery.basalBetween(dayStart, dayEnd)}
+
+		for _, injection := range injections {
+			if injectionTime := injection.GetTime(); injectionTime.Before(dayStart) || !injectionTime.Before(dayEnd) {
+				continue
+			}
+
+			if injection.InsulinType == apimodel.LONG_ACTING_INSULIN_TYPE {
+				day.Basal += float64(injection.Units)
+			} else {
+				day.Bolus += float64(injection.Units)
+			}
+		}
+		day.Bolus += delivery.extendedBolusesBetween(dayStart, dayEnd)
+
+		day.Total = day.Basal + day.Bolus
+		if day.Total > 0 {
+			day.BasalPercentage = 100 * day.Basal / day.Total
+			totals.Average.Basal += day.Basal
+			totals.Average.Bolus += day.Bolus
+			deliveryDays++
+		}
+
+		totals.Days = append(totals.Days, day)
+		dayStart = dayEnd
+	}
+
+	if deliveryDays > 0 {
+		totals.Average.Basal = totals.Average.Basal / float64(deliveryDays)
+		totals.Average.Bolus = totals.Average.Bolus / float64(deliveryDays)
+		totals.Average.Total = totals.Average.Basal + totals.Average.Bolus
+		totals.Average.BasalPercentage = 100 * totals.Average.Basal / totals.Average.Total
+	}
+
+	return totals
+}
+
+// basalBetween returns the units of basal delivered from start to end. Delivery follows the most recent basal rate
+// unless a temp basal replaces it or the pump is suspended.
+func (delivery PumpDelivery) basalBetween(start time.Time, end time.Time) (units float64) {
+	changes := []time.Time{start, end}
+	for _, basalRate := range delivery.BasalRates {
+		changes = append(changes, basalRate.GetTime())
+	}
+	for _, tempBasal := range delivery.TempBasals {
+		changes = append(changes, tempBasal.GetTime(), tempBasal.GetEndTime())
+	}
+	for _, pumpSuspend := range delivery.PumpSuspends {
+		changes = append(changes, pumpSuspend.GetTime(), pumpSuspend.GetEndTime())
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Before(changes[j]) })
+
+	// The rate is constant between two consecutive changes
+	for i := 1; i < len(changes); i++ {
+		segmentStart, segmentEnd := changes[i-1], changes[i]
+		if segmentStart.Before(start) || segmentEnd.After(end) || !segmentStart.Before(segmentEnd) {
+			continue
+		}
+
+		units += delivery.basalRateAt(segmentStart) * segmentEnd.Sub(segmentStart).Hours()
+	}
+
+	return units
+}
+
+// basalRateAt returns the basal rate, in units per hour, delivered at a given time
+func (delivery PumpDelivery) basalRateAt(instant time.Time) (unitsPerHour float64) {
+	for _, pumpSuspend := range delivery.PumpSuspends {
+		if !instant.Before(pumpSuspend.GetTime()) && instant.Before(pumpSuspend.GetEndTime()) {
+			return 0
+		}
+	}
+
+	for i := len(delivery.BasalRates) - 1; i >= 0; i-- {
+		if !delivery.BasalRates[i].GetTime().After(instant) {
+			unitsPerHour = float64(delivery.BasalRates[i].UnitsPerHour)
+			break
+		}
+	}
+
+	// The most recent temp basal wins if a new one was started before the previous one ended
+	for i := len(delivery.TempBasals) - 1; i >= 0; i-- {
+		tempBasal := delivery.TempBasals[i]
+		if !instant.Before(tempBasal.GetTime()) && instant.Before(tempBasal.GetEndTime()) {
+			if tempBasal.Percent > 0 {
+				return unitsPerHour * float64(tempBasal.Percent) / 100
+			}
+			return float64(tempBasal.UnitsPerHour)
+		}
+	}
+
+	return unitsPerHour
+}
+
+// extendedBolusesBetween returns the units of the extended boluses delivered from start to end, their immediate units
+// at their time and their extended units evenly over their duration
+func (delivery PumpDelivery) extendedBolusesBetween(start time.Time, end time.Time) (units float64) {
+	for _, extendedBolus := range delivery.ExtendedBoluses {
+		bolusStart, bolusEnd := extendedBolus.GetTime(), extendedBolus.GetEndTime()
+		if !bolusStart.Before(start) && bolusStart.Before(end) {
+			units += float64(extendedBolus.ImmediateUnits)
+			if extendedBolus.DurationMinutes <= 0 {
+				units += float64(extendedBolus.ExtendedUnits)
+			}
+		}
+
+		if extendedBolus.DurationMinutes <= 0 {
+			continue
+		}
+
+		overlapStart, overlapEnd := bolusStart, bolusEnd
+		if overlapStart.Before(start) {
+			overlapStart = start
+		}
+		if overlapEnd.After(end) {
+			overlapEnd = end
+		}
+		if overlapStart.Before(overlapEnd) {
+			units += float64(extendedBolus.ExtendedUnits) * overlapEnd.Sub(overlapStart).Minutes() / float64(extendedBolus.DurationMinutes)
+		}
+	}
+
+	return units
+}
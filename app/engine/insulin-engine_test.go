@@ -0,0 +1,64 @@
+package engine_test
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"math"
+	"testing"
+	"time"
+)
+
+var insulinStart = time.Date(2014, 4, 1, 0, 0, 0, 0, time.UTC)
+
+func pumpTime(hours float64) apimodel.Time {
+	return apimodel.Time{apimodel.GetTimeMillis(insulinStart.Add(time.Duration(hours * float64(time.Hour)))), "UTC"}
+}
+
+// pumpDelivery returns 1 U/h from before the first day and 2 U/h from noon with a 50% temp basal at 6h, a zero temp
+// basal at 14h, a suspend at 20h and a dual-wave bolus at 23h extending 4 units over 2 hours
+func pumpDelivery() engine.PumpDelivery {
+	return engine.NewPumpDelivery(
+		[]apimodel.BasalRate{{pumpTime(12), 2, "Standard"}, {pumpTime(-2), 1, "Standard"}},
+		[]apimodel.TempBasal{{pumpTime(6), 60, 0, 50}, {pumpTime(14), 30, 0, 0}},
+		[]apimodel.PumpSuspend{{pumpTime(20), 60, apimodel.MANUAL_SUSPEND_REASON}},
+		[]apimodel.ExtendedBolus{{pumpTime(23), 120, 2, 4}})
+}
+
+func TestCalculateTotalDailyInsulin(t *testing.T) {
+	injections := []apimodel.Injection{
+		{pumpTime(8), 10, "Levemir", apimodel.LONG_ACTING_INSULIN_TYPE},
+		{pumpTime(12), 5, "Humalog", apimodel.FAST_ACTING_INSULIN_TYPE}}
+
+	totals := engine.CalculateTotalDailyInsulin(injections, pumpDelivery(), insulinStart, insulinStart.Add(36*time.Hour))
+	if len(totals.Days) != 2 {
+		t.Fatalf("Expected [2] days but got [%v]", totals.Days)
+	}
+
+	// 6 + 0.5 + 5 + 4 + 0 + 11 + 0 + 6 units of basal from the pump and half of the extended bolus
+	first := totals.Days[0]
+	if first.Date != "2014-04-01" || math.Abs(first.Basal-42.5) > 0.001 || math.Abs(first.Bolus-9) > 0.001 ||
+		math.Abs(first.Total-51.5) > 0.001 || math.Abs(first.BasalPercentage-82.524) > 0.001 {
+		t.Errorf("Expected [42.5] units of basal and [9] of bolus on the first day but got [%v]", first)
+	}
+
+	// The second day ends at noon, with the rest of the extended bolus
+	second := totals.Days[1]
+	if math.Abs(second.Basal-24) > 0.001 || math.Abs(second.Bolus-2) > 0.001 {
+		t.Errorf("Expected [24] units of basal and [2] of bolus on the second day but got [%v]", second)
+	}
+
+	if math.Abs(totals.Average.Basal-33.25) > 0.001 || math.Abs(totals.Average.Bolus-5.5) > 0.001 || math.Abs(totals.Average.Total-38.75) > 0.001 {
+		t.Errorf("Expected an average of [33.25] units of basal and [5.5] of bolus but got [%v]", totals.Average)
+	}
+}
+
+func TestCalculateTotalDailyInsulinWithoutDelivery(t *testing.T) {
+	totals := engine.CalculateTotalDailyInsulin(nil, engine.NewPumpDelivery(nil, nil, nil, nil), insulinStart.Add(3*time.Hour), insulinStart.Add(72*time.Hour))
+	if len(totals.Days) != 3 || totals.Days[0].Date != "2014-04-01" {
+		t.Fatalf("Expected [3] days starting on [2014-04-01] but got [%v]", totals.Days)
+	}
+
+	if totals.Average.Total != 0 || totals.Average.BasalPercentage != 0 {
+		t.Errorf("Expected no insulin delivered but got [%v]", totals.Average)
+	}
+}
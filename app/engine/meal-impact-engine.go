@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"context"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/util"
+	"sort"
+	"time"
+)
+
+const (
+	// Time after a meal over which its impact is observed
+	MEAL_IMPACT_WINDOW = 4 * time.Hour
+	// Reads further apart than this make the impact of a meal unknown
+	MEAL_IMPACT_MAX_READ_GAP = 15 * time.Minute
+	// Days of meals in each batch of meal impact calculations
+	MEAL_IMPACT_DAYS_PER_BATCH = 7
+)
+
+// StartMealImpactCalculation kicks off the calculation of the impact of the meals following the most recent one
+// already calculated or of all meals if none were
+func StartMealImpactCalculation(context context.Context, glukitUser *model.GlukitUser) (err error) {
+	limit := 1
+	impacts, err := store.GetMealImpacts(context, glukitUser.Email, store.ScoreScanQuery{Limit: &limit})
+	if err != nil {
+		return err
+	}
+
+	lowerBound := util.GLUKIT_EPOCH_TIME
+	if len(impacts) > 0 {
+		lowerBound = impacts[0].MealTime.Add(time.Second)
+	}
+
+	if err = RunMealImpactCalculationChunk.Add(context, BATCH_CALCULATION_QUEUE_NAME, glukitUser.Email, lowerBound); err != nil {
+		return err
+	}
+
+	log.Infof(context, "Queued up meal impact calculation for user [%s] from [%s]", glukitUser.Email, lowerBound)
+	return nil
+}
+
+// RunMealImpactCalculation calculates the impact of the meals in the MEAL_IMPACT_DAYS_PER_BATCH days starting with the
+// first meal at or after lowerBound and schedules the next batch. Meals whose window isn't fully covered by the reads
+// received so far are left for later.
+func RunMealImpactCalculation(context context.Context, userEmail string, lowerBound time.Time) {
+	glukitUser, err := store.GetUserProfile(context, userEmail)
+	if err != nil {
+		log.Errorf(context, "Error getting profile of user [%s] for meal impact calculation: %v", userEmail, err)
+		return
+	}
+
+	if skipAccountPendingDeletion(context, userEmail, "meal impact calculation") {
+		return
+	}
+
+	if glukitUser.MostRecentRead.Time.Timestamp == 0 {
+		return
+	}
+	mostRecentRead := glukitUser.MostRecentRead.GetTime()
+
+	meals, err := store.GetMeals(context, userEmail, lowerBound, mostRecentRead)
+	if err != nil {
+		log.Errorf(context, "Error getting meals of user [%s] for meal impact calculation: %v", userEmail, err)
+		return
+	}
+	sort.Sort(apimodel.MealSlice(meals))
+	meals = mealsBetween(meals, lowerBound, mostRecentRead.Add(-MEAL_IMPACT_WINDOW))
+	if len(meals) == 0 {
+		log.Infof(context, "Done with meal impact calculation for user [%s]", userEmail)
+		return
+	}
+
+	// Skip ahead to the first meal so that gaps in the history don't cost empty batches
+	batchStart := meals[0].GetTime()
+	batchEnd := batchStart.AddDate(0, 0, MEAL_IMPACT_DAYS_PER_BATCH)
+	batchMeals := make([]apimodel.Meal, 0)
+	for _, meal := range meals {
+		if meal.GetTime().Before(batchEnd) {
+			batchMeals = append(batchMeals, meal)
+		}
+	}
+
+	reads, err := store.GetGlucoseReads(context, userEmail, batchStart.Add(-MEAL_IMPACT_MAX_READ_GAP), batchEnd.Add(MEAL_IMPACT_WINDOW))
+	if err != nil {
+		log.Errorf(context, "Error getting reads of user [%s] for meal impact calculation: %v", userEmail, err)
+		return
+	}
+
+	impacts := CalculateMealImpacts(batchMeals, reads)
+	calculatedOn := time.Now()
+	for i := range impacts {
+		impacts[i].CalculatedOn = calculatedOn
+	}
+
+	if err := store.StoreMealImpacts(context, userEmail, impacts); err != nil {
+		log.Errorf(context, "Error storing meal impacts of user [%s]: %v", userEmail, err)
+		return
+	}
+
+	if len(batchMeals) < len(meals) {
+		if err := RunMealImpactCalculationChunk.Add(context, BATCH_CALCULATION_QUEUE_NAME, userEmail, batchEnd); err != nil {
+			log.Criticalf(context, "Couldn't schedule the next execution of [%s] for user [%s]. "+
+				"This breaks meal impact calculation for that user!: %v", MEAL_IMPACT_CALCULATION_FUNCTION_NAME, userEmail, err)
+		}
+
+		log.Infof(context, "Queued up next chunk of meal impact calculation for user [%s] and lowerBound [%s]", userEmail, batchEnd.Format(util.TIMEFORMAT))
+	} else {
+		log.Infof(context, "Done with meal impact calculation for user [%s]", userEmail)
+	}
+}
+
+// CalculateMealImpacts returns the impacts of the meals whose window is covered by the reads
+func CalculateMealImpacts(meals []apimodel.Meal, reads []apimodel.GlucoseRead) (impacts []model.MealImpact) {
+	sortedReads := make([]apimodel.GlucoseRead, len(reads))
+	copy(sortedReads, reads)
+	sort.Sort(apimodel.GlucoseReadSlice(sortedReads))
+
+	impacts = make([]model.MealImpact, 0)
+	for _, meal := range meals {
+		if impact, ok := CalculateMealImpact(meal, sortedReads); ok {
+			impacts = append(impacts, impact)
+		}
+	}
+
+	return impacts
+}
+
+// CalculateMealImpact returns the impact of a meal from reads sorted by time. The baseline is the glucose
+// interpolated at the time of the meal. It returns false if there is no read within MEAL_IMPACT_MAX_READ_GAP of the
+// meal or the end of its window or if reads are further apart than that in between.
+func CalculateMealImpact(meal apimodel.Meal, reads []apimodel.GlucoseRead) (impact model.MealImpact, ok bool) {
+	mealTime := meal.GetTime()
+	windowEnd := mealTime.Add(MEAL_IMPACT_WINDOW)
+
+	first := sort.Search(len(reads), func(i int) bool { return reads[i].GetTime().After(mealTime) })
+	if first == 0 || first == len(reads) || mealTime.Sub(reads[first-1].GetTime()) > MEAL_IMPACT_MAX_READ_GAP {
+		return impact, false
+	}
+
+	// Reads from the one right before the meal until the end of the window
+	windowReads := make([]apimodel.GlucoseRead, 0)
+	for i := first - 1; i < len(reads) && !reads[i].GetTime().After(windowEnd); i++ {
+		if i >= first && reads[i].GetTime().Sub(reads[i-1].GetTime()) > MEAL_IMPACT_MAX_READ_GAP {
+			return impact, false
+		}
+		windowReads = append(windowReads, reads[i])
+	}
+
+	if windowEnd.Sub(windowReads[len(windowReads)-1].GetTime()) > MEAL_IMPACT_MAX_READ_GAP {
+		return impact, false
+	}
+
+	baseline := float64(apimodel.InterpolateGlucose(windowReads, meal.Time, apimodel.MG_PER_DL))
+	impact = model.MealImpact{MealTime: mealTime, Carbohydrates: meal.Carbohydrates, Baseline: baseline, MinutesToBaseline: model.UNDEFINED_MINUTES_TO_BASELINE}
+
+	peakTime := mealTime
+	previousTime, previousExcess := mealTime, 0.
+	for _, read := range windowReads[1:] {
+		value, err := read.GetNormalizedValue(apimodel.MG_PER_DL)
+		if err != nil {
+			return impact, false
+		}
+
+		readTime := read.GetTime()
+		excess := float64(value) - baseline
+		if excess > impact.PeakRise {
+			impact.PeakRise, peakTime = excess, readTime
+		}
+
+		// Incremental area under the curve, only counting what's above the baseline
+		impact.AreaUnderCurve += (positive(previousExcess) + positive(excess)) / 2 * readTime.Sub(previousTime).Minutes()
+		previousTime, previousExcess = readTime, excess
+	}
+	impact.MinutesToPeak = int(peakTime.Sub(mealTime).Minutes())
+
+	if impact.PeakRise == 0 {
+		impact.MinutesToBaseline = 0
+	} else {
+		for _, read := range windowReads[1:] {
+			value, _ := read.GetNormalizedValue(apimodel.MG_PER_DL)
+			if readTime := read.GetTime(); readTime.After(peakTime) && float64(value) <= baseline {
+				impact.MinutesToBaseline = int(readTime.Sub(mealTime).Minutes())
+				break
+			}
+		}
+	}
+
+	return impact, true
+}
+
+func positive(value float64) float64 {
+	if value < 0 {
+		return 0
+	}
+
+	return value
+}
@@ -0,0 +1,107 @@
+package engine_test
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/model"
+	"math"
+	"testing"
+	"time"
+)
+
+var mealTime = time.Date(2014, 4, 1, 12, 0, 0, 0, time.UTC)
+
+// mealReads returns reads every 5 minutes from 30 minutes before the meal until 5 hours after, each at the value
+// returned for the minutes elapsed since the meal
+func mealReads(valueAt func(minutes float64) float64) (reads []apimodel.GlucoseRead) {
+	return readsBetween(mealTime.Add(-30*time.Minute), mealTime.Add(5*time.Hour), func(i int, readTime time.Time) float64 {
+		return valueAt(readTime.Sub(mealTime).Minutes())
+	})
+}
+
+func newMeal(mealTime time.Time, carbs float32) apimodel.Meal {
+	return apimodel.Meal{apimodel.Time{apimodel.GetTimeMillis(mealTime), "UTC"}, carbs, 0, 0, 0}
+}
+
+// spike rises by 80 mg/dL over an hour, comes back to the baseline of 100 an hour later and then dips below it
+func spike(minutes float64) float64 {
+	switch {
+	case minutes <= 0:
+		return 100
+	case minutes <= 60:
+		return 100 + 80*minutes/60
+	case minutes <= 120:
+		return 180 - 80*(minutes-60)/60
+	default:
+		return 90
+	}
+}
+
+func TestMealImpactOfSpike(t *testing.T) {
+	impact, ok := engine.CalculateMealImpact(newMeal(mealTime, 60), mealReads(spike))
+	if !ok {
+		t.Fatal("Expected an impact for a meal with reads covering its window")
+	}
+
+	if impact.Baseline != 100 || impact.PeakRise != 80 || impact.MinutesToPeak != 60 || impact.MinutesToBaseline != 120 {
+		t.Errorf("Expected baseline of [100], peak rise of [80] at [60] minutes and return at [120] minutes but got [%v]", impact)
+	}
+
+	// Triangle of 120 minutes by 80 mg/dL, the dip below the baseline doesn't count
+	if math.Abs(impact.AreaUnderCurve-4800) > 0.01 {
+		t.Errorf("Expected area under the curve of [4800] but got [%f]", impact.AreaUnderCurve)
+	}
+
+	if !impact.MealTime.Equal(mealTime) || impact.Carbohydrates != 60 {
+		t.Errorf("Expected impact of meal of [60] grams at [%s] but got [%v]", mealTime, impact)
+	}
+}
+
+func TestMealImpactBaselineIsInterpolated(t *testing.T) {
+	reads := mealReads(func(minutes float64) float64 { return 100 + minutes })
+
+	impact, ok := engine.CalculateMealImpact(newMeal(mealTime.Add(150*time.Second), 30), reads)
+	if !ok {
+		t.Fatal("Expected an impact for a meal with reads covering its window")
+	}
+
+	if math.Abs(impact.Baseline-102.5) > 0.01 {
+		t.Errorf("Expected baseline of [102.5] interpolated between reads but got [%f]", impact.Baseline)
+	}
+
+	if impact.MinutesToBaseline != model.UNDEFINED_MINUTES_TO_BASELINE {
+		t.Errorf("Expected glucose to never come back to its baseline but got [%d] minutes", impact.MinutesToBaseline)
+	}
+}
+
+func TestMealImpactWithoutRise(t *testing.T) {
+	impact, ok := engine.CalculateMealImpact(newMeal(mealTime, 10), mealReads(func(minutes float64) float64 { return 100 - minutes/10 }))
+	if !ok {
+		t.Fatal("Expected an impact for a meal with reads covering its window")
+	}
+
+	if impact.PeakRise != 0 || impact.MinutesToPeak != 0 || impact.AreaUnderCurve != 0 || impact.MinutesToBaseline != 0 {
+		t.Errorf("Expected no rise but got [%v]", impact)
+	}
+}
+
+func TestMealImpactsSkipMealsWithoutCoverage(t *testing.T) {
+	reads := mealReads(spike)
+	// Drop an hour of reads after the peak
+	withGap := append(append([]apimodel.GlucoseRead{}, reads[:25]...), reads[37:]...)
+
+	meals := []apimodel.Meal{
+		newMeal(mealTime, 60),
+		newMeal(mealTime.Add(-time.Hour), 20),
+		newMeal(mealTime.Add(2*time.Hour), 40),
+	}
+
+	if impacts := engine.CalculateMealImpacts(meals, withGap); len(impacts) != 0 {
+		t.Errorf("Expected no impact with a gap in reads but got [%v]", impacts)
+	}
+
+	impacts := engine.CalculateMealImpacts(meals, reads)
+	if len(impacts) != 1 || !impacts[0].MealTime.Equal(mealTime) {
+		t.Errorf("Expected only the impact of the meal with reads before it and until the end of its window but got [%v]", impacts)
+	}
+}
@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/grd/stat"
+	"math"
+	"sort"
+)
+
+// Glycemic thresholds from the international consensus on time in range, in mg/dL
+const (
+	VERY_LOW_GLUCOSE_THRESHOLD  = 54
+	LOW_GLUCOSE_THRESHOLD       = 70
+	HIGH_GLUCOSE_THRESHOLD      = 180
+	VERY_HIGH_GLUCOSE_THRESHOLD = 250
+)
+
+// CalculateDashboardData computes the summary statistics and the standard CGM metrics (time in ranges, glycemic
+// variability and risk indices) of a set of reads. The time in the user's own ranges is calculated with the range
+// that applies at the time of each read. Glucose values are returned in the requested unit.
+func CalculateDashboardData(reads []apimodel.GlucoseRead, unit apimodel.GlucoseUnit, targets model.GlucoseTargets) (dashboardData *model.DashboardData, err error) {
+	dashboardData = &model.DashboardData{Unit: unit, ReadCount: len(reads)}
+	if dashboardData.TargetRange, err = targets.Default.ConvertTo(apimodel.MG_PER_DL, unit); err != nil {
+		return nil, err
+	}
+
+	if len(reads) == 0 {
+		return dashboardData, nil
+	}
+
+	// All metrics are defined in mg/dL so we do the calculations in mg/dL and only convert the final values
+	values := make([]float64, len(reads))
+	ranges := make([]model.GlucoseRange, len(reads))
+	for i := range reads {
+		value, err := reads[i].GetNormalizedValue(apimodel.MG_PER_DL)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = float64(value)
+		ranges[i] = targets.RangeAt(reads[i].GetTime())
+	}
+
+	sortedValues := make([]float64, len(values))
+	copy(sortedValues, values)
+	sort.Float64s(sortedValues)
+
+	average := stat.Mean(stat.Float64Slice(sortedValues))
+	standardDeviation := 0.
+	if len(sortedValues) > 1 {
+		standardDeviation = stat.Sd(stat.Float64Slice(sortedValues))
+	}
+
+	dashboardData.TimeBelow54 = percentageOfValues(values, func(value float64) bool { return value < VERY_LOW_GLUCOSE_THRESHOLD })
+	dashboardData.TimeBelow70 = percentageOfValues(values, func(value float64) bool { return value < LOW_GLUCOSE_THRESHOLD })
+	dashboardData.TimeInRange = percentageOfValues(values, func(value float64) bool {
+		return value >= LOW_GLUCOSE_THRESHOLD && value <= HIGH_GLUCOSE_THRESHOLD
+	})
+	dashboardData.TimeAbove180 = percentageOfValues(values, func(value float64) bool { return value > HIGH_GLUCOSE_THRESHOLD })
+	dashboardData.TimeAbove250 = percentageOfValues(values, func(value float64) bool { return value > VERY_HIGH_GLUCOSE_THRESHOLD })
+	dashboardData.TimeBelowVeryLow = percentageInRanges(values, ranges, func(value float64, glucoseRange model.GlucoseRange) bool {
+		return value < glucoseRange.VeryLow
+	})
+	dashboardData.TimeBelowLow = percentageInRanges(values, ranges, func(value float64, glucoseRange model.GlucoseRange) bool {
+		return value < glucoseRange.Low
+	})
+	dashboardData.TimeInTargetRange = percentageInRanges(values, ranges, func(value float64, glucoseRange model.GlucoseRange) bool {
+		return value >= glucoseRange.Low && value <= glucoseRange.High
+	})
+	dashboardData.TimeAboveHigh = percentageInRanges(values, ranges, func(value float64, glucoseRange model.GlucoseRange) bool {
+		return value > glucoseRange.High
+	})
+	dashboardData.TimeAboveVeryHigh = percentageInRanges(values, ranges, func(value float64, glucoseRange model.GlucoseRange) bool {
+		return value > glucoseRange.VeryHigh
+	})
+	if average > 0 {
+		dashboardData.CoefficientOfVariation = standardDeviation / average * 100
+	}
+	dashboardData.GMI = CalculateGMI(average)
+	dashboardData.LBGI, dashboardData.HBGI = calculateBloodGlucoseRiskIndices(values)
+
+	for _, metric := range []struct {
+		target         *float64
+		valueInMgPerDL float64
+	}{
+		{&dashboardData.Average, average},
+		{&dashboardData.Median, stat.MedianFromSortedData(stat.Float64Slice(sortedValues))},
+		{&dashboardData.High, sortedValues[len(sortedValues)-1]},
+		{&dashboardData.Low, sortedValues[0]},
+		{&dashboardData.StandardDeviation, standardDeviation},
+		{&dashboardData.MAGE, calculateMAGE(values, standardDeviation)},
+	} {
+		if *metric.target, err = convertFromMgPerDL(metric.valueInMgPerDL, unit); err != nil {
+			return nil, err
+		}
+	}
+
+	return dashboardData, nil
+}
+
+// CalculateGMI returns the glucose management indicator (an a1c-like percentage) for an average glucose in mg/dL,
+// as defined by Bergenstal et al. (2018)
+func CalculateGMI(averageInMgPerDL float64) float64 {
+	return 3.31 + 0.02392*averageInMgPerDL
+}
+
+// percentageOfValues returns the percentage of values that match the predicate
+func percentageOfValues(values []float64, predicate func(value float64) bool) float64 {
+	count := 0
+	for _, value := range values {
+		if predicate(value) {
+			count++
+		}
+	}
+
+	return float64(count) / float64(len(values)) * 100
+}
+
+// percentageInRanges returns the percentage of values that match the predicate given the range that applies to each
+func percentageInRanges(values []float64, ranges []model.GlucoseRange, predicate func(value float64, glucoseRange model.GlucoseRange) bool) float64 {
+	count := 0
+	for i, value := range values {
+		if predicate(value, ranges[i]) {
+			count++
+		}
+	}
+
+	return float64(count) / float64(len(values)) * 100
+}
+
+// calculateMAGE returns the mean amplitude of glycemic excursions of time-ordered values: the average amplitude of
+// the rises and falls between peaks and nadirs that exceed one standard deviation. Smaller fluctuations are
+// considered part of the surrounding excursion.
+func calculateMAGE(values []float64, standardDeviation float64) float64 {
+	if standardDeviation == 0 {
+		return 0
+	}
+
+	total := 0.
+	count := 0
+	// The start and the furthest point of the excursion in progress and its direction (1 for a rise, -1 for a fall)
+	start, extreme, direction := values[0], values[0], 0
+	low, high := values[0], values[0]
+	for _, value := range values[1:] {
+		switch {
+		case direction == 0:
+			// Wait for a first excursion from the lowest or highest value seen so far
+			low, high = math.Min(low, value), math.Max(high, value)
+			if value-low > standardDeviation {
+				start, extreme, direction = low, value, 1
+			} else if high-value > standardDeviation {
+				start, extreme, direction = high, value, -1
+			}
+		case (value-extreme)*float64(direction) > 0:
+			extreme = value
+		case math.Abs(value-extreme) > standardDeviation:
+			total += math.Abs(extreme - start)
+			count++
+			start, extreme, direction = extreme, value, -direction
+		}
+	}
+
+	// The excursion in progress already exceeds one standard deviation
+	if direction != 0 {
+		total += math.Abs(extreme - start)
+		count++
+	}
+
+	if count == 0 {
+		return 0
+	}
+
+	return total / float64(count)
+}
+
+// calculateBloodGlucoseRiskIndices returns the low and high blood glucose indices of values in mg/dL, as defined by
+// Kovatchev et al.
+func calculateBloodGlucoseRiskIndices(values []float64) (lbgi float64, hbgi float64) {
+	for _, value := range values {
+		// Guard against nonsensical reads that would make the logarithm blow up
+		transformed := 1.509 * (math.Pow(math.Log(math.Max(value, 1)), 1.084) - 5.381)
+		risk := 10 * transformed * transformed
+		if transformed < 0 {
+			lbgi += risk
+		} else {
+			hbgi += risk
+		}
+	}
+
+	return lbgi / float64(len(values)), hbgi / float64(len(values))
+}
+
+// convertFromMgPerDL converts a glucose value in mg/dL to the given unit
+func convertFromMgPerDL(value float64, unit apimodel.GlucoseUnit) (convertedValue float64, err error) {
+	read := apimodel.GlucoseRead{Unit: apimodel.MG_PER_DL, Value: float32(value)}
+	normalizedValue, err := read.GetNormalizedValue(unit)
+	if err != nil {
+		return 0, err
+	}
+
+	return float64(normalizedValue), nil
+}
@@ -0,0 +1,104 @@
+package engine_test
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/model"
+	"math"
+	"testing"
+	"time"
+)
+
+var metricsStart = time.Date(2014, 4, 18, 0, 0, 0, 0, time.UTC)
+
+func TestDashboardDataWithSteadyReads(t *testing.T) {
+	dashboardData, err := engine.CalculateDashboardData(readsOfValues(metricsStart, 100, 100, 100, 100), apimodel.MG_PER_DL, model.DEFAULT_GLUCOSE_TARGETS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertMetric(t, "average", dashboardData.Average, 100)
+	assertMetric(t, "timeInRange", dashboardData.TimeInRange, 100)
+	assertMetric(t, "standardDeviation", dashboardData.StandardDeviation, 0)
+	assertMetric(t, "coefficientOfVariation", dashboardData.CoefficientOfVariation, 0)
+	assertMetric(t, "gmi", dashboardData.GMI, 5.702)
+	assertMetric(t, "mage", dashboardData.MAGE, 0)
+}
+
+func TestDashboardDataTimeInRanges(t *testing.T) {
+	dashboardData, err := engine.CalculateDashboardData(readsOfValues(metricsStart, 50, 65, 100, 200, 300), apimodel.MG_PER_DL, model.DEFAULT_GLUCOSE_TARGETS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertMetric(t, "timeBelow54", dashboardData.TimeBelow54, 20)
+	assertMetric(t, "timeBelow70", dashboardData.TimeBelow70, 40)
+	assertMetric(t, "timeInRange", dashboardData.TimeInRange, 20)
+	assertMetric(t, "timeAbove180", dashboardData.TimeAbove180, 40)
+	assertMetric(t, "timeAbove250", dashboardData.TimeAbove250, 20)
+	assertMetric(t, "median", dashboardData.Median, 100)
+	assertMetric(t, "low", dashboardData.Low, 50)
+	assertMetric(t, "high", dashboardData.High, 300)
+
+	if dashboardData.LBGI <= 0 || dashboardData.HBGI <= 0 {
+		t.Errorf("Expected positive lbgi and hbgi but got [%f] and [%f]", dashboardData.LBGI, dashboardData.HBGI)
+	}
+}
+
+func TestDashboardDataMAGEIgnoresSmallFluctuations(t *testing.T) {
+	dashboardData, err := engine.CalculateDashboardData(readsOfValues(metricsStart, 100, 200, 195, 200, 100, 105, 100, 200), apimodel.MG_PER_DL, model.DEFAULT_GLUCOSE_TARGETS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertMetric(t, "mage", dashboardData.MAGE, 100)
+}
+
+func TestDashboardDataInMmolPerL(t *testing.T) {
+	dashboardData, err := engine.CalculateDashboardData(readsOfValues(metricsStart, 100, 100), apimodel.MMOL_PER_L, model.DEFAULT_GLUCOSE_TARGETS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dashboardData.Unit != apimodel.MMOL_PER_L {
+		t.Errorf("Expected unit [%s] but got [%s]", apimodel.MMOL_PER_L, dashboardData.Unit)
+	}
+	assertMetric(t, "average", dashboardData.Average, 5.55)
+	// GMI is a percentage and doesn't depend on the unit
+	assertMetric(t, "gmi", dashboardData.GMI, 5.702)
+}
+
+func TestDashboardDataWithoutReads(t *testing.T) {
+	dashboardData, err := engine.CalculateDashboardData([]apimodel.GlucoseRead{}, apimodel.MG_PER_DL, model.DEFAULT_GLUCOSE_TARGETS)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dashboardData.ReadCount != 0 || dashboardData.TimeInRange != 0 {
+		t.Errorf("Expected empty dashboard data but got [%v]", dashboardData)
+	}
+}
+
+func TestDashboardDataTimeInTargetRanges(t *testing.T) {
+	// Reads are every 5 minutes from midnight so the first two are in the tighter overnight range
+	targets := model.GlucoseTargets{model.GlucoseRange{54, 70, 100, 140, 180},
+		[]model.ScheduledGlucoseRange{{"23:00", "00:10", model.GlucoseRange{60, 90, 110, 120, 150}}}}
+	dashboardData, err := engine.CalculateDashboardData(readsOfValues(metricsStart, 80, 130, 130, 160, 200), apimodel.MG_PER_DL, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertMetric(t, "timeBelowVeryLow", dashboardData.TimeBelowVeryLow, 0)
+	assertMetric(t, "timeBelowLow", dashboardData.TimeBelowLow, 20)
+	assertMetric(t, "timeInTargetRange", dashboardData.TimeInTargetRange, 20)
+	assertMetric(t, "timeAboveHigh", dashboardData.TimeAboveHigh, 60)
+	assertMetric(t, "timeAboveVeryHigh", dashboardData.TimeAboveVeryHigh, 20)
+	assertMetric(t, "timeInRange", dashboardData.TimeInRange, 80)
+	assertMetric(t, "targetRange.high", dashboardData.TargetRange.High, 140)
+}
+
+func assertMetric(t *testing.T, name string, actual float64, expected float64) {
+	if math.Abs(actual-expected) > 0.01 {
+		t.Errorf("Expected [%s] of [%f] but got [%f]", name, expected, actual)
+	}
+}
@@ -0,0 +1,284 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/util"
+	"math"
+	"time"
+)
+
+const (
+	INSULIN_ON_BOARD_TAG = "InsulinOnBoard"
+	CARBS_ON_BOARD_TAG   = "CarbsOnBoard"
+	// Interval between two points of the insulin and carbs on board curves
+	ON_BOARD_CURVE_STEP = 5 * time.Minute
+
+	// Units of the insulin and carbs on board curves
+	INSULIN_UNITS OnBoardUnit = "units"
+	CARBS_GRAMS   OnBoardUnit = "grams"
+
+	// Shapes of the action curve of rapid-acting insulins
+	EXPONENTIAL_INSULIN_ACTION = "exponential"
+	BILINEAR_INSULIN_ACTION    = "bilinear"
+)
+
+// OnBoardUnit is the unit of the values of an on board curve
+type OnBoardUnit string
+
+// ActionProfile describes how a dose of insulin or carbs acts once taken
+type ActionProfile interface {
+	// Duration returns how long a dose acts for
+	Duration() time.Duration
+	// RemainingFraction returns the fraction of a dose, from 1 to 0, that is still to act after the elapsed time
+	RemainingFraction(elapsed time.Duration) float64
+}
+
+// ExponentialActionProfile is the exponential insulin activity curve of rapid-acting insulins, peaking at Peak and
+// ending at Total. Peak must be less than half of Total.
+type ExponentialActionProfile struct {
+	Peak  time.Duration
+	Total time.Duration
+}
+
+// BilinearActionProfile is an activity rising linearly until Peak and then falling linearly until Total
+type BilinearActionProfile struct {
+	Peak  time.Duration
+	Total time.Duration
+}
+
+// LinearActionProfile is a constant activity over Total, like long-acting basal insulin or steadily absorbed carbs
+type LinearActionProfile struct {
+	Total time.Duration
+}
+
+// OnBoardModel holds the action profiles used for the insulin and carbs on board curves. Injections use the profile
+// of their insulin type or DefaultInsulinProfile when their type is unknown.
+type OnBoardModel struct {
+	InsulinProfiles       map[string]ActionProfile
+	DefaultInsulinProfile ActionProfile
+	CarbsProfile          ActionProfile
+}
+
+// DEFAULT_ON_BOARD_MODEL uses the common 6 hours curve peaking at 75 minutes for rapid-acting insulins, a day for
+// long-acting ones and 3 hours for carbs
+var DEFAULT_ON_BOARD_MODEL = OnBoardModel{
+	InsulinProfiles: map[string]ActionProfile{
+		apimodel.FAST_ACTING_INSULIN_TYPE: ExponentialActionProfile{75 * time.Minute, 6 * time.Hour},
+		apimodel.LONG_ACTING_INSULIN_TYPE: LinearActionProfile{24 * time.Hour},
+	},
+	DefaultInsulinProfile: ExponentialActionProfile{75 * time.Minute, 6 * time.Hour},
+	CarbsProfile:          LinearActionProfile{3 * time.Hour},
+}
+
+var onBoardModel = DEFAULT_ON_BOARD_MODEL
+
+// SetOnBoardModel sets the model used for the on board curves, therapy estimates and forecasts from then on.
+// DEFAULT_ON_BOARD_MODEL is used by default.
+func SetOnBoardModel(newOnBoardModel OnBoardModel) {
+	onBoardModel = newOnBoardModel
+}
+
+// GetOnBoardModel returns the model currently used for the on board curves, therapy estimates and forecasts
+func GetOnBoardModel() OnBoardModel {
+	return onBoardModel
+}
+
+// NewOnBoardModel returns DEFAULT_ON_BOARD_MODEL with the curve of rapid-acting insulins of the given shape
+// (EXPONENTIAL_INSULIN_ACTION, the default, or BILINEAR_INSULIN_ACTION) and the durations of its profiles replaced by
+// the non-zero ones of that curve (insulinPeak and insulinDuration), of the linear curve of long-acting insulins and
+// of the linear absorption of carbs
+func NewOnBoardModel(insulinShape string, insulinPeak time.Duration, insulinDuration time.Duration, longActingInsulinDuration time.Duration, carbsAbsorption time.Duration) (newOnBoardModel OnBoardModel, err error) {
+	defaultRapidActing := DEFAULT_ON_BOARD_MODEL.DefaultInsulinProfile.(ExponentialActionProfile)
+	longActing := DEFAULT_ON_BOARD_MODEL.InsulinProfiles[apimodel.LONG_ACTING_INSULIN_TYPE].(LinearActionProfile)
+	carbs := DEFAULT_ON_BOARD_MODEL.CarbsProfile.(LinearActionProfile)
+
+	peak, total := defaultRapidActing.Peak, defaultRapidActing.Total
+	if insulinPeak != 0 {
+		peak = insulinPeak
+	}
+	if insulinDuration != 0 {
+		total = insulinDuration
+	}
+	if longActingInsulinDuration != 0 {
+		longActing.Total = longActingInsulinDuration
+	}
+	if carbsAbsorption != 0 {
+		carbs.Total = carbsAbsorption
+	}
+
+	var rapidActing ActionProfile
+	switch insulinShape {
+	case "", EXPONENTIAL_INSULIN_ACTION:
+		if peak <= 0 || 2*peak >= total {
+			return newOnBoardModel, errors.New(fmt.Sprintf("Invalid insulin action, peak [%s] must be positive and less than half of the duration [%s]", peak, total))
+		}
+		rapidActing = ExponentialActionProfile{Peak: peak, Total: total}
+	case BILINEAR_INSULIN_ACTION:
+		if peak <= 0 || peak >= total {
+			return newOnBoardModel, errors.New(fmt.Sprintf("Invalid insulin action, peak [%s] must be positive and less than the duration [%s]", peak, total))
+		}
+		rapidActing = BilinearActionProfile{Peak: peak, Total: total}
+	default:
+		return newOnBoardModel, errors.New(fmt.Sprintf("Invalid insulin action shape [%s], must be one of [%s, %s]", insulinShape, EXPONENTIAL_INSULIN_ACTION, BILINEAR_INSULIN_ACTION))
+	}
+
+	if longActing.Total <= 0 {
+		return newOnBoardModel, errors.New(fmt.Sprintf("Invalid long-acting insulin duration [%s], must be positive", longActing.Total))
+	}
+	if carbs.Total <= 0 {
+		return newOnBoardModel, errors.New(fmt.Sprintf("Invalid carbs absorption [%s], must be positive", carbs.Total))
+	}
+
+	newOnBoardModel = OnBoardModel{
+		InsulinProfiles: map[string]ActionProfile{
+			apimodel.FAST_ACTING_INSULIN_TYPE: rapidActing,
+			apimodel.LONG_ACTING_INSULIN_TYPE: longActing,
+		},
+		DefaultInsulinProfile: rapidActing,
+		CarbsProfile:          carbs,
+	}
+
+	return newOnBoardModel, nil
+}
+
+func (profile ExponentialActionProfile) Duration() time.Duration {
+	return profile.Total
+}
+
+// RemainingFraction follows the exponential model used by open source closed-loop systems
+func (profile ExponentialActionProfile) RemainingFraction(elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 1.
+	} else if elapsed >= profile.Total {
+		return 0.
+	}
+
+	t := elapsed.Minutes()
+	peak := profile.Peak.Minutes()
+	total := profile.Total.Minutes()
+
+	tau := peak * (1 - peak/total) / (1 - 2*peak/total)
+	a := 2 * tau / total
+	s := 1 / (1 - a + (1+a)*math.Exp(-total/tau))
+
+	return clampFraction(1 - s*(1-a)*((t*t/(tau*total*(1-a))-t/tau-1)*math.Exp(-t/tau)+1))
+}
+
+func (profile BilinearActionProfile) Duration() time.Duration {
+	return profile.Total
+}
+
+// RemainingFraction is what's left of the triangle of activity after the elapsed time
+func (profile BilinearActionProfile) RemainingFraction(elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 1.
+	} else if elapsed >= profile.Total {
+		return 0.
+	}
+
+	t := elapsed.Minutes()
+	peak := profile.Peak.Minutes()
+	total := profile.Total.Minutes()
+
+	// The triangle's area is 1 so its height at the peak is 2/total
+	if t <= peak {
+		return clampFraction(1 - t*t/(peak*total))
+	}
+
+	return clampFraction((total - t) * (total - t) / ((total - peak) * total))
+}
+
+func (profile LinearActionProfile) Duration() time.Duration {
+	return profile.Total
+}
+
+// RemainingFraction decreases linearly to 0 at Total
+func (profile LinearActionProfile) RemainingFraction(elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 1.
+	} else if elapsed >= profile.Total {
+		return 0.
+	}
+
+	return 1 - float64(elapsed)/float64(profile.Total)
+}
+
+// Lookback returns how long before a period doses can still be acting during it, the longest duration of the profiles
+func (onBoardModel OnBoardModel) Lookback() (lookback time.Duration) {
+	lookback = onBoardModel.CarbsProfile.Duration()
+	if onBoardModel.DefaultInsulinProfile.Duration() > lookback {
+		lookback = onBoardModel.DefaultInsulinProfile.Duration()
+	}
+
+	for _, profile := range onBoardModel.InsulinProfiles {
+		if profile.Duration() > lookback {
+			lookback = profile.Duration()
+		}
+	}
+
+	return lookback
+}
+
+// insulinProfile returns the profile of the insulin of an injection
+func (onBoardModel OnBoardModel) insulinProfile(injection apimodel.Injection) ActionProfile {
+	if profile, ok := onBoardModel.InsulinProfiles[injection.InsulinType]; ok {
+		return profile
+	}
+
+	return onBoardModel.DefaultInsulinProfile
+}
+
+// CalculateInsulinOnBoard returns the units of insulin on board every ON_BOARD_CURVE_STEP from lowerBound to
+// upperBound. Injections must include those from up to the model's Lookback before lowerBound.
+func CalculateInsulinOnBoard(injections []apimodel.Injection, onBoardModel OnBoardModel, lowerBound time.Time, upperBound time.Time) (curve []apimodel.DataPoint) {
+	doses := make([]onBoardDose, len(injections))
+	for i, injection := range injections {
+		doses[i] = onBoardDose{injection.GetTime(), float64(injection.Units), onBoardModel.insulinProfile(injection)}
+	}
+
+	return calculateOnBoardCurve(doses, lowerBound, upperBound, INSULIN_ON_BOARD_TAG, INSULIN_UNITS)
+}
+
+// CalculateCarbsOnBoard returns the grams of carbs on board every ON_BOARD_CURVE_STEP from lowerBound to upperBound.
+// Meals must include those from up to the model's Lookback before lowerBound.
+func CalculateCarbsOnBoard(meals []apimodel.Meal, onBoardModel OnBoardModel, lowerBound time.Time, upperBound time.Time) (curve []apimodel.DataPoint) {
+	doses := make([]onBoardDose, len(meals))
+	for i, meal := range meals {
+		doses[i] = onBoardDose{meal.GetTime(), float64(meal.Carbohydrates), onBoardModel.CarbsProfile}
+	}
+
+	return calculateOnBoardCurve(doses, lowerBound, upperBound, CARBS_ON_BOARD_TAG, CARBS_GRAMS)
+}
+
+// onBoardDose is an amount of insulin or carbs taken at a given time
+type onBoardDose struct {
+	time    time.Time
+	amount  float64
+	profile ActionProfile
+}
+
+// calculateOnBoardCurve sums what remains of all doses at every step, labeling points in the location of upperBound
+func calculateOnBoardCurve(doses []onBoardDose, lowerBound time.Time, upperBound time.Time, tag string, unit OnBoardUnit) (curve []apimodel.DataPoint) {
+	curve = make([]apimodel.DataPoint, 0)
+	for pointTime := lowerBound.In(upperBound.Location()); !pointTime.After(upperBound); pointTime = pointTime.Add(ON_BOARD_CURVE_STEP) {
+		onBoard := 0.
+		for _, dose := range doses {
+			if elapsed := pointTime.Sub(dose.time); elapsed >= 0 {
+				onBoard += dose.amount * dose.profile.RemainingFraction(elapsed)
+			}
+		}
+
+		value := float32(math.Round(onBoard*100) / 100)
+		curve = append(curve, apimodel.DataPoint{LocalTime: pointTime.Format(util.TIMEFORMAT), EpochTime: pointTime.Unix(), Y: value,
+			Value: value, Tag: tag, Unit: apimodel.GlucoseUnit(unit)})
+	}
+
+	return curve
+}
+
+// clampFraction bounds rounding errors of a fraction to [0, 1]
+func clampFraction(fraction float64) float64 {
+	return math.Max(0, math.Min(1, fraction))
+}
@@ -0,0 +1,129 @@
+package engine_test
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"math"
+	"testing"
+	"time"
+)
+
+var onBoardStart = time.Date(2014, 4, 18, 8, 0, 0, 0, time.UTC)
+
+func TestActionProfilesBounds(t *testing.T) {
+	for _, profile := range []engine.ActionProfile{
+		engine.ExponentialActionProfile{75 * time.Minute, 6 * time.Hour},
+		engine.BilinearActionProfile{75 * time.Minute, 4 * time.Hour},
+		engine.LinearActionProfile{24 * time.Hour},
+	} {
+		if fraction := profile.RemainingFraction(0); fraction != 1 {
+			t.Errorf("Expected all of the dose remaining when taken for [%v] but got [%f]", profile, fraction)
+		}
+
+		if fraction := profile.RemainingFraction(profile.Duration()); fraction != 0 {
+			t.Errorf("Expected nothing remaining at the end for [%v] but got [%f]", profile, fraction)
+		}
+
+		previous := 1.
+		for elapsed := time.Minute; elapsed < profile.Duration(); elapsed += time.Minute {
+			fraction := profile.RemainingFraction(elapsed)
+			if fraction > previous {
+				t.Fatalf("Expected remaining fraction to decrease for [%v] but got [%f] after [%f] at [%s]", profile, fraction, previous, elapsed)
+			}
+			previous = fraction
+		}
+	}
+}
+
+func TestBilinearActionProfile(t *testing.T) {
+	profile := engine.BilinearActionProfile{1 * time.Hour, 4 * time.Hour}
+
+	// A quarter of the activity happens before the peak which is at a quarter of the duration
+	if fraction := profile.RemainingFraction(time.Hour); math.Abs(fraction-0.75) > 1e-9 {
+		t.Errorf("Expected [0.75] remaining at the peak but got [%f]", fraction)
+	}
+}
+
+func TestInsulinOnBoard(t *testing.T) {
+	injections := []apimodel.Injection{
+		{apimodel.Time{apimodel.GetTimeMillis(onBoardStart), "UTC"}, 4, "Humalog", apimodel.FAST_ACTING_INSULIN_TYPE},
+		{apimodel.Time{apimodel.GetTimeMillis(onBoardStart), "UTC"}, 24, "Lantus", apimodel.LONG_ACTING_INSULIN_TYPE},
+	}
+
+	curve := engine.CalculateInsulinOnBoard(injections, engine.DEFAULT_ON_BOARD_MODEL, onBoardStart.Add(-time.Hour), onBoardStart.Add(12*time.Hour))
+	if len(curve) != 13*12+1 {
+		t.Fatalf("Expected a point every 5 minutes but got [%d] points", len(curve))
+	}
+
+	if curve[0].Value != 0 || curve[0].Tag != engine.INSULIN_ON_BOARD_TAG || curve[0].Unit != apimodel.GlucoseUnit(engine.INSULIN_UNITS) {
+		t.Errorf("Expected no insulin on board before the injections but got [%v]", curve[0])
+	}
+
+	if curve[12].Value != 28 {
+		t.Errorf("Expected [28] units on board at the injections but got [%v]", curve[12])
+	}
+
+	// After 6 hours, the rapid-acting insulin is done and three quarters of the long-acting one remain
+	if curve[12+6*12].Value != 18 {
+		t.Errorf("Expected [18] units on board after 6 hours but got [%v]", curve[12+6*12])
+	}
+}
+
+func TestCarbsOnBoard(t *testing.T) {
+	meals := []apimodel.Meal{{apimodel.Time{apimodel.GetTimeMillis(onBoardStart), "UTC"}, 60, 0, 0, 0}}
+
+	curve := engine.CalculateCarbsOnBoard(meals, engine.DEFAULT_ON_BOARD_MODEL, onBoardStart, onBoardStart.Add(4*time.Hour))
+	if curve[0].Value != 60 || curve[18].Value != 30 || curve[len(curve)-1].Value != 0 {
+		t.Errorf("Expected carbs on board of [60], [30] and [0] at 0, 90 and 240 minutes but got [%v], [%v] and [%v]",
+			curve[0], curve[18], curve[len(curve)-1])
+	}
+}
+
+func TestOnBoardModelDurationsAreConfigurable(t *testing.T) {
+	onBoardModel, err := engine.NewOnBoardModel("", 0, 0, 0, 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if onBoardModel.DefaultInsulinProfile != engine.DEFAULT_ON_BOARD_MODEL.DefaultInsulinProfile || onBoardModel.Lookback() != 24*time.Hour {
+		t.Errorf("Expected the default insulin profiles to be kept but got [%v]", onBoardModel)
+	}
+
+	meals := []apimodel.Meal{{apimodel.Time{apimodel.GetTimeMillis(onBoardStart), "UTC"}, 60, 0, 0, 0}}
+	curve := engine.CalculateCarbsOnBoard(meals, onBoardModel, onBoardStart, onBoardStart.Add(2*time.Hour))
+	if curve[12].Value != 30 || curve[len(curve)-1].Value != 0 || curve[0].Unit != apimodel.GlucoseUnit(engine.CARBS_GRAMS) {
+		t.Errorf("Expected carbs absorbed over 2 hours but got [%v] and [%v]", curve[12], curve[len(curve)-1])
+	}
+}
+
+func TestBilinearInsulinActionIsConfigurable(t *testing.T) {
+	onBoardModel, err := engine.NewOnBoardModel(engine.BILINEAR_INSULIN_ACTION, 3*time.Hour, 4*time.Hour, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := engine.BilinearActionProfile{3 * time.Hour, 4 * time.Hour}
+	if onBoardModel.DefaultInsulinProfile != expected {
+		t.Errorf("Expected rapid-acting insulin profile [%v] but got [%v]", expected, onBoardModel.DefaultInsulinProfile)
+	}
+}
+
+func TestInvalidOnBoardModelDurations(t *testing.T) {
+	for _, durations := range [][4]time.Duration{
+		{3 * time.Hour, 0, 0, 0},
+		{-time.Minute, 0, 0, 0},
+		{0, 0, -time.Hour, 0},
+		{0, 0, 0, -time.Hour},
+	} {
+		if _, err := engine.NewOnBoardModel(engine.EXPONENTIAL_INSULIN_ACTION, durations[0], durations[1], durations[2], durations[3]); err == nil {
+			t.Errorf("Expected an error for durations [%v]", durations)
+		}
+	}
+
+	if _, err := engine.NewOnBoardModel(engine.BILINEAR_INSULIN_ACTION, 7*time.Hour, 0, 0, 0); err == nil {
+		t.Errorf("Expected an error for a bilinear peak after the end of the insulin action")
+	}
+	if _, err := engine.NewOnBoardModel("sigmoid", 0, 0, 0, 0); err == nil {
+		t.Errorf("Expected an error for an unknown insulin action shape")
+	}
+}
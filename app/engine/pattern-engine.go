@@ -0,0 +1,359 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/tasks"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	PATTERN_DETECTION_FUNCTION_NAME       = "runPatternDetection"
+	USERS_PATTERN_DETECTION_FUNCTION_NAME = "runUsersPatternDetectionChunk"
+	// Number of users whose pattern detection is queued by each chunk
+	USERS_PER_PATTERN_DETECTION_BATCH = 100
+	// Weeks of reads, up to the most recent one, patterns are detected in
+	PATTERN_DETECTION_WEEKS = 4
+	// A pattern is recurring when seen on at least this many days and this fraction of the days with data
+	MIN_PATTERN_OCCURRENCES = 3
+	MIN_PATTERN_FREQUENCY   = 0.2
+	// Minimum number of reads in a window of the day for it to be looked at, an hour of 5 minutes reads
+	MIN_PATTERN_WINDOW_READS = 12
+	// Minimum rise (mg/dL) from the overnight nadir to the early morning high for a dawn phenomenon
+	DAWN_PHENOMENON_MIN_RISE = 20.
+	// Maximum time between a low and a high for the high to be a rebound
+	REBOUND_MAX_DELAY = 3 * time.Hour
+	// Insights detected for a period ending this long before the most recent read are no longer current
+	CURRENT_INSIGHTS_MAX_AGE = 14 * 24 * time.Hour
+)
+
+// Windows of the day, in local hours from start (inclusive) to end (exclusive), patterns are looked for in
+var (
+	NIGHT_WINDOW        = hourWindow{0, 6}
+	DAWN_NADIR_WINDOW   = hourWindow{0, 5}
+	DAWN_RISE_WINDOW    = hourWindow{5, 8}
+	AFTER_DINNER_WINDOW = hourWindow{19, 24}
+)
+
+var RunPatternDetectionTask = tasks.Func(PATTERN_DETECTION_FUNCTION_NAME, RunPatternDetection)
+
+type hourWindow struct {
+	StartHour int
+	EndHour   int
+}
+
+// patternRead is a read with its value in mg/dL and its time in the read's time zone
+type patternRead struct {
+	time  time.Time
+	value float64
+}
+
+// StartPatternDetection kicks off the detection of the recurring patterns in the reads of all users
+func StartPatternDetection(context context.Context) (err error) {
+	if err = RunUsersPatternDetectionChunk.Add(context, BATCH_CALCULATION_QUEUE_NAME, ""); err != nil {
+		return err
+	}
+
+	log.Infof(context, "Queued up pattern detection for all users")
+	return nil
+}
+
+// RunUsersPatternDetection queues the pattern detection of a batch of users, those following afterEmail, and
+// schedules the next batch until all users have been covered
+func RunUsersPatternDetection(context context.Context, afterEmail string) {
+	emails, err := store.ScanUserEmails(context, afterEmail, USERS_PER_PATTERN_DETECTION_BATCH)
+	if err != nil {
+		log.Errorf(context, "Error scanning users after [%s] for pattern detection: %v", afterEmail, err)
+		return
+	}
+
+	for _, email := range emails {
+		if err := RunPatternDetectionTask.Add(context, BATCH_CALCULATION_QUEUE_NAME, email); err != nil {
+			log.Criticalf(context, "Couldn't schedule the pattern detection for user [%s]: %v", email, err)
+		}
+	}
+
+	if len(emails) == USERS_PER_PATTERN_DETECTION_BATCH {
+		lastEmail := emails[len(emails)-1]
+		if err := RunUsersPatternDetectionChunk.Add(context, BATCH_CALCULATION_QUEUE_NAME, lastEmail); err != nil {
+			log.Criticalf(context, "Couldn't schedule the next execution of [%s] after user [%s]. "+
+				"This breaks the pattern detection for the remaining users!: %v", USERS_PATTERN_DETECTION_FUNCTION_NAME, lastEmail, err)
+		}
+
+		log.Infof(context, "Queued up next chunk of users for pattern detection after user [%s]", lastEmail)
+	} else {
+		log.Infof(context, "Done queuing pattern detection of all users")
+	}
+}
+
+// RunPatternDetection detects the recurring patterns in the PATTERN_DETECTION_WEEKS of reads of a user up to its most
+// recent read and stores them as insights. A detection that finds no pattern stores a NO_PATTERN_INSIGHT so that the
+// insights of previous detections stop being current.
+func RunPatternDetection(context context.Context, userEmail string) {
+	glukitUser, err := store.GetUserProfile(context, userEmail)
+	if err != nil {
+		log.Errorf(context, "Error getting profile of user [%s] for pattern detection: %v", userEmail, err)
+		return
+	}
+
+	if skipAccountPendingDeletion(context, userEmail, "pattern detection") {
+		return
+	}
+
+	if glukitUser.MostRecentRead.Time.Timestamp == 0 {
+		log.Infof(context, "No reads yet for user [%s], skipping pattern detection", userEmail)
+		return
+	}
+
+	upperBound := glukitUser.MostRecentRead.GetTime()
+	lowerBound := upperBound.AddDate(0, 0, -7*PATTERN_DETECTION_WEEKS)
+	reads, err := store.GetGlucoseReads(context, userEmail, lowerBound, upperBound)
+	if err != nil {
+		log.Errorf(context, "Error getting reads of user [%s] for pattern detection: %v", userEmail, err)
+		return
+	}
+
+	insights, err := DetectPatterns(reads, glukitUser.GetGlucoseTargets(), lowerBound, upperBound)
+	if err != nil {
+		log.Errorf(context, "Error detecting patterns for user [%s]: %v", userEmail, err)
+		return
+	}
+
+	storedInsights := insights
+	if len(insights) == 0 {
+		storedInsights = []model.Insight{{Type: model.NO_PATTERN_INSIGHT, LowerBound: lowerBound, UpperBound: upperBound}}
+	}
+
+	detectedOn := time.Now()
+	for i := range storedInsights {
+		storedInsights[i].DetectedOn = detectedOn
+	}
+
+	if err = store.StoreInsights(context, userEmail, storedInsights); err != nil {
+		log.Errorf(context, "Error storing insights of user [%s]: %v", userEmail, err)
+		return
+	}
+
+	log.Infof(context, "Detected [%d] patterns for user [%s] up to [%s]", len(insights), userEmail, upperBound)
+}
+
+// GetCurrentInsights returns the insights of the most recent detection for a user unless it covers a period ending
+// more than CURRENT_INSIGHTS_MAX_AGE before the most recent read. A most recent detection that found no pattern has
+// no insights.
+func GetCurrentInsights(context context.Context, email string, mostRecentRead time.Time) (insights []model.Insight, err error) {
+	since := mostRecentRead.Add(-CURRENT_INSIGHTS_MAX_AGE)
+	recentInsights, err := store.GetInsights(context, email, store.ScoreScanQuery{From: &since})
+	if err != nil {
+		return nil, err
+	}
+
+	insights = make([]model.Insight, 0)
+	for _, insight := range recentInsights {
+		if insight.UpperBound.Equal(recentInsights[0].UpperBound) && insight.Type != model.NO_PATTERN_INSIGHT {
+			insights = append(insights, insight)
+		}
+	}
+
+	return insights, nil
+}
+
+// DetectPatterns returns an insight for each pattern recurring in reads between lowerBound and upperBound. Reads are
+// looked at in their own time zone and against the targets that apply at their time of day.
+func DetectPatterns(reads []apimodel.GlucoseRead, targets model.GlucoseTargets, lowerBound time.Time, upperBound time.Time) (insights []model.Insight, err error) {
+	days, err := readsByLocalDate(reads)
+	if err != nil {
+		return nil, err
+	}
+
+	dates := make([]string, 0, len(days))
+	for date := range days {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	evidence := map[string][]model.InsightEvidence{}
+	for _, date := range dates {
+		dayReads := days[date]
+		if occurrence, ok := detectNocturnalLow(date, dayReads, targets); ok {
+			evidence[model.NOCTURNAL_HYPOGLYCEMIA_INSIGHT] = append(evidence[model.NOCTURNAL_HYPOGLYCEMIA_INSIGHT], occurrence)
+		}
+		if occurrence, ok := detectDawnPhenomenon(date, dayReads, targets); ok {
+			evidence[model.DAWN_PHENOMENON_INSIGHT] = append(evidence[model.DAWN_PHENOMENON_INSIGHT], occurrence)
+		}
+		if occurrence, ok := detectPostDinnerHigh(date, dayReads, targets); ok {
+			evidence[model.POST_DINNER_HIGH_INSIGHT] = append(evidence[model.POST_DINNER_HIGH_INSIGHT], occurrence)
+		}
+	}
+	evidence[model.REBOUND_HIGH_INSIGHT] = detectReboundHighs(days, dates, targets)
+
+	insights = make([]model.Insight, 0)
+	for _, insightType := range []string{model.NOCTURNAL_HYPOGLYCEMIA_INSIGHT, model.DAWN_PHENOMENON_INSIGHT,
+		model.POST_DINNER_HIGH_INSIGHT, model.REBOUND_HIGH_INSIGHT} {
+		occurrences := evidence[insightType]
+		if len(occurrences) < MIN_PATTERN_OCCURRENCES || float64(len(occurrences)) < MIN_PATTERN_FREQUENCY*float64(len(dates)) {
+			continue
+		}
+
+		insights = append(insights, model.Insight{Type: insightType, LowerBound: lowerBound, UpperBound: upperBound,
+			Occurrences: len(occurrences), DaysWithData: len(dates), Evidence: occurrences,
+			Message: insightMessage(insightType, occurrences, len(dates))})
+	}
+
+	return insights, nil
+}
+
+// detectNocturnalLow returns the lowest read of the night if it's below the low target
+func detectNocturnalLow(date string, dayReads []patternRead, targets model.GlucoseTargets) (occurrence model.InsightEvidence, ok bool) {
+	nightReads := NIGHT_WINDOW.filter(dayReads)
+	if len(nightReads) < MIN_PATTERN_WINDOW_READS {
+		return occurrence, false
+	}
+
+	lowest := lowestRead(nightReads)
+	if lowest.value >= targets.RangeAt(lowest.time).Low {
+		return occurrence, false
+	}
+
+	return model.InsightEvidence{Date: date, Time: lowest.time, Magnitude: lowest.value}, true
+}
+
+// detectDawnPhenomenon returns the rise from the overnight nadir to the highest read of the early morning if it's at
+// least DAWN_PHENOMENON_MIN_RISE. Nights with a low are left out since a rise after one is a rebound.
+func detectDawnPhenomenon(date string, dayReads []patternRead, targets model.GlucoseTargets) (occurrence model.InsightEvidence, ok bool) {
+	nadirReads, riseReads := DAWN_NADIR_WINDOW.filter(dayReads), DAWN_RISE_WINDOW.filter(dayReads)
+	if len(nadirReads) < MIN_PATTERN_WINDOW_READS || len(riseReads) < MIN_PATTERN_WINDOW_READS/2 {
+		return occurrence, false
+	}
+
+	nadir, highest := lowestRead(nadirReads), highestRead(riseReads)
+	if nadir.value < targets.RangeAt(nadir.time).Low || highest.value-nadir.value < DAWN_PHENOMENON_MIN_RISE {
+		return occurrence, false
+	}
+
+	return model.InsightEvidence{Date: date, Time: highest.time, Magnitude: highest.value - nadir.value}, true
+}
+
+// detectPostDinnerHigh returns the highest read of the evening if it's above the high target
+func detectPostDinnerHigh(date string, dayReads []patternRead, targets model.GlucoseTargets) (occurrence model.InsightEvidence, ok bool) {
+	eveningReads := AFTER_DINNER_WINDOW.filter(dayReads)
+	if len(eveningReads) < MIN_PATTERN_WINDOW_READS {
+		return occurrence, false
+	}
+
+	highest := highestRead(eveningReads)
+	if highest.value <= targets.RangeAt(highest.time).High {
+		return occurrence, false
+	}
+
+	return model.InsightEvidence{Date: date, Time: highest.time, Magnitude: highest.value}, true
+}
+
+// detectReboundHighs returns, for each day with one, the largest rise from a low to a high read within
+// REBOUND_MAX_DELAY of it. The day of a rebound is the day of its high.
+func detectReboundHighs(days map[string][]patternRead, dates []string, targets model.GlucoseTargets) (occurrences []model.InsightEvidence) {
+	occurrences = make([]model.InsightEvidence, 0)
+
+	var lastLow patternRead
+	hasLow := false
+	for _, date := range dates {
+		found := false
+		var occurrence model.InsightEvidence
+		for _, read := range days[date] {
+			glucoseRange := targets.RangeAt(read.time)
+			if read.value < glucoseRange.Low {
+				if !hasLow || read.time.Sub(lastLow.time) > REBOUND_MAX_DELAY || read.value < lastLow.value {
+					lastLow, hasLow = read, true
+				}
+			} else if read.value > glucoseRange.High && hasLow && read.time.Sub(lastLow.time) <= REBOUND_MAX_DELAY {
+				if rise := read.value - lastLow.value; !found || rise > occurrence.Magnitude {
+					occurrence, found = model.InsightEvidence{Date: date, Time: read.time, Magnitude: rise}, true
+				}
+			}
+		}
+
+		if found {
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+
+	return occurrences
+}
+
+// insightMessage describes a pattern for the user
+func insightMessage(insightType string, occurrences []model.InsightEvidence, daysWithData int) string {
+	lowest, highest, total := math.MaxFloat64, 0., 0.
+	for _, occurrence := range occurrences {
+		lowest, highest, total = math.Min(lowest, occurrence.Magnitude), math.Max(highest, occurrence.Magnitude), total+occurrence.Magnitude
+	}
+
+	switch insightType {
+	case model.NOCTURNAL_HYPOGLYCEMIA_INSIGHT:
+		return fmt.Sprintf("Low overnight on %d of %d days, down to %.0f mg/dL", len(occurrences), daysWithData, lowest)
+	case model.DAWN_PHENOMENON_INSIGHT:
+		return fmt.Sprintf("Rising by %.0f mg/dL on average in the early morning on %d of %d days", total/float64(len(occurrences)), len(occurrences), daysWithData)
+	case model.POST_DINNER_HIGH_INSIGHT:
+		return fmt.Sprintf("High after dinner on %d of %d days, up to %.0f mg/dL", len(occurrences), daysWithData, highest)
+	default:
+		return fmt.Sprintf("High after a low on %d of %d days, rising by up to %.0f mg/dL", len(occurrences), daysWithData, highest)
+	}
+}
+
+// readsByLocalDate groups reads, sorted by time, by the date in their time zone
+func readsByLocalDate(reads []apimodel.GlucoseRead) (days map[string][]patternRead, err error) {
+	sortedReads := make([]apimodel.GlucoseRead, len(reads))
+	copy(sortedReads, reads)
+	sort.Sort(apimodel.GlucoseReadSlice(sortedReads))
+
+	days = make(map[string][]patternRead)
+	for _, read := range sortedReads {
+		value, err := read.GetNormalizedValue(apimodel.MG_PER_DL)
+		if err != nil {
+			return nil, err
+		}
+
+		readTime := read.GetTime()
+		date := readTime.Format("2006-01-02")
+		days[date] = append(days[date], patternRead{readTime, float64(value)})
+	}
+
+	return days, nil
+}
+
+// filter returns the reads in the window
+func (window hourWindow) filter(reads []patternRead) (matching []patternRead) {
+	for _, read := range reads {
+		if hour := read.time.Hour(); hour >= window.StartHour && hour < window.EndHour {
+			matching = append(matching, read)
+		}
+	}
+
+	return matching
+}
+
+func lowestRead(reads []patternRead) (lowest patternRead) {
+	lowest = reads[0]
+	for _, read := range reads[1:] {
+		if read.value < lowest.value {
+			lowest = read
+		}
+	}
+
+	return lowest
+}
+
+func highestRead(reads []patternRead) (highest patternRead) {
+	highest = reads[0]
+	for _, read := range reads[1:] {
+		if read.value > highest.value {
+			highest = read
+		}
+	}
+
+	return highest
+}
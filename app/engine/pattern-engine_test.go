@@ -0,0 +1,187 @@
+package engine_test
+
+import (
+	"context"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/store"
+	"testing"
+	"time"
+)
+
+const (
+	PATTERNS_USER     = "patterns@glukit.com"
+	PATTERNS_TIMEZONE = "America/Montreal"
+)
+
+// patternReads returns reads every 5 minutes over days starting at local midnight in PATTERNS_TIMEZONE, each at the
+// value returned for its day and local time
+func patternReads(t *testing.T, days int, valueAt func(day int, localTime time.Time) float64) (reads []apimodel.GlucoseRead) {
+	location, err := time.LoadLocation(PATTERNS_TIMEZONE)
+	if err != nil {
+		t.Skipf("Time zone [%s] unavailable: %v", PATTERNS_TIMEZONE, err)
+	}
+
+	start := time.Date(2014, 4, 1, 0, 0, 0, 0, location)
+	for day := 0; day < days; day++ {
+		dayStart := start.AddDate(0, 0, day)
+		reads = append(reads, readsEvery(dayStart, READ_STEP, int(dayStart.AddDate(0, 0, 1).Sub(dayStart)/READ_STEP), func(i int, readTime time.Time) float64 {
+			return valueAt(day, readTime)
+		})...)
+	}
+
+	return reads
+}
+
+func TestDetectPatterns(t *testing.T) {
+	reads := patternReads(t, 10, func(day int, localTime time.Time) float64 {
+		hour := localTime.Hour()
+		switch {
+		case day < 4 && hour >= 2 && hour < 4:
+			return 55
+		case day >= 4 && day < 8 && hour < 5:
+			return 100
+		case day >= 4 && day < 8 && hour >= 5 && hour < 8:
+			return 140
+		case hour >= 20 && hour < 22:
+			return 220
+		case day >= 8 && hour == 14:
+			return 55
+		case day >= 8 && hour == 16:
+			return 200
+		default:
+			return 120
+		}
+	})
+
+	insights, err := engine.DetectPatterns(reads, model.DEFAULT_GLUCOSE_TARGETS, reads[0].GetTime(), reads[len(reads)-1].GetTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only 2 days of rebound highs aren't enough for a pattern
+	expected := []struct {
+		insightType string
+		occurrences int
+		magnitude   float64
+	}{{model.NOCTURNAL_HYPOGLYCEMIA_INSIGHT, 4, 55}, {model.DAWN_PHENOMENON_INSIGHT, 4, 40}, {model.POST_DINNER_HIGH_INSIGHT, 10, 220}}
+	if len(insights) != len(expected) {
+		t.Fatalf("Expected [%d] insights but got [%v]", len(expected), insights)
+	}
+
+	for i, insight := range insights {
+		if insight.Type != expected[i].insightType || insight.Occurrences != expected[i].occurrences || insight.DaysWithData != 10 ||
+			len(insight.Evidence) != expected[i].occurrences || insight.Evidence[0].Magnitude != expected[i].magnitude {
+			t.Errorf("Expected [%s] on [%d] of [10] days with a magnitude of [%.0f] but got [%v]", expected[i].insightType,
+				expected[i].occurrences, expected[i].magnitude, insight)
+		}
+	}
+
+	// Evidence is dated and timed in local time
+	if evidence := insights[0].Evidence[0]; evidence.Date != "2014-04-01" || evidence.Time.Hour() != 2 {
+		t.Errorf("Expected the first nocturnal low on [2014-04-01] at [2:00] local time but got [%v]", evidence)
+	}
+}
+
+func TestDetectReboundHighs(t *testing.T) {
+	reads := patternReads(t, 5, func(day int, localTime time.Time) float64 {
+		switch {
+		case day < 3 && localTime.Hour() == 10:
+			return 60
+		case day < 3 && localTime.Hour() == 12:
+			return 60 + float64(day+1)*50
+		default:
+			return 120
+		}
+	})
+
+	insights, err := engine.DetectPatterns(reads, model.DEFAULT_GLUCOSE_TARGETS, reads[0].GetTime(), reads[len(reads)-1].GetTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rises to 110 and 160 mg/dL aren't highs so there's only one rebound
+	if len(insights) != 0 {
+		t.Fatalf("Expected no insight from a single rebound high but got [%v]", insights)
+	}
+
+	reads = patternReads(t, 5, func(day int, localTime time.Time) float64 {
+		switch {
+		case day < 3 && localTime.Hour() == 10:
+			return 60
+		case day < 3 && localTime.Hour() == 12:
+			return 200 + float64(day)*10
+		default:
+			return 120
+		}
+	})
+
+	insights, err = engine.DetectPatterns(reads, model.DEFAULT_GLUCOSE_TARGETS, reads[0].GetTime(), reads[len(reads)-1].GetTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(insights) != 1 || insights[0].Type != model.REBOUND_HIGH_INSIGHT || insights[0].Occurrences != 3 || insights[0].Evidence[2].Magnitude != 160 {
+		t.Errorf("Expected rebound highs on [3] days rising by up to [160] but got [%v]", insights)
+	}
+}
+
+func TestCurrentInsightsAreFromTheMostRecentDetection(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	store.SetRepository(store.NewMemoryRepository())
+	c := context.Background()
+
+	mostRecentRead := time.Date(2014, 6, 1, 0, 0, 0, 0, time.UTC)
+	older := []model.Insight{{Type: model.POST_DINNER_HIGH_INSIGHT, UpperBound: mostRecentRead.AddDate(0, 0, -7)},
+		{Type: model.DAWN_PHENOMENON_INSIGHT, UpperBound: mostRecentRead.AddDate(0, 0, -7)}}
+	latest := []model.Insight{{Type: model.POST_DINNER_HIGH_INSIGHT, UpperBound: mostRecentRead.Add(-time.Hour)}}
+	for _, insights := range [][]model.Insight{older, latest} {
+		if err := store.StoreInsights(c, PATTERNS_USER, insights); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	current, err := engine.GetCurrentInsights(c, PATTERNS_USER, mostRecentRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(current) != 1 || !current[0].UpperBound.Equal(latest[0].UpperBound) {
+		t.Errorf("Expected only the insight of the most recent detection but got [%v]", current)
+	}
+
+	// A detection that's too old isn't current anymore
+	current, err = engine.GetCurrentInsights(c, PATTERNS_USER, mostRecentRead.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(current) != 0 {
+		t.Errorf("Expected no current insights a month after the last detection but got [%v]", current)
+	}
+}
+
+func TestDetectionWithoutPatternsClearsCurrentInsights(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c := context.Background()
+	upperDate := time.Date(2014, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	storeDaysOfFixedReads(t, c, 120, 7, upperDate)
+	mostRecentRead := upperDate.Add(-5 * time.Minute)
+	previous := []model.Insight{{Type: model.POST_DINNER_HIGH_INSIGHT, UpperBound: mostRecentRead.AddDate(0, 0, -7)}}
+	if err := store.StoreInsights(c, TEST_USER, previous); err != nil {
+		t.Fatal(err)
+	}
+
+	engine.RunPatternDetection(c, TEST_USER)
+
+	current, err := engine.GetCurrentInsights(c, TEST_USER, mostRecentRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(current) != 0 {
+		t.Errorf("Expected no current insights after a detection without patterns but got [%v]", current)
+	}
+}
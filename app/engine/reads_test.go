@@ -0,0 +1,37 @@
+package engine_test
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"time"
+)
+
+// Interval between two reads of a sensor
+const READ_STEP = 5 * time.Minute
+
+// readsEvery returns n reads, step apart from start, each at the value returned for its index and time. Reads are in
+// the location of start.
+func readsEvery(start time.Time, step time.Duration, n int, valueAt func(i int, readTime time.Time) float64) (reads []apimodel.GlucoseRead) {
+	reads = make([]apimodel.GlucoseRead, n)
+	for i := range reads {
+		readTime := start.Add(time.Duration(i) * step)
+		reads[i] = newLocalRead(readTime, float32(valueAt(i, readTime)))
+	}
+
+	return reads
+}
+
+// readsOfValues returns reads with the given values, READ_STEP apart from start
+func readsOfValues(start time.Time, values ...float32) []apimodel.GlucoseRead {
+	return readsEvery(start, READ_STEP, len(values), func(i int, readTime time.Time) float64 {
+		return float64(values[i])
+	})
+}
+
+// readsBetween returns reads every READ_STEP from start until, and including, end
+func readsBetween(start time.Time, end time.Time, valueAt func(i int, readTime time.Time) float64) []apimodel.GlucoseRead {
+	return readsEvery(start, READ_STEP, int(end.Sub(start)/READ_STEP)+1, valueAt)
+}
+
+func newLocalRead(readTime time.Time, value float32) apimodel.GlucoseRead {
+	return apimodel.GlucoseRead{Time: apimodel.Time{apimodel.GetTimeMillis(readTime), readTime.Location().String()}, Unit: apimodel.MG_PER_DL, Value: value}
+}
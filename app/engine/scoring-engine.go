@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/model"
+	"sync"
+)
+
+// ScoringStrategy is an algorithm to calculate GlukitScores. A score is the sum of the weights of the reads of its
+// period so a read's weight should grow with how far it is from perfection. Each strategy has its own version which
+// is kept as the ScoringVersion of the scores it calculates so that older scores can be recalculated.
+type ScoringStrategy interface {
+	// Version returns the scoring version of the strategy, unique amongst registered strategies
+	Version() int
+	// ReadWeight returns the contribution of a single read to a GlukitScore given the user's glucose range at the
+	// time of the read
+	ReadWeight(read apimodel.GlucoseRead, glucoseRange model.GlucoseRange) (weight float64, err error)
+}
+
+// DeviationScoringStrategy weighs a read by its deviation from a fixed target value (in mg/dL), deviations on the
+// low side being multiplied by LowMultiplier and those on the high side by HighMultiplier
+type DeviationScoringStrategy struct {
+	ScoringVersion int
+	Target         float64
+	LowMultiplier  float64
+	HighMultiplier float64
+}
+
+// TargetDeviationScoringStrategy weighs a read like the DeviationScoringStrategy but with the deviation from the
+// user's own target at the time of the read
+type TargetDeviationScoringStrategy struct {
+	ScoringVersion int
+	LowMultiplier  float64
+	HighMultiplier float64
+}
+
+var ErrUnknownScoringVersion = errors.New("Unknown scoring version")
+
+var scoringStrategies = make(map[int]ScoringStrategy)
+var scoringStrategiesLock sync.RWMutex
+
+func init() {
+	// The original Glukit score: a deviation from 83 weighted by whether it's high (multiplier of 2) or low
+	// (multiplier of 1)
+	RegisterScoringStrategy(DeviationScoringStrategy{1, model.TARGET_GLUCOSE_VALUE, LOW_MULTIPLIER, HIGH_MULTIPLIER})
+	// The same weights around each user's own target
+	RegisterScoringStrategy(TargetDeviationScoringStrategy{2, LOW_MULTIPLIER, HIGH_MULTIPLIER})
+}
+
+func (strategy DeviationScoringStrategy) Version() int {
+	return strategy.ScoringVersion
+}
+
+// ReadWeight is either 0 if the read is straight on target or its weighted deviation from the target
+func (strategy DeviationScoringStrategy) ReadWeight(read apimodel.GlucoseRead, glucoseRange model.GlucoseRange) (weight float64, err error) {
+	return weighDeviation(read, strategy.Target, strategy.LowMultiplier, strategy.HighMultiplier)
+}
+
+func (strategy TargetDeviationScoringStrategy) Version() int {
+	return strategy.ScoringVersion
+}
+
+// ReadWeight is either 0 if the read is straight on the user's target or its weighted deviation from that target
+func (strategy TargetDeviationScoringStrategy) ReadWeight(read apimodel.GlucoseRead, glucoseRange model.GlucoseRange) (weight float64, err error) {
+	return weighDeviation(read, glucoseRange.Target, strategy.LowMultiplier, strategy.HighMultiplier)
+}
+
+// weighDeviation returns the deviation of a read from the target, in mg/dL, multiplied by the low or high multiplier
+// depending on the side of the target the read is on
+func weighDeviation(read apimodel.GlucoseRead, target float64, lowMultiplier float64, highMultiplier float64) (weight float64, err error) {
+	convertedValue, err := read.GetNormalizedValue(apimodel.MG_PER_DL)
+	if err != nil {
+		return 0., err
+	}
+	value := float64(convertedValue)
+
+	if value > target {
+		return (value - target) * highMultiplier, nil
+	} else if value < target {
+		return (target - value) * lowMultiplier, nil
+	}
+
+	return 0., nil
+}
+
+// RegisterScoringStrategy makes a scoring strategy available under its version. Registering two strategies with the
+// same version is a programming error so it panics.
+func RegisterScoringStrategy(strategy ScoringStrategy) {
+	scoringStrategiesLock.Lock()
+	defer scoringStrategiesLock.Unlock()
+
+	if _, exists := scoringStrategies[strategy.Version()]; exists {
+		panic(fmt.Sprintf("A scoring strategy is already registered for version [%d]", strategy.Version()))
+	}
+
+	scoringStrategies[strategy.Version()] = strategy
+}
+
+// GetScoringStrategy returns the scoring strategy registered for the given version or ErrUnknownScoringVersion
+func GetScoringStrategy(version int) (strategy ScoringStrategy, err error) {
+	scoringStrategiesLock.RLock()
+	defer scoringStrategiesLock.RUnlock()
+
+	strategy, exists := scoringStrategies[version]
+	if !exists {
+		return nil, ErrUnknownScoringVersion
+	}
+
+	return strategy, nil
+}
+
+// CurrentScoringStrategy returns the strategy used to calculate new scores, the one registered for SCORING_VERSION
+func CurrentScoringStrategy() (strategy ScoringStrategy) {
+	strategy, err := GetScoringStrategy(SCORING_VERSION)
+	if err != nil {
+		panic(fmt.Sprintf("No scoring strategy registered for the current scoring version [%d]", SCORING_VERSION))
+	}
+
+	return strategy
+}
@@ -0,0 +1,222 @@
+package engine_test
+
+import (
+	"context"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/tasks"
+	"github.com/alexandre-normand/glukit/app/util"
+	"testing"
+	"time"
+)
+
+const SCORING_USER = "scoring@glukit.com"
+
+type constantScoringStrategy struct {
+	version int
+	weight  float64
+}
+
+func (strategy constantScoringStrategy) Version() int {
+	return strategy.version
+}
+
+func (strategy constantScoringStrategy) ReadWeight(read apimodel.GlucoseRead, glucoseRange model.GlucoseRange) (weight float64, err error) {
+	return strategy.weight, nil
+}
+
+func TestDeviationScoringStrategyWeights(t *testing.T) {
+	strategy := engine.DeviationScoringStrategy{1, 83, 1, 2}
+	for _, testCase := range []struct {
+		value          float32
+		expectedWeight float64
+	}{{83, 0}, {100, 34}, {70, 13}} {
+		weight, err := strategy.ReadWeight(apimodel.GlucoseRead{apimodel.Time{0, "UTC"}, apimodel.MG_PER_DL, testCase.value}, model.GlucoseRange{54, 70, 120, 180, 250})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if weight != testCase.expectedWeight {
+			t.Errorf("Expected weight of [%f] for [%f] but got [%f]", testCase.expectedWeight, testCase.value, weight)
+		}
+	}
+}
+
+func TestTargetDeviationScoringStrategyUsesUserTarget(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c, user, upperBound := setupScoringData(t)
+
+	user.Targets = model.GlucoseTargets{Default: model.GlucoseRange{54, 70, 120, 180, 250}}
+	strategy := engine.TargetDeviationScoringStrategy{2, 1, 2}
+
+	score, err := engine.CalculateGlukitScoreWithStrategy(c, user, upperBound, strategy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every read is at 100 mg/dL, 20 under the target
+	if score.Value != 20*engine.READS_REQUIREMENT {
+		t.Errorf("Expected score of [%d] but got [%v]", 20*engine.READS_REQUIREMENT, score)
+	}
+}
+
+func TestCurrentScoringStrategyIsRegistered(t *testing.T) {
+	if engine.CurrentScoringStrategy().Version() != engine.SCORING_VERSION {
+		t.Errorf("Expected current scoring strategy of version [%d] but got [%d]", engine.SCORING_VERSION, engine.CurrentScoringStrategy().Version())
+	}
+
+	if _, err := engine.GetScoringStrategy(-1); err != engine.ErrUnknownScoringVersion {
+		t.Errorf("Expected unknown scoring version error but got [%v]", err)
+	}
+}
+
+func TestDuplicateScoringVersionPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected registration of a duplicate scoring version to panic")
+		}
+	}()
+
+	engine.RegisterScoringStrategy(constantScoringStrategy{engine.SCORING_VERSION, 0})
+}
+
+func TestGlukitScoreWithStrategy(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c, user, upperBound := setupScoringData(t)
+
+	engine.RegisterScoringStrategy(constantScoringStrategy{100, 2})
+	strategy, err := engine.GetScoringStrategy(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	score, err := engine.CalculateGlukitScoreWithStrategy(c, user, upperBound, strategy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if score.Value != 2*engine.READS_REQUIREMENT || score.ScoringVersion != 100 {
+		t.Errorf("Expected score of [%d] with version [100] but got [%v]", 2*engine.READS_REQUIREMENT, score)
+	}
+}
+
+func TestGlukitScoreMigration(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c, user, upperBound := setupScoringData(t)
+
+	outdatedScore := model.GlukitScore{Value: 10, LowerBound: upperBound.AddDate(0, 0, -engine.GLUKIT_SCORE_PERIOD), UpperBound: upperBound, CalculatedOn: upperBound, ScoringVersion: 0}
+	if err := store.StoreGlukitScoreBatch(c, SCORING_USER, []model.GlukitScore{outdatedScore}); err != nil {
+		t.Fatal(err)
+	}
+
+	user.BestScore = outdatedScore
+	user.MostRecentScore = outdatedScore
+	if err := store.StoreUserProfile(c, time.Now(), *user); err != nil {
+		t.Fatal(err)
+	}
+
+	engine.RunGlukitScoreMigration(c, SCORING_USER, util.GLUKIT_EPOCH_TIME)
+
+	outdatedScores, err := store.FindOutdatedGlukitScores(c, SCORING_USER, engine.SCORING_VERSION, util.GLUKIT_EPOCH_TIME, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(outdatedScores) != 0 {
+		t.Errorf("Expected all scores migrated but got outdated scores [%v]", outdatedScores)
+	}
+
+	// Every read is at 100 mg/dL which is a weight of 34 with the default target
+	expectedValue := int64(34 * engine.READS_REQUIREMENT)
+	migratedUser, err := store.GetUserProfile(c, SCORING_USER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if migratedUser.BestScore.Value != expectedValue || migratedUser.BestScore.ScoringVersion != engine.SCORING_VERSION {
+		t.Errorf("Expected best score of [%d] with version [%d] but got [%v]", expectedValue, engine.SCORING_VERSION, migratedUser.BestScore)
+	}
+
+	if migratedUser.MostRecentScore.Value != expectedValue || !migratedUser.MostRecentScore.UpperBound.Equal(upperBound) {
+		t.Errorf("Expected most recent score of [%d] at [%s] but got [%v]", expectedValue, upperBound, migratedUser.MostRecentScore)
+	}
+}
+
+func TestGlukitScoreMigrationGoesPastScoresThatCantBeRecalculated(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c, _, upperBound := setupScoringData(t)
+
+	runner := tasks.NewInProcessRunner(engine.BATCH_CALCULATION_QUEUE_NAME)
+	defer runner.Close()
+	tasks.SetRunner(runner)
+	defer tasks.SetRunner(tasks.AppEngineRunner{})
+
+	migrationChunk := engine.RunGlukitScoreMigrationChunk
+	engine.RunGlukitScoreMigrationChunk = tasks.Func(engine.GLUKIT_SCORE_MIGRATION_FUNCTION_NAME, engine.RunGlukitScoreMigration)
+	defer func() { engine.RunGlukitScoreMigrationChunk = migrationChunk }()
+
+	// More than a batch of scores without any reads left to recalculate them come before the one that can be
+	scores := make([]model.GlukitScore, 0)
+	for week := 2*engine.SCORES_PER_MIGRATION_BATCH + 1; week > 0; week-- {
+		scoreUpperBound := upperBound.AddDate(0, 0, -7*week)
+		scores = append(scores, model.GlukitScore{Value: 10, LowerBound: scoreUpperBound.AddDate(0, 0, -engine.GLUKIT_SCORE_PERIOD), UpperBound: scoreUpperBound, CalculatedOn: scoreUpperBound, ScoringVersion: 0})
+	}
+	scores = append(scores, model.GlukitScore{Value: 10, LowerBound: upperBound.AddDate(0, 0, -engine.GLUKIT_SCORE_PERIOD), UpperBound: upperBound, CalculatedOn: upperBound, ScoringVersion: 0})
+	if err := store.StoreGlukitScoreBatch(c, SCORING_USER, scores); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := engine.RunGlukitScoreMigrationChunk.Add(c, engine.BATCH_CALCULATION_QUEUE_NAME, SCORING_USER, util.GLUKIT_EPOCH_TIME); err != nil {
+		t.Fatal(err)
+	}
+	runner.Wait()
+
+	outdatedScores, err := store.FindOutdatedGlukitScores(c, SCORING_USER, engine.SCORING_VERSION, util.GLUKIT_EPOCH_TIME, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(outdatedScores) != len(scores)-1 {
+		t.Errorf("Expected only the [%d] scores without reads to stay outdated but got [%d]", len(scores)-1, len(outdatedScores))
+	}
+
+	migratedUser, err := store.GetUserProfile(c, SCORING_USER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if migratedUser.MostRecentScore.ScoringVersion != engine.SCORING_VERSION || !migratedUser.MostRecentScore.UpperBound.Equal(upperBound) {
+		t.Errorf("Expected the most recent score to be migrated and the user refreshed but got [%v]", migratedUser.MostRecentScore)
+	}
+}
+
+// setupScoringData stores a user with a full scoring period of reads at 100 mg/dL and returns the upper bound of that
+// period
+func setupScoringData(t *testing.T) (c context.Context, user *model.GlukitUser, upperBound time.Time) {
+	store.SetRepository(store.NewMemoryRepository())
+	c = context.Background()
+
+	user = &model.GlukitUser{SCORING_USER, "", "", time.Now(),
+		model.DIABETES_TYPE_1, "", util.GLUKIT_EPOCH_TIME, apimodel.UNDEFINED_GLUCOSE_READ,
+		model.UNDEFINED_SCORE, model.UNDEFINED_SCORE, false, "", time.Now(), model.UNDEFINED_A1C_ESTIMATE, model.DEFAULT_GLUCOSE_TARGETS, model.A1C_FORMULA_ADAG, 0}
+	if err := store.StoreUserProfile(c, time.Now(), *user); err != nil {
+		t.Fatal(err)
+	}
+
+	upperBound = time.Date(2014, 4, 18, 0, 0, 0, 0, time.UTC)
+	days := make([]apimodel.DayOfGlucoseReads, 0)
+	for dayStart := upperBound.AddDate(0, 0, -engine.GLUKIT_SCORE_PERIOD); dayStart.Before(upperBound); dayStart = dayStart.AddDate(0, 0, 1) {
+		reads := readsEvery(dayStart, READ_STEP, 288, func(i int, readTime time.Time) float64 {
+			return 100
+		})
+		days = append(days, apimodel.NewDayOfGlucoseReads(reads))
+	}
+
+	if err := store.StoreDaysOfReads(c, SCORING_USER, days); err != nil {
+		t.Fatal(err)
+	}
+
+	return c, user, upperBound
+}
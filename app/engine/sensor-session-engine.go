@@ -0,0 +1,344 @@
+package engine
+
+import (
+	"context"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/util"
+	"sort"
+	"time"
+)
+
+const (
+	// Time from the insertion of a sensor to its first reads
+	SENSOR_WARM_UP_DURATION = 2 * time.Hour
+	// Time a sensor is approved to be worn for
+	SENSOR_LIFETIME = 7 * 24 * time.Hour
+	// Number of calibrations a new sensor needs, within SENSOR_START_UP_WINDOW of its first read, to end its warm-up
+	SENSOR_START_UP_CALIBRATIONS = 2
+	SENSOR_START_UP_WINDOW       = 12 * time.Hour
+	// Days of reads sessions are inferred from for users without any session yet
+	SENSOR_SESSION_INFERENCE_DAYS = 90
+	// Days of reads in each batch of sensor session inference
+	SENSOR_SESSION_DAYS_PER_BATCH = 30
+	// Default number of days of sensor session statistics
+	SENSOR_SESSION_STATISTICS_DAYS = 30
+)
+
+// StartSensorSessionInference kicks off the inference of the sensor sessions of a user from the first read of the most
+// recent session, which might have gone on since, or from the last SENSOR_SESSION_INFERENCE_DAYS of reads if there is
+// no session yet
+func StartSensorSessionInference(context context.Context, glukitUser *model.GlukitUser) (err error) {
+	if glukitUser.MostRecentRead.Time.Timestamp == 0 {
+		return nil
+	}
+
+	limit := 1
+	sessions, err := store.GetSensorSessions(context, glukitUser.Email, store.ScoreScanQuery{Limit: &limit})
+	if err != nil {
+		return err
+	}
+
+	lowerBound := glukitUser.MostRecentRead.GetTime().AddDate(0, 0, -1*SENSOR_SESSION_INFERENCE_DAYS)
+	if len(sessions) > 0 {
+		lowerBound = firstSessionRead(sessions[0])
+	}
+
+	if err = RunSensorSessionInferenceChunk.Add(context, BATCH_CALCULATION_QUEUE_NAME, glukitUser.Email, lowerBound); err != nil {
+		return err
+	}
+
+	log.Infof(context, "Queued up sensor session inference for user [%s] from [%s]", glukitUser.Email, lowerBound)
+	return nil
+}
+
+// RunSensorSessionInference infers the sensor sessions from the SENSOR_SESSION_DAYS_PER_BATCH days of reads and
+// calibrations starting at lowerBound, stores those that don't overlap a recorded session and schedules the next
+// batch. The last session of a batch might go on in the next one so it's inferred again from there.
+func RunSensorSessionInference(context context.Context, userEmail string, lowerBound time.Time) {
+	glukitUser, err := store.GetUserProfile(context, userEmail)
+	if err != nil {
+		log.Errorf(context, "Error getting profile of user [%s] for sensor session inference: %v", userEmail, err)
+		return
+	}
+
+	if skipAccountPendingDeletion(context, userEmail, "sensor session inference") {
+		return
+	}
+
+	mostRecentRead := glukitUser.MostRecentRead.GetTime()
+	batchEnd := lowerBound.AddDate(0, 0, SENSOR_SESSION_DAYS_PER_BATCH)
+	if batchEnd.After(mostRecentRead) {
+		batchEnd = mostRecentRead
+	}
+
+	reads, err := store.GetGlucoseReads(context, userEmail, lowerBound, batchEnd)
+	if err != nil {
+		log.Errorf(context, "Error getting reads of user [%s] for sensor session inference: %v", userEmail, err)
+		return
+	}
+
+	calibrations, err := store.GetCalibrations(context, userEmail, lowerBound, batchEnd)
+	if err != nil {
+		log.Errorf(context, "Error getting calibrations of user [%s] for sensor session inference: %v", userEmail, err)
+		return
+	}
+
+	inferred := InferSensorSessions(reads, calibrations)
+	nextLowerBound := batchEnd
+	if batchEnd.Before(mostRecentRead) && len(inferred) > 1 {
+		nextLowerBound = firstSessionRead(inferred[len(inferred)-1])
+		inferred = inferred[:len(inferred)-1]
+	}
+
+	stored, err := GetSensorSessions(context, userEmail, lowerBound, nextLowerBound)
+	if err != nil {
+		log.Errorf(context, "Error getting sensor sessions of user [%s]: %v", userEmail, err)
+		return
+	}
+
+	sessions := make([]model.SensorSession, 0)
+	for _, session := range MergeSensorSessions(append(stored, inferred...)) {
+		if session.Source == model.INFERRED_SENSOR_SESSION {
+			sessions = append(sessions, session)
+		}
+	}
+
+	if err := store.StoreSensorSessions(context, userEmail, sessions); err != nil {
+		log.Errorf(context, "Error storing sensor sessions of user [%s]: %v", userEmail, err)
+		return
+	}
+
+	if batchEnd.Before(mostRecentRead) {
+		if err := RunSensorSessionInferenceChunk.Add(context, BATCH_CALCULATION_QUEUE_NAME, userEmail, nextLowerBound); err != nil {
+			log.Criticalf(context, "Couldn't schedule the next execution of [%s] for user [%s]. "+
+				"This breaks sensor session inference for that user!: %v", SENSOR_SESSION_INFERENCE_FUNCTION_NAME, userEmail, err)
+		}
+
+		log.Infof(context, "Queued up next chunk of sensor session inference for user [%s] and lowerBound [%s]", userEmail, nextLowerBound.Format(util.TIMEFORMAT))
+	} else {
+		log.Infof(context, "Done with sensor session inference for user [%s]", userEmail)
+	}
+}
+
+// InferSensorSessions returns a session, sorted by insertion time, for every run of reads without a gap as long as the
+// warm-up of a sensor. A sensor is assumed to be inserted SENSOR_WARM_UP_DURATION before its first read and its warm-up
+// to last until its first read or, if later, its second start-up calibration.
+func InferSensorSessions(reads []apimodel.GlucoseRead, calibrations []apimodel.CalibrationRead) (sessions []model.SensorSession) {
+	sortedReads := make([]apimodel.GlucoseRead, len(reads))
+	copy(sortedReads, reads)
+	sort.Sort(apimodel.GlucoseReadSlice(sortedReads))
+
+	sortedCalibrations := make([]apimodel.CalibrationRead, len(calibrations))
+	copy(sortedCalibrations, calibrations)
+	sort.Sort(apimodel.CalibrationReadSlice(sortedCalibrations))
+
+	sessions = make([]model.SensorSession, 0)
+	for i, read := range sortedReads {
+		readTime := read.GetTime()
+		if i == 0 || readTime.Sub(sortedReads[i-1].GetTime()) >= MIN_SENSOR_WARM_UP_GAP {
+			insertedOn := readTime.Add(-SENSOR_WARM_UP_DURATION)
+			sessions = append(sessions, model.SensorSession{InsertedOn: insertedOn, WarmUpEnd: readTime,
+				ExpiresOn: insertedOn.Add(SENSOR_LIFETIME), EndedOn: readTime, Source: model.INFERRED_SENSOR_SESSION})
+		} else {
+			sessions[len(sessions)-1].EndedOn = readTime
+		}
+	}
+
+	for i, session := range sessions {
+		firstRead := session.WarmUpEnd
+		startUpCalibrations := 0
+		for _, calibration := range sortedCalibrations {
+			calibrationTime := calibration.GetTime()
+			if calibrationTime.Before(session.InsertedOn) || calibrationTime.After(session.EndedOn) ||
+				calibrationTime.After(firstRead.Add(SENSOR_START_UP_WINDOW)) {
+				continue
+			}
+
+			startUpCalibrations++
+			if startUpCalibrations == SENSOR_START_UP_CALIBRATIONS {
+				if calibrationTime.After(firstRead) {
+					sessions[i].WarmUpEnd = calibrationTime
+				}
+				break
+			}
+		}
+	}
+
+	return sessions
+}
+
+// firstSessionRead returns the time of the first read of a session, assuming it followed a typical warm-up. Inferring
+// sessions again from there gives the same insertion time without picking up the last reads of the previous sensor.
+func firstSessionRead(session model.SensorSession) time.Time {
+	return session.InsertedOn.Add(SENSOR_WARM_UP_DURATION)
+}
+
+// CompleteSensorSession returns a recorded session with its warm-up end and expiry defaulting to those of a typical
+// sensor if they're missing
+func CompleteSensorSession(session model.SensorSession) (completed model.SensorSession) {
+	completed = session
+	completed.Source = model.RECORDED_SENSOR_SESSION
+	if completed.WarmUpEnd.IsZero() {
+		completed.WarmUpEnd = completed.InsertedOn.Add(SENSOR_WARM_UP_DURATION)
+	}
+	if completed.ExpiresOn.IsZero() {
+		completed.ExpiresOn = completed.InsertedOn.Add(SENSOR_LIFETIME)
+	}
+
+	return completed
+}
+
+// MergeSensorSessions returns the sessions sorted by insertion time, leaving out the inferred sessions that overlap a
+// recorded one since what users record is what actually happened
+func MergeSensorSessions(sessions []model.SensorSession) (merged []model.SensorSession) {
+	merged = make([]model.SensorSession, 0, len(sessions))
+	for _, session := range sessions {
+		overlapsRecorded := false
+		for _, other := range sessions {
+			if session.Source == model.INFERRED_SENSOR_SESSION && other.Source == model.RECORDED_SENSOR_SESSION && session.Overlaps(other) {
+				overlapsRecorded = true
+				break
+			}
+		}
+
+		if !overlapsRecorded {
+			merged = append(merged, session)
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool { return merged[i].InsertedOn.Before(merged[j].InsertedOn) })
+	return merged
+}
+
+// GetSensorSessions returns the merged sessions of a user, sorted by insertion time, that were worn at some point
+// between lowerBound and upperBound
+func GetSensorSessions(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (sessions []model.SensorSession, err error) {
+	scanStart := lowerBound.Add(-model.MAX_SENSOR_SESSION_DURATION)
+	stored, err := store.GetSensorSessions(context, email, store.ScoreScanQuery{From: &scanStart, To: &upperBound})
+	if err != nil {
+		return nil, err
+	}
+
+	sessions = make([]model.SensorSession, 0, len(stored))
+	for _, session := range stored {
+		if session.IsOngoing() || !session.EndedOn.Before(lowerBound) {
+			sessions = append(sessions, session)
+		}
+	}
+
+	return MergeSensorSessions(sessions), nil
+}
+
+// ResolveSensorSessions returns the sessions of a user worn between lowerBound and upperBound or, if none were
+// recorded or inferred yet, those inferred from the given reads and calibrations
+func ResolveSensorSessions(context context.Context, email string, lowerBound time.Time, upperBound time.Time, reads []apimodel.GlucoseRead, calibrations []apimodel.CalibrationRead) (sessions []model.SensorSession, err error) {
+	if sessions, err = GetSensorSessions(context, email, lowerBound, upperBound); err != nil || len(sessions) > 0 {
+		return sessions, err
+	}
+
+	return InferSensorSessions(reads, calibrations), nil
+}
+
+// ExcludeWarmUps returns the reads that weren't read by a sensor warming up. Reads of a sensor still worn while the next
+// one was inserted are kept.
+func ExcludeWarmUps(reads []apimodel.GlucoseRead, sessions []model.SensorSession) (filtered []apimodel.GlucoseRead) {
+	if len(sessions) == 0 {
+		return reads
+	}
+
+	filtered = make([]apimodel.GlucoseRead, 0, len(reads))
+	for _, read := range reads {
+		readTime := read.GetTime()
+		warmingUp, worn := false, false
+		for _, session := range sessions {
+			if session.IsWarmingUp(readTime) {
+				warmingUp = true
+			} else if session.Contains(readTime) {
+				worn = true
+				break
+			}
+		}
+
+		if !warmingUp || worn {
+			filtered = append(filtered, read)
+		}
+	}
+
+	return filtered
+}
+
+// AttributeGapsToSessions refines the causes of gaps with what's known of the sensor sessions. A gap overlapping the
+// warm-up of a sensor, from its insertion, is that warm-up unless it's longer than the warm-up plus
+// MIN_SENSOR_WARM_UP_GAP to swap sensors while a gap during a session, after its warm-up, is a signal loss no matter
+// how long it is.
+func AttributeGapsToSessions(coverage model.DataCoverage, sessions []model.SensorSession) (attributed model.DataCoverage) {
+	attributed = coverage
+	attributed.Gaps = make([]model.DataGap, len(coverage.Gaps))
+	for i, gap := range coverage.Gaps {
+		attributed.Gaps[i] = gap
+		for _, session := range sessions {
+			warmUp := session.WarmUpEnd.Sub(session.InsertedOn)
+			if !gap.Start.After(session.WarmUpEnd) && !gap.End.Before(session.InsertedOn) && gap.End.Sub(gap.Start) <= warmUp+MIN_SENSOR_WARM_UP_GAP {
+				attributed.Gaps[i].Cause = model.SENSOR_WARM_UP_GAP
+				break
+			}
+
+			if !gap.Start.Before(session.WarmUpEnd) && session.Contains(gap.End) {
+				attributed.Gaps[i].Cause = model.SIGNAL_LOSS_GAP
+				break
+			}
+		}
+	}
+
+	return attributed
+}
+
+// CalculateSensorSessionStatistics returns the statistics of the reads of a session after its warm-up, up to its end
+// or upperBound if it's ongoing, and of the calibrations entered during the session. Reads and calibrations outside
+// of the session are ignored.
+func CalculateSensorSessionStatistics(session model.SensorSession, reads []apimodel.GlucoseRead, calibrations []apimodel.CalibrationRead, upperBound time.Time) (statistics model.SensorSessionStatistics, err error) {
+	sessionEnd := session.EndedOn
+	if session.IsOngoing() || sessionEnd.After(upperBound) {
+		sessionEnd = upperBound
+	}
+
+	sessionReads := make([]apimodel.GlucoseRead, 0)
+	total := 0.
+	for _, read := range reads {
+		if readTime := read.GetTime(); readTime.Before(session.WarmUpEnd) || readTime.After(sessionEnd) {
+			continue
+		}
+
+		value, err := read.GetNormalizedValue(apimodel.MG_PER_DL)
+		if err != nil {
+			return statistics, err
+		}
+
+		sessionReads = append(sessionReads, read)
+		total += float64(value)
+	}
+	sort.Sort(apimodel.GlucoseReadSlice(sessionReads))
+
+	sessionCalibrations := make([]apimodel.CalibrationRead, 0)
+	for _, calibration := range calibrations {
+		if calibrationTime := calibration.GetTime(); !calibrationTime.Before(session.InsertedOn) && !calibrationTime.After(sessionEnd) {
+			sessionCalibrations = append(sessionCalibrations, calibration)
+		}
+	}
+
+	pairs, err := PairCalibrations(sessionReads, sessionCalibrations)
+	if err != nil {
+		return statistics, err
+	}
+
+	statistics = model.SensorSessionStatistics{Session: session, ReadCount: len(sessionReads),
+		Coverage: AnalyzeCoverage(sessionReads, session.WarmUpEnd, sessionEnd).Percentage, Accuracy: summarizeAccuracy(pairs)}
+	if len(sessionReads) > 0 {
+		statistics.Average = total / float64(len(sessionReads))
+	}
+
+	return statistics, nil
+}
@@ -0,0 +1,105 @@
+package engine_test
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/model"
+	"math"
+	"testing"
+	"time"
+)
+
+func minutesAfter(minutes int) time.Time {
+	return accuracyStart.Add(time.Duration(minutes) * time.Minute)
+}
+
+func TestInferSensorSessions(t *testing.T) {
+	// The second start-up calibration of the second sensor comes 40 minutes after its first read
+	sessions := engine.InferSensorSessions(twoSessionReads(), []apimodel.CalibrationRead{calibrationAt(1570, 110), calibrationAt(1600, 100)})
+
+	expected := []model.SensorSession{
+		{minutesAfter(-120), minutesAfter(0), minutesAfter(-120).Add(engine.SENSOR_LIFETIME), minutesAfter(1440), "", model.INFERRED_SENSOR_SESSION},
+		{minutesAfter(1440), minutesAfter(1600), minutesAfter(1440).Add(engine.SENSOR_LIFETIME), minutesAfter(2880), "", model.INFERRED_SENSOR_SESSION}}
+	if len(sessions) != len(expected) {
+		t.Fatalf("Expected [%d] sessions but got [%v]", len(expected), sessions)
+	}
+
+	for i, session := range sessions {
+		if !session.InsertedOn.Equal(expected[i].InsertedOn) || !session.WarmUpEnd.Equal(expected[i].WarmUpEnd) ||
+			!session.ExpiresOn.Equal(expected[i].ExpiresOn) || !session.EndedOn.Equal(expected[i].EndedOn) || session.Source != expected[i].Source {
+			t.Errorf("Expected session [%d] to be [%v] but got [%v]", i, expected[i], session)
+		}
+	}
+}
+
+func TestMergeSensorSessions(t *testing.T) {
+	inferred := engine.InferSensorSessions(twoSessionReads(), nil)
+	recorded := engine.CompleteSensorSession(model.SensorSession{InsertedOn: minutesAfter(1450)})
+
+	merged := engine.MergeSensorSessions([]model.SensorSession{inferred[1], recorded, inferred[0]})
+	if len(merged) != 2 || merged[0].Source != model.INFERRED_SENSOR_SESSION || merged[1].Source != model.RECORDED_SENSOR_SESSION {
+		t.Fatalf("Expected the first inferred session followed by the recorded one but got [%v]", merged)
+	}
+
+	if !merged[1].WarmUpEnd.Equal(minutesAfter(1570)) || !merged[1].IsOngoing() {
+		t.Errorf("Expected the recorded session to be ongoing with a default warm-up but got [%v]", merged[1])
+	}
+}
+
+func TestExcludeWarmUps(t *testing.T) {
+	reads := twoSessionReads()
+	sessions := engine.InferSensorSessions(reads, []apimodel.CalibrationRead{calibrationAt(1570, 110), calibrationAt(1600, 100)})
+
+	// The 8 reads from 1560 until the second start-up calibration at 1600 are excluded but not the last read of the
+	// first sensor, at the insertion of the second one
+	filtered := engine.ExcludeWarmUps(reads, sessions)
+	if len(filtered) != len(reads)-8 {
+		t.Errorf("Expected [%d] reads after the warm-ups but got [%d]", len(reads)-8, len(filtered))
+	}
+
+	if unfiltered := engine.ExcludeWarmUps(reads, nil); len(unfiltered) != len(reads) {
+		t.Errorf("Expected all [%d] reads without sessions but got [%d]", len(reads), len(unfiltered))
+	}
+}
+
+func TestAttributeGapsToSessions(t *testing.T) {
+	sessions := engine.InferSensorSessions(twoSessionReads(), []apimodel.CalibrationRead{calibrationAt(1570, 110), calibrationAt(1600, 100)})
+	coverage := model.DataCoverage{Gaps: []model.DataGap{
+		{minutesAfter(1440), minutesAfter(1560), model.NO_DATA_GAP},
+		{minutesAfter(1570), minutesAfter(1590), model.SIGNAL_LOSS_GAP},
+		{minutesAfter(2000), minutesAfter(2300), model.NO_DATA_GAP},
+		{minutesAfter(3000), minutesAfter(3300), model.NO_DATA_GAP}}}
+
+	attributed := engine.AttributeGapsToSessions(coverage, sessions)
+
+	expected := []string{model.SENSOR_WARM_UP_GAP, model.SENSOR_WARM_UP_GAP, model.SIGNAL_LOSS_GAP, model.NO_DATA_GAP}
+	for i, gap := range attributed.Gaps {
+		if gap.Cause != expected[i] {
+			t.Errorf("Expected gap [%d] to be caused by [%s] but got [%s]", i, expected[i], gap.Cause)
+		}
+	}
+
+	if coverage.Gaps[0].Cause != model.NO_DATA_GAP {
+		t.Errorf("Expected the original coverage to be left untouched but got [%v]", coverage.Gaps)
+	}
+}
+
+func TestCalculateSensorSessionStatistics(t *testing.T) {
+	reads := twoSessionReads()
+	calibrations := []apimodel.CalibrationRead{calibrationAt(1570, 110), calibrationAt(1600, 100)}
+	sessions := engine.InferSensorSessions(reads, calibrations)
+
+	statistics, err := engine.CalculateSensorSessionStatistics(sessions[1], reads, calibrations, minutesAfter(2880))
+	if err != nil {
+		t.Fatalf("Unexpected error calculating sensor session statistics: %v", err)
+	}
+
+	// Reads from 1600 to 2880 and only the calibration at 1600 paired since the one at 1570 is during the warm-up
+	if statistics.ReadCount != 257 || math.Abs(statistics.Average-147.0039) > 0.001 || statistics.Coverage < 99.9 {
+		t.Errorf("Expected [257] reads averaging [147.0039] with full coverage but got [%v]", statistics)
+	}
+
+	if statistics.Accuracy.PairCount != 1 || math.Abs(statistics.Accuracy.Bias-0) > 0.001 {
+		t.Errorf("Expected [1] pair without bias but got [%v]", statistics.Accuracy)
+	}
+}
@@ -0,0 +1,391 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/tasks"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	THERAPY_ESTIMATION_FUNCTION_NAME = "runTherapyEstimation"
+	// Days of data therapy estimates are calculated from
+	THERAPY_ESTIMATION_DAYS = 30
+	// Time after a bolus over which its effect on glucose is measured
+	BOLUS_OBSERVATION_WINDOW = 4 * time.Hour
+	// Maximum time between a meal and the bolus covering it
+	MEAL_BOLUS_MAX_OFFSET = 15 * time.Minute
+	// Maximum time between the start or end of an observation window and the read giving the glucose at that time
+	OBSERVATION_READ_TOLERANCE = 10 * time.Minute
+	// Reads further apart than this make an observation window unusable
+	OBSERVATION_MAX_READ_GAP = 15 * time.Minute
+	// Without an insulin sensitivity to account for it, glucose must be back within this of its pre-meal value (in
+	// mg/dL) for a meal bolus to tell the carb ratio
+	MEAL_RETURN_TOLERANCE = 30.
+	// Minimum number of samples for an estimate to have a value
+	MIN_THERAPY_SAMPLES = 3
+)
+
+// TIME_OF_DAY_BLOCKS are the blocks of the day, in local hours, estimates are calculated for
+var TIME_OF_DAY_BLOCKS = []struct {
+	StartHour int
+	EndHour   int
+}{{0, 6}, {6, 11}, {11, 17}, {17, 24}}
+
+// Two-sided 95% quantiles of Student's t-distribution by degrees of freedom, starting at 1. Larger samples use the
+// normal distribution's 1.96.
+var studentT95 = []float64{12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228, 2.201, 2.179, 2.160,
+	2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086, 2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042}
+
+var RunTherapyEstimationTask = tasks.Func(THERAPY_ESTIMATION_FUNCTION_NAME, RunTherapyEstimation)
+
+// StartTherapyEstimation queues the estimation of the insulin sensitivity and carb ratio of a user from its most
+// recent THERAPY_ESTIMATION_DAYS of data. Estimates are calculated once per day so nothing is queued if the estimate
+// of the day of the user's most recent read is already stored.
+func StartTherapyEstimation(context context.Context, glukitUser *model.GlukitUser) (err error) {
+	limit := 1
+	latestEstimates, err := store.GetTherapyEstimates(context, glukitUser.Email, store.ScoreScanQuery{Limit: &limit})
+	if err != nil {
+		return err
+	}
+
+	upperBound := getTherapyEstimateUpperBound(glukitUser)
+	if len(latestEstimates) > 0 && !latestEstimates[0].UpperBound.Before(upperBound) {
+		log.Debugf(context, "Therapy estimate of user [%s] up to [%s] already stored, skipping", glukitUser.Email, upperBound)
+		return nil
+	}
+
+	if err = RunTherapyEstimationTask.Add(context, BATCH_CALCULATION_QUEUE_NAME, glukitUser.Email); err != nil {
+		return err
+	}
+
+	log.Infof(context, "Queued up therapy estimation for user [%s]", glukitUser.Email)
+	return nil
+}
+
+// getTherapyEstimateUpperBound returns the upper bound of the estimate of a user: midnight (UTC) of the day of its
+// most recent read. Since estimates are keyed by their upper bound, that keeps a single estimate per day.
+func getTherapyEstimateUpperBound(glukitUser *model.GlukitUser) time.Time {
+	return glukitUser.MostRecentRead.GetTime().UTC().Truncate(24 * time.Hour)
+}
+
+// RunTherapyEstimation estimates the insulin sensitivity and carb ratio of a user from the THERAPY_ESTIMATION_DAYS
+// up to midnight (UTC) of the day of its most recent read and stores the estimate
+func RunTherapyEstimation(context context.Context, userEmail string) {
+	glukitUser, err := store.GetUserProfile(context, userEmail)
+	if err != nil {
+		log.Errorf(context, "Error getting profile of user [%s] for therapy estimation: %v", userEmail, err)
+		return
+	}
+
+	if skipAccountPendingDeletion(context, userEmail, "therapy estimation") {
+		return
+	}
+
+	if glukitUser.MostRecentRead.Time.Timestamp == 0 {
+		log.Infof(context, "No reads yet for user [%s], skipping therapy estimation", userEmail)
+		return
+	}
+
+	upperBound := getTherapyEstimateUpperBound(glukitUser)
+	lowerBound := upperBound.AddDate(0, 0, -THERAPY_ESTIMATION_DAYS)
+	// Doses before the period still act at its start so they're needed to tell whether boluses are isolated
+	doseLowerBound := lowerBound.Add(-onBoardModel.Lookback())
+
+	reads, err := store.GetGlucoseReads(context, userEmail, lowerBound, upperBound)
+	if err != nil {
+		log.Errorf(context, "Error getting reads of user [%s] for therapy estimation: %v", userEmail, err)
+		return
+	}
+
+	injections, err := store.GetInjections(context, userEmail, doseLowerBound, upperBound)
+	if err != nil {
+		log.Errorf(context, "Error getting injections of user [%s] for therapy estimation: %v", userEmail, err)
+		return
+	}
+
+	meals, err := store.GetMeals(context, userEmail, doseLowerBound, upperBound)
+	if err != nil {
+		log.Errorf(context, "Error getting meals of user [%s] for therapy estimation: %v", userEmail, err)
+		return
+	}
+
+	estimate, err := EstimateTherapy(reads, injections, meals, onBoardModel, lowerBound, upperBound)
+	if err != nil {
+		log.Errorf(context, "Error estimating therapy of user [%s]: %v", userEmail, err)
+		return
+	}
+
+	estimate.CalculatedOn = time.Now()
+	if err = store.StoreTherapyEstimate(context, userEmail, estimate); err != nil {
+		log.Errorf(context, "Error storing therapy estimate of user [%s]: %v", userEmail, err)
+		return
+	}
+
+	log.Infof(context, "Done with therapy estimation for user [%s] between [%s] and [%s]", userEmail, lowerBound, upperBound)
+}
+
+// EstimateTherapy estimates the insulin sensitivity factor and carb ratio for each of the TIME_OF_DAY_BLOCKS from
+// boluses between lowerBound and upperBound. Only isolated boluses are used: no other bolus still acting and no carbs
+// being absorbed, according to the action profiles of the model, other than those of the meal it covers. The
+// insulin sensitivity comes from correction boluses (without a meal) as the glucose drop per unit of insulin acted
+// over BOLUS_OBSERVATION_WINDOW. The carb ratio comes from meal boluses as the carbs per unit of insulin, taking the
+// sensitivity of the block into account to correct for glucose not being back where it started. Long-acting insulin
+// is considered to be balanced by the user's basal needs and ignored.
+func EstimateTherapy(reads []apimodel.GlucoseRead, injections []apimodel.Injection, meals []apimodel.Meal, onBoardModel OnBoardModel,
+	lowerBound time.Time, upperBound time.Time) (estimate model.TherapyEstimate, err error) {
+	series, err := newGlucoseSeries(reads)
+	if err != nil {
+		return estimate, err
+	}
+
+	boluses := make([]apimodel.Injection, 0)
+	for _, injection := range injections {
+		if injection.InsulinType != apimodel.LONG_ACTING_INSULIN_TYPE && injection.Units > 0 {
+			boluses = append(boluses, injection)
+		}
+	}
+	sort.Sort(apimodel.InjectionSlice(boluses))
+
+	sortedMeals := make([]apimodel.Meal, len(meals))
+	copy(sortedMeals, meals)
+	sort.Sort(apimodel.MealSlice(sortedMeals))
+
+	sensitivitySamples := make([][]float64, len(TIME_OF_DAY_BLOCKS))
+	mealSamples := make([][]mealBolusSample, len(TIME_OF_DAY_BLOCKS))
+
+	for i, bolus := range boluses {
+		bolusTime := bolus.GetTime()
+		if bolusTime.Before(lowerBound) || bolusTime.Add(BOLUS_OBSERVATION_WINDOW).After(upperBound) {
+			continue
+		}
+
+		profile := onBoardModel.insulinProfile(bolus)
+		windowEnd := bolusTime.Add(BOLUS_OBSERVATION_WINDOW)
+		if !isIsolatedBolus(boluses, i, onBoardModel) {
+			continue
+		}
+
+		// Any meal still being absorbed when the bolus or the meal it covers happen disqualifies it
+		carbsStart := bolusTime.Add(-onBoardModel.CarbsProfile.Duration() - MEAL_BOLUS_MAX_OFFSET)
+		nearbyMeals := mealsBetween(sortedMeals, carbsStart, windowEnd)
+		if len(nearbyMeals) > 1 {
+			continue
+		}
+
+		observationStart := bolusTime
+		if len(nearbyMeals) == 1 {
+			mealTime := nearbyMeals[0].GetTime()
+			if math.Abs(mealTime.Sub(bolusTime).Minutes()) > MEAL_BOLUS_MAX_OFFSET.Minutes() || nearbyMeals[0].Carbohydrates <= 0 {
+				continue
+			}
+
+			if mealTime.Before(observationStart) {
+				observationStart = mealTime
+			}
+		}
+
+		startGlucose, endGlucose, ok := series.observe(observationStart, windowEnd)
+		if !ok {
+			continue
+		}
+
+		actedUnits := float64(bolus.Units) * (1 - profile.RemainingFraction(BOLUS_OBSERVATION_WINDOW))
+		block := timeOfDayBlock(bolusTime)
+
+		if len(nearbyMeals) == 0 {
+			if sensitivity := (startGlucose - endGlucose) / actedUnits; sensitivity > 0 {
+				sensitivitySamples[block] = append(sensitivitySamples[block], sensitivity)
+			}
+		} else {
+			mealSamples[block] = append(mealSamples[block], mealBolusSample{float64(nearbyMeals[0].Carbohydrates), actedUnits, endGlucose - startGlucose})
+		}
+	}
+
+	estimate = model.TherapyEstimate{LowerBound: lowerBound, UpperBound: upperBound, Blocks: make([]model.TimeOfDayEstimate, len(TIME_OF_DAY_BLOCKS))}
+	for i, block := range TIME_OF_DAY_BLOCKS {
+		sensitivity := newEstimate(sensitivitySamples[i])
+
+		carbRatioSamples := make([]float64, 0)
+		for _, sample := range mealSamples[i] {
+			if carbRatio, ok := sample.carbRatio(sensitivity); ok {
+				carbRatioSamples = append(carbRatioSamples, carbRatio)
+			}
+		}
+
+		estimate.Blocks[i] = model.TimeOfDayEstimate{Start: fmt.Sprintf("%02d:00", block.StartHour), End: fmt.Sprintf("%02d:00", block.EndHour%24),
+			InsulinSensitivity: sensitivity, CarbRatio: newEstimate(carbRatioSamples)}
+	}
+
+	return estimate, nil
+}
+
+// mealBolusSample is a meal covered by a bolus along with the glucose change over the observation window
+type mealBolusSample struct {
+	carbs         float64
+	actedUnits    float64
+	glucoseChange float64
+}
+
+// carbRatio returns the carbs covered per unit of insulin. The units of insulin that went to the carbs are those
+// acted plus those that would have been needed to bring glucose back to where it started, which needs a sensitivity.
+// Without one, only meals after which glucose came back close to where it started are used.
+func (sample mealBolusSample) carbRatio(sensitivity model.Estimate) (carbRatio float64, ok bool) {
+	unitsForCarbs := sample.actedUnits
+	if sensitivity.SampleSize >= MIN_THERAPY_SAMPLES {
+		unitsForCarbs += sample.glucoseChange / sensitivity.Value
+	} else if math.Abs(sample.glucoseChange) > MEAL_RETURN_TOLERANCE {
+		return 0, false
+	}
+
+	if unitsForCarbs <= 0 {
+		return 0, false
+	}
+
+	return sample.carbs / unitsForCarbs, true
+}
+
+// isIsolatedBolus returns true if no other bolus is still acting at the time of the bolus or happens during its
+// observation window
+func isIsolatedBolus(boluses []apimodel.Injection, index int, onBoardModel OnBoardModel) bool {
+	bolusTime := boluses[index].GetTime()
+	windowEnd := bolusTime.Add(BOLUS_OBSERVATION_WINDOW)
+
+	for i, other := range boluses {
+		if i == index {
+			continue
+		}
+
+		otherTime := other.GetTime()
+		if !otherTime.Before(bolusTime) && !otherTime.After(windowEnd) {
+			return false
+		}
+
+		if otherTime.Before(bolusTime) && bolusTime.Sub(otherTime) < onBoardModel.insulinProfile(other).Duration() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// mealsBetween returns the meals, sorted by time, between lowerBound and upperBound inclusively
+func mealsBetween(meals []apimodel.Meal, lowerBound time.Time, upperBound time.Time) (matching []apimodel.Meal) {
+	matching = make([]apimodel.Meal, 0)
+	for _, meal := range meals {
+		if mealTime := meal.GetTime(); !mealTime.Before(lowerBound) && !mealTime.After(upperBound) {
+			matching = append(matching, meal)
+		}
+	}
+
+	return matching
+}
+
+// timeOfDayBlock returns the index of the block of TIME_OF_DAY_BLOCKS the local time falls in
+func timeOfDayBlock(timeValue time.Time) int {
+	for i, block := range TIME_OF_DAY_BLOCKS {
+		if timeValue.Hour() >= block.StartHour && timeValue.Hour() < block.EndHour {
+			return i
+		}
+	}
+
+	return len(TIME_OF_DAY_BLOCKS) - 1
+}
+
+// newEstimate returns the mean of the samples along with its 95% confidence interval or an estimate without value if
+// there are fewer than MIN_THERAPY_SAMPLES samples
+func newEstimate(samples []float64) (estimate model.Estimate) {
+	estimate.SampleSize = len(samples)
+	if len(samples) < MIN_THERAPY_SAMPLES {
+		return estimate
+	}
+
+	sum := 0.
+	for _, sample := range samples {
+		sum += sample
+	}
+	mean := sum / float64(len(samples))
+
+	squaredDeviations := 0.
+	for _, sample := range samples {
+		squaredDeviations += (sample - mean) * (sample - mean)
+	}
+	standardError := math.Sqrt(squaredDeviations/float64(len(samples)-1)) / math.Sqrt(float64(len(samples)))
+
+	t := 1.96
+	if degreesOfFreedom := len(samples) - 1; degreesOfFreedom <= len(studentT95) {
+		t = studentT95[degreesOfFreedom-1]
+	}
+
+	estimate.Value = mean
+	estimate.Low = mean - t*standardError
+	estimate.High = mean + t*standardError
+	return estimate
+}
+
+// glucoseSeries holds reads as sorted times and values in mg/dL
+type glucoseSeries struct {
+	times  []time.Time
+	values []float64
+}
+
+func newGlucoseSeries(reads []apimodel.GlucoseRead) (series glucoseSeries, err error) {
+	sortedReads := make([]apimodel.GlucoseRead, len(reads))
+	copy(sortedReads, reads)
+	sort.Sort(apimodel.GlucoseReadSlice(sortedReads))
+
+	series = glucoseSeries{make([]time.Time, len(sortedReads)), make([]float64, len(sortedReads))}
+	for i, read := range sortedReads {
+		value, err := read.GetNormalizedValue(apimodel.MG_PER_DL)
+		if err != nil {
+			return series, err
+		}
+		series.times[i] = read.GetTime()
+		series.values[i] = float64(value)
+	}
+
+	return series, nil
+}
+
+// observe returns the glucose of the reads closest to the start and end of a window if they're within
+// OBSERVATION_READ_TOLERANCE and there is no gap longer than OBSERVATION_MAX_READ_GAP in between
+func (series glucoseSeries) observe(start time.Time, end time.Time) (startGlucose float64, endGlucose float64, ok bool) {
+	first := sort.Search(len(series.times), func(i int) bool { return !series.times[i].Before(start.Add(-OBSERVATION_READ_TOLERANCE)) })
+	if first == len(series.times) || series.times[first].After(start.Add(OBSERVATION_READ_TOLERANCE)) {
+		return 0, 0, false
+	}
+
+	startIndex, endIndex := first, first
+	for i := first; i < len(series.times) && !series.times[i].After(end.Add(OBSERVATION_READ_TOLERANCE)); i++ {
+		if i > first && series.times[i].Sub(series.times[i-1]) > OBSERVATION_MAX_READ_GAP {
+			return 0, 0, false
+		}
+
+		if absDuration(series.times[i].Sub(start)) < absDuration(series.times[startIndex].Sub(start)) {
+			startIndex = i
+		}
+		if absDuration(series.times[i].Sub(end)) < absDuration(series.times[endIndex].Sub(end)) {
+			endIndex = i
+		}
+	}
+
+	if absDuration(series.times[endIndex].Sub(end)) > OBSERVATION_READ_TOLERANCE {
+		return 0, 0, false
+	}
+
+	return series.values[startIndex], series.values[endIndex], true
+}
+
+func absDuration(duration time.Duration) time.Duration {
+	if duration < 0 {
+		return -duration
+	}
+
+	return duration
+}
@@ -0,0 +1,124 @@
+package engine_test
+
+import (
+	"context"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/store"
+	"math"
+	"testing"
+	"time"
+)
+
+var therapyStart = time.Date(2014, 4, 1, 0, 0, 0, 0, time.UTC)
+
+// therapyData returns reads every 5 minutes over days, each at the value returned for its time
+func therapyReads(days int, valueAt func(readTime time.Time) float64) (reads []apimodel.GlucoseRead) {
+	return readsEvery(therapyStart, READ_STEP, int(therapyStart.AddDate(0, 0, days).Sub(therapyStart)/READ_STEP), func(i int, readTime time.Time) float64 {
+		return valueAt(readTime)
+	})
+}
+
+func therapyInjection(injectionTime time.Time, units float32) apimodel.Injection {
+	return apimodel.Injection{apimodel.Time{apimodel.GetTimeMillis(injectionTime), "UTC"}, units, "Humalog", apimodel.FAST_ACTING_INSULIN_TYPE}
+}
+
+func TestInsulinSensitivityFromCorrections(t *testing.T) {
+	profile := engine.DEFAULT_ON_BOARD_MODEL.DefaultInsulinProfile
+	sensitivities := []float64{40, 50, 60}
+
+	injections := make([]apimodel.Injection, 0)
+	for day := range sensitivities {
+		injections = append(injections, therapyInjection(therapyStart.AddDate(0, 0, day).Add(13*time.Hour), 2))
+	}
+
+	// Glucose drops by the sensitivity of the day for each unit of insulin acted
+	reads := therapyReads(len(sensitivities), func(readTime time.Time) float64 {
+		day := int(readTime.Sub(therapyStart).Hours() / 24)
+		elapsed := readTime.Sub(therapyStart.AddDate(0, 0, day).Add(13 * time.Hour))
+		if elapsed < 0 {
+			return 200
+		}
+		return 200 - sensitivities[day]*2*(1-profile.RemainingFraction(elapsed))
+	})
+
+	estimate, err := engine.EstimateTherapy(reads, injections, nil, engine.DEFAULT_ON_BOARD_MODEL, therapyStart, therapyStart.AddDate(0, 0, len(sensitivities)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sensitivity := estimate.Blocks[2].InsulinSensitivity
+	if estimate.Blocks[2].Start != "11:00" || sensitivity.SampleSize != 3 || math.Abs(sensitivity.Value-50) > 0.5 {
+		t.Fatalf("Expected sensitivity of [50] from [3] samples for the 11:00 block but got [%v]", estimate.Blocks[2])
+	}
+
+	// Standard error of 10/sqrt(3) with a t quantile of 4.303 for 2 degrees of freedom
+	if math.Abs(sensitivity.High-sensitivity.Value-24.84) > 0.5 || math.Abs(sensitivity.Value-sensitivity.Low-24.84) > 0.5 {
+		t.Errorf("Expected a confidence interval of [50 ± 24.84] but got [%v]", sensitivity)
+	}
+
+	for _, block := range []int{0, 1, 3} {
+		if estimate.Blocks[block].InsulinSensitivity.SampleSize != 0 || estimate.Blocks[block].InsulinSensitivity.Value != 0 {
+			t.Errorf("Expected no sensitivity for block [%v]", estimate.Blocks[block])
+		}
+	}
+}
+
+func TestCarbRatioFromMealBoluses(t *testing.T) {
+	profile := engine.DEFAULT_ON_BOARD_MODEL.DefaultInsulinProfile
+	injections := make([]apimodel.Injection, 0)
+	meals := make([]apimodel.Meal, 0)
+	for day := 0; day < 4; day++ {
+		breakfast := therapyStart.AddDate(0, 0, day).Add(8 * time.Hour)
+		injections = append(injections, therapyInjection(breakfast, 6))
+		meals = append(meals, apimodel.Meal{apimodel.Time{apimodel.GetTimeMillis(breakfast.Add(5 * time.Minute)), "UTC"}, 60, 0, 0, 0})
+	}
+	// A correction two hours after the last breakfast makes it unusable
+	injections = append(injections, therapyInjection(therapyStart.AddDate(0, 0, 3).Add(10*time.Hour), 1))
+
+	reads := therapyReads(4, func(readTime time.Time) float64 { return 120 })
+
+	estimate, err := engine.EstimateTherapy(reads, injections, meals, engine.DEFAULT_ON_BOARD_MODEL, therapyStart, therapyStart.AddDate(0, 0, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	carbRatio := estimate.Blocks[1].CarbRatio
+	expectedRatio := 60 / (6 * (1 - profile.RemainingFraction(engine.BOLUS_OBSERVATION_WINDOW)))
+	if carbRatio.SampleSize != 3 || math.Abs(carbRatio.Value-expectedRatio) > 0.01 || carbRatio.Low != carbRatio.High {
+		t.Errorf("Expected carb ratio of [%f] from [3] samples for the 06:00 block but got [%v]", expectedRatio, carbRatio)
+	}
+
+	if estimate.Blocks[1].InsulinSensitivity.SampleSize != 0 {
+		t.Errorf("Expected meal boluses not to count as corrections but got [%v]", estimate.Blocks[1].InsulinSensitivity)
+	}
+}
+
+func TestTherapyEstimatesAreKeptOncePerDay(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c := context.Background()
+	upperDate := time.Date(2014, 4, 18, 12, 0, 0, 0, time.UTC)
+
+	storeDaysOfFixedReads(t, c, 120, 3, upperDate)
+	engine.RunTherapyEstimation(c, TEST_USER)
+
+	// A later read of the same day replaces the estimate of that day
+	glukitUser, err := store.GetUserProfile(c, TEST_USER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	glukitUser.MostRecentRead.Time = apimodel.Time{apimodel.GetTimeMillis(upperDate.Add(6 * time.Hour)), "UTC"}
+	if err := store.StoreUserProfile(c, upperDate, *glukitUser); err != nil {
+		t.Fatal(err)
+	}
+	engine.RunTherapyEstimation(c, TEST_USER)
+
+	estimates, err := store.GetTherapyEstimates(c, TEST_USER, store.ScoreScanQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expectedUpperBound := time.Date(2014, 4, 18, 0, 0, 0, 0, time.UTC); len(estimates) != 1 || !estimates[0].UpperBound.Equal(expectedUpperBound) {
+		t.Errorf("Expected a single estimate up to [%v] but got [%v]", expectedUpperBound, estimates)
+	}
+}
@@ -0,0 +1,313 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/model"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Columns of a csv export. All records share the same columns, each type of record only filling the ones that apply
+// to it. Columns are only ever added at the end so that existing ones keep their position.
+var CSV_HEADER = []string{"Type", "Time", "Timestamp", "TimeZone", "Glucose Value", "Glucose Unit", "Insulin Units", "Insulin Name",
+	"Insulin Type", "Carbohydrates", "Proteins", "Fat", "Saturated Fat", "Duration (minutes)", "Intensity", "Description", "Score",
+	"A1C", "Lower Bound", "Upper Bound", "Calculated On", "Scoring Version", "Coverage", "Interpolated Reads", "Units Per Hour",
+	"Schedule Name", "Percent", "Reason", "Immediate Units", "Extended Units", "Ketone Value", "Ketone Unit", "Systolic", "Diastolic",
+	"Pulse", "Weight", "Weight Unit", "Estimated A1C", "Recorded On", "Warm Up End", "Expires On", "Ended On", "Transmitter Id", "Source"}
+
+const (
+	csvTypeColumn = iota
+	csvTimeColumn
+	csvTimestampColumn
+	csvTimeZoneColumn
+	csvGlucoseValueColumn
+	csvGlucoseUnitColumn
+	csvInsulinUnitsColumn
+	csvInsulinNameColumn
+	csvInsulinTypeColumn
+	csvCarbohydratesColumn
+	csvProteinsColumn
+	csvFatColumn
+	csvSaturatedFatColumn
+	csvDurationColumn
+	csvIntensityColumn
+	csvDescriptionColumn
+	csvScoreColumn
+	csvA1CColumn
+	csvLowerBoundColumn
+	csvUpperBoundColumn
+	csvCalculatedOnColumn
+	csvScoringVersionColumn
+	csvCoverageColumn
+	csvInterpolatedReadsColumn
+	csvUnitsPerHourColumn
+	csvScheduleNameColumn
+	csvPercentColumn
+	csvReasonColumn
+	csvImmediateUnitsColumn
+	csvExtendedUnitsColumn
+	csvKetoneValueColumn
+	csvKetoneUnitColumn
+	csvSystolicColumn
+	csvDiastolicColumn
+	csvPulseColumn
+	csvWeightColumn
+	csvWeightUnitColumn
+	csvEstimatedA1CColumn
+	csvRecordedOnColumn
+	csvWarmUpEndColumn
+	csvExpiresOnColumn
+	csvEndedOnColumn
+	csvTransmitterIdColumn
+	csvSourceColumn
+)
+
+// CsvWriter writes all records in a single table with a header
+type CsvWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+// NewCsvWriter returns a new CsvWriter writing to w
+func NewCsvWriter(w io.Writer) *CsvWriter {
+	return &CsvWriter{writer: csv.NewWriter(w)}
+}
+
+func (w *CsvWriter) WriteCalibrations(calibrations []apimodel.CalibrationRead) (err error) {
+	for i := 0; err == nil && i < len(calibrations); i++ {
+		row := newCsvRow(CALIBRATION_RECORD_TYPE, calibrations[i].Time)
+		row[csvGlucoseValueColumn] = formatFloat(calibrations[i].Value)
+		row[csvGlucoseUnitColumn] = string(calibrations[i].Unit)
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+func (w *CsvWriter) WriteGlucoseReads(reads []apimodel.GlucoseRead) (err error) {
+	for i := 0; err == nil && i < len(reads); i++ {
+		row := newCsvRow(GLUCOSE_READ_RECORD_TYPE, reads[i].Time)
+		row[csvGlucoseValueColumn] = formatFloat(reads[i].Value)
+		row[csvGlucoseUnitColumn] = string(reads[i].Unit)
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+func (w *CsvWriter) WriteInjections(injections []apimodel.Injection) (err error) {
+	for i := 0; err == nil && i < len(injections); i++ {
+		row := newCsvRow(INJECTION_RECORD_TYPE, injections[i].Time)
+		row[csvInsulinUnitsColumn] = formatFloat(injections[i].Units)
+		row[csvInsulinNameColumn] = injections[i].InsulinName
+		row[csvInsulinTypeColumn] = injections[i].InsulinType
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+func (w *CsvWriter) WriteMeals(meals []apimodel.Meal) (err error) {
+	for i := 0; err == nil && i < len(meals); i++ {
+		row := newCsvRow(MEAL_RECORD_TYPE, meals[i].Time)
+		row[csvCarbohydratesColumn] = formatFloat(meals[i].Carbohydrates)
+		row[csvProteinsColumn] = formatFloat(meals[i].Proteins)
+		row[csvFatColumn] = formatFloat(meals[i].Fat)
+		row[csvSaturatedFatColumn] = formatFloat(meals[i].SaturatedFat)
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+func (w *CsvWriter) WriteExercises(exercises []apimodel.Exercise) (err error) {
+	for i := 0; err == nil && i < len(exercises); i++ {
+		row := newCsvRow(EXERCISE_RECORD_TYPE, exercises[i].Time)
+		row[csvDurationColumn] = strconv.Itoa(exercises[i].DurationMinutes)
+		row[csvIntensityColumn] = exercises[i].Intensity
+		row[csvDescriptionColumn] = exercises[i].Description
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+func (w *CsvWriter) WriteBasalRates(basalRates []apimodel.BasalRate) (err error) {
+	for i := 0; err == nil && i < len(basalRates); i++ {
+		row := newCsvRow(BASAL_RATE_RECORD_TYPE, basalRates[i].Time)
+		row[csvUnitsPerHourColumn] = formatFloat(basalRates[i].UnitsPerHour)
+		row[csvScheduleNameColumn] = basalRates[i].ScheduleName
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+func (w *CsvWriter) WriteTempBasals(tempBasals []apimodel.TempBasal) (err error) {
+	for i := 0; err == nil && i < len(tempBasals); i++ {
+		row := newCsvRow(TEMP_BASAL_RECORD_TYPE, tempBasals[i].Time)
+		row[csvDurationColumn] = strconv.Itoa(tempBasals[i].DurationMinutes)
+		row[csvUnitsPerHourColumn] = formatFloat(tempBasals[i].UnitsPerHour)
+		row[csvPercentColumn] = formatFloat(tempBasals[i].Percent)
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+func (w *CsvWriter) WritePumpSuspends(pumpSuspends []apimodel.PumpSuspend) (err error) {
+	for i := 0; err == nil && i < len(pumpSuspends); i++ {
+		row := newCsvRow(PUMP_SUSPEND_RECORD_TYPE, pumpSuspends[i].Time)
+		row[csvDurationColumn] = strconv.Itoa(pumpSuspends[i].DurationMinutes)
+		row[csvReasonColumn] = pumpSuspends[i].Reason
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+func (w *CsvWriter) WriteExtendedBoluses(extendedBoluses []apimodel.ExtendedBolus) (err error) {
+	for i := 0; err == nil && i < len(extendedBoluses); i++ {
+		row := newCsvRow(EXTENDED_BOLUS_RECORD_TYPE, extendedBoluses[i].Time)
+		row[csvDurationColumn] = strconv.Itoa(extendedBoluses[i].DurationMinutes)
+		row[csvImmediateUnitsColumn] = formatFloat(extendedBoluses[i].ImmediateUnits)
+		row[csvExtendedUnitsColumn] = formatFloat(extendedBoluses[i].ExtendedUnits)
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+func (w *CsvWriter) WriteKetones(ketones []apimodel.Ketone) (err error) {
+	for i := 0; err == nil && i < len(ketones); i++ {
+		row := newCsvRow(KETONE_RECORD_TYPE, ketones[i].Time)
+		row[csvKetoneValueColumn] = formatFloat(ketones[i].Value)
+		row[csvKetoneUnitColumn] = string(ketones[i].Unit)
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+func (w *CsvWriter) WriteBloodPressures(bloodPressures []apimodel.BloodPressure) (err error) {
+	for i := 0; err == nil && i < len(bloodPressures); i++ {
+		row := newCsvRow(BLOOD_PRESSURE_RECORD_TYPE, bloodPressures[i].Time)
+		row[csvSystolicColumn] = formatFloat(bloodPressures[i].Systolic)
+		row[csvDiastolicColumn] = formatFloat(bloodPressures[i].Diastolic)
+		row[csvPulseColumn] = strconv.Itoa(bloodPressures[i].Pulse)
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+func (w *CsvWriter) WriteWeights(weights []apimodel.Weight) (err error) {
+	for i := 0; err == nil && i < len(weights); i++ {
+		row := newCsvRow(WEIGHT_RECORD_TYPE, weights[i].Time)
+		row[csvWeightColumn] = formatFloat(weights[i].Value)
+		row[csvWeightUnitColumn] = string(weights[i].Unit)
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+func (w *CsvWriter) WriteGlukitScores(scores []model.GlukitScore) (err error) {
+	for i := 0; err == nil && i < len(scores); i++ {
+		row := newCsvCalculationRow(GLUKIT_SCORE_RECORD_TYPE, scores[i].LowerBound, scores[i].UpperBound, scores[i].CalculatedOn, scores[i].ScoringVersion,
+			scores[i].Coverage, scores[i].InterpolatedReads)
+		row[csvScoreColumn] = strconv.FormatInt(scores[i].Value, 10)
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+func (w *CsvWriter) WriteA1CEstimates(a1cs []model.A1CEstimate) (err error) {
+	for i := 0; err == nil && i < len(a1cs); i++ {
+		row := newCsvCalculationRow(A1C_RECORD_TYPE, a1cs[i].LowerBound, a1cs[i].UpperBound, a1cs[i].CalculatedOn, a1cs[i].ScoringVersion,
+			a1cs[i].Coverage, a1cs[i].InterpolatedReads)
+		row[csvA1CColumn] = strconv.FormatFloat(a1cs[i].Value, 'f', -1, 64)
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+func (w *CsvWriter) WriteLabA1Cs(labs []model.LabA1C) (err error) {
+	for i := 0; err == nil && i < len(labs); i++ {
+		row := newCsvRow(LAB_A1C_RECORD_TYPE, timeOf(labs[i].TakenOn))
+		row[csvA1CColumn] = strconv.FormatFloat(labs[i].Value, 'f', -1, 64)
+		row[csvEstimatedA1CColumn] = strconv.FormatFloat(labs[i].EstimatedValue, 'f', -1, 64)
+		row[csvRecordedOnColumn] = formatTime(labs[i].RecordedOn)
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+func (w *CsvWriter) WriteSensorSessions(sessions []model.SensorSession) (err error) {
+	for i := 0; err == nil && i < len(sessions); i++ {
+		row := newCsvRow(SENSOR_SESSION_RECORD_TYPE, timeOf(sessions[i].InsertedOn))
+		row[csvWarmUpEndColumn] = formatTime(sessions[i].WarmUpEnd)
+		row[csvExpiresOnColumn] = formatTime(sessions[i].ExpiresOn)
+		row[csvEndedOnColumn] = formatTime(sessions[i].EndedOn)
+		row[csvTransmitterIdColumn] = sessions[i].TransmitterId
+		row[csvSourceColumn] = sessions[i].Source
+		err = w.writeRow(row)
+	}
+	return err
+}
+
+// Close writes the header if no record was written and flushes everything
+func (w *CsvWriter) Close() (err error) {
+	if !w.headerWritten {
+		if err = w.writer.Write(CSV_HEADER); err != nil {
+			return err
+		}
+	}
+
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func (w *CsvWriter) writeRow(row []string) (err error) {
+	if !w.headerWritten {
+		if err = w.writer.Write(CSV_HEADER); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+
+	return w.writer.Write(row)
+}
+
+// newCsvRow returns a row for a timed record with its time columns filled
+func newCsvRow(recordType string, timeValue apimodel.Time) (row []string) {
+	row = make([]string, len(CSV_HEADER))
+	row[csvTypeColumn] = recordType
+	row[csvTimeColumn] = timeValue.GetTime().Format(time.RFC3339)
+	row[csvTimestampColumn] = strconv.FormatInt(timeValue.Timestamp, 10)
+	row[csvTimeZoneColumn] = timeValue.TimeZoneId
+
+	return row
+}
+
+// newCsvCalculationRow returns a row for a calculation (score or a1c) over a period
+func newCsvCalculationRow(recordType string, lowerBound, upperBound, calculatedOn time.Time, scoringVersion int, coverage float64, interpolatedReads int) (row []string) {
+	row = make([]string, len(CSV_HEADER))
+	row[csvTypeColumn] = recordType
+	row[csvLowerBoundColumn] = lowerBound.Format(time.RFC3339)
+	row[csvUpperBoundColumn] = upperBound.Format(time.RFC3339)
+	row[csvCalculatedOnColumn] = calculatedOn.Format(time.RFC3339)
+	row[csvScoringVersionColumn] = strconv.Itoa(scoringVersion)
+	row[csvCoverageColumn] = strconv.FormatFloat(coverage, 'f', -1, 64)
+	row[csvInterpolatedReadsColumn] = strconv.Itoa(interpolatedReads)
+
+	return row
+}
+
+// timeOf returns the apimodel.Time of a time, in its own location
+func timeOf(value time.Time) apimodel.Time {
+	return apimodel.Time{apimodel.GetTimeMillis(value), value.Location().String()}
+}
+
+// formatTime formats a time as RFC3339 or returns an empty string for the zero time (i.e. an ongoing sensor session)
+func formatTime(value time.Time) string {
+	if value.IsZero() {
+		return ""
+	}
+
+	return value.Format(time.RFC3339)
+}
+
+func formatFloat(value float32) string {
+	return strconv.FormatFloat(float64(value), 'f', -1, 32)
+}
@@ -0,0 +1,196 @@
+package exporter
+
+import (
+	"encoding/xml"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/dexcomimporter"
+	"github.com/alexandre-normand/glukit/app/model"
+	"io"
+)
+
+// Sections of a Dexcom Studio xml file, in the order they appear
+const (
+	xmlRootElement     = "Patient"
+	xmlMetersSection   = "MeterReadings"
+	xmlGlucoseSection  = "GlucoseReadings"
+	xmlEventsSection   = "EventMarkers"
+	xmlMeterElement    = "Meter"
+	xmlGlucoseElement  = "Glucose"
+	xmlEventElement    = "Event"
+	xmlNoSectionOpened = ""
+)
+
+// DexcomXmlWriter writes records in the Dexcom Studio xml format that importer.ParseContent reads. That format
+// can't hold pump data, ketones, blood pressures, weights, glukit scores, a1c estimates, lab a1c results, sensor sessions, insulin names and types or meal nutrients other than
+// carbohydrates so those are left out. Glucose values of unknown units and meals without carbohydrates are also left out.
+type DexcomXmlWriter struct {
+	encoder *xml.Encoder
+	section string
+	started bool
+}
+
+// NewDexcomXmlWriter returns a new DexcomXmlWriter writing to w
+func NewDexcomXmlWriter(w io.Writer) *DexcomXmlWriter {
+	return &DexcomXmlWriter{encoder: xml.NewEncoder(w), section: xmlNoSectionOpened}
+}
+
+func (w *DexcomXmlWriter) WriteCalibrations(calibrations []apimodel.CalibrationRead) (err error) {
+	if err = w.openSection(xmlMetersSection); err != nil {
+		return err
+	}
+
+	for i := range calibrations {
+		calibration, err := dexcomimporter.NewXmlCalibration(calibrations[i])
+		if err != nil {
+			continue
+		}
+
+		if err = w.encoder.EncodeElement(calibration, xml.StartElement{Name: xml.Name{Local: xmlMeterElement}}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *DexcomXmlWriter) WriteGlucoseReads(reads []apimodel.GlucoseRead) (err error) {
+	if err = w.openSection(xmlGlucoseSection); err != nil {
+		return err
+	}
+
+	for i := range reads {
+		glucose, err := dexcomimporter.NewXmlGlucoseRead(reads[i])
+		if err != nil {
+			continue
+		}
+
+		if err = w.encoder.EncodeElement(glucose, xml.StartElement{Name: xml.Name{Local: xmlGlucoseElement}}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *DexcomXmlWriter) WriteInjections(injections []apimodel.Injection) (err error) {
+	for i := 0; err == nil && i < len(injections); i++ {
+		err = w.writeEvent(dexcomimporter.NewXmlInjectionEvent(injections[i]))
+	}
+	return err
+}
+
+func (w *DexcomXmlWriter) WriteMeals(meals []apimodel.Meal) (err error) {
+	for i := 0; err == nil && i < len(meals); i++ {
+		// Dexcom only records carbohydrates so a meal without any would be an empty event
+		if int(meals[i].Carbohydrates+0.5) > 0 {
+			err = w.writeEvent(dexcomimporter.NewXmlMealEvent(meals[i]))
+		}
+	}
+	return err
+}
+
+func (w *DexcomXmlWriter) WriteExercises(exercises []apimodel.Exercise) (err error) {
+	for i := 0; err == nil && i < len(exercises); i++ {
+		err = w.writeEvent(dexcomimporter.NewXmlExerciseEvent(exercises[i]))
+	}
+	return err
+}
+
+func (w *DexcomXmlWriter) WriteBasalRates(basalRates []apimodel.BasalRate) (err error) {
+	return nil
+}
+
+func (w *DexcomXmlWriter) WriteTempBasals(tempBasals []apimodel.TempBasal) (err error) {
+	return nil
+}
+
+func (w *DexcomXmlWriter) WritePumpSuspends(pumpSuspends []apimodel.PumpSuspend) (err error) {
+	return nil
+}
+
+func (w *DexcomXmlWriter) WriteExtendedBoluses(extendedBoluses []apimodel.ExtendedBolus) (err error) {
+	return nil
+}
+
+func (w *DexcomXmlWriter) WriteKetones(ketones []apimodel.Ketone) (err error) {
+	return nil
+}
+
+func (w *DexcomXmlWriter) WriteBloodPressures(bloodPressures []apimodel.BloodPressure) (err error) {
+	return nil
+}
+
+func (w *DexcomXmlWriter) WriteWeights(weights []apimodel.Weight) (err error) {
+	return nil
+}
+
+func (w *DexcomXmlWriter) WriteGlukitScores(scores []model.GlukitScore) (err error) {
+	return nil
+}
+
+func (w *DexcomXmlWriter) WriteA1CEstimates(a1cs []model.A1CEstimate) (err error) {
+	return nil
+}
+
+func (w *DexcomXmlWriter) WriteLabA1Cs(labs []model.LabA1C) (err error) {
+	return nil
+}
+
+func (w *DexcomXmlWriter) WriteSensorSessions(sessions []model.SensorSession) (err error) {
+	return nil
+}
+
+// Close closes the last section and the root element
+func (w *DexcomXmlWriter) Close() (err error) {
+	if err = w.openSection(xmlNoSectionOpened); err != nil {
+		return err
+	}
+
+	if w.started {
+		if err = w.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: xmlRootElement}}); err != nil {
+			return err
+		}
+	} else {
+		if err = w.encoder.EncodeElement(struct{}{}, xml.StartElement{Name: xml.Name{Local: xmlRootElement}}); err != nil {
+			return err
+		}
+	}
+
+	return w.encoder.Flush()
+}
+
+func (w *DexcomXmlWriter) writeEvent(event dexcomimporter.Event) (err error) {
+	if err = w.openSection(xmlEventsSection); err != nil {
+		return err
+	}
+
+	return w.encoder.EncodeElement(event, xml.StartElement{Name: xml.Name{Local: xmlEventElement}})
+}
+
+// openSection closes the current section, if it's a different one, and opens the new one. The root element is opened
+// along with the first section.
+func (w *DexcomXmlWriter) openSection(section string) (err error) {
+	if w.section == section {
+		return nil
+	}
+
+	if w.section != xmlNoSectionOpened {
+		if err = w.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: w.section}}); err != nil {
+			return err
+		}
+	}
+
+	w.section = section
+	if section == xmlNoSectionOpened {
+		return nil
+	}
+
+	if !w.started {
+		if err = w.encoder.EncodeToken(xml.StartElement{Name: xml.Name{Local: xmlRootElement}}); err != nil {
+			return err
+		}
+		w.started = true
+	}
+
+	return w.encoder.EncodeToken(xml.StartElement{Name: xml.Name{Local: section}})
+}
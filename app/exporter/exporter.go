@@ -0,0 +1,273 @@
+/*
+Package exporter writes all the data of a user (glucose reads, calibrations, injections, meals, exercises, pump data,
+ketones, blood pressures, weights, glukit scores, a1c estimates, lab a1c results and sensor sessions) in a format that can be downloaded: JSON Lines, CSV or a Dexcom Studio xml file that can be imported
+back.
+*/
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/util"
+	"io"
+	"time"
+)
+
+const (
+	// Export formats
+	JSON_LINES_FORMAT = "jsonl"
+	CSV_FORMAT        = "csv"
+	DEXCOM_XML_FORMAT = "xml"
+
+	// Days of data are scanned in windows of this duration so that only a window's worth of data is held in memory
+	EXPORT_SCAN_WINDOW = time.Duration(90*24) * time.Hour
+)
+
+// RecordWriter writes exported records in a given format. Export writes all records of a type, in chronological order,
+// before moving on to the next type, in the order of the methods below. Close must be called once everything is written.
+type RecordWriter interface {
+	WriteCalibrations(calibrations []apimodel.CalibrationRead) (err error)
+	WriteGlucoseReads(reads []apimodel.GlucoseRead) (err error)
+	WriteInjections(injections []apimodel.Injection) (err error)
+	WriteMeals(meals []apimodel.Meal) (err error)
+	WriteExercises(exercises []apimodel.Exercise) (err error)
+	WriteBasalRates(basalRates []apimodel.BasalRate) (err error)
+	WriteTempBasals(tempBasals []apimodel.TempBasal) (err error)
+	WritePumpSuspends(pumpSuspends []apimodel.PumpSuspend) (err error)
+	WriteExtendedBoluses(extendedBoluses []apimodel.ExtendedBolus) (err error)
+	WriteKetones(ketones []apimodel.Ketone) (err error)
+	WriteBloodPressures(bloodPressures []apimodel.BloodPressure) (err error)
+	WriteWeights(weights []apimodel.Weight) (err error)
+	WriteGlukitScores(scores []model.GlukitScore) (err error)
+	WriteA1CEstimates(a1cs []model.A1CEstimate) (err error)
+	WriteLabA1Cs(labs []model.LabA1C) (err error)
+	WriteSensorSessions(sessions []model.SensorSession) (err error)
+	Close() (err error)
+}
+
+// NewRecordWriter returns a RecordWriter for the given format that writes to w
+func NewRecordWriter(format string, w io.Writer) (writer RecordWriter, err error) {
+	switch format {
+	case JSON_LINES_FORMAT:
+		return NewJsonLinesWriter(w), nil
+	case CSV_FORMAT:
+		return NewCsvWriter(w), nil
+	case DEXCOM_XML_FORMAT:
+		return NewDexcomXmlWriter(w), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown export format [%s], must be one of [%s, %s, %s]", format, JSON_LINES_FORMAT, CSV_FORMAT, DEXCOM_XML_FORMAT))
+	}
+}
+
+// ContentType returns the mime type of an export format
+func ContentType(format string) string {
+	switch format {
+	case CSV_FORMAT:
+		return "text/csv"
+	case DEXCOM_XML_FORMAT:
+		return "application/xml"
+	default:
+		return "application/x-ndjson"
+	}
+}
+
+// Export walks every day of data of a user, from the glukit epoch up to upperBound, followed by all glukit scores, a1c
+// estimates, lab a1c results and sensor sessions and writes them all to writer. The writer is closed when everything has been written.
+func Export(context context.Context, email string, upperBound time.Time, writer RecordWriter) (err error) {
+	repository := store.GetRepository()
+
+	err = walkWindows(upperBound, func(scanStart, scanEnd time.Time) error {
+		days, err := store.ScanDaysOf(context, apimodel.CALIBRATION_READ_KIND, email, scanStart, scanEnd)
+		for i := 0; err == nil && i < len(days); i++ {
+			err = writer.WriteCalibrations(days[i].Reads)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = walkWindows(upperBound, func(scanStart, scanEnd time.Time) error {
+		days, err := store.ScanDaysOf(context, apimodel.GLUCOSE_READ_KIND, email, scanStart, scanEnd)
+		for i := 0; err == nil && i < len(days); i++ {
+			err = writer.WriteGlucoseReads(days[i].Reads)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = walkWindows(upperBound, func(scanStart, scanEnd time.Time) error {
+		days, err := store.ScanDaysOf(context, apimodel.INJECTION_KIND, email, scanStart, scanEnd)
+		for i := 0; err == nil && i < len(days); i++ {
+			err = writer.WriteInjections(days[i].Injections)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = walkWindows(upperBound, func(scanStart, scanEnd time.Time) error {
+		days, err := store.ScanDaysOf(context, apimodel.MEAL_KIND, email, scanStart, scanEnd)
+		for i := 0; err == nil && i < len(days); i++ {
+			err = writer.WriteMeals(days[i].Meals)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = walkWindows(upperBound, func(scanStart, scanEnd time.Time) error {
+		days, err := store.ScanDaysOf(context, apimodel.EXERCISE_KIND, email, scanStart, scanEnd)
+		for i := 0; err == nil && i < len(days); i++ {
+			err = writer.WriteExercises(days[i].Exercises)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = walkWindows(upperBound, func(scanStart, scanEnd time.Time) error {
+		days, err := store.ScanDaysOf(context, apimodel.BASAL_RATE_KIND, email, scanStart, scanEnd)
+		for i := 0; err == nil && i < len(days); i++ {
+			err = writer.WriteBasalRates(days[i].BasalRates)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = walkWindows(upperBound, func(scanStart, scanEnd time.Time) error {
+		days, err := store.ScanDaysOf(context, apimodel.TEMP_BASAL_KIND, email, scanStart, scanEnd)
+		for i := 0; err == nil && i < len(days); i++ {
+			err = writer.WriteTempBasals(days[i].TempBasals)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = walkWindows(upperBound, func(scanStart, scanEnd time.Time) error {
+		days, err := store.ScanDaysOf(context, apimodel.PUMP_SUSPEND_KIND, email, scanStart, scanEnd)
+		for i := 0; err == nil && i < len(days); i++ {
+			err = writer.WritePumpSuspends(days[i].PumpSuspends)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = walkWindows(upperBound, func(scanStart, scanEnd time.Time) error {
+		days, err := store.ScanDaysOf(context, apimodel.EXTENDED_BOLUS_KIND, email, scanStart, scanEnd)
+		for i := 0; err == nil && i < len(days); i++ {
+			err = writer.WriteExtendedBoluses(days[i].ExtendedBoluses)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = walkWindows(upperBound, func(scanStart, scanEnd time.Time) error {
+		days, err := store.ScanDaysOf(context, apimodel.KETONE_KIND, email, scanStart, scanEnd)
+		for i := 0; err == nil && i < len(days); i++ {
+			err = writer.WriteKetones(days[i].Ketones)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = walkWindows(upperBound, func(scanStart, scanEnd time.Time) error {
+		days, err := store.ScanDaysOf(context, apimodel.BLOOD_PRESSURE_KIND, email, scanStart, scanEnd)
+		for i := 0; err == nil && i < len(days); i++ {
+			err = writer.WriteBloodPressures(days[i].BloodPressures)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = walkWindows(upperBound, func(scanStart, scanEnd time.Time) error {
+		days, err := store.ScanDaysOf(context, apimodel.WEIGHT_KIND, email, scanStart, scanEnd)
+		for i := 0; err == nil && i < len(days); i++ {
+			err = writer.WriteWeights(days[i].Weights)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Scores, a1cs, lab results and sensor sessions are scanned most recent first
+	scores, err := repository.ScanGlukitScores(context, email, store.ScoreScanQuery{})
+	if err != nil {
+		return err
+	}
+	for i, j := 0, len(scores)-1; i < j; i, j = i+1, j-1 {
+		scores[i], scores[j] = scores[j], scores[i]
+	}
+	if err = writer.WriteGlukitScores(scores); err != nil {
+		return err
+	}
+
+	a1cs, err := repository.ScanA1CEstimates(context, email, store.ScoreScanQuery{})
+	if err != nil {
+		return err
+	}
+	for i, j := 0, len(a1cs)-1; i < j; i, j = i+1, j-1 {
+		a1cs[i], a1cs[j] = a1cs[j], a1cs[i]
+	}
+	if err = writer.WriteA1CEstimates(a1cs); err != nil {
+		return err
+	}
+
+	labs, err := store.GetLabA1Cs(context, email, store.ScoreScanQuery{})
+	if err != nil {
+		return err
+	}
+	for i, j := 0, len(labs)-1; i < j; i, j = i+1, j-1 {
+		labs[i], labs[j] = labs[j], labs[i]
+	}
+	if err = writer.WriteLabA1Cs(labs); err != nil {
+		return err
+	}
+
+	sessions, err := store.GetSensorSessions(context, email, store.ScoreScanQuery{})
+	if err != nil {
+		return err
+	}
+	for i, j := 0, len(sessions)-1; i < j; i, j = i+1, j-1 {
+		sessions[i], sessions[j] = sessions[j], sessions[i]
+	}
+	if err = writer.WriteSensorSessions(sessions); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// walkWindows calls scan for consecutive windows of EXPORT_SCAN_WINDOW from the glukit epoch to upperBound. Scan
+// boundaries are inclusive so each window ends just before the start of the next one.
+func walkWindows(upperBound time.Time, scan func(scanStart, scanEnd time.Time) error) (err error) {
+	for windowStart := util.GLUKIT_EPOCH_TIME; !windowStart.After(upperBound); windowStart = windowStart.Add(EXPORT_SCAN_WINDOW) {
+		if err = scan(windowStart, windowStart.Add(EXPORT_SCAN_WINDOW).Add(-time.Microsecond)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
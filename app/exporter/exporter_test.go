@@ -0,0 +1,326 @@
+package exporter_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	. "github.com/alexandre-normand/glukit/app/exporter"
+	"github.com/alexandre-normand/glukit/app/importer"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/util"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	EXPORT_USER = "export@glukit.com"
+	IMPORT_USER = "import@glukit.com"
+)
+
+func storeUser(t *testing.T, c context.Context, email string) {
+	user := model.GlukitUser{email, "", "", time.Now(),
+		model.DIABETES_TYPE_1, "", util.GLUKIT_EPOCH_TIME, apimodel.UNDEFINED_GLUCOSE_READ,
+		model.UNDEFINED_SCORE, model.UNDEFINED_SCORE, false, "", time.Now(), model.UNDEFINED_A1C_ESTIMATE, model.DEFAULT_GLUCOSE_TARGETS, model.A1C_FORMULA_ADAG, 0}
+
+	if err := store.StoreUserProfile(c, time.Unix(1000, 0), user); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// setupExportData stores two days of data, a year apart to span more than one scan window, for the export user
+func setupExportData(t *testing.T) (c context.Context) {
+	store.SetRepository(store.NewMemoryRepository())
+	c = context.Background()
+	storeUser(t, c, EXPORT_USER)
+	storeUser(t, c, IMPORT_USER)
+
+	location, _ := time.LoadLocation("America/Montreal")
+	for _, dayStart := range []time.Time{time.Date(2015, 4, 18, 8, 0, 0, 0, location), time.Date(2016, 4, 18, 8, 0, 0, 0, location)} {
+		dayTime := func(hours int) apimodel.Time {
+			return apimodel.Time{apimodel.GetTimeMillis(dayStart.Add(time.Duration(hours) * time.Hour)), location.String()}
+		}
+
+		reads := []apimodel.GlucoseRead{{dayTime(0), apimodel.MG_PER_DL, 95}, {dayTime(1), apimodel.MG_PER_DL, 142}, {dayTime(2), apimodel.MG_PER_DL, 180}}
+		if err := store.StoreDaysOfReads(c, EXPORT_USER, []apimodel.DayOfGlucoseReads{apimodel.NewDayOfGlucoseReads(reads)}); err != nil {
+			t.Fatal(err)
+		}
+
+		calibrations := []apimodel.CalibrationRead{{dayTime(1), apimodel.MG_PER_DL, 140}}
+		if err := store.StoreDaysOf(c, apimodel.CALIBRATION_READ_KIND, EXPORT_USER, []apimodel.DayOfCalibrationReads{apimodel.NewDayOfCalibrationReads(calibrations)}); err != nil {
+			t.Fatal(err)
+		}
+
+		injections := []apimodel.Injection{{dayTime(0), 4.5, "Humalog", apimodel.FAST_ACTING_INSULIN_TYPE}}
+		if err := store.StoreDaysOf(c, apimodel.INJECTION_KIND, EXPORT_USER, []apimodel.DayOfInjections{apimodel.NewDayOfInjections(injections)}); err != nil {
+			t.Fatal(err)
+		}
+
+		meals := []apimodel.Meal{{dayTime(0), 45, 10, 5, 1}}
+		if err := store.StoreDaysOf(c, apimodel.MEAL_KIND, EXPORT_USER, []apimodel.DayOfMeals{apimodel.NewDayOfMeals(meals)}); err != nil {
+			t.Fatal(err)
+		}
+
+		exercises := []apimodel.Exercise{{dayTime(2), 30, "Heavy", "Running"}}
+		if err := store.StoreDaysOf(c, apimodel.EXERCISE_KIND, EXPORT_USER, []apimodel.DayOfExercises{apimodel.NewDayOfExercises(exercises)}); err != nil {
+			t.Fatal(err)
+		}
+
+		basalRates := []apimodel.BasalRate{{dayTime(0), 0.85, "Weekday"}}
+		if err := store.StoreDaysOf(c, apimodel.BASAL_RATE_KIND, EXPORT_USER, []apimodel.DayOfBasalRates{apimodel.NewDayOfBasalRates(basalRates)}); err != nil {
+			t.Fatal(err)
+		}
+
+		tempBasals := []apimodel.TempBasal{{dayTime(1), 60, 0.4, 50}}
+		if err := store.StoreDaysOf(c, apimodel.TEMP_BASAL_KIND, EXPORT_USER, []apimodel.DayOfTempBasals{apimodel.NewDayOfTempBasals(tempBasals)}); err != nil {
+			t.Fatal(err)
+		}
+
+		pumpSuspends := []apimodel.PumpSuspend{{dayTime(2), 20, "Exercise"}}
+		if err := store.StoreDaysOf(c, apimodel.PUMP_SUSPEND_KIND, EXPORT_USER, []apimodel.DayOfPumpSuspends{apimodel.NewDayOfPumpSuspends(pumpSuspends)}); err != nil {
+			t.Fatal(err)
+		}
+
+		extendedBoluses := []apimodel.ExtendedBolus{{dayTime(0), 120, 2, 3}}
+		if err := store.StoreDaysOf(c, apimodel.EXTENDED_BOLUS_KIND, EXPORT_USER, []apimodel.DayOfExtendedBoluses{apimodel.NewDayOfExtendedBoluses(extendedBoluses)}); err != nil {
+			t.Fatal(err)
+		}
+
+		ketones := []apimodel.Ketone{{dayTime(1), apimodel.KETONE_MMOL_PER_L, 0.6}}
+		if err := store.StoreDaysOf(c, apimodel.KETONE_KIND, EXPORT_USER, []apimodel.DayOfKetones{apimodel.NewDayOfKetones(ketones)}); err != nil {
+			t.Fatal(err)
+		}
+
+		bloodPressures := []apimodel.BloodPressure{{dayTime(0), 120, 80, 65}}
+		if err := store.StoreDaysOf(c, apimodel.BLOOD_PRESSURE_KIND, EXPORT_USER, []apimodel.DayOfBloodPressures{apimodel.NewDayOfBloodPressures(bloodPressures)}); err != nil {
+			t.Fatal(err)
+		}
+
+		weights := []apimodel.Weight{{dayTime(0), apimodel.KILOGRAMS, 72.5}}
+		if err := store.StoreDaysOf(c, apimodel.WEIGHT_KIND, EXPORT_USER, []apimodel.DayOfWeights{apimodel.NewDayOfWeights(weights)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scoreTime := time.Date(2016, 4, 19, 0, 0, 0, 0, time.UTC)
+	scores := []model.GlukitScore{{Value: 20, LowerBound: scoreTime.Add(-7 * 24 * time.Hour), UpperBound: scoreTime, CalculatedOn: scoreTime, ScoringVersion: 1}}
+	if err := store.StoreGlukitScoreBatch(c, EXPORT_USER, scores); err != nil {
+		t.Fatal(err)
+	}
+
+	a1cs := []model.A1CEstimate{{Value: 6.2, LowerBound: scoreTime.Add(-90 * 24 * time.Hour), UpperBound: scoreTime, CalculatedOn: scoreTime, ScoringVersion: 1}}
+	if err := store.StoreA1CBatch(c, EXPORT_USER, a1cs); err != nil {
+		t.Fatal(err)
+	}
+
+	lab := model.LabA1C{Value: 6.4, TakenOn: scoreTime, EstimatedValue: 6.2, RecordedOn: scoreTime.Add(24 * time.Hour)}
+	if err := store.StoreLabA1C(c, EXPORT_USER, lab); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := []model.SensorSession{
+		{InsertedOn: scoreTime.Add(-20 * 24 * time.Hour), WarmUpEnd: scoreTime.Add(-20*24*time.Hour + 2*time.Hour), ExpiresOn: scoreTime.Add(-10 * 24 * time.Hour),
+			EndedOn: scoreTime.Add(-10 * 24 * time.Hour), TransmitterId: "8G1234", Source: model.RECORDED_SENSOR_SESSION},
+		{InsertedOn: scoreTime.Add(-10 * 24 * time.Hour), WarmUpEnd: scoreTime.Add(-10*24*time.Hour + 2*time.Hour), ExpiresOn: scoreTime,
+			TransmitterId: "8G1234", Source: model.RECORDED_SENSOR_SESSION},
+	}
+	if err := store.StoreSensorSessions(c, EXPORT_USER, sessions); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func export(t *testing.T, c context.Context, format string) (content []byte) {
+	var buffer bytes.Buffer
+	writer, err := NewRecordWriter(format, &buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Export(c, EXPORT_USER, time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC), writer); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func TestJsonLinesExport(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c := setupExportData(t)
+
+	counts := make(map[string]int)
+	scanner := bufio.NewScanner(bytes.NewReader(export(t, c, JSON_LINES_FORMAT)))
+	for scanner.Scan() {
+		var record struct {
+			Type   string          `json:"type"`
+			Record json.RawMessage `json:"record"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Invalid line [%s]: %v", scanner.Text(), err)
+		}
+		counts[record.Type]++
+	}
+
+	expectedCounts := map[string]int{GLUCOSE_READ_RECORD_TYPE: 6, CALIBRATION_RECORD_TYPE: 2, INJECTION_RECORD_TYPE: 2,
+		MEAL_RECORD_TYPE: 2, EXERCISE_RECORD_TYPE: 2, BASAL_RATE_RECORD_TYPE: 2, TEMP_BASAL_RECORD_TYPE: 2, PUMP_SUSPEND_RECORD_TYPE: 2,
+		EXTENDED_BOLUS_RECORD_TYPE: 2, KETONE_RECORD_TYPE: 2, BLOOD_PRESSURE_RECORD_TYPE: 2, WEIGHT_RECORD_TYPE: 2,
+		GLUKIT_SCORE_RECORD_TYPE: 1, A1C_RECORD_TYPE: 1, LAB_A1C_RECORD_TYPE: 1, SENSOR_SESSION_RECORD_TYPE: 2}
+	for recordType, expected := range expectedCounts {
+		if counts[recordType] != expected {
+			t.Errorf("Expected [%d] records of type [%s] but got [%d]", expected, recordType, counts[recordType])
+		}
+	}
+}
+
+func TestCsvExport(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c := setupExportData(t)
+
+	rows, err := csv.NewReader(bytes.NewReader(export(t, c, CSV_FORMAT))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 34 {
+		t.Fatalf("Expected header and [33] records but got [%d] rows", len(rows))
+	}
+
+	if strings.Join(rows[0], ",") != strings.Join(CSV_HEADER, ",") {
+		t.Errorf("Expected header [%v] but got [%v]", CSV_HEADER, rows[0])
+	}
+
+	if rows[1][0] != CALIBRATION_RECORD_TYPE || rows[1][4] != "140" || rows[1][5] != string(apimodel.MG_PER_DL) {
+		t.Errorf("Unexpected first calibration row [%v]", rows[1])
+	}
+
+	if rows[1][1] != "2015-04-18T09:00:00-04:00" {
+		t.Errorf("Expected first calibration at local time [2015-04-18T09:00:00-04:00] but got [%s]", rows[1][1])
+	}
+
+	if basalRate := rows[15]; basalRate[0] != BASAL_RATE_RECORD_TYPE || basalRate[24] != "0.85" || basalRate[25] != "Weekday" {
+		t.Errorf("Unexpected basal rate row [%v]", basalRate)
+	}
+
+	if tempBasal := rows[17]; tempBasal[0] != TEMP_BASAL_RECORD_TYPE || tempBasal[13] != "60" || tempBasal[24] != "0.4" || tempBasal[26] != "50" {
+		t.Errorf("Unexpected temp basal row [%v]", tempBasal)
+	}
+
+	if pumpSuspend := rows[19]; pumpSuspend[0] != PUMP_SUSPEND_RECORD_TYPE || pumpSuspend[13] != "20" || pumpSuspend[27] != "Exercise" {
+		t.Errorf("Unexpected pump suspend row [%v]", pumpSuspend)
+	}
+
+	if extendedBolus := rows[21]; extendedBolus[0] != EXTENDED_BOLUS_RECORD_TYPE || extendedBolus[13] != "120" || extendedBolus[28] != "2" || extendedBolus[29] != "3" {
+		t.Errorf("Unexpected extended bolus row [%v]", extendedBolus)
+	}
+
+	if ketone := rows[23]; ketone[0] != KETONE_RECORD_TYPE || ketone[30] != "0.6" || ketone[31] != string(apimodel.KETONE_MMOL_PER_L) {
+		t.Errorf("Unexpected ketone row [%v]", ketone)
+	}
+
+	if bloodPressure := rows[25]; bloodPressure[0] != BLOOD_PRESSURE_RECORD_TYPE || bloodPressure[32] != "120" || bloodPressure[33] != "80" || bloodPressure[34] != "65" {
+		t.Errorf("Unexpected blood pressure row [%v]", bloodPressure)
+	}
+
+	if weight := rows[27]; weight[0] != WEIGHT_RECORD_TYPE || weight[35] != "72.5" || weight[36] != apimodel.KILOGRAMS {
+		t.Errorf("Unexpected weight row [%v]", weight)
+	}
+
+	if a1c := rows[30]; a1c[0] != A1C_RECORD_TYPE || a1c[17] != "6.2" {
+		t.Errorf("Unexpected a1c row [%v]", a1c)
+	}
+
+	if lab := rows[31]; lab[0] != LAB_A1C_RECORD_TYPE || lab[1] != "2016-04-19T00:00:00Z" || lab[17] != "6.4" || lab[37] != "6.2" || lab[38] != "2016-04-20T00:00:00Z" {
+		t.Errorf("Unexpected lab a1c row [%v]", lab)
+	}
+
+	if session := rows[32]; session[0] != SENSOR_SESSION_RECORD_TYPE || session[1] != "2016-03-30T00:00:00Z" || session[41] != "2016-04-09T00:00:00Z" ||
+		session[42] != "8G1234" || session[43] != model.RECORDED_SENSOR_SESSION {
+		t.Errorf("Unexpected first sensor session row [%v]", session)
+	}
+
+	if ongoing := rows[33]; ongoing[0] != SENSOR_SESSION_RECORD_TYPE || ongoing[40] != "2016-04-19T00:00:00Z" || ongoing[41] != "" {
+		t.Errorf("Expected the ongoing sensor session last without an end but got [%v]", ongoing)
+	}
+}
+
+func TestEmptyCsvExportHasHeader(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	store.SetRepository(store.NewMemoryRepository())
+	c := context.Background()
+	storeUser(t, c, EXPORT_USER)
+
+	rows, err := csv.NewReader(bytes.NewReader(export(t, c, CSV_FORMAT))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 {
+		t.Errorf("Expected only the header but got [%d] rows", len(rows))
+	}
+}
+
+func TestDexcomXmlExportCanBeImportedBack(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c := setupExportData(t)
+
+	content := export(t, c, DEXCOM_XML_FORMAT)
+	if _, err := importer.ParseContent(c, bytes.NewReader(content), IMPORT_USER, util.GLUKIT_EPOCH_TIME); err != nil {
+		t.Fatal(err)
+	}
+
+	lowerBound := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	upperBound := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	exportedReads, _ := store.GetGlucoseReads(c, EXPORT_USER, lowerBound, upperBound)
+	importedReads, err := store.GetGlucoseReads(c, IMPORT_USER, lowerBound, upperBound)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(importedReads) != len(exportedReads) {
+		t.Fatalf("Expected [%d] reads imported back but got [%d]", len(exportedReads), len(importedReads))
+	}
+
+	// Dexcom files only have local times so the timezone comes back as a fixed offset
+	for i := range importedReads {
+		exported, imported := exportedReads[i], importedReads[i]
+		if imported.Time.Timestamp != exported.Time.Timestamp || imported.Unit != exported.Unit || imported.Value != exported.Value {
+			t.Errorf("Expected read [%v] imported back but got [%v]", exported, imported)
+		}
+
+		if expectedOffset := exported.GetTime().Format("-0700"); imported.Time.TimeZoneId != expectedOffset {
+			t.Errorf("Expected read with offset [%s] but got [%s]", expectedOffset, imported.Time.TimeZoneId)
+		}
+	}
+
+	calibrations, _ := store.GetCalibrations(c, IMPORT_USER, lowerBound, upperBound)
+	if len(calibrations) != 2 || calibrations[0].Value != 140 {
+		t.Errorf("Expected [2] calibrations of [140] imported back but got [%v]", calibrations)
+	}
+
+	injections, _ := store.GetInjections(c, IMPORT_USER, lowerBound, upperBound)
+	if len(injections) != 2 || injections[0].Units != 4.5 {
+		t.Errorf("Expected [2] injections of [4.5] units imported back but got [%v]", injections)
+	}
+
+	meals, _ := store.GetMeals(c, IMPORT_USER, lowerBound, upperBound)
+	if len(meals) != 2 || meals[0].Carbohydrates != 45 {
+		t.Errorf("Expected [2] meals of [45] carbs imported back but got [%v]", meals)
+	}
+
+	exercises, _ := store.GetExercises(c, IMPORT_USER, lowerBound, upperBound)
+	if len(exercises) != 2 || exercises[0].DurationMinutes != 30 || exercises[0].Intensity != "Heavy" {
+		t.Errorf("Expected [2] heavy exercises of [30] minutes imported back but got [%v]", exercises)
+	}
+}
+
+func TestUnknownExportFormat(t *testing.T) {
+	if _, err := NewRecordWriter("pdf", &bytes.Buffer{}); err == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+}
@@ -0,0 +1,160 @@
+package exporter
+
+import (
+	"encoding/json"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/model"
+	"io"
+)
+
+const (
+	// Types of exported records
+	GLUCOSE_READ_RECORD_TYPE   = "glucoseRead"
+	CALIBRATION_RECORD_TYPE    = "calibration"
+	INJECTION_RECORD_TYPE      = "injection"
+	MEAL_RECORD_TYPE           = "meal"
+	EXERCISE_RECORD_TYPE       = "exercise"
+	BASAL_RATE_RECORD_TYPE     = "basalRate"
+	TEMP_BASAL_RECORD_TYPE     = "tempBasal"
+	PUMP_SUSPEND_RECORD_TYPE   = "pumpSuspend"
+	EXTENDED_BOLUS_RECORD_TYPE = "extendedBolus"
+	KETONE_RECORD_TYPE         = "ketone"
+	BLOOD_PRESSURE_RECORD_TYPE = "bloodPressure"
+	WEIGHT_RECORD_TYPE         = "weight"
+	GLUKIT_SCORE_RECORD_TYPE   = "glukitScore"
+	A1C_RECORD_TYPE            = "a1cEstimate"
+	LAB_A1C_RECORD_TYPE        = "labA1C"
+	SENSOR_SESSION_RECORD_TYPE = "sensorSession"
+)
+
+// JsonLinesRecord is a line of a JSON Lines export. Records are encoded the same way the api returns them.
+type JsonLinesRecord struct {
+	Type   string      `json:"type"`
+	Record interface{} `json:"record"`
+}
+
+// JsonLinesWriter writes each record as a json object on its own line
+type JsonLinesWriter struct {
+	encoder *json.Encoder
+}
+
+// NewJsonLinesWriter returns a new JsonLinesWriter writing to w
+func NewJsonLinesWriter(w io.Writer) *JsonLinesWriter {
+	return &JsonLinesWriter{json.NewEncoder(w)}
+}
+
+func (w *JsonLinesWriter) WriteCalibrations(calibrations []apimodel.CalibrationRead) (err error) {
+	for i := 0; err == nil && i < len(calibrations); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{CALIBRATION_RECORD_TYPE, calibrations[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) WriteGlucoseReads(reads []apimodel.GlucoseRead) (err error) {
+	for i := 0; err == nil && i < len(reads); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{GLUCOSE_READ_RECORD_TYPE, reads[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) WriteInjections(injections []apimodel.Injection) (err error) {
+	for i := 0; err == nil && i < len(injections); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{INJECTION_RECORD_TYPE, injections[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) WriteMeals(meals []apimodel.Meal) (err error) {
+	for i := 0; err == nil && i < len(meals); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{MEAL_RECORD_TYPE, meals[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) WriteExercises(exercises []apimodel.Exercise) (err error) {
+	for i := 0; err == nil && i < len(exercises); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{EXERCISE_RECORD_TYPE, exercises[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) WriteBasalRates(basalRates []apimodel.BasalRate) (err error) {
+	for i := 0; err == nil && i < len(basalRates); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{BASAL_RATE_RECORD_TYPE, basalRates[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) WriteTempBasals(tempBasals []apimodel.TempBasal) (err error) {
+	for i := 0; err == nil && i < len(tempBasals); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{TEMP_BASAL_RECORD_TYPE, tempBasals[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) WritePumpSuspends(pumpSuspends []apimodel.PumpSuspend) (err error) {
+	for i := 0; err == nil && i < len(pumpSuspends); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{PUMP_SUSPEND_RECORD_TYPE, pumpSuspends[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) WriteExtendedBoluses(extendedBoluses []apimodel.ExtendedBolus) (err error) {
+	for i := 0; err == nil && i < len(extendedBoluses); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{EXTENDED_BOLUS_RECORD_TYPE, extendedBoluses[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) WriteKetones(ketones []apimodel.Ketone) (err error) {
+	for i := 0; err == nil && i < len(ketones); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{KETONE_RECORD_TYPE, ketones[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) WriteBloodPressures(bloodPressures []apimodel.BloodPressure) (err error) {
+	for i := 0; err == nil && i < len(bloodPressures); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{BLOOD_PRESSURE_RECORD_TYPE, bloodPressures[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) WriteWeights(weights []apimodel.Weight) (err error) {
+	for i := 0; err == nil && i < len(weights); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{WEIGHT_RECORD_TYPE, weights[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) WriteGlukitScores(scores []model.GlukitScore) (err error) {
+	for i := 0; err == nil && i < len(scores); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{GLUKIT_SCORE_RECORD_TYPE, scores[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) WriteA1CEstimates(a1cs []model.A1CEstimate) (err error) {
+	for i := 0; err == nil && i < len(a1cs); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{A1C_RECORD_TYPE, a1cs[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) WriteLabA1Cs(labs []model.LabA1C) (err error) {
+	for i := 0; err == nil && i < len(labs); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{LAB_A1C_RECORD_TYPE, labs[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) WriteSensorSessions(sessions []model.SensorSession) (err error) {
+	for i := 0; err == nil && i < len(sessions); i++ {
+		err = w.encoder.Encode(JsonLinesRecord{SENSOR_SESSION_RECORD_TYPE, sessions[i]})
+	}
+	return err
+}
+
+func (w *JsonLinesWriter) Close() (err error) {
+	return nil
+}
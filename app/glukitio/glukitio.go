@@ -9,92 +9,18 @@ import (
 // but failed to return an explicit error.
 var ErrShortWrite = errors.New("short write")
 
-// CalibrationBatchWriter is the interface that wraps the basic
-// WriteCalibrationBatch and WriteCalibrationBatches methods.
-//
-// WriteCalibrationBatch writes len(p) model.CalibrationRead from p to the
-// underlying data stream. It returns the number of elements written
-// from p (0 <= n <= len(p)) and any error encountered that caused the
-// write to stop early. Write must return a non-nil error if it returns n < len(p).
-//
-// WriteCalibrationBatches writes len(p) model.DayOfCalibrationReads from p to the
-// underlying data stream. It returns the number of batch elements written
-// from p (0 <= n <= len(p)) and any error encountered that caused the
-// write to stop early. Write must return a non-nil error if it returns n < len(p).
-type CalibrationBatchWriter interface {
-	WriteCalibrationBatch(p []apimodel.CalibrationRead) (w CalibrationBatchWriter, err error)
-	WriteCalibrationBatches(p []apimodel.DayOfCalibrationReads) (w CalibrationBatchWriter, err error)
-	Flush() (w CalibrationBatchWriter, err error)
-}
-
-// GlucoseReadBatchWriter is the interface that wraps the basic
-// WriteGlucoseReadBatch and WriteGlucoseReadBatches methods.
-//
-// WriteGlucoseReadBatch writes len(p) model.CalibrationRead from p to the
-// underlying data stream. It returns the number of elements written
-// from p (0 <= n <= len(p)) and any error encountered that caused the
-// write to stop early. Write must return a non-nil error if it returns n < len(p).
-//
-// WriteGlucoseReadBatches writes len(p) model.DayOfCalibrationReads from p to the
-// underlying data stream. It returns the number of batch elements written
-// from p (0 <= n <= len(p)) and any error encountered that caused the
-// write to stop early. Write must return a non-nil error if it returns n < len(p).
-type GlucoseReadBatchWriter interface {
-	WriteGlucoseReadBatch(p []apimodel.GlucoseRead) (w GlucoseReadBatchWriter, err error)
-	WriteGlucoseReadBatches(p []apimodel.DayOfGlucoseReads) (w GlucoseReadBatchWriter, err error)
-	Flush() (w GlucoseReadBatchWriter, err error)
-}
-
-// InjectionBatchWriter is the interface that wraps the basic
-// WriteInjectionBatch and WriteInjectionBatches methods.
-//
-// WriteInjectionBatch writes len(p) model.Injection from p to the
-// underlying data stream. It returns the number of elements written
-// from p (0 <= n <= len(p)) and any error encountered that caused the
-// write to stop early. Write must return a non-nil error if it returns n < len(p).
-//
-// WriteInjectionBatches writes len(p) model.DayOfInjectionss from p to the
-// underlying data stream. It returns the number of batch elements written
-// from p (0 <= n <= len(p)) and any error encountered that caused the
-// write to stop early. Write must return a non-nil error if it returns n < len(p).
-type InjectionBatchWriter interface {
-	WriteInjectionBatch(p []apimodel.Injection) (w InjectionBatchWriter, err error)
-	WriteInjectionBatches(p []apimodel.DayOfInjections) (w InjectionBatchWriter, err error)
-	Flush() (w InjectionBatchWriter, err error)
-}
-
-// MealBatchWriter is the interface that wraps the basic
-// WriteMealBatch and WriteMealBatches methods.
-//
-// WriteMealBatch writes len(p) model.Meal from p to the
-// underlying data stream. It returns the number of elements written
-// from p (0 <= n <= len(p)) and any error encountered that caused the
-// write to stop early. Write must return a non-nil error if it returns n < len(p).
-//
-// WriteMealBatches writes len(p) model.DayOfMealss from p to the
-// underlying data stream. It returns the number of batch elements written
-// from p (0 <= n <= len(p)) and any error encountered that caused the
-// write to stop early. Write must return a non-nil error if it returns n < len(p).
-type MealBatchWriter interface {
-	WriteMealBatch(p []apimodel.Meal) (w MealBatchWriter, err error)
-	WriteMealBatches(p []apimodel.DayOfMeals) (w MealBatchWriter, err error)
-	Flush() (w MealBatchWriter, err error)
-}
-
-// ExerciseBatchWriter is the interface that wraps the basic
-// WriteExerciseBatch and WriteExerciseBatches methods.
-//
-// WriteExerciseBatch writes len(p) model.Exercise from p to the
-// underlying data stream. It returns the number of elements written
-// from p (0 <= n <= len(p)) and any error encountered that caused the
-// write to stop early. Write must return a non-nil error if it returns n < len(p).
-//
-// WriteExerciseBatches writes len(p) model.DayOfExercisess from p to the
-// underlying data stream. It returns the number of batch elements written
-// from p (0 <= n <= len(p)) and any error encountered that caused the
-// write to stop early. Write must return a non-nil error if it returns n < len(p).
-type ExerciseBatchWriter interface {
-	WriteExerciseBatch(p []apimodel.Exercise) (w ExerciseBatchWriter, err error)
-	WriteExerciseBatches(p []apimodel.DayOfExercises) (w ExerciseBatchWriter, err error)
-	Flush() (w ExerciseBatchWriter, err error)
+// BatchWriter is the interface that wraps the basic WriteBatch and WriteBatches methods for a kind of data whose
+// elements of type T are grouped in days of type D.
+//
+// WriteBatch writes len(p) elements from p, as a single day, to the
+// underlying data stream. It returns the writer to use for subsequent writes
+// and any error encountered that caused the write to stop early.
+//
+// WriteBatches writes len(p) days from p to the
+// underlying data stream. It returns the writer to use for subsequent writes
+// and any error encountered that caused the write to stop early.
+type BatchWriter[T apimodel.Timestamped, D apimodel.DayOf[T]] interface {
+	WriteBatch(p []T) (w BatchWriter[T, D], err error)
+	WriteBatches(p []D) (w BatchWriter[T, D], err error)
+	Flush() (w BatchWriter[T, D], err error)
 }
@@ -0,0 +1,321 @@
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/bufio"
+	"github.com/alexandre-normand/glukit/app/glukitio"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/streaming"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Clarity event types
+	CLARITY_EGV_EVENT_TYPE         = "EGV"
+	CLARITY_CALIBRATION_EVENT_TYPE = "Calibration"
+	CLARITY_CARBS_EVENT_TYPE       = "Carbs"
+	CLARITY_INSULIN_EVENT_TYPE     = "Insulin"
+	CLARITY_EXERCISE_EVENT_TYPE    = "Exercise"
+
+	// Clarity insulin event subtypes
+	CLARITY_FAST_ACTING_SUBTYPE = "Fast-Acting"
+	CLARITY_LONG_ACTING_SUBTYPE = "Long-Acting"
+
+	// Prefixes of the Clarity header columns we read, the remainder of the column name holds its unit
+	CLARITY_TIMESTAMP_COLUMN  = "Timestamp"
+	CLARITY_EVENT_TYPE_COLUMN = "Event Type"
+	CLARITY_SUBTYPE_COLUMN    = "Event Subtype"
+	CLARITY_GLUCOSE_COLUMN    = "Glucose Value"
+	CLARITY_INSULIN_COLUMN    = "Insulin Value"
+	CLARITY_CARBS_COLUMN      = "Carb Value"
+	CLARITY_DURATION_COLUMN   = "Duration"
+
+	CLARITY_TIMEFORMAT = "2006-01-02T15:04:05"
+)
+
+var ErrNoClarityHeader = errors.New("Not a Dexcom Clarity export, no header found")
+
+// BatchWriters are the writers that imported data is streamed to
+type BatchWriters struct {
+	GlucoseReads glukitio.BatchWriter[apimodel.GlucoseRead, apimodel.DayOfGlucoseReads]
+	Calibrations glukitio.BatchWriter[apimodel.CalibrationRead, apimodel.DayOfCalibrationReads]
+	Injections   glukitio.BatchWriter[apimodel.Injection, apimodel.DayOfInjections]
+	Meals        glukitio.BatchWriter[apimodel.Meal, apimodel.DayOfMeals]
+	Exercises    glukitio.BatchWriter[apimodel.Exercise, apimodel.DayOfExercises]
+}
+
+// NewDataStoreBatchWriters returns the batching writers that store imported data for a user
+func NewDataStoreBatchWriters(context context.Context, userEmail string) BatchWriters {
+	return BatchWriters{
+		GlucoseReads: bufio.NewWriterSize(apimodel.GLUCOSE_READ_KIND, store.NewDataStoreGlucoseReadBatchWriter(context, userEmail), store.GLUKIT_SCORE_PUT_MULTI_SIZE),
+		Calibrations: bufio.NewWriterSize(apimodel.CALIBRATION_READ_KIND, store.NewDataStoreBatchWriter(context, userEmail, apimodel.CALIBRATION_READ_KIND), store.GLUKIT_SCORE_PUT_MULTI_SIZE),
+		Injections:   bufio.NewWriterSize(apimodel.INJECTION_KIND, store.NewDataStoreBatchWriter(context, userEmail, apimodel.INJECTION_KIND), store.GLUKIT_SCORE_PUT_MULTI_SIZE),
+		Meals:        bufio.NewWriterSize(apimodel.MEAL_KIND, store.NewDataStoreBatchWriter(context, userEmail, apimodel.MEAL_KIND), store.GLUKIT_SCORE_PUT_MULTI_SIZE),
+		Exercises:    bufio.NewWriterSize(apimodel.EXERCISE_KIND, store.NewDataStoreBatchWriter(context, userEmail, apimodel.EXERCISE_KIND), store.GLUKIT_SCORE_PUT_MULTI_SIZE),
+	}
+}
+
+// clarityColumns holds the index of the columns we read, as found in the header
+type clarityColumns struct {
+	timestamp int
+	eventType int
+	subtype   int
+	glucose   int
+	insulin   int
+	carbs     int
+	duration  int
+	unit      apimodel.GlucoseUnit
+}
+
+// ParseClarityContent parses a Dexcom Clarity csv export and stores its glucose reads, calibrations, injections, meals and
+// exercises for the user. Clarity timestamps are local times without timezone information so they are interpreted in
+// the given location. Records at or before startTime are skipped.
+func ParseClarityContent(context context.Context, reader io.Reader, userEmail string, location *time.Location, startTime time.Time) (lastReadTime time.Time, err error) {
+	return StreamClarityContent(reader, location, startTime, NewDataStoreBatchWriters(context, userEmail))
+}
+
+// StreamClarityContent parses a Dexcom Clarity csv export and streams its records to the writers in batches of days.
+// Rows of event types we don't import (alerts, device info, etc.) and glucose values out of the sensor range (Low/High)
+// are skipped. Clarity doesn't guarantee that rows are sorted by time so the records are sorted before they're
+// streamed. It returns the time of the last glucose read or startTime if there were none.
+func StreamClarityContent(reader io.Reader, location *time.Location, startTime time.Time, writers BatchWriters) (lastReadTime time.Time, err error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	csvReader.TrimLeadingSpace = true
+
+	reads := make([]apimodel.GlucoseRead, 0)
+	calibrations := make([]apimodel.CalibrationRead, 0)
+	injections := make([]apimodel.Injection, 0)
+	meals := make([]apimodel.Meal, 0)
+	exercises := make([]apimodel.Exercise, 0)
+
+	lastReadTime = startTime
+	var columns *clarityColumns
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return lastReadTime, err
+		}
+
+		if columns == nil {
+			columns = parseClarityHeader(record)
+			continue
+		}
+
+		eventType := columns.value(record, columns.eventType)
+		if eventType != CLARITY_EGV_EVENT_TYPE && eventType != CLARITY_CALIBRATION_EVENT_TYPE && eventType != CLARITY_CARBS_EVENT_TYPE &&
+			eventType != CLARITY_INSULIN_EVENT_TYPE && eventType != CLARITY_EXERCISE_EVENT_TYPE {
+			continue
+		}
+
+		recordTime, err := parseClarityTime(columns.value(record, columns.timestamp), location)
+		if err != nil {
+			return lastReadTime, err
+		}
+
+		// Skip everything that's before the last import's read time
+		if recordTime.Unix() <= startTime.Unix() {
+			continue
+		}
+
+		eventTime := apimodel.Time{apimodel.GetTimeMillis(recordTime), location.String()}
+
+		switch eventType {
+		case CLARITY_EGV_EVENT_TYPE:
+			value, ok, err := parseClarityGlucoseValue(columns.value(record, columns.glucose))
+			if err != nil {
+				return lastReadTime, err
+			} else if !ok {
+				continue
+			}
+
+			reads = append(reads, apimodel.GlucoseRead{eventTime, columns.unit, value})
+			if recordTime.After(lastReadTime) {
+				lastReadTime = recordTime
+			}
+		case CLARITY_CALIBRATION_EVENT_TYPE:
+			value, ok, err := parseClarityGlucoseValue(columns.value(record, columns.glucose))
+			if err != nil {
+				return lastReadTime, err
+			} else if !ok {
+				continue
+			}
+
+			calibrations = append(calibrations, apimodel.CalibrationRead{eventTime, columns.unit, value})
+		case CLARITY_CARBS_EVENT_TYPE:
+			carbs, err := parseClarityFloat(columns.value(record, columns.carbs))
+			if err != nil {
+				return lastReadTime, err
+			}
+
+			meals = append(meals, apimodel.Meal{eventTime, carbs, 0., 0., 0.})
+		case CLARITY_INSULIN_EVENT_TYPE:
+			units, err := parseClarityFloat(columns.value(record, columns.insulin))
+			if err != nil {
+				return lastReadTime, err
+			}
+
+			insulinType := ""
+			switch columns.value(record, columns.subtype) {
+			case CLARITY_FAST_ACTING_SUBTYPE:
+				insulinType = apimodel.FAST_ACTING_INSULIN_TYPE
+			case CLARITY_LONG_ACTING_SUBTYPE:
+				insulinType = apimodel.LONG_ACTING_INSULIN_TYPE
+			}
+
+			injections = append(injections, apimodel.Injection{eventTime, units, "", insulinType})
+		case CLARITY_EXERCISE_EVENT_TYPE:
+			duration, err := parseClarityDuration(columns.value(record, columns.duration))
+			if err != nil {
+				return lastReadTime, err
+			}
+
+			exercises = append(exercises, apimodel.Exercise{eventTime, int(duration.Minutes()), columns.value(record, columns.subtype), ""})
+		}
+	}
+
+	if columns == nil {
+		return lastReadTime, ErrNoClarityHeader
+	}
+
+	sort.Stable(apimodel.GlucoseReadSlice(reads))
+	sort.Stable(apimodel.CalibrationReadSlice(calibrations))
+	sort.Stable(apimodel.InjectionSlice(injections))
+	sort.Stable(apimodel.MealSlice(meals))
+	sort.Stable(apimodel.ExerciseSlice(exercises))
+
+	if err = streamAll(reads, writers.GlucoseReads); err != nil {
+		return lastReadTime, err
+	}
+
+	if err = streamAll(calibrations, writers.Calibrations); err != nil {
+		return lastReadTime, err
+	}
+
+	if err = streamAll(injections, writers.Injections); err != nil {
+		return lastReadTime, err
+	}
+
+	if err = streamAll(meals, writers.Meals); err != nil {
+		return lastReadTime, err
+	}
+
+	if err = streamAll(exercises, writers.Exercises); err != nil {
+		return lastReadTime, err
+	}
+
+	return lastReadTime, nil
+}
+
+// streamAll streams elements sorted by time to the writer in batches of days and flushes anything pending
+func streamAll[T apimodel.Timestamped, D apimodel.DayOf[T]](elements []T, writer glukitio.BatchWriter[T, D]) (err error) {
+	streamer := streaming.NewStreamerDuration(writer, apimodel.DAY_OF_DATA_DURATION)
+	if streamer, err = streamer.WriteAll(elements); err != nil {
+		return err
+	}
+
+	_, err = streamer.Close()
+	return err
+}
+
+// parseClarityHeader returns the columns of a Clarity header or nil if the record isn't the header. The glucose unit
+// is taken from the glucose column name, i.e. "Glucose Value (mmol/L)".
+func parseClarityHeader(record []string) (columns *clarityColumns) {
+	columns = &clarityColumns{timestamp: -1, eventType: -1, subtype: -1, glucose: -1, insulin: -1, carbs: -1, duration: -1, unit: apimodel.MG_PER_DL}
+	for i, name := range record {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		switch {
+		case strings.HasPrefix(name, CLARITY_TIMESTAMP_COLUMN):
+			columns.timestamp = i
+		case strings.HasPrefix(name, CLARITY_EVENT_TYPE_COLUMN):
+			columns.eventType = i
+		case strings.HasPrefix(name, CLARITY_SUBTYPE_COLUMN):
+			columns.subtype = i
+		case strings.HasPrefix(name, CLARITY_GLUCOSE_COLUMN):
+			columns.glucose = i
+			if strings.Contains(strings.ToLower(name), "mmol/l") {
+				columns.unit = apimodel.MMOL_PER_L
+			}
+		case strings.HasPrefix(name, CLARITY_INSULIN_COLUMN):
+			columns.insulin = i
+		case strings.HasPrefix(name, CLARITY_CARBS_COLUMN):
+			columns.carbs = i
+		case strings.HasPrefix(name, CLARITY_DURATION_COLUMN):
+			columns.duration = i
+		}
+	}
+
+	if columns.timestamp < 0 || columns.eventType < 0 {
+		return nil
+	}
+
+	return columns
+}
+
+// value returns the trimmed value of a column or an empty string if the record doesn't have that column
+func (columns *clarityColumns) value(record []string, index int) string {
+	if index < 0 || index >= len(record) {
+		return ""
+	}
+
+	return strings.TrimSpace(record[index])
+}
+
+func parseClarityTime(value string, location *time.Location) (recordTime time.Time, err error) {
+	if recordTime, err = time.ParseInLocation(CLARITY_TIMEFORMAT, value, location); err != nil {
+		if recordTime, err = time.ParseInLocation("2006-01-02 15:04:05", value, location); err != nil {
+			return recordTime, errors.New(fmt.Sprintf("Invalid timestamp [%s]: %v", value, err))
+		}
+	}
+
+	return recordTime, nil
+}
+
+// parseClarityGlucoseValue parses a glucose value. Values out of the sensor range are exported as Low or High and are
+// reported as not ok.
+func parseClarityGlucoseValue(value string) (glucoseValue float32, ok bool, err error) {
+	if strings.EqualFold(value, "Low") || strings.EqualFold(value, "High") || value == "" {
+		return 0., false, nil
+	}
+
+	glucoseValue, err = parseClarityFloat(value)
+	return glucoseValue, err == nil && glucoseValue > 0, err
+}
+
+func parseClarityFloat(value string) (floatValue float32, err error) {
+	if value == "" {
+		return 0., nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		return 0., errors.New(fmt.Sprintf("Invalid number [%s]: %v", value, err))
+	}
+
+	return float32(parsed), nil
+}
+
+// parseClarityDuration parses a duration formatted as hh:mm:ss
+func parseClarityDuration(value string) (duration time.Duration, err error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	var hours, minutes, seconds int
+	if _, err = fmt.Sscanf(value, "%d:%d:%d", &hours, &minutes, &seconds); err != nil {
+		return 0, errors.New(fmt.Sprintf("Invalid duration [%s]: %v", value, err))
+	}
+
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds)*time.Second, nil
+}
@@ -0,0 +1,165 @@
+package importer_test
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/glukitio"
+	. "github.com/alexandre-normand/glukit/app/importer"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingWriter struct {
+	reads        []apimodel.GlucoseRead
+	calibrations []apimodel.CalibrationRead
+	injections   []apimodel.Injection
+	meals        []apimodel.Meal
+	exercises    []apimodel.Exercise
+}
+
+// elementsWriter records the elements of a kind written to it
+type elementsWriter[T apimodel.Timestamped, D apimodel.DayOf[T]] struct{ elements *[]T }
+
+func (w elementsWriter[T, D]) WriteBatch(p []T) (glukitio.BatchWriter[T, D], error) {
+	*w.elements = append(*w.elements, p...)
+	return w, nil
+}
+
+func (w elementsWriter[T, D]) WriteBatches(p []D) (glukitio.BatchWriter[T, D], error) {
+	for _, day := range p {
+		*w.elements = append(*w.elements, day.GetElements()...)
+	}
+	return w, nil
+}
+
+func (w elementsWriter[T, D]) Flush() (glukitio.BatchWriter[T, D], error) {
+	return w, nil
+}
+
+func newRecordingBatchWriters() (*recordingWriter, BatchWriters) {
+	r := new(recordingWriter)
+	return r, BatchWriters{
+		GlucoseReads: elementsWriter[apimodel.GlucoseRead, apimodel.DayOfGlucoseReads]{&r.reads},
+		Calibrations: elementsWriter[apimodel.CalibrationRead, apimodel.DayOfCalibrationReads]{&r.calibrations},
+		Injections:   elementsWriter[apimodel.Injection, apimodel.DayOfInjections]{&r.injections},
+		Meals:        elementsWriter[apimodel.Meal, apimodel.DayOfMeals]{&r.meals},
+		Exercises:    elementsWriter[apimodel.Exercise, apimodel.DayOfExercises]{&r.exercises},
+	}
+}
+
+const mgPerDlClarityExport = `Index,Timestamp (YYYY-MM-DDThh:mm:ss),Event Type,Event Subtype,Patient Info,Device Info,Source Device ID,Glucose Value (mg/dL),Insulin Value (u),Carb Value (grams),Duration (hh:mm:ss),Glucose Rate of Change (mg/dL/min),Transmitter Time (Long Integer)
+1,,FirstName,,Jane,,,,,,,,
+2,,Device,,,G6 Mobile App,Android G6,,,,,,
+3,2018-04-18T07:55:00,EGV,,,,Android G6,Low,,,,,1000
+4,2018-04-18T08:00:00,EGV,,,,Android G6,101,,,,,1300
+5,2018-04-18T08:02:00,Calibration,,,,Android G6,98,,,,,1420
+6,2018-04-18T08:05:00,EGV,,,,Android G6,110,,,,,1600
+7,2018-04-18T08:10:00,Carbs,,,,Android G6,,,45,,,
+8,2018-04-18T08:10:00,Insulin,Fast-Acting,,,Android G6,,4.5,,,,
+9,2018-04-18T21:00:00,Insulin,Long-Acting,,,Android G6,,18,,,,
+10,2018-04-18T17:30:00,Exercise,Medium,,,Android G6,,,,00:45:00,,
+11,2018-04-18T08:15:00,Alert,High,,,Android G6,250,,,,,
+`
+
+func TestClarityExportIsImported(t *testing.T) {
+	location, _ := time.LoadLocation("America/Montreal")
+	r, writers := newRecordingBatchWriters()
+
+	lastReadTime, err := StreamClarityContent(strings.NewReader(mgPerDlClarityExport), location, time.Unix(0, 0), writers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLastReadTime := time.Date(2018, 4, 18, 8, 5, 0, 0, location)
+	if !lastReadTime.Equal(expectedLastReadTime) {
+		t.Errorf("Expected last read time of [%s] but got [%s]", expectedLastReadTime, lastReadTime)
+	}
+
+	if len(r.reads) != 2 || r.reads[0].Value != 101 || r.reads[1].Value != 110 || r.reads[0].Unit != apimodel.MG_PER_DL {
+		t.Errorf("Unexpected glucose reads [%v]", r.reads)
+	}
+
+	if readTime := r.reads[0].GetTime(); readTime.Hour() != 8 || readTime.Location().String() != "America/Montreal" {
+		t.Errorf("Expected read at 8h in America/Montreal but got [%s]", readTime)
+	}
+
+	if len(r.calibrations) != 1 || r.calibrations[0].Value != 98 {
+		t.Errorf("Unexpected calibrations [%v]", r.calibrations)
+	}
+
+	if len(r.meals) != 1 || r.meals[0].Carbohydrates != 45 {
+		t.Errorf("Unexpected meals [%v]", r.meals)
+	}
+
+	if len(r.injections) != 2 || r.injections[0].Units != 4.5 || r.injections[0].InsulinType != apimodel.FAST_ACTING_INSULIN_TYPE ||
+		r.injections[1].Units != 18 || r.injections[1].InsulinType != apimodel.LONG_ACTING_INSULIN_TYPE {
+		t.Errorf("Unexpected injections [%v]", r.injections)
+	}
+
+	if len(r.exercises) != 1 || r.exercises[0].DurationMinutes != 45 || r.exercises[0].Intensity != "Medium" {
+		t.Errorf("Unexpected exercises [%v]", r.exercises)
+	}
+}
+
+func TestClarityMmolPerLExportIsDetected(t *testing.T) {
+	export := "\ufeffIndex,Timestamp (YYYY-MM-DDThh:mm:ss),Event Type,Event Subtype,Glucose Value (mmol/L)\n" +
+		"1,2018-04-18T08:00:00,EGV,,5.6\n" +
+		"2,2018-04-18T08:05:00,EGV,,High\n"
+
+	r, writers := newRecordingBatchWriters()
+	if _, err := StreamClarityContent(strings.NewReader(export), time.UTC, time.Unix(0, 0), writers); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.reads) != 1 || r.reads[0].Unit != apimodel.MMOL_PER_L || r.reads[0].Value != 5.6 {
+		t.Errorf("Expected a single read of [5.6] mmol/L but got [%v]", r.reads)
+	}
+}
+
+func TestClarityRecordsBeforeStartTimeAreSkipped(t *testing.T) {
+	r, writers := newRecordingBatchWriters()
+	startTime := time.Date(2018, 4, 18, 8, 0, 0, 0, time.UTC)
+
+	if _, err := StreamClarityContent(strings.NewReader(mgPerDlClarityExport), time.UTC, startTime, writers); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.reads) != 1 || r.reads[0].Value != 110 {
+		t.Errorf("Expected only the read after the start time but got [%v]", r.reads)
+	}
+}
+
+func TestClarityRowsAreSortedByTime(t *testing.T) {
+	export := "Index,Timestamp (YYYY-MM-DDThh:mm:ss),Event Type,Event Subtype,Glucose Value (mg/dL),Carb Value (grams)\n" +
+		"1,2018-04-19T08:05:00,EGV,,130,\n" +
+		"2,2018-04-18T08:00:00,EGV,,101,\n" +
+		"3,2018-04-19T08:00:00,EGV,,120,\n" +
+		"4,2018-04-19T12:00:00,Carbs,,,60\n" +
+		"5,2018-04-18T08:05:00,EGV,,110,\n" +
+		"6,2018-04-18T12:00:00,Carbs,,,30\n"
+
+	r, writers := newRecordingBatchWriters()
+	lastReadTime, err := StreamClarityContent(strings.NewReader(export), time.UTC, time.Unix(0, 0), writers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := time.Date(2018, 4, 19, 8, 5, 0, 0, time.UTC); !lastReadTime.Equal(expected) {
+		t.Errorf("Expected last read time of [%s] but got [%s]", expected, lastReadTime)
+	}
+
+	if len(r.reads) != 4 || r.reads[0].Value != 101 || r.reads[1].Value != 110 || r.reads[2].Value != 120 || r.reads[3].Value != 130 {
+		t.Errorf("Expected reads sorted by time but got [%v]", r.reads)
+	}
+
+	if len(r.meals) != 2 || r.meals[0].Carbohydrates != 30 || r.meals[1].Carbohydrates != 60 {
+		t.Errorf("Expected meals sorted by time but got [%v]", r.meals)
+	}
+}
+
+func TestClarityExportWithoutHeader(t *testing.T) {
+	_, writers := newRecordingBatchWriters()
+	if _, err := StreamClarityContent(strings.NewReader("<Patient></Patient>\n"), time.UTC, time.Unix(0, 0), writers); err != ErrNoClarityHeader {
+		t.Errorf("Expected [%v] but got [%v]", ErrNoClarityHeader, err)
+	}
+}
@@ -6,44 +6,44 @@ import (
 	"github.com/alexandre-normand/glukit/app/apimodel"
 	"github.com/alexandre-normand/glukit/app/bufio"
 	"github.com/alexandre-normand/glukit/app/dexcomimporter"
+	"github.com/alexandre-normand/glukit/app/log"
 	"github.com/alexandre-normand/glukit/app/store"
 	"github.com/alexandre-normand/glukit/app/streaming"
 	"github.com/alexandre-normand/glukit/app/util"
 	"context"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/log"
 	"io"
 	"strings"
 	"time"
 )
 
 // ParseContent is the big function that parses the Dexcom xml file. It is given a reader to the file and it parses batches of days of GlucoseReads/Events. It streams the content but
-// keeps some in memory until it reaches a full batch of a type. A batch is an array of DayOf[GlucoseReads,Injection,Meals,Exercises]. A batch is flushed to the datastore once it reaches
-// the given batchSize or we reach the end of the file.
-func ParseContent(context context.Context, reader io.Reader, parentKey *datastore.Key, startTime time.Time, readsBatchHandler func(context context.Context, userProfileKey *datastore.Key, meals []apimodel.DayOfGlucoseReads) ([]*datastore.Key, error), mealsBatchHandler func(context context.Context, userProfileKey *datastore.Key, daysOfMeals []apimodel.DayOfMeals) ([]*datastore.Key, error), injectionBatchHandler func(context context.Context, userProfileKey *datastore.Key, daysOfInjections []apimodel.DayOfInjections) ([]*datastore.Key, error), exerciseBatchHandler func(context context.Context, userProfileKey *datastore.Key, daysOfExercises []apimodel.DayOfExercises) ([]*datastore.Key, error)) (lastReadTime time.Time, err error) {
+// keeps some in memory until it reaches a full batch of a type. A batch is an array of DayOf[GlucoseReads,Injection,Meals,Exercises]. A batch is flushed to the store once it reaches
+// the given batchSize or we reach the end of the file. Reads and events at or before startTime are skipped so that an updated file can be re-imported
+// from where the last import left off. It returns the time of the last glucose read imported or startTime if there were none.
+func ParseContent(context context.Context, reader io.Reader, userEmail string, startTime time.Time) (lastReadTime time.Time, err error) {
 	decoder := xml.NewDecoder(reader)
 
-	calibrationDataStoreWriter := store.NewDataStoreCalibrationBatchWriter(context, parentKey)
-	calibrationBatchingWriter := bufio.NewCalibrationWriterSize(calibrationDataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
-	calibrationStreamer := streaming.NewCalibrationReadStreamerDuration(calibrationBatchingWriter, apimodel.DAY_OF_DATA_DURATION)
+	calibrationDataStoreWriter := store.NewDataStoreBatchWriter(context, userEmail, apimodel.CALIBRATION_READ_KIND)
+	calibrationBatchingWriter := bufio.NewWriterSize(apimodel.CALIBRATION_READ_KIND, calibrationDataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
+	calibrationStreamer := streaming.NewStreamerDuration(calibrationBatchingWriter, apimodel.DAY_OF_DATA_DURATION)
 
-	glucoseDataStoreWriter := store.NewDataStoreGlucoseReadBatchWriter(context, parentKey)
-	glucoseBatchingWriter := bufio.NewGlucoseReadWriterSize(glucoseDataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
-	glucoseStreamer := streaming.NewGlucoseStreamerDuration(glucoseBatchingWriter, apimodel.DAY_OF_DATA_DURATION)
+	glucoseDataStoreWriter := store.NewDataStoreGlucoseReadBatchWriter(context, userEmail)
+	glucoseBatchingWriter := bufio.NewWriterSize(apimodel.GLUCOSE_READ_KIND, glucoseDataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
+	glucoseStreamer := streaming.NewStreamerDuration(glucoseBatchingWriter, apimodel.DAY_OF_DATA_DURATION)
 
-	injectionDataStoreWriter := store.NewDataStoreInjectionBatchWriter(context, parentKey)
-	injectionBatchingWriter := bufio.NewInjectionWriterSize(injectionDataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
-	injectionStreamer := streaming.NewInjectionStreamerDuration(injectionBatchingWriter, apimodel.DAY_OF_DATA_DURATION)
+	injectionDataStoreWriter := store.NewDataStoreBatchWriter(context, userEmail, apimodel.INJECTION_KIND)
+	injectionBatchingWriter := bufio.NewWriterSize(apimodel.INJECTION_KIND, injectionDataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
+	injectionStreamer := streaming.NewStreamerDuration(injectionBatchingWriter, apimodel.DAY_OF_DATA_DURATION)
 
-	mealDataStoreWriter := store.NewDataStoreMealBatchWriter(context, parentKey)
-	mealBatchingWriter := bufio.NewMealWriterSize(mealDataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
-	mealStreamer := streaming.NewMealStreamerDuration(mealBatchingWriter, apimodel.DAY_OF_DATA_DURATION)
+	mealDataStoreWriter := store.NewDataStoreBatchWriter(context, userEmail, apimodel.MEAL_KIND)
+	mealBatchingWriter := bufio.NewWriterSize(apimodel.MEAL_KIND, mealDataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
+	mealStreamer := streaming.NewStreamerDuration(mealBatchingWriter, apimodel.DAY_OF_DATA_DURATION)
 
-	exerciseDataStoreWriter := store.NewDataStoreExerciseBatchWriter(context, parentKey)
-	exerciseBatchingWriter := bufio.NewExerciseWriterSize(exerciseDataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
-	exerciseStreamer := streaming.NewExerciseStreamerDuration(exerciseBatchingWriter, apimodel.DAY_OF_DATA_DURATION)
+	exerciseDataStoreWriter := store.NewDataStoreBatchWriter(context, userEmail, apimodel.EXERCISE_KIND)
+	exerciseBatchingWriter := bufio.NewWriterSize(apimodel.EXERCISE_KIND, exerciseDataStoreWriter, store.GLUKIT_SCORE_PUT_MULTI_SIZE)
+	exerciseStreamer := streaming.NewStreamerDuration(exerciseBatchingWriter, apimodel.DAY_OF_DATA_DURATION)
 
-	var lastRead *apimodel.GlucoseRead
+	lastReadTime = startTime
 	for {
 		// Read tokens from the XML document in a stream.
 		t, _ := decoder.Token()
@@ -64,17 +64,18 @@ func ParseContent(context context.Context, reader io.Reader, parentKey *datastor
 				decoder.DecodeElement(&read, &se)
 				glucoseRead, err := dexcomimporter.ConvertXmlGlucoseRead(read)
 				if err != nil {
-					return lastRead.GetTime(), err
+					return lastReadTime, err
 				}
 
-				if glucoseRead != nil && glucoseRead.Value > 0 {
-					glucoseStreamer, err = glucoseStreamer.WriteGlucoseRead(*glucoseRead)
+				// Skip reads out of the sensor range and everything that's before the last import's read time
+				if glucoseRead != nil && glucoseRead.Value > 0 && glucoseRead.GetTime().Unix() > startTime.Unix() {
+					glucoseStreamer, err = glucoseStreamer.Write(*glucoseRead)
 
 					if err != nil {
-						return lastRead.GetTime(), err
+						return lastReadTime, err
 					}
 
-					lastRead = glucoseRead
+					lastReadTime = glucoseRead.GetTime()
 				}
 			case "Event":
 				var event dexcomimporter.Event
@@ -101,9 +102,9 @@ func ParseContent(context context.Context, reader io.Reader, parentKey *datastor
 
 						meal := apimodel.Meal{apimodel.Time{apimodel.GetTimeMillis(eventTime), location.String()}, float32(mealQuantityInGrams), 0., 0., 0.}
 
-						mealStreamer, err = mealStreamer.WriteMeal(meal)
+						mealStreamer, err = mealStreamer.Write(meal)
 						if err != nil {
-							return lastRead.GetTime(), err
+							return lastReadTime, err
 						}
 
 					} else if event.EventType == "Insulin" {
@@ -114,10 +115,10 @@ func ParseContent(context context.Context, reader io.Reader, parentKey *datastor
 						} else {
 							injection := apimodel.Injection{apimodel.Time{apimodel.GetTimeMillis(eventTime), location.String()}, float32(insulinUnits), "", ""}
 
-							injectionStreamer, err = injectionStreamer.WriteInjection(injection)
+							injectionStreamer, err = injectionStreamer.Write(injection)
 
 							if err != nil {
-								return lastRead.GetTime(), err
+								return lastReadTime, err
 							}
 						}
 					} else if strings.HasPrefix(event.EventType, "Exercise") {
@@ -126,9 +127,9 @@ func ParseContent(context context.Context, reader io.Reader, parentKey *datastor
 						fmt.Sscanf(event.Description, "Exercise %s (%d minutes)", &intensity, &duration)
 
 						exercise := apimodel.Exercise{apimodel.Time{apimodel.GetTimeMillis(eventTime), location.String()}, duration, intensity, ""}
-						exerciseStreamer, err = exerciseStreamer.WriteExercise(exercise)
+						exerciseStreamer, err = exerciseStreamer.Write(exercise)
 						if err != nil {
-							return lastRead.GetTime(), err
+							return lastReadTime, err
 						}
 					}
 				}
@@ -137,12 +138,12 @@ func ParseContent(context context.Context, reader io.Reader, parentKey *datastor
 				decoder.DecodeElement(&c, &se)
 
 				if calibrationRead, err := dexcomimporter.ConvertXmlCalibrationRead(c); err != nil {
-					return lastRead.GetTime(), err
-				} else {
-					calibrationStreamer, err = calibrationStreamer.WriteCalibration(*calibrationRead)
+					return lastReadTime, err
+				} else if calibrationRead.GetTime().Unix() > startTime.Unix() {
+					calibrationStreamer, err = calibrationStreamer.Write(*calibrationRead)
 
 					if err != nil {
-						return lastRead.GetTime(), err
+						return lastReadTime, err
 					}
 				}
 			}
@@ -152,28 +153,28 @@ func ParseContent(context context.Context, reader io.Reader, parentKey *datastor
 	// Close the streams and flush anything pending
 	glucoseStreamer, err = glucoseStreamer.Close()
 	if err != nil {
-		return lastRead.GetTime(), err
+		return lastReadTime, err
 	}
 	calibrationStreamer, err = calibrationStreamer.Close()
 	if err != nil {
-		return lastRead.GetTime(), err
+		return lastReadTime, err
 	}
 
 	injectionStreamer, err = injectionStreamer.Close()
 	if err != nil {
-		return lastRead.GetTime(), err
+		return lastReadTime, err
 	}
 
 	mealStreamer, err = mealStreamer.Close()
 	if err != nil {
-		return lastRead.GetTime(), err
+		return lastReadTime, err
 	}
 
 	exerciseStreamer, err = exerciseStreamer.Close()
 	if err != nil {
-		return lastRead.GetTime(), err
+		return lastReadTime, err
 	}
 
 	log.Infof(context, "Done parsing and storing all data")
-	return lastRead.GetTime(), nil
+	return lastReadTime, nil
 }
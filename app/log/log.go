@@ -0,0 +1,49 @@
+/*
+Package log wraps the appengine log package so that the same logging calls work when running outside of App Engine
+(unit tests without aetest, standalone server). On App Engine (or its dev server), calls go to the appengine log.
+Everywhere else, they go to the standard logger.
+*/
+package log
+
+import (
+	"context"
+	"google.golang.org/appengine"
+	aelog "google.golang.org/appengine/log"
+	stdlog "log"
+)
+
+var onAppEngine = appengine.IsAppEngine() || appengine.IsDevAppServer()
+
+// Debugf formats its arguments according to the format, analogous to fmt.Printf,
+// and records the text as a log message at Debug level.
+func Debugf(context context.Context, format string, args ...interface{}) {
+	logf(context, aelog.Debugf, "DEBUG", format, args...)
+}
+
+// Infof is like Debugf, but at Info level.
+func Infof(context context.Context, format string, args ...interface{}) {
+	logf(context, aelog.Infof, "INFO", format, args...)
+}
+
+// Warningf is like Debugf, but at Warning level.
+func Warningf(context context.Context, format string, args ...interface{}) {
+	logf(context, aelog.Warningf, "WARNING", format, args...)
+}
+
+// Errorf is like Debugf, but at Error level.
+func Errorf(context context.Context, format string, args ...interface{}) {
+	logf(context, aelog.Errorf, "ERROR", format, args...)
+}
+
+// Criticalf is like Debugf, but at Critical level.
+func Criticalf(context context.Context, format string, args ...interface{}) {
+	logf(context, aelog.Criticalf, "CRITICAL", format, args...)
+}
+
+func logf(context context.Context, appengineLogf func(context.Context, string, ...interface{}), level string, format string, args ...interface{}) {
+	if onAppEngine {
+		appengineLogf(context, format, args...)
+	} else {
+		stdlog.Printf(level+": "+format, args...)
+	}
+}
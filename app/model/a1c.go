@@ -1,24 +1,87 @@
 package model
 
 import (
+	"errors"
+	"fmt"
 	"github.com/alexandre-normand/glukit/app/util"
 	"math"
 	"time"
 )
 
+// Formulas an a1c can be estimated with
+const (
+	// The ADAG study's formula, (median + 77.3) / 35.6 with the median in mg/dL
+	A1C_FORMULA_ADAG = "ADAG"
+	// The glucose management indicator, 3.31 + 0.02392 × mean with the mean in mg/dL
+	A1C_FORMULA_GMI = "GMI"
+)
+
+const (
+	// Bounds of a plausible lab a1c result, in percent
+	MIN_LAB_A1C_VALUE = 3.
+	MAX_LAB_A1C_VALUE = 20.
+)
+
 // A1CEstimate is a calculated estimate of an a1c. The lower and upper bounds
 // should match the date of the first and last read of the period
 // used to calculate the score. The scoring version represents
 // the version of the calculation algorithm used to calculate a given estimate
 // It is used to discard/recalculate older versions of glukit
 // scores in the eventuality where we change how we calculate the internal
-// estimation.
+// estimation. Coverage and InterpolatedReads are like those of a GlukitScore.
+// Formula is the one the estimate was calculated with (ADAG if empty) and
+// GlycationOffset the personal correction, learned from lab results, that
+// was added to it. The actual a1c is likely between ConfidenceLow and
+// ConfidenceHigh, a range that widens as coverage drops.
 type A1CEstimate struct {
-	Value          float64   `datastore:"value"`
-	LowerBound     time.Time `datastore:"lowerBound"`
-	UpperBound     time.Time `datastore:"upperBound"`
-	CalculatedOn   time.Time `datastore:"calculatedOn"`
-	ScoringVersion int       `datastore:"scoringVersion`
+	Value             float64   `datastore:"value"`
+	LowerBound        time.Time `datastore:"lowerBound"`
+	UpperBound        time.Time `datastore:"upperBound"`
+	CalculatedOn      time.Time `datastore:"calculatedOn"`
+	ScoringVersion    int       `datastore:"scoringVersion`
+	Coverage          float64   `datastore:"coverage,noindex"`
+	InterpolatedReads int       `datastore:"interpolatedReads,noindex"`
+	Formula           string    `datastore:"formula,noindex"`
+	GlycationOffset   float64   `datastore:"glycationOffset,noindex"`
+	ConfidenceLow     float64   `datastore:"confidenceLow,noindex"`
+	ConfidenceHigh    float64   `datastore:"confidenceHigh,noindex"`
+}
+
+// LabA1C is an a1c measured by a lab from a sample taken on TakenOn. EstimatedValue is the uncorrected estimate
+// calculated from the reads of the period ending on that day, 0 if there weren't enough of them. The difference
+// between the two is what personal glycation offsets are learned from.
+type LabA1C struct {
+	Value          float64   `datastore:"value,noindex" json:"value"`
+	TakenOn        time.Time `datastore:"takenOn" json:"takenOn"`
+	EstimatedValue float64   `datastore:"estimatedValue,noindex" json:"estimatedValue"`
+	RecordedOn     time.Time `datastore:"recordedOn,noindex" json:"recordedOn"`
+}
+
+// Validate returns an error if the lab result isn't plausible
+func (lab LabA1C) Validate() (err error) {
+	if lab.Value < MIN_LAB_A1C_VALUE || lab.Value > MAX_LAB_A1C_VALUE {
+		return fmt.Errorf("Invalid a1c value [%.1f], must be between %.0f and %.0f", lab.Value, MIN_LAB_A1C_VALUE, MAX_LAB_A1C_VALUE)
+	}
+
+	if lab.TakenOn.IsZero() {
+		return errors.New("Missing date the lab a1c sample was taken on")
+	}
+
+	return nil
+}
+
+// IsValidA1CFormula returns true if formula is one a1cs can be estimated with
+func IsValidA1CFormula(formula string) bool {
+	return formula == A1C_FORMULA_ADAG || formula == A1C_FORMULA_GMI
+}
+
+// GetA1CFormula returns the formula the user's a1cs are estimated with, ADAG if the user never chose one
+func (user GlukitUser) GetA1CFormula() string {
+	if user.A1CFormula == "" {
+		return A1C_FORMULA_ADAG
+	}
+
+	return user.A1CFormula
 }
 
 const (
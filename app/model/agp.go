@@ -0,0 +1,30 @@
+package model
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"time"
+)
+
+// AmbulatoryGlucoseProfile is the standard AGP view: the distribution of glucose values by time of day over a period
+// (typically the last 14 days). Each bucket covers BucketMinutes of the day in the user's local time and holds the
+// 5th, 25th, 50th, 75th and 95th percentiles of all reads that fall in it, expressed in Unit. Buckets without any read
+// are omitted.
+type AmbulatoryGlucoseProfile struct {
+	LowerBound    time.Time            `json:"lowerBound"`
+	UpperBound    time.Time            `json:"upperBound"`
+	BucketMinutes int                  `json:"bucketMinutes"`
+	Unit          apimodel.GlucoseUnit `json:"unit"`
+	Buckets       []AGPBucket          `json:"buckets"`
+}
+
+// AGPBucket holds the glucose percentiles of the reads for a time of day, identified by the number of minutes since
+// midnight at which it starts
+type AGPBucket struct {
+	MinuteOfDay  int     `json:"minuteOfDay"`
+	ReadCount    int     `json:"readCount"`
+	Percentile5  float64 `json:"p5"`
+	Percentile25 float64 `json:"p25"`
+	Percentile50 float64 `json:"p50"`
+	Percentile75 float64 `json:"p75"`
+	Percentile95 float64 `json:"p95"`
+}
@@ -0,0 +1,97 @@
+package model
+
+import (
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/util"
+	"net/url"
+	"time"
+)
+
+// Types of alert rules
+const (
+	// Reads below the threshold (mg/dL) for at least the rule's duration
+	SUSTAINED_LOW_ALERT = "SustainedLow"
+	// Reads above the threshold (mg/dL) for at least the rule's duration
+	SUSTAINED_HIGH_ALERT = "SustainedHigh"
+	// Glucose rising or falling faster than the threshold (mg/dL/min) between two consecutive reads
+	RATE_OF_CHANGE_ALERT = "RateOfChange"
+	// No read for at least the rule's duration
+	MISSING_DATA_ALERT = "MissingData"
+)
+
+// AlertRule is a condition on a user's reads that raises an alert when met
+type AlertRule struct {
+	Type            string  `datastore:"type,noindex" json:"type"`
+	Threshold       float64 `datastore:"threshold,noindex" json:"threshold"`
+	DurationMinutes int     `datastore:"durationMinutes,noindex" json:"durationMinutes"`
+}
+
+// AlertSettings are the alert rules of a user and where alerts are sent. MonitorsMissingData is set when one of the
+// rules is a MISSING_DATA_ALERT so that those users can be found by the periodic check for missing data.
+type AlertSettings struct {
+	Rules               []AlertRule `datastore:"rules" json:"rules"`
+	WebhookUrl          string      `datastore:"webhookUrl,noindex" json:"webhookUrl,omitempty"`
+	NotificationEmail   string      `datastore:"notificationEmail,noindex" json:"notificationEmail,omitempty"`
+	MonitorsMissingData bool        `datastore:"monitorsMissingData" json:"-"`
+}
+
+// AlertEvent is the record of an alert raised for a user. Time is the time of the read that met the rule's
+// condition (or the time data went missing for) and Value is the glucose value in mg/dL, the rate of change in
+// mg/dL/min or the minutes without data.
+type AlertEvent struct {
+	Type      string    `datastore:"type,noindex" json:"type"`
+	Time      time.Time `datastore:"time" json:"time"`
+	Value     float64   `datastore:"value,noindex" json:"value"`
+	Rule      AlertRule `datastore:"rule" json:"rule"`
+	Message   string    `datastore:"message,noindex" json:"message"`
+	RaisedOn  time.Time `datastore:"raisedOn,noindex" json:"raisedOn"`
+	Delivered bool      `datastore:"delivered,noindex" json:"delivered"`
+}
+
+// Validate returns an error describing the first invalid rule or the invalid webhook url. Webhooks must be http or https
+// urls of hosts reachable from the internet so that alerts can't be posted to the server's own network.
+func (settings AlertSettings) Validate() (err error) {
+	for i, rule := range settings.Rules {
+		switch rule.Type {
+		case SUSTAINED_LOW_ALERT, SUSTAINED_HIGH_ALERT:
+			if rule.Threshold <= 0 || rule.DurationMinutes <= 0 {
+				return fmt.Errorf("Rule [%d] of type [%s] needs a positive threshold and duration", i, rule.Type)
+			}
+		case RATE_OF_CHANGE_ALERT:
+			if rule.Threshold <= 0 {
+				return fmt.Errorf("Rule [%d] of type [%s] needs a positive threshold", i, rule.Type)
+			}
+		case MISSING_DATA_ALERT:
+			if rule.DurationMinutes <= 0 {
+				return fmt.Errorf("Rule [%d] of type [%s] needs a positive duration", i, rule.Type)
+			}
+		default:
+			return fmt.Errorf("Rule [%d] has unknown type [%s], must be one of [%s, %s, %s, %s]", i, rule.Type,
+				SUSTAINED_LOW_ALERT, SUSTAINED_HIGH_ALERT, RATE_OF_CHANGE_ALERT, MISSING_DATA_ALERT)
+		}
+	}
+
+	if settings.WebhookUrl != "" {
+		webhookUrl, err := url.Parse(settings.WebhookUrl)
+		if err != nil || (webhookUrl.Scheme != "http" && webhookUrl.Scheme != "https") || webhookUrl.Hostname() == "" {
+			return fmt.Errorf("Invalid webhook url [%s], must be an http or https url", settings.WebhookUrl)
+		}
+
+		if util.IsInternalHost(webhookUrl.Hostname()) {
+			return fmt.Errorf("Invalid webhook url [%s], must not be a loopback, private or link-local host", settings.WebhookUrl)
+		}
+	}
+
+	return nil
+}
+
+// HasMissingDataRule returns true if one of the rules is a MISSING_DATA_ALERT
+func (settings AlertSettings) HasMissingDataRule() bool {
+	for _, rule := range settings.Rules {
+		if rule.Type == MISSING_DATA_ALERT {
+			return true
+		}
+	}
+
+	return false
+}
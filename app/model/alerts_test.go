@@ -0,0 +1,36 @@
+package model_test
+
+import (
+	. "github.com/alexandre-normand/glukit/app/model"
+	"testing"
+)
+
+func TestAlertSettingsValidation(t *testing.T) {
+	invalidSettings := []AlertSettings{
+		{Rules: []AlertRule{{SUSTAINED_LOW_ALERT, 70, -15}}},
+		{Rules: []AlertRule{{SUSTAINED_HIGH_ALERT, 250, 0}}},
+		{Rules: []AlertRule{{RATE_OF_CHANGE_ALERT, 0, 0}}},
+		{Rules: []AlertRule{{MISSING_DATA_ALERT, 0, 0}}},
+		{Rules: []AlertRule{{"Unknown", 70, 15}}},
+		{WebhookUrl: "ftp://example.com/alerts"},
+		{WebhookUrl: "http://localhost:8080/alerts"},
+		{WebhookUrl: "http://127.0.0.1/alerts"},
+		{WebhookUrl: "http://10.0.0.1/alerts"},
+		{WebhookUrl: "http://192.168.1.10/alerts"},
+		{WebhookUrl: "http://169.254.169.254/latest/meta-data"},
+		{WebhookUrl: "http://[::1]/alerts"},
+		{WebhookUrl: "http://[fe80::1]/alerts"},
+	}
+
+	for _, settings := range invalidSettings {
+		if err := settings.Validate(); err == nil {
+			t.Errorf("Expected settings [%v] to be invalid", settings)
+		}
+	}
+
+	validSettings := AlertSettings{Rules: []AlertRule{{SUSTAINED_LOW_ALERT, 70, 15}, {MISSING_DATA_ALERT, 0, 180}},
+		WebhookUrl: "https://example.com/glukit-alerts"}
+	if err := validSettings.Validate(); err != nil {
+		t.Errorf("Expected settings [%v] to be valid but got [%v]", validSettings, err)
+	}
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+)
+
+// Zones of the Clarke and Parkes error grids. Sensor values in zone A are clinically accurate, in B they would lead
+// to benign or no treatment, in C to unnecessary treatment, in D to a failure to treat and in E to the opposite
+// treatment.
+const (
+	ERROR_GRID_ZONE_A = "A"
+	ERROR_GRID_ZONE_B = "B"
+	ERROR_GRID_ZONE_C = "C"
+	ERROR_GRID_ZONE_D = "D"
+	ERROR_GRID_ZONE_E = "E"
+)
+
+// CalibrationPair is a meter value entered as a calibration paired with the sensor value at the same time,
+// interpolated from the reads around it. Values are in mg/dL, Difference is the sensor value minus the meter value and
+// RelativeDifference is the absolute difference as a percentage of the meter value.
+type CalibrationPair struct {
+	Time               time.Time `json:"time"`
+	MeterValue         float64   `json:"meterValue"`
+	SensorValue        float64   `json:"sensorValue"`
+	Difference         float64   `json:"difference"`
+	RelativeDifference float64   `json:"relativeDifference"`
+	ClarkeZone         string    `json:"clarkeZone"`
+	ParkesZone         string    `json:"parkesZone"`
+}
+
+// AccuracyStatistics summarize calibration pairs. MARD is the mean absolute relative difference, in %, Bias the mean
+// difference, in mg/dL, and the zones hold the number of pairs in each zone of the error grids.
+type AccuracyStatistics struct {
+	PairCount   int            `json:"pairCount"`
+	MARD        float64        `json:"mard"`
+	Bias        float64        `json:"bias"`
+	ClarkeZones map[string]int `json:"clarkeZones"`
+	ParkesZones map[string]int `json:"parkesZones"`
+}
+
+// SessionAccuracy is the accuracy of a sensor session, from its insertion to its end, along with the pairs it's
+// calculated from
+type SessionAccuracy struct {
+	Start      time.Time          `json:"start"`
+	End        time.Time          `json:"end"`
+	Statistics AccuracyStatistics `json:"statistics"`
+	Pairs      []CalibrationPair  `json:"pairs"`
+}
+
+// DailyAccuracy is the accuracy of the pairs of a day, in the local time of the calibrations
+type DailyAccuracy struct {
+	Date       string             `json:"date"`
+	Statistics AccuracyStatistics `json:"statistics"`
+}
+
+// CalibrationAccuracy is the accuracy of the sensor against the calibrations between LowerBound and UpperBound, over
+// the whole period, for every sensor session and for every day with calibrations
+type CalibrationAccuracy struct {
+	LowerBound time.Time          `json:"lowerBound"`
+	UpperBound time.Time          `json:"upperBound"`
+	Overall    AccuracyStatistics `json:"overall"`
+	Sessions   []SessionAccuracy  `json:"sessions"`
+	Days       []DailyAccuracy    `json:"days"`
+}
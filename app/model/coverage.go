@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+)
+
+// Likely causes of a gap in reads, guessed from its duration
+const (
+	// A short interruption, typically the receiver being out of range of the transmitter
+	SIGNAL_LOSS_GAP = "SignalLoss"
+	// About the time a new sensor takes before it gives its first reads
+	SENSOR_WARM_UP_GAP = "SensorWarmUp"
+	// Anything longer, like time without a sensor
+	NO_DATA_GAP = "NoData"
+)
+
+// DataGap is a period without reads. Start is the time of the last read before the gap, or the start of the analyzed
+// window, and End is the time of the first read after it, or the end of the window.
+type DataGap struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Cause string    `json:"cause"`
+}
+
+// DataCoverage is how much of a window is covered by reads. Percentage is the share of the window, from 0 to 100,
+// that isn't part of a gap.
+type DataCoverage struct {
+	LowerBound time.Time `json:"lowerBound"`
+	UpperBound time.Time `json:"upperBound"`
+	Percentage float64   `json:"percentage"`
+	Gaps       []DataGap `json:"gaps"`
+}
@@ -0,0 +1,14 @@
+package model
+
+import (
+	"time"
+)
+
+// ForecastPoint is a forecasted glucose value, in mg/dL. Low and High bound the 95% prediction interval of the read
+// expected at that time.
+type ForecastPoint struct {
+	Time  time.Time
+	Value float64
+	Low   float64
+	High  float64
+}
@@ -5,7 +5,8 @@ import (
 	"time"
 )
 
-// Represents a GlukitUser profile
+// Represents a GlukitUser profile. A1CFormula is the formula the user's a1cs are estimated with and GlycationOffset
+// the correction, learned from the user's lab results, added to them.
 type GlukitUser struct {
 	Email           string               `datastore:"email"`
 	FirstName       string               `datastore:"firstName,noindex"`
@@ -21,6 +22,9 @@ type GlukitUser struct {
 	PictureUrl      string               `datastore:"pictureUrl,noindex"`
 	AccountCreated  time.Time            `datastore:"joinedOn"`
 	MostRecentA1C   A1CEstimate          `datastore:"mostRecentA1C"`
+	Targets         GlucoseTargets       `datastore:"targets"`
+	A1CFormula      string               `datastore:"a1cFormula,noindex"`
+	GlycationOffset float64              `datastore:"glycationOffset,noindex"`
 }
 
 // Represents a GlukitScore value, the lower and upper bounds
@@ -29,13 +33,17 @@ type GlukitUser struct {
 // the version of the calculation algorithm used to calculate a given
 // score. It is used to discard/recalculate older versions of glukit
 // scores in the eventuality where we change how we calculate the internal
-// score.
+// score. Coverage is the percentage of the period covered by reads and
+// InterpolatedReads the number of reads that were interpolated to fill short
+// gaps. Both are 0 for scores calculated before they were reported.
 type GlukitScore struct {
-	Value          int64     `datastore:"value"`
-	LowerBound     time.Time `datastore:"lowerBound"`
-	UpperBound     time.Time `datastore:"upperBound"`
-	CalculatedOn   time.Time `datastore:"calculatedOn"`
-	ScoringVersion int       `datastore:"scoringVersion`
+	Value             int64     `datastore:"value"`
+	LowerBound        time.Time `datastore:"lowerBound"`
+	UpperBound        time.Time `datastore:"upperBound"`
+	CalculatedOn      time.Time `datastore:"calculatedOn"`
+	ScoringVersion    int       `datastore:"scoringVersion`
+	Coverage          float64   `datastore:"coverage,noindex"`
+	InterpolatedReads int       `datastore:"interpolatedReads,noindex"`
 }
 
 // Type of diabetes
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+)
+
+// Types of insights
+const (
+	// Lows during the night. The magnitude of each occurrence is the lowest glucose of the night.
+	NOCTURNAL_HYPOGLYCEMIA_INSIGHT = "NocturnalHypoglycemia"
+	// Glucose rising in the early morning without a low before. The magnitude of each occurrence is the rise from the
+	// overnight nadir.
+	DAWN_PHENOMENON_INSIGHT = "DawnPhenomenon"
+	// Highs in the evening, after dinner. The magnitude of each occurrence is the highest glucose of the evening.
+	POST_DINNER_HIGH_INSIGHT = "PostDinnerHigh"
+	// Highs shortly after a low. The magnitude of each occurrence is the rise from the low to the high.
+	REBOUND_HIGH_INSIGHT = "ReboundHigh"
+	// Recorded by a detection that found no pattern so that it replaces the insights of previous detections. It's
+	// never reported.
+	NO_PATTERN_INSIGHT = "NoPattern"
+)
+
+// InsightEvidence is one occurrence of a pattern: the local date it happened on, the time of the read that showed
+// it and its magnitude in mg/dL
+type InsightEvidence struct {
+	Date      string    `datastore:"date,noindex" json:"date"`
+	Time      time.Time `datastore:"time,noindex" json:"time"`
+	Magnitude float64   `datastore:"magnitude,noindex" json:"magnitude"`
+}
+
+// Insight is a pattern recurring in the reads of a user between LowerBound and UpperBound. Occurrences is the number
+// of days the pattern was seen on, out of the DaysWithData.
+type Insight struct {
+	Type         string            `datastore:"type,noindex" json:"type"`
+	LowerBound   time.Time         `datastore:"lowerBound,noindex" json:"lowerBound"`
+	UpperBound   time.Time         `datastore:"upperBound" json:"upperBound"`
+	Occurrences  int               `datastore:"occurrences,noindex" json:"occurrences"`
+	DaysWithData int               `datastore:"daysWithData,noindex" json:"daysWithData"`
+	Message      string            `datastore:"message,noindex" json:"message"`
+	Evidence     []InsightEvidence `datastore:"evidence" json:"evidence"`
+	DetectedOn   time.Time         `datastore:"detectedOn,noindex" json:"detectedOn"`
+}
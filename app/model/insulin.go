@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+)
+
+// DailyInsulin is the insulin delivered on a day, in units. Basal is what pump basal rates delivered, temp basals
+// included and suspends excluded, plus long acting injections. Bolus is every other injection and the extended boluses
+// delivered that day. BasalPercentage is the share of the total delivered as basal.
+type DailyInsulin struct {
+	Date            string  `json:"date"`
+	Basal           float64 `json:"basal"`
+	Bolus           float64 `json:"bolus"`
+	Total           float64 `json:"total"`
+	BasalPercentage float64 `json:"basalPercentage"`
+}
+
+// TotalDailyInsulin is the insulin delivered on every day between LowerBound and UpperBound along with the average of
+// the days with any insulin delivered
+type TotalDailyInsulin struct {
+	LowerBound time.Time      `json:"lowerBound"`
+	UpperBound time.Time      `json:"upperBound"`
+	Days       []DailyInsulin `json:"days"`
+	Average    DailyInsulin   `json:"average"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+)
+
+const (
+	// Value of MinutesToBaseline when glucose didn't come back to its baseline within the observed window
+	UNDEFINED_MINUTES_TO_BASELINE = -1
+)
+
+// MealImpact is the post-prandial glucose excursion following a meal. Glucose values are in mg/dL, PeakRise is the
+// rise of the peak over the pre-meal Baseline and AreaUnderCurve is the incremental area above the baseline, in
+// mg/dL·min. Times are in minutes after the meal.
+type MealImpact struct {
+	MealTime          time.Time `datastore:"mealTime" json:"mealTime"`
+	Carbohydrates     float32   `datastore:"carbohydrates,noindex" json:"carbohydrates"`
+	Baseline          float64   `datastore:"baseline,noindex" json:"baseline"`
+	PeakRise          float64   `datastore:"peakRise,noindex" json:"peakRise"`
+	MinutesToPeak     int       `datastore:"minutesToPeak,noindex" json:"minutesToPeak"`
+	AreaUnderCurve    float64   `datastore:"areaUnderCurve,noindex" json:"areaUnderCurve"`
+	MinutesToBaseline int       `datastore:"minutesToBaseline,noindex" json:"minutesToBaseline"`
+	CalculatedOn      time.Time `datastore:"calculatedOn,noindex" json:"calculatedOn"`
+}
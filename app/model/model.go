@@ -39,3 +39,10 @@ type DataStoreDayOfCalibrationReads apimodel.DayOfCalibrationReads
 type DataStoreDayOfInjections apimodel.DayOfInjections
 type DataStoreDayOfExercises apimodel.DayOfExercises
 type DataStoreDayOfMeals apimodel.DayOfMeals
+type DataStoreDayOfBasalRates apimodel.DayOfBasalRates
+type DataStoreDayOfTempBasals apimodel.DayOfTempBasals
+type DataStoreDayOfPumpSuspends apimodel.DayOfPumpSuspends
+type DataStoreDayOfExtendedBoluses apimodel.DayOfExtendedBoluses
+type DataStoreDayOfKetones apimodel.DayOfKetones
+type DataStoreDayOfBloodPressures apimodel.DayOfBloodPressures
+type DataStoreDayOfWeights apimodel.DayOfWeights
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Sources of sensor sessions
+const (
+	// Recorded through the api by the user or an uploader
+	RECORDED_SENSOR_SESSION = "Recorded"
+	// Inferred from the reads and calibrations on import
+	INFERRED_SENSOR_SESSION = "Inferred"
+)
+
+const (
+	// Longest a sensor can be worn, even when extended past its expiry
+	MAX_SENSOR_SESSION_DURATION = 30 * 24 * time.Hour
+)
+
+// SensorSession is the wear of a CGM sensor, from its insertion to its removal. Reads from the insertion until
+// WarmUpEnd, when the sensor is calibrated and its reads become reliable, are considered part of its warm-up.
+// ExpiresOn is when the sensor is due to be replaced and EndedOn is zero as long as the session is ongoing.
+type SensorSession struct {
+	InsertedOn    time.Time `datastore:"insertedOn" json:"insertedOn"`
+	WarmUpEnd     time.Time `datastore:"warmUpEnd,noindex" json:"warmUpEnd"`
+	ExpiresOn     time.Time `datastore:"expiresOn,noindex" json:"expiresOn"`
+	EndedOn       time.Time `datastore:"endedOn,noindex" json:"endedOn"`
+	TransmitterId string    `datastore:"transmitterId,noindex" json:"transmitterId,omitempty"`
+	Source        string    `datastore:"source,noindex" json:"source"`
+}
+
+// SensorSessionStatistics are the statistics of the reads of a sensor session after its warm-up. Average is in mg/dL,
+// Coverage is the percentage of the time from the end of the warm-up to the end of the session covered by reads and
+// Accuracy compares the sensor with the calibrations entered during the session.
+type SensorSessionStatistics struct {
+	Session   SensorSession      `json:"session"`
+	ReadCount int                `json:"readCount"`
+	Average   float64            `json:"average"`
+	Coverage  float64            `json:"coverage"`
+	Accuracy  AccuracyStatistics `json:"accuracy"`
+}
+
+// Validate returns an error if the session's times are missing or inconsistent
+func (session SensorSession) Validate() (err error) {
+	if session.InsertedOn.IsZero() {
+		return errors.New("Missing time the sensor was inserted on")
+	}
+
+	if session.WarmUpEnd.Before(session.InsertedOn) || session.ExpiresOn.Before(session.InsertedOn) {
+		return fmt.Errorf("Invalid sensor session, warm-up end [%s] and expiry [%s] can't be before insertion [%s]",
+			session.WarmUpEnd, session.ExpiresOn, session.InsertedOn)
+	}
+
+	if !session.EndedOn.IsZero() && session.EndedOn.Before(session.WarmUpEnd) {
+		return fmt.Errorf("Invalid sensor session, end [%s] can't be before the end of the warm-up [%s]", session.EndedOn, session.WarmUpEnd)
+	}
+
+	if session.ExpiresOn.Sub(session.InsertedOn) > MAX_SENSOR_SESSION_DURATION || session.EndedOn.Sub(session.InsertedOn) > MAX_SENSOR_SESSION_DURATION {
+		return fmt.Errorf("Invalid sensor session, a sensor can't be worn for more than [%s]", MAX_SENSOR_SESSION_DURATION)
+	}
+
+	return nil
+}
+
+// IsOngoing returns true if the sensor hasn't been removed yet
+func (session SensorSession) IsOngoing() bool {
+	return session.EndedOn.IsZero()
+}
+
+// Contains returns true if the sensor was worn at the given time, from its insertion to its removal, both inclusive
+func (session SensorSession) Contains(instant time.Time) bool {
+	return !instant.Before(session.InsertedOn) && (session.IsOngoing() || !instant.After(session.EndedOn))
+}
+
+// IsWarmingUp returns true if the sensor was warming up at the given time
+func (session SensorSession) IsWarmingUp(instant time.Time) bool {
+	return !instant.Before(session.InsertedOn) && instant.Before(session.WarmUpEnd)
+}
+
+// Overlaps returns true if both sessions share some time
+func (session SensorSession) Overlaps(other SensorSession) bool {
+	return session.Contains(other.InsertedOn) || other.Contains(session.InsertedOn)
+}
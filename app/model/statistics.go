@@ -28,12 +28,35 @@ func (slice ReadStatsSlice) Swap(i, j int) {
 	slice[i], slice[j] = slice[j], slice[i]
 }
 
-// Represents the structure of the dashboard data for a user
+// Represents the structure of the dashboard data for a user. Glucose values (average, median, high, low, standard
+// deviation and MAGE) are expressed in Unit. Time in ranges are percentages of reads. GMI is a percentage like an a1c
+// while the coefficient of variation is a percentage of the average. LBGI and HBGI are unitless risk indices. Insights
+// are the recurring patterns found by the most recent weekly detection.
 type DashboardData struct {
-	Average float64 `json:"average"`
-	Median  float64 `json:"median"`
-	High    float64 `json:"high"`
-	Low     float64 `json:"low"`
+	Average                float64              `json:"average"`
+	Median                 float64              `json:"median"`
+	High                   float64              `json:"high"`
+	Low                    float64              `json:"low"`
+	Unit                   apimodel.GlucoseUnit `json:"unit"`
+	ReadCount              int                  `json:"readCount"`
+	TimeBelow54            float64              `json:"timeBelow54"`
+	TimeBelow70            float64              `json:"timeBelow70"`
+	TimeInRange            float64              `json:"timeInRange"`
+	TimeAbove180           float64              `json:"timeAbove180"`
+	TimeAbove250           float64              `json:"timeAbove250"`
+	TimeBelowVeryLow       float64              `json:"timeBelowVeryLow"`
+	TimeBelowLow           float64              `json:"timeBelowLow"`
+	TimeInTargetRange      float64              `json:"timeInTargetRange"`
+	TimeAboveHigh          float64              `json:"timeAboveHigh"`
+	TimeAboveVeryHigh      float64              `json:"timeAboveVeryHigh"`
+	TargetRange            GlucoseRange         `json:"targetRange"`
+	StandardDeviation      float64              `json:"standardDeviation"`
+	CoefficientOfVariation float64              `json:"coefficientOfVariation"`
+	GMI                    float64              `json:"gmi"`
+	MAGE                   float64              `json:"mage"`
+	LBGI                   float64              `json:"lbgi"`
+	HBGI                   float64              `json:"hbgi"`
+	Insights               []Insight            `json:"insights"`
 }
 
 type CoordinateSlice []Coordinate
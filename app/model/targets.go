@@ -0,0 +1,164 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"time"
+)
+
+const (
+	// Format of the local times of day bounding a scheduled glucose range
+	TIME_OF_DAY_FORMAT = "15:04"
+)
+
+// GlucoseRange holds glucose thresholds, in mg/dL. Reads from Low to High are in range, reads below VeryLow or above
+// VeryHigh are the clinically significant excursions and Target is the value aimed for.
+type GlucoseRange struct {
+	VeryLow  float64 `datastore:"veryLow,noindex" json:"veryLow"`
+	Low      float64 `datastore:"low,noindex" json:"low"`
+	Target   float64 `datastore:"target,noindex" json:"target"`
+	High     float64 `datastore:"high,noindex" json:"high"`
+	VeryHigh float64 `datastore:"veryHigh,noindex" json:"veryHigh"`
+}
+
+// ScheduledGlucoseRange is a range that applies instead of the default one from Start until End, both local times of
+// day. A schedule can wrap around midnight (i.e. from 22:00 to 06:00).
+type ScheduledGlucoseRange struct {
+	Start string       `datastore:"start,noindex" json:"start"`
+	End   string       `datastore:"end,noindex" json:"end"`
+	Range GlucoseRange `datastore:"range" json:"range"`
+}
+
+// GlucoseTargets are the glucose targets prescribed to a user: a default range and, optionally, different ranges for
+// some times of the day
+type GlucoseTargets struct {
+	Default  GlucoseRange            `datastore:"default" json:"default"`
+	Schedule []ScheduledGlucoseRange `datastore:"schedule" json:"schedule"`
+}
+
+// DEFAULT_GLUCOSE_TARGETS are the targets of users that didn't set their own. The range is the one of the
+// international consensus on time in range.
+var DEFAULT_GLUCOSE_TARGETS = GlucoseTargets{Default: GlucoseRange{VeryLow: 54, Low: 70, Target: TARGET_GLUCOSE_VALUE, High: 180, VeryHigh: 250}}
+
+// GetGlucoseTargets returns the user's glucose targets or the default ones if the user never set any
+func (user GlukitUser) GetGlucoseTargets() GlucoseTargets {
+	if user.Targets.Default.Target == 0 {
+		return DEFAULT_GLUCOSE_TARGETS
+	}
+
+	return user.Targets
+}
+
+// RangeAt returns the range that applies at the given time, in its location. When scheduled ranges overlap, the
+// first one wins.
+func (targets GlucoseTargets) RangeAt(timeValue time.Time) GlucoseRange {
+	minuteOfDay := timeValue.Hour()*60 + timeValue.Minute()
+	for _, scheduled := range targets.Schedule {
+		start, startErr := parseMinuteOfDay(scheduled.Start)
+		end, endErr := parseMinuteOfDay(scheduled.End)
+		if startErr != nil || endErr != nil {
+			continue
+		}
+
+		if start < end && minuteOfDay >= start && minuteOfDay < end {
+			return scheduled.Range
+		} else if start > end && (minuteOfDay >= start || minuteOfDay < end) {
+			return scheduled.Range
+		}
+	}
+
+	return targets.Default
+}
+
+// Validate returns an error describing the first inconsistency found in the targets
+func (targets GlucoseTargets) Validate() (err error) {
+	if err = targets.Default.Validate(); err != nil {
+		return fmt.Errorf("Invalid default range: %v", err)
+	}
+
+	for i, scheduled := range targets.Schedule {
+		start, err := parseMinuteOfDay(scheduled.Start)
+		if err != nil {
+			return fmt.Errorf("Invalid start [%s] of scheduled range [%d], must be formatted as %s", scheduled.Start, i, TIME_OF_DAY_FORMAT)
+		}
+
+		end, err := parseMinuteOfDay(scheduled.End)
+		if err != nil {
+			return fmt.Errorf("Invalid end [%s] of scheduled range [%d], must be formatted as %s", scheduled.End, i, TIME_OF_DAY_FORMAT)
+		}
+
+		if start == end {
+			return fmt.Errorf("Scheduled range [%d] starts and ends at the same time [%s]", i, scheduled.Start)
+		}
+
+		if err = scheduled.Range.Validate(); err != nil {
+			return fmt.Errorf("Invalid scheduled range [%d]: %v", i, err)
+		}
+	}
+
+	return nil
+}
+
+// Validate returns an error if the thresholds of the range aren't positive and in increasing order
+func (glucoseRange GlucoseRange) Validate() (err error) {
+	if glucoseRange.VeryLow <= 0 {
+		return errors.New("All thresholds must be positive")
+	}
+
+	if !(glucoseRange.VeryLow < glucoseRange.Low && glucoseRange.Low <= glucoseRange.Target &&
+		glucoseRange.Target <= glucoseRange.High && glucoseRange.High < glucoseRange.VeryHigh) {
+		return fmt.Errorf("Thresholds must be ordered as veryLow < low <= target <= high < veryHigh, got [%v]", glucoseRange)
+	}
+
+	return nil
+}
+
+// ConvertTo converts the targets from one glucose unit to another. Targets are stored in mg/dL.
+func (targets GlucoseTargets) ConvertTo(from apimodel.GlucoseUnit, to apimodel.GlucoseUnit) (converted GlucoseTargets, err error) {
+	if converted.Default, err = targets.Default.ConvertTo(from, to); err != nil {
+		return converted, err
+	}
+
+	converted.Schedule = make([]ScheduledGlucoseRange, len(targets.Schedule))
+	for i, scheduled := range targets.Schedule {
+		converted.Schedule[i] = ScheduledGlucoseRange{Start: scheduled.Start, End: scheduled.End}
+		if converted.Schedule[i].Range, err = scheduled.Range.ConvertTo(from, to); err != nil {
+			return converted, err
+		}
+	}
+
+	return converted, nil
+}
+
+// ConvertTo converts all thresholds of the range from one glucose unit to another
+func (glucoseRange GlucoseRange) ConvertTo(from apimodel.GlucoseUnit, to apimodel.GlucoseUnit) (converted GlucoseRange, err error) {
+	for _, threshold := range []struct {
+		target *float64
+		value  float64
+	}{
+		{&converted.VeryLow, glucoseRange.VeryLow},
+		{&converted.Low, glucoseRange.Low},
+		{&converted.Target, glucoseRange.Target},
+		{&converted.High, glucoseRange.High},
+		{&converted.VeryHigh, glucoseRange.VeryHigh},
+	} {
+		convertedValue, err := apimodel.GlucoseRead{Unit: from, Value: float32(threshold.value)}.GetNormalizedValue(to)
+		if err != nil {
+			return converted, err
+		}
+		*threshold.target = float64(convertedValue)
+	}
+
+	return converted, nil
+}
+
+// parseMinuteOfDay returns the number of minutes since midnight of a time of day
+func parseMinuteOfDay(timeOfDay string) (minuteOfDay int, err error) {
+	parsed, err := time.Parse(TIME_OF_DAY_FORMAT, timeOfDay)
+	if err != nil {
+		return 0, err
+	}
+
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
@@ -0,0 +1,64 @@
+package model_test
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	. "github.com/alexandre-normand/glukit/app/model"
+	"testing"
+	"time"
+)
+
+var NIGHT_RANGE = GlucoseRange{60, 90, 110, 140, 200}
+var TARGETS = GlucoseTargets{GlucoseRange{54, 70, 100, 160, 250}, []ScheduledGlucoseRange{{"22:00", "06:00", NIGHT_RANGE}}}
+
+func TestRangeAtTimeOfDay(t *testing.T) {
+	location, _ := time.LoadLocation("America/Montreal")
+	for _, testCase := range []struct {
+		timeValue     time.Time
+		expectedRange GlucoseRange
+	}{
+		{time.Date(2015, 4, 18, 23, 0, 0, 0, location), NIGHT_RANGE},
+		{time.Date(2015, 4, 18, 5, 59, 0, 0, location), NIGHT_RANGE},
+		{time.Date(2015, 4, 18, 6, 0, 0, 0, location), TARGETS.Default},
+		{time.Date(2015, 4, 18, 12, 0, 0, 0, location), TARGETS.Default},
+		// 2h00 in UTC is 22h00 the day before in Montreal
+		{time.Date(2015, 4, 19, 2, 0, 0, 0, time.UTC).In(location), NIGHT_RANGE},
+	} {
+		if glucoseRange := TARGETS.RangeAt(testCase.timeValue); glucoseRange != testCase.expectedRange {
+			t.Errorf("Expected range [%v] at [%s] but got [%v]", testCase.expectedRange, testCase.timeValue, glucoseRange)
+		}
+	}
+}
+
+func TestInvalidTargets(t *testing.T) {
+	for _, targets := range []GlucoseTargets{
+		{GlucoseRange{54, 70, 200, 180, 250}, nil},
+		{GlucoseRange{0, 70, 100, 180, 250}, nil},
+		{TARGETS.Default, []ScheduledGlucoseRange{{"22h", "06:00", NIGHT_RANGE}}},
+		{TARGETS.Default, []ScheduledGlucoseRange{{"06:00", "06:00", NIGHT_RANGE}}},
+	} {
+		if err := targets.Validate(); err == nil {
+			t.Errorf("Expected targets [%v] to be invalid", targets)
+		}
+	}
+
+	if err := TARGETS.Validate(); err != nil {
+		t.Errorf("Expected valid targets but got [%v]", err)
+	}
+}
+
+func TestTargetsUnitConversion(t *testing.T) {
+	converted, err := TARGETS.ConvertTo(apimodel.MG_PER_DL, apimodel.MMOL_PER_L)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if converted.Default.Target != float64(float32(100*0.0555)) || converted.Schedule[0].Start != "22:00" {
+		t.Errorf("Unexpected converted targets [%v]", converted)
+	}
+}
+
+func TestDefaultTargetsForUserWithoutTargets(t *testing.T) {
+	if targets := (GlukitUser{}).GetGlucoseTargets(); targets.Default != DEFAULT_GLUCOSE_TARGETS.Default {
+		t.Errorf("Expected default targets but got [%v]", targets)
+	}
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+)
+
+// TherapyEstimate holds the insulin sensitivity factor and insulin-to-carb ratio of a user, for each block of the
+// day, as estimated from the injections, meals and reads between LowerBound and UpperBound
+type TherapyEstimate struct {
+	LowerBound   time.Time           `datastore:"lowerBound" json:"lowerBound"`
+	UpperBound   time.Time           `datastore:"upperBound" json:"upperBound"`
+	CalculatedOn time.Time           `datastore:"calculatedOn,noindex" json:"calculatedOn"`
+	Blocks       []TimeOfDayEstimate `datastore:"blocks,noindex" json:"blocks"`
+}
+
+// TimeOfDayEstimate holds the estimates for the block of the day from Start until End, both local times of day.
+// InsulinSensitivity is in mg/dL per unit of insulin and CarbRatio in grams of carbs per unit of insulin.
+type TimeOfDayEstimate struct {
+	Start              string   `datastore:"start,noindex" json:"start"`
+	End                string   `datastore:"end,noindex" json:"end"`
+	InsulinSensitivity Estimate `datastore:"insulinSensitivity,noindex" json:"insulinSensitivity"`
+	CarbRatio          Estimate `datastore:"carbRatio,noindex" json:"carbRatio"`
+}
+
+// Estimate is the mean of SampleSize samples along with the bounds of its 95% confidence interval. An estimate with
+// too few samples has no value and bounds of 0.
+type Estimate struct {
+	Value      float64 `datastore:"value,noindex" json:"value"`
+	Low        float64 `datastore:"low,noindex" json:"low"`
+	High       float64 `datastore:"high,noindex" json:"high"`
+	SampleSize int     `datastore:"sampleSize,noindex" json:"sampleSize"`
+}
@@ -0,0 +1,287 @@
+/*
+Package nightscout maps the records of the Nightscout REST protocol (entries and treatments) to and from the glukit
+api model so that uploaders that speak Nightscout can feed glukit.
+*/
+package nightscout
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Entry types
+	SGV_ENTRY_TYPE = "sgv"
+	MBG_ENTRY_TYPE = "mbg"
+
+	// Treatment event types that carry exercise, other event types are mapped according to their insulin and carbs
+	EXERCISE_EVENT_TYPE = "Exercise"
+
+	// Nightscout always stores glucose values in mg/dL
+	NIGHTSCOUT_GLUCOSE_UNIT = apimodel.MG_PER_DL
+
+	// Sensor values under 40 mg/dL are codes of the sensor errors (i.e. ??? or sensor not active), not glucose values
+	MIN_VALID_SGV = 40
+
+	// Noise of clean sensor values, values with a higher noise aren't reliable
+	CLEAN_SGV_NOISE = 1
+
+	// Device reported for entries served by glukit
+	GLUKIT_DEVICE = "glukit"
+)
+
+// Entry is a Nightscout entry: a sensor glucose value (sgv) or a meter glucose value (mbg). The date is in
+// milliseconds since epoch and the noise of sensor values goes from 1 (clean) to 4 (heavy).
+type Entry struct {
+	Type       string  `json:"type"`
+	Sgv        float32 `json:"sgv,omitempty"`
+	Mbg        float32 `json:"mbg,omitempty"`
+	Date       int64   `json:"date"`
+	DateString string  `json:"dateString,omitempty"`
+	Direction  string  `json:"direction,omitempty"`
+	Device     string  `json:"device,omitempty"`
+	UtcOffset  *int    `json:"utcOffset,omitempty"`
+	Noise      int     `json:"noise,omitempty"`
+}
+
+// Treatment is a Nightscout treatment. A single treatment can hold both insulin and carbs (i.e. a Meal Bolus).
+type Treatment struct {
+	EventType string        `json:"eventType"`
+	CreatedAt string        `json:"created_at"`
+	Date      int64         `json:"date,omitempty"`
+	Mills     int64         `json:"mills,omitempty"`
+	Insulin   FlexibleFloat `json:"insulin,omitempty"`
+	Carbs     FlexibleFloat `json:"carbs,omitempty"`
+	Protein   FlexibleFloat `json:"protein,omitempty"`
+	Fat       FlexibleFloat `json:"fat,omitempty"`
+	Duration  FlexibleFloat `json:"duration,omitempty"`
+	Notes     string        `json:"notes,omitempty"`
+	EnteredBy string        `json:"enteredBy,omitempty"`
+	UtcOffset *int          `json:"utcOffset,omitempty"`
+}
+
+// FlexibleFloat is a number that uploaders sometimes send as a string (including empty strings for no value)
+type FlexibleFloat float32
+
+func (f *FlexibleFloat) UnmarshalJSON(data []byte) (err error) {
+	value := strings.Trim(string(data), "\"")
+	if value == "" || value == "null" {
+		*f = 0
+		return nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Invalid number [%s]: %v", value, err))
+	}
+	*f = FlexibleFloat(parsed)
+
+	return nil
+}
+
+// HashSecret returns the hash of an API secret the way Nightscout clients send it in the api-secret header
+func HashSecret(secret string) string {
+	hash := sha1.Sum([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// DecodeEntries decodes Nightscout entries sent either as an array or as a single entry
+func DecodeEntries(reader io.Reader) (entries []Entry, err error) {
+	err = decodeOneOrMany(reader, &entries, func(data []byte) error {
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+
+	return entries, err
+}
+
+// DecodeTreatments decodes Nightscout treatments sent either as an array or as a single treatment
+func DecodeTreatments(reader io.Reader) (treatments []Treatment, err error) {
+	err = decodeOneOrMany(reader, &treatments, func(data []byte) error {
+		var treatment Treatment
+		if err := json.Unmarshal(data, &treatment); err != nil {
+			return err
+		}
+		treatments = append(treatments, treatment)
+		return nil
+	})
+
+	return treatments, err
+}
+
+func decodeOneOrMany(reader io.Reader, many interface{}, decodeOne func(data []byte) error) (err error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		return decodeOne(data)
+	}
+
+	return json.Unmarshal(data, many)
+}
+
+// ToGlucoseData maps sgv entries to GlucoseReads and mbg entries to CalibrationReads, both sorted by time. Other
+// types of entries are ignored, as are sgv entries that carry a sensor error code (under MIN_VALID_SGV) or that are
+// noisy.
+func ToGlucoseData(entries []Entry) (reads []apimodel.GlucoseRead, calibrations []apimodel.CalibrationRead, err error) {
+	reads = make([]apimodel.GlucoseRead, 0)
+	calibrations = make([]apimodel.CalibrationRead, 0)
+
+	for _, entry := range entries {
+		if entry.Type != SGV_ENTRY_TYPE && entry.Type != MBG_ENTRY_TYPE {
+			continue
+		}
+
+		if entry.Type == SGV_ENTRY_TYPE && (entry.Sgv < MIN_VALID_SGV || entry.Noise > CLEAN_SGV_NOISE) {
+			continue
+		}
+
+		entryTime, err := newTime(entry.Date, entry.DateString, entry.UtcOffset)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		switch entry.Type {
+		case SGV_ENTRY_TYPE:
+			reads = append(reads, apimodel.GlucoseRead{Time: entryTime, Unit: NIGHTSCOUT_GLUCOSE_UNIT, Value: entry.Sgv})
+		case MBG_ENTRY_TYPE:
+			calibrations = append(calibrations, apimodel.CalibrationRead{Time: entryTime, Unit: NIGHTSCOUT_GLUCOSE_UNIT, Value: entry.Mbg})
+		}
+	}
+
+	sort.Sort(apimodel.GlucoseReadSlice(reads))
+	sort.Sort(apimodel.CalibrationReadSlice(calibrations))
+
+	return reads, calibrations, nil
+}
+
+// ToUserEvents maps treatments to Injections (for their insulin), Meals (for their carbs, protein and fat) and
+// Exercises (for Exercise treatments), all sorted by time. Treatments with none of those are ignored.
+func ToUserEvents(treatments []Treatment) (injections []apimodel.Injection, meals []apimodel.Meal, exercises []apimodel.Exercise, err error) {
+	injections = make([]apimodel.Injection, 0)
+	meals = make([]apimodel.Meal, 0)
+	exercises = make([]apimodel.Exercise, 0)
+
+	for _, treatment := range treatments {
+		date := treatment.Date
+		if date == 0 {
+			date = treatment.Mills
+		}
+
+		treatmentTime, err := newTime(date, treatment.CreatedAt, treatment.UtcOffset)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if treatment.Insulin > 0 {
+			injections = append(injections, apimodel.Injection{Time: treatmentTime, Units: float32(treatment.Insulin), InsulinName: "", InsulinType: ""})
+		}
+
+		if treatment.Carbs > 0 || treatment.Protein > 0 || treatment.Fat > 0 {
+			meals = append(meals, apimodel.Meal{Time: treatmentTime, Carbohydrates: float32(treatment.Carbs), Proteins: float32(treatment.Protein), Fat: float32(treatment.Fat), SaturatedFat: 0.})
+		}
+
+		if treatment.EventType == EXERCISE_EVENT_TYPE {
+			exercises = append(exercises, apimodel.Exercise{Time: treatmentTime, DurationMinutes: int(treatment.Duration), Intensity: "", Description: treatment.Notes})
+		}
+	}
+
+	sort.Sort(apimodel.InjectionSlice(injections))
+	sort.Sort(apimodel.MealSlice(meals))
+	sort.Sort(apimodel.ExerciseSlice(exercises))
+
+	return injections, meals, exercises, nil
+}
+
+// NewSgvEntries maps GlucoseReads to sgv entries, most recent first as Nightscout returns them
+func NewSgvEntries(reads []apimodel.GlucoseRead) (entries []Entry, err error) {
+	entries = make([]Entry, len(reads))
+	for i, read := range reads {
+		value, err := read.GetNormalizedValue(NIGHTSCOUT_GLUCOSE_UNIT)
+		if err != nil {
+			return nil, err
+		}
+
+		readTime := read.GetTime()
+		_, offsetInSeconds := readTime.Zone()
+		utcOffset := offsetInSeconds / 60
+		entries[len(reads)-1-i] = Entry{Type: SGV_ENTRY_TYPE, Sgv: float32(int(value + 0.5)), Date: read.Time.Timestamp,
+			DateString: readTime.Format(time.RFC3339), Device: GLUKIT_DEVICE, UtcOffset: &utcOffset}
+	}
+
+	return entries, nil
+}
+
+// newTime returns the time of a Nightscout record from its millisecond timestamp or its ISO 8601 date string if the
+// timestamp is missing. The timezone comes from the utc offset if present, from the date string otherwise.
+func newTime(date int64, dateString string, utcOffset *int) (timeValue apimodel.Time, err error) {
+	var parsed time.Time
+	if dateString != "" {
+		if parsed, err = time.Parse(time.RFC3339, dateString); err != nil {
+			if parsed, err = time.Parse("2006-01-02T15:04:05.000Z0700", dateString); err != nil {
+				if date == 0 {
+					return timeValue, errors.New(fmt.Sprintf("Invalid date [%s]: %v", dateString, err))
+				}
+				parsed = time.Unix(date/1000, 0).UTC()
+			}
+		}
+	} else if date != 0 {
+		parsed = time.Unix(date/1000, 0).UTC()
+	} else {
+		return timeValue, errors.New("Record has no date")
+	}
+
+	timestamp := apimodel.GetTimeMillis(parsed)
+	if date != 0 {
+		timestamp = date / 1000 * 1000
+	}
+
+	offsetInMinutes := 0
+	if utcOffset != nil {
+		offsetInMinutes = *utcOffset
+	} else {
+		_, offsetInSeconds := parsed.Zone()
+		offsetInMinutes = offsetInSeconds / 60
+	}
+
+	return apimodel.Time{Timestamp: timestamp, TimeZoneId: timeZoneId(offsetInMinutes)}, nil
+}
+
+// timeZoneId returns the name of a timezone location for a utc offset. Records only carry an offset so we map whole
+// hours to their Etc/GMT zone (whose sign is inverted by convention) and fractional offsets (i.e. +05:30) to a
+// "+0530" style name that util.GetOrLoadLocationForName resolves to a fixed zone.
+func timeZoneId(offsetInMinutes int) string {
+	if offsetInMinutes == 0 {
+		return "UTC"
+	}
+
+	if offsetInMinutes%60 == 0 {
+		return fmt.Sprintf("Etc/GMT%+d", -offsetInMinutes/60)
+	}
+
+	sign := "+"
+	if offsetInMinutes < 0 {
+		sign = "-"
+		offsetInMinutes = -offsetInMinutes
+	}
+
+	return fmt.Sprintf("%s%02d%02d", sign, offsetInMinutes/60, offsetInMinutes%60)
+}
@@ -0,0 +1,170 @@
+package nightscout_test
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	. "github.com/alexandre-normand/glukit/app/nightscout"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashSecret(t *testing.T) {
+	if hash := HashSecret("glukit"); hash != "e481895d9af5ce3fa9495b1e9e124d3d303fa86e" {
+		t.Errorf("Expected sha1 hex digest [e481895d9af5ce3fa9495b1e9e124d3d303fa86e] but got [%s]", hash)
+	}
+}
+
+func TestEntriesMapToReadsAndCalibrations(t *testing.T) {
+	entries, err := DecodeEntries(strings.NewReader(`[
+		{"type":"sgv","sgv":120,"date":1397835600000,"dateString":"2014-04-18T08:40:00.000-0700","direction":"Flat"},
+		{"type":"sgv","sgv":110,"date":1397835300000,"dateString":"2014-04-18T08:35:00.000-0700","direction":"Flat"},
+		{"type":"mbg","mbg":105,"date":1397835300000,"utcOffset":-420},
+		{"type":"cal","slope":800,"date":1397835300000}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reads, calibrations, err := ToGlucoseData(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reads) != 2 || len(calibrations) != 1 {
+		t.Fatalf("Expected [2] reads and [1] calibration but got [%v] and [%v]", reads, calibrations)
+	}
+
+	if reads[0].Value != 110 || reads[1].Value != 120 {
+		t.Errorf("Expected reads to be sorted by time but got [%v]", reads)
+	}
+
+	if reads[0].Unit != apimodel.MG_PER_DL || reads[0].Time.Timestamp != 1397835300000 {
+		t.Errorf("Unexpected read [%v]", reads[0])
+	}
+
+	if hour := reads[0].GetTime().Hour(); hour != 8 {
+		t.Errorf("Expected read in the local time of the uploader (8h) but got [%d]", hour)
+	}
+
+	if calibrations[0].Value != 105 || calibrations[0].GetTime().Hour() != 8 {
+		t.Errorf("Unexpected calibration [%v]", calibrations[0])
+	}
+}
+
+func TestSensorErrorsAndNoisyEntriesAreSkipped(t *testing.T) {
+	entries, err := DecodeEntries(strings.NewReader(`[
+		{"type":"sgv","sgv":120,"date":1397835600000,"noise":1},
+		{"type":"sgv","sgv":115,"date":1397835450000},
+		{"type":"sgv","sgv":5,"date":1397835300000},
+		{"type":"sgv","sgv":39,"date":1397835000000},
+		{"type":"sgv","sgv":180,"date":1397834700000,"noise":3}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reads, _, err := ToGlucoseData(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reads) != 2 || reads[0].Value != 115 || reads[1].Value != 120 {
+		t.Errorf("Expected only the clean reads [115] and [120] but got [%v]", reads)
+	}
+}
+
+func TestSingleEntryIsDecoded(t *testing.T) {
+	entries, err := DecodeEntries(strings.NewReader(`{"type":"sgv","sgv":95,"date":1397835300000}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Sgv != 95 {
+		t.Errorf("Expected a single entry with sgv [95] but got [%v]", entries)
+	}
+}
+
+func TestTreatmentsMapToUserEvents(t *testing.T) {
+	treatments, err := DecodeTreatments(strings.NewReader(`[
+		{"eventType":"Meal Bolus","created_at":"2014-04-18T15:35:00.000Z","insulin":2.5,"carbs":30,"protein":"","fat":"5","utcOffset":-420},
+		{"eventType":"Correction Bolus","created_at":"2014-04-18T17:00:00Z","insulin":1},
+		{"eventType":"Exercise","created_at":"2014-04-18T19:00:00Z","duration":45,"notes":"Bike ride"},
+		{"eventType":"Note","created_at":"2014-04-18T20:00:00Z","notes":"Nothing to map"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	injections, meals, exercises, err := ToUserEvents(treatments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(injections) != 2 || injections[0].Units != 2.5 || injections[1].Units != 1 {
+		t.Errorf("Unexpected injections [%v]", injections)
+	}
+
+	if len(meals) != 1 || meals[0].Carbohydrates != 30 || meals[0].Fat != 5 || meals[0].Proteins != 0 {
+		t.Errorf("Unexpected meals [%v]", meals)
+	}
+
+	if meals[0].GetTime().Hour() != 8 {
+		t.Errorf("Expected meal at 8h local time but got [%s]", meals[0].GetTime())
+	}
+
+	if len(exercises) != 1 || exercises[0].DurationMinutes != 45 || exercises[0].Description != "Bike ride" {
+		t.Errorf("Unexpected exercises [%v]", exercises)
+	}
+}
+
+func TestFractionalUtcOffsetsKeepTheirMinutes(t *testing.T) {
+	entries, err := DecodeEntries(strings.NewReader(`[
+		{"type":"sgv","sgv":120,"date":1397835600000,"utcOffset":330},
+		{"type":"sgv","sgv":110,"date":1397835300000,"utcOffset":-570}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reads, _, err := ToGlucoseData(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reads) != 2 {
+		t.Fatalf("Expected [2] reads but got [%v]", reads)
+	}
+
+	// 15:35 UTC is 06:05 at -09:30
+	if readTime := reads[0].GetTime(); reads[0].Time.TimeZoneId != "-0930" || readTime.Hour() != 6 || readTime.Minute() != 5 {
+		t.Errorf("Expected read at 06:05 in zone [-0930] but got [%s] in zone [%s]", readTime, reads[0].Time.TimeZoneId)
+	}
+
+	// 15:40 UTC is 21:10 at +05:30
+	if readTime := reads[1].GetTime(); reads[1].Time.TimeZoneId != "+0530" || readTime.Hour() != 21 || readTime.Minute() != 10 {
+		t.Errorf("Expected read at 21:10 in zone [+0530] but got [%s] in zone [%s]", readTime, reads[1].Time.TimeZoneId)
+	}
+}
+
+func TestTreatmentWithoutDate(t *testing.T) {
+	if _, _, _, err := ToUserEvents([]Treatment{Treatment{EventType: "Correction Bolus", Insulin: 1}}); err == nil {
+		t.Errorf("Expected an error for a treatment without a date")
+	}
+}
+
+func TestNewSgvEntriesAreMostRecentFirst(t *testing.T) {
+	readTime := time.Date(2014, 4, 18, 8, 35, 0, 0, time.UTC)
+	reads := []apimodel.GlucoseRead{
+		apimodel.GlucoseRead{Time: apimodel.Time{apimodel.GetTimeMillis(readTime), "UTC"}, Unit: apimodel.MMOL_PER_L, Value: 5.55},
+		apimodel.GlucoseRead{Time: apimodel.Time{apimodel.GetTimeMillis(readTime.Add(time.Duration(5) * time.Minute)), "UTC"}, Unit: apimodel.MG_PER_DL, Value: 120},
+	}
+
+	entries, err := NewSgvEntries(reads)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entries[0].Sgv != 120 || entries[1].Sgv != 100 {
+		t.Errorf("Expected entries in mg/dL, most recent first, but got [%v]", entries)
+	}
+
+	if entries[1].Type != SGV_ENTRY_TYPE || entries[1].Date != apimodel.GetTimeMillis(readTime) {
+		t.Errorf("Unexpected entry [%v]", entries[1])
+	}
+}
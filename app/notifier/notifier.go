@@ -0,0 +1,69 @@
+// The notifier package delivers the alerts raised for users through webhooks and emails
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/util"
+	"google.golang.org/appengine/urlfetch"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// How long connecting to a webhook can take
+const WEBHOOK_DIAL_TIMEOUT = 30 * time.Second
+
+// Notifier delivers alerts raised for a user
+type Notifier interface {
+	// Notify delivers an alert raised for the user with the given email
+	Notify(context context.Context, email string, event model.AlertEvent) (err error)
+}
+
+// The provider of http clients for webhooks, App Engine's urlfetch unless configured otherwise
+var httpClientProvider = urlfetch.Client
+
+// SetHttpClientProvider sets the function returning the http client used to call webhooks. This is meant to be
+// called once, during initialization, before any request is served.
+func SetHttpClientProvider(provider func(context context.Context) *http.Client) {
+	httpClientProvider = provider
+}
+
+// NewPublicHttpClient returns an http client that refuses to connect to loopback, private and link-local addresses.
+// Addresses are checked once host names are resolved so that webhooks can't reach the server's own network through a
+// host name that resolves to it.
+func NewPublicHttpClient() *http.Client {
+	dialer := &net.Dialer{Timeout: WEBHOOK_DIAL_TIMEOUT, Control: rejectInternalAddress}
+	return &http.Client{Transport: &http.Transport{DialContext: dialer.DialContext}}
+}
+
+// rejectInternalAddress fails the connection to an internal address
+func rejectInternalAddress(network string, address string, conn syscall.RawConn) (err error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || util.IsInternalIP(ip) {
+		return fmt.Errorf("Connection to internal address [%s] refused", address)
+	}
+
+	return nil
+}
+
+// NotifiersFor returns the notifiers for the channels configured in the alert settings of a user. Emails are only
+// sent if an smtp server is configured.
+func NotifiersFor(settings model.AlertSettings) (notifiers []Notifier) {
+	notifiers = make([]Notifier, 0)
+	if settings.WebhookUrl != "" {
+		notifiers = append(notifiers, WebhookNotifier{Url: settings.WebhookUrl})
+	}
+
+	if settings.NotificationEmail != "" && smtpServer != nil {
+		notifiers = append(notifiers, SmtpNotifier{Server: *smtpServer, To: settings.NotificationEmail})
+	}
+
+	return notifiers
+}
@@ -0,0 +1,142 @@
+package notifier_test
+
+import (
+	"bufio"
+	"context"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/notifier"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testEvent = model.AlertEvent{Type: model.SUSTAINED_LOW_ALERT, Time: time.Date(2014, 4, 18, 0, 0, 0, 0, time.UTC),
+	Value: 60, Rule: model.AlertRule{model.SUSTAINED_LOW_ALERT, 70, 15}, Message: "Glucose below 70 mg/dL for 15 minutes, now at 60 mg/dL"}
+
+func TestWebhookPostsAlert(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != "POST" || request.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected json post but got [%s] of [%s]", request.Method, request.Header.Get("Content-Type"))
+		}
+		buffer := new(strings.Builder)
+		bufio.NewReader(request.Body).WriteTo(buffer)
+		body = buffer.String()
+	}))
+	defer server.Close()
+
+	webhook := notifier.WebhookNotifier{Url: server.URL, Client: server.Client()}
+	if err := webhook.Notify(context.Background(), "test@glukit.com", testEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(body, `"email":"test@glukit.com"`) || !strings.Contains(body, `"type":"SustainedLow"`) {
+		t.Errorf("Expected alert of user in webhook body but got [%s]", body)
+	}
+}
+
+func TestWebhookFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.Error(writer, "Unavailable", 503)
+	}))
+	defer server.Close()
+
+	webhook := notifier.WebhookNotifier{Url: server.URL, Client: server.Client()}
+	if err := webhook.Notify(context.Background(), "test@glukit.com", testEvent); err == nil {
+		t.Errorf("Expected error on webhook responding with an error status")
+	}
+}
+
+func TestPublicHttpClientRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		t.Errorf("Expected no request to reach the internal webhook")
+	}))
+	defer server.Close()
+
+	webhook := notifier.WebhookNotifier{Url: server.URL, Client: notifier.NewPublicHttpClient()}
+	if err := webhook.Notify(context.Background(), "test@glukit.com", testEvent); err == nil {
+		t.Errorf("Expected error on webhook at internal address [%s]", server.URL)
+	}
+}
+
+func TestSmtpSendsAlert(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	messages := make(chan string, 1)
+	go serveSmtp(t, listener, messages)
+
+	smtpNotifier := notifier.SmtpNotifier{Server: notifier.SmtpServer{Address: listener.Addr().String(), From: "glukit@localhost"}, To: "caregiver@glukit.com"}
+	if err := smtpNotifier.Notify(context.Background(), "test@glukit.com", testEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case message := <-messages:
+		if !strings.Contains(message, "To: caregiver@glukit.com") || !strings.Contains(message, "Subject: Glukit alert: "+testEvent.Message) {
+			t.Errorf("Expected alert email to caregiver but got [%s]", message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for email")
+	}
+}
+
+func TestNotifiersForSettings(t *testing.T) {
+	notifiers := notifier.NotifiersFor(model.AlertSettings{WebhookUrl: "http://localhost/alerts"})
+	if len(notifiers) != 1 {
+		t.Errorf("Expected a single webhook notifier but got [%v]", notifiers)
+	}
+}
+
+// serveSmtp is a minimal smtp server accepting a single message, which it sends to messages
+func serveSmtp(t *testing.T, listener net.Listener, messages chan<- string) {
+	connection, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer connection.Close()
+
+	reader := bufio.NewReader(connection)
+	reply := func(line string) {
+		connection.Write([]byte(line + "\r\n"))
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		switch command := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "DATA"):
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var message strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				message.WriteString(dataLine)
+			}
+			messages <- message.String()
+			reply("250 OK")
+		case strings.HasPrefix(command, "QUIT"):
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
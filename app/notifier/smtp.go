@@ -0,0 +1,62 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/model"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SmtpServer is the server emails are sent through. Authentication is only attempted if a username is set.
+type SmtpServer struct {
+	Address  string
+	From     string
+	Username string
+	Password string
+}
+
+// The smtp server, none unless configured
+var smtpServer *SmtpServer
+
+// SetSmtpServer sets the server used to send alerts by email. This is meant to be called once, during
+// initialization, before any request is served.
+func SetSmtpServer(server SmtpServer) {
+	smtpServer = &server
+}
+
+// SmtpNotifier sends alerts by email to To
+type SmtpNotifier struct {
+	Server SmtpServer
+	To     string
+}
+
+// Notify sends the alert as a plain text email
+func (notifier SmtpNotifier) Notify(context context.Context, email string, event model.AlertEvent) (err error) {
+	var auth smtp.Auth
+	if notifier.Server.Username != "" {
+		host, _, err := net.SplitHostPort(notifier.Server.Address)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", notifier.Server.Username, notifier.Server.Password, host)
+	}
+
+	return smtp.SendMail(notifier.Server.Address, auth, notifier.Server.From, []string{notifier.To}, notifier.message(email, event))
+}
+
+// message returns the email for an alert, headers included
+func (notifier SmtpNotifier) message(email string, event model.AlertEvent) []byte {
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", notifier.Server.From)
+	fmt.Fprintf(&message, "To: %s\r\n", notifier.To)
+	fmt.Fprintf(&message, "Subject: Glukit alert: %s\r\n", event.Message)
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&message, "%s\r\n\r\n", event.Message)
+	fmt.Fprintf(&message, "User: %s\r\nAlert: %s\r\nTime: %s\r\n", email, event.Type, event.Time.Format(time.RFC3339))
+
+	return message.Bytes()
+}
@@ -0,0 +1,48 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/model"
+	"net/http"
+)
+
+// WebhookNotifier posts alerts as json to a url. Client is the http client to use, the one of the configured
+// provider if nil.
+type WebhookNotifier struct {
+	Url    string
+	Client *http.Client
+}
+
+// WebhookPayload is the json body posted to webhooks
+type WebhookPayload struct {
+	Email string           `json:"email"`
+	Alert model.AlertEvent `json:"alert"`
+}
+
+// Notify posts the alert to the webhook and fails if it doesn't respond with a 2xx status
+func (notifier WebhookNotifier) Notify(context context.Context, email string, event model.AlertEvent) (err error) {
+	body, err := json.Marshal(WebhookPayload{email, event})
+	if err != nil {
+		return err
+	}
+
+	client := notifier.Client
+	if client == nil {
+		client = httpClientProvider(context)
+	}
+
+	response, err := client.Post(notifier.Url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("Webhook [%s] responded with status [%d]", notifier.Url, response.StatusCode)
+	}
+
+	return nil
+}
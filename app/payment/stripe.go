@@ -6,7 +6,7 @@ import (
 	"github.com/alexandre-normand/glukit/app/config"
 	"github.com/cosn/stripe"
 	"context"
-	"google.golang.org/appengine/log"
+	"github.com/alexandre-normand/glukit/app/log"
 	"google.golang.org/appengine/urlfetch"
 	"google.golang.org/appengine/user"
 	"strconv"
@@ -0,0 +1,664 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/model"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"math"
+	"sort"
+	"time"
+)
+
+// DatastoreRepository is the Repository backed by the App Engine datastore
+type DatastoreRepository struct {
+}
+
+// NewDatastoreRepository returns a new Repository that persists to the App Engine datastore
+func NewDatastoreRepository() *DatastoreRepository {
+	return &DatastoreRepository{}
+}
+
+// GetUserKey returns the GlukitUser datastore key given its email address.
+func GetUserKey(context context.Context, email string) (key *datastore.Key) {
+	return datastore.NewKey(context, "GlukitUser", email, 0, nil)
+}
+
+func (r *DatastoreRepository) GetUser(context context.Context, email string) (userProfile *model.GlukitUser, err error) {
+	key := GetUserKey(context, email)
+	userProfile = new(model.GlukitUser)
+	log.Infof(context, "Fetching user profile for key: %s", key.String())
+	err = datastore.Get(context, key, userProfile)
+	if err == datastore.ErrNoSuchEntity {
+		return nil, ErrNoSuchEntity
+	} else if err != nil {
+		if unknownFields, ok := err.(*datastore.ErrFieldMismatch); ok {
+			log.Infof(context, "Ignoring unknown fields [%s]", unknownFields.Error())
+		} else {
+			return nil, err
+		}
+	}
+
+	return userProfile, nil
+}
+
+func (r *DatastoreRepository) PutUser(context context.Context, userProfile model.GlukitUser) (err error) {
+	_, err = datastore.Put(context, GetUserKey(context, userProfile.Email), &userProfile)
+	return err
+}
+
+func (r *DatastoreRepository) FindUsersByDiabetesType(context context.Context, diabetesType string, limit int) (users []model.GlukitUser, err error) {
+	query := datastore.NewQuery("GlukitUser").
+		Filter("diabetesType =", diabetesType).
+		Order("mostRecentScore.value").Limit(limit)
+
+	_, err = query.GetAll(context, &users)
+	if err != nil {
+		if unknownFields, ok := err.(*datastore.ErrFieldMismatch); ok {
+			log.Infof(context, "Ignoring unknown fields [%s]", unknownFields.Error())
+		} else {
+			return nil, err
+		}
+	}
+
+	return users, nil
+}
+
+func (r *DatastoreRepository) ScanUserEmails(context context.Context, after string, limit int) (emails []string, err error) {
+	query := datastore.NewQuery("GlukitUser").KeysOnly().Order("__key__").Limit(limit)
+	if after != "" {
+		query = query.Filter("__key__ >", GetUserKey(context, after))
+	}
+
+	keys, err := query.GetAll(context, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	emails = make([]string, len(keys))
+	for i, key := range keys {
+		emails[i] = key.StringID()
+	}
+
+	return emails, nil
+}
+
+// dayKeys returns the datastore keys of the days of data of the given kind
+func dayKeys(context context.Context, kind string, email string, startTimes []time.Time) (keys []*datastore.Key) {
+	userProfileKey := GetUserKey(context, email)
+	keys = make([]*datastore.Key, len(startTimes))
+	for i := range startTimes {
+		keys[i] = datastore.NewKey(context, kind, "", startTimes[i].Unix(), userProfileKey)
+	}
+
+	return keys
+}
+
+// GetDays does a GetMulti of days of data into dst which must be a slice of the same length as startTimes.
+// Elements that don't exist are flagged as not found.
+func (r *DatastoreRepository) GetDays(context context.Context, kind string, email string, startTimes []time.Time, dst interface{}) (found []bool, err error) {
+	found = make([]bool, len(startTimes))
+	err = datastore.GetMulti(context, dayKeys(context, kind, email, startTimes), dst)
+	// If there's an error and it's not a MultiError, return immediately as something went wrong
+	if multierr, ok := err.(appengine.MultiError); !ok && err != nil {
+		log.Warningf(context, "Got error: %v", err)
+		return nil, err
+	} else if err == nil {
+		for i := range found {
+			found[i] = true
+		}
+	} else {
+		for i, elementErr := range multierr {
+			found[i] = elementErr != datastore.ErrNoSuchEntity
+		}
+	}
+
+	return found, nil
+}
+
+// ScanDays gets all days of data of a kind with a start time between scanStart and scanEnd into dst
+func (r *DatastoreRepository) ScanDays(context context.Context, kind string, email string, scanStart, scanEnd time.Time, dst interface{}) (err error) {
+	query := datastore.NewQuery(kind).Ancestor(GetUserKey(context, email)).Filter("startTime >=", scanStart).Filter("startTime <=", scanEnd).Order("startTime")
+	_, err = query.GetAll(context, dst)
+	return err
+}
+
+// PutDays does a PutMulti of days of data of a kind. src must be a slice of the same length as startTimes.
+func (r *DatastoreRepository) PutDays(context context.Context, kind string, email string, startTimes []time.Time, src interface{}) (err error) {
+	elementKeys := dayKeys(context, kind, email, startTimes)
+
+	log.Infof(context, "Emitting a PutMulti with %d keys for all days of [%s]", len(elementKeys), kind)
+	if _, err = datastore.PutMulti(context, elementKeys, src); err != nil {
+		log.Criticalf(context, "Error writing %d days of [%s] with keys [%s]: %v", len(elementKeys), kind, elementKeys, err)
+		return err
+	}
+
+	return nil
+}
+
+// PutGlukitScores stores a batch of GlukitScores. The array could be of any size. A large batch of GlukitScores
+// will be internally split into multiple PutMultis.
+func (r *DatastoreRepository) PutGlukitScores(context context.Context, email string, scores []model.GlukitScore) (err error) {
+	parentKey := GetUserKey(context, email)
+
+	totalBatchSize := float64(len(scores))
+	for chunkStartIndex := 0; chunkStartIndex < len(scores); chunkStartIndex = chunkStartIndex + GLUKIT_SCORE_PUT_MULTI_SIZE {
+		chunkEndIndex := int(math.Min(float64(chunkStartIndex+GLUKIT_SCORE_PUT_MULTI_SIZE), totalBatchSize))
+		glukitScoreChunk := scores[chunkStartIndex:chunkEndIndex]
+		if _, err := storeGlukitScoreChunk(context, parentKey, glukitScoreChunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func storeGlukitScoreChunk(context context.Context, parentKey *datastore.Key, glukitScoreChunk []model.GlukitScore) (keys []*datastore.Key, err error) {
+	log.Debugf(context, "Storing chunk of [%d] glukit scores", len(glukitScoreChunk))
+
+	elementKeys := make([]*datastore.Key, len(glukitScoreChunk))
+	for i := range glukitScoreChunk {
+		elementKeys[i] = datastore.NewKey(context, "GlukitScore", "", glukitScoreChunk[i].UpperBound.Unix(), parentKey)
+	}
+
+	log.Infof(context, "Emitting a PutMulti with [%d] keys for all [%d] glukit scores of chunk", len(elementKeys), len(glukitScoreChunk))
+	keys, error := datastore.PutMulti(context, elementKeys, glukitScoreChunk)
+	if error != nil {
+		log.Criticalf(context, "Error writing [%d] glukit scores with keys [%s]: %v", len(elementKeys), elementKeys, error)
+		return nil, error
+	}
+
+	return keys, nil
+}
+
+func (r *DatastoreRepository) ScanGlukitScores(context context.Context, email string, scanQuery ScoreScanQuery) (scores []model.GlukitScore, err error) {
+	_, err = newScoreQuery("GlukitScore", GetUserKey(context, email), scanQuery).GetAll(context, &scores)
+	return scores, err
+}
+
+// FindGlukitScoresBelowVersion walks the scores by upper bound and filters on ScoringVersion, which is the name the
+// scoring version is stored under, in memory since the datastore doesn't allow inequality filters on both
+func (r *DatastoreRepository) FindGlukitScoresBelowVersion(context context.Context, email string, scoringVersion int, after time.Time, limit int) (scores []model.GlukitScore, err error) {
+	scores = make([]model.GlukitScore, 0)
+	iterator := datastore.NewQuery("GlukitScore").Ancestor(GetUserKey(context, email)).Filter("upperBound >", after).Order("upperBound").Run(context)
+	for len(scores) < limit {
+		var score model.GlukitScore
+		if _, err = iterator.Next(&score); err == datastore.Done {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if score.ScoringVersion < scoringVersion {
+			scores = append(scores, score)
+		}
+	}
+
+	return scores, nil
+}
+
+// PutA1CEstimates stores a batch of A1C calculations. The array could be of any size. A large batch of A1CEstimates
+// will be internally split into multiple PutMultis.
+func (r *DatastoreRepository) PutA1CEstimates(context context.Context, email string, a1cs []model.A1CEstimate) (err error) {
+	parentKey := GetUserKey(context, email)
+
+	totalBatchSize := float64(len(a1cs))
+	for chunkStartIndex := 0; chunkStartIndex < len(a1cs); chunkStartIndex = chunkStartIndex + GLUKIT_SCORE_PUT_MULTI_SIZE {
+		chunkEndIndex := int(math.Min(float64(chunkStartIndex+GLUKIT_SCORE_PUT_MULTI_SIZE), totalBatchSize))
+		a1cChunk := a1cs[chunkStartIndex:chunkEndIndex]
+		if _, err := storeA1CChunk(context, parentKey, a1cChunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func storeA1CChunk(context context.Context, parentKey *datastore.Key, a1cChunk []model.A1CEstimate) (keys []*datastore.Key, err error) {
+	log.Debugf(context, "Storing chunk of [%d] a1c calculations", len(a1cChunk))
+
+	elementKeys := make([]*datastore.Key, len(a1cChunk))
+	for i := range a1cChunk {
+		elementKeys[i] = datastore.NewKey(context, "A1CEstimate", "", a1cChunk[i].UpperBound.Unix(), parentKey)
+	}
+
+	log.Infof(context, "Emitting a PutMulti with [%d] keys for all [%d] a1cs of chunk", len(elementKeys), len(a1cChunk))
+	keys, error := datastore.PutMulti(context, elementKeys, a1cChunk)
+	if error != nil {
+		log.Criticalf(context, "Error writing [%d] a1c calculations with keys [%s]: %v", len(elementKeys), elementKeys, error)
+		return nil, error
+	}
+
+	return keys, nil
+}
+
+func (r *DatastoreRepository) ScanA1CEstimates(context context.Context, email string, scanQuery ScoreScanQuery) (a1cs []model.A1CEstimate, err error) {
+	_, err = newScoreQuery("A1CEstimate", GetUserKey(context, email), scanQuery).GetAll(context, &a1cs)
+	return a1cs, err
+}
+
+func (r *DatastoreRepository) PutLabA1C(context context.Context, email string, lab model.LabA1C) (err error) {
+	key := datastore.NewKey(context, "LabA1C", "", lab.TakenOn.Unix(), GetUserKey(context, email))
+
+	log.Infof(context, "Emitting a Put for lab a1c with key [%s]", key)
+	_, err = datastore.Put(context, key, &lab)
+	return err
+}
+
+func (r *DatastoreRepository) ScanLabA1Cs(context context.Context, email string, scanQuery ScoreScanQuery) (labs []model.LabA1C, err error) {
+	_, err = newTimeRangeQuery("LabA1C", "takenOn", GetUserKey(context, email), scanQuery).GetAll(context, &labs)
+	return labs, err
+}
+
+func (r *DatastoreRepository) PutSensorSessions(context context.Context, email string, sessions []model.SensorSession) (err error) {
+	parentKey := GetUserKey(context, email)
+
+	for chunkStartIndex := 0; chunkStartIndex < len(sessions); chunkStartIndex = chunkStartIndex + GLUKIT_SCORE_PUT_MULTI_SIZE {
+		chunkEndIndex := int(math.Min(float64(chunkStartIndex+GLUKIT_SCORE_PUT_MULTI_SIZE), float64(len(sessions))))
+		sessionChunk := sessions[chunkStartIndex:chunkEndIndex]
+
+		elementKeys := make([]*datastore.Key, len(sessionChunk))
+		for i := range sessionChunk {
+			elementKeys[i] = datastore.NewKey(context, "SensorSession", "", sessionChunk[i].InsertedOn.Unix(), parentKey)
+		}
+
+		log.Infof(context, "Emitting a PutMulti with [%d] keys for sensor sessions", len(elementKeys))
+		if _, err = datastore.PutMulti(context, elementKeys, sessionChunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *DatastoreRepository) ScanSensorSessions(context context.Context, email string, scanQuery ScoreScanQuery) (sessions []model.SensorSession, err error) {
+	_, err = newTimeRangeQuery("SensorSession", "insertedOn", GetUserKey(context, email), scanQuery).GetAll(context, &sessions)
+	return sessions, err
+}
+
+// PutTherapyEstimate stores an estimate keyed by its upper bound (midnight UTC, so one per day), replacing any previous
+// estimate for the same day
+func (r *DatastoreRepository) PutTherapyEstimate(context context.Context, email string, estimate model.TherapyEstimate) (err error) {
+	key := datastore.NewKey(context, "TherapyEstimate", "", estimate.UpperBound.Unix(), GetUserKey(context, email))
+
+	log.Infof(context, "Emitting a Put for therapy estimate with key [%s]", key)
+	_, err = datastore.Put(context, key, &estimate)
+	return err
+}
+
+func (r *DatastoreRepository) ScanTherapyEstimates(context context.Context, email string, scanQuery ScoreScanQuery) (estimates []model.TherapyEstimate, err error) {
+	_, err = newScoreQuery("TherapyEstimate", GetUserKey(context, email), scanQuery).GetAll(context, &estimates)
+	return estimates, err
+}
+
+// PutMealImpacts stores meal impacts keyed by the time of their meal, replacing previous calculations
+func (r *DatastoreRepository) PutMealImpacts(context context.Context, email string, impacts []model.MealImpact) (err error) {
+	parentKey := GetUserKey(context, email)
+
+	for chunkStartIndex := 0; chunkStartIndex < len(impacts); chunkStartIndex = chunkStartIndex + GLUKIT_SCORE_PUT_MULTI_SIZE {
+		chunkEndIndex := int(math.Min(float64(chunkStartIndex+GLUKIT_SCORE_PUT_MULTI_SIZE), float64(len(impacts))))
+		impactChunk := impacts[chunkStartIndex:chunkEndIndex]
+
+		elementKeys := make([]*datastore.Key, len(impactChunk))
+		for i := range impactChunk {
+			elementKeys[i] = datastore.NewKey(context, "MealImpact", "", impactChunk[i].MealTime.Unix(), parentKey)
+		}
+
+		log.Infof(context, "Emitting a PutMulti with [%d] keys for meal impacts", len(elementKeys))
+		if _, err = datastore.PutMulti(context, elementKeys, impactChunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *DatastoreRepository) ScanMealImpacts(context context.Context, email string, scanQuery ScoreScanQuery) (impacts []model.MealImpact, err error) {
+	_, err = newTimeRangeQuery("MealImpact", "mealTime", GetUserKey(context, email), scanQuery).GetAll(context, &impacts)
+	return impacts, err
+}
+
+func (r *DatastoreRepository) PutInsights(context context.Context, email string, insights []model.Insight) (err error) {
+	parentKey := GetUserKey(context, email)
+
+	elementKeys := make([]*datastore.Key, len(insights))
+	for i := range insights {
+		elementKeys[i] = datastore.NewKey(context, "Insight", insightKeyName(insights[i].Type, insights[i].UpperBound), 0, parentKey)
+	}
+
+	log.Infof(context, "Emitting a PutMulti with [%d] keys for insights", len(elementKeys))
+	_, err = datastore.PutMulti(context, elementKeys, insights)
+	return err
+}
+
+func (r *DatastoreRepository) ScanInsights(context context.Context, email string, scanQuery ScoreScanQuery) (insights []model.Insight, err error) {
+	_, err = newScoreQuery("Insight", GetUserKey(context, email), scanQuery).GetAll(context, &insights)
+	return insights, err
+}
+
+// newScoreQuery returns the query for elements keyed by upper bound, most recent first
+func newScoreQuery(kind string, parentKey *datastore.Key, scanQuery ScoreScanQuery) (query *datastore.Query) {
+	return newTimeRangeQuery(kind, "upperBound", parentKey, scanQuery)
+}
+
+// newTimeRangeQuery returns the query for elements whose time property matches the scan query, most recent first
+func newTimeRangeQuery(kind string, property string, parentKey *datastore.Key, scanQuery ScoreScanQuery) (query *datastore.Query) {
+	query = datastore.NewQuery(kind).Ancestor(parentKey)
+	if scanQuery.From != nil {
+		query = query.Filter(property+" >=", *scanQuery.From)
+	}
+	if scanQuery.To != nil {
+		query = query.Filter(property+" <=", *scanQuery.To)
+	}
+	if scanQuery.Limit != nil {
+		query = query.Limit(*scanQuery.Limit)
+	}
+
+	return query.Order("-" + property)
+}
+
+func (r *DatastoreRepository) PutFileImportLog(context context.Context, email string, fileImport model.FileImportLog) (err error) {
+	key := datastore.NewKey(context, "FileImportLog", fileImport.Id, 0, GetUserKey(context, email))
+
+	log.Infof(context, "Emitting a Put for file import log with key [%s] for file id [%s]", key, fileImport.Id)
+	_, err = datastore.Put(context, key, &fileImport)
+	return err
+}
+
+func (r *DatastoreRepository) GetFileImportLog(context context.Context, email string, fileId string) (fileImport *model.FileImportLog, err error) {
+	key := datastore.NewKey(context, "FileImportLog", fileId, 0, GetUserKey(context, email))
+
+	fileImport = new(model.FileImportLog)
+	if err = datastore.Get(context, key, fileImport); err == datastore.ErrNoSuchEntity {
+		return nil, ErrNoSuchEntity
+	} else if err != nil {
+		return nil, err
+	}
+
+	return fileImport, nil
+}
+
+func (r *DatastoreRepository) PutUploadedFileChunks(context context.Context, email string, chunks []UploadedFileChunk) (err error) {
+	keys := make([]*datastore.Key, len(chunks))
+	for i, chunk := range chunks {
+		keys[i] = datastore.NewKey(context, "UploadedFileChunk", fmt.Sprintf("%s-%d", chunk.FileId, chunk.Index), 0, GetUserKey(context, email))
+	}
+
+	// Chunks are close to the maximum entity size so we put them one at a time to stay under the maximum request size
+	for i := range chunks {
+		if _, err = datastore.Put(context, keys[i], &chunks[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *DatastoreRepository) GetUploadedFileChunks(context context.Context, email string, fileId string) (chunks []UploadedFileChunk, err error) {
+	query := datastore.NewQuery("UploadedFileChunk").Ancestor(GetUserKey(context, email)).Filter("FileId =", fileId)
+	if _, err = query.GetAll(context, &chunks); err != nil {
+		return nil, err
+	}
+
+	sort.Sort(uploadedFileChunksByIndex(chunks))
+	return chunks, nil
+}
+
+func (r *DatastoreRepository) DeleteUploadedFileChunks(context context.Context, email string, fileId string) (err error) {
+	query := datastore.NewQuery("UploadedFileChunk").Ancestor(GetUserKey(context, email)).Filter("FileId =", fileId).KeysOnly()
+	keys, err := query.GetAll(context, nil)
+	if err != nil {
+		return err
+	}
+
+	return datastore.DeleteMulti(context, keys)
+}
+
+func (r *DatastoreRepository) GetOAuthClient(context context.Context, id string) (client *OAuthClient, err error) {
+	client = new(OAuthClient)
+	if err = getByName(context, "osin.client", id, client); err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+func (r *DatastoreRepository) PutOAuthClient(context context.Context, client OAuthClient) (err error) {
+	return putByName(context, "osin.client", client.Id, &client)
+}
+
+func (r *DatastoreRepository) GetOAuthAuthorizeData(context context.Context, code string) (data *OAuthAuthorizeData, err error) {
+	data = new(OAuthAuthorizeData)
+	if err = getByName(context, "authorize.data", code, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (r *DatastoreRepository) PutOAuthAuthorizeData(context context.Context, data OAuthAuthorizeData) (err error) {
+	return putByName(context, "authorize.data", data.Code, &data)
+}
+
+func (r *DatastoreRepository) DeleteOAuthAuthorizeData(context context.Context, code string) (err error) {
+	return datastore.Delete(context, datastore.NewKey(context, "authorize.data", code, 0, nil))
+}
+
+func (r *DatastoreRepository) GetOAuthAccessData(context context.Context, token string) (data *OAuthAccessData, err error) {
+	data = new(OAuthAccessData)
+	if err = getByName(context, "access.data", token, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (r *DatastoreRepository) PutOAuthAccessData(context context.Context, data OAuthAccessData) (err error) {
+	return putByName(context, "access.data", data.AccessToken, &data)
+}
+
+func (r *DatastoreRepository) DeleteOAuthAccessData(context context.Context, token string) (err error) {
+	return datastore.Delete(context, datastore.NewKey(context, "access.data", token, 0, nil))
+}
+
+func (r *DatastoreRepository) GetOAuthRefreshData(context context.Context, token string) (data *OAuthAccessData, err error) {
+	data = new(OAuthAccessData)
+	if err = getByName(context, "access.refresh", token, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (r *DatastoreRepository) PutOAuthRefreshData(context context.Context, data OAuthAccessData) (err error) {
+	return putByName(context, "access.refresh", data.RefreshToken, &data)
+}
+
+func (r *DatastoreRepository) DeleteOAuthRefreshData(context context.Context, token string) (err error) {
+	return datastore.Delete(context, datastore.NewKey(context, "access.refresh", token, 0, nil))
+}
+
+func (r *DatastoreRepository) FindOAuthAccessData(context context.Context, email string) (data []OAuthAccessData, err error) {
+	return findOAuthDataOfUser(context, "access.data", email)
+}
+
+func (r *DatastoreRepository) FindOAuthRefreshData(context context.Context, email string) (data []OAuthAccessData, err error) {
+	return findOAuthDataOfUser(context, "access.refresh", email)
+}
+
+// findOAuthDataOfUser scans all the access or refresh data and keeps the ones issued to a user. UserData isn't indexed
+// so it can't be filtered on by the query. This is only done when revoking the tokens of a user so the full scan is
+// acceptable.
+func findOAuthDataOfUser(context context.Context, kind string, email string) (data []OAuthAccessData, err error) {
+	data = make([]OAuthAccessData, 0)
+	iterator := datastore.NewQuery(kind).Run(context)
+	for {
+		var accessData OAuthAccessData
+		_, err = iterator.Next(&accessData)
+		if err == datastore.Done {
+			return data, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if accessData.UserData == email {
+			data = append(data, accessData)
+		}
+	}
+}
+
+func (r *DatastoreRepository) GetNightscoutSecret(context context.Context, secretHash string) (secret *NightscoutSecret, err error) {
+	secret = new(NightscoutSecret)
+	if err = getByName(context, "NightscoutSecret", secretHash, secret); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+func (r *DatastoreRepository) FindNightscoutSecrets(context context.Context, email string) (secrets []NightscoutSecret, err error) {
+	query := datastore.NewQuery("NightscoutSecret").Filter("Email =", email)
+	if _, err = query.GetAll(context, &secrets); err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
+
+func (r *DatastoreRepository) PutNightscoutSecret(context context.Context, secret NightscoutSecret) (err error) {
+	return putByName(context, "NightscoutSecret", secret.SecretHash, &secret)
+}
+
+func (r *DatastoreRepository) DeleteNightscoutSecret(context context.Context, secretHash string) (err error) {
+	return datastore.Delete(context, datastore.NewKey(context, "NightscoutSecret", secretHash, 0, nil))
+}
+
+func (r *DatastoreRepository) GetAccountDeletion(context context.Context, emailHash string) (deletion *AccountDeletion, err error) {
+	deletion = new(AccountDeletion)
+	if err = getByName(context, "AccountDeletion", emailHash, deletion); err != nil {
+		return nil, err
+	}
+
+	return deletion, nil
+}
+
+func (r *DatastoreRepository) PutAccountDeletion(context context.Context, deletion AccountDeletion) (err error) {
+	return putByName(context, "AccountDeletion", deletion.EmailHash, &deletion)
+}
+
+func (r *DatastoreRepository) FindAccountDeletions(context context.Context, status string) (deletions []AccountDeletion, err error) {
+	query := datastore.NewQuery("AccountDeletion").Filter("Status =", status)
+	if _, err = query.GetAll(context, &deletions); err != nil {
+		return nil, err
+	}
+
+	return deletions, nil
+}
+
+func (r *DatastoreRepository) GetAlertSettings(context context.Context, email string) (settings *model.AlertSettings, err error) {
+	key := datastore.NewKey(context, "AlertSettings", ALERT_SETTINGS_KEY_NAME, 0, GetUserKey(context, email))
+
+	settings = new(model.AlertSettings)
+	if err = datastore.Get(context, key, settings); err == datastore.ErrNoSuchEntity {
+		return nil, ErrNoSuchEntity
+	} else if err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func (r *DatastoreRepository) PutAlertSettings(context context.Context, email string, settings model.AlertSettings) (err error) {
+	key := datastore.NewKey(context, "AlertSettings", ALERT_SETTINGS_KEY_NAME, 0, GetUserKey(context, email))
+	_, err = datastore.Put(context, key, &settings)
+	return err
+}
+
+func (r *DatastoreRepository) FindEmailsMonitoringMissingData(context context.Context) (emails []string, err error) {
+	keys, err := datastore.NewQuery("AlertSettings").Filter("monitorsMissingData =", true).KeysOnly().GetAll(context, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	emails = make([]string, len(keys))
+	for i, key := range keys {
+		emails[i] = key.Parent().StringID()
+	}
+
+	return emails, nil
+}
+
+func (r *DatastoreRepository) GetAlertEvent(context context.Context, email string, rule model.AlertRule, eventTime time.Time) (event *model.AlertEvent, err error) {
+	key := datastore.NewKey(context, "AlertEvent", alertEventKeyName(rule, eventTime), 0, GetUserKey(context, email))
+
+	event = new(model.AlertEvent)
+	if err = datastore.Get(context, key, event); err == datastore.ErrNoSuchEntity {
+		return nil, ErrNoSuchEntity
+	} else if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (r *DatastoreRepository) PutAlertEvent(context context.Context, email string, event model.AlertEvent) (err error) {
+	key := datastore.NewKey(context, "AlertEvent", alertEventKeyName(event.Rule, event.Time), 0, GetUserKey(context, email))
+	_, err = datastore.Put(context, key, &event)
+	return err
+}
+
+func (r *DatastoreRepository) ScanAlertEvents(context context.Context, email string, scanStart, scanEnd time.Time) (events []model.AlertEvent, err error) {
+	query := datastore.NewQuery("AlertEvent").Ancestor(GetUserKey(context, email)).Filter("time >=", scanStart).
+		Filter("time <=", scanEnd).Order("time")
+	if _, err = query.GetAll(context, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// DeleteUserData deletes the entities under the user key with a kindless ancestor query, whatever their kind
+func (r *DatastoreRepository) DeleteUserData(context context.Context, email string, limit int) (deleted int, err error) {
+	userKey := GetUserKey(context, email)
+
+	// The ancestor query also returns the user itself so we ask for one more
+	keys, err := datastore.NewQuery("").Ancestor(userKey).KeysOnly().Limit(limit+1).GetAll(context, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	descendantKeys := make([]*datastore.Key, 0, len(keys))
+	for _, key := range keys {
+		if !key.Equal(userKey) && len(descendantKeys) < limit {
+			descendantKeys = append(descendantKeys, key)
+		}
+	}
+
+	log.Infof(context, "Emitting a DeleteMulti with [%d] keys for user [%s]", len(descendantKeys), email)
+	if err = datastore.DeleteMulti(context, descendantKeys); err != nil {
+		return 0, err
+	}
+
+	return len(descendantKeys), nil
+}
+
+func (r *DatastoreRepository) DeleteUser(context context.Context, email string) (err error) {
+	return datastore.Delete(context, GetUserKey(context, email))
+}
+
+// getByName gets a root entity by its name, translating datastore.ErrNoSuchEntity to ErrNoSuchEntity
+func getByName(context context.Context, kind string, name string, dst interface{}) (err error) {
+	if err = datastore.Get(context, datastore.NewKey(context, kind, name, 0, nil), dst); err == datastore.ErrNoSuchEntity {
+		return ErrNoSuchEntity
+	}
+
+	return err
+}
+
+// putByName puts a root entity keyed by name
+func putByName(context context.Context, kind string, name string, src interface{}) (err error) {
+	_, err = datastore.Put(context, datastore.NewKey(context, kind, name, 0, nil), src)
+	return err
+}
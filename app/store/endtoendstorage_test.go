@@ -10,12 +10,12 @@ import (
 )
 
 func TestEndToEndMergeOfReadBatches(t *testing.T) {
-	c, key := setup(t)
-	defer c.Close()
+	c, email := setup(t)
+	defer store.SetRepository(store.NewDatastoreRepository())
 
-	w := store.NewDataStoreGlucoseReadBatchWriter(c, key)
-	bufferedWriter := bufio.NewGlucoseReadWriterSize(w, 5)
-	s := streaming.NewGlucoseStreamerDuration(bufferedWriter, apimodel.DAY_OF_DATA_DURATION)
+	w := store.NewDataStoreGlucoseReadBatchWriter(c, email)
+	bufferedWriter := bufio.NewWriterSize(apimodel.GLUCOSE_READ_KIND, w, 5)
+	s := streaming.NewStreamerDuration(bufferedWriter, apimodel.DAY_OF_DATA_DURATION)
 
 	// Write first chunk
 	r := make([]apimodel.GlucoseRead, 25)
@@ -24,7 +24,7 @@ func TestEndToEndMergeOfReadBatches(t *testing.T) {
 		readTime := firstChunkStart.Add(time.Duration(i) * time.Hour)
 		r[i] = apimodel.GlucoseRead{apimodel.Time{apimodel.GetTimeMillis(readTime), "America/Los_Angeles"}, apimodel.MG_PER_DL, float32(i)}
 	}
-	s, _ = s.WriteGlucoseReads(r)
+	s, _ = s.WriteAll(r)
 	s, _ = s.Flush()
 	s, _ = s.Close()
 
@@ -56,7 +56,7 @@ func TestEndToEndMergeOfReadBatches(t *testing.T) {
 		readTime := secondChunkStart.Add(time.Duration(i) * time.Hour)
 		r[i] = apimodel.GlucoseRead{apimodel.Time{apimodel.GetTimeMillis(readTime), "America/Los_Angeles"}, apimodel.MG_PER_DL, float32(i)}
 	}
-	s, _ = s.WriteGlucoseReads(r)
+	s, _ = s.WriteAll(r)
 	s, _ = s.Flush()
 	s, _ = s.Close()
 
@@ -77,12 +77,12 @@ func TestEndToEndMergeOfReadBatches(t *testing.T) {
 }
 
 func TestEndToEndMergeOfCalibrationBatches(t *testing.T) {
-	c, key := setup(t)
-	defer c.Close()
+	c, email := setup(t)
+	defer store.SetRepository(store.NewDatastoreRepository())
 
-	w := store.NewDataStoreCalibrationBatchWriter(c, key)
-	bufferedWriter := bufio.NewCalibrationWriterSize(w, 5)
-	s := streaming.NewCalibrationReadStreamerDuration(bufferedWriter, apimodel.DAY_OF_DATA_DURATION)
+	w := store.NewDataStoreBatchWriter(c, email, apimodel.CALIBRATION_READ_KIND)
+	bufferedWriter := bufio.NewWriterSize(apimodel.CALIBRATION_READ_KIND, w, 5)
+	s := streaming.NewStreamerDuration(bufferedWriter, apimodel.DAY_OF_DATA_DURATION)
 
 	// Write first chunk
 	r := make([]apimodel.CalibrationRead, 25)
@@ -91,7 +91,7 @@ func TestEndToEndMergeOfCalibrationBatches(t *testing.T) {
 		readTime := firstChunkStart.Add(time.Duration(i) * time.Hour)
 		r[i] = apimodel.CalibrationRead{apimodel.Time{apimodel.GetTimeMillis(readTime), "America/Los_Angeles"}, apimodel.MG_PER_DL, float32(i)}
 	}
-	s, _ = s.WriteCalibrations(r)
+	s, _ = s.WriteAll(r)
 	s, _ = s.Flush()
 	s, _ = s.Close()
 
@@ -123,7 +123,7 @@ func TestEndToEndMergeOfCalibrationBatches(t *testing.T) {
 		readTime := secondChunkStart.Add(time.Duration(i) * time.Hour)
 		r[i] = apimodel.CalibrationRead{apimodel.Time{apimodel.GetTimeMillis(readTime), "America/Los_Angeles"}, apimodel.MG_PER_DL, float32(i)}
 	}
-	s, _ = s.WriteCalibrations(r)
+	s, _ = s.WriteAll(r)
 	s, _ = s.Flush()
 	s, _ = s.Close()
 
@@ -144,12 +144,12 @@ func TestEndToEndMergeOfCalibrationBatches(t *testing.T) {
 }
 
 func TestEndToEndMergeOfInjectionBatches(t *testing.T) {
-	c, key := setup(t)
-	defer c.Close()
+	c, email := setup(t)
+	defer store.SetRepository(store.NewDatastoreRepository())
 
-	w := store.NewDataStoreInjectionBatchWriter(c, key)
-	bufferedWriter := bufio.NewInjectionWriterSize(w, 5)
-	s := streaming.NewInjectionStreamerDuration(bufferedWriter, apimodel.DAY_OF_DATA_DURATION)
+	w := store.NewDataStoreBatchWriter(c, email, apimodel.INJECTION_KIND)
+	bufferedWriter := bufio.NewWriterSize(apimodel.INJECTION_KIND, w, 5)
+	s := streaming.NewStreamerDuration(bufferedWriter, apimodel.DAY_OF_DATA_DURATION)
 
 	// Write first chunk
 	r := make([]apimodel.Injection, 25)
@@ -158,7 +158,7 @@ func TestEndToEndMergeOfInjectionBatches(t *testing.T) {
 		readTime := firstChunkStart.Add(time.Duration(i) * time.Hour)
 		r[i] = apimodel.Injection{apimodel.Time{apimodel.GetTimeMillis(readTime), "America/Montreal"}, float32(i), "Humalog", "Bolus"}
 	}
-	s, _ = s.WriteInjections(r)
+	s, _ = s.WriteAll(r)
 	s, _ = s.Flush()
 	s, _ = s.Close()
 
@@ -190,7 +190,7 @@ func TestEndToEndMergeOfInjectionBatches(t *testing.T) {
 		readTime := secondChunkStart.Add(time.Duration(i) * time.Hour)
 		r[i] = apimodel.Injection{apimodel.Time{apimodel.GetTimeMillis(readTime), "America/Montreal"}, float32(i), "Humalog", "Bolus"}
 	}
-	s, _ = s.WriteInjections(r)
+	s, _ = s.WriteAll(r)
 	s, _ = s.Flush()
 	s, _ = s.Close()
 
@@ -211,12 +211,12 @@ func TestEndToEndMergeOfInjectionBatches(t *testing.T) {
 }
 
 func TestEndToEndMergeOfExerciseBatches(t *testing.T) {
-	c, key := setup(t)
-	defer c.Close()
+	c, email := setup(t)
+	defer store.SetRepository(store.NewDatastoreRepository())
 
-	w := store.NewDataStoreExerciseBatchWriter(c, key)
-	bufferedWriter := bufio.NewExerciseWriterSize(w, 5)
-	s := streaming.NewExerciseStreamerDuration(bufferedWriter, apimodel.DAY_OF_DATA_DURATION)
+	w := store.NewDataStoreBatchWriter(c, email, apimodel.EXERCISE_KIND)
+	bufferedWriter := bufio.NewWriterSize(apimodel.EXERCISE_KIND, w, 5)
+	s := streaming.NewStreamerDuration(bufferedWriter, apimodel.DAY_OF_DATA_DURATION)
 
 	// Write first chunk
 	r := make([]apimodel.Exercise, 25)
@@ -225,7 +225,7 @@ func TestEndToEndMergeOfExerciseBatches(t *testing.T) {
 		readTime := firstChunkStart.Add(time.Duration(i) * time.Hour)
 		r[i] = apimodel.Exercise{apimodel.Time{apimodel.GetTimeMillis(readTime), "America/Montreal"}, i, "Light", "details"}
 	}
-	s, _ = s.WriteExercises(r)
+	s, _ = s.WriteAll(r)
 	s, _ = s.Flush()
 	s, _ = s.Close()
 
@@ -257,7 +257,7 @@ func TestEndToEndMergeOfExerciseBatches(t *testing.T) {
 		readTime := secondChunkStart.Add(time.Duration(i) * time.Hour)
 		r[i] = apimodel.Exercise{apimodel.Time{apimodel.GetTimeMillis(readTime), "America/Montreal"}, i, "Light", "details"}
 	}
-	s, _ = s.WriteExercises(r)
+	s, _ = s.WriteAll(r)
 	s, _ = s.Flush()
 	s, _ = s.Close()
 
@@ -278,12 +278,12 @@ func TestEndToEndMergeOfExerciseBatches(t *testing.T) {
 }
 
 func TestEndToEndMergeOfMealBatches(t *testing.T) {
-	c, key := setup(t)
-	defer c.Close()
+	c, email := setup(t)
+	defer store.SetRepository(store.NewDatastoreRepository())
 
-	w := store.NewDataStoreMealBatchWriter(c, key)
-	bufferedWriter := bufio.NewMealWriterSize(w, 5)
-	s := streaming.NewMealStreamerDuration(bufferedWriter, apimodel.DAY_OF_DATA_DURATION)
+	w := store.NewDataStoreBatchWriter(c, email, apimodel.MEAL_KIND)
+	bufferedWriter := bufio.NewWriterSize(apimodel.MEAL_KIND, w, 5)
+	s := streaming.NewStreamerDuration(bufferedWriter, apimodel.DAY_OF_DATA_DURATION)
 
 	// Write first chunk
 	r := make([]apimodel.Meal, 25)
@@ -292,7 +292,7 @@ func TestEndToEndMergeOfMealBatches(t *testing.T) {
 		readTime := firstChunkStart.Add(time.Duration(i) * time.Hour)
 		r[i] = apimodel.Meal{apimodel.Time{apimodel.GetTimeMillis(readTime), "America/Montreal"}, float32(i), float32(i + 1), float32(i + 2), float32(i + 3)}
 	}
-	s, _ = s.WriteMeals(r)
+	s, _ = s.WriteAll(r)
 	s, _ = s.Flush()
 	s, _ = s.Close()
 
@@ -324,7 +324,7 @@ func TestEndToEndMergeOfMealBatches(t *testing.T) {
 		readTime := secondChunkStart.Add(time.Duration(i) * time.Hour)
 		r[i] = apimodel.Meal{apimodel.Time{apimodel.GetTimeMillis(readTime), "America/Montreal"}, float32(i), float32(i + 1), float32(i + 2), float32(i + 3)}
 	}
-	s, _ = s.WriteMeals(r)
+	s, _ = s.WriteAll(r)
 	s, _ = s.Flush()
 	s, _ = s.Close()
 
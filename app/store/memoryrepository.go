@@ -0,0 +1,1120 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"github.com/alexandre-normand/glukit/app/model"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository is an embedded Repository that keeps everything in memory. When created with NewFileRepository,
+// every change is also written to a snapshot file which is loaded back on startup. Data is persisted by segment: one
+// file per user for the data of that user and one file for the data shared by all users (i.e. oauth, nightscout secrets
+// and account deletions) so that a change only rewrites the segment it affects.
+type MemoryRepository struct {
+	mutex sync.RWMutex
+	path  string
+	data  memorySnapshot
+}
+
+// Extension of the segment files of users
+const SEGMENT_FILE_EXTENSION = ".gob"
+
+// memorySnapshot holds all the data of a MemoryRepository. Days of data are gob encoded and kept by kind. They're keyed,
+// like scores and a1cs, by email and then by the unix time of their StartTime (or UpperBound for scores, a1cs and
+// therapy estimates, TakenOn for lab a1cs, InsertedOn for sensor sessions and MealTime for meal impacts).
+type memorySnapshot struct {
+	Users              map[string]model.GlukitUser
+	Days               map[string]map[string]map[int64][]byte
+	GlukitScores       map[string]map[int64]model.GlukitScore
+	A1CEstimates       map[string]map[int64]model.A1CEstimate
+	LabA1Cs            map[string]map[int64]model.LabA1C
+	SensorSessions     map[string]map[int64]model.SensorSession
+	TherapyEstimates   map[string]map[int64]model.TherapyEstimate
+	MealImpacts        map[string]map[int64]model.MealImpact
+	Insights           map[string]map[string]model.Insight
+	FileImportLogs     map[string]map[string]model.FileImportLog
+	OAuthClients       map[string]OAuthClient
+	OAuthAuthorizeData map[string]OAuthAuthorizeData
+	OAuthAccessData    map[string]OAuthAccessData
+	OAuthRefreshData   map[string]OAuthAccessData
+	NightscoutSecrets  map[string]NightscoutSecret
+	UploadedFiles      map[string]map[string][]UploadedFileChunk
+	AccountDeletions   map[string]AccountDeletion
+	AlertSettings      map[string]model.AlertSettings
+	AlertEvents        map[string]map[string]model.AlertEvent
+}
+
+// NewMemoryRepository returns a new empty Repository that only lives in memory
+func NewMemoryRepository() *MemoryRepository {
+	r := new(MemoryRepository)
+	r.data.init()
+	return r
+}
+
+// NewFileRepository returns a new Repository kept in memory and persisted to the file at path, for shared data, and to
+// files in the <path>.users directory, one per user. If they already exist, their content is loaded.
+func NewFileRepository(path string) (r *MemoryRepository, err error) {
+	r = NewMemoryRepository()
+	r.path = path
+
+	if err = loadSegment(path, &r.data); err != nil {
+		return nil, err
+	}
+	r.data.init()
+
+	files, err := ioutil.ReadDir(r.usersPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, file := range files {
+		if filepath.Ext(file.Name()) != SEGMENT_FILE_EXTENSION {
+			continue
+		}
+
+		var segment memorySnapshot
+		if err = loadSegment(filepath.Join(r.usersPath(), file.Name()), &segment); err != nil {
+			return nil, err
+		}
+		r.data.merge(segment)
+	}
+
+	return r, nil
+}
+
+// loadSegment decodes the segment file at path into s, if it exists
+func loadSegment(path string, s *memorySnapshot) (err error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return gob.NewDecoder(file).Decode(s)
+}
+
+func (s *memorySnapshot) init() {
+	if s.Users == nil {
+		s.Users = make(map[string]model.GlukitUser)
+	}
+	if s.Days == nil {
+		s.Days = make(map[string]map[string]map[int64][]byte)
+	}
+	if s.GlukitScores == nil {
+		s.GlukitScores = make(map[string]map[int64]model.GlukitScore)
+	}
+	if s.A1CEstimates == nil {
+		s.A1CEstimates = make(map[string]map[int64]model.A1CEstimate)
+	}
+	if s.LabA1Cs == nil {
+		s.LabA1Cs = make(map[string]map[int64]model.LabA1C)
+	}
+	if s.SensorSessions == nil {
+		s.SensorSessions = make(map[string]map[int64]model.SensorSession)
+	}
+	if s.TherapyEstimates == nil {
+		s.TherapyEstimates = make(map[string]map[int64]model.TherapyEstimate)
+	}
+	if s.MealImpacts == nil {
+		s.MealImpacts = make(map[string]map[int64]model.MealImpact)
+	}
+	if s.Insights == nil {
+		s.Insights = make(map[string]map[string]model.Insight)
+	}
+	if s.FileImportLogs == nil {
+		s.FileImportLogs = make(map[string]map[string]model.FileImportLog)
+	}
+	if s.OAuthClients == nil {
+		s.OAuthClients = make(map[string]OAuthClient)
+	}
+	if s.OAuthAuthorizeData == nil {
+		s.OAuthAuthorizeData = make(map[string]OAuthAuthorizeData)
+	}
+	if s.OAuthAccessData == nil {
+		s.OAuthAccessData = make(map[string]OAuthAccessData)
+	}
+	if s.OAuthRefreshData == nil {
+		s.OAuthRefreshData = make(map[string]OAuthAccessData)
+	}
+	if s.NightscoutSecrets == nil {
+		s.NightscoutSecrets = make(map[string]NightscoutSecret)
+	}
+	if s.UploadedFiles == nil {
+		s.UploadedFiles = make(map[string]map[string][]UploadedFileChunk)
+	}
+	if s.AccountDeletions == nil {
+		s.AccountDeletions = make(map[string]AccountDeletion)
+	}
+	if s.AlertSettings == nil {
+		s.AlertSettings = make(map[string]model.AlertSettings)
+	}
+	if s.AlertEvents == nil {
+		s.AlertEvents = make(map[string]map[string]model.AlertEvent)
+	}
+}
+
+// putDay keeps the gob encoding of a day of data
+func (s *memorySnapshot) putDay(kind string, email string, startTime int64, day interface{}) (err error) {
+	var encoded bytes.Buffer
+	if err = gob.NewEncoder(&encoded).Encode(day); err != nil {
+		return err
+	}
+
+	if s.Days[kind] == nil {
+		s.Days[kind] = make(map[string]map[int64][]byte)
+	}
+	if s.Days[kind][email] == nil {
+		s.Days[kind][email] = make(map[int64][]byte)
+	}
+	s.Days[kind][email][startTime] = encoded.Bytes()
+
+	return nil
+}
+
+// getDay decodes the day of data of a kind starting at startTime into dst, if there's one
+func (s *memorySnapshot) getDay(kind string, email string, startTime int64, dst reflect.Value) (found bool, err error) {
+	encoded, found := s.Days[kind][email][startTime]
+	if !found {
+		return false, nil
+	}
+
+	return true, gob.NewDecoder(bytes.NewReader(encoded)).DecodeValue(dst)
+}
+
+// userSegment returns a snapshot of the data of a single user. found is false if there's no data left for the user.
+func (s *memorySnapshot) userSegment(email string) (segment memorySnapshot, found bool) {
+	segment.init()
+	found = copyEntry(segment.Users, s.Users, email)
+	for kind, days := range s.Days {
+		if userDays, hasDays := days[email]; hasDays {
+			segment.Days[kind] = map[string]map[int64][]byte{email: userDays}
+			found = true
+		}
+	}
+	found = copyEntry(segment.GlukitScores, s.GlukitScores, email) || found
+	found = copyEntry(segment.A1CEstimates, s.A1CEstimates, email) || found
+	found = copyEntry(segment.LabA1Cs, s.LabA1Cs, email) || found
+	found = copyEntry(segment.SensorSessions, s.SensorSessions, email) || found
+	found = copyEntry(segment.TherapyEstimates, s.TherapyEstimates, email) || found
+	found = copyEntry(segment.MealImpacts, s.MealImpacts, email) || found
+	found = copyEntry(segment.Insights, s.Insights, email) || found
+	found = copyEntry(segment.FileImportLogs, s.FileImportLogs, email) || found
+	found = copyEntry(segment.UploadedFiles, s.UploadedFiles, email) || found
+	found = copyEntry(segment.AlertSettings, s.AlertSettings, email) || found
+	found = copyEntry(segment.AlertEvents, s.AlertEvents, email) || found
+
+	return segment, found
+}
+
+// sharedSegment returns a snapshot of the data that isn't kept by user
+func (s *memorySnapshot) sharedSegment() (segment memorySnapshot) {
+	segment.OAuthClients = s.OAuthClients
+	segment.OAuthAuthorizeData = s.OAuthAuthorizeData
+	segment.OAuthAccessData = s.OAuthAccessData
+	segment.OAuthRefreshData = s.OAuthRefreshData
+	segment.NightscoutSecrets = s.NightscoutSecrets
+	segment.AccountDeletions = s.AccountDeletions
+
+	return segment
+}
+
+// merge adds the data of a user segment
+func (s *memorySnapshot) merge(segment memorySnapshot) {
+	mergeEntries(s.Users, segment.Users)
+	for kind, days := range segment.Days {
+		if s.Days[kind] == nil {
+			s.Days[kind] = make(map[string]map[int64][]byte)
+		}
+		mergeEntries(s.Days[kind], days)
+	}
+	mergeEntries(s.GlukitScores, segment.GlukitScores)
+	mergeEntries(s.A1CEstimates, segment.A1CEstimates)
+	mergeEntries(s.LabA1Cs, segment.LabA1Cs)
+	mergeEntries(s.SensorSessions, segment.SensorSessions)
+	mergeEntries(s.TherapyEstimates, segment.TherapyEstimates)
+	mergeEntries(s.MealImpacts, segment.MealImpacts)
+	mergeEntries(s.Insights, segment.Insights)
+	mergeEntries(s.FileImportLogs, segment.FileImportLogs)
+	mergeEntries(s.UploadedFiles, segment.UploadedFiles)
+	mergeEntries(s.AlertSettings, segment.AlertSettings)
+	mergeEntries(s.AlertEvents, segment.AlertEvents)
+}
+
+func copyEntry[V any](dst, src map[string]V, email string) (found bool) {
+	value, found := src[email]
+	if found {
+		dst[email] = value
+	}
+
+	return found
+}
+
+func mergeEntries[V any](dst, src map[string]V) {
+	for key, value := range src {
+		dst[key] = value
+	}
+}
+
+// usersPath returns the directory holding the segment files of users
+func (r *MemoryRepository) usersPath() string {
+	return r.path + ".users"
+}
+
+// userSegmentPath returns the path of the segment file of a user
+func (r *MemoryRepository) userSegmentPath(email string) string {
+	return filepath.Join(r.usersPath(), url.PathEscape(email)+SEGMENT_FILE_EXTENSION)
+}
+
+// persistUser writes the segment of a user, if the repository is backed by files. The segment file is removed once
+// the user has no data left. It must be called with the write lock held.
+func (r *MemoryRepository) persistUser(email string) (err error) {
+	if r.path == "" {
+		return nil
+	}
+
+	segment, found := r.data.userSegment(email)
+	if !found {
+		if err = os.Remove(r.userSegmentPath(email)); os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if err = os.MkdirAll(r.usersPath(), 0700); err != nil {
+		return err
+	}
+
+	return writeSegment(r.userSegmentPath(email), segment)
+}
+
+// persistShared writes the segment of the data shared by all users, if the repository is backed by files. It must be
+// called with the write lock held.
+func (r *MemoryRepository) persistShared() (err error) {
+	if r.path == "" {
+		return nil
+	}
+
+	return writeSegment(r.path, r.data.sharedSegment())
+}
+
+// writeSegment writes a segment to path. The segment is first written to a temporary file that then replaces the
+// previous one so that a crash never leaves a partially written file behind.
+func writeSegment(path string, segment memorySnapshot) (err error) {
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if err = gob.NewEncoder(file).Encode(&segment); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// keysInRange returns the keys between start and end (both inclusive) in ascending order
+func keysInRange(keys []int64, start, end int64) (inRange []int64) {
+	inRange = make([]int64, 0)
+	for _, key := range keys {
+		if key >= start && key <= end {
+			inRange = append(inRange, key)
+		}
+	}
+	sort.Slice(inRange, func(i, j int) bool { return inRange[i] < inRange[j] })
+
+	return inRange
+}
+
+// scoreKeys returns the keys matching the scan query in descending order (i.e. most recent first), limited to the
+// query's limit
+func scoreKeys(keys []int64, scanQuery ScoreScanQuery) (matching []int64) {
+	start, end := int64(math.MinInt64), int64(math.MaxInt64)
+	if scanQuery.From != nil {
+		start = scanQuery.From.Unix()
+	}
+	if scanQuery.To != nil {
+		end = scanQuery.To.Unix()
+	}
+
+	matching = keysInRange(keys, start, end)
+	sort.Slice(matching, func(i, j int) bool { return matching[i] > matching[j] })
+	if scanQuery.Limit != nil && len(matching) > *scanQuery.Limit {
+		matching = matching[:*scanQuery.Limit]
+	}
+
+	return matching
+}
+
+func (r *MemoryRepository) GetUser(context context.Context, email string) (userProfile *model.GlukitUser, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	user, found := r.data.Users[email]
+	if !found {
+		return nil, ErrNoSuchEntity
+	}
+
+	return &user, nil
+}
+
+func (r *MemoryRepository) PutUser(context context.Context, userProfile model.GlukitUser) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.data.Users[userProfile.Email] = userProfile
+	return r.persistUser(userProfile.Email)
+}
+
+func (r *MemoryRepository) FindUsersByDiabetesType(context context.Context, diabetesType string, limit int) (users []model.GlukitUser, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	users = make([]model.GlukitUser, 0)
+	for _, user := range r.data.Users {
+		if user.DiabetesType == diabetesType {
+			users = append(users, user)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].MostRecentScore.Value < users[j].MostRecentScore.Value })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+
+	return users, nil
+}
+
+func (r *MemoryRepository) ScanUserEmails(context context.Context, after string, limit int) (emails []string, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	emails = make([]string, 0)
+	for email := range r.data.Users {
+		if email > after {
+			emails = append(emails, email)
+		}
+	}
+
+	sort.Strings(emails)
+	if len(emails) > limit {
+		emails = emails[:limit]
+	}
+
+	return emails, nil
+}
+
+func (r *MemoryRepository) GetDays(context context.Context, kind string, email string, startTimes []time.Time, days interface{}) (found []bool, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	dst := reflect.ValueOf(days)
+	found = make([]bool, len(startTimes))
+	for i := range startTimes {
+		if found[i], err = r.data.getDay(kind, email, startTimes[i].Unix(), dst.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+
+	return found, nil
+}
+
+func (r *MemoryRepository) ScanDays(context context.Context, kind string, email string, scanStart, scanEnd time.Time, days interface{}) (err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	userDays := r.data.Days[kind][email]
+	keys := make([]int64, 0, len(userDays))
+	for key := range userDays {
+		keys = append(keys, key)
+	}
+
+	dst := reflect.ValueOf(days).Elem()
+	dst.Set(reflect.MakeSlice(dst.Type(), 0, len(keys)))
+	for _, key := range keysInRange(keys, scanStart.Unix(), scanEnd.Unix()) {
+		day := reflect.New(dst.Type().Elem()).Elem()
+		if _, err = r.data.getDay(kind, email, key, day); err != nil {
+			return err
+		}
+		dst.Set(reflect.Append(dst, day))
+	}
+
+	return nil
+}
+
+func (r *MemoryRepository) PutDays(context context.Context, kind string, email string, startTimes []time.Time, days interface{}) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	src := reflect.ValueOf(days)
+	for i := range startTimes {
+		if err = r.data.putDay(kind, email, startTimes[i].Unix(), src.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+
+	return r.persistUser(email)
+}
+
+func (r *MemoryRepository) PutGlukitScores(context context.Context, email string, scores []model.GlukitScore) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.data.GlukitScores[email] == nil {
+		r.data.GlukitScores[email] = make(map[int64]model.GlukitScore)
+	}
+	for _, score := range scores {
+		r.data.GlukitScores[email][score.UpperBound.Unix()] = score
+	}
+
+	return r.persistUser(email)
+}
+
+func (r *MemoryRepository) ScanGlukitScores(context context.Context, email string, scanQuery ScoreScanQuery) (scores []model.GlukitScore, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	userScores := r.data.GlukitScores[email]
+	keys := make([]int64, 0, len(userScores))
+	for key := range userScores {
+		keys = append(keys, key)
+	}
+
+	scores = make([]model.GlukitScore, 0)
+	for _, key := range scoreKeys(keys, scanQuery) {
+		scores = append(scores, userScores[key])
+	}
+
+	return scores, nil
+}
+
+func (r *MemoryRepository) FindGlukitScoresBelowVersion(context context.Context, email string, scoringVersion int, after time.Time, limit int) (scores []model.GlukitScore, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	userScores := r.data.GlukitScores[email]
+	keys := make([]int64, 0, len(userScores))
+	for key, score := range userScores {
+		if score.ScoringVersion < scoringVersion && score.UpperBound.After(after) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	scores = make([]model.GlukitScore, 0, len(keys))
+	for _, key := range keys {
+		scores = append(scores, userScores[key])
+	}
+
+	return scores, nil
+}
+
+func (r *MemoryRepository) PutA1CEstimates(context context.Context, email string, a1cs []model.A1CEstimate) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.data.A1CEstimates[email] == nil {
+		r.data.A1CEstimates[email] = make(map[int64]model.A1CEstimate)
+	}
+	for _, a1c := range a1cs {
+		r.data.A1CEstimates[email][a1c.UpperBound.Unix()] = a1c
+	}
+
+	return r.persistUser(email)
+}
+
+func (r *MemoryRepository) ScanA1CEstimates(context context.Context, email string, scanQuery ScoreScanQuery) (a1cs []model.A1CEstimate, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	userA1Cs := r.data.A1CEstimates[email]
+	keys := make([]int64, 0, len(userA1Cs))
+	for key := range userA1Cs {
+		keys = append(keys, key)
+	}
+
+	a1cs = make([]model.A1CEstimate, 0)
+	for _, key := range scoreKeys(keys, scanQuery) {
+		a1cs = append(a1cs, userA1Cs[key])
+	}
+
+	return a1cs, nil
+}
+
+func (r *MemoryRepository) PutLabA1C(context context.Context, email string, lab model.LabA1C) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.data.LabA1Cs[email] == nil {
+		r.data.LabA1Cs[email] = make(map[int64]model.LabA1C)
+	}
+	r.data.LabA1Cs[email][lab.TakenOn.Unix()] = lab
+
+	return r.persistUser(email)
+}
+
+func (r *MemoryRepository) ScanLabA1Cs(context context.Context, email string, scanQuery ScoreScanQuery) (labs []model.LabA1C, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	userLabs := r.data.LabA1Cs[email]
+	keys := make([]int64, 0, len(userLabs))
+	for key := range userLabs {
+		keys = append(keys, key)
+	}
+
+	labs = make([]model.LabA1C, 0)
+	for _, key := range scoreKeys(keys, scanQuery) {
+		labs = append(labs, userLabs[key])
+	}
+
+	return labs, nil
+}
+
+func (r *MemoryRepository) PutSensorSessions(context context.Context, email string, sessions []model.SensorSession) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.data.SensorSessions[email] == nil {
+		r.data.SensorSessions[email] = make(map[int64]model.SensorSession)
+	}
+	for _, session := range sessions {
+		r.data.SensorSessions[email][session.InsertedOn.Unix()] = session
+	}
+
+	return r.persistUser(email)
+}
+
+func (r *MemoryRepository) ScanSensorSessions(context context.Context, email string, scanQuery ScoreScanQuery) (sessions []model.SensorSession, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	userSessions := r.data.SensorSessions[email]
+	keys := make([]int64, 0, len(userSessions))
+	for key := range userSessions {
+		keys = append(keys, key)
+	}
+
+	sessions = make([]model.SensorSession, 0)
+	for _, key := range scoreKeys(keys, scanQuery) {
+		sessions = append(sessions, userSessions[key])
+	}
+
+	return sessions, nil
+}
+
+func (r *MemoryRepository) PutTherapyEstimate(context context.Context, email string, estimate model.TherapyEstimate) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.data.TherapyEstimates[email] == nil {
+		r.data.TherapyEstimates[email] = make(map[int64]model.TherapyEstimate)
+	}
+	r.data.TherapyEstimates[email][estimate.UpperBound.Unix()] = estimate
+
+	return r.persistUser(email)
+}
+
+func (r *MemoryRepository) ScanTherapyEstimates(context context.Context, email string, scanQuery ScoreScanQuery) (estimates []model.TherapyEstimate, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	userEstimates := r.data.TherapyEstimates[email]
+	keys := make([]int64, 0, len(userEstimates))
+	for key := range userEstimates {
+		keys = append(keys, key)
+	}
+
+	estimates = make([]model.TherapyEstimate, 0)
+	for _, key := range scoreKeys(keys, scanQuery) {
+		estimates = append(estimates, userEstimates[key])
+	}
+
+	return estimates, nil
+}
+
+func (r *MemoryRepository) PutMealImpacts(context context.Context, email string, impacts []model.MealImpact) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.data.MealImpacts[email] == nil {
+		r.data.MealImpacts[email] = make(map[int64]model.MealImpact)
+	}
+	for _, impact := range impacts {
+		r.data.MealImpacts[email][impact.MealTime.Unix()] = impact
+	}
+
+	return r.persistUser(email)
+}
+
+func (r *MemoryRepository) ScanMealImpacts(context context.Context, email string, scanQuery ScoreScanQuery) (impacts []model.MealImpact, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	userImpacts := r.data.MealImpacts[email]
+	keys := make([]int64, 0, len(userImpacts))
+	for key := range userImpacts {
+		keys = append(keys, key)
+	}
+
+	impacts = make([]model.MealImpact, 0)
+	for _, key := range scoreKeys(keys, scanQuery) {
+		impacts = append(impacts, userImpacts[key])
+	}
+
+	return impacts, nil
+}
+
+func (r *MemoryRepository) PutInsights(context context.Context, email string, insights []model.Insight) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.data.Insights[email] == nil {
+		r.data.Insights[email] = make(map[string]model.Insight)
+	}
+	for _, insight := range insights {
+		r.data.Insights[email][insightKeyName(insight.Type, insight.UpperBound)] = insight
+	}
+
+	return r.persistUser(email)
+}
+
+// ScanInsights orders insights of the same period by type like the datastore does by key
+func (r *MemoryRepository) ScanInsights(context context.Context, email string, scanQuery ScoreScanQuery) (insights []model.Insight, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	insights = make([]model.Insight, 0)
+	for _, insight := range r.data.Insights[email] {
+		if scanQuery.From != nil && insight.UpperBound.Before(*scanQuery.From) {
+			continue
+		}
+		if scanQuery.To != nil && insight.UpperBound.After(*scanQuery.To) {
+			continue
+		}
+		insights = append(insights, insight)
+	}
+
+	sort.Slice(insights, func(i, j int) bool {
+		if !insights[i].UpperBound.Equal(insights[j].UpperBound) {
+			return insights[i].UpperBound.After(insights[j].UpperBound)
+		}
+		return insights[i].Type < insights[j].Type
+	})
+	if scanQuery.Limit != nil && len(insights) > *scanQuery.Limit {
+		insights = insights[:*scanQuery.Limit]
+	}
+
+	return insights, nil
+}
+
+func (r *MemoryRepository) PutFileImportLog(context context.Context, email string, fileImport model.FileImportLog) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.data.FileImportLogs[email] == nil {
+		r.data.FileImportLogs[email] = make(map[string]model.FileImportLog)
+	}
+	r.data.FileImportLogs[email][fileImport.Id] = fileImport
+
+	return r.persistUser(email)
+}
+
+func (r *MemoryRepository) GetFileImportLog(context context.Context, email string, fileId string) (fileImport *model.FileImportLog, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	existing, found := r.data.FileImportLogs[email][fileId]
+	if !found {
+		return nil, ErrNoSuchEntity
+	}
+
+	return &existing, nil
+}
+
+func (r *MemoryRepository) PutUploadedFileChunks(context context.Context, email string, chunks []UploadedFileChunk) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.data.UploadedFiles[email] == nil {
+		r.data.UploadedFiles[email] = make(map[string][]UploadedFileChunk)
+	}
+
+	for _, chunk := range chunks {
+		existing := r.data.UploadedFiles[email][chunk.FileId]
+		replaced := false
+		for i := range existing {
+			if existing[i].Index == chunk.Index {
+				existing[i] = chunk
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			r.data.UploadedFiles[email][chunk.FileId] = append(existing, chunk)
+		}
+	}
+
+	return r.persistUser(email)
+}
+
+func (r *MemoryRepository) GetUploadedFileChunks(context context.Context, email string, fileId string) (chunks []UploadedFileChunk, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	chunks = append([]UploadedFileChunk(nil), r.data.UploadedFiles[email][fileId]...)
+	sort.Sort(uploadedFileChunksByIndex(chunks))
+
+	return chunks, nil
+}
+
+func (r *MemoryRepository) DeleteUploadedFileChunks(context context.Context, email string, fileId string) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.data.UploadedFiles[email], fileId)
+	return r.persistUser(email)
+}
+
+func (r *MemoryRepository) GetOAuthClient(context context.Context, id string) (client *OAuthClient, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	existing, found := r.data.OAuthClients[id]
+	if !found {
+		return nil, ErrNoSuchEntity
+	}
+
+	return &existing, nil
+}
+
+func (r *MemoryRepository) PutOAuthClient(context context.Context, client OAuthClient) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.data.OAuthClients[client.Id] = client
+	return r.persistShared()
+}
+
+func (r *MemoryRepository) GetOAuthAuthorizeData(context context.Context, code string) (data *OAuthAuthorizeData, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	existing, found := r.data.OAuthAuthorizeData[code]
+	if !found {
+		return nil, ErrNoSuchEntity
+	}
+
+	return &existing, nil
+}
+
+func (r *MemoryRepository) PutOAuthAuthorizeData(context context.Context, data OAuthAuthorizeData) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.data.OAuthAuthorizeData[data.Code] = data
+	return r.persistShared()
+}
+
+func (r *MemoryRepository) DeleteOAuthAuthorizeData(context context.Context, code string) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.data.OAuthAuthorizeData, code)
+	return r.persistShared()
+}
+
+func (r *MemoryRepository) GetOAuthAccessData(context context.Context, token string) (data *OAuthAccessData, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	existing, found := r.data.OAuthAccessData[token]
+	if !found {
+		return nil, ErrNoSuchEntity
+	}
+
+	return &existing, nil
+}
+
+func (r *MemoryRepository) PutOAuthAccessData(context context.Context, data OAuthAccessData) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.data.OAuthAccessData[data.AccessToken] = data
+	return r.persistShared()
+}
+
+func (r *MemoryRepository) DeleteOAuthAccessData(context context.Context, token string) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.data.OAuthAccessData, token)
+	return r.persistShared()
+}
+
+func (r *MemoryRepository) GetOAuthRefreshData(context context.Context, token string) (data *OAuthAccessData, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	existing, found := r.data.OAuthRefreshData[token]
+	if !found {
+		return nil, ErrNoSuchEntity
+	}
+
+	return &existing, nil
+}
+
+func (r *MemoryRepository) PutOAuthRefreshData(context context.Context, data OAuthAccessData) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.data.OAuthRefreshData[data.RefreshToken] = data
+	return r.persistShared()
+}
+
+func (r *MemoryRepository) DeleteOAuthRefreshData(context context.Context, token string) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.data.OAuthRefreshData, token)
+	return r.persistShared()
+}
+
+func (r *MemoryRepository) FindOAuthAccessData(context context.Context, email string) (data []OAuthAccessData, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, accessData := range r.data.OAuthAccessData {
+		if accessData.UserData == email {
+			data = append(data, accessData)
+		}
+	}
+
+	return data, nil
+}
+
+func (r *MemoryRepository) FindOAuthRefreshData(context context.Context, email string) (data []OAuthAccessData, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, refreshData := range r.data.OAuthRefreshData {
+		if refreshData.UserData == email {
+			data = append(data, refreshData)
+		}
+	}
+
+	return data, nil
+}
+
+func (r *MemoryRepository) GetNightscoutSecret(context context.Context, secretHash string) (secret *NightscoutSecret, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	existing, found := r.data.NightscoutSecrets[secretHash]
+	if !found {
+		return nil, ErrNoSuchEntity
+	}
+
+	return &existing, nil
+}
+
+func (r *MemoryRepository) FindNightscoutSecrets(context context.Context, email string) (secrets []NightscoutSecret, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, secret := range r.data.NightscoutSecrets {
+		if secret.Email == email {
+			secrets = append(secrets, secret)
+		}
+	}
+
+	return secrets, nil
+}
+
+func (r *MemoryRepository) PutNightscoutSecret(context context.Context, secret NightscoutSecret) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.data.NightscoutSecrets[secret.SecretHash] = secret
+	return r.persistShared()
+}
+
+func (r *MemoryRepository) DeleteNightscoutSecret(context context.Context, secretHash string) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.data.NightscoutSecrets, secretHash)
+	return r.persistShared()
+}
+
+func (r *MemoryRepository) GetAccountDeletion(context context.Context, emailHash string) (deletion *AccountDeletion, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	existing, found := r.data.AccountDeletions[emailHash]
+	if !found {
+		return nil, ErrNoSuchEntity
+	}
+
+	return &existing, nil
+}
+
+func (r *MemoryRepository) PutAccountDeletion(context context.Context, deletion AccountDeletion) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.data.AccountDeletions[deletion.EmailHash] = deletion
+	return r.persistShared()
+}
+
+func (r *MemoryRepository) FindAccountDeletions(context context.Context, status string) (deletions []AccountDeletion, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, deletion := range r.data.AccountDeletions {
+		if deletion.Status == status {
+			deletions = append(deletions, deletion)
+		}
+	}
+
+	return deletions, nil
+}
+
+// DeleteUserData deletes all of the user's data at once, regardless of limit, since there's no limit on the size of
+// a batch in memory
+func (r *MemoryRepository) DeleteUserData(context context.Context, email string, limit int) (deleted int, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	deleted = len(r.data.GlukitScores[email]) + len(r.data.A1CEstimates[email]) + len(r.data.LabA1Cs[email]) +
+		len(r.data.SensorSessions[email]) + len(r.data.TherapyEstimates[email]) + len(r.data.MealImpacts[email]) +
+		len(r.data.Insights[email]) + len(r.data.FileImportLogs[email]) + len(r.data.AlertEvents[email])
+	for _, days := range r.data.Days {
+		deleted += len(days[email])
+	}
+	for _, chunks := range r.data.UploadedFiles[email] {
+		deleted += len(chunks)
+	}
+	if _, found := r.data.AlertSettings[email]; found {
+		deleted++
+	}
+
+	for _, days := range r.data.Days {
+		delete(days, email)
+	}
+	delete(r.data.GlukitScores, email)
+	delete(r.data.A1CEstimates, email)
+	delete(r.data.LabA1Cs, email)
+	delete(r.data.SensorSessions, email)
+	delete(r.data.TherapyEstimates, email)
+	delete(r.data.MealImpacts, email)
+	delete(r.data.Insights, email)
+	delete(r.data.FileImportLogs, email)
+	delete(r.data.UploadedFiles, email)
+	delete(r.data.AlertSettings, email)
+	delete(r.data.AlertEvents, email)
+
+	return deleted, r.persistUser(email)
+}
+
+func (r *MemoryRepository) GetAlertSettings(context context.Context, email string) (settings *model.AlertSettings, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	existing, found := r.data.AlertSettings[email]
+	if !found {
+		return nil, ErrNoSuchEntity
+	}
+
+	return &existing, nil
+}
+
+func (r *MemoryRepository) PutAlertSettings(context context.Context, email string, settings model.AlertSettings) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.data.AlertSettings[email] = settings
+	return r.persistUser(email)
+}
+
+func (r *MemoryRepository) FindEmailsMonitoringMissingData(context context.Context) (emails []string, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	emails = make([]string, 0)
+	for email, settings := range r.data.AlertSettings {
+		if settings.MonitorsMissingData {
+			emails = append(emails, email)
+		}
+	}
+	sort.Strings(emails)
+
+	return emails, nil
+}
+
+func (r *MemoryRepository) GetAlertEvent(context context.Context, email string, rule model.AlertRule, eventTime time.Time) (event *model.AlertEvent, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	existing, found := r.data.AlertEvents[email][alertEventKeyName(rule, eventTime)]
+	if !found {
+		return nil, ErrNoSuchEntity
+	}
+
+	return &existing, nil
+}
+
+func (r *MemoryRepository) PutAlertEvent(context context.Context, email string, event model.AlertEvent) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.data.AlertEvents[email] == nil {
+		r.data.AlertEvents[email] = make(map[string]model.AlertEvent)
+	}
+	r.data.AlertEvents[email][alertEventKeyName(event.Rule, event.Time)] = event
+
+	return r.persistUser(email)
+}
+
+func (r *MemoryRepository) ScanAlertEvents(context context.Context, email string, scanStart, scanEnd time.Time) (events []model.AlertEvent, err error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	events = make([]model.AlertEvent, 0)
+	for _, event := range r.data.AlertEvents[email] {
+		if !event.Time.Before(scanStart) && !event.Time.After(scanEnd) {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+
+	return events, nil
+}
+
+func (r *MemoryRepository) DeleteUser(context context.Context, email string) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.data.Users, email)
+	return r.persistUser(email)
+}
@@ -0,0 +1,465 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/bufio"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/streaming"
+	"github.com/alexandre-normand/glukit/app/util"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func setupMemoryRepository(t *testing.T, r store.Repository) (c context.Context) {
+	store.SetRepository(r)
+	c = context.Background()
+
+	user := model.GlukitUser{TEST_USER, "", "", time.Now(),
+		model.DIABETES_TYPE_1, "", util.GLUKIT_EPOCH_TIME, apimodel.UNDEFINED_GLUCOSE_READ,
+		model.UNDEFINED_SCORE, model.UNDEFINED_SCORE, false, "", time.Now(), model.UNDEFINED_A1C_ESTIMATE, model.DEFAULT_GLUCOSE_TARGETS, model.A1C_FORMULA_ADAG, 0}
+
+	if err := store.StoreUserProfile(c, time.Unix(1000, 0), user); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func writeHourlyReads(t *testing.T, c context.Context, start time.Time, count int) {
+	w := store.NewDataStoreGlucoseReadBatchWriter(c, TEST_USER)
+	bufferedWriter := bufio.NewWriterSize(apimodel.GLUCOSE_READ_KIND, w, 5)
+	s := streaming.NewStreamerDuration(bufferedWriter, apimodel.DAY_OF_DATA_DURATION)
+
+	r := make([]apimodel.GlucoseRead, count)
+	for i := 0; i < count; i++ {
+		readTime := start.Add(time.Duration(i) * time.Hour)
+		r[i] = apimodel.GlucoseRead{apimodel.Time{apimodel.GetTimeMillis(readTime), "America/Los_Angeles"}, apimodel.MG_PER_DL, float32(i)}
+	}
+
+	s, err := s.WriteAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryRepositoryMergeOfReadBatches(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c := setupMemoryRepository(t, store.NewMemoryRepository())
+
+	firstChunkStart, _ := time.Parse("02/01/2006 15:04", "18/04/2015 01:00")
+	writeHourlyReads(t, c, firstChunkStart, 25)
+
+	secondChunkStart, _ := time.Parse("02/01/2006 15:04", "18/04/2015 05:00")
+	writeHourlyReads(t, c, secondChunkStart, 25)
+
+	lowerBound, _ := time.Parse("02/01/2006 15:04", "18/04/2015 00:00")
+	upperBound, _ := time.Parse("02/01/2006 15:04", "20/04/2015 02:00")
+	reads, err := store.GetGlucoseReads(c, TEST_USER, lowerBound, upperBound)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reads[0].GetTime().Unix() != firstChunkStart.Unix() {
+		t.Errorf("First reads of batch doesn't match expected time. Expected [%v], got [%v]", firstChunkStart, reads[0].GetTime())
+	}
+
+	if len(reads) != 29 {
+		t.Errorf("Expected [29] reads but got [%d]", len(reads))
+	}
+
+	glukitUser, err := store.GetGlukitUser(c, TEST_USER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expectedLastRead := secondChunkStart.Add(24 * time.Hour); glukitUser.MostRecentRead.GetTime().Unix() != expectedLastRead.Unix() {
+		t.Errorf("Expected most recent read at [%v] but got [%v]", expectedLastRead, glukitUser.MostRecentRead.GetTime())
+	}
+}
+
+func TestMemoryRepositoryMergeOfBasalRateBatches(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c := setupMemoryRepository(t, store.NewMemoryRepository())
+
+	// Two overlapping imports of a basal rate every 3 hours, the second one changing the overlapping rates
+	start, _ := time.Parse("02/01/2006 15:04", "18/04/2015 00:00")
+	for chunk, unitsPerHour := range []float32{1, 1.5} {
+		w := store.NewDataStoreBatchWriter(c, TEST_USER, apimodel.BASAL_RATE_KIND)
+		s := streaming.NewStreamerDuration(bufio.NewWriterSize(apimodel.BASAL_RATE_KIND, w, 5), apimodel.DAY_OF_DATA_DURATION)
+
+		basalRates := make([]apimodel.BasalRate, 16)
+		for i := range basalRates {
+			rateTime := start.Add(time.Duration(chunk*24+i*3) * time.Hour)
+			basalRates[i] = apimodel.BasalRate{apimodel.Time{apimodel.GetTimeMillis(rateTime), "UTC"}, unitsPerHour, "Standard"}
+		}
+
+		s, err := s.WriteAll(basalRates)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s, err = s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	basalRates, err := store.GetBasalRates(c, TEST_USER, start, start.Add(72*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(basalRates) != 24 {
+		t.Fatalf("Expected [24] basal rates but got [%d]", len(basalRates))
+	}
+
+	if basalRates[7].UnitsPerHour != 1 || basalRates[8].UnitsPerHour != 1.5 {
+		t.Errorf("Expected the rates of the second import to replace the overlapping ones but got [%v]", basalRates)
+	}
+}
+
+func TestMemoryRepositoryKetonesAreReadBackAndFlaggedWhenHigh(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c := setupMemoryRepository(t, store.NewMemoryRepository())
+
+	// A ketone every 4 hours, rising by 0.5 mmol/L, one of them logged in mg/dL
+	start, _ := time.Parse("02/01/2006 15:04", "18/04/2015 00:00")
+	ketones := make([]apimodel.Ketone, 4)
+	for i := range ketones {
+		ketoneTime := start.Add(time.Duration(i*4) * time.Hour)
+		ketones[i] = apimodel.Ketone{apimodel.Time{apimodel.GetTimeMillis(ketoneTime), "UTC"}, apimodel.KETONE_MMOL_PER_L, 0.5 * float32(i)}
+	}
+	ketones[3] = apimodel.Ketone{ketones[3].Time, apimodel.KETONE_MG_PER_DL, 1.5 * apimodel.KETONE_MG_PER_DL_PER_MMOL_PER_L}
+
+	w := store.NewDataStoreBatchWriter(c, TEST_USER, apimodel.KETONE_KIND)
+	s := streaming.NewStreamerDuration(bufio.NewWriterSize(apimodel.KETONE_KIND, w, 5), apimodel.DAY_OF_DATA_DURATION)
+	s, err := s.WriteAll(ketones)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := store.GetKetones(c, TEST_USER, start, start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dataPoints := apimodel.KetoneSlice(stored).ToDataPointSlice()
+	if len(dataPoints) != 4 {
+		t.Fatalf("Expected [4] ketones but got [%v]", dataPoints)
+	}
+
+	for i, expectedTag := range []string{apimodel.KETONE_TAG, apimodel.KETONE_TAG, apimodel.KETONE_TAG, apimodel.HIGH_KETONE_TAG} {
+		if dataPoints[i].Tag != expectedTag || dataPoints[i].Unit != apimodel.MMOL_PER_L {
+			t.Errorf("Expected ketone [%d] to be tagged [%s] in [%s] but got [%v]", i, expectedTag, apimodel.MMOL_PER_L, dataPoints[i])
+		}
+	}
+
+	if dataPoints[3].Value < 1.49 || dataPoints[3].Value > 1.51 {
+		t.Errorf("Expected the ketone logged in mg/dL to be converted to [1.5] mmol/L but got [%v]", dataPoints[3].Value)
+	}
+}
+
+func TestMemoryRepositoryGetUnknownUser(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c := setupMemoryRepository(t, store.NewMemoryRepository())
+
+	if _, err := store.GetGlukitUser(c, "nobody@glukit.com"); err != store.ErrNoSuchEntity {
+		t.Errorf("Expected [%v] for unknown user but got [%v]", store.ErrNoSuchEntity, err)
+	}
+}
+
+func TestMemoryRepositoryScoreScanOrderAndLimit(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c := setupMemoryRepository(t, store.NewMemoryRepository())
+
+	start, _ := time.Parse("02/01/2006 15:04", "18/04/2015 00:00")
+	scores := make([]model.GlukitScore, 15)
+	for i := range scores {
+		upperBound := start.Add(time.Duration(i*24) * time.Hour)
+		scores[i] = model.GlukitScore{Value: int64(i), LowerBound: upperBound.Add(-7 * 24 * time.Hour), UpperBound: upperBound, CalculatedOn: time.Now(), ScoringVersion: 1}
+	}
+
+	if err := store.StoreGlukitScoreBatch(c, TEST_USER, scores); err != nil {
+		t.Fatal(err)
+	}
+
+	limit := 3
+	to := start.Add(10 * 24 * time.Hour)
+	result, err := store.GetGlukitScores(c, TEST_USER, store.ScoreScanQuery{Limit: &limit, To: &to})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 3 {
+		t.Fatalf("Expected [3] scores but got [%d]", len(result))
+	}
+
+	for i, expected := range []int64{10, 9, 8} {
+		if result[i].Value != expected {
+			t.Errorf("Expected score [%d] at index [%d] but got [%d]", expected, i, result[i].Value)
+		}
+	}
+}
+
+func TestFileRepositoryReload(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+
+	dir, err := ioutil.TempDir("", "glukit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "glukit.db")
+
+	r, err := store.NewFileRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := setupMemoryRepository(t, r)
+
+	start, _ := time.Parse("02/01/2006 15:04", "18/04/2015 01:00")
+	writeHourlyReads(t, c, start, 25)
+
+	reloaded, err := store.NewFileRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetRepository(reloaded)
+
+	reads, err := store.GetGlucoseReads(c, TEST_USER, start, start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reads) != 25 {
+		t.Errorf("Expected [25] reads after reload but got [%d]", len(reads))
+	}
+
+	if _, err := store.GetGlukitUser(c, TEST_USER); err != nil {
+		t.Errorf("Expected user [%s] after reload but got error [%v]", TEST_USER, err)
+	}
+}
+
+func TestFileRepositoryKeepsASegmentPerUser(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+
+	dir, err := ioutil.TempDir("", "glukit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "glukit.db")
+
+	r, err := store.NewFileRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := setupMemoryRepository(t, r)
+
+	start, _ := time.Parse("02/01/2006 15:04", "18/04/2015 01:00")
+	writeHourlyReads(t, c, start, 25)
+
+	otherUser := model.GlukitUser{Email: "other@glukit.com"}
+	if err := store.StoreUserProfile(c, time.Unix(1000, 0), otherUser); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StoreNightscoutSecret(c, TEST_USER, "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	segments, err := filepath.Glob(filepath.Join(path+".users", "*"+store.SEGMENT_FILE_EXTENSION))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) != 2 {
+		t.Errorf("Expected a segment for each of the [2] users but got [%v]", segments)
+	}
+
+	if err := r.DeleteUser(c, otherUser.Email); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := store.NewFileRepository(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetRepository(reloaded)
+
+	if reads, err := store.GetGlucoseReads(c, TEST_USER, start, start.Add(24*time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if len(reads) != 25 {
+		t.Errorf("Expected [25] reads after reload but got [%d]", len(reads))
+	}
+
+	if email, err := store.GetNightscoutSecretUser(c, "secret"); err != nil || email != TEST_USER {
+		t.Errorf("Expected secret of user [%s] after reload but got [%s] with error [%v]", TEST_USER, email, err)
+	}
+
+	if _, err := store.GetGlukitUser(c, otherUser.Email); err != store.ErrNoSuchEntity {
+		t.Errorf("Expected [%v] for deleted user [%s] but got [%v]", store.ErrNoSuchEntity, otherUser.Email, err)
+	}
+}
+
+func TestNightscoutSecretReplacesPreviousSecret(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c := setupMemoryRepository(t, store.NewMemoryRepository())
+
+	if err := store.StoreNightscoutSecret(c, TEST_USER, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StoreNightscoutSecret(c, TEST_USER, "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.GetNightscoutSecretUser(c, "first"); err != store.ErrNoSuchEntity {
+		t.Errorf("Expected [%v] for a replaced secret but got [%v]", store.ErrNoSuchEntity, err)
+	}
+
+	email, err := store.GetNightscoutSecretUser(c, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email != TEST_USER {
+		t.Errorf("Expected secret of user [%s] but got [%s]", TEST_USER, email)
+	}
+}
+
+func TestUploadedFileIsSplitInChunks(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c := setupMemoryRepository(t, store.NewMemoryRepository())
+
+	content := bytes.Repeat([]byte("0123456789"), store.UPLOADED_FILE_CHUNK_SIZE/4)
+	if err := store.StoreUploadedFile(c, TEST_USER, "export.xml", []byte("previous upload")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StoreUploadedFile(c, TEST_USER, "export.xml", content); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, err := store.GetRepository().GetUploadedFileChunks(c, TEST_USER, "export.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 {
+		t.Errorf("Expected [3] chunks but got [%d]", len(chunks))
+	}
+
+	storedContent, err := store.GetUploadedFile(c, TEST_USER, "export.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(storedContent, content) {
+		t.Errorf("Expected stored content of [%d] bytes to match the upload of [%d] bytes", len(storedContent), len(content))
+	}
+
+	if err = store.DeleteUploadedFile(c, TEST_USER, "export.xml"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.GetUploadedFile(c, TEST_USER, "export.xml"); err != store.ErrNoSuchEntity {
+		t.Errorf("Expected [%v] for a deleted upload but got [%v]", store.ErrNoSuchEntity, err)
+	}
+}
+
+func TestAccountPurge(t *testing.T) {
+	defer store.SetRepository(store.NewDatastoreRepository())
+	c := setupMemoryRepository(t, store.NewMemoryRepository())
+
+	const otherUser = "other@glukit.com"
+	other := model.GlukitUser{otherUser, "", "", time.Now(),
+		model.DIABETES_TYPE_1, "", util.GLUKIT_EPOCH_TIME, apimodel.UNDEFINED_GLUCOSE_READ,
+		model.UNDEFINED_SCORE, model.UNDEFINED_SCORE, false, "", time.Now(), model.UNDEFINED_A1C_ESTIMATE, model.DEFAULT_GLUCOSE_TARGETS, model.A1C_FORMULA_ADAG, 0}
+	if err := store.StoreUserProfile(c, time.Now(), other); err != nil {
+		t.Fatal(err)
+	}
+
+	start, _ := time.Parse("02/01/2006 15:04", "18/04/2015 00:00")
+	writeHourlyReads(t, c, start, 48)
+	if err := store.StoreGlukitScoreBatch(c, TEST_USER, []model.GlukitScore{{Value: 10, LowerBound: start, UpperBound: start, CalculatedOn: start, ScoringVersion: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.LogFileImport(c, TEST_USER, model.FileImportLog{Id: "export.xml", LastDataProcessed: start, ImportResult: "Success"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StoreNightscoutSecret(c, TEST_USER, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.GetRepository().PutOAuthAccessData(c, store.OAuthAccessData{AccessToken: "access", RefreshToken: "refresh", UserData: TEST_USER}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.GetRepository().PutOAuthRefreshData(c, store.OAuthAccessData{AccessToken: "access", RefreshToken: "refresh", UserData: TEST_USER}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.PurgeAccountBatch(c, TEST_USER); err != store.ErrNoSuchEntity {
+		t.Fatalf("Expected [%v] when purging an account that isn't being deleted but got [%v]", store.ErrNoSuchEntity, err)
+	}
+
+	deletion, err := store.RequestAccountDeletion(c, TEST_USER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deletion.Status != store.ACCOUNT_DELETION_PENDING {
+		t.Errorf("Expected deletion status [%s] but got [%s]", store.ACCOUNT_DELETION_PENDING, deletion.Status)
+	}
+
+	if _, err = store.GetNightscoutSecretUser(c, "secret"); err != store.ErrNoSuchEntity {
+		t.Errorf("Expected nightscout secret to be revoked but got [%v]", err)
+	}
+	if _, err = store.GetRepository().GetOAuthAccessData(c, "access"); err != store.ErrNoSuchEntity {
+		t.Errorf("Expected access token to be revoked but got [%v]", err)
+	}
+	if _, err = store.GetRepository().GetOAuthRefreshData(c, "refresh"); err != store.ErrNoSuchEntity {
+		t.Errorf("Expected refresh token to be revoked but got [%v]", err)
+	}
+
+	// The user being deleted is the only steady sailor candidate of the other user
+	if _, _, err = store.FindSteadySailor(c, otherUser); err != store.ErrNoSteadySailorMatchFound {
+		t.Errorf("Expected [%v] with the only candidate being deleted but got [%v]", store.ErrNoSteadySailorMatchFound, err)
+	}
+
+	done := false
+	for batches := 0; !done; batches++ {
+		if batches > 10 {
+			t.Fatalf("Purge still not done after [%d] batches", batches)
+		}
+
+		if done, err = store.PurgeAccountBatch(c, TEST_USER); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err = store.GetGlukitUser(c, TEST_USER); err != store.ErrNoSuchEntity {
+		t.Errorf("Expected user to be deleted but got [%v]", err)
+	}
+	if days, _ := store.ScanDaysOf(c, apimodel.GLUCOSE_READ_KIND, TEST_USER, util.GLUKIT_EPOCH_TIME, time.Now()); len(days) != 0 {
+		t.Errorf("Expected no reads left but got [%d] days", len(days))
+	}
+	if scores, _ := store.GetRepository().ScanGlukitScores(c, TEST_USER, store.ScoreScanQuery{}); len(scores) != 0 {
+		t.Errorf("Expected no scores left but got [%d]", len(scores))
+	}
+	if _, err = store.GetFileImportLog(c, TEST_USER, "export.xml"); err != store.ErrNoSuchEntity {
+		t.Errorf("Expected file import log to be deleted but got [%v]", err)
+	}
+
+	deletion, err = store.GetAccountDeletion(c, TEST_USER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deletion.Status != store.ACCOUNT_DELETION_COMPLETED || deletion.Email != "" {
+		t.Errorf("Expected completed deletion without email but got [%v]", deletion)
+	}
+
+	if _, err = store.GetGlukitUser(c, otherUser); err != nil {
+		t.Errorf("Expected other user to be left untouched but got [%v]", err)
+	}
+}
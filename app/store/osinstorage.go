@@ -2,51 +2,16 @@ package store
 
 import (
 	"errors"
+	"github.com/alexandre-normand/glukit/app/log"
 	"github.com/alexandre-normand/osin"
 	"context"
 	"google.golang.org/appengine"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/log"
 	"net/http"
-	"time"
 )
 
 type OsinAppEngineStore struct {
 }
 
-type oClient struct {
-	Id          string `datastore:"Id"`
-	Secret      string `datastore:"Secret,noindex"`
-	RedirectUri string `datastore:"RedirectUri,noindex"`
-	UserData    string `datastore:"UserData,noindex"`
-}
-
-// Authorization data
-type oAuthorizeData struct {
-	ClientId    string    `datastore:"ClientId,noindex"`
-	Code        string    `datastore:"Code"`
-	ExpiresIn   int32     `datastore:"ExpiresIn"`
-	Scope       string    `datastore:"Scope"`
-	RedirectUri string    `datastore:"RedirectUri"`
-	State       string    `datastore:"State"`
-	CreatedAt   time.Time `datastore:"CreatedAt"`
-	UserData    string    `datastore:"UserData,noindex"`
-}
-
-// AccessData
-type oAccessData struct {
-	ClientId          string    `datastore:"ClientId,noindex"`
-	AuthorizeDataCode string    `datastore:"AuthorizeDataCode,noindex"`
-	AccessDataToken   string    `datastore:"AccessDataToken,noindex"`
-	AccessToken       string    `datastore:"AccessToken"`
-	RefreshToken      string    `datastore:"RefreshToken"`
-	ExpiresIn         int32     `datastore:"ExpiresIn,noindex"`
-	Scope             string    `datastore:"Scope,noindex"`
-	RedirectUri       string    `datastore:"RedirectUri,noindex"`
-	CreatedAt         time.Time `datastore:"CreatedAt,noindex"`
-	UserData          string    `datastore:"UserData,noindex"`
-}
-
 func NewOsinAppEngineStoreWithRequest(r *http.Request) *OsinAppEngineStore {
 	c := appengine.NewContext(r)
 
@@ -59,18 +24,17 @@ func NewOsinAppEngineStoreWithContext(c context.Context) *OsinAppEngineStore {
 	return s
 }
 
-func (s *OsinAppEngineStore) addClient(c *osin.Client, context context.Context) error {
+// AddClientWithContext stores a new client. If a client with the same id already exists, it is left untouched.
+func (s *OsinAppEngineStore) AddClientWithContext(c *osin.Client, context context.Context) error {
 	log.Debugf(context, "AddClient: %s...\n", c.Id)
-	key := datastore.NewKey(context, "osin.client", c.Id, 0, nil)
-	client := new(oClient)
-	err := datastore.Get(context, key, client)
+	_, err := repository.GetOAuthClient(context, c.Id)
 
-	if err == nil || err != datastore.ErrNoSuchEntity {
+	if err == nil || err != ErrNoSuchEntity {
 		log.Debugf(context, "Client [%s] already stored, skipping.\n", c.Id)
 		return nil
 	}
 
-	_, err = datastore.Put(context, key, newInternalClient(c))
+	err = repository.PutOAuthClient(context, *newInternalClient(c))
 
 	if err != nil {
 		log.Warningf(context, "Error storing client [%s]: %v", c.Id, err)
@@ -81,14 +45,14 @@ func (s *OsinAppEngineStore) addClient(c *osin.Client, context context.Context)
 	return nil
 }
 
-func newInternalClient(c *osin.Client) *oClient {
+func newInternalClient(c *osin.Client) *OAuthClient {
 	if c == nil {
 		return nil
 	}
-	return &oClient{c.Id, c.Secret, c.RedirectUri, c.UserData.(string)}
+	return &OAuthClient{c.Id, c.Secret, c.RedirectUri, c.UserData.(string)}
 }
 
-func newOsinClient(c *oClient) *osin.Client {
+func newOsinClient(c *OAuthClient) *osin.Client {
 	if c == nil {
 		return nil
 	}
@@ -103,9 +67,7 @@ func (s *OsinAppEngineStore) GetClient(id string, r *http.Request) (*osin.Client
 
 func (s *OsinAppEngineStore) GetClientWithContext(id string, context context.Context) (*osin.Client, error) {
 	log.Debugf(context, "GetClient: %s\n", id)
-	key := datastore.NewKey(context, "osin.client", id, 0, nil)
-	client := new(oClient)
-	err := datastore.Get(context, key, client)
+	client, err := repository.GetOAuthClient(context, id)
 
 	if err != nil {
 		log.Warningf(context, "Error looking up client by id [%s]: [%v]", id, err)
@@ -115,7 +77,7 @@ func (s *OsinAppEngineStore) GetClientWithContext(id string, context context.Con
 	return osinClient, nil
 }
 
-func newInternalAuthorizeData(d *osin.AuthorizeData) *oAuthorizeData {
+func newInternalAuthorizeData(d *osin.AuthorizeData) *OAuthAuthorizeData {
 	if d == nil {
 		return nil
 	}
@@ -125,10 +87,10 @@ func newInternalAuthorizeData(d *osin.AuthorizeData) *oAuthorizeData {
 		clientId = client.Id
 	}
 
-	return &oAuthorizeData{clientId, d.Code, d.ExpiresIn, d.Scope, d.RedirectUri, d.State, d.CreatedAt, d.UserData.(string)}
+	return &OAuthAuthorizeData{clientId, d.Code, d.ExpiresIn, d.Scope, d.RedirectUri, d.State, d.CreatedAt, d.UserData.(string)}
 }
 
-func newOsinAuthorizeData(d *oAuthorizeData, c *osin.Client) *osin.AuthorizeData {
+func newOsinAuthorizeData(d *OAuthAuthorizeData, c *osin.Client) *osin.AuthorizeData {
 	if d == nil {
 		return nil
 	}
@@ -142,8 +104,7 @@ func (s *OsinAppEngineStore) SaveAuthorize(data *osin.AuthorizeData, r *http.Req
 
 func (s *OsinAppEngineStore) SaveAuthorizeWithContext(data *osin.AuthorizeData, context context.Context) error {
 	log.Debugf(context, "SaveAuthorize: %s\n", data.Code)
-	key := datastore.NewKey(context, "authorize.data", data.Code, 0, nil)
-	_, err := datastore.Put(context, key, newInternalAuthorizeData(data))
+	err := repository.PutOAuthAuthorizeData(context, *newInternalAuthorizeData(data))
 	if err != nil {
 		log.Warningf(context, "Error saving authorize data [%s]: [%v]", data.Code, err)
 		return err
@@ -159,9 +120,7 @@ func (s *OsinAppEngineStore) LoadAuthorize(code string, r *http.Request) (*osin.
 
 func (s *OsinAppEngineStore) LoadAuthorizeWithContext(code string, context context.Context) (*osin.AuthorizeData, error) {
 	log.Debugf(context, "LoadAuthorize: %s\n", code)
-	key := datastore.NewKey(context, "authorize.data", code, 0, nil)
-	authorizeData := new(oAuthorizeData)
-	err := datastore.Get(context, key, authorizeData)
+	authorizeData, err := repository.GetOAuthAuthorizeData(context, code)
 	if err != nil {
 		log.Infof(context, "Authorization data not found for code [%s]: %v", code, err)
 		return nil, errors.New("Authorize not found")
@@ -186,8 +145,7 @@ func (s *OsinAppEngineStore) RemoveAuthorize(code string, r *http.Request) error
 
 func (s *OsinAppEngineStore) RemoveAuthorizeWithContext(code string, context context.Context) error {
 	log.Debugf(context, "RemoveAuthorize: %s\n", code)
-	key := datastore.NewKey(context, "authorize.data", code, 0, nil)
-	err := datastore.Delete(context, key)
+	err := repository.DeleteOAuthAuthorizeData(context, code)
 	if err != nil {
 		return err
 	}
@@ -195,7 +153,7 @@ func (s *OsinAppEngineStore) RemoveAuthorizeWithContext(code string, context con
 	return nil
 }
 
-func newInternalAccessData(d *osin.AccessData) *oAccessData {
+func newInternalAccessData(d *osin.AccessData) *OAuthAccessData {
 	if d == nil {
 		return nil
 	}
@@ -214,10 +172,10 @@ func newInternalAccessData(d *osin.AccessData) *oAccessData {
 	if accessData := d.AccessData; accessData != nil {
 		accessToken = accessData.AccessToken
 	}
-	return &oAccessData{clientId, authCode, accessToken, d.AccessToken, d.RefreshToken, d.ExpiresIn, d.Scope, d.RedirectUri, d.CreatedAt, d.UserData.(string)}
+	return &OAuthAccessData{clientId, authCode, accessToken, d.AccessToken, d.RefreshToken, d.ExpiresIn, d.Scope, d.RedirectUri, d.CreatedAt, d.UserData.(string)}
 }
 
-func newOsinAccessData(d *oAccessData, c *osin.Client, authData *osin.AuthorizeData, accessData *osin.AccessData) *osin.AccessData {
+func newOsinAccessData(d *OAuthAccessData, c *osin.Client, authData *osin.AuthorizeData, accessData *osin.AccessData) *osin.AccessData {
 	if d == nil {
 		return nil
 	}
@@ -231,16 +189,14 @@ func (s *OsinAppEngineStore) SaveAccess(data *osin.AccessData, r *http.Request)
 
 func (s *OsinAppEngineStore) SaveAccessWithContext(data *osin.AccessData, context context.Context) error {
 	log.Debugf(context, "SaveAccess [%s]: [%v]\n", data.AccessToken, data)
-	key := datastore.NewKey(context, "access.data", data.AccessToken, 0, nil)
 	internalAccessData := newInternalAccessData(data)
-	_, err := datastore.Put(context, key, internalAccessData)
+	err := repository.PutOAuthAccessData(context, *internalAccessData)
 	if err != nil {
 		return err
 	}
 
 	if data.RefreshToken != "" {
-		key = datastore.NewKey(context, "access.refresh", data.RefreshToken, 0, nil)
-		_, err := datastore.Put(context, key, internalAccessData)
+		err := repository.PutOAuthRefreshData(context, *internalAccessData)
 		if err != nil {
 			return err
 		}
@@ -255,9 +211,7 @@ func (s *OsinAppEngineStore) LoadAccess(code string, r *http.Request) (*osin.Acc
 
 func (s *OsinAppEngineStore) LoadAccessWithContext(code string, context context.Context) (*osin.AccessData, error) {
 	log.Debugf(context, "LoadAccess: %s\n", code)
-	key := datastore.NewKey(context, "access.data", code, 0, nil)
-	accessData := new(oAccessData)
-	err := datastore.Get(context, key, accessData)
+	accessData, err := repository.GetOAuthAccessData(context, code)
 	if err != nil {
 		log.Infof(context, "Access data not found for code [%s]: %v", code, err)
 		return nil, errors.New("Access data not found")
@@ -298,8 +252,7 @@ func (s *OsinAppEngineStore) RemoveAccess(code string, r *http.Request) error {
 
 func (s *OsinAppEngineStore) RemoveAccessWithContext(code string, context context.Context) error {
 	log.Debugf(context, "RemoveAccess: %s\n", code)
-	key := datastore.NewKey(context, "access.data", code, 0, nil)
-	err := datastore.Delete(context, key)
+	err := repository.DeleteOAuthAccessData(context, code)
 	if err != nil {
 		return err
 	}
@@ -314,12 +267,10 @@ func (s *OsinAppEngineStore) LoadRefresh(code string, r *http.Request) (*osin.Ac
 
 func (s *OsinAppEngineStore) LoadRefreshWithContext(code string, context context.Context) (*osin.AccessData, error) {
 	log.Debugf(context, "LoadRefresh: %s\n", code)
-	key := datastore.NewKey(context, "access.refresh", code, 0, nil)
-	accessData := new(oAccessData)
-	err := datastore.Get(context, key, accessData)
+	accessData, err := repository.GetOAuthRefreshData(context, code)
 	if err != nil {
 		log.Infof(context, "Refresh data not found for code [%s]: %v", code, err)
-		errors.New("Refresh not found")
+		return nil, errors.New("Refresh not found")
 	}
 
 	var c *osin.Client
@@ -357,8 +308,7 @@ func (s *OsinAppEngineStore) RemoveRefresh(code string, r *http.Request) error {
 
 func (s *OsinAppEngineStore) RemoveRefreshWithContext(code string, context context.Context) error {
 	log.Debugf(context, "RemoveRefresh: %s\n", code)
-	key := datastore.NewKey(context, "access.refresh", code, 0, nil)
-	err := datastore.Delete(context, key)
+	err := repository.DeleteOAuthRefreshData(context, code)
 	if err != nil {
 		return err
 	}
@@ -1,23 +1,34 @@
 package store_test
 
 import (
+	"context"
 	. "github.com/alexandre-normand/glukit/app/store"
 	"github.com/alexandre-normand/osin"
-	"google.golang.org/appengine/aetest"
 	"testing"
 	"time"
 )
 
-func TestGetClient(t *testing.T) {
-	c, err := aetest.NewContext(nil)
-	if err != nil {
+const TEST_CLIENT_ID = "ENV_GLUKLOADER_CLIENT_ID"
+
+// setupOsinStorage initializes a new memory repository with the TEST_CLIENT_ID client. Callers restore the datastore
+// repository when done.
+func setupOsinStorage(t *testing.T) (c context.Context, osinStorage *OsinAppEngineStore) {
+	SetRepository(NewMemoryRepository())
+	c = context.Background()
+
+	osinStorage = NewOsinAppEngineStoreWithContext(c)
+	if err := osinStorage.AddClientWithContext(&osin.Client{Id: TEST_CLIENT_ID, Secret: "secret", RedirectUri: "uri", UserData: ""}, c); err != nil {
 		t.Fatal(err)
 	}
-	defer c.Close()
-	t.Logf("Request is [%v] from context [%v]", c.Request(), c)
 
-	osinStorage := NewOsinAppEngineStoreWithContext(c)
-	_, err = osinStorage.GetClientWithContext("ENV_GLUKLOADER_CLIENT_ID", c)
+	return c, osinStorage
+}
+
+func TestGetClient(t *testing.T) {
+	defer SetRepository(NewDatastoreRepository())
+	c, osinStorage := setupOsinStorage(t)
+
+	_, err := osinStorage.GetClientWithContext(TEST_CLIENT_ID, c)
 
 	if err != nil {
 		t.Fatal(err)
@@ -25,15 +36,13 @@ func TestGetClient(t *testing.T) {
 }
 
 func TestAccessDataStorage(t *testing.T) {
-	c, err := aetest.NewContext(nil)
+	defer SetRepository(NewDatastoreRepository())
+	c, osinStorage := setupOsinStorage(t)
+
+	client, err := osinStorage.GetClientWithContext(TEST_CLIENT_ID, c)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer c.Close()
-	t.Logf("Request is [%v] from context [%v]", c.Request(), c)
-
-	osinStorage := NewOsinAppEngineStoreWithContext(c)
-	client, err := osinStorage.GetClientWithContext("ENV_GLUKLOADER_CLIENT_ID", c)
 	d := osin.AccessData{client, nil, nil, "token", "test", 0, "scope", "uri", time.Now(), TEST_USER}
 	err = osinStorage.SaveAccessWithContext(&d, c)
 	if err != nil {
@@ -47,14 +56,13 @@ func TestAccessDataStorage(t *testing.T) {
 }
 
 func TestAuthorizeDataStorage(t *testing.T) {
-	c, err := aetest.NewContext(nil)
+	defer SetRepository(NewDatastoreRepository())
+	c, osinStorage := setupOsinStorage(t)
+
+	client, err := osinStorage.GetClientWithContext(TEST_CLIENT_ID, c)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer c.Close()
-
-	osinStorage := NewOsinAppEngineStoreWithContext(c)
-	client, err := osinStorage.GetClientWithContext("ENV_GLUKLOADER_CLIENT_ID", c)
 	d := osin.AuthorizeData{client, "code", 0, "scope", "uri", "state", time.Now(), TEST_USER}
 	err = osinStorage.SaveAuthorizeWithContext(&d, c)
 	if err != nil {
@@ -68,14 +76,13 @@ func TestAuthorizeDataStorage(t *testing.T) {
 }
 
 func TestFullAccessDataStorage(t *testing.T) {
-	c, err := aetest.NewContext(nil)
+	defer SetRepository(NewDatastoreRepository())
+	c, osinStorage := setupOsinStorage(t)
+
+	client, err := osinStorage.GetClientWithContext(TEST_CLIENT_ID, c)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer c.Close()
-
-	osinStorage := NewOsinAppEngineStoreWithContext(c)
-	client, err := osinStorage.GetClientWithContext("ENV_GLUKLOADER_CLIENT_ID", c)
 	d := osin.AuthorizeData{client, "code", 0, "scope", "uri", "state", time.Now(), TEST_USER}
 	err = osinStorage.SaveAuthorizeWithContext(&d, c)
 	if err != nil {
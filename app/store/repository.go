@@ -0,0 +1,220 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/model"
+	"time"
+)
+
+// ErrNoSuchEntity is returned by a Repository when the requested element doesn't exist.
+var ErrNoSuchEntity = errors.New("store: no such entity")
+
+// Repository is the interface to the physical storage of all glukit data. Everything is scoped to a user and a user
+// is identified by its email address. The package-level functions of the store hold the backend-agnostic logic
+// (merging of days of data, filtering, etc.) and use a Repository for the actual reads and writes.
+//
+// Days of data are keyed by their start time. The Get variants return one element for each of the requested start
+// times along with found[i] set to false for those that don't exist yet. The Scan variants return all the elements
+// whose start time falls between scanStart and scanEnd (both inclusive), ordered by start time.
+type Repository interface {
+	GetUser(context context.Context, email string) (userProfile *model.GlukitUser, err error)
+	PutUser(context context.Context, userProfile model.GlukitUser) (err error)
+	// FindUsersByDiabetesType returns up to limit users with the given type of diabetes in ascending order of their most
+	// recent score
+	FindUsersByDiabetesType(context context.Context, diabetesType string, limit int) (users []model.GlukitUser, err error)
+	// ScanUserEmails returns up to limit emails of users, in ascending order, that come after the given email. An empty
+	// email starts from the first user.
+	ScanUserEmails(context context.Context, after string, limit int) (emails []string, err error)
+
+	// Days of data are kept by kind (apimodel.Kind.Name). days must be a slice of the days of that kind of the same
+	// length as startTimes for GetDays and PutDays and a pointer to such a slice for ScanDays.
+	GetDays(context context.Context, kind string, email string, startTimes []time.Time, days interface{}) (found []bool, err error)
+	ScanDays(context context.Context, kind string, email string, scanStart, scanEnd time.Time, days interface{}) (err error)
+	PutDays(context context.Context, kind string, email string, startTimes []time.Time, days interface{}) (err error)
+
+	PutGlukitScores(context context.Context, email string, scores []model.GlukitScore) (err error)
+	ScanGlukitScores(context context.Context, email string, scanQuery ScoreScanQuery) (scores []model.GlukitScore, err error)
+	// FindGlukitScoresBelowVersion returns up to limit scores calculated with a scoring version older than the given one
+	// whose upper bound is after the given time, ordered by upper bound
+	FindGlukitScoresBelowVersion(context context.Context, email string, scoringVersion int, after time.Time, limit int) (scores []model.GlukitScore, err error)
+
+	PutA1CEstimates(context context.Context, email string, a1cs []model.A1CEstimate) (err error)
+	ScanA1CEstimates(context context.Context, email string, scanQuery ScoreScanQuery) (a1cs []model.A1CEstimate, err error)
+
+	// PutLabA1C stores a lab a1c result keyed by the time its sample was taken, replacing any previous result for then
+	PutLabA1C(context context.Context, email string, lab model.LabA1C) (err error)
+	// ScanLabA1Cs returns the lab a1c results whose sample time matches the query, most recent first
+	ScanLabA1Cs(context context.Context, email string, scanQuery ScoreScanQuery) (labs []model.LabA1C, err error)
+
+	// PutSensorSessions stores sensor sessions keyed by their insertion time, replacing previous sessions inserted then
+	PutSensorSessions(context context.Context, email string, sessions []model.SensorSession) (err error)
+	// ScanSensorSessions returns the sensor sessions whose insertion time matches the query, most recent first
+	ScanSensorSessions(context context.Context, email string, scanQuery ScoreScanQuery) (sessions []model.SensorSession, err error)
+
+	PutTherapyEstimate(context context.Context, email string, estimate model.TherapyEstimate) (err error)
+	ScanTherapyEstimates(context context.Context, email string, scanQuery ScoreScanQuery) (estimates []model.TherapyEstimate, err error)
+
+	PutMealImpacts(context context.Context, email string, impacts []model.MealImpact) (err error)
+	// ScanMealImpacts returns the impacts of the meals matching the query on their time, most recent first
+	ScanMealImpacts(context context.Context, email string, scanQuery ScoreScanQuery) (impacts []model.MealImpact, err error)
+
+	// PutInsights stores insights keyed by their type and upper bound, replacing previous detections for the same period
+	PutInsights(context context.Context, email string, insights []model.Insight) (err error)
+	// ScanInsights returns the insights whose upper bound matches the query, most recent first
+	ScanInsights(context context.Context, email string, scanQuery ScoreScanQuery) (insights []model.Insight, err error)
+
+	PutFileImportLog(context context.Context, email string, fileImport model.FileImportLog) (err error)
+	GetFileImportLog(context context.Context, email string, fileId string) (fileImport *model.FileImportLog, err error)
+
+	// Uploaded files are kept, in chunks, until they're imported. Chunks are returned in the order of their index.
+	PutUploadedFileChunks(context context.Context, email string, chunks []UploadedFileChunk) (err error)
+	GetUploadedFileChunks(context context.Context, email string, fileId string) (chunks []UploadedFileChunk, err error)
+	DeleteUploadedFileChunks(context context.Context, email string, fileId string) (err error)
+
+	GetOAuthClient(context context.Context, id string) (client *OAuthClient, err error)
+	PutOAuthClient(context context.Context, client OAuthClient) (err error)
+	GetOAuthAuthorizeData(context context.Context, code string) (data *OAuthAuthorizeData, err error)
+	PutOAuthAuthorizeData(context context.Context, data OAuthAuthorizeData) (err error)
+	DeleteOAuthAuthorizeData(context context.Context, code string) (err error)
+	GetOAuthAccessData(context context.Context, token string) (data *OAuthAccessData, err error)
+	PutOAuthAccessData(context context.Context, data OAuthAccessData) (err error)
+	DeleteOAuthAccessData(context context.Context, token string) (err error)
+	GetOAuthRefreshData(context context.Context, token string) (data *OAuthAccessData, err error)
+	PutOAuthRefreshData(context context.Context, data OAuthAccessData) (err error)
+	DeleteOAuthRefreshData(context context.Context, token string) (err error)
+	// Access and refresh data are found by the email of the user they were issued to (their UserData). This scans all
+	// of them so it's only meant for revoking the tokens of a user.
+	FindOAuthAccessData(context context.Context, email string) (data []OAuthAccessData, err error)
+	FindOAuthRefreshData(context context.Context, email string) (data []OAuthAccessData, err error)
+
+	// Nightscout API secrets are keyed by their hash since that's what clients authenticate with
+	GetNightscoutSecret(context context.Context, secretHash string) (secret *NightscoutSecret, err error)
+	FindNightscoutSecrets(context context.Context, email string) (secrets []NightscoutSecret, err error)
+	PutNightscoutSecret(context context.Context, secret NightscoutSecret) (err error)
+	DeleteNightscoutSecret(context context.Context, secretHash string) (err error)
+
+	// Account deletions are keyed by the hash of the user's email
+	GetAccountDeletion(context context.Context, emailHash string) (deletion *AccountDeletion, err error)
+	PutAccountDeletion(context context.Context, deletion AccountDeletion) (err error)
+	FindAccountDeletions(context context.Context, status string) (deletions []AccountDeletion, err error)
+
+	// Alert settings are kept under the user. Alert events are keyed by their rule and time so that an alert is only
+	// recorded once. Events are scanned in ascending order of time.
+	GetAlertSettings(context context.Context, email string) (settings *model.AlertSettings, err error)
+	PutAlertSettings(context context.Context, email string, settings model.AlertSettings) (err error)
+	// FindEmailsMonitoringMissingData returns the emails of users whose alert settings have a missing data rule
+	FindEmailsMonitoringMissingData(context context.Context) (emails []string, err error)
+	GetAlertEvent(context context.Context, email string, rule model.AlertRule, eventTime time.Time) (event *model.AlertEvent, err error)
+	PutAlertEvent(context context.Context, email string, event model.AlertEvent) (err error)
+	ScanAlertEvents(context context.Context, email string, scanStart, scanEnd time.Time) (events []model.AlertEvent, err error)
+
+	// DeleteUserData deletes up to limit of the elements held under a user (days of data, scores, a1cs, lab a1cs,
+	// therapy estimates, meal impacts, insights, file import logs, uploaded files and alerts) and returns how many were
+	// deleted. The user itself is left untouched.
+	DeleteUserData(context context.Context, email string, limit int) (deleted int, err error)
+	DeleteUser(context context.Context, email string) (err error)
+}
+
+// OAuthClient is the flattened storage representation of an osin.Client
+type OAuthClient struct {
+	Id          string `datastore:"Id"`
+	Secret      string `datastore:"Secret,noindex"`
+	RedirectUri string `datastore:"RedirectUri,noindex"`
+	UserData    string `datastore:"UserData,noindex"`
+}
+
+// OAuthAuthorizeData is the flattened storage representation of an osin.AuthorizeData
+type OAuthAuthorizeData struct {
+	ClientId    string    `datastore:"ClientId,noindex"`
+	Code        string    `datastore:"Code"`
+	ExpiresIn   int32     `datastore:"ExpiresIn"`
+	Scope       string    `datastore:"Scope"`
+	RedirectUri string    `datastore:"RedirectUri"`
+	State       string    `datastore:"State"`
+	CreatedAt   time.Time `datastore:"CreatedAt"`
+	UserData    string    `datastore:"UserData,noindex"`
+}
+
+// OAuthAccessData is the flattened storage representation of an osin.AccessData. UserData holds the email of the user
+// the token was issued to.
+type OAuthAccessData struct {
+	ClientId          string    `datastore:"ClientId,noindex"`
+	AuthorizeDataCode string    `datastore:"AuthorizeDataCode,noindex"`
+	AccessDataToken   string    `datastore:"AccessDataToken,noindex"`
+	AccessToken       string    `datastore:"AccessToken"`
+	RefreshToken      string    `datastore:"RefreshToken"`
+	ExpiresIn         int32     `datastore:"ExpiresIn,noindex"`
+	Scope             string    `datastore:"Scope,noindex"`
+	RedirectUri       string    `datastore:"RedirectUri,noindex"`
+	CreatedAt         time.Time `datastore:"CreatedAt,noindex"`
+	UserData          string    `datastore:"UserData,noindex"`
+}
+
+// NightscoutSecret associates the hash of a user's Nightscout API secret with the user. The secret itself is never
+// stored.
+type NightscoutSecret struct {
+	SecretHash string    `datastore:"SecretHash,noindex"`
+	Email      string    `datastore:"Email"`
+	CreatedAt  time.Time `datastore:"CreatedAt,noindex"`
+}
+
+// AccountDeletion tracks the purge of all of a user's data. It's keyed by the hash of the user's email so that it can
+// be kept, as confirmation, once the purge is completed and the email is cleared.
+type AccountDeletion struct {
+	EmailHash   string    `json:"-" datastore:"EmailHash,noindex"`
+	Email       string    `json:"email,omitempty" datastore:"Email,noindex"`
+	Status      string    `json:"status" datastore:"Status"`
+	RequestedAt time.Time `json:"requestedAt" datastore:"RequestedAt,noindex"`
+	CompletedAt time.Time `json:"completedAt" datastore:"CompletedAt,noindex"`
+}
+
+// UploadedFileChunk is a part of a file uploaded by a user. Files are split in chunks to stay under the maximum
+// size of an entity.
+type UploadedFileChunk struct {
+	FileId string `datastore:"FileId"`
+	Index  int    `datastore:"Index,noindex"`
+	Data   []byte `datastore:"Data,noindex"`
+}
+
+type uploadedFileChunksByIndex []UploadedFileChunk
+
+func (chunks uploadedFileChunksByIndex) Len() int           { return len(chunks) }
+func (chunks uploadedFileChunksByIndex) Swap(i, j int)      { chunks[i], chunks[j] = chunks[j], chunks[i] }
+func (chunks uploadedFileChunksByIndex) Less(i, j int) bool { return chunks[i].Index < chunks[j].Index }
+
+// The active repository, App Engine's datastore unless configured otherwise
+var repository Repository = NewDatastoreRepository()
+
+// SetRepository sets the Repository used by all store functions. This is meant to be called once, during
+// initialization, before any request is served.
+func SetRepository(r Repository) {
+	repository = r
+}
+
+// GetRepository returns the Repository currently used by the store functions
+func GetRepository() Repository {
+	return repository
+}
+
+// alertEventKeyName returns the name an alert event is keyed by, unique for the rule that raised it and its time so
+// that rules of the same type don't suppress each other
+func alertEventKeyName(rule model.AlertRule, eventTime time.Time) string {
+	return fmt.Sprintf("%s-%g-%d-%d", rule.Type, rule.Threshold, rule.DurationMinutes, eventTime.Unix())
+}
+
+// insightKeyName returns the name identifying the insight of a type detected for the period ending at upperBound
+func insightKeyName(insightType string, upperBound time.Time) string {
+	return fmt.Sprintf("%s-%d", insightType, upperBound.Unix())
+}
+
+// dayStartTimes returns the start times that identify each of the given days of data
+func dayStartTimes(length int, startTime func(i int) time.Time) (startTimes []time.Time) {
+	startTimes = make([]time.Time, length)
+	for i := range startTimes {
+		startTimes[i] = startTime(i)
+	}
+
+	return startTimes
+}
@@ -2,22 +2,35 @@
 package store
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"github.com/alexandre-normand/glukit/app/apimodel"
 	"github.com/alexandre-normand/glukit/app/container"
+	"github.com/alexandre-normand/glukit/app/log"
 	"github.com/alexandre-normand/glukit/app/model"
 	"github.com/alexandre-normand/glukit/app/util"
 	"context"
-	"google.golang.org/appengine"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/log"
-	"math"
 	"sort"
+	"strconv"
 	"time"
 )
 
 const (
 	// Number of GlukitScores to batch in a single PutMulti
 	GLUKIT_SCORE_PUT_MULTI_SIZE = 10
+
+	// Size of the chunks of uploaded files, kept under the maximum size of a datastore entity
+	UPLOADED_FILE_CHUNK_SIZE = 900 * 1024
+
+	// Number of elements deleted by each batch of an account purge, the maximum of a datastore DeleteMulti
+	ACCOUNT_PURGE_BATCH_SIZE = 500
+
+	// Status of an AccountDeletion
+	ACCOUNT_DELETION_PENDING   = "Pending"
+	ACCOUNT_DELETION_COMPLETED = "Completed"
+
+	// Key name of a user's alert settings, there's only one per user
+	ALERT_SETTINGS_KEY_NAME = "alerts"
 )
 
 // Error interface to distinguish between temporary errors from permanent ones
@@ -44,164 +57,120 @@ var (
 	ErrNoSteadySailorMatchFound = StoreError{"store: no match for a steady sailor found", true}
 )
 
-// GetUserKey returns the GlukitUser datastore key given its email address.
-func GetUserKey(context context.Context, email string) (key *datastore.Key) {
-	return datastore.NewKey(context, "GlukitUser", email, 0, nil)
-}
-
-// StoreUserProfile stores a GlukitUser profile to the datastore. If the entry already exists, it is overriden and it is created
+// StoreUserProfile stores a GlukitUser profile. If the entry already exists, it is overriden and it is created
 // otherwise
-func StoreUserProfile(context context.Context, updatedAt time.Time, userProfile model.GlukitUser) (key *datastore.Key, err error) {
-	key, error := datastore.Put(context, GetUserKey(context, userProfile.Email), &userProfile)
-	if error != nil {
-		util.Propagate(error)
+func StoreUserProfile(context context.Context, updatedAt time.Time, userProfile model.GlukitUser) (err error) {
+	if err = repository.PutUser(context, userProfile); err != nil {
+		util.Propagate(err)
 	}
 
-	return key, nil
+	return nil
 }
 
-// GetUserProfile returns the GlukitUser entry associated with the given datastore key. This can be obtained
-// by calling GetUserKey.
-func GetUserProfile(context context.Context, key *datastore.Key) (userProfile *model.GlukitUser, err error) {
-	userProfile = new(model.GlukitUser)
-	log.Infof(context, "Fetching user profile for key: %s", key.String())
-	err = datastore.Get(context, key, userProfile)
-	if err != nil {
-		if unknownFields, ok := err.(*datastore.ErrFieldMismatch); ok {
-			log.Infof(context, "Ignoring unknown fields [%s]", unknownFields.Error())
-		} else {
-			return nil, err
-		}
-	}
-
-	return userProfile, nil
+// GetUserProfile returns the GlukitUser entry associated with the given email address. If the user doesn't exist,
+// ErrNoSuchEntity is returned.
+func GetUserProfile(context context.Context, email string) (userProfile *model.GlukitUser, err error) {
+	return repository.GetUser(context, email)
 }
 
-// GetGlucoseReads returns all GlucoseReads given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
-func GetGlucoseReads(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (reads []apimodel.GlucoseRead, err error) {
-	key := GetUserKey(context, email)
-
-	// Scan start should be one day prior and scan end should be one day later so that we can capture the day using
-	// a single column inequality filter. The scan should actually capture at least one day and a maximum of 3
-	scanStart := lowerBound.Add(time.Duration(-24 * time.Hour))
-	scanEnd := upperBound.Add(time.Duration(24 * time.Hour))
-
-	log.Infof(context, "Scanning for reads between %s and %s to get reads between %s and %s", scanStart, scanEnd, lowerBound, upperBound)
+// scanBoundaries returns the boundaries of the scan for days of data. Scan start should be one day prior and scan end
+// should be one day later so that we can capture the day using a single column inequality filter. The scan should
+// actually capture at least one day and a maximum of 3
+func scanBoundaries(lowerBound time.Time, upperBound time.Time) (scanStart, scanEnd time.Time) {
+	return lowerBound.Add(time.Duration(-24 * time.Hour)), upperBound.Add(time.Duration(24 * time.Hour))
+}
 
-	query := datastore.NewQuery("DayOfReads").Ancestor(key).Filter("startTime >=", scanStart).Filter("startTime <=", scanEnd).Order("startTime")
-	daysOfReads := new(apimodel.DayOfGlucoseReads)
-	readsForPeriod := make([]apimodel.GlucoseRead, 0)
+// GetElements returns all elements of a kind given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
+func GetElements[T apimodel.Timestamped, D apimodel.DayOf[T]](context context.Context, kind apimodel.Kind[T, D], email string, lowerBound time.Time, upperBound time.Time) (elements []T, err error) {
+	scanStart, scanEnd := scanBoundaries(lowerBound, upperBound)
+	log.Infof(context, "Scanning for [%s] between %s and %s to get elements between %s and %s", kind.Name, scanStart, scanEnd, lowerBound, upperBound)
 
-	iterator := query.Run(context)
-	for _, err := iterator.Next(daysOfReads); err == nil; _, err = iterator.Next(daysOfReads) {
-		log.Debugf(context, "Loaded batch of %d reads...", len(daysOfReads.Reads))
-		readsForPeriod = mergeGlucoseReadArrays(readsForPeriod, daysOfReads.Reads)
-		daysOfReads = new(apimodel.DayOfGlucoseReads)
+	days, err := ScanDaysOf(context, kind, email, scanStart, scanEnd)
+	if err != nil {
+		util.Propagate(err)
 	}
 
-	readSlice := apimodel.GlucoseReadSlice(readsForPeriod)
-	startIndex, endIndex := apimodel.GetBoundariesOfElementsInRange(readSlice, lowerBound, upperBound)
-	filteredReads := readsForPeriod[startIndex : endIndex+1]
-
-	if err != datastore.Done {
-		util.Propagate(err)
+	elementsForPeriod := make([]T, 0)
+	for _, day := range days {
+		log.Debugf(context, "Loaded batch of %d elements of [%s]...", len(day.GetElements()), kind.Name)
+		elementsForPeriod = append(elementsForPeriod, day.GetElements()...)
 	}
 
-	return filteredReads, nil
-}
+	elementSlice := apimodel.TimestampedSlice[T](elementsForPeriod)
+	startIndex, endIndex := apimodel.GetBoundariesOfElementsInRange(elementSlice, lowerBound, upperBound)
+	filtered := elementsForPeriod[startIndex : endIndex+1]
 
-// StoreDaysOfReads stores a batch of DayOfReads elements. It is a optimized operation in that:
-//    1. One element represents a relatively short-and-wide entry of all reads for a single day.
-//    2. We have multiple DayOfReads elements and we use a PutMulti to make this faster.
-// For details of how a single element of DayOfReads is physically stored, see the implementation of apimodel.Store and apimodel.Load.
-// Also important to note, this store operation also handles updating the GlukitUser entry with the most recent read, if applicable.
-func StoreDaysOfReads(context context.Context, userProfileKey *datastore.Key, daysOfReads []apimodel.DayOfGlucoseReads) (keys []*datastore.Key, err error) {
-	elementKeys := make([]*datastore.Key, len(daysOfReads))
-	for i := range daysOfReads {
-		log.Debugf(context, "Storing day of reads with [%d] reads and key [%d]", len(daysOfReads[i].Reads), daysOfReads[i].StartTime.Unix())
-		elementKeys[i] = datastore.NewKey(context, "DayOfReads", "", daysOfReads[i].StartTime.Unix(), userProfileKey)
-	}
+	return filtered, nil
+}
 
-	daysOfReads, err = reconcileDayOfReadsWithExisting(context, elementKeys, daysOfReads)
-	if err != nil {
+// ScanDaysOf returns the days of data of a kind whose start time falls between scanStart and scanEnd (both inclusive),
+// ordered by start time
+func ScanDaysOf[T apimodel.Timestamped, D apimodel.DayOf[T]](context context.Context, kind apimodel.Kind[T, D], email string, scanStart, scanEnd time.Time) (days []D, err error) {
+	if err = repository.ScanDays(context, kind.Name, email, scanStart, scanEnd, &days); err != nil {
 		return nil, err
 	}
 
-	log.Infof(context, "Emitting a PutMulti with %d keys for all %d days of reads", len(elementKeys), len(daysOfReads))
-	keys, error := datastore.PutMulti(context, elementKeys, daysOfReads)
-	if error != nil {
-		log.Warningf(context, "Error writing %d days of reads with keys [%s]: %v", len(elementKeys), elementKeys, error)
-		return nil, error
-	}
+	return days, nil
+}
 
-	// Get the time of the batch's last read and update the most recent read timestamp if necessary
-	userProfile, err := GetGlukitUserWithKey(context, userProfileKey)
+// StoreDaysOf stores a batch of days of data of a kind. It is a optimized operation in that:
+//    1. One element represents a relatively short-and-wide entry of all elements for a single day.
+//    2. We have multiple days and we write them all in a single repository operation.
+// Elements of a day that was already stored are merged with the stored ones, the new ones replacing those at the same time.
+func StoreDaysOf[T apimodel.Timestamped, D apimodel.DayOf[T]](context context.Context, kind apimodel.Kind[T, D], email string, days []D) (err error) {
+	days, err = reconcileDaysWithExisting(context, kind, email, days)
 	if err != nil {
-		log.Criticalf(context, "Error reading user profile [%s] for its most recent read value: %v", userProfileKey, err)
-		return nil, err
+		return err
 	}
 
-	lastDayOfRead := daysOfReads[len(daysOfReads)-1]
-	lastRead := lastDayOfRead.Reads[len(lastDayOfRead.Reads)-1]
-	if userProfile.MostRecentRead.GetTime().Before(lastRead.GetTime()) {
-		log.Infof(context, "Updating most recent read date to %s", lastRead.GetTime())
-		userProfile.MostRecentRead = lastRead
-		_, err := StoreUserProfile(context, time.Now(), *userProfile)
-		if err != nil {
-			log.Criticalf(context, "Error storing updated user profile [%s] with most recent read value of %s: %v", userProfileKey, userProfile.MostRecentRead, err)
-			return nil, err
-		}
+	log.Infof(context, "Writing all %d days of [%s]", len(days), kind.Name)
+	if err = repository.PutDays(context, kind.Name, email, dayStartTimes(len(days), func(i int) time.Time { return days[i].GetStartTime() }), days); err != nil {
+		log.Criticalf(context, "Error writing %d days of [%s] for user [%s]: %v", len(days), kind.Name, email, err)
+		return err
 	}
 
-	return elementKeys, nil
+	return nil
 }
 
-func reconcileDayOfReadsWithExisting(context context.Context, elementKeys []*datastore.Key, freshData []apimodel.DayOfGlucoseReads) (reconciledData []apimodel.DayOfGlucoseReads, err error) {
-	reconciledData = make([]apimodel.DayOfGlucoseReads, len(freshData))
+func reconcileDaysWithExisting[T apimodel.Timestamped, D apimodel.DayOf[T]](context context.Context, kind apimodel.Kind[T, D], email string, freshData []D) (reconciledData []D, err error) {
+	reconciledData = make([]D, len(freshData))
 	// Merge with any pre-existing data
-	existingData := make([]apimodel.DayOfGlucoseReads, len(elementKeys))
-	err = datastore.GetMulti(context, elementKeys, existingData)
-	// If there's an error and it's not a MultiError, return immediately as something went wrong
-	if multierr, ok := err.(appengine.MultiError); !ok && err != nil {
+	startTimes := dayStartTimes(len(freshData), func(i int) time.Time { return freshData[i].GetStartTime() })
+	existingData := make([]D, len(freshData))
+	found, err := repository.GetDays(context, kind.Name, email, startTimes, existingData)
+	if err != nil {
 		log.Warningf(context, "Got error: %v", err)
 		return nil, err
-	} else {
-		if err == nil {
-			for i := range existingData {
-				log.Debugf(context, "Merging old ([%d]) with new ([%d]) at index [%d]", len(existingData[i].Reads), len(freshData[i].Reads), i)
-				reconciledReads := reconcileReads(existingData[i].Reads, freshData[i].Reads)
-				log.Debugf(context, "Merged reads ([%d]) is [%v]", len(reconciledReads), reconciledReads)
-				reconciledData[i] = apimodel.DayOfGlucoseReads{reconciledReads, existingData[i].StartTime, freshData[i].EndTime}
-			}
-		}
+	}
 
-		for i, elementErr := range multierr {
-			if elementErr == datastore.ErrNoSuchEntity {
-				log.Debugf(context, "Keeping day of reads for key [%s] as-is since we have no pre-existing data for it.", elementKeys[i].String())
-				reconciledData[i] = freshData[i]
-			} else {
-				log.Debugf(context, "Merging old ([%d]) with new ([%d]) at index [%d]", len(existingData[i].Reads), len(freshData[i].Reads), i)
-				reconciledReads := reconcileReads(existingData[i].Reads, freshData[i].Reads)
-				log.Debugf(context, "Merged reads ([%d]) is [%v]", len(reconciledReads), reconciledReads)
-				reconciledData[i] = apimodel.DayOfGlucoseReads{reconciledReads, existingData[i].StartTime, freshData[i].EndTime}
-			}
+	for i := range freshData {
+		if !found[i] {
+			log.Debugf(context, "Keeping day of [%s] for key [%d] as-is since we have no pre-existing data for it.", kind.Name, startTimes[i].Unix())
+			reconciledData[i] = freshData[i]
+		} else {
+			log.Debugf(context, "Merging old ([%d]) with new ([%d]) at index [%d]", len(existingData[i].GetElements()), len(freshData[i].GetElements()), i)
+			reconciled := reconcileElements(existingData[i].GetElements(), freshData[i].GetElements())
+			log.Debugf(context, "Merged [%s] ([%d]) is [%v]", kind.Name, len(reconciled), reconciled)
+			reconciledData[i] = kind.NewDayOf(reconciled, existingData[i].GetStartTime(), freshData[i].GetEndTime())
 		}
 	}
 
 	return reconciledData, nil
 }
 
-func reconcileReads(older, recent []apimodel.GlucoseRead) (reconciledReads []apimodel.GlucoseRead) {
+// reconcileElements merges older and recent elements sorted by time, the recent ones replacing older ones at the same
+// time
+func reconcileElements[T apimodel.Timestamped](older, recent []T) (reconciledElements []T) {
 	allKeys := make([]int64, 0)
-	values := make(map[int64]apimodel.GlucoseRead)
+	values := make(map[int64]T)
 	for i := range older {
-		timestamp := older[i].Time.Timestamp
+		timestamp := apimodel.GetTimeMillis(older[i].GetTime())
 		allKeys = append(allKeys, timestamp)
 		values[timestamp] = older[i]
 	}
 
 	for i := range recent {
-		timestamp := recent[i].Time.Timestamp
+		timestamp := apimodel.GetTimeMillis(recent[i].GetTime())
 		if _, exists := values[timestamp]; !exists {
 			allKeys = append(allKeys, timestamp)
 		}
@@ -210,536 +179,300 @@ func reconcileReads(older, recent []apimodel.GlucoseRead) (reconciledReads []api
 
 	sort.Sort(container.Int64Slice(allKeys))
 
-	reconciledReads = make([]apimodel.GlucoseRead, len(allKeys))
+	reconciledElements = make([]T, len(allKeys))
 	for i := range allKeys {
-		reconciledReads[i] = values[allKeys[i]]
+		reconciledElements[i] = values[allKeys[i]]
 	}
 
-	return reconciledReads
+	return reconciledElements
 }
 
-// GetCalibrations returns all Calibration entries given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
-func GetCalibrations(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (meals []apimodel.CalibrationRead, err error) {
-	key := GetUserKey(context, email)
-
-	// Scan start should be one day prior and scan end should be one day later so that we can capture the day using
-	// a single column inequality filter. The scan should actually capture at least one day and a maximum of 3
-	scanStart := lowerBound.Add(time.Duration(-24 * time.Hour))
-	scanEnd := upperBound.Add(time.Duration(24 * time.Hour))
-
-	log.Infof(context, "Scanning for calibrations between %s and %s to get calibrations between %s and %s", scanStart, scanEnd, lowerBound, upperBound)
-
-	query := datastore.NewQuery("DayOfCalibrationReads").Ancestor(key).Filter("startTime >=", scanStart).Filter("startTime <=", scanEnd).Order("startTime")
-	daysOfCalibration := new(apimodel.DayOfCalibrationReads)
-	calibrationsForPeriod := make([]apimodel.CalibrationRead, 0)
-
-	iterator := query.Run(context)
-	for _, err := iterator.Next(daysOfCalibration); err == nil; _, err = iterator.Next(daysOfCalibration) {
-		log.Debugf(context, "Loaded batch of %d calibrations...", len(daysOfCalibration.Reads))
-		calibrationsForPeriod = mergeCalibrationReadArrays(calibrationsForPeriod, daysOfCalibration.Reads)
-		daysOfCalibration = new(apimodel.DayOfCalibrationReads)
-	}
-
-	calibrationSlice := apimodel.CalibrationReadSlice(calibrationsForPeriod)
-	startIndex, endIndex := apimodel.GetBoundariesOfElementsInRange(calibrationSlice, lowerBound, upperBound)
-	filteredCalibrations := calibrationsForPeriod[startIndex : endIndex+1]
-
-	if err != datastore.Done {
-		util.Propagate(err)
-	}
-
-	return filteredCalibrations, nil
+// GetGlucoseReads returns all GlucoseReads given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
+func GetGlucoseReads(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (reads []apimodel.GlucoseRead, err error) {
+	return GetElements(context, apimodel.GLUCOSE_READ_KIND, email, lowerBound, upperBound)
 }
 
-// StoreCalibrationReads stores a batch of DayOfCalibrations elements. It is a optimized operation in that:
-//    1. One element represents a relatively short-and-wide entry of all calibration reads for a single day.
-//    2. We have multiple DayOfReads elements and we use a PutMulti to make this faster.
-// For details of how a single element of DayOfReads is physically stored, see the implementation of apimodel.Store and apimodel.Load.
-func StoreCalibrationReads(context context.Context, userProfileKey *datastore.Key, daysOfCalibrationReads []apimodel.DayOfCalibrationReads) (keys []*datastore.Key, err error) {
-	elementKeys := make([]*datastore.Key, len(daysOfCalibrationReads))
-	for i := range daysOfCalibrationReads {
-		log.Debugf(context, "Storing day of calibration reads with [%d] reads and key [%d]", len(daysOfCalibrationReads[i].Reads), daysOfCalibrationReads[i].StartTime.Unix())
-		elementKeys[i] = datastore.NewKey(context, "DayOfCalibrationReads", "", daysOfCalibrationReads[i].StartTime.Unix(), userProfileKey)
+// StoreDaysOfReads stores a batch of DayOfReads elements like StoreDaysOf does for any kind but also handles updating
+// the GlukitUser entry with the most recent read, if applicable.
+func StoreDaysOfReads(context context.Context, email string, daysOfReads []apimodel.DayOfGlucoseReads) (err error) {
+	for i := range daysOfReads {
+		log.Debugf(context, "Storing day of reads with [%d] reads and key [%d]", len(daysOfReads[i].Reads), daysOfReads[i].StartTime.Unix())
 	}
 
-	daysOfCalibrationReads, err = reconcileDayOfCalibrationsWithExisting(context, elementKeys, daysOfCalibrationReads)
-	if err != nil {
-		return nil, err
+	if err = StoreDaysOf(context, apimodel.GLUCOSE_READ_KIND, email, daysOfReads); err != nil {
+		log.Warningf(context, "Error writing %d days of reads for user [%s]: %v", len(daysOfReads), email, err)
+		return err
 	}
 
-	log.Infof(context, "Emitting a PutMulti with %d keys for all %d days of calibration reads", len(elementKeys), len(daysOfCalibrationReads))
-	keys, error := datastore.PutMulti(context, elementKeys, daysOfCalibrationReads)
-	if error != nil {
-		log.Criticalf(context, "Error writing %d days of calibration reads with keys [%s]: %v", len(elementKeys), elementKeys, error)
-		return nil, error
+	// Get the time of the batch's last read and update the most recent read timestamp if necessary
+	userProfile, err := GetGlukitUser(context, email)
+	if err != nil {
+		log.Criticalf(context, "Error reading user profile [%s] for its most recent read value: %v", email, err)
+		return err
 	}
 
-	return elementKeys, nil
-}
-
-func reconcileDayOfCalibrationsWithExisting(context context.Context, elementKeys []*datastore.Key, freshData []apimodel.DayOfCalibrationReads) (reconciledData []apimodel.DayOfCalibrationReads, err error) {
-	reconciledData = make([]apimodel.DayOfCalibrationReads, len(freshData))
-	// Merge with any pre-existing data
-	existingData := make([]apimodel.DayOfCalibrationReads, len(elementKeys))
-	err = datastore.GetMulti(context, elementKeys, existingData)
-	// If there's an error and it's not a MultiError, return immediately as something went wrong
-	if multierr, ok := err.(appengine.MultiError); !ok && err != nil {
-		log.Warningf(context, "Got error: %v", err)
-		return nil, err
-	} else {
-		if err == nil {
-			for i := range existingData {
-				log.Debugf(context, "Merging old ([%d]) with new ([%d]) at index [%d]", len(existingData[i].Reads), len(freshData[i].Reads), i)
-				reconciledReads := reconcileCalibrations(existingData[i].Reads, freshData[i].Reads)
-				log.Debugf(context, "Merged calibrations ([%d]) is [%v]", len(reconciledReads), reconciledReads)
-				reconciledData[i] = apimodel.DayOfCalibrationReads{reconciledReads, existingData[i].StartTime, freshData[i].EndTime}
-			}
-		}
-
-		for i, elementErr := range multierr {
-			if elementErr == datastore.ErrNoSuchEntity {
-				log.Debugf(context, "Keeping day of calibrations for key [%s] as-is since we have no pre-existing data for it.", elementKeys[i].String())
-				reconciledData[i] = freshData[i]
-			} else {
-				log.Debugf(context, "Merging old ([%d]) with new ([%d]) at index [%d]", len(existingData[i].Reads), len(freshData[i].Reads), i)
-				reconciledReads := reconcileCalibrations(existingData[i].Reads, freshData[i].Reads)
-				log.Debugf(context, "Merged calibrations ([%d]) is [%v]", len(reconciledReads), reconciledReads)
-				reconciledData[i] = apimodel.DayOfCalibrationReads{reconciledReads, existingData[i].StartTime, freshData[i].EndTime}
-			}
+	lastDayOfRead := daysOfReads[len(daysOfReads)-1]
+	lastRead := lastDayOfRead.Reads[len(lastDayOfRead.Reads)-1]
+	if userProfile.MostRecentRead.GetTime().Before(lastRead.GetTime()) {
+		log.Infof(context, "Updating most recent read date to %s", lastRead.GetTime())
+		userProfile.MostRecentRead = lastRead
+		err := StoreUserProfile(context, time.Now(), *userProfile)
+		if err != nil {
+			log.Criticalf(context, "Error storing updated user profile [%s] with most recent read value of %s: %v", email, userProfile.MostRecentRead, err)
+			return err
 		}
 	}
 
-	return reconciledData, nil
+	return nil
 }
 
-func reconcileCalibrations(older, recent []apimodel.CalibrationRead) (reconciledReads []apimodel.CalibrationRead) {
-	allKeys := make([]int64, 0)
-	values := make(map[int64]apimodel.CalibrationRead)
-	for i := range older {
-		timestamp := older[i].Time.Timestamp
-		allKeys = append(allKeys, timestamp)
-		values[timestamp] = older[i]
-	}
-
-	for i := range recent {
-		timestamp := recent[i].Time.Timestamp
-		if _, exists := values[timestamp]; !exists {
-			allKeys = append(allKeys, timestamp)
-		}
-		values[timestamp] = recent[i]
-	}
-
-	sort.Sort(container.Int64Slice(allKeys))
-
-	reconciledReads = make([]apimodel.CalibrationRead, len(allKeys))
-	for i := range allKeys {
-		reconciledReads[i] = values[allKeys[i]]
-	}
-
-	return reconciledReads
+// GetCalibrations returns all Calibration entries given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
+func GetCalibrations(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (calibrations []apimodel.CalibrationRead, err error) {
+	return GetElements(context, apimodel.CALIBRATION_READ_KIND, email, lowerBound, upperBound)
 }
 
 // GetInjections returns all Injection entries given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
-func GetInjections(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (meals []apimodel.Injection, err error) {
-	key := GetUserKey(context, email)
-
-	// Scan start should be one day prior and scan end should be one day later so that we can capture the day using
-	// a single column inequality filter. The scan should actually capture at least one day and a maximum of 3
-	scanStart := lowerBound.Add(time.Duration(-24 * time.Hour))
-	scanEnd := upperBound.Add(time.Duration(24 * time.Hour))
-
-	log.Infof(context, "Scanning for meals between %s and %s to get meals between %s and %s", scanStart, scanEnd, lowerBound, upperBound)
-
-	query := datastore.NewQuery("DayOfInjections").Ancestor(key).Filter("startTime >=", scanStart).Filter("startTime <=", scanEnd).Order("startTime")
-	daysOfInjections := new(apimodel.DayOfInjections)
-	mealsForPeriod := make([]apimodel.Injection, 0)
-
-	iterator := query.Run(context)
-	for _, err := iterator.Next(daysOfInjections); err == nil; _, err = iterator.Next(daysOfInjections) {
-		log.Debugf(context, "Loaded batch of %d meals...", len(daysOfInjections.Injections))
-		mealsForPeriod = mergeInjectionArrays(mealsForPeriod, daysOfInjections.Injections)
-		daysOfInjections = new(apimodel.DayOfInjections)
-	}
-
-	mealSlice := apimodel.InjectionSlice(mealsForPeriod)
-	startIndex, endIndex := apimodel.GetBoundariesOfElementsInRange(mealSlice, lowerBound, upperBound)
-	filteredInjections := mealsForPeriod[startIndex : endIndex+1]
-
-	if err != datastore.Done {
-		util.Propagate(err)
-	}
-
-	return filteredInjections, nil
+func GetInjections(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (injections []apimodel.Injection, err error) {
+	return GetElements(context, apimodel.INJECTION_KIND, email, lowerBound, upperBound)
 }
 
-// StoreDaysOfInjections stores a batch of DayOfInjections elements. It is a optimized operation in that:
-//    1. One element represents a relatively short-and-wide entry of all meals for a single day.
-//    2. We have multiple DayOfInjections elements and we use a PutMulti to make this faster.
-// For details of how a single element of DayOfInjections is physically stored, see the implementation of apimodel.Store and apimodel.Load.
-func StoreDaysOfInjections(context context.Context, userProfileKey *datastore.Key, daysOfInjections []apimodel.DayOfInjections) (keys []*datastore.Key, err error) {
-	elementKeys := make([]*datastore.Key, len(daysOfInjections))
-	for i := range daysOfInjections {
-		elementKeys[i] = datastore.NewKey(context, "DayOfInjections", "", daysOfInjections[i].StartTime.Unix(), userProfileKey)
-	}
+// GetMeals returns all Meal entries given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
+func GetMeals(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (carbs []apimodel.Meal, err error) {
+	return GetElements(context, apimodel.MEAL_KIND, email, lowerBound, upperBound)
+}
 
-	daysOfInjections, err = reconcileDayOfInjectionsWithExisting(context, elementKeys, daysOfInjections)
-	if err != nil {
-		return nil, err
-	}
+// GetExercises returns all Exercise entries given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
+func GetExercises(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (exercises []apimodel.Exercise, err error) {
+	return GetElements(context, apimodel.EXERCISE_KIND, email, lowerBound, upperBound)
+}
 
-	log.Infof(context, "Emitting a PutMulti with %d keys for all %d days of meals", len(elementKeys), len(daysOfInjections))
-	keys, error := datastore.PutMulti(context, elementKeys, daysOfInjections)
-	if error != nil {
-		log.Criticalf(context, "Error writing %d days of meals with keys [%s]: %v", len(elementKeys), elementKeys, error)
-		return nil, error
+// LogFileImport persist a log of a file import operation. A log entry is actually kept for each distinct file and NOT for every log import
+// operation. That is, if we re-import and updated file, we should update the FileImportLog for that file but not create a new one.
+// This is used to optimize and not reimport a file that hasn't been updated.
+func LogFileImport(context context.Context, email string, fileImport model.FileImportLog) (err error) {
+	log.Infof(context, "Storing file import log for file id [%s]", fileImport.Id)
+	if err = repository.PutFileImportLog(context, email, fileImport); err != nil {
+		log.Criticalf(context, "Error storing file import log for file id [%s]: %v", fileImport.Id, err)
+		return err
 	}
 
-	return elementKeys, nil
+	return nil
 }
 
-func reconcileDayOfInjectionsWithExisting(context context.Context, elementKeys []*datastore.Key, freshData []apimodel.DayOfInjections) (reconciledData []apimodel.DayOfInjections, err error) {
-	reconciledData = make([]apimodel.DayOfInjections, len(freshData))
-	// Merge with any pre-existing data
-	existingData := make([]apimodel.DayOfInjections, len(elementKeys))
-	err = datastore.GetMulti(context, elementKeys, existingData)
-	// If there's an error and it's not a MultiError, return immediately as something went wrong
-	if multierr, ok := err.(appengine.MultiError); !ok && err != nil {
-		log.Warningf(context, "Got error: %v", err)
-		return nil, err
-	} else {
-		if err == nil {
-			for i := range existingData {
-				log.Debugf(context, "Merging old ([%d]) with new ([%d]) at index [%d]", len(existingData[i].Injections), len(freshData[i].Injections), i)
-				reconciledInjections := reconcileInjections(existingData[i].Injections, freshData[i].Injections)
-				log.Debugf(context, "Merged meals ([%d]) is [%v]", len(reconciledInjections), reconciledInjections)
-				reconciledData[i] = apimodel.DayOfInjections{reconciledInjections, existingData[i].StartTime, freshData[i].EndTime}
-			}
-		}
-
-		for i, elementErr := range multierr {
-			if elementErr == datastore.ErrNoSuchEntity {
-				log.Debugf(context, "Keeping day of meals for key [%s] as-is since we have no pre-existing data for it.", elementKeys[i].String())
-				reconciledData[i] = freshData[i]
-			} else {
-				log.Debugf(context, "Merging old ([%d]) with new ([%d]) at index [%d]", len(existingData[i].Injections), len(freshData[i].Injections), i)
-				reconciledInjections := reconcileInjections(existingData[i].Injections, freshData[i].Injections)
-				log.Debugf(context, "Merged meals ([%d]) is [%v]", len(reconciledInjections), reconciledInjections)
-				reconciledData[i] = apimodel.DayOfInjections{reconciledInjections, existingData[i].StartTime, freshData[i].EndTime}
-			}
-		}
-	}
-
-	return reconciledData, nil
+// GetFileImportLog retrieves a FileImportLog entry for a given file id. If it's the first time we import this file id,
+// ErrNoSuchEntity is returned
+func GetFileImportLog(context context.Context, email string, fileId string) (fileImport *model.FileImportLog, err error) {
+	log.Infof(context, "Reading file import log for file id [%s]", fileId)
+	return repository.GetFileImportLog(context, email, fileId)
 }
 
-func reconcileInjections(older, recent []apimodel.Injection) (reconciledInjections []apimodel.Injection) {
-	allKeys := make([]int64, 0)
-	values := make(map[int64]apimodel.Injection)
-	for i := range older {
-		timestamp := older[i].Time.Timestamp
-		allKeys = append(allKeys, timestamp)
-		values[timestamp] = older[i]
+// StoreUploadedFile keeps the content of an uploaded file until it's imported, replacing any previous upload with
+// the same file id
+func StoreUploadedFile(context context.Context, email string, fileId string, content []byte) (err error) {
+	if err = repository.DeleteUploadedFileChunks(context, email, fileId); err != nil {
+		return err
 	}
 
-	for i := range recent {
-		timestamp := recent[i].Time.Timestamp
-		if _, exists := values[timestamp]; !exists {
-			allKeys = append(allKeys, timestamp)
+	chunks := make([]UploadedFileChunk, 0, len(content)/UPLOADED_FILE_CHUNK_SIZE+1)
+	for start := 0; start < len(content); start += UPLOADED_FILE_CHUNK_SIZE {
+		end := start + UPLOADED_FILE_CHUNK_SIZE
+		if end > len(content) {
+			end = len(content)
 		}
-		values[timestamp] = recent[i]
-	}
-
-	sort.Sort(container.Int64Slice(allKeys))
-
-	reconciledInjections = make([]apimodel.Injection, len(allKeys))
-	for i := range allKeys {
-		reconciledInjections[i] = values[allKeys[i]]
+		chunks = append(chunks, UploadedFileChunk{FileId: fileId, Index: len(chunks), Data: content[start:end]})
 	}
 
-	return reconciledInjections
+	log.Infof(context, "Storing uploaded file [%s] of [%d] bytes in [%d] chunks for user [%s]", fileId, len(content), len(chunks), email)
+	return repository.PutUploadedFileChunks(context, email, chunks)
 }
 
-// GetMeals returns all Meal entries given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
-func GetMeals(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (carbs []apimodel.Meal, err error) {
-	key := GetUserKey(context, email)
-
-	// Scan start should be one day prior and scan end should be one day later so that we can capture the day using
-	// a single column inequality filter. The scan should actually capture at least one day and a maximum of 3
-	scanStart := lowerBound.Add(time.Duration(-24 * time.Hour))
-	scanEnd := upperBound.Add(time.Duration(24 * time.Hour))
-
-	log.Infof(context, "Scanning for carbs between %s and %s to get carbs between %s and %s", scanStart, scanEnd, lowerBound, upperBound)
-
-	query := datastore.NewQuery("DayOfMeals").Ancestor(key).Filter("startTime >=", scanStart).Filter("startTime <=", scanEnd).Order("startTime")
-	daysOfMeals := new(apimodel.DayOfMeals)
-	mealsForPeriod := make([]apimodel.Meal, 0)
-
-	iterator := query.Run(context)
-	for _, err := iterator.Next(daysOfMeals); err == nil; _, err = iterator.Next(daysOfMeals) {
-		log.Debugf(context, "Loaded batch of %d carbs...", len(daysOfMeals.Meals))
-		mealsForPeriod = mergeMealArrays(mealsForPeriod, daysOfMeals.Meals)
-		daysOfMeals = new(apimodel.DayOfMeals)
+// GetUploadedFile returns the content of an uploaded file or ErrNoSuchEntity if there is no such upload
+func GetUploadedFile(context context.Context, email string, fileId string) (content []byte, err error) {
+	chunks, err := repository.GetUploadedFileChunks(context, email, fileId)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Debugf(context, "Filtering between [%s] and [%s], %d carbs: %v", lowerBound, upperBound, len(mealsForPeriod), mealsForPeriod)
-	carbSlice := apimodel.MealSlice(mealsForPeriod)
-	startIndex, endIndex := apimodel.GetBoundariesOfElementsInRange(carbSlice, lowerBound, upperBound)
-	filteredMeals := mealsForPeriod[startIndex : endIndex+1]
-	log.Debugf(context, "Finished filtering with %d carbs", len(filteredMeals))
+	if len(chunks) == 0 {
+		return nil, ErrNoSuchEntity
+	}
 
-	if err != datastore.Done {
-		util.Propagate(err)
+	for _, chunk := range chunks {
+		content = append(content, chunk.Data...)
 	}
 
-	return filteredMeals, nil
+	return content, nil
 }
 
-// StoreDaysOfMeals stores a batch of DayOfMeals elements. It is a optimized operation in that:
-//    1. One element represents a relatively short-and-wide entry of all Meals for a single day.
-//    2. We have multiple DayOfMeals elements and we use a PutMulti to make this faster.
-// For details of how a single element of DayOfMeals is physically stored, see the implementation of apimodel.Store and apimodel.Load.
-func StoreDaysOfMeals(context context.Context, userProfileKey *datastore.Key, daysOfMeals []apimodel.DayOfMeals) (keys []*datastore.Key, err error) {
-	elementKeys := make([]*datastore.Key, len(daysOfMeals))
-	for i := range daysOfMeals {
-		elementKeys[i] = datastore.NewKey(context, "DayOfMeals", "", daysOfMeals[i].StartTime.Unix(), userProfileKey)
-	}
+// DeleteUploadedFile deletes the content of an uploaded file, once it's been imported
+func DeleteUploadedFile(context context.Context, email string, fileId string) (err error) {
+	return repository.DeleteUploadedFileChunks(context, email, fileId)
+}
 
-	daysOfMeals, err = reconcileDayOfMealsWithExisting(context, elementKeys, daysOfMeals)
+// StoreNightscoutSecret sets the hash of the Nightscout API secret of a user, replacing any secret the user had before
+func StoreNightscoutSecret(context context.Context, email string, secretHash string) (err error) {
+	existingSecrets, err := repository.FindNightscoutSecrets(context, email)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	log.Infof(context, "Emitting a PutMulti with %d keys for all %d days of meals", len(elementKeys), len(daysOfMeals))
-	keys, error := datastore.PutMulti(context, elementKeys, daysOfMeals)
-	if error != nil {
-		log.Criticalf(context, "Error writing %d days of meals with keys [%s]: %v", len(elementKeys), elementKeys, error)
-		return nil, error
+	for _, existingSecret := range existingSecrets {
+		if err = repository.DeleteNightscoutSecret(context, existingSecret.SecretHash); err != nil {
+			return err
+		}
 	}
 
-	return elementKeys, nil
+	log.Infof(context, "Storing new nightscout secret for user [%s]", email)
+	return repository.PutNightscoutSecret(context, NightscoutSecret{SecretHash: secretHash, Email: email, CreatedAt: time.Now()})
 }
 
-func reconcileDayOfMealsWithExisting(context context.Context, elementKeys []*datastore.Key, freshData []apimodel.DayOfMeals) (reconciledData []apimodel.DayOfMeals, err error) {
-	reconciledData = make([]apimodel.DayOfMeals, len(freshData))
-	// Merge with any pre-existing data
-	existingData := make([]apimodel.DayOfMeals, len(elementKeys))
-	err = datastore.GetMulti(context, elementKeys, existingData)
-	// If there's an error and it's not a MultiError, return immediately as something went wrong
-	if multierr, ok := err.(appengine.MultiError); !ok && err != nil {
-		log.Warningf(context, "Got error: %v", err)
-		return nil, err
-	} else {
-		if err == nil {
-			for i := range existingData {
-				log.Debugf(context, "Merging old ([%d]) with new ([%d]) at index [%d]", len(existingData[i].Meals), len(freshData[i].Meals), i)
-				reconciledMeals := reconcileMeals(existingData[i].Meals, freshData[i].Meals)
-				log.Debugf(context, "Merged meals ([%d]) is [%v]", len(reconciledMeals), reconciledMeals)
-				reconciledData[i] = apimodel.DayOfMeals{reconciledMeals, existingData[i].StartTime, freshData[i].EndTime}
-			}
-		}
-
-		for i, elementErr := range multierr {
-			if elementErr == datastore.ErrNoSuchEntity {
-				log.Debugf(context, "Keeping day of meals for key [%s] as-is since we have no pre-existing data for it.", elementKeys[i].String())
-				reconciledData[i] = freshData[i]
-			} else {
-				log.Debugf(context, "Merging old ([%d]) with new ([%d]) at index [%d]", len(existingData[i].Meals), len(freshData[i].Meals), i)
-				reconciledMeals := reconcileMeals(existingData[i].Meals, freshData[i].Meals)
-				log.Debugf(context, "Merged meals ([%d]) is [%v]", len(reconciledMeals), reconciledMeals)
-				reconciledData[i] = apimodel.DayOfMeals{reconciledMeals, existingData[i].StartTime, freshData[i].EndTime}
-			}
-		}
+// GetNightscoutSecretUser returns the email of the user with the given Nightscout API secret hash or ErrNoSuchEntity
+// if no user has that secret
+func GetNightscoutSecretUser(context context.Context, secretHash string) (email string, err error) {
+	secret, err := repository.GetNightscoutSecret(context, secretHash)
+	if err != nil {
+		return "", err
 	}
 
-	return reconciledData, nil
+	return secret.Email, nil
 }
 
-func reconcileMeals(older, recent []apimodel.Meal) (reconciledMeals []apimodel.Meal) {
-	allKeys := make([]int64, 0)
-	values := make(map[int64]apimodel.Meal)
-	for i := range older {
-		timestamp := older[i].Time.Timestamp
-		allKeys = append(allKeys, timestamp)
-		values[timestamp] = older[i]
+// RequestAccountDeletion flags the account of a user for deletion and revokes its Nightscout API secret and oauth
+// tokens right away. The actual purge of its data is done in batches by PurgeAccountBatch. Requesting the deletion of
+// an account that's already pending deletion returns the existing request.
+func RequestAccountDeletion(context context.Context, email string) (deletion *AccountDeletion, err error) {
+	deletion, err = GetAccountDeletion(context, email)
+	if err == nil && deletion.Status == ACCOUNT_DELETION_PENDING {
+		return deletion, nil
+	} else if err != nil && err != ErrNoSuchEntity {
+		return nil, err
 	}
 
-	for i := range recent {
-		timestamp := recent[i].Time.Timestamp
-		if _, exists := values[timestamp]; !exists {
-			allKeys = append(allKeys, timestamp)
-		}
-		values[timestamp] = recent[i]
+	log.Infof(context, "Flagging account of user [%s] for deletion", email)
+	deletion = &AccountDeletion{EmailHash: hashEmail(email), Email: email, Status: ACCOUNT_DELETION_PENDING, RequestedAt: time.Now()}
+	if err = repository.PutAccountDeletion(context, *deletion); err != nil {
+		return nil, err
 	}
 
-	sort.Sort(container.Int64Slice(allKeys))
-
-	reconciledMeals = make([]apimodel.Meal, len(allKeys))
-	for i := range allKeys {
-		reconciledMeals[i] = values[allKeys[i]]
+	if err = revokeCredentials(context, email); err != nil {
+		return nil, err
 	}
 
-	return reconciledMeals
+	return deletion, nil
 }
 
-// GetExercises returns all Exercise entries given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
-func GetExercises(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (exercises []apimodel.Exercise, err error) {
-	key := GetUserKey(context, email)
-
-	// Scan start should be one day prior and scan end should be one day later so that we can capture the day using
-	// a single column inequality filter. The scan should actually capture at least one day and a maximum of 3
-	scanStart := lowerBound.Add(time.Duration(-24 * time.Hour))
-	scanEnd := upperBound.Add(time.Duration(24 * time.Hour))
-
-	log.Infof(context, "Scanning for exercises between %s and %s to get exercises between %s and %s", scanStart, scanEnd, lowerBound, upperBound)
+// GetAccountDeletion returns the latest deletion requested for the account of a user or ErrNoSuchEntity if the
+// deletion of that account was never requested
+func GetAccountDeletion(context context.Context, email string) (deletion *AccountDeletion, err error) {
+	return repository.GetAccountDeletion(context, hashEmail(email))
+}
 
-	query := datastore.NewQuery("DayOfExercises").Ancestor(key).Filter("startTime >=", scanStart).Filter("startTime <=", scanEnd).Order("startTime")
-	daysOfExercises := new(apimodel.DayOfExercises)
-	exercisesForPeriod := make([]apimodel.Exercise, 0)
+// FindPendingAccountDeletions returns the account deletions whose purge isn't completed yet
+func FindPendingAccountDeletions(context context.Context) (deletions []AccountDeletion, err error) {
+	return repository.FindAccountDeletions(context, ACCOUNT_DELETION_PENDING)
+}
 
-	iterator := query.Run(context)
-	for _, err := iterator.Next(daysOfExercises); err == nil; _, err = iterator.Next(daysOfExercises) {
-		log.Debugf(context, "Loaded batch of %d exercises...", len(daysOfExercises.Exercises))
-		exercisesForPeriod = mergeExerciseArrays(exercisesForPeriod, daysOfExercises.Exercises)
-		daysOfExercises = new(apimodel.DayOfExercises)
+// IsAccountPendingDeletion returns true if the account of the user is being purged
+func IsAccountPendingDeletion(context context.Context, email string) (pending bool, err error) {
+	deletion, err := GetAccountDeletion(context, email)
+	if err == ErrNoSuchEntity {
+		return false, nil
+	} else if err != nil {
+		return false, err
 	}
 
-	exerciseSlice := apimodel.ExerciseSlice(exercisesForPeriod)
-	startIndex, endIndex := apimodel.GetBoundariesOfElementsInRange(exerciseSlice, lowerBound, upperBound)
-	filteredExercises := exercisesForPeriod[startIndex : endIndex+1]
+	return deletion.Status == ACCOUNT_DELETION_PENDING, nil
+}
 
-	if err != datastore.Done {
-		util.Propagate(err)
+// PurgeAccountBatch deletes the next batch of ACCOUNT_PURGE_BATCH_SIZE elements of data of an account pending deletion.
+// Once no data is left, credentials issued since the deletion was requested are revoked, the user itself is deleted and
+// the deletion is marked as completed, forgetting the email. done is true when that's over. Accounts that aren't
+// pending deletion are never purged.
+func PurgeAccountBatch(context context.Context, email string) (done bool, err error) {
+	deletion, err := GetAccountDeletion(context, email)
+	if err != nil {
+		return false, err
 	}
 
-	return filteredExercises, nil
-}
-
-// StoreDaysOfExercises stores a batch of DayOfExercises elements. It is a optimized operation in that:
-//    1. One element represents a relatively short-and-wide entry of all Exercises for a single day.
-//    2. We have multiple DayOfExercises elements and we use a PutMulti to make this faster.
-// For details of how a single element of DayOfExercises is physically stored, see the implementation of apimodel.Store and apimodel.Load.
-func StoreDaysOfExercises(context context.Context, userProfileKey *datastore.Key, daysOfExercises []apimodel.DayOfExercises) (keys []*datastore.Key, err error) {
-	elementKeys := make([]*datastore.Key, len(daysOfExercises))
-	for i := range daysOfExercises {
-		elementKeys[i] = datastore.NewKey(context, "DayOfExercises", "", daysOfExercises[i].StartTime.Unix(), userProfileKey)
+	if deletion.Status != ACCOUNT_DELETION_PENDING {
+		return true, nil
 	}
 
-	daysOfExercises, err = reconcileDayOfExercisesWithExisting(context, elementKeys, daysOfExercises)
+	deleted, err := repository.DeleteUserData(context, email, ACCOUNT_PURGE_BATCH_SIZE)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
-	log.Infof(context, "Emitting a PutMulti with %d keys for all %d days of exercises", len(elementKeys), len(daysOfExercises))
-	keys, error := datastore.PutMulti(context, elementKeys, daysOfExercises)
-	if error != nil {
-		log.Criticalf(context, "Error writing %d days of exercises with keys [%s]: %v", len(elementKeys), elementKeys, error)
-		return nil, error
+	if deleted > 0 {
+		log.Infof(context, "Purged batch of [%d] elements of user [%s]", deleted, email)
+		return false, nil
 	}
 
-	return elementKeys, nil
-}
+	if err = revokeCredentials(context, email); err != nil {
+		return false, err
+	}
 
-func reconcileDayOfExercisesWithExisting(context context.Context, elementKeys []*datastore.Key, freshData []apimodel.DayOfExercises) (reconciledData []apimodel.DayOfExercises, err error) {
-	reconciledData = make([]apimodel.DayOfExercises, len(freshData))
-	// Merge with any pre-existing data
-	existingData := make([]apimodel.DayOfExercises, len(elementKeys))
-	err = datastore.GetMulti(context, elementKeys, existingData)
-	// If there's an error and it's not a MultiError, return immediately as something went wrong
-	if multierr, ok := err.(appengine.MultiError); !ok && err != nil {
-		log.Warningf(context, "Got error: %v", err)
-		return nil, err
-	} else {
-		if err == nil {
-			for i := range existingData {
-				log.Debugf(context, "Merging old ([%d]) with new ([%d]) at index [%d]", len(existingData[i].Exercises), len(freshData[i].Exercises), i)
-				reconciledExercises := reconcileExercises(existingData[i].Exercises, freshData[i].Exercises)
-				log.Debugf(context, "Merged exercises ([%d]) is [%v]", len(reconciledExercises), reconciledExercises)
-				reconciledData[i] = apimodel.DayOfExercises{reconciledExercises, existingData[i].StartTime, freshData[i].EndTime}
-			}
-		}
+	if err = repository.DeleteUser(context, email); err != nil {
+		return false, err
+	}
 
-		for i, elementErr := range multierr {
-			if elementErr == datastore.ErrNoSuchEntity {
-				log.Debugf(context, "Keeping day of exercises for key [%s] as-is since we have no pre-existing data for it.", elementKeys[i].String())
-				reconciledData[i] = freshData[i]
-			} else {
-				log.Debugf(context, "Merging old ([%d]) with new ([%d]) at index [%d]", len(existingData[i].Exercises), len(freshData[i].Exercises), i)
-				reconciledExercises := reconcileExercises(existingData[i].Exercises, freshData[i].Exercises)
-				log.Debugf(context, "Merged exercises ([%d]) is [%v]", len(reconciledExercises), reconciledExercises)
-				reconciledData[i] = apimodel.DayOfExercises{reconciledExercises, existingData[i].StartTime, freshData[i].EndTime}
-			}
-		}
+	log.Infof(context, "Completed purge of account of user [%s]", email)
+	deletion.Email = ""
+	deletion.Status = ACCOUNT_DELETION_COMPLETED
+	deletion.CompletedAt = time.Now()
+	if err = repository.PutAccountDeletion(context, *deletion); err != nil {
+		return false, err
 	}
 
-	return reconciledData, nil
+	return true, nil
 }
 
-func reconcileExercises(older, recent []apimodel.Exercise) (reconciledExercises []apimodel.Exercise) {
-	allKeys := make([]int64, 0)
-	values := make(map[int64]apimodel.Exercise)
-	for i := range older {
-		timestamp := older[i].Time.Timestamp
-		allKeys = append(allKeys, timestamp)
-		values[timestamp] = older[i]
+// revokeCredentials deletes the Nightscout API secrets and oauth access and refresh tokens of a user
+func revokeCredentials(context context.Context, email string) (err error) {
+	secrets, err := repository.FindNightscoutSecrets(context, email)
+	if err != nil {
+		return err
 	}
-
-	for i := range recent {
-		timestamp := recent[i].Time.Timestamp
-		if _, exists := values[timestamp]; !exists {
-			allKeys = append(allKeys, timestamp)
+	for _, secret := range secrets {
+		if err = repository.DeleteNightscoutSecret(context, secret.SecretHash); err != nil {
+			return err
 		}
-		values[timestamp] = recent[i]
 	}
 
-	sort.Sort(container.Int64Slice(allKeys))
-
-	reconciledExercises = make([]apimodel.Exercise, len(allKeys))
-	for i := range allKeys {
-		reconciledExercises[i] = values[allKeys[i]]
+	accessData, err := repository.FindOAuthAccessData(context, email)
+	if err != nil {
+		return err
+	}
+	for _, data := range accessData {
+		if err = repository.DeleteOAuthAccessData(context, data.AccessToken); err != nil {
+			return err
+		}
 	}
 
-	return reconciledExercises
-}
-
-// LogFileImport persist a log of a file import operation. A log entry is actually kept for each distinct file and NOT for every log import
-// operation. That is, if we re-import and updated file, we should update the FileImportLog for that file but not create a new one.
-// This is used to optimize and not reimport a file that hasn't been updated.
-func LogFileImport(context context.Context, userProfileKey *datastore.Key, fileImport model.FileImportLog) (key *datastore.Key, err error) {
-	key = datastore.NewKey(context, "FileImportLog", fileImport.Id, 0, userProfileKey)
-
-	log.Infof(context, "Emitting a Put for file import log with key [%s] for file id [%s]", key, fileImport.Id)
-	key, err = datastore.Put(context, key, &fileImport)
+	refreshData, err := repository.FindOAuthRefreshData(context, email)
 	if err != nil {
-		log.Criticalf(context, "Error storing file import log with key [%s] for file id [%s]: %v", key, fileImport.Id, err)
-		return nil, err
+		return err
 	}
-
-	return key, nil
-}
-
-// GetFileImportLog retrieves a FileImportLog entry for a given file id. If it's the first time we import this file id, a zeroed FileImportLog
-// element is returned
-func GetFileImportLog(context context.Context, userProfileKey *datastore.Key, fileId string) (fileImport *model.FileImportLog, err error) {
-	key := datastore.NewKey(context, "FileImportLog", fileId, 0, userProfileKey)
-
-	log.Infof(context, "Reading file import log for file id [%s]", fileId)
-	fileImport = new(model.FileImportLog)
-	error := datastore.Get(context, key, fileImport)
-	if error != nil {
-		return nil, error
+	for _, data := range refreshData {
+		if err = repository.DeleteOAuthRefreshData(context, data.RefreshToken); err != nil {
+			return err
+		}
 	}
 
-	return fileImport, nil
+	log.Infof(context, "Revoked [%d] nightscout secrets, [%d] access tokens and [%d] refresh tokens of user [%s]", len(secrets),
+		len(accessData), len(refreshData), email)
+	return nil
 }
 
-func GetGlukitUser(context context.Context, email string) (key *datastore.Key, userProfile *model.GlukitUser, err error) {
-	key = GetUserKey(context, email)
-	userProfile, err = GetGlukitUserWithKey(context, key)
-	return key, userProfile, err
+// hashEmail returns the hex-encoded sha256 hash of an email
+func hashEmail(email string) string {
+	hash := sha256.Sum256([]byte(email))
+	return hex.EncodeToString(hash[:])
 }
 
-func GetGlukitUserWithKey(context context.Context, key *datastore.Key) (userProfile *model.GlukitUser, err error) {
-	userProfile, err = GetUserProfile(context, key)
+// GetGlukitUser returns the GlukitUser entry for the given email address
+func GetGlukitUser(context context.Context, email string) (userProfile *model.GlukitUser, err error) {
+	userProfile, err = GetUserProfile(context, email)
 	if err != nil {
 		return nil, err
 	}
@@ -749,36 +482,33 @@ func GetGlukitUserWithKey(context context.Context, key *datastore.Key) (userProf
 
 // GetUserData returns a GlukitUser entry and the boundaries of its most recent complete reads.
 // If the user doesn't have any imported data yet, GetUserData returns ErrNoImportedDataFound
-func GetUserData(context context.Context, email string) (userProfile *model.GlukitUser, key *datastore.Key, upperBound time.Time, err error) {
-	key = GetUserKey(context, email)
-	userProfile, err = GetUserProfile(context, key)
+func GetUserData(context context.Context, email string) (userProfile *model.GlukitUser, upperBound time.Time, err error) {
+	userProfile, err = GetUserProfile(context, email)
 	if err != nil {
-		return nil, nil, util.GLUKIT_EPOCH_TIME, err
+		return nil, util.GLUKIT_EPOCH_TIME, err
 	}
 
 	// If the most recent read is still at the beginning on time, we know no data has been imported yet
 	if util.GLUKIT_EPOCH_TIME.Equal(userProfile.MostRecentRead.GetTime()) {
-		return userProfile, key, util.GLUKIT_EPOCH_TIME, ErrNoImportedDataFound
+		return userProfile, util.GLUKIT_EPOCH_TIME, ErrNoImportedDataFound
 	} else {
-		return userProfile, key, userProfile.MostRecentRead.GetTime(), nil
+		return userProfile, userProfile.MostRecentRead.GetTime(), nil
 	}
 }
 
-// FindSteadySailor queries the datastore for others users of the same type of diabetes. It will then select the match that
+// FindSteadySailor queries the repository for others users of the same type of diabetes. It will then select the match that
 // has a top glukit score and return that user profile along with the upper boundary for its most recent day of reads.
 // The steps involved are:
 //    - Find the user profile of the recipient
-//    - Query the data store for profile data that matches (using the type of diabetes) in ascending order of score value
+//    - Query the repository for profile data that matches (using the type of diabetes) in ascending order of score value
 //       * A first time for users that are NOT internal
 //       * A second time including internal users (if the first one returns no match)
-//    - Filter out the recipient profile that could be returned in the search
+//    - Filter out the recipient profile that could be returned in the search and users whose account is being deleted
 //    - If match found, get the profile of the steady sailor
-func FindSteadySailor(context context.Context, recipientEmail string) (sailorProfile *model.GlukitUser, key *datastore.Key, upperBound time.Time, err error) {
-	key = GetUserKey(context, recipientEmail)
-
-	recipientProfile, err := GetUserProfile(context, key)
+func FindSteadySailor(context context.Context, recipientEmail string) (sailorProfile *model.GlukitUser, upperBound time.Time, err error) {
+	recipientProfile, err := GetUserProfile(context, recipientEmail)
 	if err != nil {
-		return nil, nil, util.GLUKIT_EPOCH_TIME, err
+		return nil, util.GLUKIT_EPOCH_TIME, err
 	}
 
 	log.Debugf(context, "Looking for other diabetes of type [%s]", recipientProfile.DiabetesType)
@@ -786,22 +516,19 @@ func FindSteadySailor(context context.Context, recipientEmail string) (sailorPro
 	// Only get the top-sailor of the same type of diabetes. We might want to throw some randomization in there and pick one of the top 10
 	// using cursors or offsets. We need to check at least for two because the recipient user will always be returned by the query.
 	// It's more efficient to filter the recipient after the fact than before.
-	query := datastore.NewQuery("GlukitUser").
-		Filter("diabetesType =", recipientProfile.DiabetesType).
-		Order("mostRecentScore.value").Limit(5)
-
-	var steadySailors []model.GlukitUser
-	_, err = query.GetAll(context, &steadySailors)
+	steadySailors, err := repository.FindUsersByDiabetesType(context, recipientProfile.DiabetesType, 5)
 	if err != nil {
-		if unknownFields, ok := err.(*datastore.ErrFieldMismatch); ok {
-			log.Infof(context, "Ignoring unknown fields [%s]", unknownFields.Error())
-		} else {
-			return nil, nil, util.GLUKIT_EPOCH_TIME, err
-		}
+		return nil, util.GLUKIT_EPOCH_TIME, err
 	}
 
 	log.Debugf(context, "Found a few unfiltered matches [%v]", steadySailors)
 
+	// Users whose account is being deleted never show up as a steady sailor
+	steadySailors, err = excludeAccountsPendingDeletion(context, steadySailors)
+	if err != nil {
+		return nil, util.GLUKIT_EPOCH_TIME, err
+	}
+
 	// Stop when we find the first match.
 
 	// First, try for real users
@@ -825,132 +552,221 @@ func FindSteadySailor(context context.Context, recipientEmail string) (sailorPro
 
 	if sailorProfile == nil {
 		log.Warningf(context, "No steady sailor match found for user [%s] with type of diabetes [%s]", recipientEmail, recipientProfile.DiabetesType)
-		return nil, nil, util.GLUKIT_EPOCH_TIME, ErrNoSteadySailorMatchFound
+		return nil, util.GLUKIT_EPOCH_TIME, ErrNoSteadySailorMatchFound
 	} else {
 		log.Infof(context, "Found a steady sailor match for user [%s]: healthy [%s]", recipientEmail, sailorProfile.Email)
 		upperBound = util.GetEndOfDayBoundaryBefore(sailorProfile.MostRecentRead.GetTime())
-		return sailorProfile, GetUserKey(context, sailorProfile.Email), upperBound, nil
+		return sailorProfile, upperBound, nil
 	}
 }
 
-// StoreGlukitScoreBatch stores a batch of GlukitScores. The array could be of any size. A large batch of GlukitScores
-// will be internally split into multiple PutMultis.
-func StoreGlukitScoreBatch(context context.Context, userEmail string, glukitScores []model.GlukitScore) error {
-	parentKey := GetUserKey(context, userEmail)
+// excludeAccountsPendingDeletion returns the users whose account isn't pending deletion
+func excludeAccountsPendingDeletion(context context.Context, users []model.GlukitUser) (filtered []model.GlukitUser, err error) {
+	filtered = make([]model.GlukitUser, 0, len(users))
+	for _, user := range users {
+		pending, err := IsAccountPendingDeletion(context, user.Email)
+		if err != nil {
+			return nil, err
+		}
 
-	totalBatchSize := float64(len(glukitScores))
-	for chunkStartIndex := 0; chunkStartIndex < len(glukitScores); chunkStartIndex = chunkStartIndex + GLUKIT_SCORE_PUT_MULTI_SIZE {
-		chunkEndIndex := int(math.Min(float64(chunkStartIndex+GLUKIT_SCORE_PUT_MULTI_SIZE), totalBatchSize))
-		glukitScoreChunk := glukitScores[chunkStartIndex:chunkEndIndex]
-		storeGlukitScoreChunk(context, parentKey, glukitScoreChunk)
+		if !pending {
+			filtered = append(filtered, user)
+		}
 	}
 
-	return nil
+	return filtered, nil
 }
 
-func storeGlukitScoreChunk(context context.Context, parentKey *datastore.Key, glukitScoreChunk []model.GlukitScore) (keys []*datastore.Key, err error) {
-	log.Debugf(context, "Storing chunk of [%d] glukit scores", len(glukitScoreChunk))
+// StoreGlukitScoreBatch stores a batch of GlukitScores. The array could be of any size.
+func StoreGlukitScoreBatch(context context.Context, userEmail string, glukitScores []model.GlukitScore) error {
+	log.Debugf(context, "Storing batch of [%d] glukit scores", len(glukitScores))
+	return repository.PutGlukitScores(context, userEmail, glukitScores)
+}
 
-	elementKeys := make([]*datastore.Key, len(glukitScoreChunk))
-	for i := range glukitScoreChunk {
-		elementKeys[i] = datastore.NewKey(context, "GlukitScore", "", glukitScoreChunk[i].UpperBound.Unix(), parentKey)
-	}
+// GetGlukitScores returns all GlukitScores for the given email address and matching the query parameters
+func GetGlukitScores(context context.Context, email string, scanQuery ScoreScanQuery) (scores []model.GlukitScore, err error) {
+	log.Infof(context, "Scanning for glukit scores with limit [%s], from [%s], to [%s]", formatLimit(scanQuery.Limit), scanQuery.From, scanQuery.To)
 
-	log.Infof(context, "Emitting a PutMulti with [%d] keys for all [%d] glukit scores of chunk", len(elementKeys), len(glukitScoreChunk))
-	keys, error := datastore.PutMulti(context, elementKeys, glukitScoreChunk)
-	if error != nil {
-		log.Criticalf(context, "Error writing [%d] glukit scores with keys [%s]: %v", len(elementKeys), elementKeys, error)
-		return nil, error
+	scores, err = repository.ScanGlukitScores(context, email, scanQuery)
+	if err != nil {
+		util.Propagate(err)
 	}
 
-	return elementKeys, nil
+	log.Infof(context, "Found [%d] glukit scores.", len(scores))
+	return scores, nil
 }
 
-// GetGlukitScores returns all GlukitScores for the given email address and matching the query parameters
-func GetGlukitScores(context context.Context, email string, scanQuery ScoreScanQuery) (scores []model.GlukitScore, err error) {
-	key := GetUserKey(context, email)
+// FindOutdatedGlukitScores returns up to limit GlukitScores calculated with a scoring version older than scoringVersion
+// whose upper bound is after the given time, ordered by upper bound
+func FindOutdatedGlukitScores(context context.Context, email string, scoringVersion int, after time.Time, limit int) (scores []model.GlukitScore, err error) {
+	log.Infof(context, "Looking for up to [%d] glukit scores of user [%s] older than scoring version [%d] after [%s]", limit, email, scoringVersion, after)
+	return repository.FindGlukitScoresBelowVersion(context, email, scoringVersion, after, limit)
+}
 
-	log.Infof(context, "Scanning for glukit scores with limit [%d], from [%s], to [%s]", *scanQuery.Limit, scanQuery.From, scanQuery.To)
+// ScanUserEmails returns up to limit emails of users that come after the given email, in ascending order
+func ScanUserEmails(context context.Context, after string, limit int) (emails []string, err error) {
+	return repository.ScanUserEmails(context, after, limit)
+}
 
-	query := datastore.NewQuery("GlukitScore").Ancestor(key)
-	if scanQuery.From != nil {
-		query = query.Filter("upperBound >=", *scanQuery.From)
-	}
-	if scanQuery.To != nil {
-		query = query.Filter("upperBound <=", *scanQuery.To)
-	}
-	if scanQuery.Limit != nil {
-		query = query.Limit(*scanQuery.Limit)
-	}
-	query = query.Order("-upperBound")
+// GetAlertSettings returns the alert settings of a user or ErrNoSuchEntity if the user never set any
+func GetAlertSettings(context context.Context, email string) (settings *model.AlertSettings, err error) {
+	return repository.GetAlertSettings(context, email)
+}
 
-	_, err = query.GetAll(context, &scores)
+// StoreAlertSettings replaces the alert settings of a user
+func StoreAlertSettings(context context.Context, email string, settings model.AlertSettings) (err error) {
+	settings.MonitorsMissingData = settings.HasMissingDataRule()
+	return repository.PutAlertSettings(context, email, settings)
+}
 
-	if err != datastore.Done {
-		util.Propagate(err)
+// FindEmailsMonitoringMissingData returns the emails of all users with a missing data alert rule
+func FindEmailsMonitoringMissingData(context context.Context) (emails []string, err error) {
+	return repository.FindEmailsMonitoringMissingData(context)
+}
+
+// HasAlertEvent returns true if an alert of the given rule was already recorded for the given time
+func HasAlertEvent(context context.Context, email string, rule model.AlertRule, eventTime time.Time) (exists bool, err error) {
+	_, err = repository.GetAlertEvent(context, email, rule, eventTime)
+	if err == ErrNoSuchEntity {
+		return false, nil
+	} else if err != nil {
+		return false, err
 	}
 
-	log.Infof(context, "Found [%d] glukit scores.", len(scores))
-	return scores, nil
+	return true, nil
 }
 
-// StoreA1CBatch stores a batch of A1C calculations. The array could be of any size. A large batch of A1CEstimates
-// will be internally split into multiple PutMultis.
-func StoreA1CBatch(context context.Context, userEmail string, a1cs []model.A1CEstimate) error {
-	parentKey := GetUserKey(context, userEmail)
+// StoreAlertEvent records an alert raised for a user
+func StoreAlertEvent(context context.Context, email string, event model.AlertEvent) (err error) {
+	log.Infof(context, "Recording alert [%s] at [%s] for user [%s]", event.Type, event.Time, email)
+	return repository.PutAlertEvent(context, email, event)
+}
 
-	totalBatchSize := float64(len(a1cs))
-	for chunkStartIndex := 0; chunkStartIndex < len(a1cs); chunkStartIndex = chunkStartIndex + GLUKIT_SCORE_PUT_MULTI_SIZE {
-		chunkEndIndex := int(math.Min(float64(chunkStartIndex+GLUKIT_SCORE_PUT_MULTI_SIZE), totalBatchSize))
-		a1cChunk := a1cs[chunkStartIndex:chunkEndIndex]
-		storeA1CChunk(context, parentKey, a1cChunk)
-	}
+// GetAlertEvents returns the alerts raised for a user between lowerBound and upperBound, in ascending order of time
+func GetAlertEvents(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (events []model.AlertEvent, err error) {
+	return repository.ScanAlertEvents(context, email, lowerBound, upperBound)
+}
 
-	return nil
+// StoreLabA1C stores a lab a1c result of a user, replacing any previous result for a sample taken at the same time
+func StoreLabA1C(context context.Context, userEmail string, lab model.LabA1C) error {
+	log.Debugf(context, "Storing lab a1c of user [%s] taken on [%s]", userEmail, lab.TakenOn)
+	return repository.PutLabA1C(context, userEmail, lab)
 }
 
-func storeA1CChunk(context context.Context, parentKey *datastore.Key, a1cChunk []model.A1CEstimate) (keys []*datastore.Key, err error) {
-	log.Debugf(context, "Storing chunk of [%d] a1c calculations", len(a1cChunk))
+// GetLabA1Cs returns the lab a1c results of a user matching the query parameters, most recent first
+func GetLabA1Cs(context context.Context, email string, scanQuery ScoreScanQuery) (labs []model.LabA1C, err error) {
+	log.Infof(context, "Scanning for lab a1cs with limit [%s], from [%s], to [%s]", formatLimit(scanQuery.Limit), scanQuery.From, scanQuery.To)
+	return repository.ScanLabA1Cs(context, email, scanQuery)
+}
 
-	elementKeys := make([]*datastore.Key, len(a1cChunk))
-	for i := range a1cChunk {
-		elementKeys[i] = datastore.NewKey(context, "A1CEstimate", "", a1cChunk[i].UpperBound.Unix(), parentKey)
-	}
+// StoreSensorSessions stores sensor sessions, replacing previous sessions of sensors inserted at the same time
+func StoreSensorSessions(context context.Context, userEmail string, sessions []model.SensorSession) error {
+	log.Debugf(context, "Storing batch of [%d] sensor sessions", len(sessions))
+	return repository.PutSensorSessions(context, userEmail, sessions)
+}
 
-	log.Infof(context, "Emitting a PutMulti with [%d] keys for all [%d] a1cs of chunk", len(elementKeys), len(a1cChunk))
-	keys, error := datastore.PutMulti(context, elementKeys, a1cChunk)
-	if error != nil {
-		log.Criticalf(context, "Error writing [%d] a1c calculations with keys [%s]: %v", len(elementKeys), elementKeys, error)
-		return nil, error
-	}
+// GetSensorSessions returns the sensor sessions of a user matching the query parameters on their insertion time, most
+// recent first
+func GetSensorSessions(context context.Context, email string, scanQuery ScoreScanQuery) (sessions []model.SensorSession, err error) {
+	log.Infof(context, "Scanning for sensor sessions with limit [%s], from [%s], to [%s]", formatLimit(scanQuery.Limit), scanQuery.From, scanQuery.To)
+	return repository.ScanSensorSessions(context, email, scanQuery)
+}
 
-	return elementKeys, nil
+// StoreTherapyEstimate stores the insulin sensitivity and carb ratio estimates of a user for a period
+func StoreTherapyEstimate(context context.Context, userEmail string, estimate model.TherapyEstimate) error {
+	log.Debugf(context, "Storing therapy estimate of user [%s] up to [%s]", userEmail, estimate.UpperBound)
+	return repository.PutTherapyEstimate(context, userEmail, estimate)
 }
 
-// GetA1CEstimates returns all a1c calculations for the given email address and matching the query parameters
-func GetA1CEstimates(context context.Context, email string, scanQuery ScoreScanQuery) (scores []model.A1CEstimate, err error) {
-	key := GetUserKey(context, email)
+// GetTherapyEstimates returns the therapy estimates of a user matching the query parameters, most recent first
+func GetTherapyEstimates(context context.Context, email string, scanQuery ScoreScanQuery) (estimates []model.TherapyEstimate, err error) {
+	log.Infof(context, "Scanning for therapy estimates with limit [%s], from [%s], to [%s]", formatLimit(scanQuery.Limit), scanQuery.From, scanQuery.To)
+	return repository.ScanTherapyEstimates(context, email, scanQuery)
+}
 
-	log.Infof(context, "Scanning for a1c estimates scores with limit [%d], from [%s], to [%s]", *scanQuery.Limit, scanQuery.From, scanQuery.To)
+// StoreMealImpacts stores the impacts of meals, replacing previous calculations for the same meals
+func StoreMealImpacts(context context.Context, userEmail string, impacts []model.MealImpact) error {
+	log.Debugf(context, "Storing batch of [%d] meal impacts", len(impacts))
+	return repository.PutMealImpacts(context, userEmail, impacts)
+}
 
-	query := datastore.NewQuery("A1CEstimate").Ancestor(key)
-	if scanQuery.From != nil {
-		query = query.Filter("upperBound >=", *scanQuery.From)
-	}
-	if scanQuery.To != nil {
-		query = query.Filter("upperBound <=", *scanQuery.To)
-	}
-	if scanQuery.Limit != nil {
-		query = query.Limit(*scanQuery.Limit)
+// GetMealImpacts returns the impacts of the meals of a user matching the query parameters, most recent first
+func GetMealImpacts(context context.Context, email string, scanQuery ScoreScanQuery) (impacts []model.MealImpact, err error) {
+	log.Infof(context, "Scanning for meal impacts with limit [%s], from [%s], to [%s]", formatLimit(scanQuery.Limit), scanQuery.From, scanQuery.To)
+	return repository.ScanMealImpacts(context, email, scanQuery)
+}
+
+// StoreInsights stores the insights detected for a user, replacing previous detections for the same period
+func StoreInsights(context context.Context, userEmail string, insights []model.Insight) error {
+	log.Debugf(context, "Storing batch of [%d] insights", len(insights))
+	return repository.PutInsights(context, userEmail, insights)
+}
+
+// GetInsights returns the insights of a user matching the query parameters, most recent first
+func GetInsights(context context.Context, email string, scanQuery ScoreScanQuery) (insights []model.Insight, err error) {
+	log.Infof(context, "Scanning for insights with limit [%s], from [%s], to [%s]", formatLimit(scanQuery.Limit), scanQuery.From, scanQuery.To)
+	return repository.ScanInsights(context, email, scanQuery)
+}
+
+// formatLimit formats the limit of a scan query which is unlimited if nil
+func formatLimit(limit *int) string {
+	if limit == nil {
+		return "none"
 	}
-	query = query.Order("-upperBound")
 
-	_, err = query.GetAll(context, &scores)
+	return strconv.Itoa(*limit)
+}
 
-	if err != datastore.Done {
+// StoreA1CBatch stores a batch of A1C calculations. The array could be of any size.
+func StoreA1CBatch(context context.Context, userEmail string, a1cs []model.A1CEstimate) error {
+	log.Debugf(context, "Storing batch of [%d] a1c calculations", len(a1cs))
+	return repository.PutA1CEstimates(context, userEmail, a1cs)
+}
+
+// GetA1CEstimates returns all a1c calculations for the given email address and matching the query parameters
+func GetA1CEstimates(context context.Context, email string, scanQuery ScoreScanQuery) (scores []model.A1CEstimate, err error) {
+	log.Infof(context, "Scanning for a1c estimates scores with limit [%s], from [%s], to [%s]", formatLimit(scanQuery.Limit), scanQuery.From, scanQuery.To)
+
+	scores, err = repository.ScanA1CEstimates(context, email, scanQuery)
+	if err != nil {
 		util.Propagate(err)
 	}
 
 	log.Infof(context, "Found [%d] a1c estimates.", len(scores))
 	return scores, nil
 }
+
+// GetBasalRates returns all BasalRate entries given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
+func GetBasalRates(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (basalRates []apimodel.BasalRate, err error) {
+	return GetElements(context, apimodel.BASAL_RATE_KIND, email, lowerBound, upperBound)
+}
+
+// GetTempBasals returns all TempBasal entries given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
+func GetTempBasals(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (tempBasals []apimodel.TempBasal, err error) {
+	return GetElements(context, apimodel.TEMP_BASAL_KIND, email, lowerBound, upperBound)
+}
+
+// GetPumpSuspends returns all PumpSuspend entries given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
+func GetPumpSuspends(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (pumpSuspends []apimodel.PumpSuspend, err error) {
+	return GetElements(context, apimodel.PUMP_SUSPEND_KIND, email, lowerBound, upperBound)
+}
+
+// GetExtendedBoluses returns all ExtendedBolus entries given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
+func GetExtendedBoluses(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (extendedBoluses []apimodel.ExtendedBolus, err error) {
+	return GetElements(context, apimodel.EXTENDED_BOLUS_KIND, email, lowerBound, upperBound)
+}
+
+// GetKetones returns all Ketone entries given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
+func GetKetones(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (ketones []apimodel.Ketone, err error) {
+	return GetElements(context, apimodel.KETONE_KIND, email, lowerBound, upperBound)
+}
+
+// GetBloodPressures returns all BloodPressure entries given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
+func GetBloodPressures(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (bloodPressures []apimodel.BloodPressure, err error) {
+	return GetElements(context, apimodel.BLOOD_PRESSURE_KIND, email, lowerBound, upperBound)
+}
+
+// GetWeights returns all Weight entries given a user's email address and the time boundaries. Not that the boundaries are both inclusive.
+func GetWeights(context context.Context, email string, lowerBound time.Time, upperBound time.Time) (weights []apimodel.Weight, err error) {
+	return GetElements(context, apimodel.WEIGHT_KIND, email, lowerBound, upperBound)
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/glukitio"
+	"context"
+)
+
+// daysStorer stores days of data of a user
+type daysStorer[D any] func(c context.Context, email string, days []D) (err error)
+
+type DataStoreBatchWriter[T apimodel.Timestamped, D apimodel.DayOf[T]] struct {
+	c         context.Context
+	email     string
+	kind      apimodel.Kind[T, D]
+	storeDays daysStorer[D]
+}
+
+// NewDataStoreBatchWriter creates a new BatchWriter that persists days of data of a kind to the store
+func NewDataStoreBatchWriter[T apimodel.Timestamped, D apimodel.DayOf[T]](context context.Context, email string, kind apimodel.Kind[T, D]) *DataStoreBatchWriter[T, D] {
+	return newDataStoreBatchWriter(context, email, kind, storeDaysOf(kind))
+}
+
+// NewDataStoreGlucoseReadBatchWriter creates a new BatchWriter that persists glucose reads to the store, keeping the
+// user's most recent read up to date
+func NewDataStoreGlucoseReadBatchWriter(context context.Context, email string) *DataStoreBatchWriter[apimodel.GlucoseRead, apimodel.DayOfGlucoseReads] {
+	return newDataStoreBatchWriter(context, email, apimodel.GLUCOSE_READ_KIND, StoreDaysOfReads)
+}
+
+func newDataStoreBatchWriter[T apimodel.Timestamped, D apimodel.DayOf[T]](context context.Context, email string, kind apimodel.Kind[T, D], storeDays daysStorer[D]) *DataStoreBatchWriter[T, D] {
+	w := new(DataStoreBatchWriter[T, D])
+	w.c = context
+	w.email = email
+	w.kind = kind
+	w.storeDays = storeDays
+	return w
+}
+
+// storeDaysOf returns the daysStorer that stores days of a kind with StoreDaysOf
+func storeDaysOf[T apimodel.Timestamped, D apimodel.DayOf[T]](kind apimodel.Kind[T, D]) daysStorer[D] {
+	return func(c context.Context, email string, days []D) error {
+		return StoreDaysOf(c, kind, email, days)
+	}
+}
+
+func (w *DataStoreBatchWriter[T, D]) WriteBatches(p []D) (glukitio.BatchWriter[T, D], error) {
+	if err := w.storeDays(w.c, w.email, p); err != nil {
+		return w, err
+	} else {
+		return w, nil
+	}
+}
+
+func (w *DataStoreBatchWriter[T, D]) WriteBatch(p []T) (glukitio.BatchWriter[T, D], error) {
+	days := make([]D, 1)
+	days[0] = w.kind.NewDay(p)
+	return w.WriteBatches(days)
+}
+
+func (w *DataStoreBatchWriter[T, D]) Flush() (glukitio.BatchWriter[T, D], error) {
+	return w, nil
+}
@@ -0,0 +1,145 @@
+package store_test
+
+import (
+	"context"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	. "github.com/alexandre-normand/glukit/app/store"
+	"testing"
+	"time"
+)
+
+var TEST_USER = "test@glukit.com"
+
+// setup initializes TEST_USER in a new memory repository. Callers restore the datastore repository when done.
+func setup(t *testing.T) (c context.Context, email string) {
+	return setupMemoryRepository(t, NewMemoryRepository()), TEST_USER
+}
+
+func TestSimpleWriteOfSingleGlucoseReadBatch(t *testing.T) {
+	c, email := setup(t)
+	defer SetRepository(NewDatastoreRepository())
+
+	r := make([]apimodel.GlucoseRead, 25)
+	ct, _ := time.Parse("02/01/2006 15:04", "18/04/2014 00:00")
+	for i := 0; i < 25; i++ {
+		readTime := ct.Add(time.Duration(i) * time.Hour)
+		r[i] = apimodel.GlucoseRead{apimodel.Time{apimodel.GetTimeMillis(readTime), "America/Los_Angeles"}, apimodel.MG_PER_DL, float32(i)}
+	}
+
+	w := NewDataStoreGlucoseReadBatchWriter(c, email)
+	if _, err := w.WriteBatch(r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSimpleWriteOfGlucoseReadBatches(t *testing.T) {
+	c, email := setup(t)
+	defer SetRepository(NewDatastoreRepository())
+
+	b := make([]apimodel.DayOfGlucoseReads, 10)
+
+	for i := 0; i < 10; i++ {
+		reads := make([]apimodel.GlucoseRead, 24)
+		ct, _ := time.Parse("02/01/2006 15:04", "18/04/2014 00:00")
+		for j := 0; j < 24; j++ {
+			readTime := ct.Add(time.Duration(j) * time.Hour)
+			reads[j] = apimodel.GlucoseRead{apimodel.Time{apimodel.GetTimeMillis(readTime), "America/Los_Angeles"}, apimodel.MG_PER_DL, float32(i*24 + j)}
+		}
+		b[i] = apimodel.NewDayOfGlucoseReads(reads)
+	}
+
+	w := NewDataStoreGlucoseReadBatchWriter(c, email)
+	if _, err := w.WriteBatches(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeOfBatches writes a day of elements of a kind and then ten days of them in a single call
+func writeOfBatches[T apimodel.Timestamped, D apimodel.DayOf[T]](t *testing.T, kind apimodel.Kind[T, D], newElement func(elementTime apimodel.Time, i int) T) {
+	c, email := setup(t)
+	defer SetRepository(NewDatastoreRepository())
+
+	ct, _ := time.Parse("02/01/2006 15:04", "18/04/2014 00:00")
+	elements := make([]T, 25)
+	for i := 0; i < 25; i++ {
+		elementTime := ct.Add(time.Duration(i) * time.Hour)
+		elements[i] = newElement(apimodel.Time{apimodel.GetTimeMillis(elementTime), "America/Los_Angeles"}, i)
+	}
+
+	w := NewDataStoreBatchWriter(c, email, kind)
+	if _, err := w.WriteBatch(elements); err != nil {
+		t.Fatal(err)
+	}
+
+	b := make([]D, 10)
+	for i := 0; i < 10; i++ {
+		dayElements := make([]T, 24)
+		for j := 0; j < 24; j++ {
+			elementTime := ct.Add(time.Duration(i*24+j) * time.Hour)
+			dayElements[j] = newElement(apimodel.Time{apimodel.GetTimeMillis(elementTime), "America/Los_Angeles"}, i*24+j)
+		}
+		b[i] = kind.NewDay(dayElements)
+	}
+
+	if _, err := w.WriteBatches(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSimpleWriteOfBatchesOfEveryKind(t *testing.T) {
+	t.Run(apimodel.CALIBRATION_READ_KIND.Name, func(t *testing.T) {
+		writeOfBatches(t, apimodel.CALIBRATION_READ_KIND, func(calibrationTime apimodel.Time, i int) apimodel.CalibrationRead {
+			return apimodel.CalibrationRead{Time: calibrationTime, Unit: apimodel.MG_PER_DL, Value: 75}
+		})
+	})
+	t.Run(apimodel.INJECTION_KIND.Name, func(t *testing.T) {
+		writeOfBatches(t, apimodel.INJECTION_KIND, func(injectionTime apimodel.Time, i int) apimodel.Injection {
+			return apimodel.Injection{Time: injectionTime, Units: float32(i), InsulinName: "Levemir", InsulinType: "Basal"}
+		})
+	})
+	t.Run(apimodel.MEAL_KIND.Name, func(t *testing.T) {
+		writeOfBatches(t, apimodel.MEAL_KIND, func(mealTime apimodel.Time, i int) apimodel.Meal {
+			return apimodel.Meal{Time: mealTime, Carbohydrates: float32(i), Proteins: float32(i + 1), Fat: float32(i + 2), SaturatedFat: float32(i + 3)}
+		})
+	})
+	t.Run(apimodel.EXERCISE_KIND.Name, func(t *testing.T) {
+		writeOfBatches(t, apimodel.EXERCISE_KIND, func(exerciseTime apimodel.Time, i int) apimodel.Exercise {
+			return apimodel.Exercise{Time: exerciseTime, DurationMinutes: i, Intensity: "Light", Description: "details"}
+		})
+	})
+	t.Run(apimodel.BASAL_RATE_KIND.Name, func(t *testing.T) {
+		writeOfBatches(t, apimodel.BASAL_RATE_KIND, func(basalRateTime apimodel.Time, i int) apimodel.BasalRate {
+			return apimodel.BasalRate{Time: basalRateTime, UnitsPerHour: float32(i), ScheduleName: "Standard"}
+		})
+	})
+	t.Run(apimodel.TEMP_BASAL_KIND.Name, func(t *testing.T) {
+		writeOfBatches(t, apimodel.TEMP_BASAL_KIND, func(tempBasalTime apimodel.Time, i int) apimodel.TempBasal {
+			return apimodel.TempBasal{Time: tempBasalTime, DurationMinutes: 30, UnitsPerHour: float32(i)}
+		})
+	})
+	t.Run(apimodel.PUMP_SUSPEND_KIND.Name, func(t *testing.T) {
+		writeOfBatches(t, apimodel.PUMP_SUSPEND_KIND, func(suspendTime apimodel.Time, i int) apimodel.PumpSuspend {
+			return apimodel.PumpSuspend{Time: suspendTime, DurationMinutes: 30, Reason: apimodel.MANUAL_SUSPEND_REASON}
+		})
+	})
+	t.Run(apimodel.EXTENDED_BOLUS_KIND.Name, func(t *testing.T) {
+		writeOfBatches(t, apimodel.EXTENDED_BOLUS_KIND, func(bolusTime apimodel.Time, i int) apimodel.ExtendedBolus {
+			return apimodel.ExtendedBolus{Time: bolusTime, DurationMinutes: 120, ExtendedUnits: float32(i)}
+		})
+	})
+	t.Run(apimodel.KETONE_KIND.Name, func(t *testing.T) {
+		writeOfBatches(t, apimodel.KETONE_KIND, func(ketoneTime apimodel.Time, i int) apimodel.Ketone {
+			return apimodel.Ketone{Time: ketoneTime, Unit: apimodel.KETONE_MMOL_PER_L, Value: float32(i) / 10}
+		})
+	})
+	t.Run(apimodel.BLOOD_PRESSURE_KIND.Name, func(t *testing.T) {
+		writeOfBatches(t, apimodel.BLOOD_PRESSURE_KIND, func(pressureTime apimodel.Time, i int) apimodel.BloodPressure {
+			return apimodel.BloodPressure{Time: pressureTime, Systolic: float32(110 + i), Diastolic: float32(70 + i), Pulse: 60}
+		})
+	})
+	t.Run(apimodel.WEIGHT_KIND.Name, func(t *testing.T) {
+		writeOfBatches(t, apimodel.WEIGHT_KIND, func(weightTime apimodel.Time, i int) apimodel.Weight {
+			return apimodel.Weight{Time: weightTime, Unit: apimodel.KILOGRAMS, Value: float32(70 + i)}
+		})
+	})
+}
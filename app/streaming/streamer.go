@@ -0,0 +1,114 @@
+package streaming
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/container"
+	"github.com/alexandre-normand/glukit/app/glukitio"
+	"time"
+)
+
+const (
+	BUFFER_SIZE = 86400
+)
+
+// Streamer groups a stream of elements of a kind in batches of a duration (usually a day) that are written to the
+// underlying glukitio.BatchWriter
+type Streamer[T apimodel.Timestamped, D apimodel.DayOf[T]] struct {
+	head      *container.ImmutableList
+	startTime *time.Time
+	wr        glukitio.BatchWriter[T, D]
+	d         time.Duration
+}
+
+// NewStreamerDuration returns a new Streamer whose buffer has the specified duration.
+func NewStreamerDuration[T apimodel.Timestamped, D apimodel.DayOf[T]](wr glukitio.BatchWriter[T, D], bufferDuration time.Duration) *Streamer[T, D] {
+	return newStreamerDuration(nil, nil, wr, bufferDuration)
+}
+
+func newStreamerDuration[T apimodel.Timestamped, D apimodel.DayOf[T]](head *container.ImmutableList, startTime *time.Time, wr glukitio.BatchWriter[T, D], bufferDuration time.Duration) *Streamer[T, D] {
+	w := new(Streamer[T, D])
+	w.head = head
+	w.startTime = startTime
+	w.wr = wr
+	w.d = bufferDuration
+
+	return w
+}
+
+// Write writes a single element into the buffer.
+func (b *Streamer[T, D]) Write(c T) (s *Streamer[T, D], err error) {
+	return b.WriteAll([]T{c})
+}
+
+// WriteAll writes the contents of p into the buffer.
+// It returns the streamer to use for subsequent writes.
+// If the write is short, it also returns an error explaining
+// why. p must be sorted by time (oldest to most recent).
+func (b *Streamer[T, D]) WriteAll(p []T) (s *Streamer[T, D], err error) {
+	s = newStreamerDuration(b.head, b.startTime, b.wr, b.d)
+
+	for i := range p {
+		c := p[i]
+		t := c.GetTime()
+		truncatedTime := t.Truncate(s.d)
+
+		if s.head == nil {
+			s = newStreamerDuration(container.NewImmutableList(nil, c), &truncatedTime, s.wr, s.d)
+		} else if t.Sub(*s.startTime) >= s.d {
+			s, err = s.Flush()
+			if err != nil {
+				return s, err
+			}
+			s = newStreamerDuration(container.NewImmutableList(nil, c), &truncatedTime, s.wr, s.d)
+		} else {
+			s = newStreamerDuration(container.NewImmutableList(s.head, c), s.startTime, s.wr, s.d)
+		}
+	}
+
+	return s, err
+}
+
+// Flush writes any buffered data to the underlying glukitio.Writer as a batch.
+func (b *Streamer[T, D]) Flush() (s *Streamer[T, D], err error) {
+	r, size := b.head.ReverseList()
+	batch := ListToArrayOfElements[T](r, size)
+
+	if len(batch) > 0 {
+		innerWriter, err := b.wr.WriteBatch(batch)
+		if err != nil {
+			return nil, err
+		} else {
+			return newStreamerDuration(nil, nil, innerWriter, b.d), nil
+		}
+	}
+
+	return newStreamerDuration(nil, nil, b.wr, b.d), nil
+}
+
+// ListToArrayOfElements returns the size elements held in a list
+func ListToArrayOfElements[T any](head *container.ImmutableList, size int) []T {
+	r := make([]T, size)
+	cursor := head
+	for i := 0; i < size; i++ {
+		r[i] = cursor.Value().(T)
+		cursor = cursor.Next()
+	}
+
+	return r
+}
+
+// Close flushes the buffer and the inner writer to effectively ensure nothing is left
+// unwritten
+func (b *Streamer[T, D]) Close() (s *Streamer[T, D], err error) {
+	g, err := b.Flush()
+	if err != nil {
+		return g, err
+	}
+
+	innerWriter, err := g.wr.Flush()
+	if err != nil {
+		return newStreamerDuration(g.head, g.startTime, innerWriter, b.d), err
+	}
+
+	return newStreamerDuration(nil, nil, innerWriter, g.d), nil
+}
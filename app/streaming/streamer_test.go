@@ -0,0 +1,368 @@
+package streaming_test
+
+import (
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/bufio"
+	"github.com/alexandre-normand/glukit/app/glukitio"
+	. "github.com/alexandre-normand/glukit/app/streaming"
+	"log"
+	"testing"
+	"time"
+)
+
+type writerState struct {
+	total      int
+	batchCount int
+	writeCount int
+	batches    map[int64]int
+}
+
+type statsWriter[T apimodel.Timestamped, D apimodel.DayOf[T]] struct {
+	kind  apimodel.Kind[T, D]
+	state *writerState
+}
+
+func NewWriterState() *writerState {
+	s := new(writerState)
+	s.batches = make(map[int64]int)
+
+	return s
+}
+
+func NewStatsWriter[T apimodel.Timestamped, D apimodel.DayOf[T]](kind apimodel.Kind[T, D], s *writerState) *statsWriter[T, D] {
+	w := new(statsWriter[T, D])
+	w.kind = kind
+	w.state = s
+
+	return w
+}
+
+func (w *statsWriter[T, D]) WriteBatch(p []T) (glukitio.BatchWriter[T, D], error) {
+	log.Printf("WriteBatch of [%s] with [%d] elements: %v", w.kind.Name, len(p), p)
+
+	return w.WriteBatches([]D{w.kind.NewDay(p)})
+}
+
+func (w *statsWriter[T, D]) WriteBatches(p []D) (glukitio.BatchWriter[T, D], error) {
+	log.Printf("WriteBatches of [%s] with [%d] batches: %v", w.kind.Name, len(p), p)
+	for _, dayOfData := range p {
+		elements := dayOfData.GetElements()
+		log.Printf("Persisting batch with start date of [%v]", elements[0].GetTime())
+		w.state.total += len(elements)
+		w.state.batches[elements[0].GetTime().Unix()] = len(elements)
+	}
+
+	log.Printf("WriteBatches with total of %d", w.state.total)
+	w.state.batchCount += len(p)
+	w.state.writeCount++
+
+	return w, nil
+}
+
+func (w *statsWriter[T, D]) Flush() (glukitio.BatchWriter[T, D], error) {
+	return w, nil
+}
+
+// kindTest runs the streamer tests for a kind of data
+type kindTest interface {
+	name() string
+	writeOfDayBatch(t *testing.T)
+	writeOfDayBatchesInSingleCall(t *testing.T)
+	writeOfHourlyBatch(t *testing.T)
+	writeOfMultipleBatches(t *testing.T)
+	streamerWithBufferedIO(t *testing.T)
+	batchBoundaries(t *testing.T)
+}
+
+type kindTests[T apimodel.Timestamped, D apimodel.DayOf[T]] struct {
+	kind       apimodel.Kind[T, D]
+	newElement func(elementTime apimodel.Time, i int) T
+}
+
+func newKindTests[T apimodel.Timestamped, D apimodel.DayOf[T]](kind apimodel.Kind[T, D], newElement func(elementTime apimodel.Time, i int) T) kindTest {
+	return kindTests[T, D]{kind: kind, newElement: newElement}
+}
+
+func newGlucoseRead(readTime apimodel.Time, i int) apimodel.GlucoseRead {
+	return apimodel.GlucoseRead{Time: readTime, Unit: apimodel.MG_PER_DL, Value: float32(i)}
+}
+
+var KIND_TESTS = []kindTest{
+	newKindTests(apimodel.GLUCOSE_READ_KIND, newGlucoseRead),
+	newKindTests(apimodel.CALIBRATION_READ_KIND, func(calibrationTime apimodel.Time, i int) apimodel.CalibrationRead {
+		return apimodel.CalibrationRead{Time: calibrationTime, Unit: apimodel.MG_PER_DL, Value: 75}
+	}),
+	newKindTests(apimodel.INJECTION_KIND, func(injectionTime apimodel.Time, i int) apimodel.Injection {
+		return apimodel.Injection{Time: injectionTime, Units: float32(i), InsulinName: "Humalog", InsulinType: "Bolus"}
+	}),
+	newKindTests(apimodel.MEAL_KIND, func(mealTime apimodel.Time, i int) apimodel.Meal {
+		return apimodel.Meal{Time: mealTime, Carbohydrates: float32(i), Proteins: float32(i + 1), Fat: float32(i + 2), SaturatedFat: float32(i + 3)}
+	}),
+	newKindTests(apimodel.EXERCISE_KIND, func(exerciseTime apimodel.Time, i int) apimodel.Exercise {
+		return apimodel.Exercise{Time: exerciseTime, DurationMinutes: i, Intensity: "Light", Description: "details"}
+	}),
+	newKindTests(apimodel.BASAL_RATE_KIND, func(basalRateTime apimodel.Time, i int) apimodel.BasalRate {
+		return apimodel.BasalRate{Time: basalRateTime, UnitsPerHour: float32(i), ScheduleName: "Standard"}
+	}),
+	newKindTests(apimodel.TEMP_BASAL_KIND, func(tempBasalTime apimodel.Time, i int) apimodel.TempBasal {
+		return apimodel.TempBasal{Time: tempBasalTime, DurationMinutes: 30, UnitsPerHour: float32(i)}
+	}),
+	newKindTests(apimodel.PUMP_SUSPEND_KIND, func(suspendTime apimodel.Time, i int) apimodel.PumpSuspend {
+		return apimodel.PumpSuspend{Time: suspendTime, DurationMinutes: 30, Reason: apimodel.MANUAL_SUSPEND_REASON}
+	}),
+	newKindTests(apimodel.EXTENDED_BOLUS_KIND, func(bolusTime apimodel.Time, i int) apimodel.ExtendedBolus {
+		return apimodel.ExtendedBolus{Time: bolusTime, DurationMinutes: 120, ExtendedUnits: float32(i)}
+	}),
+	newKindTests(apimodel.KETONE_KIND, func(ketoneTime apimodel.Time, i int) apimodel.Ketone {
+		return apimodel.Ketone{Time: ketoneTime, Unit: apimodel.KETONE_MMOL_PER_L, Value: float32(i) / 10}
+	}),
+	newKindTests(apimodel.BLOOD_PRESSURE_KIND, func(pressureTime apimodel.Time, i int) apimodel.BloodPressure {
+		return apimodel.BloodPressure{Time: pressureTime, Systolic: float32(110 + i), Diastolic: float32(70 + i), Pulse: 60}
+	}),
+	newKindTests(apimodel.WEIGHT_KIND, func(weightTime apimodel.Time, i int) apimodel.Weight {
+		return apimodel.Weight{Time: weightTime, Unit: apimodel.KILOGRAMS, Value: float32(70 + i)}
+	}),
+}
+
+func (k kindTests[T, D]) name() string {
+	return k.kind.Name
+}
+
+func (k kindTests[T, D]) element(elementTime time.Time, i int) T {
+	return k.newElement(apimodel.Time{apimodel.GetTimeMillis(elementTime), "America/Montreal"}, i)
+}
+
+func (k kindTests[T, D]) writeOfDayBatch(t *testing.T) {
+	state := NewWriterState()
+	w := NewStreamerDuration(NewStatsWriter(k.kind, state), apimodel.DAY_OF_DATA_DURATION)
+
+	ct, _ := time.Parse("02/01/2006 15:04", "18/04/2014 00:00")
+
+	for i := 0; i < 25; i++ {
+		w, _ = w.Write(k.element(ct.Add(time.Duration(i)*time.Hour), i))
+	}
+
+	if state.total != 24 {
+		t.Errorf("TestWriteOfDayBatch failed: got a total of %d but expected %d", state.total, 24)
+	}
+
+	if state.batchCount != 1 {
+		t.Errorf("TestWriteOfDayBatch failed: got a batchCount of %d but expected %d", state.batchCount, 1)
+	}
+
+	if state.writeCount != 1 {
+		t.Errorf("TestWriteOfDayBatch failed: got a writeCount of %d but expected %d", state.writeCount, 1)
+	}
+}
+
+func (k kindTests[T, D]) writeOfDayBatchesInSingleCall(t *testing.T) {
+	state := NewWriterState()
+	w := NewStreamerDuration(NewStatsWriter(k.kind, state), apimodel.DAY_OF_DATA_DURATION)
+
+	ct, _ := time.Parse("02/01/2006 15:04", "18/04/2014 00:00")
+
+	elements := make([]T, 25)
+
+	for i := 0; i < 25; i++ {
+		elements[i] = k.element(ct.Add(time.Duration(i)*time.Hour), i)
+	}
+
+	w, _ = w.WriteAll(elements)
+	w.Flush()
+
+	if state.total != 25 {
+		t.Errorf("TestWriteOfDayBatchesInSingleCall failed: got a total of %d but expected %d", state.total, 25)
+	}
+
+	if state.batchCount != 2 {
+		t.Errorf("TestWriteOfDayBatchesInSingleCall failed: got a batchCount of %d but expected %d", state.batchCount, 2)
+	}
+
+	if state.writeCount != 2 {
+		t.Errorf("TestWriteOfDayBatchesInSingleCall failed: got a writeCount of %d but expected %d", state.writeCount, 2)
+	}
+}
+
+func (k kindTests[T, D]) writeOfHourlyBatch(t *testing.T) {
+	state := NewWriterState()
+	w := NewStreamerDuration(NewStatsWriter(k.kind, state), time.Hour*1)
+
+	ct, _ := time.Parse("02/01/2006 15:04", "18/04/2014 00:00")
+
+	for i := 0; i < 13; i++ {
+		w, _ = w.Write(k.element(ct.Add(time.Duration(i*5)*time.Minute), i))
+	}
+
+	if state.total != 12 {
+		t.Errorf("TestWriteOfHourlyBatch failed: got a total of %d but expected %d", state.total, 12)
+	}
+
+	if state.batchCount != 1 {
+		t.Errorf("TestWriteOfHourlyBatch failed: got a batchCount of %d but expected %d", state.batchCount, 1)
+	}
+
+	if state.writeCount != 1 {
+		t.Errorf("TestWriteOfHourlyBatch failed: got a writeCount of %d but expected %d", state.writeCount, 1)
+	}
+
+	// Flushing should trigger the trailing element to be written
+	w, _ = w.Flush()
+
+	if state.total != 13 {
+		t.Errorf("TestWriteOfHourlyBatch failed: got a total of %d but expected %d", state.total, 13)
+	}
+
+	if state.batchCount != 2 {
+		t.Errorf("TestWriteOfHourlyBatch failed: got a batchCount of %d but expected %d", state.batchCount, 2)
+	}
+
+	if state.writeCount != 2 {
+		t.Errorf("TestWriteOfHourlyBatch failed: got a writeCount of %d but expected %d", state.writeCount, 2)
+	}
+}
+
+func (k kindTests[T, D]) writeOfMultipleBatches(t *testing.T) {
+	state := NewWriterState()
+	w := NewStreamerDuration(NewStatsWriter(k.kind, state), time.Hour*1)
+
+	ct, _ := time.Parse("02/01/2006 15:04", "18/04/2014 00:00")
+
+	for i := 0; i < 25; i++ {
+		w, _ = w.Write(k.element(ct.Add(time.Duration(i*5)*time.Minute), i))
+	}
+
+	if state.total != 24 {
+		t.Errorf("TestWriteOfMultipleBatches failed: got a total of %d but expected %d", state.total, 24)
+	}
+
+	if state.batchCount != 2 {
+		t.Errorf("TestWriteOfMultipleBatches failed: got a batchCount of %d but expected %d", state.batchCount, 2)
+	}
+
+	if state.writeCount != 2 {
+		t.Errorf("TestWriteOfMultipleBatches failed: got a writeCount of %d but expected %d", state.writeCount, 2)
+	}
+
+	// Flushing should trigger the trailing element to be written
+	w, _ = w.Flush()
+
+	if state.total != 25 {
+		t.Errorf("TestWriteOfMultipleBatches failed: got a total of %d but expected %d", state.total, 25)
+	}
+
+	if state.batchCount != 3 {
+		t.Errorf("TestWriteOfMultipleBatches failed: got a batchCount of %d but expected %d", state.batchCount, 3)
+	}
+
+	if state.writeCount != 3 {
+		t.Errorf("TestWriteOfMultipleBatches failed: got a writeCount of %d but expected %d", state.writeCount, 3)
+	}
+}
+
+func (k kindTests[T, D]) streamerWithBufferedIO(t *testing.T) {
+	state := NewWriterState()
+	bufferedWriter := bufio.NewWriterSize(k.kind, NewStatsWriter(k.kind, state), 2)
+	w := NewStreamerDuration(bufferedWriter, apimodel.DAY_OF_DATA_DURATION)
+
+	ct, _ := time.Parse("02/01/2006 15:04", "18/04/2014 00:00")
+
+	for b := 0; b < 3; b++ {
+		for i := 0; i < 48; i++ {
+			w, _ = w.Write(k.element(ct.Add(time.Duration(b*48+i)*30*time.Minute), b*48+i))
+		}
+	}
+
+	w.Close()
+
+	for day := 0; day < 3; day++ {
+		batchTime := ct.Add(time.Duration(day*24) * time.Hour)
+		if _, ok := state.batches[batchTime.Unix()]; !ok {
+			t.Errorf("TestStreamerWithBufferedIO test failed: could not find a batch starting with a time of [%v]/ts[%d] in batches: [%v]", batchTime, batchTime.Unix(), state.batches)
+		}
+	}
+}
+
+func (k kindTests[T, D]) batchBoundaries(t *testing.T) {
+	state := NewWriterState()
+	bufferedWriter := bufio.NewWriterSize(k.kind, NewStatsWriter(k.kind, state), 2)
+	w := NewStreamerDuration(bufferedWriter, apimodel.DAY_OF_DATA_DURATION)
+
+	ct, _ := time.Parse("02/01/2006 15:04", "18/04/2014 01:00")
+
+	for b := 0; b < 3; b++ {
+		for i := 0; i < 48; i++ {
+			w, _ = w.Write(k.element(ct.Add(time.Duration(b*48+i)*30*time.Minute), b*48+i))
+		}
+	}
+
+	w.Close()
+
+	// Fist batch still starts with the first element which isn't a day boundary because we're just keeping track of
+	// an array of elements and therefore will have the first element potentially not line up with the data
+	if _, ok := state.batches[ct.Unix()]; !ok {
+		t.Errorf("TestBatchBoundaries test failed: could not find first batch starting with a time of [%v]/ts[%d] in batches: [%v]", ct, ct.Unix(), state.batches)
+	}
+
+	// Following batches start at the truncated day boundary because we have a matching element that starts with it
+	for _, day := range []string{"19/04/2014 00:00", "20/04/2014 00:00", "21/04/2014 00:00"} {
+		batchTime, _ := time.Parse("02/01/2006 15:04", day)
+		if _, ok := state.batches[batchTime.Unix()]; !ok {
+			t.Errorf("TestBatchBoundaries test failed: could not find a batch starting with a time of [%v]/ts[%d] in batches: [%v]", batchTime, batchTime.Unix(), state.batches)
+		}
+	}
+}
+
+func TestWriteOfDayBatch(t *testing.T) {
+	for _, k := range KIND_TESTS {
+		t.Run(k.name(), k.writeOfDayBatch)
+	}
+}
+
+func TestWriteOfDayBatchesInSingleCall(t *testing.T) {
+	for _, k := range KIND_TESTS {
+		t.Run(k.name(), k.writeOfDayBatchesInSingleCall)
+	}
+}
+
+func TestWriteOfHourlyBatch(t *testing.T) {
+	for _, k := range KIND_TESTS {
+		t.Run(k.name(), k.writeOfHourlyBatch)
+	}
+}
+
+func TestWriteOfMultipleBatches(t *testing.T) {
+	for _, k := range KIND_TESTS {
+		t.Run(k.name(), k.writeOfMultipleBatches)
+	}
+}
+
+func TestStreamerWithBufferedIO(t *testing.T) {
+	for _, k := range KIND_TESTS {
+		t.Run(k.name(), k.streamerWithBufferedIO)
+	}
+}
+
+func TestBatchBoundaries(t *testing.T) {
+	for _, k := range KIND_TESTS {
+		t.Run(k.name(), k.batchBoundaries)
+	}
+}
+
+func BenchmarkStreamerWithBufferedIO(b *testing.B) {
+	for n := 0; n < b.N; n++ {
+		state := NewWriterState()
+		bufferedWriter := bufio.NewWriterSize(apimodel.GLUCOSE_READ_KIND, NewStatsWriter(apimodel.GLUCOSE_READ_KIND, state), 2)
+		w := NewStreamerDuration(bufferedWriter, apimodel.DAY_OF_DATA_DURATION)
+
+		ct, _ := time.Parse("02/01/2006 15:04", "18/04/2014 00:00")
+
+		for j := 0; j < 3; j++ {
+			for i := 0; i < 288; i++ {
+				readTime := ct.Add(time.Duration(j*288+i) * 5 * time.Minute)
+				w, _ = w.Write(newGlucoseRead(apimodel.Time{apimodel.GetTimeMillis(readTime), "America/Montreal"}, j*288+i))
+			}
+		}
+
+		w.Close()
+	}
+}
@@ -0,0 +1,125 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/log"
+	"sync"
+)
+
+// InProcessRunner is a Runner that executes tasks in the current process. Each queue has its own worker that runs
+// tasks one at a time in the order they were added. Queues are unbounded so a task can safely schedule its next
+// chunk on its own queue.
+type InProcessRunner struct {
+	mutex   sync.Mutex
+	idle    *sync.Cond
+	pending int
+	queues  map[string]*inProcessQueue
+	closed  bool
+}
+
+type inProcessQueue struct {
+	name  string
+	tasks []inProcessTask
+	ready *sync.Cond
+}
+
+type inProcessTask struct {
+	f    *Function
+	args []interface{}
+}
+
+// NewInProcessRunner returns a new InProcessRunner with one worker for each of the given queue names
+func NewInProcessRunner(queueNames ...string) *InProcessRunner {
+	r := &InProcessRunner{queues: make(map[string]*inProcessQueue)}
+	r.idle = sync.NewCond(&r.mutex)
+
+	for _, name := range queueNames {
+		queue := &inProcessQueue{name: name, ready: sync.NewCond(&r.mutex)}
+		r.queues[name] = queue
+		go r.work(queue)
+	}
+
+	return r
+}
+
+func (r *InProcessRunner) Add(context context.Context, f *Function, queueName string, args ...interface{}) (err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	queue, found := r.queues[queueName]
+	if !found {
+		return ErrUnknownQueue
+	}
+	if r.closed {
+		return fmt.Errorf("tasks: runner is closed, can't add [%s] to queue [%s]", f.name, queueName)
+	}
+
+	queue.tasks = append(queue.tasks, inProcessTask{f, args})
+	r.pending++
+	queue.ready.Signal()
+
+	return nil
+}
+
+// Wait blocks until all queues are empty and no task is running, including tasks added by other tasks
+func (r *InProcessRunner) Wait() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for r.pending > 0 {
+		r.idle.Wait()
+	}
+}
+
+// Close stops all workers once their queue is drained. Tasks can't be added after Close.
+func (r *InProcessRunner) Close() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.closed = true
+	for _, queue := range r.queues {
+		queue.ready.Broadcast()
+	}
+}
+
+func (r *InProcessRunner) work(queue *inProcessQueue) {
+	for {
+		r.mutex.Lock()
+		for len(queue.tasks) == 0 && !r.closed {
+			queue.ready.Wait()
+		}
+		if len(queue.tasks) == 0 {
+			r.mutex.Unlock()
+			return
+		}
+
+		task := queue.tasks[0]
+		queue.tasks = queue.tasks[1:]
+		r.mutex.Unlock()
+
+		r.run(queue.name, task)
+
+		r.mutex.Lock()
+		r.pending--
+		if r.pending == 0 {
+			r.idle.Broadcast()
+		}
+		r.mutex.Unlock()
+	}
+}
+
+// run executes a single task. Tasks run detached from the request that scheduled them so they get a fresh context.
+// A panicking task is logged and dropped, it doesn't bring down the worker.
+func (r *InProcessRunner) run(queueName string, task inProcessTask) {
+	context := context.Background()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Errorf(context, "Task [%s] on queue [%s] panicked: %v", task.f.name, queueName, recovered)
+		}
+	}()
+
+	if err := task.f.Call(context, task.args...); err != nil {
+		log.Errorf(context, "Task [%s] on queue [%s] failed: %v", task.f.name, queueName, err)
+	}
+}
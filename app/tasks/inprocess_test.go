@@ -0,0 +1,80 @@
+package tasks_test
+
+import (
+	"context"
+	"errors"
+	. "github.com/alexandre-normand/glukit/app/tasks"
+	"sync"
+	"testing"
+)
+
+const TEST_QUEUE = "test-queue"
+
+var callsMutex sync.Mutex
+var calls []int
+
+var countdown *Function
+
+func init() {
+	countdown = Func("countdown", func(context context.Context, remaining int) {
+		callsMutex.Lock()
+		calls = append(calls, remaining)
+		callsMutex.Unlock()
+
+		if remaining > 0 {
+			countdown.Add(context, TEST_QUEUE, remaining-1)
+		}
+	})
+}
+
+var failing = Func("failing", func(context context.Context, message string) error {
+	return errors.New(message)
+})
+
+func TestInProcessRunnerRunsChainedTasks(t *testing.T) {
+	r := NewInProcessRunner(TEST_QUEUE)
+	defer r.Close()
+	SetRunner(r)
+	defer SetRunner(AppEngineRunner{})
+
+	calls = nil
+	if err := countdown.Add(context.Background(), TEST_QUEUE, 3); err != nil {
+		t.Fatal(err)
+	}
+	r.Wait()
+
+	expected := []int{3, 2, 1, 0}
+	if len(calls) != len(expected) {
+		t.Fatalf("Expected calls [%v] but got [%v]", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("Expected call [%d] at index [%d] but got [%d]", expected[i], i, calls[i])
+		}
+	}
+}
+
+func TestInProcessRunnerUnknownQueue(t *testing.T) {
+	r := NewInProcessRunner(TEST_QUEUE)
+	defer r.Close()
+
+	if err := r.Add(context.Background(), countdown, "unknown", 0); err != ErrUnknownQueue {
+		t.Errorf("Expected [%v] but got [%v]", ErrUnknownQueue, err)
+	}
+}
+
+func TestCallReturnsFunctionError(t *testing.T) {
+	if err := failing.Call(context.Background(), "boom"); err == nil || err.Error() != "boom" {
+		t.Errorf("Expected error [boom] but got [%v]", err)
+	}
+}
+
+func TestCallWithWrongArguments(t *testing.T) {
+	if err := failing.Call(context.Background(), 42); err == nil {
+		t.Errorf("Expected an error calling with an argument of the wrong type")
+	}
+
+	if err := failing.Call(context.Background()); err == nil {
+		t.Errorf("Expected an error calling with a missing argument")
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Package tasks schedules the deferred execution of functions on named queues. On App Engine, a Function is backed
+by a delay.Function and added to the task queue. Outside of App Engine (standalone server, tests), an InProcessRunner
+runs the same functions on in-process queues.
+*/
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/appengine/delay"
+	"google.golang.org/appengine/taskqueue"
+	"reflect"
+)
+
+var (
+	// ErrUnknownQueue is returned when adding a task to a queue that the Runner doesn't know about
+	ErrUnknownQueue = errors.New("tasks: unknown queue")
+
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Function is a function that can be scheduled for deferred execution. Like delay.Func, the function's first
+// argument must be a context.Context.
+type Function struct {
+	name    string
+	fn      reflect.Value
+	delayed *delay.Function
+}
+
+// Func declares a new Function. As with delay.Func, this must be called at program initialization time.
+func Func(name string, fn interface{}) *Function {
+	f := &Function{name: name, fn: reflect.ValueOf(fn)}
+
+	t := f.fn.Type()
+	if t.Kind() != reflect.Func || t.NumIn() == 0 || t.In(0) != contextType {
+		panic(fmt.Sprintf("tasks: function [%s] must be a func with a first argument of type context.Context", name))
+	}
+	f.delayed = delay.Func(name, fn)
+
+	return f
+}
+
+// Name returns the name under which the Function was declared
+func (f *Function) Name() string {
+	return f.name
+}
+
+// Add schedules the execution of the function with the given arguments on the named queue using the active Runner
+func (f *Function) Add(context context.Context, queueName string, args ...interface{}) (err error) {
+	return runner.Add(context, f, queueName, args...)
+}
+
+// Call invokes the function right away with the given context and arguments. If the function returns an error as its
+// last value, it is returned.
+func (f *Function) Call(context context.Context, args ...interface{}) (err error) {
+	t := f.fn.Type()
+	if len(args) != t.NumIn()-1 {
+		return fmt.Errorf("tasks: function [%s] takes [%d] arguments, got [%d]", f.name, t.NumIn()-1, len(args))
+	}
+
+	in := make([]reflect.Value, len(args)+1)
+	in[0] = reflect.ValueOf(context)
+	for i, arg := range args {
+		argType := t.In(i + 1)
+		if arg == nil {
+			in[i+1] = reflect.Zero(argType)
+			continue
+		}
+
+		value := reflect.ValueOf(arg)
+		if !value.Type().AssignableTo(argType) {
+			return fmt.Errorf("tasks: argument [%d] of function [%s] is of type [%s], expected [%s]", i, f.name, value.Type(), argType)
+		}
+		in[i+1] = value
+	}
+
+	out := f.fn.Call(in)
+	if len(out) > 0 && t.Out(len(out)-1) == errorType && !out[len(out)-1].IsNil() {
+		return out[len(out)-1].Interface().(error)
+	}
+
+	return nil
+}
+
+// Runner schedules the execution of Functions on named queues
+type Runner interface {
+	Add(context context.Context, f *Function, queueName string, args ...interface{}) (err error)
+}
+
+// AppEngineRunner is the Runner that adds tasks to the App Engine task queue
+type AppEngineRunner struct {
+}
+
+func (r AppEngineRunner) Add(context context.Context, f *Function, queueName string, args ...interface{}) (err error) {
+	task, err := f.delayed.Task(args...)
+	if err != nil {
+		return err
+	}
+
+	_, err = taskqueue.Add(context, task, queueName)
+	return err
+}
+
+// The active runner, App Engine's task queue unless configured otherwise
+var runner Runner = AppEngineRunner{}
+
+// SetRunner sets the Runner used to schedule all Functions. This is meant to be called once, during initialization,
+// before any request is served.
+func SetRunner(r Runner) {
+	runner = r
+}
@@ -0,0 +1,23 @@
+package util
+
+import (
+	"net"
+	"strings"
+)
+
+// IsInternalIP returns true if ip is a loopback, private, link-local or unspecified address, i.e. one that isn't
+// reachable from the internet
+func IsInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// IsInternalHost returns true if host is localhost or an internal ip address. Other host names aren't resolved.
+func IsInternalHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && IsInternalIP(ip)
+}
@@ -45,10 +45,10 @@ func ParseGoogleDriveDate(value string) (timeValue time.Time, err error) {
 // GetTimeInSeconds parses a datetime string and returns its unix timestamp.
 func GetTimeInSeconds(timeValue string) (value int64) {
 	// time values without timezone info are interpreted as UTC, which is perfect
-	if timeValue, err := time.Parse(TIMEFORMAT_NO_TZ, timeValue); err == nil {
-		return timeValue.Unix()
+	if parsedTime, err := time.Parse(TIMEFORMAT_NO_TZ, timeValue); err == nil {
+		return parsedTime.Unix()
 	} else {
-		log.Printf("Error parsing string", err)
+		log.Printf("Error parsing string [%s]: %v", timeValue, err)
 	}
 	return 0
 }
@@ -136,11 +136,15 @@ func GetOrLoadLocationForName(locationName string) (location *time.Location, err
 			if !zoneNameRegexp.MatchString(locationName) {
 				return nil, errors.New(fmt.Sprintf("Invalid location name, not a valid timezone location [%s]", locationName))
 			} else {
-				var hours, minutes int64
-				fmt.Sscanf(locationName, "%+03d%02d", &hours, &minutes)
-				offsetInMinutes := hours*int64(time.Duration(60)*time.Minute) + minutes
-				offsetInSeconds := offsetInMinutes + minutes*int64(time.Duration(60)*time.Second)
-				location = time.FixedZone(locationName, int(offsetInSeconds))
+				// Parse the sign separately so that offsets under an hour (i.e. -0030) keep theirs
+				offset := zoneNameRegexp.FindString(locationName)
+				var hours, minutes int
+				fmt.Sscanf(offset[1:], "%02d%02d", &hours, &minutes)
+				offsetInSeconds := hours*int(time.Hour/time.Second) + minutes*int(time.Minute/time.Second)
+				if offset[0] == '-' {
+					offsetInSeconds = -offsetInSeconds
+				}
+				location = time.FixedZone(locationName, offsetInSeconds)
 				locationCache[locationName] = location
 			}
 		}
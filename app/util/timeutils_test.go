@@ -60,3 +60,16 @@ func TestGetTimeWithImpliedLocation(t *testing.T) {
 		t.Errorf("Expected timestamp [%d] but got [%d]", expected, timeValue.Unix())
 	}
 }
+
+func TestOffsetLocationLoading(t *testing.T) {
+	for locationName, expectedOffset := range map[string]int{"-0700": -7 * 3600, "+0130": 5400, "-0030": -1800} {
+		location, err := GetOrLoadLocationForName(locationName)
+		if err != nil {
+			t.Fatalf("Should be a valid location [%s] but got error: [%v]", locationName, err)
+		}
+
+		if _, offset := time.Date(2014, 4, 18, 0, 0, 0, 0, location).Zone(); offset != expectedOffset {
+			t.Errorf("Expected offset of [%d] seconds for location [%s] but got [%d]", expectedOffset, locationName, offset)
+		}
+	}
+}
@@ -1,2 +1,2 @@
-// The util package contains general functions to support the other packages (timeutils, sysutils)
+// The util package contains general functions to support the other packages (timeutils, sysutils, netutils)
 package util
@@ -0,0 +1,21 @@
+//go:build !standalone
+// +build !standalone
+
+package main
+
+import (
+	"github.com/alexandre-normand/glukit/app/config"
+	"google.golang.org/appengine"
+	"net/http"
+)
+
+// main initializes the routes and global initialization and serves through App Engine
+func main() {
+	appConfig = config.NewAppConfig()
+	configureEngine(appConfig)
+
+	http.Handle("/", muxRouter)
+	initRoutes()
+
+	appengine.Main()
+}
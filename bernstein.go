@@ -11,8 +11,7 @@ import (
 	"github.com/alexandre-normand/glukit/app/util"
 	"context"
 	"google.golang.org/appengine"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/log"
+	"github.com/alexandre-normand/glukit/app/log"
 	"io"
 	"net/http"
 	"strings"
@@ -21,7 +20,8 @@ import (
 
 const (
 	GLUKIT_BERNSTEIN_EMAIL = "dr.bernstein@glukit.com"
-	PERFECT_SCORE          = 83
+	// Every read of Glukit Bernstein is right on the default target
+	PERFECT_SCORE = 83
 )
 
 var BERNSTEIN_EARLIEST_READ, _ = time.Parse(util.TIMEFORMAT_NO_TZ, "2014-06-01 12:00:00")
@@ -34,28 +34,27 @@ var BERNSTEIN_BIRTH_DATE, _ = time.Parse(util.TIMEFORMAT_NO_TZ, "1934-06-17 00:0
 func initializeGlukitBernstein(writer http.ResponseWriter, reader *http.Request) {
 	context := appengine.NewContext(reader)
 
-	_, _, _, err := store.GetUserData(context, GLUKIT_BERNSTEIN_EMAIL)
-	if err == datastore.ErrNoSuchEntity {
+	_, _, err := store.GetUserData(context, GLUKIT_BERNSTEIN_EMAIL)
+	if err == store.ErrNoSuchEntity {
 		log.Infof(context, "No data found for glukit bernstein user [%s], creating it", GLUKIT_BERNSTEIN_EMAIL)
-		userProfileKey, err := store.StoreUserProfile(context, time.Now(),
+		err := store.StoreUserProfile(context, time.Now(),
 			model.GlukitUser{GLUKIT_BERNSTEIN_EMAIL, "Glukit", "Bernstein", BERNSTEIN_BIRTH_DATE, model.DIABETES_TYPE_1, "America/New_York", time.Now(),
-				BERNSTEIN_MOST_RECENT_READ, model.UNDEFINED_SCORE, model.UNDEFINED_SCORE, true, "", time.Now(), model.UNDEFINED_A1C_ESTIMATE})
+				BERNSTEIN_MOST_RECENT_READ, model.UNDEFINED_SCORE, model.UNDEFINED_SCORE, true, "", time.Now(), model.UNDEFINED_A1C_ESTIMATE, model.DEFAULT_GLUCOSE_TARGETS, model.A1C_FORMULA_ADAG, 0})
 		if err != nil {
 			util.Propagate(err)
 		}
 
 		fileReader := generateBernsteinData(context)
-		lastReadTime, err := importer.ParseContent(context, fileReader, userProfileKey, util.GLUKIT_EPOCH_TIME,
-			store.StoreDaysOfReads, store.StoreDaysOfMeals, store.StoreDaysOfInjections, store.StoreDaysOfExercises)
+		lastReadTime, err := importer.ParseContent(context, fileReader, GLUKIT_BERNSTEIN_EMAIL, util.GLUKIT_EPOCH_TIME)
 
 		if err != nil {
 			util.Propagate(err)
 		}
 
-		store.LogFileImport(context, userProfileKey, model.FileImportLog{Id: "bernstein", Md5Checksum: "dummychecksum",
+		store.LogFileImport(context, GLUKIT_BERNSTEIN_EMAIL, model.FileImportLog{Id: "bernstein", Md5Checksum: "dummychecksum",
 			LastDataProcessed: lastReadTime, ImportResult: "Success"})
 
-		if glukitUser, err := store.GetUserProfile(context, userProfileKey); err != nil {
+		if glukitUser, err := store.GetUserProfile(context, GLUKIT_BERNSTEIN_EMAIL); err != nil {
 			log.Warningf(context, "Error getting retrieving GlukitUser [%s], this needs attention: [%v]", GLUKIT_BERNSTEIN_EMAIL, err)
 		} else {
 			// Start batch calculation of the glukit scores
@@ -10,10 +10,9 @@ import (
 	"github.com/alexandre-normand/glukit/app/payment"
 	"github.com/alexandre-normand/glukit/app/store"
 	"github.com/alexandre-normand/glukit/app/util"
-	"github.com/grd/stat"
 	"google.golang.org/appengine"
-	"google.golang.org/appengine/log"
-	"google.golang.org/appengine/user"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/auth"
 	"net/http"
 	"sort"
 	"strconv"
@@ -31,6 +30,14 @@ type DataResponse struct {
 	JoinedOn     time.Time         `json:"joinedOn"`
 	Data         []DataSeries      `json:"data"`
 	Trend        string            `json:"trend"`
+	// HighKetones is true when any ketone in Data is at or over apimodel.HIGH_KETONE_THRESHOLD
+	HighKetones bool `json:"highKetones"`
+}
+
+// A1CsWithLabResults is the response of the a1cs endpoint when lab results are overlaid on the estimates
+type A1CsWithLabResults struct {
+	Estimates  []model.A1CEstimate `json:"estimates"`
+	LabResults []model.LabA1C      `json:"labResults"`
 }
 
 // Represents a generic DataSeries structure with a series of DataPoints
@@ -41,15 +48,31 @@ type DataSeries struct {
 }
 
 const (
-	QUERY_PARAM_LIMIT = "limit"
-	QUERY_PARAM_FROM  = "from"
-	QUERY_PARAM_TO    = "to"
+	QUERY_PARAM_LIMIT          = "limit"
+	QUERY_PARAM_FROM           = "from"
+	QUERY_PARAM_TO             = "to"
+	QUERY_PARAM_DAYS           = "days"
+	QUERY_PARAM_BUCKET_MINUTES = "bucket"
+	QUERY_PARAM_RANK           = "rank"
+	QUERY_PARAM_OVERLAY        = "overlay"
+	QUERY_PARAM_MINUTES        = "minutes"
+	QUERY_PARAM_MODEL          = "model"
+
+	// Lab a1c results can be overlaid on a1c estimates
+	OVERLAY_LAB_A1CS = "lab"
+
+	// Meal impacts can be ranked by either of these, in descending order
+	RANK_BY_PEAK_RISE        = "peakRise"
+	RANK_BY_AREA_UNDER_CURVE = "areaUnderCurve"
+
+	// Default and max number of days of reads the dashboard statistics are calculated from
+	DASHBOARD_DEFAULT_DAYS = 1
+	DASHBOARD_MAX_DAYS     = 90
 )
 
 // content renders the most recent day's worth of data as json for the active user
 func personalData(writer http.ResponseWriter, request *http.Request) {
-	context := appengine.NewContext(request)
-	user := user.Current(context)
+	user := auth.CurrentUser(request)
 
 	mostRecentWeekAsJson(writer, request, user.Email)
 }
@@ -63,7 +86,7 @@ func demoContent(writer http.ResponseWriter, request *http.Request) {
 // the given email address and writes to the response writer as json
 func mostRecentWeekAsJson(writer http.ResponseWriter, request *http.Request, email string) {
 	context := appengine.NewContext(request)
-	glukitUser, _, upperBound, err := store.GetUserData(context, email)
+	glukitUser, upperBound, err := store.GetUserData(context, email)
 	lowerBound := util.GetEndOfDayBoundaryBefore(upperBound).Add(model.DEFAULT_LOOKBACK_PERIOD)
 
 	if err != nil && err == store.ErrNoImportedDataFound {
@@ -90,23 +113,51 @@ func mostRecentWeekAsJson(writer http.ResponseWriter, request *http.Request, ema
 		if err != nil {
 			util.Propagate(err)
 		}
+		// Injections and meals from before the period are needed for what's still on board at its start
+		onBoardModel := engine.GetOnBoardModel()
+		onBoardLowerBound := lowerBound.Add(-onBoardModel.Lookback())
+		injectionsOnBoard, err := store.GetInjections(context, email, onBoardLowerBound, upperBound)
+		if err != nil {
+			util.Propagate(err)
+		}
+		carbsOnBoard, err := store.GetMeals(context, email, onBoardLowerBound, upperBound)
+		if err != nil {
+			util.Propagate(err)
+		}
 		exercises, err := store.GetExercises(context, email, lowerBound, upperBound)
 		if err != nil {
 			util.Propagate(err)
 		}
+		ketones, err := store.GetKetones(context, email, lowerBound, upperBound)
+		if err != nil {
+			util.Propagate(err)
+		}
+		bloodPressures, err := store.GetBloodPressures(context, email, lowerBound, upperBound)
+		if err != nil {
+			util.Propagate(err)
+		}
+		weights, err := store.GetWeights(context, email, lowerBound, upperBound)
+		if err != nil {
+			util.Propagate(err)
+		}
 
 		value := writer.Header()
 		value.Add("Content-type", "application/json")
 
 		response := DataResponse{FirstName: glukitUser.FirstName, LastName: glukitUser.LastName, Picture: glukitUser.PictureUrl, LastSync: glukitUser.MostRecentRead.GetTime(), Score: engine.CalculateUserFacingScore(glukitUser.MostRecentScore), ScoreDetails: glukitUser.MostRecentScore, JoinedOn: glukitUser.AccountCreated, Data: generateDataSeriesFromData(reads, injections, carbs, exercises, *unitValue)}
+		response.Data = append(response.Data,
+			DataSeries{"InsulinOnBoard", engine.CalculateInsulinOnBoard(injectionsOnBoard, onBoardModel, lowerBound, upperBound), "InsulinOnBoard"},
+			DataSeries{"CarbsOnBoard", engine.CalculateCarbsOnBoard(carbsOnBoard, onBoardModel, lowerBound, upperBound), "CarbsOnBoard"})
+		measurementSeries, highKetones := generateMeasurementDataSeries(ketones, bloodPressures, weights)
+		response.Data = append(response.Data, measurementSeries...)
+		response.HighKetones = highKetones
 		writeAsJson(writer, response)
 	}
 }
 
 // find the steady sailor and retrieve his most recent day's worth of data.
 func steadySailorData(writer http.ResponseWriter, request *http.Request) {
-	context := appengine.NewContext(request)
-	user := user.Current(context)
+	user := auth.CurrentUser(request)
 
 	steadySailorDataForEmail(writer, request, user.Email)
 }
@@ -119,7 +170,7 @@ func demoSteadySailorData(writer http.ResponseWriter, request *http.Request) {
 // find the steady sailor and retrieve his most recent day's worth of data.
 func steadySailorDataForEmail(writer http.ResponseWriter, request *http.Request, recipientEmail string) {
 	context := appengine.NewContext(request)
-	steadySailor, _, upperBound, err := store.FindSteadySailor(context, recipientEmail)
+	steadySailor, upperBound, err := store.FindSteadySailor(context, recipientEmail)
 
 	// Overscan by a day so that we have enough data to cover for a partial day of the user's data
 	lowerBound := upperBound.Add(model.DEFAULT_LOOKBACK_PERIOD + time.Duration(-24)*time.Hour)
@@ -181,10 +232,29 @@ func generateDataSeriesFromData(reads []apimodel.GlucoseRead, injections []apimo
 	return data
 }
 
+// generateMeasurementDataSeries returns the data series of ketones, blood pressures (systolic and diastolic) and
+// weights and whether any of the ketones is high
+func generateMeasurementDataSeries(ketones []apimodel.Ketone, bloodPressures []apimodel.BloodPressure, weights []apimodel.Weight) (dataSeries []DataSeries, highKetones bool) {
+	ketonePoints := apimodel.KetoneSlice(ketones).ToDataPointSlice()
+	for _, ketonePoint := range ketonePoints {
+		if ketonePoint.Tag == apimodel.HIGH_KETONE_TAG {
+			highKetones = true
+		}
+	}
+	systolic, diastolic := apimodel.BloodPressureSlice(bloodPressures).ToDataPointSlices()
+
+	dataSeries = []DataSeries{
+		DataSeries{"Ketones", ketonePoints, "Ketones"},
+		DataSeries{"Systolic", systolic, "BloodPressure"},
+		DataSeries{"Diastolic", diastolic, "BloodPressure"},
+		DataSeries{"Weights", apimodel.WeightSlice(weights).ToDataPointSlice(), "Weights"}}
+
+	return dataSeries, highKetones
+}
+
 // dashboard renders the dashboard statistics as json
 func dashboard(writer http.ResponseWriter, request *http.Request) {
-	context := appengine.NewContext(request)
-	user := user.Current(context)
+	user := auth.CurrentUser(request)
 
 	dashboardDataForUser(writer, request, user.Email)
 }
@@ -194,12 +264,18 @@ func demoDashboard(writer http.ResponseWriter, request *http.Request) {
 	dashboardDataForUser(writer, request, DEMO_EMAIL)
 }
 
-// dashboardDataForUser retrieves reads and generates dashboard statistics from them
+// dashboardDataForUser retrieves reads over the requested number of days and generates dashboard statistics from them
 func dashboardDataForUser(writer http.ResponseWriter, request *http.Request, email string) {
 	context := appengine.NewContext(request)
 
-	_, _, upperBound, err := store.GetUserData(context, email)
-	lowerBound := util.GetEndOfDayBoundaryBefore(upperBound).Add(time.Duration(-1*24) * time.Hour)
+	days, err := requestedDays(request, DASHBOARD_DEFAULT_DAYS)
+	if err != nil {
+		http.Error(writer, err.Error(), 400)
+		return
+	}
+
+	glukitUser, upperBound, err := store.GetUserData(context, email)
+	lowerBound := util.GetEndOfDayBoundaryBefore(upperBound).Add(time.Duration(-1*24*days) * time.Hour)
 
 	if err != nil && err == store.ErrNoImportedDataFound {
 		log.Debugf(context, "No imported data found for user [%s]", email)
@@ -212,32 +288,58 @@ func dashboardDataForUser(writer http.ResponseWriter, request *http.Request, ema
 			util.Propagate(err)
 		}
 
-		writeDashboardDataAsJson(writer, request, reads)
+		unit, err := resolveGlucoseUnit(email, request)
+		if err != nil {
+			util.Propagate(err)
+		}
+
+		insights, err := engine.GetCurrentInsights(context, email, upperBound)
+		if err != nil {
+			util.Propagate(err)
+		}
+
+		writeDashboardDataAsJson(writer, request, reads, *unit, glukitUser.GetGlucoseTargets(), insights)
 	}
 }
 
-// writedashboardDataAsJson calculates dashboard statistics from an array of GlucoseReads and writes it
-// as json
-func writeDashboardDataAsJson(writer http.ResponseWriter, request *http.Request, reads []apimodel.GlucoseRead) {
-	value := writer.Header()
-	value.Add("Content-type", "application/json")
+// requestedDays returns the number of days of reads requested, up to DASHBOARD_MAX_DAYS, or defaultDays if none is
+// specified
+func requestedDays(request *http.Request, defaultDays int) (days int, err error) {
+	rawDays := request.FormValue(QUERY_PARAM_DAYS)
+	if len(rawDays) == 0 {
+		return defaultDays, nil
+	}
 
-	var dashboardData model.DashboardData
-	if len(reads) > 0 {
-		sort.Sort(model.ReadStatsSlice(reads))
-		dashboardData.Average = stat.Mean(model.ReadStatsSlice(reads))
-		dashboardData.High, _ = stat.Max(model.ReadStatsSlice(reads))
-		dashboardData.Low, _ = stat.Min(model.ReadStatsSlice(reads))
-		dashboardData.Median = stat.MedianFromSortedData(model.ReadStatsSlice(reads))
+	value, err := strconv.ParseInt(rawDays, 10, 32)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Invalid value for %s: [%v].", QUERY_PARAM_DAYS, err))
 	}
 
+	if value < 1 || value > DASHBOARD_MAX_DAYS {
+		return 0, errors.New(fmt.Sprintf("Invalid value for %s: [%d] must be between 1 and %d.", QUERY_PARAM_DAYS, value, DASHBOARD_MAX_DAYS))
+	}
+
+	return int(value), nil
+}
+
+// writedashboardDataAsJson calculates dashboard statistics from an array of GlucoseReads and the user's targets and
+// writes it as json with glucose values in the given unit
+func writeDashboardDataAsJson(writer http.ResponseWriter, request *http.Request, reads []apimodel.GlucoseRead, unit apimodel.GlucoseUnit, targets model.GlucoseTargets, insights []model.Insight) {
+	dashboardData, err := engine.CalculateDashboardData(reads, unit, targets)
+	if err != nil {
+		util.Propagate(err)
+	}
+	dashboardData.Insights = insights
+
+	value := writer.Header()
+	value.Add("Content-type", "application/json")
+
 	enc := json.NewEncoder(writer)
 	enc.Encode(dashboardData)
 }
 
 func glukitScores(writer http.ResponseWriter, request *http.Request) {
-	context := appengine.NewContext(request)
-	user := user.Current(context)
+	user := auth.CurrentUser(request)
 
 	glukitScoresForEmail(writer, request, user.Email)
 }
@@ -273,8 +375,7 @@ func glukitScoresForEmail(writer http.ResponseWriter, request *http.Request, ema
 }
 
 func a1cEstimates(writer http.ResponseWriter, request *http.Request) {
-	context := appengine.NewContext(request)
-	user := user.Current(context)
+	user := auth.CurrentUser(request)
 
 	a1csForEmail(writer, request, user.Email)
 }
@@ -283,7 +384,8 @@ func a1cEstimatesForDemo(writer http.ResponseWriter, request *http.Request) {
 	a1csForEmail(writer, request, DEMO_EMAIL)
 }
 
-// a1cs is the endpoint to retrieve a list of a1cs.
+// a1cs is the endpoint to retrieve a list of a1cs. With the lab overlay, the lab results taken over the period of
+// the estimates are returned along with them.
 func a1csForEmail(writer http.ResponseWriter, request *http.Request, email string) {
 	context := appengine.NewContext(request)
 
@@ -293,6 +395,12 @@ func a1csForEmail(writer http.ResponseWriter, request *http.Request, email strin
 		return
 	}
 
+	overlay := request.FormValue(QUERY_PARAM_OVERLAY)
+	if overlay != "" && overlay != OVERLAY_LAB_A1CS {
+		http.Error(writer, fmt.Sprintf("Invalid value for %s: [%s] must be [%s].", QUERY_PARAM_OVERLAY, overlay, OVERLAY_LAB_A1CS), 400)
+		return
+	}
+
 	a1cs, err := store.GetA1CEstimates(context, email, *scanQuery)
 	if err != nil {
 		util.Propagate(err)
@@ -307,7 +415,540 @@ func a1csForEmail(writer http.ResponseWriter, request *http.Request, email strin
 	value.Add("Content-type", "application/json")
 
 	enc := json.NewEncoder(writer)
-	enc.Encode(a1cs)
+	if overlay != OVERLAY_LAB_A1CS {
+		enc.Encode(a1cs)
+		return
+	}
+
+	// Estimates are most recent first
+	labs, err := store.GetLabA1Cs(context, email, store.ScoreScanQuery{From: &a1cs[len(a1cs)-1].LowerBound, To: &a1cs[0].UpperBound})
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	enc.Encode(A1CsWithLabResults{Estimates: a1cs, LabResults: labs})
+}
+
+func therapyEstimates(writer http.ResponseWriter, request *http.Request) {
+	user := auth.CurrentUser(request)
+
+	therapyEstimatesForEmail(writer, request, user.Email)
+}
+
+func therapyEstimatesForDemo(writer http.ResponseWriter, request *http.Request) {
+	therapyEstimatesForEmail(writer, request, DEMO_EMAIL)
+}
+
+// therapyEstimatesForEmail is the endpoint to retrieve the history of insulin sensitivity and carb ratio estimates,
+// most recent first
+func therapyEstimatesForEmail(writer http.ResponseWriter, request *http.Request, email string) {
+	context := appengine.NewContext(request)
+
+	scanQuery, err := newScanQuery(request)
+	if err != nil {
+		http.Error(writer, err.Error(), 400)
+		return
+	}
+
+	estimates, err := store.GetTherapyEstimates(context, email, *scanQuery)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	if len(estimates) < 1 {
+		http.Error(writer, "No therapy estimated yet.", 204)
+		return
+	}
+
+	value := writer.Header()
+	value.Add("Content-type", "application/json")
+
+	enc := json.NewEncoder(writer)
+	enc.Encode(estimates)
+}
+
+func mealImpacts(writer http.ResponseWriter, request *http.Request) {
+	user := auth.CurrentUser(request)
+
+	mealImpactsForEmail(writer, request, user.Email)
+}
+
+func mealImpactsForDemo(writer http.ResponseWriter, request *http.Request) {
+	mealImpactsForEmail(writer, request, DEMO_EMAIL)
+}
+
+// mealImpactsForEmail is the endpoint to retrieve the post-prandial excursions of meals, most recent first or, when
+// a rank is given, from the largest to the smallest peak rise or area under the curve. The limit then applies to the
+// ranked meals.
+func mealImpactsForEmail(writer http.ResponseWriter, request *http.Request, email string) {
+	context := appengine.NewContext(request)
+
+	scanQuery, err := newScanQuery(request)
+	if err != nil {
+		http.Error(writer, err.Error(), 400)
+		return
+	}
+
+	rank := request.FormValue(QUERY_PARAM_RANK)
+	if len(rank) > 0 && rank != RANK_BY_PEAK_RISE && rank != RANK_BY_AREA_UNDER_CURVE {
+		http.Error(writer, fmt.Sprintf("Invalid value for %s: [%s] must be one of [%s, %s].", QUERY_PARAM_RANK, rank,
+			RANK_BY_PEAK_RISE, RANK_BY_AREA_UNDER_CURVE), 400)
+		return
+	}
+
+	limit := scanQuery.Limit
+	if len(rank) > 0 {
+		scanQuery.Limit = nil
+	}
+
+	impacts, err := store.GetMealImpacts(context, email, *scanQuery)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	if len(rank) > 0 {
+		sort.SliceStable(impacts, func(i, j int) bool {
+			if rank == RANK_BY_AREA_UNDER_CURVE {
+				return impacts[i].AreaUnderCurve > impacts[j].AreaUnderCurve
+			}
+			return impacts[i].PeakRise > impacts[j].PeakRise
+		})
+
+		if limit != nil && len(impacts) > *limit {
+			impacts = impacts[:*limit]
+		}
+	}
+
+	if len(impacts) < 1 {
+		http.Error(writer, "No meal impact calculated yet.", 204)
+		return
+	}
+
+	value := writer.Header()
+	value.Add("Content-type", "application/json")
+
+	enc := json.NewEncoder(writer)
+	enc.Encode(impacts)
+}
+
+func insights(writer http.ResponseWriter, request *http.Request) {
+	user := auth.CurrentUser(request)
+
+	insightsForEmail(writer, request, user.Email)
+}
+
+func insightsForDemo(writer http.ResponseWriter, request *http.Request) {
+	insightsForEmail(writer, request, DEMO_EMAIL)
+}
+
+// insightsForEmail is the endpoint to retrieve the history of recurring patterns detected in reads, most recent first
+func insightsForEmail(writer http.ResponseWriter, request *http.Request, email string) {
+	context := appengine.NewContext(request)
+
+	scanQuery, err := newScanQuery(request)
+	if err != nil {
+		http.Error(writer, err.Error(), 400)
+		return
+	}
+
+	storedInsights, err := store.GetInsights(context, email, *scanQuery)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	insights := make([]model.Insight, 0, len(storedInsights))
+	for _, insight := range storedInsights {
+		if insight.Type != model.NO_PATTERN_INSIGHT {
+			insights = append(insights, insight)
+		}
+	}
+
+	if len(insights) < 1 {
+		http.Error(writer, "No insights detected yet.", 204)
+		return
+	}
+
+	value := writer.Header()
+	value.Add("Content-type", "application/json")
+
+	enc := json.NewEncoder(writer)
+	enc.Encode(insights)
+}
+
+func coverage(writer http.ResponseWriter, request *http.Request) {
+	user := auth.CurrentUser(request)
+
+	coverageForEmail(writer, request, user.Email)
+}
+
+func coverageForDemo(writer http.ResponseWriter, request *http.Request) {
+	coverageForEmail(writer, request, DEMO_EMAIL)
+}
+
+// coverageForEmail is the endpoint to retrieve how much of the most recent days, the period of a glukit score by
+// default, is covered by reads along with the gaps in them
+func coverageForEmail(writer http.ResponseWriter, request *http.Request, email string) {
+	context := appengine.NewContext(request)
+
+	days, err := requestedDays(request, engine.GLUKIT_SCORE_PERIOD)
+	if err != nil {
+		http.Error(writer, err.Error(), 400)
+		return
+	}
+
+	_, upperBound, err := store.GetUserData(context, email)
+	if err != nil && err == store.ErrNoImportedDataFound {
+		log.Debugf(context, "No imported data found for user [%s]", email)
+		http.Error(writer, err.Error(), 204)
+		return
+	} else if err != nil {
+		util.Propagate(err)
+	}
+
+	lowerBound := upperBound.AddDate(0, 0, -1*days)
+	reads, err := store.GetGlucoseReads(context, email, lowerBound, upperBound)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	value := writer.Header()
+	value.Add("Content-type", "application/json")
+
+	sessions, err := engine.GetSensorSessions(context, email, lowerBound, upperBound)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	enc := json.NewEncoder(writer)
+	enc.Encode(engine.AttributeGapsToSessions(engine.AnalyzeCoverage(reads, lowerBound, upperBound), sessions))
+}
+
+func calibrationAccuracy(writer http.ResponseWriter, request *http.Request) {
+	user := auth.CurrentUser(request)
+
+	calibrationAccuracyForEmail(writer, request, user.Email)
+}
+
+func calibrationAccuracyForDemo(writer http.ResponseWriter, request *http.Request) {
+	calibrationAccuracyForEmail(writer, request, DEMO_EMAIL)
+}
+
+// calibrationAccuracyForEmail is the endpoint to retrieve the accuracy of the sensor against the calibrations of the
+// most recent days
+func calibrationAccuracyForEmail(writer http.ResponseWriter, request *http.Request, email string) {
+	context := appengine.NewContext(request)
+
+	days, err := requestedDays(request, engine.CALIBRATION_ACCURACY_PERIOD)
+	if err != nil {
+		http.Error(writer, err.Error(), 400)
+		return
+	}
+
+	_, upperBound, err := store.GetUserData(context, email)
+	if err != nil && err == store.ErrNoImportedDataFound {
+		log.Debugf(context, "No imported data found for user [%s]", email)
+		http.Error(writer, err.Error(), 204)
+		return
+	} else if err != nil {
+		util.Propagate(err)
+	}
+
+	lowerBound := upperBound.AddDate(0, 0, -1*days)
+	reads, err := store.GetGlucoseReads(context, email, lowerBound, upperBound)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	calibrations, err := store.GetCalibrations(context, email, lowerBound, upperBound)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	sessions, err := engine.ResolveSensorSessions(context, email, lowerBound, upperBound, reads, calibrations)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	accuracy, err := engine.AnalyzeCalibrationAccuracy(reads, calibrations, sessions, lowerBound, upperBound)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	if accuracy.Overall.PairCount == 0 {
+		http.Error(writer, "No calibration paired with reads yet.", 204)
+		return
+	}
+
+	value := writer.Header()
+	value.Add("Content-type", "application/json")
+
+	enc := json.NewEncoder(writer)
+	enc.Encode(accuracy)
+}
+
+func sensorSessions(writer http.ResponseWriter, request *http.Request) {
+	user := auth.CurrentUser(request)
+
+	sensorSessionsForEmail(writer, request, user.Email)
+}
+
+func sensorSessionsForDemo(writer http.ResponseWriter, request *http.Request) {
+	sensorSessionsForEmail(writer, request, DEMO_EMAIL)
+}
+
+// sensorSessionsForEmail is the endpoint to retrieve the statistics of the sensor sessions worn during the most recent
+// days. Statistics only cover the reads and calibrations of the requested days.
+func sensorSessionsForEmail(writer http.ResponseWriter, request *http.Request, email string) {
+	context := appengine.NewContext(request)
+
+	days, err := requestedDays(request, engine.SENSOR_SESSION_STATISTICS_DAYS)
+	if err != nil {
+		http.Error(writer, err.Error(), 400)
+		return
+	}
+
+	_, upperBound, err := store.GetUserData(context, email)
+	if err != nil && err == store.ErrNoImportedDataFound {
+		log.Debugf(context, "No imported data found for user [%s]", email)
+		http.Error(writer, err.Error(), 204)
+		return
+	} else if err != nil {
+		util.Propagate(err)
+	}
+
+	lowerBound := upperBound.AddDate(0, 0, -1*days)
+	reads, err := store.GetGlucoseReads(context, email, lowerBound, upperBound)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	calibrations, err := store.GetCalibrations(context, email, lowerBound, upperBound)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	sessions, err := engine.ResolveSensorSessions(context, email, lowerBound, upperBound, reads, calibrations)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	if len(sessions) == 0 {
+		http.Error(writer, "No sensor session found.", 204)
+		return
+	}
+
+	statistics := make([]model.SensorSessionStatistics, len(sessions))
+	for i, session := range sessions {
+		if statistics[i], err = engine.CalculateSensorSessionStatistics(session, reads, calibrations, upperBound); err != nil {
+			util.Propagate(err)
+		}
+	}
+
+	value := writer.Header()
+	value.Add("Content-type", "application/json")
+
+	enc := json.NewEncoder(writer)
+	enc.Encode(statistics)
+}
+
+func insulin(writer http.ResponseWriter, request *http.Request) {
+	user := auth.CurrentUser(request)
+
+	insulinForEmail(writer, request, user.Email)
+}
+
+func insulinForDemo(writer http.ResponseWriter, request *http.Request) {
+	insulinForEmail(writer, request, DEMO_EMAIL)
+}
+
+// insulinForEmail is the endpoint to retrieve the total daily insulin, split into basal and bolus, of the most recent
+// days, the last one being the day of the most recent read
+func insulinForEmail(writer http.ResponseWriter, request *http.Request, email string) {
+	context := appengine.NewContext(request)
+
+	days, err := requestedDays(request, engine.DAILY_INSULIN_DAYS)
+	if err != nil {
+		http.Error(writer, err.Error(), 400)
+		return
+	}
+
+	_, upperBound, err := store.GetUserData(context, email)
+	if err != nil && err == store.ErrNoImportedDataFound {
+		log.Debugf(context, "No imported data found for user [%s]", email)
+		http.Error(writer, err.Error(), 204)
+		return
+	} else if err != nil {
+		util.Propagate(err)
+	}
+
+	lowerBound := time.Date(upperBound.Year(), upperBound.Month(), upperBound.Day()-days+1, 0, 0, 0, 0, upperBound.Location())
+	injections, err := store.GetInjections(context, email, lowerBound, upperBound)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	pumpLowerBound := lowerBound.Add(-engine.PUMP_EVENT_LOOKBACK)
+	basalRates, err := store.GetBasalRates(context, email, pumpLowerBound, upperBound)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	tempBasals, err := store.GetTempBasals(context, email, pumpLowerBound, upperBound)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	pumpSuspends, err := store.GetPumpSuspends(context, email, pumpLowerBound, upperBound)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	extendedBoluses, err := store.GetExtendedBoluses(context, email, pumpLowerBound, upperBound)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	delivery := engine.NewPumpDelivery(basalRates, tempBasals, pumpSuspends, extendedBoluses)
+	totals := engine.CalculateTotalDailyInsulin(injections, delivery, lowerBound, upperBound)
+	if totals.Average.Total == 0 {
+		http.Error(writer, "No insulin delivered over the requested days.", 204)
+		return
+	}
+
+	value := writer.Header()
+	value.Add("Content-type", "application/json")
+
+	enc := json.NewEncoder(writer)
+	enc.Encode(totals)
+}
+
+func forecast(writer http.ResponseWriter, request *http.Request) {
+	user := auth.CurrentUser(request)
+
+	forecastForEmail(writer, request, user.Email)
+}
+
+func forecastForDemo(writer http.ResponseWriter, request *http.Request) {
+	forecastForEmail(writer, request, DEMO_EMAIL)
+}
+
+// forecastForEmail is the endpoint to retrieve the glucose forecast following the most recent read, along with the
+// bounds of its prediction intervals, as data series in the requested unit
+func forecastForEmail(writer http.ResponseWriter, request *http.Request, email string) {
+	context := appengine.NewContext(request)
+
+	horizon := engine.DEFAULT_FORECAST_HORIZON
+	if rawMinutes := request.FormValue(QUERY_PARAM_MINUTES); len(rawMinutes) > 0 {
+		value, err := strconv.ParseInt(rawMinutes, 10, 32)
+		horizon = time.Duration(value) * time.Minute
+		if err != nil || horizon < engine.FORECAST_STEP || horizon > engine.MAX_FORECAST_HORIZON {
+			http.Error(writer, fmt.Sprintf("Invalid value for %s: [%s] must be between %.0f and %.0f.", QUERY_PARAM_MINUTES,
+				rawMinutes, engine.FORECAST_STEP.Minutes(), engine.MAX_FORECAST_HORIZON.Minutes()), 400)
+			return
+		}
+	}
+
+	forecasterName := engine.DEFAULT_FORECASTER
+	if rawModel := request.FormValue(QUERY_PARAM_MODEL); len(rawModel) > 0 {
+		forecasterName = rawModel
+	}
+	forecaster, err := engine.GetForecaster(forecasterName)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("Invalid value for %s: [%s] %v.", QUERY_PARAM_MODEL, forecasterName, err), 400)
+		return
+	}
+
+	_, upperBound, err := store.GetUserData(context, email)
+	if err != nil && err == store.ErrNoImportedDataFound {
+		log.Debugf(context, "No imported data found for user [%s]", email)
+		http.Error(writer, err.Error(), 204)
+		return
+	} else if err != nil {
+		util.Propagate(err)
+	}
+
+	unit, err := resolveGlucoseUnit(email, request)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	points, err := engine.ForecastGlucose(context, email, upperBound, horizon, forecaster)
+	if err == engine.ErrInsufficientReadsForForecast {
+		http.Error(writer, err.Error(), 204)
+		return
+	} else if err != nil {
+		util.Propagate(err)
+	}
+
+	values, lows, highs, err := engine.ForecastToDataPoints(points, *unit)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	value := writer.Header()
+	value.Add("Content-type", "application/json")
+
+	enc := json.NewEncoder(writer)
+	enc.Encode([]DataSeries{
+		DataSeries{Name: engine.FORECAST_TAG, Data: values, Type: engine.FORECAST_TAG},
+		DataSeries{Name: engine.FORECAST_LOW_TAG, Data: lows, Type: engine.FORECAST_LOW_TAG},
+		DataSeries{Name: engine.FORECAST_HIGH_TAG, Data: highs, Type: engine.FORECAST_HIGH_TAG}})
+}
+
+func agp(writer http.ResponseWriter, request *http.Request) {
+	user := auth.CurrentUser(request)
+
+	agpForEmail(writer, request, user.Email)
+}
+
+func agpForDemo(writer http.ResponseWriter, request *http.Request) {
+	agpForEmail(writer, request, DEMO_EMAIL)
+}
+
+// agpForEmail is the endpoint to retrieve the ambulatory glucose profile of the most recent days of data
+func agpForEmail(writer http.ResponseWriter, request *http.Request, email string) {
+	context := appengine.NewContext(request)
+
+	bucketMinutes := engine.AGP_BUCKET_15_MINUTES
+	if rawBucketMinutes := request.FormValue(QUERY_PARAM_BUCKET_MINUTES); len(rawBucketMinutes) > 0 {
+		value, err := strconv.ParseInt(rawBucketMinutes, 10, 32)
+		if err != nil || (value != engine.AGP_BUCKET_5_MINUTES && value != engine.AGP_BUCKET_15_MINUTES) {
+			http.Error(writer, fmt.Sprintf("Invalid value for %s: [%s] must be one of [%d, %d].", QUERY_PARAM_BUCKET_MINUTES,
+				rawBucketMinutes, engine.AGP_BUCKET_5_MINUTES, engine.AGP_BUCKET_15_MINUTES), 400)
+			return
+		}
+		bucketMinutes = int(value)
+	}
+
+	_, upperBound, err := store.GetUserData(context, email)
+	if err != nil && err == store.ErrNoImportedDataFound {
+		log.Debugf(context, "No imported data found for user [%s]", email)
+		http.Error(writer, err.Error(), 204)
+		return
+	} else if err != nil {
+		util.Propagate(err)
+	}
+
+	unit, err := resolveGlucoseUnit(email, request)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	agp, err := engine.BuildAGP(context, email, upperBound, bucketMinutes, *unit)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	if len(agp.Buckets) < 1 {
+		http.Error(writer, "No reads to build the agp from.", 204)
+		return
+	}
+
+	value := writer.Header()
+	value.Add("Content-type", "application/json")
+
+	enc := json.NewEncoder(writer)
+	enc.Encode(agp)
 }
 
 func newScanQuery(request *http.Request) (scanQuery *store.ScoreScanQuery, err error) {
@@ -355,7 +996,7 @@ func newScanQuery(request *http.Request) (scanQuery *store.ScoreScanQuery, err e
 
 func handleDonation(writer http.ResponseWriter, request *http.Request) {
 	context := appengine.NewContext(request)
-	user := user.Current(context)
+	user := auth.CurrentUser(request)
 
 	request.ParseForm()
 	token := request.FormValue(payment.STRIPE_TOKEN)
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/auth"
+	"github.com/alexandre-normand/glukit/app/exporter"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/store"
+	"google.golang.org/appengine"
+	"net/http"
+	"time"
+)
+
+const (
+	EXPORT_FORMAT_PARAMETER = "format"
+	EXPORT_FILENAME_PREFIX  = "glukit-export"
+)
+
+// exportData streams all of the logged in user's data as a downloadable file. The format is given by the format
+// parameter (jsonl, csv or xml) and defaults to JSON Lines.
+func exportData(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := auth.CurrentUser(request)
+
+	format := request.FormValue(EXPORT_FORMAT_PARAMETER)
+	if format == "" {
+		format = exporter.JSON_LINES_FORMAT
+	}
+
+	recordWriter, err := exporter.NewRecordWriter(format, writer)
+	if err != nil {
+		http.Error(writer, err.Error(), 400)
+		return
+	}
+
+	if _, err := store.GetGlukitUser(context, user.Email); err != nil {
+		log.Warningf(context, "Error getting user to export data, user email is [%s]: %v", user.Email, err)
+		http.Error(writer, "Error getting user to export data", 500)
+		return
+	}
+
+	value := writer.Header()
+	value.Add("Content-type", exporter.ContentType(format))
+	value.Add("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", EXPORT_FILENAME_PREFIX, format))
+
+	// Include anything that might have been recorded in a timezone ahead of us
+	if err = exporter.Export(context, user.Email, time.Now().Add(24*time.Hour), recordWriter); err != nil {
+		// Headers are already sent so all we can do is log it, the download will be truncated
+		log.Errorf(context, "Error exporting data of user [%s] in format [%s]: %v", user.Email, format, err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/alexandre-normand/glukit/app/apimodel"
 	"github.com/alexandre-normand/glukit/app/config"
+	"github.com/alexandre-normand/glukit/app/engine"
 	"github.com/alexandre-normand/glukit/app/model"
 	"github.com/alexandre-normand/glukit/app/store"
 	"github.com/alexandre-normand/glukit/app/util"
@@ -14,8 +15,7 @@ import (
 	"golang.org/x/oauth2/google"
 	googleuser "google.golang.org/api/oauth2/v2"
 	"google.golang.org/appengine"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/log"
+	"github.com/alexandre-normand/glukit/app/log"
 	"net/http"
 	"time"
 )
@@ -27,6 +27,18 @@ const (
 var emptyDataPointSlice []apimodel.DataPoint
 var appConfig *config.AppConfig
 
+// configureEngine sets the engine options from the application configuration
+func configureEngine(appConfig *config.AppConfig) {
+	engine.SetGapFilling(engine.GapFilling{MaxGap: appConfig.MaxFilledGap})
+
+	onBoard := appConfig.OnBoard
+	onBoardModel, err := engine.NewOnBoardModel(onBoard.InsulinShape, onBoard.InsulinPeak, onBoard.InsulinDuration, onBoard.LongActingInsulinDuration, onBoard.CarbsAbsorption)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid on board configuration: %v", err))
+	}
+	engine.SetOnBoardModel(onBoardModel)
+}
+
 // config returns the configuration information for OAuth.
 func configuration() *oauth2.Config {
 	configuration := oauth2.Config{
@@ -93,9 +105,9 @@ func handleLoggedInUser(writer http.ResponseWriter, request *http.Request) {
 			log.Infof(context, "User profile refreshed to %v", userInfo)
 
 			log.Infof(context, "Got user info for logged in google account [%s]", userInfo)
-			glukitUser, _, _, err := store.GetUserData(context, userInfo.Email)
+			glukitUser, _, err := store.GetUserData(context, userInfo.Email)
 
-			if err == datastore.ErrNoSuchEntity {
+			if err == store.ErrNoSuchEntity {
 				log.Infof(context, "No data found for user [%s], creating it", userInfo.Email)
 
 				// TODO: Populate GlukitUser correctly, this will likely require getting rid of all data from the store when
@@ -104,8 +116,8 @@ func handleLoggedInUser(writer http.ResponseWriter, request *http.Request) {
 				// we have a glukit user with no refresh token, we need to force getting a new one (which is to be avoided)
 				glukitUser = &model.GlukitUser{userInfo.Email, userInfo.GivenName, userInfo.FamilyName, time.Now(),
 					model.DIABETES_TYPE_1, "", util.GLUKIT_EPOCH_TIME, apimodel.UNDEFINED_GLUCOSE_READ,
-					model.UNDEFINED_SCORE, model.UNDEFINED_SCORE, false, userInfo.Picture, time.Now(), model.UNDEFINED_A1C_ESTIMATE}
-				_, err = store.StoreUserProfile(context, time.Now(), *glukitUser)
+					model.UNDEFINED_SCORE, model.UNDEFINED_SCORE, false, userInfo.Picture, time.Now(), model.UNDEFINED_A1C_ESTIMATE, model.DEFAULT_GLUCOSE_TARGETS, model.A1C_FORMULA_ADAG, 0}
+				err = store.StoreUserProfile(context, time.Now(), *glukitUser)
 				if err != nil {
 					util.Propagate(err)
 				}
@@ -116,7 +128,7 @@ func handleLoggedInUser(writer http.ResponseWriter, request *http.Request) {
 				glukitUser.FirstName = userInfo.GivenName
 				glukitUser.LastName = userInfo.FamilyName
 
-				_, err = store.StoreUserProfile(context, time.Now(), *glukitUser)
+				err = store.StoreUserProfile(context, time.Now(), *glukitUser)
 				if err != nil {
 					util.Propagate(err)
 				}
@@ -137,11 +149,11 @@ func handleLoggedInUser(writer http.ResponseWriter, request *http.Request) {
 	renderRealUser(writer, request)
 }
 
-// buildPerfectBaseline generates an array of reads that represents the target/perfection
-func buildPerfectBaseline(glucoseReads []apimodel.GlucoseRead) (reads []apimodel.GlucoseRead) {
+// buildPerfectBaseline generates an array of reads that represents the user's target/perfection at the time of each read
+func buildPerfectBaseline(glucoseReads []apimodel.GlucoseRead, targets model.GlucoseTargets) (reads []apimodel.GlucoseRead) {
 	reads = make([]apimodel.GlucoseRead, len(glucoseReads))
 	for i := range glucoseReads {
-		reads[i] = apimodel.GlucoseRead{glucoseReads[i].Time, apimodel.MG_PER_DL, model.TARGET_GLUCOSE_VALUE}
+		reads[i] = apimodel.GlucoseRead{glucoseReads[i].Time, apimodel.MG_PER_DL, float32(targets.RangeAt(glucoseReads[i].GetTime()).Target)}
 	}
 
 	return reads
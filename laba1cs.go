@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/alexandre-normand/glukit/app/auth"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/util"
+	"google.golang.org/appengine"
+	"net/http"
+)
+
+func labA1Cs(writer http.ResponseWriter, request *http.Request) {
+	user := auth.CurrentUser(request)
+
+	labA1CsForEmail(writer, request, user.Email)
+}
+
+func labA1CsForDemo(writer http.ResponseWriter, request *http.Request) {
+	labA1CsForEmail(writer, request, DEMO_EMAIL)
+}
+
+// labA1CsForEmail is the endpoint to retrieve the lab a1c results of a user, most recent first
+func labA1CsForEmail(writer http.ResponseWriter, request *http.Request, email string) {
+	context := appengine.NewContext(request)
+
+	scanQuery, err := newScanQuery(request)
+	if err != nil {
+		http.Error(writer, err.Error(), 400)
+		return
+	}
+
+	labs, err := store.GetLabA1Cs(context, email, *scanQuery)
+	if err != nil {
+		util.Propagate(err)
+	}
+
+	if len(labs) < 1 {
+		http.Error(writer, "No lab a1c recorded yet.", 204)
+		return
+	}
+
+	writer.Header().Add("Content-type", "application/json")
+	json.NewEncoder(writer).Encode(labs)
+}
+
+// recordLabA1C records the lab a1c result given as json in the body of the request for the logged in user. The user's
+// glycation offset is recalibrated and corrects a1cs estimated from then on.
+func recordLabA1C(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := auth.CurrentUser(request)
+
+	var lab model.LabA1C
+	if err := json.NewDecoder(request.Body).Decode(&lab); err != nil {
+		http.Error(writer, "Invalid lab a1c: "+err.Error(), 400)
+		return
+	}
+
+	if err := lab.Validate(); err != nil {
+		http.Error(writer, err.Error(), 400)
+		return
+	}
+
+	glukitUser, err := store.GetUserProfile(context, user.Email)
+	if err != nil {
+		log.Warningf(context, "Error getting profile of user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error recording lab a1c", 500)
+		return
+	}
+
+	if err = engine.RecordLabA1C(context, glukitUser, lab); err != nil {
+		log.Warningf(context, "Error recording lab a1c of user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error recording lab a1c", 500)
+		return
+	}
+
+	log.Infof(context, "Recorded lab a1c [%v] of user [%s], glycation offset is now [%f]", lab, user.Email, glukitUser.GlycationOffset)
+	writeA1CSettings(writer, glukitUser)
+}
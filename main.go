@@ -4,18 +4,15 @@ package main
 import (
 	"fmt"
 	"github.com/alexandre-normand/glukit/app/apimodel"
-	"github.com/alexandre-normand/glukit/app/config"
 	"github.com/alexandre-normand/glukit/app/engine"
 	"github.com/alexandre-normand/glukit/app/model"
 	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/tasks"
 	"github.com/alexandre-normand/glukit/app/util"
 	"github.com/gorilla/mux"
 	"google.golang.org/appengine"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/delay"
-	"google.golang.org/appengine/log"
-	"google.golang.org/appengine/taskqueue"
-	"google.golang.org/appengine/user"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/auth"
 	"html/template"
 	"net/http"
 	"sync"
@@ -43,12 +40,9 @@ type RenderVariables struct {
 	GlucoseUnit          apimodel.GlucoseUnit
 }
 
-// init initializes the routes and global initialization
-func main() {
-	appConfig = config.NewAppConfig()
-
-	http.Handle("/", muxRouter)
-
+// initRoutes registers all routes on the mux router along with the task functions. It's shared by the App Engine
+// and standalone entry points.
+func initRoutes() {
 	// Create user Glukit Bernstein as a fallback for comparisons
 	muxRouter.HandleFunc("/_ah/warmup", warmUp)
 	muxRouter.HandleFunc("/initpower", warmUp)
@@ -64,6 +58,27 @@ func main() {
 	muxRouter.HandleFunc("/glukitScores", glukitScores)
 	muxRouter.HandleFunc("/"+DEMO_PATH_PREFIX+"a1cs", a1cEstimatesForDemo)
 	muxRouter.HandleFunc("/a1cs", a1cEstimates)
+	muxRouter.HandleFunc("/"+DEMO_PATH_PREFIX+"laba1cs", labA1CsForDemo)
+	muxRouter.HandleFunc("/laba1cs", labA1Cs).Methods("GET")
+	muxRouter.HandleFunc("/laba1cs", recordLabA1C).Methods("POST")
+	muxRouter.HandleFunc("/"+DEMO_PATH_PREFIX+"therapyestimates", therapyEstimatesForDemo)
+	muxRouter.HandleFunc("/therapyestimates", therapyEstimates)
+	muxRouter.HandleFunc("/"+DEMO_PATH_PREFIX+"mealimpacts", mealImpactsForDemo)
+	muxRouter.HandleFunc("/mealimpacts", mealImpacts)
+	muxRouter.HandleFunc("/"+DEMO_PATH_PREFIX+"insights", insightsForDemo)
+	muxRouter.HandleFunc("/insights", insights)
+	muxRouter.HandleFunc("/"+DEMO_PATH_PREFIX+"coverage", coverageForDemo)
+	muxRouter.HandleFunc("/coverage", coverage)
+	muxRouter.HandleFunc("/"+DEMO_PATH_PREFIX+"calibrationAccuracy", calibrationAccuracyForDemo)
+	muxRouter.HandleFunc("/calibrationAccuracy", calibrationAccuracy)
+	muxRouter.HandleFunc("/"+DEMO_PATH_PREFIX+"sensorsessions", sensorSessionsForDemo)
+	muxRouter.HandleFunc("/sensorsessions", sensorSessions)
+	muxRouter.HandleFunc("/"+DEMO_PATH_PREFIX+"insulin", insulinForDemo)
+	muxRouter.HandleFunc("/insulin", insulin)
+	muxRouter.HandleFunc("/"+DEMO_PATH_PREFIX+"forecast", forecastForDemo)
+	muxRouter.HandleFunc("/forecast", forecast)
+	muxRouter.HandleFunc("/"+DEMO_PATH_PREFIX+"agp", agpForDemo)
+	muxRouter.HandleFunc("/agp", agp)
 	muxRouter.HandleFunc("/donation", handleDonation)
 
 	// "main"-page for both demo and real users
@@ -85,16 +100,71 @@ func main() {
 	muxRouter.HandleFunc("/v1/meals", initializeAndHandleRequest).Methods("POST").Name(MEALS_V1_ROUTE)
 	muxRouter.HandleFunc("/v1/glucosereads", initializeAndHandleRequest).Methods("POST").Name(GLUCOSEREADS_V1_ROUTE)
 	muxRouter.HandleFunc("/v1/exercises", initializeAndHandleRequest).Methods("POST").Name(EXERCISES_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/sensorsessions", initializeAndHandleRequest).Methods("POST").Name(SENSORSESSIONS_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/basalrates", initializeAndHandleRequest).Methods("POST").Name(BASALRATES_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/tempbasals", initializeAndHandleRequest).Methods("POST").Name(TEMPBASALS_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/pumpsuspends", initializeAndHandleRequest).Methods("POST").Name(PUMPSUSPENDS_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/extendedboluses", initializeAndHandleRequest).Methods("POST").Name(EXTENDEDBOLUSES_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/ketones", initializeAndHandleRequest).Methods("POST").Name(KETONES_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/bloodpressures", initializeAndHandleRequest).Methods("POST").Name(BLOODPRESSURES_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/weights", initializeAndHandleRequest).Methods("POST").Name(WEIGHTS_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/calibrations", initializeAndHandleRequest).Methods("GET").Name(CALIBRATIONS_READ_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/injections", initializeAndHandleRequest).Methods("GET").Name(INJECTIONS_READ_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/meals", initializeAndHandleRequest).Methods("GET").Name(MEALS_READ_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/glucosereads", initializeAndHandleRequest).Methods("GET").Name(GLUCOSEREADS_READ_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/exercises", initializeAndHandleRequest).Methods("GET").Name(EXERCISES_READ_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/sensorsessions", initializeAndHandleRequest).Methods("GET").Name(SENSORSESSIONS_READ_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/ketones", initializeAndHandleRequest).Methods("GET").Name(KETONES_READ_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/bloodpressures", initializeAndHandleRequest).Methods("GET").Name(BLOODPRESSURES_READ_V1_ROUTE)
+	muxRouter.HandleFunc("/v1/weights", initializeAndHandleRequest).Methods("GET").Name(WEIGHTS_READ_V1_ROUTE)
+
+	// Nightscout-compatible endpoints
+	muxRouter.HandleFunc("/api/v1/status{format:(?:\\.json)?}", nightscoutStatus).Methods("GET")
+	muxRouter.HandleFunc("/api/v1/entries{format:(?:\\.json)?}", initializeAndHandleRequest).Methods("POST").Name(NIGHTSCOUT_ENTRIES_ROUTE)
+	muxRouter.HandleFunc("/api/v1/entries{format:(?:\\.json|/sgv|/sgv\\.json)?}", initializeAndHandleRequest).Methods("GET").Name(NIGHTSCOUT_ENTRIES_READ_ROUTE)
+	muxRouter.HandleFunc("/api/v1/treatments{format:(?:\\.json)?}", initializeAndHandleRequest).Methods("POST").Name(NIGHTSCOUT_TREATMENTS_ROUTE)
+	muxRouter.HandleFunc("/api/v1/verifyauth", initializeAndHandleRequest).Methods("GET").Name(NIGHTSCOUT_VERIFY_AUTH_ROUTE)
+	muxRouter.HandleFunc("/nightscoutsecret", generateNightscoutSecret).Methods("POST")
+
+	// Uploads of device exports by logged in users
+	muxRouter.HandleFunc("/upload", uploadFile).Methods("POST")
+	muxRouter.HandleFunc("/upload/clarity", uploadClarityFile).Methods("POST")
+
+	// Download of all of a logged in user's data
+	muxRouter.HandleFunc("/export", exportData).Methods("GET")
+
+	// Deletion of a logged in user's account and all of its data
+	muxRouter.HandleFunc("/account/deletion", requestAccountDeletion).Methods("POST")
+	muxRouter.HandleFunc("/account/deletion", accountDeletionStatus).Methods("GET")
+
+	// Settings of a logged in user
+	muxRouter.HandleFunc("/settings/targets", glucoseTargets).Methods("GET")
+	muxRouter.HandleFunc("/settings/targets", updateGlucoseTargets).Methods("POST")
+	muxRouter.HandleFunc("/settings/alerts", alertSettings).Methods("GET")
+	muxRouter.HandleFunc("/settings/alerts", updateAlertSettings).Methods("POST")
+	muxRouter.HandleFunc("/settings/a1c", a1cSettings).Methods("GET")
+	muxRouter.HandleFunc("/settings/a1c", updateA1CSettings).Methods("POST")
+
+	// Alerts raised for a logged in user
+	muxRouter.HandleFunc("/alerts", alerts).Methods("GET")
+
+	// Administration
+	muxRouter.HandleFunc("/admin/scoremigration", migrateGlukitScores).Methods("POST")
+	muxRouter.HandleFunc("/admin/alerts/missingdata", checkMissingData).Methods("GET")
+	muxRouter.HandleFunc("/admin/insights", detectPatterns).Methods("GET")
 
 	// Register oauth endpoints to warmup which will initilize the oauth server and replace the routes with the actual oauth handlers
 	muxRouter.HandleFunc("/token", initializeAndHandleRequest).Methods("POST").Name(TOKEN_ROUTE)
 	muxRouter.HandleFunc("/authorize", initializeAndHandleRequest).Methods("GET").Name(AUTHORIZE_ROUTE)
 
 	// Initialize task functions that would otherwise be prone to initialization loops
-	engine.RunGlukitScoreCalculationChunk = delay.Func(engine.GLUKIT_SCORE_BATCH_CALCULATION_FUNCTION_NAME, engine.RunGlukitScoreBatchCalculation)
-	engine.RunA1CCalculationChunk = delay.Func(engine.A1C_BATCH_CALCULATION_FUNCTION_NAME, engine.RunA1CBatchCalculation)
-
-	appengine.Main()
+	engine.RunGlukitScoreCalculationChunk = tasks.Func(engine.GLUKIT_SCORE_BATCH_CALCULATION_FUNCTION_NAME, engine.RunGlukitScoreBatchCalculation)
+	engine.RunA1CCalculationChunk = tasks.Func(engine.A1C_BATCH_CALCULATION_FUNCTION_NAME, engine.RunA1CBatchCalculation)
+	engine.RunGlukitScoreMigrationChunk = tasks.Func(engine.GLUKIT_SCORE_MIGRATION_FUNCTION_NAME, engine.RunGlukitScoreMigration)
+	engine.RunUsersGlukitScoreMigrationChunk = tasks.Func(engine.USERS_GLUKIT_SCORE_MIGRATION_FUNCTION_NAME, engine.RunUsersGlukitScoreMigration)
+	engine.RunMealImpactCalculationChunk = tasks.Func(engine.MEAL_IMPACT_CALCULATION_FUNCTION_NAME, engine.RunMealImpactCalculation)
+	engine.RunUsersPatternDetectionChunk = tasks.Func(engine.USERS_PATTERN_DETECTION_FUNCTION_NAME, engine.RunUsersPatternDetection)
+	engine.RunSensorSessionInferenceChunk = tasks.Func(engine.SENSOR_SESSION_INFERENCE_FUNCTION_NAME, engine.RunSensorSessionInference)
 }
 
 // landing executes the landing page template
@@ -114,25 +184,23 @@ func oauthCallback(writer http.ResponseWriter, request *http.Request) {
 func renderDemo(w http.ResponseWriter, request *http.Request) {
 	context := appengine.NewContext(request)
 
-	_, key, _, err := store.GetUserData(context, DEMO_EMAIL)
-	if err == datastore.ErrNoSuchEntity {
+	_, _, err := store.GetUserData(context, DEMO_EMAIL)
+	if err == store.ErrNoSuchEntity {
 		log.Infof(context, "No data found for demo user [%s], creating it", DEMO_EMAIL)
 
 		// TODO: Populate GlukitUser correctly, this will likely require
 		// getting rid of all data from the store when this is ready
-		key, err = store.StoreUserProfile(context, time.Now(),
+		err = store.StoreUserProfile(context, time.Now(),
 			model.GlukitUser{DEMO_EMAIL, "Demo", "OfMe", time.Now(), model.DIABETES_TYPE_1, "", time.Now(),
 				apimodel.UNDEFINED_GLUCOSE_READ, model.UNDEFINED_SCORE, model.UNDEFINED_SCORE, true, DEMO_PICTURE_URL, time.Now(),
-				model.UNDEFINED_A1C_ESTIMATE})
+				model.UNDEFINED_A1C_ESTIMATE, model.DEFAULT_GLUCOSE_TARGETS, model.A1C_FORMULA_ADAG, 0})
 		if err != nil {
 			util.Propagate(err)
 		}
 
-		task, err := processDemoFile.Task(key)
-		if err != nil {
+		if err := processDemoFile.Add(context, DATASTORE_WRITES_QUEUE_NAME, DEMO_EMAIL); err != nil {
 			util.Propagate(err)
 		}
-		taskqueue.Add(context, task, DATASTORE_WRITES_QUEUE_NAME)
 
 	} else if err != nil {
 		util.Propagate(err)
@@ -145,15 +213,14 @@ func renderDemo(w http.ResponseWriter, request *http.Request) {
 
 // renderRealUser executes the graph page template for a real user
 func renderRealUser(w http.ResponseWriter, request *http.Request) {
-	context := appengine.NewContext(request)
-	user := user.Current(context)
+	user := auth.CurrentUser(request)
 	render(user.Email, "", w, request)
 }
 
 // report executes the report page template
 func demoReport(w http.ResponseWriter, request *http.Request) {
 	context := appengine.NewContext(request)
-	user := user.Current(context)
+	user := auth.CurrentUser(request)
 	unitValue, err := resolveGlucoseUnit(user.Email, request)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -171,7 +238,7 @@ func demoReport(w http.ResponseWriter, request *http.Request) {
 // report executes the report page template
 func report(w http.ResponseWriter, request *http.Request) {
 	context := appengine.NewContext(request)
-	user := user.Current(context)
+	user := auth.CurrentUser(request)
 	unitValue, err := resolveGlucoseUnit(user.Email, request)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -210,7 +277,7 @@ func resolveGlucoseUnit(email string, request *http.Request) (unit *apimodel.Glu
 	rawUnitValue := request.FormValue(GLUCOSE_UNIT_PARAMETER)
 	if rawUnitValue != apimodel.MMOL_PER_L && rawUnitValue != apimodel.MG_PER_DL {
 		context := appengine.NewContext(request)
-		glukitUser, _, _, err := store.GetUserData(context, email)
+		glukitUser, _, err := store.GetUserData(context, email)
 		if err != nil {
 			return nil, err
 		}
@@ -224,8 +291,7 @@ func resolveGlucoseUnit(email string, request *http.Request) (unit *apimodel.Glu
 }
 
 func googleauth(writer http.ResponseWriter, request *http.Request) {
-	context := appengine.NewContext(request)
-	loginurl, err := user.LoginURL(context, fmt.Sprintf("https://%s/userlogin", appConfig.Host))
+	loginurl, err := auth.LoginURL(request, fmt.Sprintf("https://%s/userlogin", appConfig.Host))
 	if err != nil {
 		util.Propagate(err)
 	}
@@ -236,7 +302,7 @@ func googleauth(writer http.ResponseWriter, request *http.Request) {
 // loginUser handles the flow for a real non-demo user. It will redirect to authorization if required
 func loginUser(writer http.ResponseWriter, request *http.Request) {
 	/**
-	glukitUser, _, _, err := store.GetUserData(context, user.Email)
+	glukitUser, _, err := store.GetUserData(context, user.Email)
 	if _, ok := err.(store.StoreError); err != nil && !ok || len(glukitUser.RefreshToken) == 0 {
 		log.Infof(context, "Redirecting [%s], glukitUser [%v] for authorization. Error: [%v]", user.Email, glukitUser, err)
 
@@ -274,5 +340,6 @@ func initializeAndHandleRequest(writer http.ResponseWriter, request *http.Reques
 func initializeApp(writer http.ResponseWriter, request *http.Request) {
 	initOauthProvider(writer, request)
 	initApiEndpoints(writer, request)
+	initNightscoutEndpoints(writer, request)
 	initializeGlukitBernstein(writer, request)
 }
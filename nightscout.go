@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/auth"
+	"github.com/alexandre-normand/glukit/app/bufio"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/nightscout"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/streaming"
+	"google.golang.org/appengine"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	NIGHTSCOUT_ENTRIES_ROUTE      = "nightscout_entries"
+	NIGHTSCOUT_ENTRIES_READ_ROUTE = "nightscout_entries_read"
+	NIGHTSCOUT_TREATMENTS_ROUTE   = "nightscout_treatments"
+	NIGHTSCOUT_VERIFY_AUTH_ROUTE  = "nightscout_verifyauth"
+
+	// Header holding the sha1 hex digest of the API secret
+	NIGHTSCOUT_API_SECRET_HEADER = "api-secret"
+
+	// Version of the Nightscout API we emulate, reported to uploaders checking the server status
+	NIGHTSCOUT_API_VERSION = "0.10.2"
+
+	NIGHTSCOUT_QUERY_PARAM_COUNT     = "count"
+	NIGHTSCOUT_DEFAULT_ENTRIES_COUNT = 10
+	// A week of reads every 5 minutes
+	NIGHTSCOUT_MAX_ENTRIES_COUNT = 2016
+
+	// Length in bytes of generated secrets, hex-encoded to twice as many characters
+	NIGHTSCOUT_SECRET_LENGTH = 16
+)
+
+// Represents the status of the server as returned to Nightscout uploaders
+type NightscoutStatus struct {
+	Status     string                   `json:"status"`
+	Name       string                   `json:"name"`
+	Version    string                   `json:"version"`
+	ServerTime time.Time                `json:"serverTime"`
+	ApiEnabled bool                     `json:"apiEnabled"`
+	Settings   NightscoutStatusSettings `json:"settings"`
+}
+
+type NightscoutStatusSettings struct {
+	Units string `json:"units"`
+}
+
+type nightscoutAuthenticatedHandler struct {
+	authenticatedHandler http.Handler
+}
+
+// ServeHTTP lets requests through if they carry the hash of a known API secret, like Nightscout does, or a valid OAuth
+// bearer token like the rest of the API
+func (handler *nightscoutAuthenticatedHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	secretHash := request.Header.Get(NIGHTSCOUT_API_SECRET_HEADER)
+	if secretHash == "" {
+		newOauthAuthenticationHandler(handler.authenticatedHandler).ServeHTTP(writer, request)
+		return
+	}
+
+	context := appengine.NewContext(request)
+	if _, err := store.GetNightscoutSecretUser(context, strings.ToLower(secretHash)); err == store.ErrNoSuchEntity {
+		http.Error(writer, "Unauthorized", http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		log.Warningf(context, "Error verifying nightscout secret: %v", err)
+		http.Error(writer, "Error verifying secret", 500)
+		return
+	}
+
+	handler.authenticatedHandler.ServeHTTP(writer, request)
+}
+
+func newNightscoutAuthenticationHandler(next http.Handler) *nightscoutAuthenticatedHandler {
+	return &nightscoutAuthenticatedHandler{next}
+}
+
+// CurrentNightscoutUser returns the user identified by the API secret hash or, in its absence, by the OAuth bearer token
+func CurrentNightscoutUser(request *http.Request) (user *ApiUser) {
+	secretHash := request.Header.Get(NIGHTSCOUT_API_SECRET_HEADER)
+	if secretHash == "" {
+		return CurrentApiUser(request)
+	}
+
+	if email, err := store.GetNightscoutSecretUser(appengine.NewContext(request), strings.ToLower(secretHash)); err == nil {
+		return &ApiUser{email}
+	}
+
+	return nil
+}
+
+func initNightscoutEndpoints(writer http.ResponseWriter, request *http.Request) {
+	muxRouter.Get(NIGHTSCOUT_ENTRIES_ROUTE).Handler(newNightscoutAuthenticationHandler(http.HandlerFunc(processNightscoutEntries)))
+	muxRouter.Get(NIGHTSCOUT_ENTRIES_READ_ROUTE).Handler(newNightscoutAuthenticationHandler(http.HandlerFunc(getNightscoutEntries)))
+	muxRouter.Get(NIGHTSCOUT_TREATMENTS_ROUTE).Handler(newNightscoutAuthenticationHandler(http.HandlerFunc(processNightscoutTreatments)))
+	muxRouter.Get(NIGHTSCOUT_VERIFY_AUTH_ROUTE).Handler(newNightscoutAuthenticationHandler(http.HandlerFunc(verifyNightscoutAuth)))
+}
+
+// nightscoutStatus returns the server status that uploaders check before sending data
+func nightscoutStatus(writer http.ResponseWriter, request *http.Request) {
+	status := NightscoutStatus{Status: "ok", Name: "glukit", Version: NIGHTSCOUT_API_VERSION, ServerTime: time.Now(), ApiEnabled: true,
+		Settings: NightscoutStatusSettings{Units: "mg/dl"}}
+
+	writeNightscoutResponse(writer, status)
+}
+
+// verifyNightscoutAuth confirms to uploaders that their credentials are valid, the authentication handler having
+// already rejected them otherwise
+func verifyNightscoutAuth(writer http.ResponseWriter, request *http.Request) {
+	writeNightscoutResponse(writer, map[string]string{"message": "OK"})
+}
+
+// processNightscoutEntries handles a Post of Nightscout entries and stores sgv entries as glucose reads and mbg entries
+// as calibrations
+func processNightscoutEntries(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := CurrentNightscoutUser(request)
+
+	glukitUser, err := store.GetGlukitUser(context, user.Email)
+	if err != nil {
+		log.Warningf(context, "Error getting user to process nightscout entries, user email is [%s]: %v", user.Email, err)
+		http.Error(writer, "Error getting user to process nightscout entries", 500)
+		return
+	}
+
+	entries, err := nightscout.DecodeEntries(request.Body)
+	if err != nil {
+		log.Warningf(context, "Error decoding nightscout entries for user [%s]: %v", user.Email, err)
+		http.Error(writer, fmt.Sprintf("Error decoding data: %v", err), 400)
+		return
+	}
+
+	reads, calibrations, err := nightscout.ToGlucoseData(entries)
+	if err != nil {
+		log.Warningf(context, "Error mapping nightscout entries for user [%s]: %v", user.Email, err)
+		http.Error(writer, fmt.Sprintf("Error decoding data: %v", err), 400)
+		return
+	}
+
+	if len(reads) > 0 {
+		glucoseReadStreamer := streaming.NewStreamerDuration(bufio.NewWriterSize(apimodel.GLUCOSE_READ_KIND, store.NewDataStoreGlucoseReadBatchWriter(context, user.Email), store.GLUKIT_SCORE_PUT_MULTI_SIZE), apimodel.DAY_OF_DATA_DURATION)
+		if glucoseReadStreamer, err = glucoseReadStreamer.WriteAll(reads); err == nil {
+			_, err = glucoseReadStreamer.Close()
+		}
+		if err != nil {
+			log.Warningf(context, "Error storing glucose reads from nightscout entries for user [%s]: %v", user.Email, err)
+			http.Error(writer, fmt.Sprintf("Error storing data: %v", err), 502)
+			return
+		}
+	}
+
+	if len(calibrations) > 0 {
+		calibrationStreamer := streaming.NewStreamerDuration(bufio.NewWriterSize(apimodel.CALIBRATION_READ_KIND, store.NewDataStoreBatchWriter(context, user.Email, apimodel.CALIBRATION_READ_KIND), store.GLUKIT_SCORE_PUT_MULTI_SIZE), apimodel.DAY_OF_DATA_DURATION)
+		if calibrationStreamer, err = calibrationStreamer.WriteAll(calibrations); err == nil {
+			_, err = calibrationStreamer.Close()
+		}
+		if err != nil {
+			log.Warningf(context, "Error storing calibrations from nightscout entries for user [%s]: %v", user.Email, err)
+			http.Error(writer, fmt.Sprintf("Error storing data: %v", err), 502)
+			return
+		}
+	}
+
+	if len(reads) > 0 {
+		engine.StartUserCalculations(context, glukitUser)
+	}
+
+	log.Infof(context, "Wrote [%d] glucose reads and [%d] calibrations from nightscout entries for user [%s]", len(reads), len(calibrations), user.Email)
+	writeNightscoutResponse(writer, entries)
+}
+
+// processNightscoutTreatments handles a Post of Nightscout treatments and stores them as injections, meals and exercises
+func processNightscoutTreatments(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := CurrentNightscoutUser(request)
+
+	_, err := store.GetGlukitUser(context, user.Email)
+	if err != nil {
+		log.Warningf(context, "Error getting user to process nightscout treatments, user email is [%s]: %v", user.Email, err)
+		http.Error(writer, "Error getting user to process nightscout treatments", 500)
+		return
+	}
+
+	treatments, err := nightscout.DecodeTreatments(request.Body)
+	if err != nil {
+		log.Warningf(context, "Error decoding nightscout treatments for user [%s]: %v", user.Email, err)
+		http.Error(writer, fmt.Sprintf("Error decoding data: %v", err), 400)
+		return
+	}
+
+	injections, meals, exercises, err := nightscout.ToUserEvents(treatments)
+	if err != nil {
+		log.Warningf(context, "Error mapping nightscout treatments for user [%s]: %v", user.Email, err)
+		http.Error(writer, fmt.Sprintf("Error decoding data: %v", err), 400)
+		return
+	}
+
+	if len(injections) > 0 {
+		injectionStreamer := streaming.NewStreamerDuration(bufio.NewWriterSize(apimodel.INJECTION_KIND, store.NewDataStoreBatchWriter(context, user.Email, apimodel.INJECTION_KIND), store.GLUKIT_SCORE_PUT_MULTI_SIZE), apimodel.DAY_OF_DATA_DURATION)
+		if injectionStreamer, err = injectionStreamer.WriteAll(injections); err == nil {
+			_, err = injectionStreamer.Close()
+		}
+		if err != nil {
+			log.Warningf(context, "Error storing injections from nightscout treatments for user [%s]: %v", user.Email, err)
+			http.Error(writer, fmt.Sprintf("Error storing data: %v", err), 502)
+			return
+		}
+	}
+
+	if len(meals) > 0 {
+		mealStreamer := streaming.NewStreamerDuration(bufio.NewWriterSize(apimodel.MEAL_KIND, store.NewDataStoreBatchWriter(context, user.Email, apimodel.MEAL_KIND), store.GLUKIT_SCORE_PUT_MULTI_SIZE), apimodel.DAY_OF_DATA_DURATION)
+		if mealStreamer, err = mealStreamer.WriteAll(meals); err == nil {
+			_, err = mealStreamer.Close()
+		}
+		if err != nil {
+			log.Warningf(context, "Error storing meals from nightscout treatments for user [%s]: %v", user.Email, err)
+			http.Error(writer, fmt.Sprintf("Error storing data: %v", err), 502)
+			return
+		}
+	}
+
+	if len(exercises) > 0 {
+		exerciseStreamer := streaming.NewStreamerDuration(bufio.NewWriterSize(apimodel.EXERCISE_KIND, store.NewDataStoreBatchWriter(context, user.Email, apimodel.EXERCISE_KIND), store.GLUKIT_SCORE_PUT_MULTI_SIZE), apimodel.DAY_OF_DATA_DURATION)
+		if exerciseStreamer, err = exerciseStreamer.WriteAll(exercises); err == nil {
+			_, err = exerciseStreamer.Close()
+		}
+		if err != nil {
+			log.Warningf(context, "Error storing exercises from nightscout treatments for user [%s]: %v", user.Email, err)
+			http.Error(writer, fmt.Sprintf("Error storing data: %v", err), 502)
+			return
+		}
+	}
+
+	log.Infof(context, "Wrote [%d] injections, [%d] meals and [%d] exercises from nightscout treatments for user [%s]", len(injections), len(meals), len(exercises), user.Email)
+	writeNightscoutResponse(writer, treatments)
+}
+
+// getNightscoutEntries handles a Get of Nightscout entries and returns the most recent sgv entries, up to count
+func getNightscoutEntries(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := CurrentNightscoutUser(request)
+
+	count := NIGHTSCOUT_DEFAULT_ENTRIES_COUNT
+	if rawCount := request.FormValue(NIGHTSCOUT_QUERY_PARAM_COUNT); len(rawCount) > 0 {
+		value, err := strconv.ParseInt(rawCount, 10, 32)
+		if err != nil || value < 1 || value > NIGHTSCOUT_MAX_ENTRIES_COUNT {
+			http.Error(writer, fmt.Sprintf("Invalid value for %s: [%s] must be between 1 and %d.", NIGHTSCOUT_QUERY_PARAM_COUNT,
+				rawCount, NIGHTSCOUT_MAX_ENTRIES_COUNT), 400)
+			return
+		}
+		count = int(value)
+	}
+
+	_, upperBound, err := store.GetUserData(context, user.Email)
+	if err == store.ErrNoImportedDataFound {
+		writeNightscoutResponse(writer, []nightscout.Entry{})
+		return
+	} else if err != nil {
+		log.Warningf(context, "Error getting user data for user [%s]: %v", user.Email, err)
+		http.Error(writer, fmt.Sprintf("Error getting data: %v", err), 500)
+		return
+	}
+
+	reads, err := store.GetGlucoseReads(context, user.Email, upperBound.Add(model.DEFAULT_LOOKBACK_PERIOD), upperBound)
+	if err != nil {
+		log.Warningf(context, "Error getting glucose reads for user [%s]: %v", user.Email, err)
+		http.Error(writer, fmt.Sprintf("Error getting data: %v", err), 500)
+		return
+	}
+
+	if len(reads) > count {
+		reads = reads[len(reads)-count:]
+	}
+
+	entries, err := nightscout.NewSgvEntries(reads)
+	if err != nil {
+		log.Warningf(context, "Error converting glucose reads to nightscout entries for user [%s]: %v", user.Email, err)
+		http.Error(writer, fmt.Sprintf("Error getting data: %v", err), 500)
+		return
+	}
+
+	writeNightscoutResponse(writer, entries)
+}
+
+// generateNightscoutSecret creates a new Nightscout API secret for the logged in user, replacing the previous one. The
+// secret is only returned this once since we only keep its hash.
+func generateNightscoutSecret(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := auth.CurrentUser(request)
+
+	secret, err := randomHex(NIGHTSCOUT_SECRET_LENGTH)
+	if err != nil {
+		log.Warningf(context, "Error generating nightscout secret for user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error generating secret", 500)
+		return
+	}
+
+	if err = store.StoreNightscoutSecret(context, user.Email, nightscout.HashSecret(secret)); err != nil {
+		log.Warningf(context, "Error storing nightscout secret for user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error storing secret", 500)
+		return
+	}
+
+	writeNightscoutResponse(writer, map[string]string{"apiSecret": secret})
+}
+
+// writeNightscoutResponse writes a Nightscout API response as json
+func writeNightscoutResponse(writer http.ResponseWriter, response interface{}) {
+	value := writer.Header()
+	value.Add("Content-type", "application/json")
+
+	enc := json.NewEncoder(writer)
+	enc.Encode(response)
+}
@@ -8,9 +8,8 @@ import (
 	"github.com/alexandre-normand/glukit/app/util"
 	"github.com/alexandre-normand/osin"
 	"google.golang.org/appengine"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/log"
-	"google.golang.org/appengine/user"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/auth"
 	"html/template"
 	"net/http"
 	"strings"
@@ -47,7 +46,7 @@ func initOauthProvider(writer http.ResponseWriter, request *http.Request) {
 	server = osin.NewServer(sconfig, store.NewOsinAppEngineStoreWithRequest(request))
 	muxRouter.Get(AUTHORIZE_ROUTE).HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		c := appengine.NewContext(req)
-		user := user.Current(c)
+		user := auth.CurrentUser(req)
 		resp := server.NewResponse()
 		req.ParseForm()
 		req.SetBasicAuth(req.Form.Get("client_id"), req.Form.Get("client_secret"))
@@ -57,14 +56,14 @@ func initOauthProvider(writer http.ResponseWriter, request *http.Request) {
 			ar.Authorized = true
 			ar.UserData = user.Email
 
-			_, _, _, err := store.GetUserData(c, user.Email)
-			if err == datastore.ErrNoSuchEntity {
+			_, _, err := store.GetUserData(c, user.Email)
+			if err == store.ErrNoSuchEntity {
 				log.Debugf(c, "Creating GlukitUser on first oauth access for [%s]: ", user.Email)
 				// If the user doesn't exist already, create it
 				glukitUser := model.GlukitUser{user.Email, "", "", time.Now(),
 					model.DIABETES_TYPE_1, "", util.GLUKIT_EPOCH_TIME, apimodel.UNDEFINED_GLUCOSE_READ,
-					model.UNDEFINED_SCORE, model.UNDEFINED_SCORE, false, "", time.Now(), model.UNDEFINED_A1C_ESTIMATE}
-				_, err = store.StoreUserProfile(c, time.Now(), glukitUser)
+					model.UNDEFINED_SCORE, model.UNDEFINED_SCORE, false, "", time.Now(), model.UNDEFINED_A1C_ESTIMATE, model.DEFAULT_GLUCOSE_TARGETS, model.A1C_FORMULA_ADAG, 0}
+				err = store.StoreUserProfile(c, time.Now(), glukitUser)
 				if err != nil {
 					resp.SetError(osin.E_SERVER_ERROR, fmt.Sprintf("Fail to initialize user for email [%s]: [%v]", user.Email, err))
 					resp.StatusCode = 500
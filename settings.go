@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/apimodel"
+	"github.com/alexandre-normand/glukit/app/auth"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/store"
+	"google.golang.org/appengine"
+	"net/http"
+	"time"
+)
+
+// A1CSettings are the formula a user's a1cs are estimated with and the glycation offset, learned from the user's lab
+// results, they're corrected with. The offset can't be set directly.
+type A1CSettings struct {
+	Formula         string  `json:"formula"`
+	GlycationOffset float64 `json:"glycationOffset"`
+}
+
+// glucoseTargets returns the glucose targets of the logged in user in the requested unit, defaulting to the unit of
+// the user's reads
+func glucoseTargets(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := auth.CurrentUser(request)
+
+	glukitUser, err := store.GetUserProfile(context, user.Email)
+	if err != nil {
+		log.Warningf(context, "Error getting profile of user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error getting glucose targets", 500)
+		return
+	}
+
+	writeGlucoseTargets(writer, request, glukitUser.GetGlucoseTargets(), settingsGlucoseUnit(request, glukitUser))
+}
+
+// updateGlucoseTargets replaces the glucose targets of the logged in user with the ones given as json in the body
+// of the request. Glucose values are in the requested unit, defaulting to the unit of the user's reads. Targets apply
+// to scores and metrics calculated from then on.
+func updateGlucoseTargets(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := auth.CurrentUser(request)
+
+	glukitUser, err := store.GetUserProfile(context, user.Email)
+	if err != nil {
+		log.Warningf(context, "Error getting profile of user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error updating glucose targets", 500)
+		return
+	}
+
+	var targets model.GlucoseTargets
+	if err = json.NewDecoder(request.Body).Decode(&targets); err != nil {
+		http.Error(writer, "Invalid glucose targets: "+err.Error(), 400)
+		return
+	}
+
+	if err = targets.Validate(); err != nil {
+		http.Error(writer, err.Error(), 400)
+		return
+	}
+
+	unit := settingsGlucoseUnit(request, glukitUser)
+	if targets, err = targets.ConvertTo(unit, apimodel.MG_PER_DL); err != nil {
+		http.Error(writer, err.Error(), 400)
+		return
+	}
+
+	glukitUser.Targets = targets
+	if err = store.StoreUserProfile(context, time.Now(), *glukitUser); err != nil {
+		log.Warningf(context, "Error storing glucose targets of user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error updating glucose targets", 500)
+		return
+	}
+
+	log.Infof(context, "Updated glucose targets of user [%s] to [%v]", user.Email, targets)
+	writeGlucoseTargets(writer, request, targets, unit)
+}
+
+// settingsGlucoseUnit returns the unit requested with the unit parameter or the unit of the user's most recent read
+func settingsGlucoseUnit(request *http.Request, glukitUser *model.GlukitUser) (unit apimodel.GlucoseUnit) {
+	switch rawUnitValue := request.FormValue(GLUCOSE_UNIT_PARAMETER); rawUnitValue {
+	case apimodel.MG_PER_DL, apimodel.MMOL_PER_L:
+		return apimodel.GlucoseUnit(rawUnitValue)
+	}
+
+	if glukitUser.MostRecentRead.Unit == apimodel.MMOL_PER_L {
+		return apimodel.MMOL_PER_L
+	}
+
+	return apimodel.MG_PER_DL
+}
+
+func writeGlucoseTargets(writer http.ResponseWriter, request *http.Request, targets model.GlucoseTargets, unit apimodel.GlucoseUnit) {
+	context := appengine.NewContext(request)
+
+	convertedTargets, err := targets.ConvertTo(apimodel.MG_PER_DL, unit)
+	if err != nil {
+		log.Warningf(context, "Error converting glucose targets to [%s]: %v", unit, err)
+		http.Error(writer, "Error converting glucose targets", 500)
+		return
+	}
+
+	writer.Header().Add("Content-type", "application/json")
+
+	enc := json.NewEncoder(writer)
+	enc.Encode(struct {
+		Unit apimodel.GlucoseUnit `json:"unit"`
+		model.GlucoseTargets
+	}{unit, convertedTargets})
+}
+
+// alertSettings returns the alert rules of the logged in user, thresholds being in mg/dL
+func alertSettings(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := auth.CurrentUser(request)
+
+	settings, err := store.GetAlertSettings(context, user.Email)
+	if err == store.ErrNoSuchEntity {
+		settings = &model.AlertSettings{Rules: make([]model.AlertRule, 0)}
+	} else if err != nil {
+		log.Warningf(context, "Error getting alert settings of user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error getting alert settings", 500)
+		return
+	}
+
+	writer.Header().Add("Content-type", "application/json")
+	json.NewEncoder(writer).Encode(settings)
+}
+
+// updateAlertSettings replaces the alert rules of the logged in user with the ones given as json in the body of the
+// request. Thresholds are in mg/dL (mg/dL/min for rates of change) and apply to reads received from then on.
+func updateAlertSettings(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := auth.CurrentUser(request)
+
+	var settings model.AlertSettings
+	if err := json.NewDecoder(request.Body).Decode(&settings); err != nil {
+		http.Error(writer, "Invalid alert settings: "+err.Error(), 400)
+		return
+	}
+
+	if err := settings.Validate(); err != nil {
+		http.Error(writer, err.Error(), 400)
+		return
+	}
+
+	if settings.Rules == nil {
+		settings.Rules = make([]model.AlertRule, 0)
+	}
+
+	if err := store.StoreAlertSettings(context, user.Email, settings); err != nil {
+		log.Warningf(context, "Error storing alert settings of user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error updating alert settings", 500)
+		return
+	}
+
+	log.Infof(context, "Updated alert settings of user [%s] to [%v]", user.Email, settings)
+	writer.Header().Add("Content-type", "application/json")
+	json.NewEncoder(writer).Encode(settings)
+}
+
+// a1cSettings returns the a1c estimation settings of the logged in user
+func a1cSettings(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := auth.CurrentUser(request)
+
+	glukitUser, err := store.GetUserProfile(context, user.Email)
+	if err != nil {
+		log.Warningf(context, "Error getting profile of user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error getting a1c settings", 500)
+		return
+	}
+
+	writeA1CSettings(writer, glukitUser)
+}
+
+// updateA1CSettings sets the formula the logged in user's a1cs are estimated with to the one given as json in the body
+// of the request. The glycation offset is recalibrated for the new formula and both apply to a1cs estimated from then
+// on.
+func updateA1CSettings(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := auth.CurrentUser(request)
+
+	var settings A1CSettings
+	if err := json.NewDecoder(request.Body).Decode(&settings); err != nil {
+		http.Error(writer, "Invalid a1c settings: "+err.Error(), 400)
+		return
+	}
+
+	if !model.IsValidA1CFormula(settings.Formula) {
+		http.Error(writer, fmt.Sprintf("Invalid a1c formula [%s], must be one of [%s, %s].", settings.Formula,
+			model.A1C_FORMULA_ADAG, model.A1C_FORMULA_GMI), 400)
+		return
+	}
+
+	glukitUser, err := store.GetUserProfile(context, user.Email)
+	if err != nil {
+		log.Warningf(context, "Error getting profile of user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error updating a1c settings", 500)
+		return
+	}
+
+	glukitUser.A1CFormula = settings.Formula
+	if err = engine.CalibrateA1C(context, glukitUser); err != nil {
+		log.Warningf(context, "Error updating a1c settings of user [%s]: %v", user.Email, err)
+		http.Error(writer, "Error updating a1c settings", 500)
+		return
+	}
+
+	log.Infof(context, "Updated a1c formula of user [%s] to [%s]", user.Email, settings.Formula)
+	writeA1CSettings(writer, glukitUser)
+}
+
+func writeA1CSettings(writer http.ResponseWriter, glukitUser *model.GlukitUser) {
+	writer.Header().Add("Content-type", "application/json")
+	json.NewEncoder(writer).Encode(A1CSettings{Formula: glukitUser.GetA1CFormula(), GlycationOffset: glukitUser.GlycationOffset})
+}
@@ -0,0 +1,234 @@
+//go:build standalone
+// +build standalone
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/auth"
+	"github.com/alexandre-normand/glukit/app/config"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/notifier"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/tasks"
+	"github.com/alexandre-normand/osin"
+	stdlog "log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	AUTH_MODE_STATIC = "static"
+	AUTH_MODE_HEADER = "header"
+)
+
+var (
+	listenAddress   = flag.String("addr", ":8080", "Address to listen on")
+	host            = flag.String("host", "localhost:8080", "Host the server is reachable at")
+	sslHost         = flag.String("sslhost", "", "Base url of the server including the scheme (defaults to http://<host>)")
+	dataFile        = flag.String("data", "glukit.db", "File where shared data is stored, next to a <file>.users directory of user data, empty to only keep data in memory")
+	authMode        = flag.String("auth", AUTH_MODE_STATIC, "How users are identified: static (single user) or header (trusted reverse proxy)")
+	staticUserEmail = flag.String("user", "", "Email of the single user when -auth=static")
+	authHeader      = flag.String("authheader", "X-Forwarded-Email", "Header holding the user's email when -auth=header")
+	adminEmails     = flag.String("admins", "", "Comma-separated emails of administrators when -auth=header")
+	loginPage       = flag.String("loginurl", "", "Url of the login page of the reverse proxy when -auth=header")
+	oauthClient     = flag.String("oauthclient", "", "API client to register as id:secret:redirectUri")
+	smtpAddress     = flag.String("smtp", "", "Address (host:port) of the smtp server alerts are emailed through, empty to not send emails")
+	smtpFrom        = flag.String("smtpfrom", "glukit@localhost", "Sender of alert emails")
+	smtpUser        = flag.String("smtpuser", "", "Username to authenticate with the smtp server, empty to not authenticate")
+	smtpPassword    = flag.String("smtppassword", "", "Password to authenticate with the smtp server")
+	fillGaps        = flag.Duration("fillgaps", 0, "Longest gap in reads filled by interpolation for glukit scores and a1c estimates, 0 to not fill gaps")
+	insulinShape    = flag.String("insulinshape", "", "Shape of the action of rapid-acting insulins: exponential (the default) or bilinear")
+	insulinPeak     = flag.Duration("insulinpeak", 0, "Peak of the action of rapid-acting insulins, 0 for the default (1h15m)")
+	insulinDuration = flag.Duration("insulinduration", 0, "Duration of the action of rapid-acting insulins, 0 for the default (6h)")
+	longInsulin     = flag.Duration("longinsulinduration", 0, "Duration of the action of long-acting insulins, 0 for the default (24h)")
+	carbsAbsorption = flag.Duration("carbsabsorption", 0, "Duration of the absorption of carbs, 0 for the default (3h)")
+)
+
+const (
+	// How often users monitoring missing data are checked, like cron.yaml does on App Engine
+	MISSING_DATA_CHECK_INTERVAL = 15 * time.Minute
+	// How often patterns are detected in the reads of all users, like cron.yaml does on App Engine
+	PATTERN_DETECTION_INTERVAL = 7 * 24 * time.Hour
+)
+
+// Paths that require a logged in user, as declared in app.yaml. The value is true if the user must be an admin.
+var loginRequiredPaths = map[string]bool{
+	"/browse":           false,
+	"/report":           false,
+	"/data":             false,
+	"/googleauth":       false,
+	"/authorize":        false,
+	"/nightscoutsecret": false,
+	"/upload":           false,
+	"/upload/":          false,
+	"/export":           false,
+	"/account/":         false,
+	"/settings/":        false,
+	"/alerts":           false,
+	"/laba1cs":          false,
+	"/initpower":        true,
+	"/admin/":           true,
+}
+
+// Static content served by App Engine, as declared in app.yaml
+var staticDirs = map[string]string{
+	"/js/":               "view/js",
+	"/css/":              "view/css",
+	"/bower_components/": "view/bower_components",
+	"/images/":           "view/images",
+	"/fonts/":            "view/fonts",
+}
+
+// main starts glukit as a standalone http server. Storage is kept in a local file, tasks run in-process and users
+// are identified by the configured authenticator. It must be run from the root of the repository so that templates
+// and static content are found.
+func main() {
+	flag.Parse()
+
+	if *sslHost == "" {
+		*sslHost = "http://" + *host
+	}
+	appConfig = config.NewStandaloneAppConfig(*host, *sslHost, *fillGaps, config.OnBoardConfig{InsulinShape: *insulinShape, InsulinPeak: *insulinPeak,
+		InsulinDuration: *insulinDuration, LongActingInsulinDuration: *longInsulin, CarbsAbsorption: *carbsAbsorption})
+
+	if err := initStandaloneRepository(*dataFile); err != nil {
+		stdlog.Fatalf("Error initializing storage with data file [%s]: %v", *dataFile, err)
+	}
+
+	authenticator, err := newStandaloneAuthenticator()
+	if err != nil {
+		stdlog.Fatal(err)
+	}
+	auth.SetAuthenticator(authenticator)
+
+	tasks.SetRunner(tasks.NewInProcessRunner(DATASTORE_WRITES_QUEUE_NAME, REFRESH_QUEUE_NAME, engine.BATCH_CALCULATION_QUEUE_NAME,
+		engine.ALERTS_QUEUE_NAME))
+
+	webhookClient := notifier.NewPublicHttpClient()
+	notifier.SetHttpClientProvider(func(context context.Context) *http.Client {
+		return webhookClient
+	})
+	if *smtpAddress != "" {
+		notifier.SetSmtpServer(notifier.SmtpServer{Address: *smtpAddress, From: *smtpFrom, Username: *smtpUser, Password: *smtpPassword})
+	}
+
+	configureEngine(appConfig)
+
+	if err := registerOauthClient(*oauthClient); err != nil {
+		stdlog.Fatalf("Error registering oauth client [%s]: %v", *oauthClient, err)
+	}
+
+	if err := resumeAccountPurges(context.Background()); err != nil {
+		stdlog.Fatalf("Error resuming purges of deleted accounts: %v", err)
+	}
+
+	initRoutes()
+
+	go checkMissingDataPeriodically(context.Background())
+	go detectPatternsPeriodically(context.Background())
+
+	stdlog.Printf("Serving glukit on [%s] for host [%s]", *listenAddress, *host)
+	stdlog.Fatal(http.ListenAndServe(*listenAddress, newStandaloneHandler()))
+}
+
+// initStandaloneRepository sets the store's repository to a file-backed one or a memory-only one if no file is given
+func initStandaloneRepository(path string) (err error) {
+	if path == "" {
+		store.SetRepository(store.NewMemoryRepository())
+		return nil
+	}
+
+	repository, err := store.NewFileRepository(path)
+	if err != nil {
+		return err
+	}
+	store.SetRepository(repository)
+
+	return nil
+}
+
+// newStandaloneAuthenticator returns the Authenticator for the configured authentication mode
+func newStandaloneAuthenticator() (authenticator auth.Authenticator, err error) {
+	switch *authMode {
+	case AUTH_MODE_STATIC:
+		if *staticUserEmail == "" {
+			return nil, fmt.Errorf("-user is required with -auth=%s", AUTH_MODE_STATIC)
+		}
+		return auth.StaticAuthenticator{Email: *staticUserEmail}, nil
+	case AUTH_MODE_HEADER:
+		admins := make([]string, 0)
+		for _, admin := range strings.Split(*adminEmails, ",") {
+			if admin = strings.TrimSpace(admin); admin != "" {
+				admins = append(admins, admin)
+			}
+		}
+		return auth.HeaderAuthenticator{Header: *authHeader, AdminEmails: admins, LoginPage: *loginPage}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported authentication mode [%s], must be one of [%s, %s]", *authMode, AUTH_MODE_STATIC, AUTH_MODE_HEADER)
+	}
+}
+
+// registerOauthClient adds the API client given as id:secret:redirectUri, if any. App Engine deployments have their
+// clients provisioned in the datastore directly.
+func registerOauthClient(client string) (err error) {
+	if client == "" {
+		return nil
+	}
+
+	parts := strings.SplitN(client, ":", 3)
+	if len(parts) != 3 {
+		return fmt.Errorf("Expected client as id:secret:redirectUri")
+	}
+
+	context := context.Background()
+	osinStore := store.NewOsinAppEngineStoreWithContext(context)
+	return osinStore.AddClientWithContext(&osin.Client{Id: parts[0], Secret: parts[1], RedirectUri: parts[2], UserData: ""}, context)
+}
+
+// newStandaloneHandler returns the handler that does what App Engine does given app.yaml: serve static content,
+// enforce login where required and route everything else to the mux router.
+func newStandaloneHandler() http.Handler {
+	serveMux := http.NewServeMux()
+
+	for path, dir := range staticDirs {
+		serveMux.Handle(path, http.StripPrefix(path, http.FileServer(http.Dir(dir))))
+	}
+	serveMux.HandleFunc("/favicon.ico", func(writer http.ResponseWriter, request *http.Request) {
+		http.ServeFile(writer, request, "view/images/Glukit.ico")
+	})
+
+	for path, admin := range loginRequiredPaths {
+		serveMux.Handle(path, auth.RequireLogin(muxRouter, admin))
+	}
+	serveMux.Handle("/", muxRouter)
+
+	return serveMux
+}
+
+// checkMissingDataPeriodically runs the check of users monitoring missing data every MISSING_DATA_CHECK_INTERVAL, App Engine
+// deployments relying on cron instead
+func checkMissingDataPeriodically(context context.Context) {
+	ticker := time.NewTicker(MISSING_DATA_CHECK_INTERVAL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		engine.RunMissingDataCheck(context)
+	}
+}
+
+// detectPatternsPeriodically queues the detection of patterns for all users every PATTERN_DETECTION_INTERVAL, App
+// Engine deployments relying on cron instead
+func detectPatternsPeriodically(context context.Context) {
+	ticker := time.NewTicker(PATTERN_DETECTION_INTERVAL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := engine.StartPatternDetection(context); err != nil {
+			stdlog.Printf("Error starting pattern detection: %v", err)
+		}
+	}
+}
@@ -6,19 +6,19 @@ import (
 	"github.com/alexandre-normand/glukit/app/importer"
 	"github.com/alexandre-normand/glukit/app/model"
 	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/tasks"
 	"github.com/alexandre-normand/glukit/app/util"
 	"context"
 	"google.golang.org/appengine/channel"
-	"google.golang.org/appengine/datastore"
-	"google.golang.org/appengine/delay"
-	"google.golang.org/appengine/log"
+	"github.com/alexandre-normand/glukit/app/log"
 	"os"
 )
 
-var processDemoFile = delay.Func("processDemoFile", processStaticDemoFile)
+var processDemoFile = tasks.Func("processDemoFile", processStaticDemoFile)
 
 const (
 	DATASTORE_WRITES_QUEUE_NAME = "datastore-writes"
+	REFRESH_QUEUE_NAME          = "refresh"
 )
 
 func disabledUpdateUserData(context context.Context, userEmail string, autoScheduleNextRun bool) {
@@ -26,7 +26,7 @@ func disabledUpdateUserData(context context.Context, userEmail string, autoSched
 }
 
 // processStaticDemoFile imports the static resource included with the app for the demo user
-func processStaticDemoFile(context context.Context, userProfileKey *datastore.Key) {
+func processStaticDemoFile(context context.Context, userEmail string) {
 
 	// open input file
 	fi, err := os.Open("data.xml")
@@ -42,27 +42,19 @@ func processStaticDemoFile(context context.Context, userProfileKey *datastore.Ke
 	// make a read buffer
 	reader := bufio.NewReader(fi)
 
-	lastReadTime, err := importer.ParseContent(context, reader, userProfileKey, util.GLUKIT_EPOCH_TIME,
-		store.StoreDaysOfReads, store.StoreDaysOfMeals, store.StoreDaysOfInjections, store.StoreDaysOfExercises)
+	lastReadTime, err := importer.ParseContent(context, reader, userEmail, util.GLUKIT_EPOCH_TIME)
 
 	if err != nil {
 		util.Propagate(err)
 	}
 
-	store.LogFileImport(context, userProfileKey, model.FileImportLog{Id: "demo", Md5Checksum: "dummychecksum",
+	store.LogFileImport(context, userEmail, model.FileImportLog{Id: "demo", Md5Checksum: "dummychecksum",
 		LastDataProcessed: lastReadTime, ImportResult: "Success"})
 
-	if userProfile, err := store.GetUserProfile(context, userProfileKey); err != nil {
+	if userProfile, err := store.GetUserProfile(context, userEmail); err != nil {
 		log.Warningf(context, "Error while persisting score for %s: %v", DEMO_EMAIL, err)
 	} else {
-		if err := engine.StartGlukitScoreBatch(context, userProfile); err != nil {
-			log.Warningf(context, "Error while starting batch calculation of glukit scores for %s: %v", DEMO_EMAIL, err)
-		}
-
-		err = engine.StartA1CCalculationBatch(context, userProfile)
-		if err != nil {
-			log.Warningf(context, "Error starting a1c calculation batch for user [%s]: %v", DEMO_EMAIL, err)
-		}
+		engine.StartUserCalculations(context, userProfile)
 	}
 
 	channel.Send(context, DEMO_EMAIL, "Refresh")
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/alexandre-normand/glukit/app/auth"
+	"github.com/alexandre-normand/glukit/app/engine"
+	"github.com/alexandre-normand/glukit/app/importer"
+	"github.com/alexandre-normand/glukit/app/log"
+	"github.com/alexandre-normand/glukit/app/model"
+	"github.com/alexandre-normand/glukit/app/store"
+	"github.com/alexandre-normand/glukit/app/tasks"
+	"github.com/alexandre-normand/glukit/app/util"
+	"google.golang.org/appengine"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	UPLOAD_FILE_FIELD     = "file"
+	UPLOAD_TIMEZONE_FIELD = "timezone"
+
+	// Prefix of the FileImportLog ids of uploaded files, followed by the uploaded file name
+	DEXCOM_FILE_ID_PREFIX  = "dexcom-"
+	CLARITY_FILE_ID_PREFIX = "clarity-"
+
+	// Import results of a FileImportLog. Failures carry their error after the prefix.
+	IMPORT_RESULT_SUCCESS        = "Success"
+	IMPORT_RESULT_PENDING        = "Pending"
+	IMPORT_RESULT_FAILURE_PREFIX = "Failed: "
+
+	// Uploads larger than this are kept on disk while they're being parsed
+	UPLOAD_MAX_MEMORY = 8 << 20
+)
+
+var processUploadedFile = tasks.Func("processUploadedFile", importUploadedFile)
+var processUploadedClarityFile = tasks.Func("processUploadedClarityFile", importUploadedClarityFile)
+
+// uploadFile handles the upload of a Dexcom xml export by the logged in user. The file is kept until it's imported on
+// the datastore writes queue. A file that was already imported successfully is skipped if it hasn't changed (same
+// checksum) and an updated file is only imported from where the last import of that file left off.
+func uploadFile(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := auth.CurrentUser(request)
+
+	if _, err := store.GetGlukitUser(context, user.Email); err != nil {
+		log.Warningf(context, "Error getting user to process upload, user email is [%s]: %v", user.Email, err)
+		http.Error(writer, "Error getting user to process upload", 500)
+		return
+	}
+
+	content, filename, ok := readUploadedFile(writer, request)
+	if !ok {
+		return
+	}
+
+	queueFileImport(context, writer, user.Email, DEXCOM_FILE_ID_PREFIX+filename, content, processUploadedFile)
+}
+
+// uploadClarityFile handles the upload of a Dexcom Clarity csv export by the logged in user. Since Clarity timestamps
+// don't carry their timezone, it is taken from the timezone field, defaulting to the timezone of the user profile.
+// Like xml exports, the file is imported on the datastore writes queue, skipped if it hasn't changed and only imported
+// from where the last import of that file left off.
+func uploadClarityFile(writer http.ResponseWriter, request *http.Request) {
+	context := appengine.NewContext(request)
+	user := auth.CurrentUser(request)
+
+	glukitUser, err := store.GetGlukitUser(context, user.Email)
+	if err != nil {
+		log.Warningf(context, "Error getting user to process clarity upload, user email is [%s]: %v", user.Email, err)
+		http.Error(writer, "Error getting user to process upload", 500)
+		return
+	}
+
+	content, filename, ok := readUploadedFile(writer, request)
+	if !ok {
+		return
+	}
+
+	timezone := request.FormValue(UPLOAD_TIMEZONE_FIELD)
+	if timezone == "" {
+		timezone = glukitUser.Timezone
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("Invalid timezone [%s]: %v", timezone, err), 400)
+		return
+	}
+
+	queueFileImport(context, writer, user.Email, CLARITY_FILE_ID_PREFIX+filename, content, processUploadedClarityFile, location.String())
+}
+
+// readUploadedFile reads the file of an upload form. If the upload is invalid, the error is written to the response
+// and ok is false.
+func readUploadedFile(writer http.ResponseWriter, request *http.Request) (content []byte, filename string, ok bool) {
+	if err := request.ParseMultipartForm(UPLOAD_MAX_MEMORY); err != nil {
+		http.Error(writer, fmt.Sprintf("Invalid upload: %v", err), 400)
+		return nil, "", false
+	}
+
+	file, header, err := request.FormFile(UPLOAD_FILE_FIELD)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("Missing file in field [%s]: %v", UPLOAD_FILE_FIELD, err), 400)
+		return nil, "", false
+	}
+	defer file.Close()
+
+	content, err = ioutil.ReadAll(file)
+	if err != nil {
+		http.Error(writer, fmt.Sprintf("Error reading file: %v", err), 400)
+		return nil, "", false
+	} else if len(content) == 0 {
+		http.Error(writer, "Empty file", 400)
+		return nil, "", false
+	}
+
+	return content, header.Filename, true
+}
+
+// queueFileImport keeps an uploaded file and queues its import by importTask, called with the user email, the file id
+// and args. A file that was already imported successfully is skipped if it hasn't changed (same checksum) and an
+// updated file is only imported from where the last import of that file left off.
+func queueFileImport(context context.Context, writer http.ResponseWriter, userEmail string, fileId string, content []byte, importTask *tasks.Function, args ...interface{}) {
+	checksum := md5.Sum(content)
+	fileImport := model.FileImportLog{Id: fileId, Md5Checksum: hex.EncodeToString(checksum[:]),
+		LastDataProcessed: util.GLUKIT_EPOCH_TIME, ImportResult: IMPORT_RESULT_PENDING}
+
+	existingImport, err := store.GetFileImportLog(context, userEmail, fileImport.Id)
+	if err == nil {
+		if existingImport.Md5Checksum == fileImport.Md5Checksum && existingImport.ImportResult == IMPORT_RESULT_SUCCESS {
+			log.Infof(context, "Skipping import of unchanged file [%s] for user [%s]", fileImport.Id, userEmail)
+			writeFileImportLog(writer, http.StatusOK, *existingImport)
+			return
+		}
+		fileImport.LastDataProcessed = existingImport.LastDataProcessed
+	} else if err != store.ErrNoSuchEntity {
+		log.Warningf(context, "Error getting file import log [%s] for user [%s]: %v", fileImport.Id, userEmail, err)
+		http.Error(writer, "Error getting previous imports", 500)
+		return
+	}
+
+	if err = store.StoreUploadedFile(context, userEmail, fileImport.Id, content); err != nil {
+		log.Warningf(context, "Error storing uploaded file [%s] for user [%s]: %v", fileImport.Id, userEmail, err)
+		http.Error(writer, fmt.Sprintf("Error storing file: %v", err), 502)
+		return
+	}
+
+	if err = store.LogFileImport(context, userEmail, fileImport); err != nil {
+		http.Error(writer, fmt.Sprintf("Error storing file: %v", err), 502)
+		return
+	}
+
+	if err = importTask.Add(context, DATASTORE_WRITES_QUEUE_NAME, append([]interface{}{userEmail, fileImport.Id}, args...)...); err != nil {
+		log.Warningf(context, "Error queuing import of file [%s] for user [%s]: %v", fileImport.Id, userEmail, err)
+		http.Error(writer, "Error queuing import", 500)
+		return
+	}
+
+	writeFileImportLog(writer, http.StatusAccepted, fileImport)
+}
+
+// importUploadedFile imports a Dexcom xml file uploaded by a user from where the last import of that file left off
+// and then starts the calculation of the glukit scores and a1c estimates.
+func importUploadedFile(context context.Context, userEmail string, fileId string) {
+	importFile(context, userEmail, fileId, func(content io.Reader, startTime time.Time) (lastReadTime time.Time, err error) {
+		return importer.ParseContent(context, content, userEmail, startTime)
+	})
+}
+
+// importUploadedClarityFile imports a Dexcom Clarity csv file uploaded by a user, with its timestamps in the given
+// timezone, from where the last import of that file left off and then starts the calculation of the glukit scores and
+// a1c estimates.
+func importUploadedClarityFile(context context.Context, userEmail string, fileId string, timezone string) {
+	importFile(context, userEmail, fileId, func(content io.Reader, startTime time.Time) (lastReadTime time.Time, err error) {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return startTime, err
+		}
+
+		return importer.ParseClarityContent(context, content, userEmail, location, startTime)
+	})
+}
+
+// importFile parses an uploaded file from where the last import of that file left off and records the result in the
+// file import log. Failures aren't retried since the file would fail the same way.
+func importFile(context context.Context, userEmail string, fileId string, parse func(content io.Reader, startTime time.Time) (lastReadTime time.Time, err error)) {
+	fileImport, err := store.GetFileImportLog(context, userEmail, fileId)
+	if err != nil {
+		log.Warningf(context, "Error getting file import log [%s] for user [%s], skipping import: %v", fileId, userEmail, err)
+		return
+	}
+
+	content, err := store.GetUploadedFile(context, userEmail, fileId)
+	if err != nil {
+		log.Warningf(context, "Error getting uploaded file [%s] for user [%s], skipping import: %v", fileId, userEmail, err)
+		return
+	}
+
+	lastReadTime, err := parse(bytes.NewReader(content), fileImport.LastDataProcessed)
+	if err != nil {
+		log.Warningf(context, "Error importing file [%s] for user [%s]: %v", fileId, userEmail, err)
+		fileImport.ImportResult = IMPORT_RESULT_FAILURE_PREFIX + err.Error()
+	} else {
+		log.Infof(context, "Imported file [%s] for user [%s] up to [%s]", fileId, userEmail, lastReadTime)
+		fileImport.LastDataProcessed = lastReadTime
+		fileImport.ImportResult = IMPORT_RESULT_SUCCESS
+	}
+
+	store.LogFileImport(context, userEmail, *fileImport)
+
+	if err = store.DeleteUploadedFile(context, userEmail, fileId); err != nil {
+		log.Warningf(context, "Error deleting uploaded file [%s] for user [%s]: %v", fileId, userEmail, err)
+	}
+
+	if fileImport.ImportResult != IMPORT_RESULT_SUCCESS {
+		return
+	}
+
+	if userProfile, err := store.GetUserProfile(context, userEmail); err != nil {
+		log.Warningf(context, "Error getting user profile [%s] to start calculation batches: %v", userEmail, err)
+	} else {
+		engine.StartUserCalculations(context, userProfile)
+	}
+}
+
+// writeFileImportLog writes the log of a file import as json, with the given status code
+func writeFileImportLog(writer http.ResponseWriter, statusCode int, fileImport model.FileImportLog) {
+	writer.Header().Add("Content-type", "application/json")
+	writer.WriteHeader(statusCode)
+
+	enc := json.NewEncoder(writer)
+	enc.Encode(fileImport)
+}
@@ -79,6 +79,16 @@ function showDataBrowser(pathPrefix, unit) {
         .y(function(d) {
             return y(d.y);
         });
+    var forecastBand = d3.svg.area()
+        .x(function(d) {
+            return x(d.date);
+        })
+        .y0(function(d) {
+            return y(d.low);
+        })
+        .y1(function(d) {
+            return y(d.high);
+        });
     var viewfinderLine = d3.svg.line()
         .x(function(d) {
             return x2(d.date);
@@ -175,7 +185,7 @@ function showDataBrowser(pathPrefix, unit) {
             .attr("d", focusLine);
         var hoverbox = d3.select("#hoverbox");
         var chartContainerElement = d3.select("#chart_container")[0][0];
-        context.append("g")
+        var viewfinderAxis = context.append("g")
             .attr("class", "x axis")
             .attr("transform", "translate(0," + viewfinderHeight + ")")
             .call(xAxis2);
@@ -241,6 +251,8 @@ function showDataBrowser(pathPrefix, unit) {
             x.domain(brush.empty() ? x2.domain() : brush.extent());
             focus.selectAll("path.self").attr("d", glucoseLine);
             focus.selectAll("path.steadySailor").attr("d", glucoseLine);
+            focus.selectAll("path.forecast").attr("d", glucoseLine);
+            focus.selectAll("path.forecastBand").attr("d", forecastBand);
             focus.selectAll("#dayBoundaries")
             dayBoundaryGroup.selectAll(".dayBoundary")
                 .attr("x", function(d) {
@@ -331,6 +343,54 @@ function showDataBrowser(pathPrefix, unit) {
                 addToGraph(focus, "steadySailor", context, sailorSegments, steadySailor, y, glucoseLine, false);
             }
         });
+        // Add the forecast following the most recent read as a dashed line within its prediction interval
+        d3.json("/" + pathPrefix + "forecast?unit=" + unit, function(error, data) {
+            if (data == undefined || data[0].data.length == 0) {
+                return;
+            }
+
+            var lastRead = glucoseReads[glucoseReads.length - 1];
+            var forecast = [lastRead];
+            var forecastInterval = [{
+                date: lastRead.date,
+                low: lastRead.y,
+                high: lastRead.y
+            }];
+            for (var i = 0; i < data[0].data.length; i++) {
+                var point = data[0].data[i];
+                point.date = parseDate(point.x * 1000);
+                forecast.push(point);
+                forecastInterval.push({
+                    date: point.date,
+                    low: data[1].data[i].y,
+                    high: data[2].data[i].y
+                });
+            }
+
+            // Extend the viewfinder to the end of the forecast and, if it showed the most recent day, keep it
+            // following the most recent data
+            var forecastEnd = forecast[forecast.length - 1].date;
+            var extent = brush.extent();
+            x2.domain([x2.domain()[0], forecastEnd]);
+            viewfinderAxis.call(xAxis2);
+            context.selectAll("path.viewfinder").attr("d", viewfinderLine);
+            if (extent[1].getTime() == lastRead.date.getTime()) {
+                brush.extent([new Date(extent[0].getTime() + forecastEnd.getTime() - extent[1].getTime()), forecastEnd]);
+            }
+            brushElement.call(brush);
+
+            focus.append("path")
+                .attr("class", "forecastBand")
+                .attr("clip-path", "url(#clip)")
+                .datum(forecastInterval)
+                .attr("d", forecastBand);
+            focus.append("path")
+                .attr("class", "forecast")
+                .attr("clip-path", "url(#clip)")
+                .datum(forecast)
+                .attr("d", glucoseLine);
+            brushed();
+        });
         addBackgroundAndHover(focus, glucoseReads, userEventGroups, width, height, x, y, focusCoordinates, chartContainerElement, hoverbox, focusLine, unit);
     });
 }
@@ -353,6 +413,7 @@ function addToGraph(focus, className, context, segments, glucoseReads, y, glucos
     }
     if (viewfinderLineFunc != undefined) {
         context.append("path")
+            .attr("class", "viewfinder")
             .datum(glucoseReads)
             .attr("d", viewfinderLineFunc);
     }